@@ -0,0 +1,988 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/internal/handlers"
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/backup"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/client"
+	"github.com/sarat/caddyproxymanager/pkg/events"
+	"github.com/sarat/caddyproxymanager/pkg/health"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/portcheck"
+	"github.com/sarat/caddyproxymanager/pkg/redact"
+	"github.com/sarat/caddyproxymanager/pkg/secrets"
+	"github.com/sarat/caddyproxymanager/pkg/update"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/webdav"
+)
+
+const contractBootstrapToken = "contract-bootstrap-token"
+
+// fakeCaddy is an in-memory stand-in for Caddy's admin API, enough for the manager to load,
+// read and patch its config
+type fakeCaddy struct {
+	mu     sync.Mutex
+	config []byte
+	writes int // accepted config changes
+}
+
+func (f *fakeCaddy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/config/":
+		w.Header().Set("Content-Type", "application/json")
+		if f.config == nil {
+			w.Write([]byte("null"))
+			return
+		}
+		w.Write(f.config)
+	case r.Method == http.MethodPost && (r.URL.Path == "/load" || r.URL.Path == "/config/"):
+		body, _ := io.ReadAll(r.Body)
+		// Like a Caddy build without the bandwidth handler module
+		if bytes.Contains(body, []byte(`"handler":"`+caddy.BandwidthHandler+`"`)) {
+			http.Error(w, `{"error":"loading handler modules: unknown module: http.handlers.`+caddy.BandwidthHandler+`"}`, http.StatusBadRequest)
+			return
+		}
+		if len(bytes.TrimSpace(body)) > 0 {
+			f.config = body
+		}
+		f.writes++
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/id/"):
+		f.patchID(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == "/reverse_proxy/upstreams":
+		w.Write([]byte("[]"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// patchID replaces the object with the @id in the path, as Caddy does
+func (f *fakeCaddy) patchID(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/id/")
+	var replacement, config any
+	if err := json.NewDecoder(r.Body).Decode(&replacement); err != nil || json.Unmarshal(f.config, &config) != nil {
+		http.Error(w, "invalid config", http.StatusBadRequest)
+		return
+	}
+
+	var replace func(node any) (any, bool)
+	replace = func(node any) (any, bool) {
+		switch value := node.(type) {
+		case map[string]any:
+			if value["@id"] == id {
+				return replacement, true
+			}
+			for key, child := range value {
+				if updated, found := replace(child); found {
+					value[key] = updated
+					return value, true
+				}
+			}
+		case []any:
+			for i, child := range value {
+				if updated, found := replace(child); found {
+					value[i] = updated
+					return value, true
+				}
+			}
+		}
+		return node, false
+	}
+
+	updated, found := replace(config)
+	if !found {
+		http.Error(w, "unknown object ID", http.StatusNotFound)
+		return
+	}
+	f.config, _ = json.Marshal(updated)
+	f.writes++
+}
+
+// newContractServer serves the manager's routes against a fake Caddy and returns a client signed
+// in as the admin
+func newContractServer(t *testing.T) (*client.Client, *httptest.Server) {
+	t.Helper()
+
+	apiClient, server, _ := newContractServerWithCaddy(t)
+	return apiClient, server
+}
+
+// newContractServerWithCaddy is newContractServer that also returns the fake Caddy, for tests
+// checking the config it was sent
+func newContractServerWithCaddy(t *testing.T) (*client.Client, *httptest.Server, *fakeCaddy) {
+	t.Helper()
+
+	fake := &fakeCaddy{}
+	caddyAdmin := httptest.NewServer(fake)
+	t.Cleanup(caddyAdmin.Close)
+
+	cfg := &serverConfig{dataDir: t.TempDir()}
+	cfg.configFile = filepath.Join(cfg.dataDir, "caddy-config.json")
+	if err := os.MkdirAll(filepath.Join(cfg.dataDir, "presets"), 0o755); err != nil {
+		t.Fatalf("failed to create presets directory: %v", err)
+	}
+	preset := `{"id": "gitea", "name": "Gitea", "proxy": {"target_port": 3000}}`
+	if err := os.WriteFile(filepath.Join(cfg.dataDir, "presets", "gitea.json"), []byte(preset), 0o644); err != nil {
+		t.Fatalf("failed to write preset: %v", err)
+	}
+
+	pageStore := newPageStore(cfg)
+	caddyClient := caddy.New(caddyAdmin.URL, cfg.configFile)
+	caddyClient.SetPages(pageStore)
+	box, err := secrets.New(make([]byte, 32))
+	if err != nil {
+		t.Fatalf("failed to create secrets box: %v", err)
+	}
+	caddyClient.SetSecrets(box)
+
+	healthService := health.NewService(nil)
+	healthService.SetHeaderOpener(caddyClient.OpenHealthCheckHeaders)
+	healthService.SetJitter(0) // first checks run right away
+	authStorage := auth.NewStorage(cfg.dataDir, auth.NewFileSessionStore(cfg.dataDir))
+	if err := authStorage.Initialize(); err != nil {
+		t.Fatalf("failed to initialize auth storage: %v", err)
+	}
+	auditService := audit.NewService(cfg.dataDir)
+	statusPoller := caddy.NewStatusPoller(caddyClient, time.Minute)
+	configWatcher := caddy.NewConfigWatcher(caddyClient, time.Minute)
+	eventBroker := events.NewBroker()
+	notifier := newNotifier(caddyClient, healthService, nil)
+
+	handler := handlers.New(caddyClient, healthService, auditService, statusPoller, configWatcher, nil, eventBroker)
+	handler.PortCheck = newPortChecker()
+	authHandler := handlers.NewAuthHandler(authStorage, auditService, contractBootstrapToken)
+	presetHandler := handlers.NewPresetHandler(loadPresets(cfg))
+	certificateHandler := handlers.NewCertificateHandler(newCertificateService(cfg, caddyClient))
+	updateHandler := handlers.NewUpdateHandler(update.NewUpdater("", updateCheckCacheTTL), auditService, nil)
+	pageHandler := handlers.NewPageHandler(pageStore, caddyClient, auditService)
+	backupHandler := handlers.NewBackupHandler(cfg.dataDir, caddyClient, authStorage, healthService, auditService, func() {})
+	var backupWaitGroup sync.WaitGroup
+	backupHandler.Remote = startRemoteBackups(context.Background(), cfg, auditService, notifier, nil, &backupWaitGroup)
+	notificationHandler := handlers.NewNotificationHandler(notifier, caddyClient, auditService)
+	authMiddleware := auth.NewMiddleware(authStorage)
+	authMiddleware.ProxyTags = caddyClient.ProxyTags
+
+	mux := http.NewServeMux()
+	setupRoutes(mux, handler, authHandler, presetHandler, certificateHandler, updateHandler, pageHandler, backupHandler, notificationHandler, authMiddleware.CORS, authMiddleware)
+	server := httptest.NewServer(handler.RedirectLegacyIDs(mux))
+	t.Cleanup(server.Close)
+
+	apiClient, err := client.New(server.URL, client.WithRetries(0, 0))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := apiClient.Setup(context.Background(), "admin", "contract-password-1", contractBootstrapToken); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	return apiClient, server, fake
+}
+
+// newContractProxy creates a proxy without TLS, so no certificate is requested for it
+func newContractProxy(t *testing.T, apiClient *client.Client, domain string) *models.Proxy {
+	t.Helper()
+
+	proxy, err := apiClient.CreateProxy(context.Background(), models.Proxy{
+		Domain:    domain,
+		TargetURL: "http://127.0.0.1:8080",
+		SSLMode:   handlers.SSLModeNone,
+	})
+	if err != nil {
+		t.Fatalf("CreateProxy(%s) failed: %v", domain, err)
+	}
+	if proxy.ID == "" || proxy.Domain != domain {
+		t.Fatalf("CreateProxy(%s) returned %+v", domain, proxy)
+	}
+	return proxy
+}
+
+func TestClientContractAuth(t *testing.T) {
+	apiClient, server := newContractServer(t)
+	ctx := context.Background()
+
+	status, err := apiClient.AuthStatus(ctx)
+	if err != nil || !status.IsSetup {
+		t.Fatalf("AuthStatus() = %+v, %v, want set up", status, err)
+	}
+
+	me, err := apiClient.Me(ctx)
+	if err != nil || me.User.Username != "admin" {
+		t.Fatalf("Me() = %+v, %v", me, err)
+	}
+
+	search, err := apiClient.CreateSavedSearch(ctx, client.SavedSearch{Name: "Down", Filters: map[string]string{"status": "unhealthy"}})
+	if err != nil || search.ID == "" || search.Filters["status"] != "unhealthy" {
+		t.Fatalf("CreateSavedSearch() = %+v, %v", search, err)
+	}
+	search, err = apiClient.UpdateSavedSearch(ctx, search.ID, client.SavedSearch{Name: "Down", Filters: map[string]string{"status": "unhealthy"}, Shared: true})
+	if err != nil || !search.Shared {
+		t.Fatalf("UpdateSavedSearch() = %+v, %v", search, err)
+	}
+	searches, err := apiClient.SavedSearches(ctx)
+	if err != nil || len(searches) != 1 {
+		t.Fatalf("SavedSearches() = %+v, %v", searches, err)
+	}
+	if err := apiClient.DeleteSavedSearch(ctx, search.ID); err != nil {
+		t.Fatalf("DeleteSavedSearch() failed: %v", err)
+	}
+
+	created, err := apiClient.CreateAPIToken(ctx, client.NewAPIToken{Name: "ci", Scopes: []string{"read"}})
+	if err != nil || created.Token == "" || created.APIToken.ID == "" {
+		t.Fatalf("CreateAPIToken() = %+v, %v", created, err)
+	}
+	tokens, err := apiClient.APITokens(ctx)
+	if err != nil || len(tokens) != 1 {
+		t.Fatalf("APITokens() = %+v, %v", tokens, err)
+	}
+
+	// A read-only token can read but not write
+	readOnly, _ := client.New(server.URL, client.WithToken(created.Token), client.WithRetries(0, 0))
+	if _, err := readOnly.ListProxies(ctx, client.ProxyListOptions{}); err != nil {
+		t.Fatalf("ListProxies() with a read token failed: %v", err)
+	}
+	var apiErr *client.APIError
+	if _, err := readOnly.CreateProxy(ctx, models.Proxy{Domain: "denied.example.com", TargetURL: "http://127.0.0.1:8080"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("CreateProxy() with a read token = %v, want a 403 API error", err)
+	}
+
+	if err := apiClient.DeleteAPIToken(ctx, created.APIToken.ID); err != nil {
+		t.Fatalf("DeleteAPIToken() failed: %v", err)
+	}
+}
+
+func TestClientContractProxySecretsMasked(t *testing.T) {
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	authorizations := make(chan string, 4)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case authorizations <- r.Header.Get("Authorization"):
+		default:
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	checkedWith := func(want string) {
+		t.Helper()
+		select {
+		case got := <-authorizations:
+			if got != want {
+				t.Fatalf("health check sent Authorization %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("health check didn't run")
+		}
+	}
+
+	created, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:             "secret.example.com",
+		TargetURL:          upstream.URL,
+		SSLMode:            handlers.SSLModeNone,
+		HealthCheckEnabled: true,
+		HealthCheckHeaders: map[string]string{"Authorization": "Bearer s3cret"},
+	})
+	if err != nil || created.HealthCheckHeaders["Authorization"] != redact.Mask {
+		t.Fatalf("CreateProxy() = %+v, %v, want the Authorization header masked", created, err)
+	}
+	checkedWith("Bearer s3cret")
+
+	details, err := apiClient.GetProxy(ctx, created.ID)
+	if err != nil || details.Proxy.HealthCheckHeaders["Authorization"] != redact.Mask {
+		t.Fatalf("GetProxy() = %+v, %v, want the Authorization header masked", details, err)
+	}
+	list, err := apiClient.ListProxies(ctx, client.ProxyListOptions{})
+	if err != nil || len(list.Proxies) != 1 || list.Proxies[0].HealthCheckHeaders["Authorization"] != redact.Mask {
+		t.Fatalf("ListProxies() = %+v, %v, want the Authorization header masked", list, err)
+	}
+
+	// Sending the masked value back keeps the stored one
+	details.Proxy.Notes = "updated"
+	if _, err := apiClient.UpdateProxy(ctx, details.Proxy); err != nil {
+		t.Fatalf("UpdateProxy() failed: %v", err)
+	}
+	checkedWith("Bearer s3cret")
+}
+
+func TestClientContractProxyCredentialsMasked(t *testing.T) {
+	apiClient, _, fake := newContractServerWithCaddy(t)
+	ctx := context.Background()
+
+	created, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:         "locked.example.com",
+		TargetURL:      "http://127.0.0.1:8080",
+		SSLMode:        handlers.SSLModeAuto,
+		ChallengeType:  "dns",
+		DNSProvider:    "cloudflare",
+		DNSCredentials: map[string]string{"api_token": "cf-s3cret"},
+		BasicAuth:      &models.BasicAuth{Enabled: true, Username: "ops", Password: "pa55word"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProxy() failed: %v", err)
+	}
+	if created.DNSCredentials["api_token"] != redact.Mask || created.BasicAuth.Password != redact.Mask {
+		t.Fatalf("CreateProxy() = %+v, want the DNS token and basic auth password masked", created)
+	}
+
+	details, err := apiClient.GetProxy(ctx, created.ID)
+	if err != nil || details.Proxy.DNSCredentials["api_token"] != redact.Mask || details.Proxy.BasicAuth.Password != redact.Mask {
+		t.Fatalf("GetProxy() = %+v, %v, want the DNS token and basic auth password masked", details, err)
+	}
+	generated, err := apiClient.GeneratedProxyConfig(ctx, created.ID)
+	if err != nil || strings.Contains(string(generated), "cf-s3cret") || strings.Contains(string(generated), "$2a$") {
+		t.Fatalf("GeneratedProxyConfig() = %s, %v, want the DNS token and password hash masked", generated, err)
+	}
+
+	// Sending the masked values back keeps the stored ones
+	details.Proxy.Notes = "updated"
+	if _, err := apiClient.UpdateProxy(ctx, details.Proxy); err != nil {
+		t.Fatalf("UpdateProxy() failed: %v", err)
+	}
+	fake.mu.Lock()
+	config := string(fake.config)
+	fake.mu.Unlock()
+	if !strings.Contains(config, `"cf-s3cret"`) {
+		t.Fatal("Caddy config lost the DNS token after updating with it masked")
+	}
+	hash := regexp.MustCompile(`"password":"([^"]+)","username":"ops"`).FindStringSubmatch(config)
+	if hash == nil || bcrypt.CompareHashAndPassword([]byte(hash[1]), []byte("pa55word")) != nil {
+		t.Fatal("Caddy config lost the basic auth password after updating with it masked")
+	}
+}
+
+func TestClientContractProxyAccessLog(t *testing.T) {
+	apiClient, _, fake := newContractServerWithCaddy(t)
+	ctx := context.Background()
+
+	running := func() models.CaddyConfig {
+		t.Helper()
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		var config models.CaddyConfig
+		if err := json.Unmarshal(fake.config, &config); err != nil {
+			t.Fatalf("Caddy config is invalid: %v", err)
+		}
+		return config
+	}
+
+	proxy, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:    "logged.example.com",
+		TargetURL: "http://127.0.0.1:8080",
+		SSLMode:   handlers.SSLModeNone,
+		AccessLog: "errors",
+	})
+	if err != nil {
+		t.Fatalf("CreateProxy() failed: %v", err)
+	}
+	if proxy.AccessLog != "errors" {
+		t.Fatalf("CreateProxy().AccessLog = %q, want errors", proxy.AccessLog)
+	}
+
+	loggerName := "cpm_access_" + proxy.ID
+	config := running()
+	proxyLog, exists := config.Logging.Logs[loggerName]
+	if !exists || !slices.Equal(proxyLog.Include, []string{"http.log.access." + loggerName}) ||
+		!slices.Equal(proxyLog.Exclude, []string{"http.log.access." + loggerName + ".ok"}) {
+		t.Fatalf("access log = %+v, want it to include the proxy's logger and exclude responses below 400", proxyLog)
+	}
+	server := config.Apps.HTTP.Servers["http_only"]
+	if server.Logs == nil || server.Logs.LoggerNames["logged.example.com"] != loggerName {
+		t.Fatalf("server logs = %+v, want the proxy's host mapped to its logger", server.Logs)
+	}
+	var handleResponse []models.CaddyResponseHandler
+	for _, route := range server.Routes {
+		for _, handler := range route.Handle {
+			if route.ID == proxy.ID && handler.Handler == "reverse_proxy" {
+				handleResponse = handler.HandleResponse
+			}
+		}
+	}
+	if len(handleResponse) != 1 || !slices.Equal(handleResponse[0].Match.StatusCode, []int{1, 2, 3}) ||
+		!slices.Equal(handleResponse[0].Routes[0].Handle[0].AccessLoggerNames, []string{loggerName + ".ok"}) {
+		t.Fatalf("handle_response = %+v, want responses below 400 logged to the excluded logger", handleResponse)
+	}
+
+	if err := os.WriteFile(proxyLog.Writer.Filename, []byte(`{"status":502}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var downloaded bytes.Buffer
+	if err := apiClient.DownloadAccessLog(ctx, proxy.ID, &downloaded); err != nil || downloaded.String() != `{"status":502}`+"\n" {
+		t.Fatalf("DownloadAccessLog() = %q, %v, want the log file", downloaded.String(), err)
+	}
+
+	proxy.AccessLog = "all"
+	if _, err := apiClient.UpdateProxy(ctx, *proxy); err != nil {
+		t.Fatalf("UpdateProxy() failed: %v", err)
+	}
+	config = running()
+	raw, _ := json.Marshal(config.Apps.HTTP.Servers)
+	if proxyLog, exists := config.Logging.Logs[loggerName]; !exists || len(proxyLog.Exclude) != 0 || strings.Contains(string(raw), "handle_response") {
+		t.Fatalf("access log = %+v, want every request logged after switching to all", proxyLog)
+	}
+
+	proxy.AccessLog = ""
+	if _, err := apiClient.UpdateProxy(ctx, *proxy); err != nil {
+		t.Fatalf("UpdateProxy() failed: %v", err)
+	}
+	config = running()
+	if config.Logging != nil {
+		if _, exists := config.Logging.Logs[loggerName]; exists {
+			t.Fatal("access log still configured after turning it off")
+		}
+	}
+	if logs := config.Apps.HTTP.Servers["http_only"].Logs; logs != nil && logs.LoggerNames["logged.example.com"] != "" {
+		t.Fatalf("server logs = %+v, want the host no longer mapped", logs)
+	}
+}
+
+func TestClientContractPortForward(t *testing.T) {
+	var checked []string
+	checkService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checked = append(checked, r.URL.RawQuery)
+		json.NewEncoder(w).Encode(map[string]bool{"reachable": r.URL.Query().Get("port") == "25565"})
+	}))
+	t.Cleanup(checkService.Close)
+	t.Setenv("PORT_CHECK_URL", checkService.URL+"/check?port={port}&protocol={protocol}")
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	stream, reachability, err := apiClient.CreatePortForward(ctx, client.PortForward{ExternalPort: 25565, Target: "192.168.1.20:25565"})
+	if err != nil {
+		t.Fatalf("CreatePortForward() failed: %v", err)
+	}
+	if stream.Protocol != "tcp" || stream.Listen != ":25565" || !slices.Equal(stream.Upstreams, []string{"192.168.1.20:25565"}) || stream.Name != "Port forward 25565" {
+		t.Fatalf("CreatePortForward() stream = %+v, want tcp :25565 to 192.168.1.20:25565", stream)
+	}
+	if reachability.Status != portcheck.StatusReachable || len(reachability.Hints) != 0 {
+		t.Fatalf("CreatePortForward() reachability = %+v, want reachable", reachability)
+	}
+
+	stream, reachability, err = apiClient.CreatePortForward(ctx, client.PortForward{Name: "Voice", Protocol: "udp", ExternalPort: 9987, Target: "192.168.1.20:9987"})
+	if err != nil {
+		t.Fatalf("CreatePortForward() failed: %v", err)
+	}
+	if reachability.Status != portcheck.StatusUnreachable || len(reachability.Hints) == 0 {
+		t.Fatalf("CreatePortForward() reachability = %+v, want unreachable with hints", reachability)
+	}
+	if !slices.Equal(checked, []string{"port=25565&protocol=tcp", "port=9987&protocol=udp"}) {
+		t.Fatalf("check service got %v, want one check per port forward", checked)
+	}
+
+	reachability, err = apiClient.StreamReachability(ctx, stream.ID)
+	if err != nil || reachability.Status != portcheck.StatusUnreachable || reachability.Port != 9987 {
+		t.Fatalf("StreamReachability() = %+v, %v, want udp 9987 unreachable", reachability, err)
+	}
+
+	if _, _, err := apiClient.CreatePortForward(ctx, client.PortForward{ExternalPort: 70000, Target: "192.168.1.20:22"}); err == nil {
+		t.Fatal("CreatePortForward() accepted port 70000")
+	}
+}
+
+func TestClientContractProxyHealthCheckPause(t *testing.T) {
+	apiClient, _, fake := newContractServerWithCaddy(t)
+	ctx := context.Background()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(upstream.Close)
+	proxy, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:              "paused.example.com",
+		TargetURL:           upstream.URL,
+		SSLMode:             handlers.SSLModeNone,
+		HealthCheckEnabled:  true,
+		HealthCheckInterval: "1h",
+		HealthCheckPath:     "/",
+	})
+	if err != nil {
+		t.Fatalf("CreateProxy() failed: %v", err)
+	}
+	var healthy *models.HealthStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if healthy, err = apiClient.ProxyHealth(ctx, proxy.ID); err == nil && healthy.Status == "Healthy" {
+			break
+		}
+	}
+	if healthy == nil || healthy.Status != "Healthy" {
+		t.Fatalf("ProxyHealth() = %+v, %v, want Healthy", healthy, err)
+	}
+
+	fake.mu.Lock()
+	writes := fake.writes
+	fake.mu.Unlock()
+	for _, paused := range []bool{true, false} {
+		status, err := apiClient.PauseProxyHealthCheck(ctx, proxy.ID, paused)
+		if err != nil || status.Status != "Healthy" || status.LastChecked != healthy.LastChecked || status.Paused != paused {
+			t.Fatalf("PauseProxyHealthCheck(%v) = %+v, %v, want the last status kept", paused, status, err)
+		}
+	}
+	fake.mu.Lock()
+	changes := fake.writes - writes
+	fake.mu.Unlock()
+	if changes != 0 {
+		t.Fatalf("pausing and resuming the health check made %d Caddy config changes, want none", changes)
+	}
+
+	details, err := apiClient.GetProxy(ctx, proxy.ID)
+	if err != nil || details.Proxy.HealthCheckPaused {
+		t.Fatalf("GetProxy() = %+v, %v, want the health check resumed", details, err)
+	}
+}
+
+func TestClientContractRejectedProxyLeavesNoMetadata(t *testing.T) {
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	_, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:         "limited.example.com",
+		TargetURL:      "http://127.0.0.1:8080",
+		SSLMode:        handlers.SSLModeNone,
+		BandwidthLimit: 1 << 20,
+	})
+	if err == nil {
+		t.Fatal("CreateProxy() with a bandwidth limit Caddy can't load succeeded")
+	}
+
+	// Metadata left behind would take the ID, numbering the next proxy for the domain
+	proxy := newContractProxy(t, apiClient, "limited.example.com")
+	if proxy.ID != "limited-example-com" || proxy.BandwidthLimit != 0 {
+		t.Fatalf("CreateProxy() after a rejected proxy = %+v, want ID limited-example-com without a bandwidth limit", proxy)
+	}
+}
+
+func TestClientContractProxyScopedAPIToken(t *testing.T) {
+	apiClient, server := newContractServer(t)
+	ctx := context.Background()
+
+	own := newContractProxy(t, apiClient, "own.example.com")
+	other := newContractProxy(t, apiClient, "other.example.com")
+	tagged, err := apiClient.CreateProxy(ctx, models.Proxy{
+		Domain:    "tagged.example.com",
+		TargetURL: "http://127.0.0.1:8080",
+		SSLMode:   handlers.SSLModeNone,
+		Tags:      []string{"ci"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProxy(tagged.example.com) failed: %v", err)
+	}
+
+	created, err := apiClient.CreateAPIToken(ctx, client.NewAPIToken{Name: "deploy", Scopes: []string{"write"}, ProxyIDs: []string{own.ID}, Tags: []string{"CI"}})
+	if err != nil || !slices.Equal(created.APIToken.ProxyIDs, []string{own.ID}) || !slices.Equal(created.APIToken.Tags, []string{"ci"}) {
+		t.Fatalf("CreateAPIToken() = %+v, %v", created, err)
+	}
+	scoped, _ := client.New(server.URL, client.WithToken(created.Token), client.WithRetries(0, 0))
+
+	for _, id := range []string{own.ID, tagged.ID} {
+		if _, err := scoped.GetProxy(ctx, id); err != nil {
+			t.Errorf("GetProxy(%s) with a token limited to it failed: %v", id, err)
+		}
+	}
+
+	// Other proxies and routes that aren't about a single proxy are refused
+	var apiErr *client.APIError
+	if _, err := scoped.GetProxy(ctx, other.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("GetProxy(%s) with a token limited to other proxies = %v, want a 403 API error", other.ID, err)
+	}
+	if _, err := scoped.ListProxies(ctx, client.ProxyListOptions{}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("ListProxies() with a proxy-limited token = %v, want a 403 API error", err)
+	}
+}
+
+func TestClientContractProxies(t *testing.T) {
+	apiClient, server := newContractServer(t)
+	ctx := context.Background()
+
+	proxy := newContractProxy(t, apiClient, "app.example.com")
+
+	details, err := apiClient.GetProxy(ctx, proxy.ID)
+	if err != nil || details.Proxy.ID != proxy.ID {
+		t.Fatalf("GetProxy() = %+v, %v", details, err)
+	}
+	if looked, err := apiClient.LookupProxy(ctx, "app.example.com"); err != nil || looked.Proxy.ID != proxy.ID {
+		t.Fatalf("LookupProxy() = %+v, %v", looked, err)
+	}
+	list, err := apiClient.ListProxies(ctx, client.ProxyListOptions{Query: "app"})
+	if err != nil || list.Count != 1 {
+		t.Fatalf("ListProxies() = %+v, %v", list, err)
+	}
+
+	patched, err := apiClient.PatchProxy(ctx, proxy.ID, map[string]any{"target_url": "http://127.0.0.1:9090"})
+	if err != nil || patched.TargetURL != "http://127.0.0.1:9090" {
+		t.Fatalf("PatchProxy() = %+v, %v", patched, err)
+	}
+
+	preview, err := apiClient.PreviewProxy(ctx, models.Proxy{Domain: "preview.example.com", TargetURL: "http://127.0.0.1:8080", SSLMode: handlers.SSLModeNone})
+	if err != nil || !preview.Valid || len(preview.Generated) == 0 {
+		t.Fatalf("PreviewProxy() = %+v, %v", preview, err)
+	}
+	if generated, err := apiClient.GeneratedProxyConfig(ctx, proxy.ID); err != nil || len(generated) == 0 {
+		t.Fatalf("GeneratedProxyConfig() = %s, %v", generated, err)
+	}
+
+	result, err := apiClient.ValidateProxy(ctx, handlers.WizardStepDomain, models.Proxy{Domain: "app.example.com"})
+	if err != nil || result.Valid || len(result.Checks) == 0 {
+		t.Fatalf("ValidateProxy() of a managed domain = %+v, %v, want a failed check", result, err)
+	}
+
+	connectivity, err := apiClient.TestProxyConnectivity(ctx, proxy.ID)
+	if err != nil || connectivity.ProxyID != proxy.ID || len(connectivity.Targets) != 1 {
+		t.Fatalf("TestProxyConnectivity() = %+v, %v", connectivity, err)
+	}
+
+	// Deploy hooks authenticate with the proxy's deploy token alone
+	deployToken, err := apiClient.CreateDeployToken(ctx, proxy.ID)
+	if err != nil || deployToken.Token == "" || !strings.Contains(deployToken.HookURL, proxy.ID) {
+		t.Fatalf("CreateDeployToken() = %+v, %v", deployToken, err)
+	}
+	anonymous, _ := client.New(server.URL, client.WithRetries(0, 0))
+	target, err := anonymous.Deploy(ctx, proxy.ID, deployToken.Token, "", 7070)
+	if err != nil || target != "http://127.0.0.1:7070" {
+		t.Fatalf("Deploy() = %q, %v", target, err)
+	}
+	if err := apiClient.DeleteDeployToken(ctx, proxy.ID); err != nil {
+		t.Fatalf("DeleteDeployToken() failed: %v", err)
+	}
+	if _, err := anonymous.Deploy(ctx, proxy.ID, deployToken.Token, "", 7071); err == nil {
+		t.Fatal("Deploy() with a revoked token succeeded")
+	}
+
+	ipList, err := apiClient.ImportIPList(ctx, proxy.ID, client.NewIPList{Name: "office", List: models.IPListBlocked, Content: "192.0.2.0/24\n198.51.100.7\n"})
+	if err != nil || ipList.ID == "" || ipList.Entries != 2 {
+		t.Fatalf("ImportIPList() = %+v, %v", ipList, err)
+	}
+	if lists, err := apiClient.IPLists(ctx, proxy.ID); err != nil || len(lists) != 1 {
+		t.Fatalf("IPLists() = %+v, %v", lists, err)
+	}
+	if err := apiClient.DeleteIPList(ctx, proxy.ID, ipList.ID); err != nil {
+		t.Fatalf("DeleteIPList() failed: %v", err)
+	}
+
+	capture, err := apiClient.StartDebugCapture(ctx, proxy.ID, 5)
+	if err != nil || !capture.Active {
+		t.Fatalf("StartDebugCapture() = %+v, %v", capture, err)
+	}
+	if capture, err := apiClient.GetDebugCapture(ctx, proxy.ID); err != nil || !capture.Active {
+		t.Fatalf("GetDebugCapture() = %+v, %v", capture, err)
+	}
+	if capture, err := apiClient.StopDebugCapture(ctx, proxy.ID); err != nil || capture.Active {
+		t.Fatalf("StopDebugCapture() = %+v, %v", capture, err)
+	}
+
+	certificatePEM, keyPEM := selfSignedCertificate(t, "app.example.com")
+	uploaded, applied, err := apiClient.UploadCustomCertificate(ctx, proxy.ID, certificatePEM, keyPEM)
+	if err != nil || applied || len(uploaded.SANs) != 1 || uploaded.SANs[0] != "app.example.com" {
+		t.Fatalf("UploadCustomCertificate() = %+v, %v, %v", uploaded, applied, err)
+	}
+	if certificate, err := apiClient.CustomCertificate(ctx, proxy.ID); err != nil || certificate.NotAfter != uploaded.NotAfter {
+		t.Fatalf("CustomCertificate() = %+v, %v", certificate, err)
+	}
+	if err := apiClient.DeleteCustomCertificate(ctx, proxy.ID); err != nil {
+		t.Fatalf("DeleteCustomCertificate() failed: %v", err)
+	}
+
+	if err := apiClient.DeleteProxy(ctx, proxy.ID); err != nil {
+		t.Fatalf("DeleteProxy() failed: %v", err)
+	}
+	if _, err := apiClient.GetProxy(ctx, proxy.ID); !client.IsNotFound(err) {
+		t.Fatalf("GetProxy() of a deleted proxy = %v, want not found", err)
+	}
+}
+
+func TestClientContractResources(t *testing.T) {
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	redirect, err := apiClient.CreateRedirect(ctx, models.Redirect{
+		SourceDomains:  []string{"old.example.com"},
+		DestinationURL: "https://new.example.com",
+		RedirectCode:   301,
+	})
+	if err != nil || redirect.ID == "" {
+		t.Fatalf("CreateRedirect() = %+v, %v", redirect, err)
+	}
+	if details, err := apiClient.GetRedirect(ctx, redirect.ID); err != nil || details.Redirect.ID != redirect.ID {
+		t.Fatalf("GetRedirect() = %+v, %v", details, err)
+	}
+
+	template, err := apiClient.CreateProxyTemplate(ctx, models.ProxyTemplate{Name: "Internal", Proxy: json.RawMessage(`{"ssl_mode": "none"}`)})
+	if err != nil || template.ID == "" {
+		t.Fatalf("CreateProxyTemplate() = %+v, %v", template, err)
+	}
+	proxy, err := apiClient.CreateProxyFromTemplate(ctx, template.ID, "templated.example.com", "http://127.0.0.1:8080")
+	if err != nil || proxy.SSLMode != handlers.SSLModeNone {
+		t.Fatalf("CreateProxyFromTemplate() = %+v, %v", proxy, err)
+	}
+
+	page, applied, err := apiClient.SavePage(ctx, "error", "default", "<h1>{{.StatusCode}}</h1>")
+	if err != nil || page.Kind != "error" || !applied {
+		t.Fatalf("SavePage() = %+v, %v, %v", page, applied, err)
+	}
+	if page, err := apiClient.GetPage(ctx, "error", "default"); err != nil || page.Body == "" {
+		t.Fatalf("GetPage() = %+v, %v", page, err)
+	}
+	if pages, err := apiClient.Pages(ctx); err != nil || len(pages) != 1 {
+		t.Fatalf("Pages() = %+v, %v", pages, err)
+	}
+	if err := apiClient.DeletePage(ctx, "error", "default"); err != nil {
+		t.Fatalf("DeletePage() failed: %v", err)
+	}
+
+	if presets, err := apiClient.Presets(ctx); err != nil || len(presets) != 1 {
+		t.Fatalf("Presets() = %+v, %v", presets, err)
+	}
+	if preset, err := apiClient.GetPreset(ctx, "gitea"); err != nil || preset.Proxy.TargetPort != 3000 {
+		t.Fatalf("GetPreset() = %+v, %v", preset, err)
+	}
+	if _, err := apiClient.GetPreset(ctx, "missing"); !client.IsNotFound(err) {
+		t.Fatalf("GetPreset() of a missing preset = %v, want not found", err)
+	}
+
+	csv := "domain,target\ncsv.example.com,http://127.0.0.1:8080\n"
+	imported, err := apiClient.ImportCSV(ctx, "proxies", []byte(csv), true)
+	if err != nil || !imported.DryRun || !imported.Valid || len(imported.Results) != 1 {
+		t.Fatalf("ImportCSV() = %+v, %v", imported, err)
+	}
+
+	if renamed, err := apiClient.MigrateIDs(ctx); err != nil || renamed == nil {
+		t.Fatalf("MigrateIDs() = %+v, %v", renamed, err)
+	}
+
+	providers, err := apiClient.DNSProviders(ctx)
+	if err != nil || len(providers) == 0 || len(providers[0].Fields) == 0 {
+		t.Fatalf("DNSProviders() = %+v, %v", providers, err)
+	}
+	if accounts, err := apiClient.ACMEDNSAccounts(ctx); err != nil || accounts == nil {
+		t.Fatalf("ACMEDNSAccounts() = %+v, %v", accounts, err)
+	}
+	if report, err := apiClient.CertificateReport(ctx); err != nil || report.GeneratedAt == "" {
+		t.Fatalf("CertificateReport() = %+v, %v", report, err)
+	}
+	if status, err := apiClient.PreprovisionedCertificates(ctx); err != nil || !status.AllIssued {
+		t.Fatalf("PreprovisionedCertificates() = %+v, %v", status, err)
+	}
+	if ca, err := apiClient.InternalCA(ctx); err != nil || ca.ACMEServer.Enabled || ca.CA != nil {
+		t.Fatalf("InternalCA() = %+v, %v", ca, err)
+	}
+
+	if err := apiClient.DeleteRedirect(ctx, redirect.ID); err != nil {
+		t.Fatalf("DeleteRedirect() failed: %v", err)
+	}
+	if err := apiClient.DeleteProxyTemplate(ctx, template.ID); err != nil {
+		t.Fatalf("DeleteProxyTemplate() failed: %v", err)
+	}
+}
+
+func TestClientContractSystem(t *testing.T) {
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	if err := apiClient.Health(ctx); err != nil {
+		t.Fatalf("Health() failed: %v", err)
+	}
+	if features, err := apiClient.Features(ctx); err != nil || features.Version == "" {
+		t.Fatalf("Features() = %+v, %v", features, err)
+	}
+
+	secret, err := apiClient.GenerateSecret(ctx, handlers.SecretTypeHtpasswd, 24, "ops")
+	if err != nil || len(secret.Value) != 24 || !strings.HasPrefix(secret.Htpasswd, "ops:") {
+		t.Fatalf("GenerateSecret() = %+v, %v", secret, err)
+	}
+
+	var apiErr *client.APIError
+	if _, err := apiClient.ApplyUpdate(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || !strings.Contains(apiErr.Message, "SELF_UPDATE") {
+		t.Fatalf("ApplyUpdate() with self-update disabled = %v, want a 403 naming SELF_UPDATE", err)
+	}
+
+	newContractProxy(t, apiClient, "report.example.com")
+	if _, err := apiClient.CreateAccessList(ctx, models.AccessList{Name: "Office", AllowedIPs: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("CreateAccessList() = %v", err)
+	}
+	report, err := apiClient.UsageReport(ctx, false)
+	if err != nil || report.RedirectsChecked || report.Summary["proxies"] != 1 {
+		t.Fatalf("UsageReport() = %+v, %v", report, err)
+	}
+	// Traffic statistics are off, so idle proxies can't be reported
+	if len(report.IdleProxies) != 0 || !slices.Contains(report.Unavailable, "traffic") {
+		t.Fatalf("UsageReport() without traffic statistics = %+v, want traffic unavailable", report)
+	}
+	if len(report.UnusedAccessLists) != 1 || report.UnusedAccessLists[0].Name != "Office" || slices.Contains(report.Unavailable, "access_lists") {
+		t.Fatalf("UsageReport() = %+v, want the Office access list unused", report)
+	}
+	if violations, err := apiClient.WAFViolations(ctx, "", 10); err != nil || violations == nil {
+		t.Fatalf("WAFViolations() = %+v, %v", violations, err)
+	}
+	if analysis, err := apiClient.AuditAnalysis(ctx, time.Hour); err != nil || analysis.Since.IsZero() {
+		t.Fatalf("AuditAnalysis() = %+v, %v", analysis, err)
+	}
+	if entries, err := apiClient.AuditLog(ctx); err != nil || len(entries) == 0 {
+		t.Fatalf("AuditLog() = %+v, %v", entries, err)
+	}
+	if _, err := apiClient.ConfigDrift(ctx); err != nil {
+		t.Fatalf("ConfigDrift() failed: %v", err)
+	}
+	if changes, err := apiClient.ForeignChanges(ctx); err != nil || len(changes) != 0 {
+		t.Fatalf("ForeignChanges() = %+v, %v", changes, err)
+	}
+
+	if caddyfile, err := apiClient.ExportCaddyfile(ctx); err != nil || !strings.Contains(caddyfile, "report.example.com") {
+		t.Fatalf("ExportCaddyfile() = %q, %v", caddyfile, err)
+	}
+	var backup bytes.Buffer
+	if err := apiClient.Backup(ctx, &backup); err != nil || backup.Len() == 0 {
+		t.Fatalf("Backup() wrote %d bytes, %v", backup.Len(), err)
+	}
+}
+
+func TestClientContractRemoteBackups(t *testing.T) {
+	share := webdav.NewMemFS()
+	dav := httptest.NewServer(&webdav.Handler{FileSystem: share, LockSystem: webdav.NewMemLS()})
+	t.Cleanup(dav.Close)
+	t.Setenv("BACKUP_WEBDAV_URL", dav.URL+"/backups")
+	t.Setenv("BACKUP_PASSPHRASE", "contract-passphrase")
+	t.Setenv("BACKUP_KEEP", "1")
+	t.Setenv("BACKUP_INTERVAL", "0")
+	apiClient, _ := newContractServer(t)
+	ctx := context.Background()
+
+	// An older archive, pruned by the next upload, next to a file that isn't the manager's
+	if err := share.Mkdir(ctx, "/backups", 0o755); err != nil {
+		t.Fatalf("failed to create WebDAV folder: %v", err)
+	}
+	for _, name := range []string{"caddyproxymanager-backup-20000101-000000.tar.gz.enc", "notes.txt"} {
+		file, err := share.OpenFile(ctx, "/backups/"+name, os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		file.Close()
+	}
+
+	proxy := newContractProxy(t, apiClient, "remote.example.com")
+	name, err := apiClient.CreateRemoteBackup(ctx)
+	if err != nil {
+		t.Fatalf("CreateRemoteBackup() failed: %v", err)
+	}
+	backups, err := apiClient.RemoteBackups(ctx)
+	if err != nil || backups.Target != "webdav" || backups.Keep != 1 || len(backups.Backups) != 1 || backups.Backups[0].Name != name {
+		t.Fatalf("RemoteBackups() = %+v, %v, want only %s", backups, err, name)
+	}
+	if _, err := share.Stat(ctx, "/backups/notes.txt"); err != nil {
+		t.Fatalf("pruning removed a file that isn't a backup: %v", err)
+	}
+
+	file, err := share.OpenFile(ctx, "/backups/"+name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open uploaded backup: %v", err)
+	}
+	uploaded, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || !backup.Encrypted(uploaded) || bytes.Contains(uploaded, []byte("remote.example.com")) {
+		t.Fatalf("uploaded backup isn't encrypted (%v)", err)
+	}
+	if _, err := backup.Open(uploaded, "wrong-passphrase"); err == nil {
+		t.Fatal("Open() with the wrong passphrase succeeded")
+	}
+
+	if err := apiClient.DeleteProxy(ctx, proxy.ID); err != nil {
+		t.Fatalf("DeleteProxy() failed: %v", err)
+	}
+	result, err := apiClient.RestoreRemoteBackup(ctx, name)
+	if err != nil || result.Proxies != 1 {
+		t.Fatalf("RestoreRemoteBackup() = %+v, %v, want one proxy", result, err)
+	}
+	if _, err := apiClient.GetProxy(ctx, proxy.ID); err != nil {
+		t.Fatalf("GetProxy() after restoring failed: %v", err)
+	}
+
+	// Uploaded encrypted archives open with the configured passphrase
+	if result, err := apiClient.Restore(ctx, bytes.NewReader(uploaded)); err != nil || result.Proxies != 1 {
+		t.Fatalf("Restore() of an encrypted archive = %+v, %v", result, err)
+	}
+}
+
+func TestClientContractEvents(t *testing.T) {
+	apiClient, _ := newContractServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan client.Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- apiClient.Events(ctx, func(event client.Event) error {
+			received <- event
+			return errors.New("stop")
+		})
+	}()
+
+	// Keep creating proxies until one is announced, since the stream may not be subscribed yet
+	for i := 0; ; i++ {
+		select {
+		case err := <-done:
+			if err == nil || err.Error() != "stop" {
+				t.Fatalf("Events() = %v, want the handler's error", err)
+			}
+			event := <-received
+			var change events.ProxyChange
+			if event.Type != events.TypeProxy || json.Unmarshal(event.Data, &change) != nil || change.Action != events.ActionCreated {
+				t.Fatalf("Events() delivered %+v, want a proxy created event", event)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+			newContractProxy(t, apiClient, fmt.Sprintf("event%d.example.com", i))
+		}
+	}
+}
+
+// selfSignedCertificate returns a PEM certificate and key for domain
+func selfSignedCertificate(t *testing.T, domain string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return string(certificatePEM), string(keyPEM)
+}
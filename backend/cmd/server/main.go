@@ -5,32 +5,75 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sarat/caddyproxymanager/internal/handlers"
 	"github.com/sarat/caddyproxymanager/pkg/audit"
 	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/backup"
 	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/certs"
+	"github.com/sarat/caddyproxymanager/pkg/discovery"
+	"github.com/sarat/caddyproxymanager/pkg/events"
 	"github.com/sarat/caddyproxymanager/pkg/health"
+	"github.com/sarat/caddyproxymanager/pkg/iplist"
+	"github.com/sarat/caddyproxymanager/pkg/leader"
+	"github.com/sarat/caddyproxymanager/pkg/limiter"
+	"github.com/sarat/caddyproxymanager/pkg/metrics"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/netguard"
+	"github.com/sarat/caddyproxymanager/pkg/notify"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+	"github.com/sarat/caddyproxymanager/pkg/portcheck"
+	"github.com/sarat/caddyproxymanager/pkg/presets"
+	"github.com/sarat/caddyproxymanager/pkg/secrets"
+	"github.com/sarat/caddyproxymanager/pkg/stats"
+	"github.com/sarat/caddyproxymanager/pkg/store"
+	"github.com/sarat/caddyproxymanager/pkg/update"
 )
 
 const (
-	timeout60s               = 60 // Default timeout for HTTP operations in seconds
-	readHeaderTimeoutSeconds = 30 // Maximum time to read request headers
-	shutdownTimeoutSeconds   = 30 // Maximum time to wait for graceful shutdown
-	defaultPort              = "8080"
-	defaultCaddyAdminURL     = "http://localhost:2019"
-	defaultDataDir           = "./data"
-	defaultStaticDir         = "./static/"
-	sessionCleanupInterval   = 1 * time.Hour // Interval for cleaning expired sessions
+	timeout60s                 = 60 // Default timeout for HTTP operations in seconds
+	readHeaderTimeoutSeconds   = 30 // Maximum time to read request headers
+	defaultPort                = "8080"
+	defaultCaddyAdminURL       = "http://localhost:2019"
+	defaultDataDir             = "./data"
+	defaultStaticDir           = "./static/"
+	defaultRedisURL            = "redis://localhost:6379/0"
+	sessionCleanupInterval     = 1 * time.Hour      // Interval for cleaning expired sessions
+	debugCaptureInterval       = 30 * time.Second   // Interval for disabling expired debug captures
+	ipListRefreshInterval      = 5 * time.Minute    // Interval for checking which imported IP lists are due a refresh
+	defaultStatusPollInterval  = 10 * time.Second   // Interval for refreshing cached Caddy status
+	defaultConfigWatchInterval = 30 * time.Second   // Interval for checking Caddy's config for outside changes
+	defaultMetricsPushInterval = 30 * time.Second   // Interval for pushing health metrics
+	defaultHealthHookTimeout   = 30 * time.Second   // Maximum run time of the health hook command
+	defaultCertReportInterval  = 7 * 24 * time.Hour // Interval for the certificate expiry report
+	defaultBackupInterval      = 24 * time.Hour     // Interval for remote backups
+	defaultBackupKeep          = 7                  // Remote backups kept
+	defaultSecurityInterval    = 15 * time.Minute   // Interval for analysing the audit log
+	defaultKubernetesInterval  = 30 * time.Second   // Interval for listing Kubernetes Services
+	defaultShutdownTimeout     = 30 * time.Second   // Maximum time to drain requests and config writes on shutdown
+	defaultHealthDrainTimeout  = 5 * time.Second    // Maximum time to wait for running health checks on shutdown
+	securityAnalysisWindow     = time.Hour          // Audit history counted towards each security finding
+	defaultLeaderLeaseTTL      = 15 * time.Second
+	updateCheckCacheTTL        = 6 * time.Hour // How long the latest GitHub release is cached
+	defaultAPIMaxInFlight      = 64
+	defaultAPIRateLimit        = 20 // Requests per second per client IP
+	defaultAPIRateBurst        = 40
+	defaultAPIRequestTimeout   = 30 * time.Second
+	cleanShutdownMarker        = "clean-shutdown" // Written to the data directory after a complete graceful shutdown
 )
 
 // serverConfig holds all configuration parameters for the proxy manager server
@@ -40,6 +83,8 @@ type serverConfig struct {
 	dataDir       string // Directory for storing persistent data
 	configFile    string // Path to the Caddy configuration file
 	staticDir     string // Directory for static assets
+	conflictMode  string // What to do when Caddy's running config differs from the saved file at startup
+	idStrategy    string // How new proxies and redirects are named
 }
 
 // getServerConfig retrieves server configuration from environment variables with fallback defaults
@@ -64,29 +109,203 @@ func getServerConfig() *serverConfig {
 		staticDir = defaultStaticDir
 	}
 
+	conflictMode := os.Getenv("STARTUP_CONFLICT_MODE")
+	if conflictMode == "" {
+		conflictMode = caddy.ConflictPreferFile
+	}
+	if !caddy.ValidConflictMode(conflictMode) {
+		log.Fatalf("Invalid STARTUP_CONFLICT_MODE %q, expected %s, %s or %s", conflictMode, caddy.ConflictPreferFile, caddy.ConflictPreferCaddy, caddy.ConflictFail)
+	}
+
+	idStrategy := os.Getenv("ID_STRATEGY")
+	if idStrategy == "" {
+		idStrategy = caddy.IDStrategySlug
+	}
+	if !caddy.ValidIDStrategy(idStrategy) {
+		log.Fatalf("Invalid ID_STRATEGY %q, expected %s or %s", idStrategy, caddy.IDStrategySlug, caddy.IDStrategyTimestamp)
+	}
+
 	return &serverConfig{
 		port:          port,
 		caddyAdminURL: caddyAdminURL,
 		dataDir:       dataDir,
 		configFile:    filepath.Join(dataDir, "caddy-config.json"),
 		staticDir:     staticDir,
+		conflictMode:  conflictMode,
+		idStrategy:    idStrategy,
 	}
 }
 
 // initializeCaddy creates and configures a Caddy client, attempting to restore previous configuration
-func initializeCaddy(cfg *serverConfig) *caddy.Client {
+func initializeCaddy(cfg *serverConfig, pageStore *pages.Store, database *store.Postgres) *caddy.Client {
 	caddyClient := caddy.New(cfg.caddyAdminURL, cfg.configFile)
+	if database != nil {
+		if err := caddyClient.SetStore(database); err != nil {
+			log.Fatalf("Failed to load proxy metadata from the database: %v", err)
+		}
+	}
+	caddyClient.SetSecrets(newSecretsBox(cfg))
+	caddyClient.SetPages(pageStore)
+	caddyClient.SetIDStrategy(cfg.idStrategy)
 
-	if err := caddyClient.RestoreConfigFromFile(); err != nil {
+	// After a clean shutdown every write reached both Caddy and the saved file, so the comparison with
+	// Caddy's running config is skipped
+	cleanShutdown := consumeCleanShutdownMarker(cfg.dataDir)
+	if cleanShutdown && cfg.conflictMode != caddy.ConflictPreferFile {
+		log.Println("Previous run shut down cleanly, skipping the startup conflict check")
+	}
+
+	if !cleanShutdown && resolveStartupConflict(caddyClient, cfg) {
+		log.Printf("Keeping Caddy's running configuration, saved to: %s\n", cfg.configFile)
+	} else if err := caddyClient.RestoreConfigFromFile(); err != nil {
 		log.Printf("Warning: Could not restore config from file: %v\n", err)
 		log.Println("Starting with empty configuration...")
 	} else {
 		log.Printf("Configuration restored from: %s\n", cfg.configFile)
 	}
 
+	// Pick up template or SUPPORT_EMAIL changes made while the manager was stopped
+	if err := caddyClient.ApplyPages(); err != nil {
+		log.Printf("Warning: Could not apply page templates: %v\n", err)
+	}
+
 	return caddyClient
 }
 
+// resolveStartupConflict applies STARTUP_CONFLICT_MODE when Caddy already runs a config that differs
+// from the saved file. It reports whether Caddy's config was kept, in which case the file must not be
+// restored; with the fail mode the manager exits after logging the differences.
+func resolveStartupConflict(caddyClient *caddy.Client, cfg *serverConfig) bool {
+	if cfg.conflictMode == caddy.ConflictPreferFile {
+		return false
+	}
+
+	conflict, err := caddyClient.StartupConflict()
+	if err != nil {
+		log.Printf("Warning: Could not compare Caddy's config with the saved file: %v\n", err)
+		if cfg.conflictMode == caddy.ConflictFail {
+			log.Fatalf("Refusing to start with STARTUP_CONFLICT_MODE=%s until the configs can be compared", caddy.ConflictFail)
+		}
+		return false
+	}
+	if conflict == nil {
+		return false
+	}
+
+	if cfg.conflictMode == caddy.ConflictFail {
+		log.Printf("Caddy's running config differs from %s (- only in the file, + only in Caddy, ~ changed):", cfg.configFile)
+		for _, line := range conflict.Diff {
+			log.Printf("  %s", line)
+		}
+		if conflict.Truncated {
+			log.Printf("  ... more differences omitted")
+		}
+		log.Fatalf("Refusing to start with STARTUP_CONFLICT_MODE=%s; set it to %s or %s to pick a side", caddy.ConflictFail, caddy.ConflictPreferFile, caddy.ConflictPreferCaddy)
+	}
+
+	if err := caddyClient.AdoptRunningConfig(); err != nil {
+		log.Fatalf("Failed to adopt Caddy's running config: %v", err)
+	}
+	log.Printf("Caddy's running config differs from the saved file (STARTUP_CONFLICT_MODE=%s)", caddy.ConflictPreferCaddy)
+	return true
+}
+
+// configurePasswordHashing applies PASSWORD_HASH (bcrypt or argon2id) and its parameters to newly
+// hashed passwords. Existing hashes are upgraded when their users log in.
+func configurePasswordHashing() {
+	config := auth.DefaultPasswordHashing
+	if value := os.Getenv("PASSWORD_HASH"); value != "" {
+		config.Algorithm = value
+	}
+
+	parse := func(name string, bits int, target func(uint64)) {
+		value := os.Getenv(name)
+		if value == "" {
+			return
+		}
+		parsed, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			log.Fatalf("Invalid %s %q: %v", name, value, err)
+		}
+		target(parsed)
+	}
+	parse("BCRYPT_COST", 8, func(v uint64) { config.BcryptCost = int(v) })
+	parse("ARGON2_TIME", 32, func(v uint64) { config.Argon2Time = uint32(v) })
+	parse("ARGON2_MEMORY", 32, func(v uint64) { config.Argon2Memory = uint32(v) })
+	parse("ARGON2_THREADS", 8, func(v uint64) { config.Argon2Threads = uint8(v) })
+
+	if err := auth.SetPasswordHashing(config); err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+}
+
+// newPageStore keeps the templates for responses Caddy serves itself in $DATA_DIR/pages.
+// SUPPORT_EMAIL is available to them as {{.SupportEmail}}.
+func newPageStore(cfg *serverConfig) *pages.Store {
+	return pages.NewStore(filepath.Join(cfg.dataDir, "pages"), os.Getenv("SUPPORT_EMAIL"))
+}
+
+// newSecretsBox creates the box that encrypts sensitive proxy metadata. The key comes from SECRETS_KEY
+// or, when unset, from secret.key in the data directory, which is generated on first start.
+func newSecretsBox(cfg *serverConfig) *secrets.Box {
+	key, err := secrets.LoadKey(os.Getenv("SECRETS_KEY"), cfg.dataDir)
+	if err != nil {
+		log.Fatalf("Failed to load secrets key: %v", err)
+	}
+
+	box, err := secrets.New(key)
+	if err != nil {
+		log.Fatalf("Failed to create secrets box: %v", err)
+	}
+	return box
+}
+
+// newOutboundGuard builds the SSRF guard for requests the manager makes itself, such as health checks
+// and target reachability tests.
+// OUTBOUND_BLOCKED_CIDRS replaces the default blocklist with a comma-separated list, or "none" to disable it.
+func newOutboundGuard() *netguard.Guard {
+	ranges := netguard.DefaultBlockedRanges
+	if value := os.Getenv("OUTBOUND_BLOCKED_CIDRS"); value == "none" {
+		return nil
+	} else if value != "" {
+		ranges = strings.Split(value, ",")
+	}
+
+	guard, err := netguard.New(ranges)
+	if err != nil {
+		log.Fatalf("Invalid OUTBOUND_BLOCKED_CIDRS: %v", err)
+	}
+
+	return guard
+}
+
+// loadPresets reads application presets from the presets directory in the data dir. Invalid files
+// are logged and skipped so a single bad preset doesn't prevent startup.
+func loadPresets(cfg *serverConfig) *presets.Store {
+	presetDir := filepath.Join(cfg.dataDir, "presets")
+
+	store, errs := presets.Load(presetDir)
+	for _, err := range errs {
+		log.Printf("Warning: Skipping invalid preset: %v\n", err)
+	}
+
+	log.Printf("Loaded %d application presets from %s\n", len(store.List()), presetDir)
+
+	return store
+}
+
+// newCertificateService lists certificates from Caddy's storage directory, CADDY_STORAGE_DIR or by
+// default $DATA_DIR/caddy, where Caddy keeps its data when XDG_DATA_HOME is the data dir as in the
+// Docker image
+func newCertificateService(cfg *serverConfig, caddyClient *caddy.Client) *certs.Service {
+	storageDir := os.Getenv("CADDY_STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = filepath.Join(cfg.dataDir, "caddy")
+	}
+
+	return certs.New(caddyClient, storageDir)
+}
+
 // startHealthChecks initializes health monitoring for all configured proxies that have it enabled
 func startHealthChecks(caddyClient *caddy.Client, healthService *health.Service) {
 	config, err := caddyClient.GetConfig()
@@ -106,8 +325,314 @@ func startHealthChecks(caddyClient *caddy.Client, healthService *health.Service)
 	log.Printf("Started health checks for %d proxies\n", len(proxies))
 }
 
+// auditFailovers records an audit entry whenever a proxy with a backup target fails over or back
+func auditFailovers(healthService *health.Service, auditService *audit.Service) {
+	healthService.OnStatusChange(func(proxy models.Proxy, oldStatus, newStatus, message string) {
+		if proxy.BackupTargetURL == "" {
+			return
+		}
+
+		var action, details string
+		switch {
+		case newStatus == "Unhealthy" && oldStatus != "Unhealthy":
+			action = "FAILOVER"
+			details = fmt.Sprintf("Proxy '%s' failed over from '%s' to backup '%s': %s", proxy.ID, proxy.TargetURL, proxy.BackupTargetURL, message)
+		case newStatus == "Healthy" && oldStatus == "Unhealthy":
+			action = "FAILBACK"
+			details = fmt.Sprintf("Proxy '%s' failed back to primary '%s'", proxy.ID, proxy.TargetURL)
+		default:
+			return
+		}
+
+		log.Println(details)
+		if err := auditService.Log(action, details, "system", "health-check", ""); err != nil {
+			log.Printf("Warning: Failed to write audit log: %v\n", err)
+		}
+	})
+}
+
+// newNotifier creates the notifier delivering alerts to notification channels and sends it proxies
+// going down and recovering
+func newNotifier(caddyClient *caddy.Client, healthService *health.Service, outboundGuard *netguard.Guard) *notify.Notifier {
+	notifier := notify.New(caddyClient, outboundGuard)
+
+	healthService.OnStatusChange(func(proxy models.Proxy, oldStatus, newStatus, message string) {
+		msg := notify.Message{ProxyID: proxy.ID, Domain: proxy.Domain, Text: message}
+		switch {
+		case newStatus == "Unhealthy" && oldStatus != "Unhealthy":
+			msg.Event = notify.EventProxyDown
+			msg.Title = fmt.Sprintf("%s is down", proxy.Domain)
+			// Every checked proxy down usually means the host or its network is, which digests
+			// shouldn't hold back
+			msg.Critical = allDown(healthService)
+		case newStatus == "Healthy" && oldStatus == "Unhealthy":
+			msg.Event = notify.EventProxyUp
+			msg.Title = fmt.Sprintf("%s recovered", proxy.Domain)
+		default:
+			return
+		}
+		if proxy.Links != nil && proxy.Links.Runbook != "" {
+			msg.Text += "\nRunbook: " + proxy.Links.Runbook
+		}
+		notifier.Notify(msg)
+	})
+
+	return notifier
+}
+
+// allDown reports whether every checked proxy is unhealthy
+func allDown(healthService *health.Service) bool {
+	statuses := healthService.GetAllHealthStatuses()
+	for _, status := range statuses {
+		if status.Status != "Unhealthy" {
+			return false
+		}
+	}
+	return len(statuses) > 0
+}
+
+// startNotificationDigests sends the digests of channels in digest mode when they are due, and the
+// ones still collecting on shutdown
+func startNotificationDigests(ctx context.Context, notifier *notify.Notifier, waitGroup *sync.WaitGroup) {
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		notifier.RunDigests(ctx)
+		log.Println("Notification digest goroutine shutting down...")
+	}()
+}
+
+// notifyConfigChanges delivers proxy, redirect and reload events from the broker to the notification
+// channels subscribed to configuration changes
+func notifyConfigChanges(broker *events.Broker, notifier *notify.Notifier) {
+	broker.OnPublish(func(event events.Event) {
+		if msg, ok := notify.ChangeMessage(event); ok {
+			notifier.Notify(msg)
+		}
+	})
+}
+
+// newEventBroker creates the broker behind /api/events and publishes health and Caddy reachability
+// changes to it
+func newEventBroker(healthService *health.Service, statusPoller *caddy.StatusPoller) *events.Broker {
+	broker := events.NewBroker()
+
+	healthService.OnStatusChange(func(proxy models.Proxy, oldStatus, newStatus, message string) {
+		change := events.HealthChange{
+			ProxyID:   proxy.ID,
+			Domain:    proxy.Domain,
+			OldStatus: oldStatus,
+			NewStatus: newStatus,
+			Message:   message,
+		}
+		if proxy.Links != nil {
+			change.Runbook = proxy.Links.Runbook
+			change.Dashboard = proxy.Links.Dashboard
+		}
+		broker.Publish(events.TypeHealth, change)
+	})
+	statusPoller.OnReachabilityChange(func(snapshot caddy.StatusSnapshot) {
+		broker.Publish(events.TypeCaddy, events.CaddyChange{Reachable: snapshot.Reachable, Error: snapshot.Error})
+	})
+
+	return broker
+}
+
+// startSecurityAnalysis scans the audit log every SECURITY_ANALYSIS_INTERVAL and raises findings with
+// new activity as log lines, SECURITY_ALERT audit entries and security events
+func startSecurityAnalysis(ctx context.Context, auditService *audit.Service, eventBroker *events.Broker, elector *leader.Elector, waitGroup *sync.WaitGroup) {
+	interval := defaultSecurityInterval
+	if value := os.Getenv("SECURITY_ANALYSIS_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		switch {
+		case err == nil && parsed == 0:
+			return
+		case err != nil || parsed < 0:
+			log.Printf("Warning: Invalid SECURITY_ANALYSIS_INTERVAL %q, using %s", value, defaultSecurityInterval)
+		default:
+			interval = parsed
+		}
+	}
+
+	window := max(interval, securityAnalysisWindow)
+	lastRun := time.Now()
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now()
+				since := lastRun
+				lastRun = now
+				if elector != nil && !elector.IsLeader() {
+					continue
+				}
+
+				entries, err := auditService.Entries()
+				if err != nil {
+					log.Printf("Failed to read audit log for security analysis: %v", err)
+					continue
+				}
+
+				// Findings count the whole window but are only raised again when they have new activity
+				for _, finding := range audit.Analyze(entries, now.Add(-window)) {
+					if !finding.Last.After(since) {
+						continue
+					}
+					log.Printf("Security alert: %s", finding.Details)
+					if err := auditService.Log(audit.ActionSecurityAlert, finding.Details, "system", "security-analysis", finding.IPAddress); err != nil {
+						log.Printf("Warning: Failed to write audit log: %v\n", err)
+					}
+					eventBroker.Publish(events.TypeSecurity, finding)
+				}
+			case <-ctx.Done():
+				log.Println("Security analysis goroutine shutting down...")
+
+				return
+			}
+		}
+	}()
+}
+
+// setHealthCheckCaddyHost tells health checks run through Caddy where it serves proxies
+func setHealthCheckCaddyHost(cfg *serverConfig, healthService *health.Service) {
+	host, ok := caddyHost(cfg)
+	if !ok {
+		log.Printf("Warning: Can't tell Caddy's host from CADDY_ADMIN_URL, set HEALTH_CHECK_CADDY_HOST for health checks through Caddy")
+		return
+	}
+	healthService.SetCaddyAddress(host)
+}
+
+// configureUpstreamProbe enables connection tests run from Caddy on CADDY_PROBE_PORT (default
+// 2020), unless it is set to "off"
+func configureUpstreamProbe(cfg *serverConfig, caddyClient *caddy.Client) {
+	port := os.Getenv("CADDY_PROBE_PORT")
+	if port == "off" {
+		return
+	}
+	if port == "" {
+		port = "2020"
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		log.Printf("Warning: Invalid CADDY_PROBE_PORT %q, connection tests from Caddy are disabled", port)
+		return
+	}
+
+	host, ok := caddyHost(cfg)
+	if !ok {
+		log.Printf("Warning: Can't tell Caddy's host from CADDY_ADMIN_URL, set HEALTH_CHECK_CADDY_HOST for connection tests from Caddy")
+		return
+	}
+	caddyClient.SetProbeAddress(host, port)
+}
+
+// caddyHost returns the host the manager reaches Caddy's listeners on: HEALTH_CHECK_CADDY_HOST, or
+// else the host of the Caddy admin URL
+func caddyHost(cfg *serverConfig) (string, bool) {
+	if host := os.Getenv("HEALTH_CHECK_CADDY_HOST"); host != "" {
+		return host, true
+	}
+	adminURL, err := url.Parse(cfg.caddyAdminURL)
+	if err != nil || adminURL.Hostname() == "" {
+		return "", false
+	}
+	return adminURL.Hostname(), true
+}
+
+// configureHealthCheckPacing sets the jitter of health check intervals from HEALTH_CHECK_JITTER, a
+// percentage of the interval, and restores the global pause switch
+func configureHealthCheckPacing(caddyClient *caddy.Client, healthService *health.Service) {
+	if value := os.Getenv("HEALTH_CHECK_JITTER"); value != "" {
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percent < 0 || percent > 100 {
+			log.Printf("Warning: Invalid HEALTH_CHECK_JITTER %q, using %d%%", value, int(health.DefaultJitter*100))
+		} else {
+			healthService.SetJitter(float64(percent) / 100)
+		}
+	}
+
+	if caddyClient.HealthChecksPaused() {
+		healthService.SetPaused(true)
+		log.Println("Health checks are paused")
+	}
+}
+
+// startHealthHook runs HEALTH_HOOK_COMMAND whenever a proxy's health status changes
+func startHealthHook(healthService *health.Service) {
+	command := os.Getenv("HEALTH_HOOK_COMMAND")
+	if command == "" {
+		return
+	}
+
+	timeout := defaultHealthHookTimeout
+	if value := os.Getenv("HEALTH_HOOK_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid HEALTH_HOOK_TIMEOUT %q, using %s", value, defaultHealthHookTimeout)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	health.NewCommandHook(command, timeout).Register(healthService)
+	log.Printf("Running health hook %s on proxy status changes", command)
+}
+
+// startLeaderElection enables HA mode when HA_MODE=true. Only the elected leader runs background
+// jobs such as health checks, while every replica keeps serving the API. Returns nil when HA is off.
+func startLeaderElection(ctx context.Context, cfg *serverConfig, database *store.Postgres, healthService *health.Service, waitGroup *sync.WaitGroup) *leader.Elector {
+	if os.Getenv("HA_MODE") != "true" {
+		return nil
+	}
+
+	instanceID := os.Getenv("INSTANCE_ID")
+	if instanceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("Failed to determine instance ID: %v", err)
+		}
+		instanceID = hostname
+	}
+
+	leaseTTL := defaultLeaderLeaseTTL
+	if ttl := os.Getenv("LEADER_LEASE_TTL"); ttl != "" {
+		parsed, err := time.ParseDuration(ttl)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Invalid LEADER_LEASE_TTL: %s", ttl)
+		}
+		leaseTTL = parsed
+	}
+
+	// Background jobs stay paused until this instance wins the election
+	healthService.SetActive(false)
+
+	// Replicas sharing a database may not share a data directory, so the lease lives with the state
+	var backend leader.Backend = leader.NewFile(filepath.Join(cfg.dataDir, "leader.json"))
+	if database != nil {
+		backend = database.Lease("leader")
+	}
+
+	elector := leader.NewElector(backend, instanceID, leaseTTL, healthService.SetActive)
+	log.Printf("HA mode enabled, instance ID: %s\n", instanceID)
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		elector.Run(ctx)
+	}()
+
+	return elector
+}
+
 // startSessionCleanup runs a background goroutine that periodically removes expired authentication sessions
-func startSessionCleanup(ctx context.Context, authStorage *auth.Storage, waitGroup *sync.WaitGroup) {
+func startSessionCleanup(ctx context.Context, authStorage *auth.Storage, elector *leader.Elector, waitGroup *sync.WaitGroup) {
 	waitGroup.Add(1)
 
 	tickerFunc := func() {
@@ -119,6 +644,9 @@ func startSessionCleanup(ctx context.Context, authStorage *auth.Storage, waitGro
 		for {
 			select {
 			case <-ticker.C:
+				if elector != nil && !elector.IsLeader() {
+					continue
+				}
 				if err := authStorage.CleanExpiredSessions(); err != nil {
 					log.Printf("Failed to clean expired sessions: %v", err)
 				}
@@ -133,11 +661,542 @@ func startSessionCleanup(ctx context.Context, authStorage *auth.Storage, waitGro
 	go tickerFunc()
 }
 
+// startDebugCaptureExpiry runs a background goroutine that disables debug captures once their duration has elapsed
+func startDebugCaptureExpiry(ctx context.Context, caddyClient *caddy.Client, elector *leader.Elector, waitGroup *sync.WaitGroup) {
+	waitGroup.Add(1)
+
+	go func() {
+		defer waitGroup.Done()
+
+		ticker := time.NewTicker(debugCaptureInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if elector != nil && !elector.IsLeader() {
+					continue
+				}
+				caddyClient.ExpireDebugCaptures()
+			case <-ctx.Done():
+				log.Println("Debug capture expiry goroutine shutting down...")
+
+				return
+			}
+		}
+	}()
+}
+
+// newPortChecker creates the checker port forwards are tested with, calling PORT_CHECK_URL with
+// PORT_CHECK_HOST as the public host. Returns nil when no check service is configured.
+func newPortChecker() *portcheck.Checker {
+	checkURL := os.Getenv("PORT_CHECK_URL")
+	if checkURL == "" {
+		return nil
+	}
+
+	checker, err := portcheck.New(checkURL, os.Getenv("PORT_CHECK_HOST"))
+	if err != nil {
+		log.Fatalf("Invalid port check configuration: %v", err)
+	}
+	return checker
+}
+
+// startTrafficStats has Caddy write access logs to the data directory and aggregates them into
+// per-proxy traffic statistics when TRAFFIC_STATS=true. Returns nil, and removes the access log
+// from Caddy's config, when it is off.
+func startTrafficStats(ctx context.Context, caddyClient *caddy.Client, waitGroup *sync.WaitGroup) *stats.Aggregator {
+	enabled := os.Getenv("TRAFFIC_STATS") == "true"
+	if err := caddyClient.SetTrafficLog(enabled); err != nil {
+		log.Printf("Warning: Traffic statistics disabled: %v", err)
+		enabled = false
+	}
+	if err := caddyClient.ApplyTrafficLog(); err != nil {
+		log.Printf("Warning: Could not apply the traffic access log: %v", err)
+	}
+	if !enabled {
+		return nil
+	}
+
+	file, err := caddyClient.TrafficLogFile()
+	if err != nil {
+		log.Printf("Warning: Traffic statistics disabled: %v", err)
+		return nil
+	}
+
+	aggregator := stats.NewAggregator()
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		aggregator.Follow(ctx, file)
+		log.Println("Traffic statistics goroutine shutting down...")
+	}()
+
+	log.Printf("Traffic statistics enabled, reading Caddy's access log from %s", file)
+	return aggregator
+}
+
+// startKubernetesDiscovery lists Kubernetes Services and Ingresses every KUBERNETES_POLL_INTERVAL when
+// KUBERNETES_DISCOVERY=true, using KUBERNETES_API_URL or the kubeconfig in KUBECONFIG when set and
+// the pod's service account otherwise. With KUBERNETES_SYNC=true the leader also syncs annotated Services into proxies.
+// Returns nil when discovery is off.
+func startKubernetesDiscovery(ctx context.Context, handler *handlers.Handler, elector *leader.Elector, waitGroup *sync.WaitGroup) *discovery.Watcher {
+	if os.Getenv("KUBERNETES_DISCOVERY") != "true" {
+		return nil
+	}
+
+	config, inCluster := discovery.InClusterConfig()
+	if apiURL := os.Getenv("KUBERNETES_API_URL"); apiURL != "" {
+		config = discovery.KubernetesConfig{
+			APIURL:    apiURL,
+			Token:     os.Getenv("KUBERNETES_TOKEN"),
+			TokenFile: os.Getenv("KUBERNETES_TOKEN_FILE"),
+			CAFile:    os.Getenv("KUBERNETES_CA_FILE"),
+		}
+	} else if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		// Like kubectl, KUBECONFIG may list several files; only the first is read
+		path := filepath.SplitList(kubeconfig)[0]
+		loaded, err := discovery.LoadKubeconfig(path, os.Getenv("KUBERNETES_CONTEXT"))
+		if err != nil {
+			log.Printf("Warning: Kubernetes discovery disabled: %v", err)
+			return nil
+		}
+		config = loaded
+	} else if !inCluster {
+		log.Printf("Warning: Kubernetes discovery disabled, not running in a cluster and neither KUBERNETES_API_URL nor KUBECONFIG is set")
+		return nil
+	}
+	config.Namespace = os.Getenv("KUBERNETES_NAMESPACE")
+	config.ClusterDomain = os.Getenv("KUBERNETES_CLUSTER_DOMAIN")
+
+	kubernetes, err := discovery.NewKubernetes(config)
+	if err != nil {
+		log.Printf("Warning: Kubernetes discovery disabled: %v", err)
+		return nil
+	}
+
+	interval := defaultKubernetesInterval
+	if value := os.Getenv("KUBERNETES_POLL_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid KUBERNETES_POLL_INTERVAL %q, using %s", value, defaultKubernetesInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	var syncServices discovery.SyncFunc
+	if os.Getenv("KUBERNETES_SYNC") == "true" {
+		// Followers list Services too but leave the proxies to the leader
+		syncServices = func(ctx context.Context, services []discovery.Service) map[string]string {
+			if elector != nil && !elector.IsLeader() {
+				return nil
+			}
+			return handler.SyncKubernetesServices(ctx, services)
+		}
+	}
+
+	watcher := discovery.NewWatcher(kubernetes, interval, syncServices)
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		watcher.Run(ctx)
+		log.Println("Kubernetes discovery goroutine shutting down...")
+	}()
+
+	log.Printf("Kubernetes discovery enabled (%s, sync %t)", config.APIURL, syncServices != nil)
+	return watcher
+}
+
+// startIPListRefresh fetches IP lists imported from URLs again once their refresh interval has
+// passed, auditing lists whose entries changed
+func startIPListRefresh(ctx context.Context, caddyClient *caddy.Client, auditService *audit.Service, eventBroker *events.Broker, outboundGuard *netguard.Guard, elector *leader.Elector, waitGroup *sync.WaitGroup) {
+	fetcher := iplist.NewFetcher(outboundGuard)
+	fetch := func(url string) ([]string, error) {
+		return fetcher.Fetch(ctx, url)
+	}
+
+	waitGroup.Add(1)
+
+	go func() {
+		defer waitGroup.Done()
+
+		ticker := time.NewTicker(ipListRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if elector != nil && !elector.IsLeader() {
+					continue
+				}
+
+				for _, result := range caddyClient.RefreshDueIPLists(fetch) {
+					if result.Err != nil {
+						log.Printf("Warning: Failed to refresh IP list '%s' of proxy %s: %v\n", result.List.Name, result.ProxyID, result.Err)
+						continue
+					}
+					if !result.Changed {
+						continue
+					}
+
+					details := fmt.Sprintf("Refreshed IP list '%s' of proxy '%s', now %d entries", result.List.Name, result.ProxyID, result.List.Entries)
+					log.Println(details)
+					if err := auditService.Log("REFRESH_IP_LIST", details, "system", "ip-list-refresh", ""); err != nil {
+						log.Printf("Warning: Failed to write audit log: %v\n", err)
+					}
+					eventBroker.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: result.ProxyID})
+				}
+			case <-ctx.Done():
+				log.Println("IP list refresh goroutine shutting down...")
+
+				return
+			}
+		}
+	}()
+}
+
+// startMetricsExporter pushes health check results to METRICS_PUSH_URL when it is set, using
+// METRICS_PUSH_FORMAT (influx or remote_write) every METRICS_PUSH_INTERVAL
+func startMetricsExporter(ctx context.Context, healthService *health.Service, elector *leader.Elector, waitGroup *sync.WaitGroup) {
+	pushURL := os.Getenv("METRICS_PUSH_URL")
+	if pushURL == "" {
+		return
+	}
+
+	format := os.Getenv("METRICS_PUSH_FORMAT")
+	if format == "" {
+		format = metrics.FormatInflux
+	}
+
+	interval := defaultMetricsPushInterval
+	if value := os.Getenv("METRICS_PUSH_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid METRICS_PUSH_INTERVAL %q, using %s", value, defaultMetricsPushInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	exporter, err := metrics.NewExporter(pushURL, format, os.Getenv("METRICS_PUSH_AUTHORIZATION"), interval, healthService)
+	if err != nil {
+		log.Fatalf("Invalid metrics exporter configuration: %v", err)
+	}
+
+	// Only the leader runs health checks, so only it has results to push
+	shouldPush := func() bool {
+		return elector == nil || elector.IsLeader()
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		exporter.Run(ctx, shouldPush)
+		log.Println("Metrics exporter goroutine shutting down...")
+	}()
+
+	log.Printf("Pushing health metrics to %s (%s) every %s\n", pushURL, format, interval)
+}
+
+// startCertificateReport periodically records a certificate expiry summary in the log and audit log,
+// and alerts notification channels to certificates that are failing to renew. CERT_REPORT_INTERVAL
+// changes the schedule (default weekly); set it to 0 to disable the report.
+func startCertificateReport(ctx context.Context, caddyClient *caddy.Client, auditService *audit.Service, notifier *notify.Notifier, elector *leader.Elector, waitGroup *sync.WaitGroup) {
+	interval := defaultCertReportInterval
+	if value := os.Getenv("CERT_REPORT_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		switch {
+		case err == nil && parsed == 0:
+			return
+		case err != nil || parsed < 0:
+			log.Printf("Warning: Invalid CERT_REPORT_INTERVAL %q, using %s", value, defaultCertReportInterval)
+		default:
+			interval = parsed
+		}
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if elector != nil && !elector.IsLeader() {
+					continue
+				}
+				report, err := caddyClient.CertificateReport()
+				if err != nil {
+					log.Printf("Failed to build certificate report: %v", err)
+					continue
+				}
+				summary := caddy.SummarizeCertificateReport(report)
+				log.Println(summary)
+				if err := auditService.Log("CERTIFICATE_REPORT", summary, "system", "cert-report", ""); err != nil {
+					log.Printf("Warning: Failed to write audit log: %v\n", err)
+				}
+				for _, status := range report {
+					if status.RenewalOverdue() {
+						notifier.Notify(notify.Message{
+							Event:    notify.EventCertificateRenewalFailed,
+							Title:    fmt.Sprintf("Certificate for %s is not renewing", status.Domain),
+							Text:     status.Message,
+							Domain:   status.Domain,
+							Critical: status.DaysToExpiry <= 0, // expired, or within a day of it
+						})
+					}
+				}
+			case <-ctx.Done():
+				log.Println("Certificate report goroutine shutting down...")
+
+				return
+			}
+		}
+	}()
+}
+
+// startRemoteBackups uploads archives encrypted with BACKUP_PASSPHRASE to S3 (BACKUP_S3_*) or
+// WebDAV (BACKUP_WEBDAV_*) every BACKUP_INTERVAL (default daily, 0 for on demand only), keeping the
+// newest BACKUP_KEEP. It returns nil when no target is configured.
+func startRemoteBackups(ctx context.Context, cfg *serverConfig, auditService *audit.Service, notifier *notify.Notifier, elector *leader.Elector, waitGroup *sync.WaitGroup) *backup.Remote {
+	var target backup.Target
+	var err error
+	switch {
+	case os.Getenv("BACKUP_S3_BUCKET") != "":
+		target, err = backup.NewS3(backup.S3Config{
+			Endpoint:  os.Getenv("BACKUP_S3_ENDPOINT"),
+			Bucket:    os.Getenv("BACKUP_S3_BUCKET"),
+			Region:    os.Getenv("BACKUP_S3_REGION"),
+			AccessKey: os.Getenv("BACKUP_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("BACKUP_S3_SECRET_KEY"),
+			Prefix:    os.Getenv("BACKUP_S3_PREFIX"),
+		})
+	case os.Getenv("BACKUP_WEBDAV_URL") != "":
+		target, err = backup.NewWebDAV(backup.WebDAVConfig{
+			URL:      os.Getenv("BACKUP_WEBDAV_URL"),
+			Username: os.Getenv("BACKUP_WEBDAV_USERNAME"),
+			Password: os.Getenv("BACKUP_WEBDAV_PASSWORD"),
+		})
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Fatalf("Invalid remote backup configuration: %v", err)
+	}
+
+	interval := defaultBackupInterval
+	if value := os.Getenv("BACKUP_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Printf("Warning: Invalid BACKUP_INTERVAL %q, using %s", value, defaultBackupInterval)
+		} else {
+			interval = parsed
+		}
+	}
+	keep := defaultBackupKeep
+	if value := os.Getenv("BACKUP_KEEP"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Warning: Invalid BACKUP_KEEP %q, using %d", value, defaultBackupKeep)
+		} else {
+			keep = parsed
+		}
+	}
+
+	remote, err := backup.NewRemote(cfg.dataDir, target, os.Getenv("BACKUP_PASSPHRASE"), keep, interval)
+	if err != nil {
+		log.Fatalf("Invalid remote backup configuration: %v", err)
+	}
+	if kind := stateStoreKind(); kind != "file" {
+		log.Printf("Warning: Remote backups are off, as archives only cover the data directory but state is kept in %s", kind)
+		return nil
+	}
+
+	// Replicas share the data directory, so one upload per interval is enough
+	shouldUpload := func() bool {
+		return elector == nil || elector.IsLeader()
+	}
+	uploaded := func(name string, err error) {
+		if err != nil {
+			notifier.Notify(notify.Message{
+				Event: notify.EventBackupFailed,
+				Title: "Scheduled backup failed",
+				Text:  err.Error(),
+			})
+			return
+		}
+		if err := auditService.Log("CREATE_BACKUP", fmt.Sprintf("Backup %s uploaded to %s", name, remote.Kind()), "system", "backup", ""); err != nil {
+			log.Printf("Warning: Failed to write audit log: %v\n", err)
+		}
+	}
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		remote.Run(ctx, shouldUpload, uploaded)
+		log.Println("Remote backup goroutine shutting down...")
+	}()
+
+	if interval > 0 {
+		log.Printf("Uploading encrypted backups to %s every %s, keeping %d\n", remote.Kind(), interval, keep)
+	}
+	return remote
+}
+
+// startStatusPoller refreshes Caddy status in the background so /api/status is served from cache.
+// The interval can be changed with STATUS_POLL_INTERVAL.
+func startStatusPoller(ctx context.Context, caddyClient *caddy.Client, waitGroup *sync.WaitGroup) *caddy.StatusPoller {
+	interval := defaultStatusPollInterval
+	if value := os.Getenv("STATUS_POLL_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid STATUS_POLL_INTERVAL %q, using %s", value, defaultStatusPollInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	poller := caddy.NewStatusPoller(caddyClient, interval)
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		poller.Run(ctx)
+		log.Println("Status poller goroutine shutting down...")
+	}()
+
+	return poller
+}
+
+// newAPILimiter configures in-flight, per-IP rate and deadline limits for the API from
+// API_MAX_IN_FLIGHT, API_RATE_LIMIT, API_RATE_BURST and API_REQUEST_TIMEOUT. 0 disables a limit.
+func newAPILimiter() *limiter.Limiter {
+	config := limiter.Config{
+		MaxInFlight:   defaultAPIMaxInFlight,
+		RatePerSecond: defaultAPIRateLimit,
+		Burst:         defaultAPIRateBurst,
+		Timeout:       defaultAPIRequestTimeout,
+		// Routes that legitimately wait on Caddy or downloads get longer deadlines
+		RouteTimeouts: map[string]time.Duration{
+			"POST /api/certificates/preprovision": 130 * time.Second,
+			"POST /api/update":                    5 * time.Minute,
+			"GET /api/reports/usage":              2 * time.Minute,
+			"POST /api/restore":                   2 * time.Minute,
+		},
+		StreamRoutes: map[string]bool{
+			"GET /api/events": true,
+		},
+		ServerTimeout: timeout60s * time.Second,
+	}
+
+	if value := os.Getenv("API_MAX_IN_FLIGHT"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid API_MAX_IN_FLIGHT: %s", value)
+		}
+		config.MaxInFlight = parsed
+	}
+
+	if value := os.Getenv("API_RATE_LIMIT"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid API_RATE_LIMIT: %s", value)
+		}
+		config.RatePerSecond = parsed
+	}
+
+	if value := os.Getenv("API_RATE_BURST"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid API_RATE_BURST: %s", value)
+		}
+		config.Burst = parsed
+	}
+
+	if value := os.Getenv("API_REQUEST_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Fatalf("Invalid API_REQUEST_TIMEOUT: %s", value)
+		}
+		config.Timeout = parsed
+	}
+
+	return limiter.New(config)
+}
+
+// startConfigWatcher checks Caddy's config every CONFIG_WATCH_INTERVAL for changes made outside the
+// manager, logging and auditing drift when it is first seen. Unless CONFIG_SELF_HEAL is false, the
+// leader also re-applies the saved config when Caddy comes back from a restart without it.
+func startConfigWatcher(ctx context.Context, caddyClient *caddy.Client, auditService *audit.Service, eventBroker *events.Broker, elector *leader.Elector, waitGroup *sync.WaitGroup) *caddy.ConfigWatcher {
+	interval := defaultConfigWatchInterval
+	if value := os.Getenv("CONFIG_WATCH_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid CONFIG_WATCH_INTERVAL %q, using %s", value, defaultConfigWatchInterval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	watcher := caddy.NewConfigWatcher(caddyClient, interval)
+	watcher.OnDrift(func(state caddy.DriftState) {
+		// Every replica watches so the API can report drift, but only the leader records it
+		if elector != nil && !elector.IsLeader() {
+			return
+		}
+
+		details := "Caddy's config was changed outside the proxy manager; adopt or restore it via /api/config/drift/resolve"
+		log.Println(details)
+		if err := auditService.Log("CONFIG_DRIFT", details, "system", "config-watcher", ""); err != nil {
+			log.Printf("Warning: Failed to write audit log: %v\n", err)
+		}
+	})
+
+	if os.Getenv("CONFIG_SELF_HEAL") != "false" {
+		watcher.EnableReapply(func() bool {
+			return elector == nil || elector.IsLeader()
+		})
+	}
+	watcher.OnReapply(func(result caddy.ReapplyResult) {
+		action := "CONFIG_REAPPLIED"
+		details := fmt.Sprintf("Caddy was running none of the managed routes, likely after a restart; re-applied the saved config with %d routes", result.Routes)
+		if result.Error != "" {
+			action = "CONFIG_REAPPLY_FAILED"
+			details = fmt.Sprintf("Caddy was running none of the managed routes, likely after a restart; re-applying the saved config failed and will be retried: %s", result.Error)
+		}
+
+		log.Println(details)
+		if err := auditService.Log(action, details, "system", "config-watcher", ""); err != nil {
+			log.Printf("Warning: Failed to write audit log: %v\n", err)
+		}
+		eventBroker.Publish(events.TypeConfig, events.ConfigReapply{Routes: result.Routes, Error: result.Error})
+	})
+
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		watcher.Run(ctx)
+		log.Println("Config watcher goroutine shutting down...")
+	}()
+
+	return watcher
+}
+
 // setupRoutes registers all HTTP routes for the API, separating public auth routes from protected routes
 func setupRoutes(
 	mux *http.ServeMux,
 	handler *handlers.Handler,
 	authHandler *handlers.AuthHandler,
+	presetHandler *handlers.PresetHandler,
+	certificateHandler *handlers.CertificateHandler,
+	updateHandler *handlers.UpdateHandler,
+	pageHandler *handlers.PageHandler,
+	backupHandler *handlers.BackupHandler,
+	notificationHandler *handlers.NotificationHandler,
 	corsHandler func(http.HandlerFunc) http.HandlerFunc,
 	authMiddleware *auth.Middleware,
 ) {
@@ -147,21 +1206,138 @@ func setupRoutes(
 	mux.HandleFunc("POST /api/auth/login", corsHandler(authHandler.Login))
 	mux.HandleFunc("POST /api/auth/logout", corsHandler(authHandler.Logout))
 	mux.HandleFunc("GET /api/auth/me", corsHandler(authMiddleware.RequireAuth(authHandler.Me)))
+	mux.HandleFunc("POST /api/auth/change-password", corsHandler(authMiddleware.RequireAuth(authHandler.ChangePassword)))
+	mux.HandleFunc("GET /api/saved-searches", corsHandler(authMiddleware.RequireAuth(authHandler.GetSavedSearches)))
+	mux.HandleFunc("POST /api/saved-searches", corsHandler(authMiddleware.RequireAuth(authHandler.CreateSavedSearch)))
+	mux.HandleFunc("PUT /api/saved-searches/{id}", corsHandler(authMiddleware.RequireAuth(authHandler.UpdateSavedSearch)))
+	mux.HandleFunc("DELETE /api/saved-searches/{id}", corsHandler(authMiddleware.RequireAuth(authHandler.DeleteSavedSearch)))
+	mux.HandleFunc("GET /api/users", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.GetUsers)))
+	mux.HandleFunc("POST /api/users", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.CreateUser)))
+	mux.HandleFunc("GET /api/users/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.GetUser)))
+	mux.HandleFunc("PUT /api/users/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.UpdateUser)))
+	mux.HandleFunc("DELETE /api/users/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.DeleteUser)))
+	mux.HandleFunc("GET /api/tokens", corsHandler(authMiddleware.RequireAuth(authHandler.GetAPITokens)))
+	mux.HandleFunc("POST /api/tokens", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.CreateAPIToken)))
+	mux.HandleFunc("DELETE /api/tokens/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, authHandler.DeleteAPIToken)))
+
+	// The local CA root is public so devices can fetch and trust it without logging in
+	mux.HandleFunc("GET /api/ca/root.crt", corsHandler(handler.GetInternalCARoot))
+
+	// Deploy hooks authenticate with a per-proxy token instead of a session
+	mux.HandleFunc("POST /api/hooks/deploy/{proxyID}", corsHandler(handler.DeployHook))
+
+	// The API description is public so tools can generate clients before anyone signs in
+	mux.HandleFunc("GET /api/openapi.json", corsHandler(handler.GetOpenAPI))
 
 	// Protected API routes
 	mux.HandleFunc("GET /api/health", corsHandler(authMiddleware.RequireAuth(handler.Health)))
 	mux.HandleFunc("GET /api/proxies", corsHandler(authMiddleware.RequireAuth(handler.GetProxies)))
-	mux.HandleFunc("POST /api/proxies", corsHandler(authMiddleware.RequireAuth(handler.CreateProxy)))
-	mux.HandleFunc("PUT /api/proxies/{id}", corsHandler(authMiddleware.RequireAuth(handler.UpdateProxy)))
-	mux.HandleFunc("DELETE /api/proxies/{id}", corsHandler(authMiddleware.RequireAuth(handler.DeleteProxy)))
+	mux.HandleFunc("POST /api/proxies", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateProxy)))
+	mux.HandleFunc("POST /api/proxies/validate", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.ValidateProxyStep)))
+	mux.HandleFunc("GET /api/proxies/lookup", corsHandler(authMiddleware.RequireAuth(handler.LookupProxy)))
+	mux.HandleFunc("GET /api/proxies/{id}", corsHandler(authMiddleware.RequireAuth(handler.GetProxy)))
+	mux.HandleFunc("PUT /api/proxies/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateProxy)))
+	mux.HandleFunc("PATCH /api/proxies/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.PatchProxy)))
+	mux.HandleFunc("DELETE /api/proxies/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteProxy)))
+	mux.HandleFunc("POST /api/proxies/{id}/connectivity", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.TestProxyConnectivity)))
+	mux.HandleFunc("GET /api/proxies/{id}/stats", corsHandler(authMiddleware.RequireAuth(handler.GetProxyStats)))
+	mux.HandleFunc("GET /api/discovery/kubernetes", corsHandler(authMiddleware.RequireAuth(handler.GetKubernetesServices)))
 	mux.HandleFunc("GET /api/proxies/{id}/status", corsHandler(authMiddleware.RequireAuth(handler.GetProxyStatus)))
+	mux.HandleFunc("GET /api/proxies/{id}/generated", corsHandler(authMiddleware.RequireAuth(handler.GetProxyGenerated)))
+	mux.HandleFunc("POST /api/proxies/{id}/deploy-token", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateDeployToken)))
+	mux.HandleFunc("DELETE /api/proxies/{id}/deploy-token", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteDeployToken)))
+	mux.HandleFunc("GET /api/proxies/{id}/certificate", corsHandler(authMiddleware.RequireAuth(handler.GetCustomCertificate)))
+	mux.HandleFunc("PUT /api/proxies/{id}/certificate", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UploadCustomCertificate)))
+	mux.HandleFunc("DELETE /api/proxies/{id}/certificate", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteCustomCertificate)))
+	mux.HandleFunc("PUT /api/proxies/{id}/health-check/pause", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.PauseProxyHealthCheck)))
+	mux.HandleFunc("GET /api/proxies/{id}/ip-lists", corsHandler(authMiddleware.RequireAuth(handler.GetIPLists)))
+	mux.HandleFunc("POST /api/proxies/{id}/ip-lists", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.ImportIPList)))
+	mux.HandleFunc("POST /api/proxies/{id}/ip-lists/{listID}/refresh", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.RefreshIPList)))
+	mux.HandleFunc("DELETE /api/proxies/{id}/ip-lists/{listID}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteIPList)))
+	mux.HandleFunc("GET /api/proxies/{id}/debug-capture", corsHandler(authMiddleware.RequireAuth(handler.GetDebugCapture)))
+	mux.HandleFunc("POST /api/proxies/{id}/debug-capture", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.StartDebugCapture)))
+	mux.HandleFunc("DELETE /api/proxies/{id}/debug-capture", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.StopDebugCapture)))
+	mux.HandleFunc("GET /api/proxies/{id}/debug-capture/download", corsHandler(authMiddleware.RequireAuth(handler.DownloadDebugCapture)))
+	mux.HandleFunc("GET /api/proxies/{id}/access-log", corsHandler(authMiddleware.RequireAuth(handler.DownloadAccessLog)))
+	mux.HandleFunc("GET /api/dns-providers", corsHandler(authMiddleware.RequireAuth(handler.GetDNSProviders)))
+	mux.HandleFunc("GET /api/acme-dns/accounts", corsHandler(authMiddleware.RequireAuth(handler.GetACMEDNSAccounts)))
+	mux.HandleFunc("POST /api/acme-dns/accounts", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.RegisterACMEDNSAccount)))
+	mux.HandleFunc("DELETE /api/acme-dns/accounts/{domain}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteACMEDNSAccount)))
+	mux.HandleFunc("GET /api/certificates", corsHandler(authMiddleware.RequireAuth(certificateHandler.GetCertificates)))
+	mux.HandleFunc("GET /api/certificates/report", corsHandler(authMiddleware.RequireAuth(handler.GetCertificateReport)))
+	mux.HandleFunc("GET /api/certificates/preprovision", corsHandler(authMiddleware.RequireAuth(handler.GetPreprovisionedCertificates)))
+	mux.HandleFunc("POST /api/certificates/preprovision", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.PreprovisionCertificates)))
+	mux.HandleFunc("DELETE /api/certificates/preprovision/{domain}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeletePreprovisionedCertificate)))
+	mux.HandleFunc("GET /api/pages", corsHandler(authMiddleware.RequireAuth(pageHandler.GetPages)))
+	mux.HandleFunc("GET /api/pages/{kind}/{language}", corsHandler(authMiddleware.RequireAuth(pageHandler.GetPage)))
+	mux.HandleFunc("PUT /api/pages/{kind}/{language}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, pageHandler.SavePage)))
+	mux.HandleFunc("DELETE /api/pages/{kind}/{language}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, pageHandler.DeletePage)))
+	mux.HandleFunc("GET /api/presets", corsHandler(authMiddleware.RequireAuth(presetHandler.GetPresets)))
+	mux.HandleFunc("GET /api/presets/{id}", corsHandler(authMiddleware.RequireAuth(presetHandler.GetPreset)))
 	mux.HandleFunc("GET /api/redirects", corsHandler(authMiddleware.RequireAuth(handler.GetRedirects)))
-	mux.HandleFunc("POST /api/redirects", corsHandler(authMiddleware.RequireAuth(handler.CreateRedirect)))
-	mux.HandleFunc("PUT /api/redirects/{id}", corsHandler(authMiddleware.RequireAuth(handler.UpdateRedirect)))
-	mux.HandleFunc("DELETE /api/redirects/{id}", corsHandler(authMiddleware.RequireAuth(handler.DeleteRedirect)))
+	mux.HandleFunc("POST /api/redirects", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateRedirect)))
+	mux.HandleFunc("GET /api/redirects/{id}", corsHandler(authMiddleware.RequireAuth(handler.GetRedirect)))
+	mux.HandleFunc("PUT /api/redirects/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateRedirect)))
+	mux.HandleFunc("DELETE /api/redirects/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteRedirect)))
+	mux.HandleFunc("GET /api/access-lists", corsHandler(authMiddleware.RequireAuth(handler.GetAccessLists)))
+	mux.HandleFunc("POST /api/access-lists", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateAccessList)))
+	mux.HandleFunc("GET /api/access-lists/{id}", corsHandler(authMiddleware.RequireAuth(handler.GetAccessList)))
+	mux.HandleFunc("PUT /api/access-lists/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateAccessList)))
+	mux.HandleFunc("DELETE /api/access-lists/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteAccessList)))
+	mux.HandleFunc("GET /api/templates", corsHandler(authMiddleware.RequireAuth(handler.GetProxyTemplates)))
+	mux.HandleFunc("POST /api/templates", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateProxyTemplate)))
+	mux.HandleFunc("GET /api/templates/{id}", corsHandler(authMiddleware.RequireAuth(handler.GetProxyTemplate)))
+	mux.HandleFunc("PUT /api/templates/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateProxyTemplate)))
+	mux.HandleFunc("DELETE /api/templates/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteProxyTemplate)))
+	mux.HandleFunc("POST /api/templates/{id}/proxies", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateProxyFromTemplate)))
+	mux.HandleFunc("GET /api/notifications", corsHandler(authMiddleware.RequireAuth(notificationHandler.GetNotificationChannels)))
+	mux.HandleFunc("POST /api/notifications", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, notificationHandler.CreateNotificationChannel)))
+	mux.HandleFunc("GET /api/notifications/types", corsHandler(authMiddleware.RequireAuth(notificationHandler.GetNotificationTypes)))
+	mux.HandleFunc("GET /api/notifications/{id}", corsHandler(authMiddleware.RequireAuth(notificationHandler.GetNotificationChannel)))
+	mux.HandleFunc("PUT /api/notifications/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, notificationHandler.UpdateNotificationChannel)))
+	mux.HandleFunc("DELETE /api/notifications/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, notificationHandler.DeleteNotificationChannel)))
+	mux.HandleFunc("POST /api/notifications/{id}/test", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, notificationHandler.TestNotificationChannel)))
+	mux.HandleFunc("GET /api/streams", corsHandler(authMiddleware.RequireAuth(handler.GetStreams)))
+	mux.HandleFunc("POST /api/streams", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreateStream)))
+	mux.HandleFunc("POST /api/streams/port-forward", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.CreatePortForward)))
+	mux.HandleFunc("GET /api/streams/{id}", corsHandler(authMiddleware.RequireAuth(handler.GetStream)))
+	mux.HandleFunc("GET /api/streams/{id}/reachability", corsHandler(authMiddleware.RequireAuth(handler.GetStreamReachability)))
+	mux.HandleFunc("PUT /api/streams/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateStream)))
+	mux.HandleFunc("DELETE /api/streams/{id}", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.DeleteStream)))
+	mux.HandleFunc("GET /api/health-checks", corsHandler(authMiddleware.RequireAuth(handler.GetHealthChecks)))
+	mux.HandleFunc("PUT /api/health-checks/pause", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.PauseHealthChecks)))
+	mux.HandleFunc("GET /api/settings/server", corsHandler(authMiddleware.RequireAuth(handler.GetServerSettings)))
+	mux.HandleFunc("PUT /api/settings/server", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateServerSettings)))
+	mux.HandleFunc("GET /api/settings/storage", corsHandler(authMiddleware.RequireAuth(handler.GetStorageSettings)))
+	mux.HandleFunc("PUT /api/settings/storage", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateStorageSettings)))
+	mux.HandleFunc("GET /api/settings/default-site", corsHandler(authMiddleware.RequireAuth(handler.GetDefaultSite)))
+	mux.HandleFunc("PUT /api/settings/default-site", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateDefaultSite)))
+	mux.HandleFunc("GET /api/generate/secret", corsHandler(authMiddleware.RequireAuth(handler.GenerateSecret)))
+	mux.HandleFunc("GET /api/waf/violations", corsHandler(authMiddleware.RequireAuth(handler.GetWAFViolations)))
+	mux.HandleFunc("POST /api/ids/migrate", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.MigrateIDs)))
+	mux.HandleFunc("POST /api/import/csv", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.ImportCSV)))
+	mux.HandleFunc("GET /api/events", corsHandler(authMiddleware.RequireAuth(handler.StreamEvents)))
+	mux.HandleFunc("GET /api/reports/usage", corsHandler(authMiddleware.RequireAuth(handler.GetUsageReport)))
 	mux.HandleFunc("GET /api/status", corsHandler(authMiddleware.RequireAuth(handler.Status)))
-	mux.HandleFunc("POST /api/reload", corsHandler(authMiddleware.RequireAuth(handler.Reload)))
+	mux.HandleFunc("GET /api/features", corsHandler(authMiddleware.RequireAuth(handler.GetFeatures)))
+	mux.HandleFunc("GET /api/version", corsHandler(authMiddleware.RequireAuth(updateHandler.GetVersion)))
+	mux.HandleFunc("POST /api/update", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, updateHandler.ApplyUpdate)))
+	mux.HandleFunc("GET /api/backup", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, backupHandler.GetBackup)))
+	mux.HandleFunc("POST /api/restore", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, backupHandler.Restore)))
+	mux.HandleFunc("GET /api/backup/remote", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, backupHandler.GetRemoteBackups)))
+	mux.HandleFunc("POST /api/backup/remote", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, backupHandler.CreateRemoteBackup)))
+	mux.HandleFunc("POST /api/backup/remote/{name}/restore", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, backupHandler.RestoreRemoteBackup)))
+	mux.HandleFunc("GET /api/config/export", corsHandler(authMiddleware.RequireAuth(handler.ExportConfig)))
+	mux.HandleFunc("GET /api/export/caddyfile", corsHandler(authMiddleware.RequireAuth(handler.ExportCaddyfile)))
+	mux.HandleFunc("GET /api/config/drift", corsHandler(authMiddleware.RequireAuth(handler.GetConfigDrift)))
+	mux.HandleFunc("GET /api/config/foreign-changes", corsHandler(authMiddleware.RequireAuth(handler.GetForeignChanges)))
+	mux.HandleFunc("POST /api/config/drift/resolve", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.ResolveConfigDrift)))
+	mux.HandleFunc("POST /api/reload", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.Reload)))
 	mux.HandleFunc("GET /api/audit-log", corsHandler(authMiddleware.RequireAuth(handler.GetAuditLog)))
+	mux.HandleFunc("GET /api/audit-log/analysis", corsHandler(authMiddleware.RequireAuth(handler.GetAuditAnalysis)))
+	mux.HandleFunc("GET /api/internal-ca", corsHandler(authMiddleware.RequireAuth(handler.GetInternalCA)))
+	mux.HandleFunc("PUT /api/internal-ca", corsHandler(authMiddleware.RequireRole(models.RoleAdmin, handler.UpdateInternalCA)))
+	mux.HandleFunc("GET /api/internal-ca/root.crt", corsHandler(authMiddleware.RequireAuth(handler.GetInternalCARoot)))
 }
 
 // setupStaticHandler configures serving of static files with SPA fallback support
@@ -209,6 +1385,7 @@ func startServer(server *http.Server, cfg *serverConfig, waitGroup *sync.WaitGro
 		log.Printf("Caddy Admin API: %s\n", cfg.caddyAdminURL)
 		log.Printf("Config file: %s\n", cfg.configFile)
 		log.Printf("Data directory: %s\n", cfg.dataDir)
+		log.Printf("Version: %s\n", update.Version)
 
 		if os.Getenv("DISABLE_AUTH") == "true" {
 			log.Println("Authentication: DISABLED")
@@ -224,9 +1401,105 @@ func startServer(server *http.Server, cfg *serverConfig, waitGroup *sync.WaitGro
 	go serverFunc()
 }
 
-// initializeAuthStorage creates and initializes the authentication storage system
-func initializeAuthStorage(dataDir string) *auth.Storage {
-	authStorage := auth.NewStorage(dataDir)
+// deploymentFeatures describes the optional capabilities enabled by the environment at startup
+func deploymentFeatures(cfg *serverConfig, caddyClient *caddy.Client, handler *handlers.Handler, elector *leader.Elector, outboundGuard *netguard.Guard) handlers.Features {
+	return handlers.Features{
+		Auth:             os.Getenv("DISABLE_AUTH") != "true",
+		SessionStore:     sessionStoreKind(),
+		StateStore:       stateStoreKind(),
+		HighAvailability: elector != nil,
+		SelfUpdate:       os.Getenv("SELF_UPDATE") == "true",
+		ConfigSelfHeal:   os.Getenv("CONFIG_SELF_HEAL") != "false",
+		MetricsPush:      os.Getenv("METRICS_PUSH_URL") != "",
+		HealthHook:       os.Getenv("HEALTH_HOOK_COMMAND") != "",
+		OutboundGuard:    outboundGuard != nil,
+		ConnectivityTest: caddyClient.ProbeEnabled(),
+		TrafficStats:     caddyClient.TrafficLogEnabled(),
+		Kubernetes:       handler.Kubernetes != nil,
+		IDStrategy:       cfg.idStrategy,
+	}
+}
+
+// stateStoreKind names where users, proxy metadata and the audit log are kept
+func stateStoreKind() string {
+	if os.Getenv("DATABASE_URL") != "" {
+		return "postgres"
+	}
+	return "file"
+}
+
+// newStateStore connects to the Postgres database at DATABASE_URL, or returns nil to keep state in
+// the data directory
+func newStateStore() *store.Postgres {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return nil
+	}
+
+	database, err := store.NewPostgres(databaseURL)
+	if err != nil {
+		log.Fatalf("Failed to configure database: %v", err)
+	}
+	if err := database.Init(); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	log.Printf("State store: postgres (%s)\n", database.Addr())
+
+	return database
+}
+
+// sessionStoreKind returns SESSION_STORE, defaulting to the database when DATABASE_URL is set
+func sessionStoreKind() string {
+	if kind := os.Getenv("SESSION_STORE"); kind != "" {
+		return kind
+	}
+	return stateStoreKind()
+}
+
+// newSessionStore selects the session backend from SESSION_STORE ("file", "redis" or "postgres")
+func newSessionStore(database *store.Postgres) auth.SessionStore {
+	switch sessionStoreKind() {
+	case "file":
+		return nil // Storage falls back to the file store in the data directory
+	case "postgres":
+		if database == nil {
+			log.Fatalf("SESSION_STORE=postgres requires DATABASE_URL")
+		}
+
+		log.Println("Session store: postgres")
+
+		return database.Sessions()
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = defaultRedisURL
+		}
+
+		store, err := auth.NewRedisSessionStore(redisURL)
+		if err != nil {
+			log.Fatalf("Failed to configure redis session store: %v", err)
+		}
+
+		log.Printf("Session store: redis (%s)\n", store.Addr())
+
+		return store
+	default:
+		log.Fatalf("Unsupported SESSION_STORE: %s", os.Getenv("SESSION_STORE"))
+
+		return nil
+	}
+}
+
+// initializeAuthStorage creates and initializes the authentication storage system, keeping it in the
+// database when there is one
+func initializeAuthStorage(dataDir string, database *store.Postgres) *auth.Storage {
+	configurePasswordHashing()
+
+	authStorage := auth.NewStorage(dataDir, newSessionStore(database))
+	if database != nil {
+		authStorage.SetStore(database)
+	}
 	if err := authStorage.Initialize(); err != nil {
 		log.Fatalf("Failed to initialize auth storage: %v", err)
 	}
@@ -234,28 +1507,170 @@ func initializeAuthStorage(dataDir string) *auth.Storage {
 	return authStorage
 }
 
-// gracefulShutdown handles server shutdown by stopping HTTP server and waiting for all goroutines to complete
-func gracefulShutdown(server *http.Server, waitGroup *sync.WaitGroup, cancel context.CancelFunc) {
+// newSelfUpdateRestart returns the function the update endpoint calls after replacing the binary,
+// or nil when SELF_UPDATE isn't enabled. Restarting shuts the server down gracefully and then
+// re-executes the new binary in place of the current process.
+func newSelfUpdateRestart(cancel context.CancelFunc, restartRequested *atomic.Bool) func() {
+	if os.Getenv("SELF_UPDATE") != "true" {
+		return nil
+	}
+
+	return func() {
+		restartRequested.Store(true)
+		cancel()
+	}
+}
+
+// restartProcess replaces the current process with a fresh copy of the (updated) executable
+func restartProcess() {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to locate executable for restart: %v", err)
+	}
+
+	log.Printf("Restarting %s\n", executable)
+	if err := syscall.Exec(executable, os.Args, os.Environ()); err != nil {
+		log.Fatalf("Failed to restart: %v", err)
+	}
+}
+
+// runSelfUpdate implements the "update" command, installing the latest release and exiting
+func runSelfUpdate() {
+	log.Printf("Current version: %s\n", update.Version)
+
+	version, err := update.NewUpdater("", 0).Apply(context.Background())
+	if errors.Is(err, update.ErrNoUpdate) {
+		log.Println("Already running the latest version")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Update failed: %v", err)
+	}
+
+	log.Printf("Updated to %s, restart the server to run it\n", version)
+}
+
+// newBootstrapToken returns the token the first-run setup request must include, taken from
+// SETUP_TOKEN or generated and printed to the log. Returns an empty string once setup is done.
+func newBootstrapToken(authStorage *auth.Storage) string {
+	if os.Getenv("DISABLE_AUTH") == "true" || authStorage.IsSetup() {
+		return ""
+	}
+
+	if token := strings.TrimSpace(os.Getenv("SETUP_TOKEN")); token != "" {
+		log.Println("Setup required: use the bootstrap token from SETUP_TOKEN to create the admin account")
+		return token
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		log.Fatalf("Failed to generate bootstrap token: %v", err)
+	}
+
+	log.Println("Setup required: create the admin account with this bootstrap token:")
+	log.Printf("    %s\n", token)
+
+	return token
+}
+
+// shutdownTimeouts reads SHUTDOWN_TIMEOUT, bounding how long shutdown waits for requests and config
+// writes, and HEALTH_CHECK_DRAIN_TIMEOUT, bounding the wait for health checks in progress
+func shutdownTimeouts() (time.Duration, time.Duration) {
+	timeout := defaultShutdownTimeout
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid SHUTDOWN_TIMEOUT %q, using %s", value, defaultShutdownTimeout)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	healthTimeout := defaultHealthDrainTimeout
+	if value := os.Getenv("HEALTH_CHECK_DRAIN_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("Warning: Invalid HEALTH_CHECK_DRAIN_TIMEOUT %q, using %s", value, defaultHealthDrainTimeout)
+		} else {
+			healthTimeout = parsed
+		}
+	}
+
+	return timeout, healthTimeout
+}
+
+// consumeCleanShutdownMarker reports whether the previous run ended with a complete graceful shutdown,
+// removing the marker so a crash of this run isn't mistaken for one
+func consumeCleanShutdownMarker(dataDir string) bool {
+	marker := filepath.Join(dataDir, cleanShutdownMarker)
+	if _, err := os.Stat(marker); err != nil {
+		return false
+	}
+	if err := os.Remove(marker); err != nil {
+		log.Printf("Warning: Could not remove clean shutdown marker: %v", err)
+		return false
+	}
+	return true
+}
+
+// gracefulShutdown stops accepting mutations, lets in-flight requests finish, stops health checks and
+// background jobs, then flushes pending config and metadata writes. The clean shutdown marker is only
+// written when every step finished in time.
+func gracefulShutdown(server *http.Server, drainGate *handlers.DrainGate, healthService *health.Service, caddyClient *caddy.Client, cfg *serverConfig, waitGroup *sync.WaitGroup, cancel context.CancelFunc) {
 	log.Println("\nShutdown signal received, initiating graceful shutdown...")
+	drainGate.Start()
 	cancel()
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeoutSeconds*time.Second)
+	timeout, healthTimeout := shutdownTimeouts()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
 	defer shutdownCancel()
 
+	clean := true
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
+		clean = false
 	} else {
 		log.Println("HTTP server gracefully stopped")
 	}
 
+	if healthService.Shutdown(healthTimeout) {
+		log.Println("Health checks stopped")
+	} else {
+		log.Printf("Warning: Health checks still running after %s, not waiting for them", healthTimeout)
+		clean = false
+	}
+
 	log.Println("Waiting for goroutines to finish...")
 	waitGroup.Wait()
-	log.Println("All goroutines finished, graceful shutdown completed")
+	log.Println("All goroutines finished")
+
+	if err := caddyClient.Drain(shutdownCtx); err != nil {
+		log.Printf("Warning: Failed to flush configuration writes: %v", err)
+		clean = false
+	} else {
+		log.Println("Configuration writes flushed")
+	}
+
+	if !clean {
+		log.Println("Graceful shutdown completed with errors, recovery checks will run at next startup")
+		return
+	}
+	marker := filepath.Join(cfg.dataDir, cleanShutdownMarker)
+	if err := os.WriteFile(marker, []byte(time.Now().Format(time.RFC3339)+"\n"), 0600); err != nil {
+		log.Printf("Warning: Could not write clean shutdown marker: %v", err)
+	}
+	log.Println("Graceful shutdown completed")
 }
 
 // main is the entry point that initializes and orchestrates all server components
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "update" {
+		runSelfUpdate()
+		return
+	}
+
 	var waitGroup sync.WaitGroup
+	var restartRequested atomic.Bool
 
 	// Set up signal handling for graceful shutdown
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -263,36 +1678,80 @@ func main() {
 
 	// Load configuration and initialize core services
 	cfg := getServerConfig()
-	caddyClient := initializeCaddy(cfg)
+	pageStore := newPageStore(cfg)
+	database := newStateStore()
+	caddyClient := initializeCaddy(cfg, pageStore, database)
 
 	// Initialize health monitoring system
-	healthService := health.NewService()
+	outboundGuard := newOutboundGuard()
+	healthService := health.NewService(outboundGuard)
+	healthService.SetHeaderOpener(caddyClient.OpenHealthCheckHeaders)
+	setHealthCheckCaddyHost(cfg, healthService)
+	configureUpstreamProbe(cfg, caddyClient)
+	configureHealthCheckPacing(caddyClient, healthService)
+	elector := startLeaderElection(ctx, cfg, database, healthService, &waitGroup)
 	startHealthChecks(caddyClient, healthService)
 
 	// Set up authentication system
-	authStorage := initializeAuthStorage(cfg.dataDir)
-	startSessionCleanup(ctx, authStorage, &waitGroup)
+	authStorage := initializeAuthStorage(cfg.dataDir, database)
+	startSessionCleanup(ctx, authStorage, elector, &waitGroup)
+	startDebugCaptureExpiry(ctx, caddyClient, elector, &waitGroup)
+	startMetricsExporter(ctx, healthService, elector, &waitGroup)
 
 	// Initialize audit logging
 	auditService := audit.NewService(cfg.dataDir)
+	if database != nil {
+		auditService.SetStore(database)
+	}
+	auditFailovers(healthService, auditService)
+	startHealthHook(healthService)
+	notifier := newNotifier(caddyClient, healthService, outboundGuard)
+	startNotificationDigests(ctx, notifier, &waitGroup)
+	startCertificateReport(ctx, caddyClient, auditService, notifier, elector, &waitGroup)
 
 	// Create HTTP handlers and middleware
-	handler := handlers.New(caddyClient, healthService, auditService)
-	authHandler := handlers.NewAuthHandler(authStorage, auditService)
+	statusPoller := startStatusPoller(ctx, caddyClient, &waitGroup)
+	eventBroker := newEventBroker(healthService, statusPoller)
+	notifyConfigChanges(eventBroker, notifier)
+	configWatcher := startConfigWatcher(ctx, caddyClient, auditService, eventBroker, elector, &waitGroup)
+	startSecurityAnalysis(ctx, auditService, eventBroker, elector, &waitGroup)
+	startIPListRefresh(ctx, caddyClient, auditService, eventBroker, outboundGuard, elector, &waitGroup)
+	handler := handlers.New(caddyClient, healthService, auditService, statusPoller, configWatcher, outboundGuard, eventBroker)
+	handler.Stats = startTrafficStats(ctx, caddyClient, &waitGroup)
+	handler.Kubernetes = startKubernetesDiscovery(ctx, handler, elector, &waitGroup)
+	handler.PortCheck = newPortChecker()
+	handler.Features = deploymentFeatures(cfg, caddyClient, handler, elector, outboundGuard)
+	authHandler := handlers.NewAuthHandler(authStorage, auditService, newBootstrapToken(authStorage))
+	presetHandler := handlers.NewPresetHandler(loadPresets(cfg))
+	certificateHandler := handlers.NewCertificateHandler(newCertificateService(cfg, caddyClient))
+	pageHandler := handlers.NewPageHandler(pageStore, caddyClient, auditService)
+	notificationHandler := handlers.NewNotificationHandler(notifier, caddyClient, auditService)
+	backupHandler := handlers.NewBackupHandler(cfg.dataDir, caddyClient, authStorage, healthService, auditService, func() {
+		caddyClient.SetSecrets(newSecretsBox(cfg))
+	})
+	backupHandler.StateStore = stateStoreKind()
+	backupHandler.Remote = startRemoteBackups(ctx, cfg, auditService, notifier, elector, &waitGroup)
+	updateHandler := handlers.NewUpdateHandler(update.NewUpdater("", updateCheckCacheTTL), auditService, newSelfUpdateRestart(cancel, &restartRequested))
 	authMiddleware := auth.NewMiddleware(authStorage)
+	authMiddleware.ProxyTags = caddyClient.ProxyTags
 
 	// Configure HTTP routing
 	mux := http.NewServeMux()
 	corsHandler := authMiddleware.CORS
 
-	setupRoutes(mux, handler, authHandler, corsHandler, authMiddleware)
+	setupRoutes(mux, handler, authHandler, presetHandler, certificateHandler, updateHandler, pageHandler, backupHandler, notificationHandler, corsHandler, authMiddleware)
 	setupStaticHandler(mux, cfg.staticDir, corsHandler)
 
 	// Start the HTTP server
-	server := createServer(cfg.port, mux)
+	drainGate := handlers.NewDrainGate()
+	server := createServer(cfg.port, drainGate.Middleware(handler.RedirectLegacyIDs(newAPILimiter().Middleware(mux))))
 	startServer(server, cfg, &waitGroup)
 
 	// Wait for shutdown signal
 	<-ctx.Done()
-	gracefulShutdown(server, &waitGroup, cancel)
+	gracefulShutdown(server, drainGate, healthService, caddyClient, cfg, &waitGroup, cancel)
+
+	if restartRequested.Load() {
+		restartProcess()
+	}
 }
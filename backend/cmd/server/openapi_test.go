@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sarat/caddyproxymanager/internal/handlers"
+)
+
+// apiRoute is an API route registered in setupRoutes
+type apiRoute struct {
+	pattern string // e.g. "GET /api/proxies/{id}"
+	public  bool   // served without RequireAuth or RequireRole
+}
+
+// registeredRoutes parses setupRoutes for the API routes it registers
+func registeredRoutes(t *testing.T) map[string]apiRoute {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse main.go: %v", err)
+	}
+
+	routes := map[string]apiRoute{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || selector.Sel.Name != "HandleFunc" {
+			return true
+		}
+		literal, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		pattern, err := strconv.Unquote(literal.Value)
+		if err != nil || !strings.Contains(pattern, " /api/") {
+			return true
+		}
+
+		public := true
+		ast.Inspect(call.Args[1], func(node ast.Node) bool {
+			if selector, ok := node.(*ast.SelectorExpr); ok && (selector.Sel.Name == "RequireAuth" || selector.Sel.Name == "RequireRole") {
+				public = false
+			}
+			return true
+		})
+		routes[pattern] = apiRoute{pattern: pattern, public: public}
+		return true
+	})
+	if len(routes) == 0 {
+		t.Fatal("found no API routes in main.go")
+	}
+	return routes
+}
+
+// openAPIOperation is the part of an OpenAPI operation the tests check
+type openAPIOperation struct {
+	Summary   string             `json:"summary"`
+	Security  *[]json.RawMessage `json:"security"`
+	Responses map[string]any     `json:"responses"`
+}
+
+// openAPISpec is the part of the OpenAPI description the tests check
+type openAPISpec struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]any `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var spec openAPISpec
+	if err := json.Unmarshal(handlers.OpenAPI, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+
+	routes := registeredRoutes(t)
+	operations := map[string]openAPIOperation{}
+	for path, methods := range spec.Paths {
+		for method, operation := range methods {
+			operations[strings.ToUpper(method)+" "+path] = operation
+		}
+	}
+
+	var missing, extra []string
+	for pattern := range routes {
+		if _, ok := operations[pattern]; !ok {
+			missing = append(missing, pattern)
+		}
+	}
+	for pattern := range operations {
+		if _, ok := routes[pattern]; !ok {
+			extra = append(extra, pattern)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		t.Errorf("routes missing from openapi.json:\n%s", strings.Join(missing, "\n"))
+	}
+	if len(extra) > 0 {
+		t.Errorf("openapi.json operations without a route:\n%s", strings.Join(extra, "\n"))
+	}
+
+	for pattern, operation := range operations {
+		route, ok := routes[pattern]
+		if !ok {
+			continue
+		}
+		if operation.Summary == "" {
+			t.Errorf("%s has no summary", pattern)
+		}
+		if len(operation.Responses) == 0 {
+			t.Errorf("%s has no responses", pattern)
+		}
+		// Public routes override the global bearer requirement with an empty one
+		if public := operation.Security != nil && len(*operation.Security) == 0; public != route.public {
+			t.Errorf("%s: documented as public = %t, registered as public = %t", pattern, public, route.public)
+		}
+	}
+}
+
+func TestOpenAPISchemaReferences(t *testing.T) {
+	var spec openAPISpec
+	if err := json.Unmarshal(handlers.OpenAPI, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	var document any
+	if err := json.Unmarshal(handlers.OpenAPI, &document); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	var check func(value any)
+	check = func(value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			if ref, ok := value["$ref"].(string); ok {
+				if name, ok := strings.CutPrefix(ref, "#/components/schemas/"); ok {
+					if _, ok := spec.Components.Schemas[name]; !ok {
+						t.Errorf("reference to undefined schema %s", name)
+					}
+				}
+			}
+			for _, child := range value {
+				check(child)
+			}
+		case []any:
+			for _, child := range value {
+				check(child)
+			}
+		}
+	}
+	check(document)
+}
+
+func TestOpenAPIServedWithoutSigningIn(t *testing.T) {
+	_, server := newContractServer(t)
+
+	response, err := http.Get(server.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /api/openapi.json: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	if string(body) != string(handlers.OpenAPI) {
+		t.Error("served description differs from the embedded openapi.json")
+	}
+}
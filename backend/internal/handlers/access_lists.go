@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// accessListRequest is the body of requests creating or updating an access list
+type accessListRequest struct {
+	Name       string                  `json:"name"`
+	AllowedIPs []string                `json:"allowed_ips"`
+	BlockedIPs []string                `json:"blocked_ips"`
+	Users      []models.AccessListUser `json:"users"`
+}
+
+// accessListResponse is an access list without password hashes, with the proxies using it
+type accessListResponse struct {
+	models.AccessList
+	Proxies []string `json:"proxies"`
+}
+
+// accessListResponseFor builds the response for an access list
+func (h *Handler) accessListResponseFor(list models.AccessList) accessListResponse {
+	proxies := h.CaddyClient.AccessListUsers(list.ID)
+	if proxies == nil {
+		proxies = []string{}
+	}
+	return accessListResponse{AccessList: list.Redacted(), Proxies: proxies}
+}
+
+// decodeAccessListRequest reads an access list from the request body, validated against the list it
+// replaces, if any
+func decodeAccessListRequest(r *http.Request, previous *models.AccessList) (*models.AccessList, error) {
+	var listReq accessListRequest
+	if err := json.NewDecoder(r.Body).Decode(&listReq); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+
+	list := models.NewAccessList(listReq.Name, listReq.AllowedIPs, listReq.BlockedIPs, listReq.Users)
+	if err := caddy.NormalizeAccessList(list, previous); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetAccessLists returns the shared access lists
+func (h *Handler) GetAccessLists(w http.ResponseWriter, r *http.Request) {
+	lists := h.CaddyClient.AccessLists()
+	responses := make([]accessListResponse, 0, len(lists))
+	for _, list := range lists {
+		responses = append(responses, h.accessListResponseFor(list))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_lists": responses,
+		"count":        len(responses),
+	})
+}
+
+// GetAccessList returns a single access list
+func (h *Handler) GetAccessList(w http.ResponseWriter, r *http.Request) {
+	list, err := h.CaddyClient.GetAccessList(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Access list not found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.accessListResponseFor(*list))
+}
+
+// CreateAccessList creates a new access list
+func (h *Handler) CreateAccessList(w http.ResponseWriter, r *http.Request) {
+	list, err := decodeAccessListRequest(r, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	list.ID = h.CaddyClient.NewAccessListID(list.Name)
+
+	if err := h.caddyClientFor(r).SaveAccessList(*list); err != nil {
+		writeCaddyError(w, "Failed to save access list", err)
+		return
+	}
+
+	h.logAudit(r, "CREATE_ACCESS_LIST", fmt.Sprintf("Access list '%s' created with %d allowed, %d blocked ranges and %d users", list.ID, len(list.AllowedIPs), len(list.BlockedIPs), len(list.Users)))
+	writeJSON(w, http.StatusCreated, h.accessListResponseFor(*list))
+}
+
+// UpdateAccessList replaces an access list's rules and users, rebuilding the routes of every proxy
+// using it
+func (h *Handler) UpdateAccessList(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, err := h.CaddyClient.GetAccessList(id)
+	if err != nil {
+		http.Error(w, `{"error": "Access list not found"}`, http.StatusNotFound)
+		return
+	}
+
+	list, err := decodeAccessListRequest(r, existing)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	list.ID = id
+	list.CreatedAt = existing.CreatedAt
+
+	if err := h.caddyClientFor(r).SaveAccessList(*list); err != nil {
+		writeCaddyError(w, "Failed to save access list", err)
+		return
+	}
+
+	response := h.accessListResponseFor(*list)
+	h.logAudit(r, "UPDATE_ACCESS_LIST", fmt.Sprintf("Access list '%s' updated, applied to %d proxies", list.ID, len(response.Proxies)))
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DeleteAccessList removes an access list that no proxy uses
+func (h *Handler) DeleteAccessList(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.CaddyClient.GetAccessList(id); err != nil {
+		http.Error(w, `{"error": "Access list not found"}`, http.StatusNotFound)
+		return
+	}
+	if proxies := h.CaddyClient.AccessListUsers(id); len(proxies) > 0 {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":   "Access list is used by proxies, remove it from them first",
+			"proxies": proxies,
+		})
+		return
+	}
+
+	if err := h.CaddyClient.DeleteAccessList(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete access list: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "DELETE_ACCESS_LIST", fmt.Sprintf("Access list '%s' deleted", id))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Access list %s deleted successfully", id),
+	})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// DownloadAccessLog serves a proxy's access log as newline-delimited JSON, one Caddy access log
+// entry per line. Rolled-over files are left out.
+func (h *Handler) DownloadAccessLog(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	file, err := h.CaddyClient.AccessLogFile(id)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid proxy ID"})
+		return
+	}
+
+	log, err := os.Open(file)
+	if os.IsNotExist(err) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No access log available for this proxy"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to read access log: %v", err)})
+		return
+	}
+	defer log.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="access-%s.log"`, id))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, log); err != nil {
+		// The response is already under way
+		return
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/acmedns"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const acmeDNSRequestTimeout = 15 * time.Second
+
+// acmeDNSAccountResponse describes an acme-dns account and the CNAME the user must create
+type acmeDNSAccountResponse struct {
+	models.ACMEDNSAccount
+	CNAMEName       string `json:"cname_name"`
+	CNAMETarget     string `json:"cname_target"`
+	CNAMEConfigured bool   `json:"cname_configured"`
+}
+
+// GetACMEDNSAccounts lists the registered acme-dns accounts and whether each CNAME is in place
+func (h *Handler) GetACMEDNSAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts := h.CaddyClient.ACMEDNSAccounts()
+
+	response := make([]acmeDNSAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, newACMEDNSAccountResponse(r, account))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"accounts": response,
+		"count":    len(response),
+	})
+}
+
+// RegisterACMEDNSAccount registers an account on an acme-dns server for a domain. The response
+// holds the CNAME to create; proxies for the domain using the acmedns provider pick up the account
+// automatically.
+func (h *Handler) RegisterACMEDNSAccount(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Domain    string `json:"domain"`
+		ServerURL string `json:"server_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(req.Domain))
+	if domain == "" || strings.ContainsAny(domain, "/: ") {
+		http.Error(w, `{"error": "A valid domain is required"}`, http.StatusBadRequest)
+		return
+	}
+	if _, exists := h.CaddyClient.ACMEDNSAccount(domain); exists {
+		http.Error(w, fmt.Sprintf(`{"error": "An acme-dns account is already registered for %s, delete it first"}`, domain), http.StatusConflict)
+		return
+	}
+
+	serverURL := strings.TrimSuffix(strings.TrimSpace(req.ServerURL), "/")
+	if serverURL == "" {
+		serverURL = acmedns.DefaultServerURL
+	}
+	if u, err := url.Parse(serverURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		http.Error(w, `{"error": "server_url must be an http(s) URL"}`, http.StatusBadRequest)
+		return
+	}
+
+	client := &http.Client{Timeout: acmeDNSRequestTimeout}
+	if h.OutboundGuard != nil {
+		client.Transport = h.OutboundGuard.Transport()
+	}
+
+	registration, err := acmedns.Register(r.Context(), client, serverURL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Registration failed: %v"}`, err), http.StatusBadGateway)
+		return
+	}
+
+	account := models.ACMEDNSAccount{
+		Domain:       domain,
+		ServerURL:    serverURL,
+		Username:     registration.Username,
+		Password:     registration.Password,
+		Subdomain:    registration.Subdomain,
+		FullDomain:   registration.FullDomain,
+		RegisteredAt: time.Now().Format(time.RFC3339),
+	}
+	if err := h.CaddyClient.SaveACMEDNSAccount(account); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to save account: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "REGISTER_ACMEDNS", fmt.Sprintf("Registered acme-dns account for %s on %s", domain, serverURL))
+
+	account.Password = ""
+	writeJSON(w, http.StatusCreated, newACMEDNSAccountResponse(r, account))
+}
+
+// DeleteACMEDNSAccount forgets the acme-dns account registered for a domain
+func (h *Handler) DeleteACMEDNSAccount(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	if err := h.CaddyClient.DeleteACMEDNSAccount(domain); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	h.logAudit(r, "DELETE_ACMEDNS", fmt.Sprintf("Deleted acme-dns account for %s", domain))
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("acme-dns account for %s deleted", domain),
+	})
+}
+
+func newACMEDNSAccountResponse(r *http.Request, account models.ACMEDNSAccount) acmeDNSAccountResponse {
+	return acmeDNSAccountResponse{
+		ACMEDNSAccount:  account,
+		CNAMEName:       acmedns.ChallengeRecord(account.Domain),
+		CNAMETarget:     account.FullDomain,
+		CNAMEConfigured: acmedns.CNAMEConfigured(r.Context(), account.Domain, account.FullDomain),
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxAPITokenExpiryDays caps how far ahead an API token can expire
+const maxAPITokenExpiryDays = 3650
+
+type createAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ProxyIDs      []string `json:"proxy_ids"`       // Limits the token to these proxies
+	Tags          []string `json:"tags"`            // Limits the token to proxies with any of these tags
+	ExpiresInDays int      `json:"expires_in_days"` // 0 for a token that doesn't expire
+}
+
+type createAPITokenResponse struct {
+	Success  bool             `json:"success"`
+	Token    string           `json:"token"` // Only returned here, it can't be retrieved later
+	APIToken *models.APIToken `json:"api_token"`
+}
+
+// GetAPITokens lists the API tokens, without their values
+func (h *AuthHandler) GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !h.requireSession(w, r) {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"tokens":  h.storage.ListAPITokens(),
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// CreateAPIToken issues a long-lived token for scripts and CI pipelines
+func (h *AuthHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !h.requireSession(w, r) {
+		return
+	}
+	user := auth.GetUserFromContext(r.Context())
+
+	var req createAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		h.badRequest(w, "Token name is required")
+		return
+	}
+	if len(req.Scopes) == 0 {
+		h.badRequest(w, "At least one scope is required")
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			h.badRequest(w, fmt.Sprintf("Invalid scope %q, expected %q or %q", scope, auth.ScopeRead, auth.ScopeWrite))
+			return
+		}
+	}
+	var proxyIDs []string
+	for _, id := range req.ProxyIDs {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(proxyIDs, id) {
+			proxyIDs = append(proxyIDs, id)
+		}
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		h.badRequest(w, err.Error())
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPITokenExpiryDays {
+		h.badRequest(w, fmt.Sprintf("expires_in_days must be between 0 and %d", maxAPITokenExpiryDays))
+		return
+	}
+
+	var expires time.Time
+	if req.ExpiresInDays > 0 {
+		expires = time.Now().AddDate(0, 0, req.ExpiresInDays)
+	}
+
+	apiToken, value, err := h.storage.CreateAPIToken(user.ID, req.Name, req.Scopes, proxyIDs, tags, expires)
+	if err != nil {
+		h.internalError(w, "Failed to create API token")
+		return
+	}
+
+	details := fmt.Sprintf("Created API token %s (%s) with scopes %s", apiToken.Name, apiToken.Prefix, strings.Join(apiToken.Scopes, ","))
+	if len(apiToken.ProxyIDs) > 0 {
+		details += fmt.Sprintf(", proxies %s", strings.Join(apiToken.ProxyIDs, ","))
+	}
+	if len(apiToken.Tags) > 0 {
+		details += fmt.Sprintf(", tags %s", strings.Join(apiToken.Tags, ","))
+	}
+	h.logAudit(r, "API_TOKEN_CREATED", details)
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(createAPITokenResponse{
+		Success:  true,
+		Token:    value,
+		APIToken: apiToken,
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// DeleteAPIToken revokes an API token
+func (h *AuthHandler) DeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !h.requireSession(w, r) {
+		return
+	}
+
+	apiToken, err := h.storage.DeleteAPIToken(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(models.AuthResponse{
+			Success: false,
+			Message: "API token not found",
+		}); err != nil {
+			// Log error if needed, but response is already written
+		}
+		return
+	}
+
+	h.logAudit(r, "API_TOKEN_REVOKED", fmt.Sprintf("Revoked API token %s (%s)", apiToken.Name, apiToken.Prefix))
+
+	if err := json.NewEncoder(w).Encode(models.AuthResponse{
+		Success: true,
+		Message: "API token revoked",
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// requireSession rejects requests that aren't made from a logged in session, so an API token can't
+// be used to mint or revoke tokens
+func (h *AuthHandler) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if auth.GetAPITokenFromContext(r.Context()) != nil {
+		h.forbidden(w, "API tokens cannot manage API tokens")
+		return false
+	}
+	if auth.GetUserFromContext(r.Context()) == nil {
+		h.unauthorized(w, "Not authenticated")
+		return false
+	}
+	return true
+}
+
+// logAudit records an action by the authenticated user
+func (h *AuthHandler) logAudit(r *http.Request, action, details string) {
+	if h.auditService == nil {
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	username := "unknown"
+	userID := "unknown"
+	if user != nil {
+		username = user.Username
+		userID = user.ID
+	}
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.auditService.Log(action, details, userID, username, ipAddress)
+}
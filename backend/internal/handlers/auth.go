@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -17,14 +20,16 @@ const (
 )
 
 type AuthHandler struct {
-	storage      *auth.Storage
-	auditService *audit.Service
+	storage        *auth.Storage
+	auditService   *audit.Service
+	bootstrapToken string // required by the setup request; empty once setup is done
 }
 
-func NewAuthHandler(storage *auth.Storage, auditService *audit.Service) *AuthHandler {
+func NewAuthHandler(storage *auth.Storage, auditService *audit.Service, bootstrapToken string) *AuthHandler {
 	return &AuthHandler{
-		storage:      storage,
-		auditService: auditService,
+		storage:        storage,
+		auditService:   auditService,
+		bootstrapToken: bootstrapToken,
 	}
 }
 
@@ -62,6 +67,19 @@ func (h *AuthHandler) Setup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only whoever can read the server log (or set SETUP_TOKEN) may claim the admin account
+	if h.bootstrapToken == "" || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(req.BootstrapToken)), []byte(h.bootstrapToken)) != 1 {
+		if h.auditService != nil {
+			ipAddress := r.RemoteAddr
+			if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+				ipAddress = ip
+			}
+			h.auditService.Log("SETUP_FAILED", "Setup attempted with an invalid bootstrap token", "unknown", req.Username, ipAddress)
+		}
+		h.unauthorized(w, "Invalid bootstrap token")
+		return
+	}
+
 	// Validate input
 	if strings.TrimSpace(req.Username) == "" || strings.TrimSpace(req.Password) == "" {
 		h.badRequest(w, "Username and password are required")
@@ -74,7 +92,11 @@ func (h *AuthHandler) Setup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create user
-	user, err := h.storage.CreateUser(req.Username, req.Password)
+	user, err := h.storage.CreateInitialUser(req.Username, req.Password)
+	if errors.Is(err, auth.ErrAlreadySetup) {
+		h.badRequest(w, "System already setup")
+		return
+	}
 	if err != nil {
 		h.internalError(w, "Failed to create user: "+err.Error())
 		return
@@ -135,16 +157,30 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Get user
 	user, err := h.storage.GetUserByUsername(req.Username)
 	if err != nil {
+		h.logFailedLogin(r, "unknown", req.Username, "unknown user")
 		h.unauthorized(w, "Invalid credentials")
 		return
 	}
 
 	// Check password
 	if !auth.CheckPassword(req.Password, user.Password) {
+		h.logFailedLogin(r, user.ID, req.Username, "wrong password")
 		h.unauthorized(w, "Invalid credentials")
 		return
 	}
 
+	if user.Disabled {
+		h.forbidden(w, "Account is disabled")
+		return
+	}
+
+	// Move the hash to the current algorithm and parameters while the password is at hand
+	if auth.NeedsRehash(user.Password) {
+		if err := h.storage.UpgradePasswordHash(user.ID, req.Password); err != nil {
+			fmt.Printf("Warning: Failed to upgrade password hash for user %s: %v\n", user.ID, err)
+		}
+	}
+
 	// Create session
 	session, err := h.storage.CreateSession(user.ID)
 	if err != nil {
@@ -170,6 +206,19 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// logFailedLogin records a rejected login, which the security analysis counts per IP address
+func (h *AuthHandler) logFailedLogin(r *http.Request, userID, username, reason string) {
+	if h.auditService == nil {
+		return
+	}
+
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.auditService.Log("LOGIN_FAILED", "Login rejected: "+reason, userID, username, ipAddress)
+}
+
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -256,15 +305,11 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return user info (without password)
+	// Return user info (without password) and the saved searches the user can use
 	response := map[string]interface{}{
-		"success": true,
-		"user": map[string]interface{}{
-			"id":       user.ID,
-			"username": user.Username,
-			"created":  user.Created,
-			"updated":  user.Updated,
-		},
+		"success":        true,
+		"user":           publicUser(user),
+		"saved_searches": h.storage.ListSavedSearches(user.ID),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -272,6 +317,74 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ChangePassword sets a new password for the authenticated user after checking the current one.
+// The user's other sessions are ended and the caller gets a new session token.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if auth.GetAPITokenFromContext(r.Context()) != nil {
+		h.forbidden(w, "API tokens cannot change passwords")
+		return
+	}
+	current := auth.GetUserFromContext(r.Context())
+	if current == nil {
+		h.unauthorized(w, "Not authenticated")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.CurrentPassword == "" || strings.TrimSpace(req.NewPassword) == "" {
+		h.badRequest(w, "Current and new password are required")
+		return
+	}
+	if len(req.NewPassword) < 6 {
+		h.badRequest(w, "Password must be at least 6 characters")
+		return
+	}
+
+	user, err := h.storage.GetUserByID(current.ID)
+	if err != nil {
+		h.unauthorized(w, "Not authenticated")
+		return
+	}
+	if !auth.CheckPassword(req.CurrentPassword, user.Password) {
+		h.logAudit(r, "CHANGE_PASSWORD_FAILED", "Password change rejected: current password is incorrect")
+		h.unauthorized(w, "Current password is incorrect")
+		return
+	}
+	if auth.CheckPassword(req.NewPassword, user.Password) {
+		h.badRequest(w, "New password must differ from the current password")
+		return
+	}
+
+	// Changing the password invalidates every existing session, including the caller's
+	if _, err := h.storage.UpdateUser(user.ID, auth.UserUpdate{Password: &req.NewPassword}); err != nil {
+		h.internalError(w, "Failed to change password: "+err.Error())
+		return
+	}
+
+	session, err := h.storage.CreateSession(user.ID)
+	if err != nil {
+		h.internalError(w, "Password changed but failed to create session: "+err.Error())
+		return
+	}
+
+	h.logAudit(r, "CHANGE_PASSWORD", "User changed their password, other sessions were ended")
+
+	if err := json.NewEncoder(w).Encode(models.AuthResponse{
+		Success: true,
+		Message: "Password changed successfully",
+		Token:   session.Token,
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
 func (h *AuthHandler) badRequest(w http.ResponseWriter, message string) {
 	w.WriteHeader(http.StatusBadRequest)
 	if err := json.NewEncoder(w).Encode(models.AuthResponse{
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/backup"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/health"
+)
+
+// maxRestoreBytes caps the size of an uploaded backup archive
+const maxRestoreBytes = 50 << 20
+
+type BackupHandler struct {
+	dataDir       string
+	caddyClient   *caddy.Client
+	authStorage   *auth.Storage
+	healthService *health.Service
+	auditService  *audit.Service
+	reloadSecrets func() // Reloads the key encrypting secrets after a restore replaced it
+
+	// StateStore is where users, proxy metadata and the audit log are kept. Archives only cover the
+	// data directory, so backups are refused for any other store.
+	StateStore string
+	// Remote uploads encrypted archives to S3 or WebDAV, nil when no target is configured
+	Remote *backup.Remote
+}
+
+func NewBackupHandler(dataDir string, caddyClient *caddy.Client, authStorage *auth.Storage, healthService *health.Service, auditService *audit.Service, reloadSecrets func()) *BackupHandler {
+	return &BackupHandler{
+		dataDir:       dataDir,
+		caddyClient:   caddyClient,
+		authStorage:   authStorage,
+		healthService: healthService,
+		auditService:  auditService,
+		reloadSecrets: reloadSecrets,
+	}
+}
+
+// GetBackup downloads the manager's state as a gzipped tar archive. It includes the secrets key,
+// so the archive must be stored as carefully as the data directory itself.
+func (h *BackupHandler) GetBackup(w http.ResponseWriter, r *http.Request) {
+	if h.refuseExternalStore(w) {
+		return
+	}
+
+	var archive bytes.Buffer
+	if err := backup.Write(&archive, h.dataDir); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to create backup: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "CREATE_BACKUP", fmt.Sprintf("Backup downloaded (%d bytes)", archive.Len()))
+
+	filename := fmt.Sprintf("caddyproxymanager-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive.Bytes()); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
+
+// refuseExternalStore rejects the request when the state lives outside the data directory, where
+// an archive would silently miss it
+func (h *BackupHandler) refuseExternalStore(w http.ResponseWriter) bool {
+	if h.StateStore == "" || h.StateStore == "file" {
+		return false
+	}
+
+	http.Error(w, fmt.Sprintf(`{"error": "Backups only cover the data directory, but users, metadata and the audit log are kept in %s; back up the database instead"}`, h.StateStore), http.StatusConflict)
+	return true
+}
+
+// Restore replaces the manager's state with an archive from GetBackup, sent as the request body.
+// The archive is validated first, and the previous state is put back if Caddy rejects the
+// restored config.
+func (h *BackupHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if h.refuseExternalStore(w) {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRestoreBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid backup: %v", err)})
+		return
+	}
+	// Encrypted archives open with the passphrase sent along, or the one remote backups use
+	passphrase := r.Header.Get("X-Backup-Passphrase")
+	if passphrase == "" && h.Remote != nil {
+		passphrase = h.Remote.Passphrase()
+	}
+	archive, err := backup.Open(data, passphrase)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid backup: %v", err)})
+		return
+	}
+	h.restore(w, r, archive, "uploaded")
+}
+
+// restore validates an opened archive and swaps it in, putting the previous state back if Caddy
+// rejects the restored config. source describes where the archive came from in the audit log.
+func (h *BackupHandler) restore(w http.ResponseWriter, r *http.Request, archive *backup.Archive, source string) {
+	if err := archive.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid backup: %v", err)})
+		return
+	}
+
+	restore, err := archive.Apply(h.dataDir)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to restore backup: %v", err)})
+		return
+	}
+
+	if err := h.load(); err != nil {
+		if rollbackErr := restore.Rollback(); rollbackErr != nil {
+			fmt.Printf("Warning: Failed to roll back restore: %v\n", rollbackErr)
+		}
+		if err := h.load(); err != nil {
+			fmt.Printf("Warning: Failed to reload previous state after rollback: %v\n", err)
+		}
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to load the restored state, the previous state was put back: %v", err)})
+		return
+	}
+	restore.Commit()
+
+	if err := h.caddyClient.ApplyPages(); err != nil {
+		fmt.Printf("Warning: Failed to apply page templates: %v\n", err)
+	}
+	proxies := h.restartHealthChecks()
+
+	h.logAudit(r, "RESTORE_BACKUP", fmt.Sprintf("Backup from %s (%s) restored with %d proxies", archive.Manifest.Created.Format(time.RFC3339), source, proxies))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":     "Backup restored",
+		"created":     archive.Manifest.Created,
+		"files":       len(archive.Files),
+		"proxies":     proxies,
+		"secrets_key": archive.HasSecretsKey(),
+	})
+}
+
+// remoteConfigured rejects the request when no remote backup target is configured
+func (h *BackupHandler) remoteConfigured(w http.ResponseWriter) bool {
+	if h.Remote != nil {
+		return true
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "Remote backups are not configured"})
+	return false
+}
+
+// GetRemoteBackups lists the archives on the remote backup target, newest first
+func (h *BackupHandler) GetRemoteBackups(w http.ResponseWriter, r *http.Request) {
+	if !h.remoteConfigured(w) {
+		return
+	}
+
+	archives, err := h.Remote.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to list remote backups: %v", err)})
+		return
+	}
+	if archives == nil {
+		archives = []backup.RemoteArchive{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"target":   h.Remote.Kind(),
+		"interval": h.Remote.Interval().String(),
+		"keep":     h.Remote.Keep(),
+		"backups":  archives,
+	})
+}
+
+// CreateRemoteBackup uploads an encrypted archive to the remote backup target right away
+func (h *BackupHandler) CreateRemoteBackup(w http.ResponseWriter, r *http.Request) {
+	if h.refuseExternalStore(w) || !h.remoteConfigured(w) {
+		return
+	}
+
+	name, err := h.Remote.Upload(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.logAudit(r, "CREATE_BACKUP", fmt.Sprintf("Backup %s uploaded to %s", name, h.Remote.Kind()))
+	writeJSON(w, http.StatusCreated, map[string]string{"name": name})
+}
+
+// RestoreRemoteBackup restores an archive from the remote backup target
+func (h *BackupHandler) RestoreRemoteBackup(w http.ResponseWriter, r *http.Request) {
+	if h.refuseExternalStore(w) || !h.remoteConfigured(w) {
+		return
+	}
+
+	name := r.PathValue("name")
+	archive, err := h.Remote.Download(r.Context(), name)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to fetch backup: %v", err)})
+		return
+	}
+	h.restore(w, r, archive, name+" from "+h.Remote.Kind())
+}
+
+// load reads the state in the data directory into memory and pushes the config to Caddy
+func (h *BackupHandler) load() error {
+	if h.reloadSecrets != nil {
+		h.reloadSecrets()
+	}
+	if err := h.caddyClient.ReloadMetadata(); err != nil {
+		return err
+	}
+	if err := h.authStorage.Reload(); err != nil {
+		return err
+	}
+	return h.caddyClient.RestoreConfigFromFile()
+}
+
+// restartHealthChecks replaces the running health checks with those of the restored proxies,
+// returning the number of restored proxies
+func (h *BackupHandler) restartHealthChecks() int {
+	for _, proxy := range h.healthService.CheckedProxies() {
+		h.healthService.StopHealthCheck(proxy.ID)
+	}
+
+	config, err := h.caddyClient.GetConfig()
+	if err != nil {
+		fmt.Printf("Warning: Failed to get restored config: %v\n", err)
+		return 0
+	}
+
+	proxies := h.caddyClient.ParseProxiesFromConfig(config)
+	for _, proxy := range proxies {
+		if !proxy.HealthCheckEnabled {
+			continue
+		}
+		if err := h.healthService.StartHealthCheck(proxy); err != nil {
+			fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+		}
+	}
+	return len(proxies)
+}
+
+func (h *BackupHandler) logAudit(r *http.Request, action, details string) {
+	if h.auditService == nil {
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	username := "unknown"
+	userID := "unknown"
+	if user != nil {
+		username = user.Username
+		userID = user.ID
+	}
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.auditService.Log(action, details, userID, username, ipAddress)
+}
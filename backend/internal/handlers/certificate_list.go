@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/certs"
+)
+
+type CertificateHandler struct {
+	service *certs.Service
+}
+
+func NewCertificateHandler(service *certs.Service) *CertificateHandler {
+	return &CertificateHandler{
+		service: service,
+	}
+}
+
+// GetCertificates lists the certificates in Caddy's storage and managed domains still without one
+func (h *CertificateHandler) GetCertificates(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to list certificates: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificates": list,
+		"count":        len(list),
+		"storage_dir":  h.service.StorageDir(),
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	maxPreprovisionDomains      = 100
+	defaultPreprovisionWait     = 30 * time.Second
+	maxPreprovisionWait         = 120 * time.Second
+	preprovisionPollingInterval = 2 * time.Second
+	preprovisionWriteMargin     = 30 * time.Second // Time to update Caddy and write the response
+)
+
+// PreprovisionCertificates triggers certificate issuance for domains ahead of a migration cutover
+// and waits briefly to report which certificates were issued
+func (h *Handler) PreprovisionCertificates(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Domains        []string          `json:"domains"`
+		ChallengeType  string            `json:"challenge_type"`
+		DNSProvider    string            `json:"dns_provider"`
+		DNSCredentials map[string]string `json:"dns_credentials"`
+		WaitSeconds    *int              `json:"wait_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	var domains []string
+	for _, domain := range req.Domains {
+		if strings.TrimSpace(domain) == "" {
+			continue
+		}
+		domain, err := hostname.Normalize(domain, false)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 || len(domains) > maxPreprovisionDomains {
+		http.Error(w, fmt.Sprintf(`{"error": "Between 1 and %d domains are required"}`, maxPreprovisionDomains), http.StatusBadRequest)
+		return
+	}
+
+	if req.ChallengeType == "" {
+		req.ChallengeType = "http"
+	}
+	switch req.ChallengeType {
+	case "http":
+	case "dns":
+		if req.DNSProvider == "" {
+			http.Error(w, `{"error": "DNS provider is required for DNS challenge"}`, http.StatusBadRequest)
+			return
+		}
+		if err := h.validateDNSCredentials(req.DNSProvider, req.DNSCredentials); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf(`{"error": "Unsupported challenge type: %s"}`, req.ChallengeType), http.StatusBadRequest)
+		return
+	}
+
+	wait := defaultPreprovisionWait
+	if req.WaitSeconds != nil {
+		wait = time.Duration(*req.WaitSeconds) * time.Second
+	}
+	if wait < 0 || wait > maxPreprovisionWait {
+		http.Error(w, fmt.Sprintf(`{"error": "wait_seconds must be between 0 and %d"}`, int(maxPreprovisionWait.Seconds())), http.StatusBadRequest)
+		return
+	}
+
+	// The server's write timeout would otherwise cut off the response of longer waits
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + preprovisionWriteMargin))
+
+	challenge := models.Proxy{
+		ChallengeType:  req.ChallengeType,
+		DNSProvider:    req.DNSProvider,
+		DNSCredentials: req.DNSCredentials,
+	}
+	if err := h.CaddyClient.PreprovisionCertificates(domains, challenge); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to update Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "PREPROVISION_CERTIFICATES", fmt.Sprintf("Certificate pre-provisioning requested for %s via %s challenge", strings.Join(domains, ", "), req.ChallengeType))
+
+	// Poll until every certificate is issued, the wait runs out, or the client goes away
+	deadline := time.Now().Add(wait)
+	statuses := h.certificateStatuses(domains)
+	for !allCertificatesIssued(statuses) && time.Now().Add(preprovisionPollingInterval).Before(deadline) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(preprovisionPollingInterval):
+		}
+		statuses = h.certificateStatuses(domains)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"all_issued": allCertificatesIssued(statuses),
+		"domains":    statuses,
+	})
+}
+
+// GetPreprovisionedCertificates reports the certificate status of every pre-provisioned domain
+func (h *Handler) GetPreprovisionedCertificates(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	statuses := h.certificateStatuses(h.CaddyClient.PreprovisionedDomains(config))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"all_issued": allCertificatesIssued(statuses),
+		"domains":    statuses,
+	})
+}
+
+// DeletePreprovisionedCertificate stops Caddy from managing a pre-provisioned domain's certificate
+func (h *Handler) DeletePreprovisionedCertificate(w http.ResponseWriter, r *http.Request) {
+	domain := strings.ToLower(r.PathValue("domain"))
+	if domain == "" {
+		http.Error(w, `{"error": "Domain is required"}`, http.StatusBadRequest)
+		return
+	}
+	// Accept the Unicode form of an internationalized domain too
+	if normalized, err := hostname.Normalize(domain, false); err == nil {
+		domain = normalized
+	}
+
+	if err := h.CaddyClient.RemovePreprovisionedDomain(domain); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	h.logAudit(r, "DELETE_PREPROVISIONED_CERTIFICATE", fmt.Sprintf("Stopped pre-provisioning certificate for %s", domain))
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Certificate for %s is no longer pre-provisioned", domain),
+	})
+}
+
+func (h *Handler) certificateStatuses(domains []string) []caddy.CertificateStatus {
+	statuses := make([]caddy.CertificateStatus, 0, len(domains))
+	for _, domain := range domains {
+		statuses = append(statuses, h.CaddyClient.CertificateStatus(domain))
+	}
+	return statuses
+}
+
+func allCertificatesIssued(statuses []caddy.CertificateStatus) bool {
+	return !slices.ContainsFunc(statuses, func(status caddy.CertificateStatus) bool {
+		return status.Status != caddy.CertificateIssued
+	})
+}
+
+// GetCertificateReport lists every managed certificate with its days to expiry and any renewal problems
+func (h *Handler) GetCertificateReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.CaddyClient.CertificateReport()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificates": report,
+		"summary":      caddy.SummarizeCertificateReport(report),
+		"generated_at": time.Now().Format(time.RFC3339),
+	})
+}
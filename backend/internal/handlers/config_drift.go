@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+// GetConfigDrift reports whether Caddy's config was changed outside the manager
+func (h *Handler) GetConfigDrift(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ConfigWatcher.State())
+}
+
+// GetForeignChanges lists managed routes another tool changed or removed in Caddy, which block
+// writes until they're resolved or overwritten with force=true
+func (h *Handler) GetForeignChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.CaddyClient.ForeignChanges()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to check for foreign changes: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []caddy.ForeignChange{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"foreign_changes": changes,
+		"count":           len(changes),
+	})
+}
+
+// ResolveConfigDrift either adopts Caddy's running config as the managed config or restores the
+// managed config to Caddy
+func (h *Handler) ResolveConfigDrift(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action string `json:"action"` // "adopt" or "restore"
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	switch req.Action {
+	case "adopt":
+		if err := h.ConfigWatcher.Adopt(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to adopt Caddy config: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, "ADOPT_CADDY_CONFIG", "Caddy's running config adopted as the managed config")
+	case "restore":
+		if err := h.ConfigWatcher.Restore(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to restore managed config: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		h.logAudit(r, "RESTORE_CADDY_CONFIG", "Managed config restored to Caddy, discarding outside changes")
+	default:
+		http.Error(w, `{"error": "Action must be adopt or restore"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Don't make clients wait for the next poll to see the restored upstreams
+	h.StatusPoller.Refresh()
+
+	writeJSON(w, http.StatusOK, h.ConfigWatcher.State())
+}
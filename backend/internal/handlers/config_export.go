@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/redact"
+)
+
+// ExportConfig returns the proxies, redirects and Caddy's running config with every secret
+// masked, so the export can be attached to a support request or shared publicly
+func (h *Handler) ExportConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	masked, err := redact.JSON(map[string]any{
+		"exported_at":  now.Format(time.RFC3339),
+		"masked":       true,
+		"proxies":      h.CaddyClient.ParseProxiesFromConfig(config),
+		"redirects":    h.CaddyClient.ParseRedirectsFromConfig(config),
+		"caddy_config": config,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to mask config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "EXPORT_CONFIG", "Configuration exported with secrets masked")
+
+	filename := fmt.Sprintf("caddyproxymanager-config-%s.json", now.Format("20060102-150405"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	writeJSON(w, http.StatusOK, masked)
+}
+
+// ExportCaddyfile returns the managed proxies and redirects as a Caddyfile, for users leaving the
+// manager or reviewing their config in familiar syntax
+func (h *Handler) ExportCaddyfile(w http.ResponseWriter, r *http.Request) {
+	caddyfile, err := h.CaddyClient.Caddyfile()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to generate Caddyfile: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "EXPORT_CADDYFILE", "Configuration exported as a Caddyfile")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="Caddyfile"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(caddyfile)); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
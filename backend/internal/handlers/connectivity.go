@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+// Verdicts of a connectivity test, comparing what the manager and Caddy can reach
+const (
+	connectivityReachable    = "reachable"            // both reach the target
+	connectivityCaddyCut     = "caddy_cannot_reach"   // only the manager reaches it: Caddy's network is the problem
+	connectivityManagerCut   = "manager_cannot_reach" // only Caddy reaches it, which is all traffic needs
+	connectivityUnreachable  = "unreachable"          // neither reaches it: the upstream is down or the address is wrong
+	connectivityInconclusive = "inconclusive"         // Caddy couldn't be asked
+)
+
+// targetConnectivity is the connectivity test result of one target
+type targetConnectivity struct {
+	Target  string               `json:"target"`
+	Dial    string               `json:"dial"`
+	Manager managerReachability  `json:"manager"`
+	Caddy   *caddy.UpstreamProbe `json:"caddy,omitempty"`
+	Verdict string               `json:"verdict"`
+	Message string               `json:"message"`
+}
+
+// managerReachability is whether the manager could open a connection to a target
+type managerReachability struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TestProxyConnectivity checks every target of a proxy from both the manager and Caddy, telling a
+// target that is down apart from one only Caddy can't reach, e.g. because it runs in another Docker
+// network or network namespace. The usual cause of Bad Gateway responses for targets that look fine.
+func (h *Handler) TestProxyConnectivity(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+		return
+	}
+	if proxy.UpstreamType == caddy.UpstreamTypeFastCGI {
+		http.Error(w, `{"error": "Connectivity tests don't support FastCGI upstreams, use a tcp health check instead"}`, http.StatusBadRequest)
+		return
+	}
+
+	resolved, err := caddy.ResolveTemplates(*proxy)
+	if err != nil {
+		writeRequestError(w, fmt.Errorf("Failed to resolve target templates: %w", err))
+		return
+	}
+	targets := caddy.ProxyTargets(resolved)
+	if resolved.BackupTargetURL != "" {
+		targets = append(targets, resolved.BackupTargetURL)
+	}
+
+	results := make([]targetConnectivity, 0, len(targets))
+	for _, target := range targets {
+		results = append(results, h.testTargetConnectivity(r.Context(), target))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"proxy_id": proxy.ID,
+		"targets":  results,
+	})
+}
+
+// testTargetConnectivity dials a target from the manager, has Caddy request it and compares both
+func (h *Handler) testTargetConnectivity(ctx context.Context, target string) targetConnectivity {
+	result := targetConnectivity{Target: target}
+
+	dialAddr, err := caddy.TargetDialAddress(target)
+	if err != nil {
+		result.Verdict = connectivityInconclusive
+		result.Message = fmt.Sprintf("Invalid target: %v", err)
+		return result
+	}
+	result.Dial = dialAddr
+	result.Manager = h.dialFromManager(ctx, dialAddr)
+
+	probe, err := h.CaddyClient.ProbeUpstream(ctx, target)
+	if err != nil {
+		result.Verdict = connectivityInconclusive
+		result.Message = fmt.Sprintf("Couldn't test from Caddy: %v", err)
+		return result
+	}
+	result.Caddy = probe
+
+	switch {
+	case probe.Reachable && result.Manager.Reachable:
+		result.Verdict = connectivityReachable
+		result.Message = fmt.Sprintf("Caddy reached %s, which answered with status %d", dialAddr, probe.Status)
+	case probe.Reachable:
+		result.Verdict = connectivityManagerCut
+		result.Message = fmt.Sprintf("Caddy reached %s but the manager can't; proxying works, but the manager's own health checks of it will fail", dialAddr)
+	case result.Manager.Reachable:
+		result.Verdict = connectivityCaddyCut
+		result.Message = fmt.Sprintf("The manager reaches %s but Caddy can't; make sure Caddy shares the target's network, e.g. the same Docker network, and that the address means the same from inside Caddy's container (localhost there is Caddy itself)", dialAddr)
+	default:
+		result.Verdict = connectivityUnreachable
+		result.Message = fmt.Sprintf("Neither the manager nor Caddy reach %s; check that the upstream is running and listening on that address", dialAddr)
+	}
+	return result
+}
+
+// dialFromManager opens and closes a TCP connection to address, through the outbound guard
+func (h *Handler) dialFromManager(ctx context.Context, address string) managerReachability {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if h.OutboundGuard != nil {
+		conn, err = h.OutboundGuard.DialContext(ctx, "tcp", address)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return managerReachability{Error: err.Error()}
+	}
+	conn.Close()
+	return managerReachability{Reachable: true}
+}
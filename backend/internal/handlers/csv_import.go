@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/events"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxCSVImportBytes caps the size of an uploaded CSV file
+const maxCSVImportBytes = 5 << 20
+
+// CSV import types, chosen with the type query parameter
+const (
+	CSVImportProxies   = "proxies"
+	CSVImportRedirects = "redirects"
+)
+
+// csvImportRow is the validation, and when not a dry run, import result of one CSV row
+type csvImportRow struct {
+	Row      int      `json:"row"` // Line number in the file, the header is row 1
+	Domain   string   `json:"domain"`
+	Valid    bool     `json:"valid"`
+	Imported bool     `json:"imported"`
+	ID       string   `json:"id,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Code     string   `json:"code,omitempty"` // Set for domain validation errors
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// csvImport is a validated row waiting to be applied
+type csvImport struct {
+	result   *csvImportRow
+	proxy    *models.Proxy
+	redirect *models.Redirect
+}
+
+// ImportCSV bulk creates proxies or redirects from a CSV file with a header row. Proxies use the
+// columns domain, target, ssl_mode, challenge_type, dns_provider, health_check_path, allowed_ips,
+// grpc, tags and notes; redirects use source, destination, code, preserve_path, tags and notes.
+// Only domain and target, or source and destination, are required, and list columns are separated
+// with ";". Nothing is imported unless every row is valid, and dry_run=true only returns the
+// validation results.
+func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+	importType := r.URL.Query().Get("type")
+	if importType == "" {
+		importType = CSVImportProxies
+	}
+	if importType != CSVImportProxies && importType != CSVImportRedirects {
+		http.Error(w, fmt.Sprintf(`{"error": "type must be %s or %s"}`, CSVImportProxies, CSVImportRedirects), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	header, records, err := readImportCSV(http.MaxBytesReader(w, r.Body, maxCSVImportBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid CSV: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Hosts already served by a proxy or redirect, including earlier rows of the file
+	taken := make(map[string]string)
+	for _, proxy := range h.CaddyClient.ParseProxiesFromConfig(config) {
+		taken[strings.ToLower(hostWithoutPort(proxy.Domain))] = "proxy " + proxy.ID
+	}
+	for _, redirect := range h.CaddyClient.ParseRedirectsFromConfig(config) {
+		for _, source := range redirect.SourceDomains {
+			taken[strings.ToLower(source)] = "redirect " + redirect.ID
+		}
+	}
+
+	var imports []csvImport
+	for i, record := range records {
+		row := importRow(header, record)
+		item := csvImport{result: &csvImportRow{Row: i + 2}}
+
+		var hosts []string
+		var err error
+		if importType == CSVImportProxies {
+			item.result.Domain = row["domain"]
+			item.proxy, err = h.csvProxy(row)
+			if err == nil {
+				hosts = []string{item.proxy.Domain}
+			}
+		} else {
+			item.result.Domain = row["source"]
+			item.redirect, item.result.Warnings, err = h.csvRedirect(row)
+			if err == nil {
+				hosts = item.redirect.SourceDomains
+			}
+		}
+
+		for _, host := range hosts {
+			host = strings.ToLower(hostWithoutPort(host))
+			if owner, exists := taken[host]; exists && err == nil {
+				err = fmt.Errorf("%s is already used by %s", host, owner)
+			}
+		}
+		for _, host := range hosts {
+			taken[strings.ToLower(hostWithoutPort(host))] = fmt.Sprintf("row %d", item.result.Row)
+		}
+
+		if err != nil {
+			item.result.Error = err.Error()
+			var domainErr *hostname.Error
+			if errors.As(err, &domainErr) {
+				item.result.Code = domainErr.Code
+			}
+		} else {
+			item.result.Valid = true
+		}
+		imports = append(imports, item)
+	}
+
+	results := make([]*csvImportRow, 0, len(imports))
+	invalid := 0
+	for _, item := range imports {
+		results = append(results, item.result)
+		if !item.result.Valid {
+			invalid++
+		}
+	}
+
+	if dryRun || invalid > 0 {
+		status := http.StatusOK
+		if !dryRun {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, map[string]any{
+			"dry_run": dryRun,
+			"type":    importType,
+			"valid":   invalid == 0,
+			"invalid": invalid,
+			"results": results,
+		})
+		return
+	}
+
+	client := h.caddyClientFor(r)
+	imported := 0
+	for _, item := range imports {
+		if item.proxy != nil {
+			item.proxy.ID = h.CaddyClient.NewProxyID(item.proxy.Domain)
+			item.proxy.MarkApplied(requestUsername(r))
+			err = client.AddProxy(*item.proxy)
+			if err == nil {
+				h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionCreated, ProxyID: item.proxy.ID, Domain: item.proxy.Domain})
+			}
+			if err == nil && item.proxy.HealthCheckEnabled {
+				if err := h.HealthService.StartHealthCheck(*item.proxy); err != nil {
+					fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", item.proxy.ID, err)
+				}
+			}
+			item.result.ID = item.proxy.ID
+		} else {
+			item.redirect.ID = h.CaddyClient.NewRedirectID(item.redirect.SourceDomains)
+			item.redirect.MarkApplied(requestUsername(r))
+			err = client.AddRedirect(*item.redirect)
+			item.result.ID = item.redirect.ID
+		}
+
+		if err != nil {
+			item.result.ID = ""
+			item.result.Error = fmt.Sprintf("Failed to add to Caddy: %v", err)
+			continue
+		}
+		item.result.Imported = true
+		imported++
+	}
+
+	h.logAudit(r, "IMPORT_CSV", fmt.Sprintf("Imported %d of %d %s from CSV", imported, len(imports), importType))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dry_run":  false,
+		"type":     importType,
+		"valid":    true,
+		"imported": imported,
+		"results":  results,
+	})
+}
+
+// csvProxy builds a proxy from a CSV row with the same validation as the proxy API
+func (h *Handler) csvProxy(row map[string]string) (*models.Proxy, error) {
+	proxyReq := proxyRequest{
+		Domain:          row["domain"],
+		TargetURL:       row["target"],
+		SSLMode:         row["ssl_mode"],
+		ChallengeType:   row["challenge_type"],
+		DNSProvider:     row["dns_provider"],
+		HealthCheckPath: row["health_check_path"],
+		AllowedIPs:      splitCSVList(row["allowed_ips"]),
+		Tags:            splitCSVList(row["tags"]),
+		Notes:           row["notes"],
+	}
+	proxyReq.HealthCheckEnabled = proxyReq.HealthCheckPath != ""
+
+	if value := row["grpc"]; value != "" {
+		grpc, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("grpc must be true or false")
+		}
+		proxyReq.GRPC = grpc
+	}
+
+	return h.buildProxy(proxyReq)
+}
+
+// csvRedirect builds a redirect from a CSV row, returning redirect chain warnings
+func (h *Handler) csvRedirect(row map[string]string) (*models.Redirect, []string, error) {
+	sources := splitCSVList(row["source"])
+	if len(sources) == 0 || row["destination"] == "" {
+		return nil, nil, fmt.Errorf("source and destination are required")
+	}
+
+	code := 301
+	if value := row["code"]; value != "" {
+		var err error
+		if code, err = strconv.Atoi(value); err != nil || (code != 301 && code != 302) {
+			return nil, nil, fmt.Errorf("code must be 301 or 302")
+		}
+	}
+
+	preservePath := false
+	if value := row["preserve_path"]; value != "" {
+		var err error
+		if preservePath, err = strconv.ParseBool(value); err != nil {
+			return nil, nil, fmt.Errorf("preserve_path must be true or false")
+		}
+	}
+
+	sources, err := hostname.NormalizeAll(sources, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redirect := models.NewRedirect(sources, row["destination"], code, preservePath)
+	if redirect.Tags, err = normalizeTags(splitCSVList(row["tags"])); err != nil {
+		return nil, nil, err
+	}
+	if redirect.Notes, err = normalizeNotes(row["notes"]); err != nil {
+		return nil, nil, err
+	}
+
+	warnings, err := h.checkRedirectChain(*redirect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return redirect, warnings, nil
+}
+
+// readImportCSV reads a CSV file, returning its lower-cased header and the remaining records
+func readImportCSV(body io.Reader) ([]string, [][]string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1 // Trailing empty columns are often dropped by spreadsheets
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	}
+	if !slices.Contains(header, "domain") && !slices.Contains(header, "source") {
+		return nil, nil, fmt.Errorf("header row must name the columns, e.g. domain,target or source,destination")
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, records, nil
+}
+
+// importRow maps a record's values to their column names
+func importRow(header, record []string) map[string]string {
+	row := make(map[string]string, len(header))
+	for i, column := range header {
+		if i < len(record) {
+			row[column] = strings.TrimSpace(record[i])
+		}
+	}
+	return row
+}
+
+// splitCSVList splits a ";" separated cell into its non-empty values
+func splitCSVList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+const maxCertificateUploadBytes = 1 << 20
+
+// GetCustomCertificate describes the certificate uploaded for a proxy
+func (h *Handler) GetCustomCertificate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.CaddyClient.GetProxy(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	info, err := h.CaddyClient.CustomCertificate(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, `{"error": "No certificate uploaded for this proxy"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to read certificate: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// UploadCustomCertificate stores a PEM certificate chain and key for a proxy and loads them into
+// Caddy when the proxy uses the custom SSL mode
+func (h *Handler) UploadCustomCertificate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Certificate string `json:"certificate"` // PEM chain, leaf first
+		Key         string `json:"key"`         // PEM private key
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCertificateUploadBytes)).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Certificate == "" || req.Key == "" {
+		http.Error(w, `{"error": "certificate and key are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.CaddyClient.SaveCustomCertificate(*proxy, []byte(req.Certificate), []byte(req.Key))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	applied := proxy.SSLMode == SSLModeCustom
+	if applied {
+		proxy.MarkApplied(requestUsername(r))
+		if err := h.CaddyClient.UpdateProxy(*proxy); err != nil {
+			writeCaddyError(w, "Failed to update proxy in Caddy", err)
+			return
+		}
+	}
+
+	h.logAudit(r, "UPLOAD_CERTIFICATE", fmt.Sprintf("Uploaded certificate for proxy '%s' (%s), valid until %s", proxy.ID, proxy.Domain, info.NotAfter))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"certificate": info,
+		"applied":     applied, // false until the proxy's ssl_mode is "custom"
+	})
+}
+
+// DeleteCustomCertificate removes the certificate uploaded for a proxy
+func (h *Handler) DeleteCustomCertificate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	if err := h.CaddyClient.DeleteCustomCertificate(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete certificate: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Unload it from Caddy; the domain has no certificate until a new one is uploaded
+	if proxy.SSLMode == SSLModeCustom {
+		proxy.MarkApplied(requestUsername(r))
+		if err := h.CaddyClient.UpdateProxy(*proxy); err != nil {
+			writeCaddyError(w, "Failed to update proxy in Caddy", err)
+			return
+		}
+	}
+
+	h.logAudit(r, "DELETE_CERTIFICATE", fmt.Sprintf("Deleted uploaded certificate for proxy '%s' (%s)", proxy.ID, proxy.Domain))
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Certificate deleted",
+	})
+}
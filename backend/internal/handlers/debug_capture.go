@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+// debugCaptureResponse describes a proxy's debug capture state
+type debugCaptureResponse struct {
+	Active      bool   `json:"active"`
+	StartedAt   string `json:"started_at,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func (h *Handler) debugCaptureStatus(id string) debugCaptureResponse {
+	var response debugCaptureResponse
+	if capture, exists := h.CaddyClient.GetDebugCapture(id); exists {
+		response.Active = true
+		response.StartedAt = capture.StartedAt
+		response.ExpiresAt = capture.ExpiresAt
+	}
+
+	if file, err := h.CaddyClient.DebugCaptureFile(id); err == nil {
+		if _, err := os.Stat(file); err == nil {
+			response.DownloadURL = fmt.Sprintf("/api/proxies/%s/debug-capture/download", id)
+		}
+	}
+
+	return response
+}
+
+// GetDebugCapture returns whether a debug capture is running for a proxy and whether a capture file exists
+func (h *Handler) GetDebugCapture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.debugCaptureStatus(id))
+}
+
+// StartDebugCapture records request/response metadata for a single proxy for a limited time.
+// The capture disables itself once the duration has elapsed.
+func (h *Handler) StartDebugCapture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		DurationMinutes int `json:"duration_minutes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	duration := caddy.DefaultDebugCaptureDuration
+	if req.DurationMinutes != 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	if duration <= 0 || duration > caddy.MaxDebugCaptureDuration {
+		http.Error(w, fmt.Sprintf(`{"error": "duration_minutes must be between 1 and %d"}`, int(caddy.MaxDebugCaptureDuration.Minutes())), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.CaddyClient.GetProxy(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	if _, err := h.CaddyClient.StartDebugCapture(id, duration); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to start debug capture: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "START_DEBUG_CAPTURE", fmt.Sprintf("Debug capture started for proxy '%s' for %s", id, duration))
+
+	writeJSON(w, http.StatusCreated, h.debugCaptureStatus(id))
+}
+
+// StopDebugCapture ends a running debug capture early. The capture file is kept for download.
+func (h *Handler) StopDebugCapture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, exists := h.CaddyClient.GetDebugCapture(id); !exists {
+		http.Error(w, `{"error": "No debug capture running for this proxy"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := h.CaddyClient.StopDebugCapture(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to stop debug capture: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "STOP_DEBUG_CAPTURE", fmt.Sprintf("Debug capture stopped for proxy '%s'", id))
+
+	writeJSON(w, http.StatusOK, h.debugCaptureStatus(id))
+}
+
+// DownloadDebugCapture serves the captured access log for a proxy as newline-delimited JSON
+func (h *Handler) DownloadDebugCapture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	file, err := h.CaddyClient.DebugCaptureFile(id)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		http.Error(w, `{"error": "No debug capture available for this proxy"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to read debug capture: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="debug-capture-%s.log"`, id))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
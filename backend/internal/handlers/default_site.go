@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// GetDefaultSite returns how Caddy answers requests for hostnames no proxy or redirect serves
+func (h *Handler) GetDefaultSite(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.CaddyClient.DefaultSite())
+}
+
+// UpdateDefaultSite enables or disables the catch-all default site and applies it to Caddy. Its
+// page comes from the default-site page templates.
+func (h *Handler) UpdateDefaultSite(w http.ResponseWriter, r *http.Request) {
+	var site models.DefaultSite
+	if err := json.NewDecoder(r.Body).Decode(&site); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := caddy.NormalizeDefaultSite(&site); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.caddyClientFor(r).SaveDefaultSite(site); err != nil {
+		writeCaddyError(w, "Failed to apply default site", err)
+		return
+	}
+
+	h.logAudit(r, "UPDATE_DEFAULT_SITE", fmt.Sprintf("Default site %s", defaultSiteDescription(site)))
+	writeJSON(w, http.StatusOK, h.CaddyClient.DefaultSite())
+}
+
+// defaultSiteDescription describes default site settings for the audit log
+func defaultSiteDescription(site models.DefaultSite) string {
+	switch {
+	case !site.Enabled:
+		return "disabled"
+	case site.Mode == models.DefaultSiteAbort:
+		return "enabled, closing connections"
+	default:
+		return fmt.Sprintf("enabled, serving the page with status %d", site.StatusCode)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// validateDependencies checks a proxy's dependencies exist and don't form a cycle
+func (h *Handler) validateDependencies(proxy *models.Proxy) error {
+	if len(proxy.DependsOn) == 0 {
+		return nil
+	}
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get Caddy config: %v", err)
+	}
+
+	dependencies := make(map[string][]string)
+	for _, existing := range h.CaddyClient.ParseProxiesFromConfig(config) {
+		dependencies[existing.ID] = existing.DependsOn
+	}
+	dependencies[proxy.ID] = proxy.DependsOn
+
+	for _, dep := range proxy.DependsOn {
+		if dep == proxy.ID {
+			return fmt.Errorf("a proxy can't depend on itself")
+		}
+		if _, exists := dependencies[dep]; !exists {
+			return fmt.Errorf("dependency %s is not a known proxy", dep)
+		}
+	}
+
+	// Any new cycle has to pass through this proxy, so look for a path back to it
+	visited := make(map[string]bool)
+	var reachesProxy func(id string) bool
+	reachesProxy = func(id string) bool {
+		if id == proxy.ID {
+			return true
+		}
+		if visited[id] {
+			return false
+		}
+		visited[id] = true
+		return slices.ContainsFunc(dependencies[id], reachesProxy)
+	}
+	for _, dep := range proxy.DependsOn {
+		if reachesProxy(dep) {
+			return fmt.Errorf("dependency on %s would create a cycle", dep)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/events"
+)
+
+// CreateDeployToken generates a new deploy hook token for a proxy, replacing any previous one.
+// The plain token is only returned once; only its hash is stored.
+func (h *Handler) CreateDeployToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.CaddyClient.GetProxy(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	token, err := auth.GenerateToken()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to generate token: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.CaddyClient.SetDeployToken(id, auth.HashToken(token)); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to save deploy token: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "CREATE_DEPLOY_TOKEN", fmt.Sprintf("Deploy hook token created for proxy '%s'", id))
+
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"token":    token,
+		"hook_url": fmt.Sprintf("/api/hooks/deploy/%s?token=%s", id, token),
+	})
+}
+
+// DeleteDeployToken revokes the deploy hook token for a proxy
+func (h *Handler) DeleteDeployToken(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		http.Error(w, `{"error": "Invalid proxy ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.CaddyClient.DeleteDeployToken(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete deploy token: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "DELETE_DEPLOY_TOKEN", fmt.Sprintf("Deploy hook token revoked for proxy '%s'", id))
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Deploy token for proxy %s revoked", id),
+	})
+}
+
+// DeployHook lets a deployment pipeline point a proxy at a new upstream. It is authenticated
+// by the proxy's deploy token rather than a session and can only change that proxy's target.
+func (h *Handler) DeployHook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("proxyID")
+	token := r.URL.Query().Get("token")
+	if id == "" || token == "" {
+		http.Error(w, `{"error": "Proxy ID and token are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	tokenHash, exists := h.CaddyClient.GetDeployToken(id)
+	if !exists || !auth.TokenMatchesHash(token, tokenHash) {
+		http.Error(w, `{"error": "Invalid deploy token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		TargetURL string `json:"target_url"`
+		Port      int    `json:"port"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	previousTarget := proxy.TargetURL
+	switch {
+	case req.TargetURL != "" && proxy.UpstreamType == caddy.UpstreamTypeFastCGI:
+		if _, err := url.Parse(req.TargetURL); err != nil {
+			http.Error(w, `{"error": "Invalid target_url"}`, http.StatusBadRequest)
+			return
+		}
+		proxy.TargetURL = req.TargetURL
+	case req.TargetURL != "":
+		target, err := caddy.NormalizeTargetURL(req.TargetURL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		proxy.TargetURL = target
+	case req.Port > 0 && req.Port <= 65535:
+		// Keep the current upstream host and swap only the port, e.g. for a new container
+		target, err := url.Parse(proxy.TargetURL)
+		if err != nil || target.Hostname() == "" {
+			http.Error(w, `{"error": "Current target URL has no host to apply the port to"}`, http.StatusBadRequest)
+			return
+		}
+		target.Host = net.JoinHostPort(target.Hostname(), strconv.Itoa(req.Port))
+		proxy.TargetURL = target.String()
+	default:
+		http.Error(w, `{"error": "target_url or a valid port is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	// The deploy hook moves the first upstream of a load balanced proxy
+	if len(proxy.TargetURLs) > 0 {
+		proxy.TargetURLs[0] = proxy.TargetURL
+	}
+
+	proxy.UpdateTimestamp()
+	proxy.MarkApplied("deploy-hook")
+	if err := h.CaddyClient.UpdateProxy(*proxy); err != nil {
+		writeCaddyError(w, "Failed to update proxy in Caddy", err)
+		return
+	}
+
+	if proxy.HealthCheckEnabled {
+		if err := h.HealthService.StartHealthCheck(*proxy); err != nil {
+			fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+		}
+	}
+
+	if h.AuditService != nil {
+		ipAddress := r.RemoteAddr
+		if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+			ipAddress = ip
+		}
+		h.AuditService.Log("DEPLOY_HOOK", fmt.Sprintf("Proxy '%s' target changed from '%s' to '%s'", proxy.ID, previousTarget, proxy.TargetURL), "deploy-hook", "deploy-hook", ipAddress)
+	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: proxy.ID, Domain: proxy.Domain})
+
+	// Deploy tokens only move the target, so the response leaves out the proxy's credentials
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":         proxy.ID,
+		"target_url": proxy.TargetURL,
+	})
+}
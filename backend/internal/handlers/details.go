@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/redact"
+)
+
+// GetProxy returns a single proxy with its health, certificate, deploy hook and debug capture state
+func (h *Handler) GetProxy(w http.ResponseWriter, r *http.Request) {
+	proxy, err := h.CaddyClient.GetProxy(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+		return
+	}
+	h.writeProxyDetails(w, proxy)
+}
+
+// LookupProxy returns the proxy serving ?domain= like GetProxy, so tools that know proxies by domain,
+// such as a Terraform import, can find their ID
+func (h *Handler) LookupProxy(w http.ResponseWriter, r *http.Request) {
+	domain, err := hostname.Normalize(r.URL.Query().Get("domain"), true)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	for _, proxy := range h.CaddyClient.ParseProxiesFromConfig(config) {
+		if proxy.Domain == domain {
+			h.writeProxyDetails(w, &proxy)
+			return
+		}
+	}
+	http.Error(w, `{"error": "No proxy serves this domain"}`, http.StatusNotFound)
+}
+
+// writeProxyDetails responds with a proxy and its health, certificate, deploy hook and debug capture
+// state
+func (h *Handler) writeProxyDetails(w http.ResponseWriter, proxy *models.Proxy) {
+	response := map[string]any{
+		"debug_capture": h.debugCaptureStatus(proxy.ID),
+	}
+
+	if status, exists := h.HealthService.GetHealthStatus(proxy.ID); exists {
+		proxy.Status = status.Status
+		proxy.DownDependencies = status.DownDependencies
+		response["health"] = status
+	} else if proxy.HealthCheckEnabled {
+		proxy.Status = "Pending"
+	}
+	response["proxy"] = proxy.Redacted()
+
+	if proxy.SSLMode != SSLModeNone {
+		response["certificate"] = h.CaddyClient.CertificateStatus(caddy.HostOnly(proxy.Domain))
+	}
+
+	_, hasDeployToken := h.CaddyClient.GetDeployToken(proxy.ID)
+	response["deploy_hook_enabled"] = hasDeployToken
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetProxyGenerated returns the Caddy config the manager generated for a proxy, so users can see
+// what their settings produce
+func (h *Handler) GetProxyGenerated(w http.ResponseWriter, r *http.Request) {
+	generated, err := h.CaddyClient.GeneratedConfig(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Read-only users see this too, so DNS provider credentials and password hashes are masked
+	masked, err := redact.JSON(generated)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to mask generated config: %v", err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, masked)
+}
+
+// GetRedirect returns a single redirect with the certificate status of its source domains
+func (h *Handler) GetRedirect(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	id := r.PathValue("id")
+	var redirect *models.Redirect
+	for _, candidate := range h.CaddyClient.ParseRedirectsFromConfig(config) {
+		if candidate.ID == id {
+			redirect = &candidate
+			break
+		}
+	}
+	if redirect == nil {
+		http.Error(w, `{"error": "Redirect not found"}`, http.StatusNotFound)
+		return
+	}
+
+	certificates := make([]caddy.CertificateStatus, 0, len(redirect.SourceDomains))
+	for _, domain := range redirect.SourceDomains {
+		certificates = append(certificates, h.CaddyClient.CertificateStatus(caddy.HostOnly(domain)))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"redirect":     redirect,
+		"certificates": certificates,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// drainRetryAfter is the Retry-After, in seconds, sent with mutations refused during shutdown
+const drainRetryAfter = "30"
+
+// DrainGate refuses requests that change state once shutdown has started, while reads keep being
+// served until the HTTP server stops
+type DrainGate struct {
+	draining atomic.Bool
+}
+
+// NewDrainGate creates a gate that lets every request through until Start is called
+func NewDrainGate() *DrainGate {
+	return &DrainGate{}
+}
+
+// Start makes the gate refuse mutations
+func (g *DrainGate) Start() {
+	g.draining.Store(true)
+}
+
+// Draining reports whether Start was called
+func (g *DrainGate) Draining() bool {
+	return g.draining.Load()
+}
+
+// Middleware answers requests other than GET, HEAD and OPTIONS with 503 Service Unavailable while
+// draining, so clients retry against the restarted or another instance
+func (g *DrainGate) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.Draining() {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				w.Header().Set("Retry-After", drainRetryAfter)
+				http.Error(w, `{"error": "The manager is shutting down, try again shortly"}`, http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventKeepAliveInterval is how often a comment is sent on an idle stream so proxies in front of
+// the manager don't close it
+const eventKeepAliveInterval = 30 * time.Second
+
+// StreamEvents streams health transitions, proxy changes and Caddy reachability changes as
+// server-sent events until the client disconnects
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.Events == nil {
+		http.Error(w, `{"error": "Events are not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error": "Streaming is not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	stream, unsubscribe, err := h.Events.Subscribe()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusServiceUnavailable)
+		return
+	}
+	defer unsubscribe()
+
+	// The server's read and write timeouts would otherwise end the stream after a minute
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Time{})
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable response buffering in nginx
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event := <-stream:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
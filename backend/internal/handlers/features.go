@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/update"
+)
+
+// Features are the optional capabilities of a deployment that depend on how it was started
+type Features struct {
+	Auth             bool   `json:"auth"`
+	SessionStore     string `json:"session_store"` // "file", "redis" or "postgres"
+	StateStore       string `json:"state_store"`   // where users, metadata and the audit log are kept: "file" or "postgres"
+	HighAvailability bool   `json:"high_availability"`
+	SelfUpdate       bool   `json:"self_update"`
+	ConfigSelfHeal   bool   `json:"config_self_heal"`
+	MetricsPush      bool   `json:"metrics_push"`
+	HealthHook       bool   `json:"health_hook"`
+	OutboundGuard    bool   `json:"outbound_guard"`
+	ConnectivityTest bool   `json:"connectivity_test"`
+	TrafficStats     bool   `json:"traffic_stats"`
+	Kubernetes       bool   `json:"kubernetes_discovery"`
+	IDStrategy       string `json:"id_strategy"`
+}
+
+// GetFeatures describes what this deployment can do: its startup configuration, the optional Caddy
+// modules its running config uses and the integrations set up at runtime, so the UI can hide what
+// isn't available and support can see a deployment's shape at a glance
+func (h *Handler) GetFeatures(w http.ResponseWriter, r *http.Request) {
+	channels := h.CaddyClient.NotificationChannels()
+	enabled := 0
+	for _, channel := range channels {
+		if channel.Enabled {
+			enabled++
+		}
+	}
+
+	caddyModules := map[string]any{}
+	if modules, err := h.CaddyClient.ModulesInUse(); err != nil {
+		caddyModules["error"] = err.Error()
+	} else {
+		caddyModules["in_use"] = modules
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":  update.Version,
+		"features": h.Features,
+		"caddy":    caddyModules,
+		"notifications": map[string]int{
+			"channels": len(channels),
+			"enabled":  enabled,
+		},
+	})
+}
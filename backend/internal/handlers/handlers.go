@@ -2,35 +2,60 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/sarat/caddyproxymanager/pkg/audit"
 	"github.com/sarat/caddyproxymanager/pkg/auth"
 	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/discovery"
+	"github.com/sarat/caddyproxymanager/pkg/events"
 	"github.com/sarat/caddyproxymanager/pkg/health"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
 	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/netguard"
+	"github.com/sarat/caddyproxymanager/pkg/portcheck"
+	"github.com/sarat/caddyproxymanager/pkg/redact"
+	"github.com/sarat/caddyproxymanager/pkg/stats"
 )
 
 // Constants for repeated strings
 const (
-	SSLModeAuto = "auto"
+	SSLModeAuto     = "auto"
+	SSLModeNone     = "none"
+	SSLModeCustom   = "custom"
+	SSLModeInternal = "internal"
+	SSLModeExisting = "existing"
 )
 
 type Handler struct {
 	CaddyClient   *caddy.Client
 	HealthService *health.Service
 	AuditService  *audit.Service
+	StatusPoller  *caddy.StatusPoller
+	ConfigWatcher *caddy.ConfigWatcher
+	OutboundGuard *netguard.Guard // nil allows outbound checks to any address
+	Events        *events.Broker
+	Features      Features           // set once at startup
+	Stats         *stats.Aggregator  // nil when traffic statistics are off
+	Kubernetes    *discovery.Watcher // nil when Kubernetes discovery is off
+	PortCheck     *portcheck.Checker // nil when no port check service is configured
 }
 
-func New(caddyClient *caddy.Client, healthService *health.Service, auditService *audit.Service) *Handler {
+func New(caddyClient *caddy.Client, healthService *health.Service, auditService *audit.Service, statusPoller *caddy.StatusPoller, configWatcher *caddy.ConfigWatcher, outboundGuard *netguard.Guard, eventBroker *events.Broker) *Handler {
 	return &Handler{
 		CaddyClient:   caddyClient,
 		HealthService: healthService,
 		AuditService:  auditService,
+		StatusPoller:  statusPoller,
+		ConfigWatcher: configWatcher,
+		OutboundGuard: outboundGuard,
+		Events:        eventBroker,
 	}
 }
 
@@ -43,7 +68,15 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetProxies lists proxies. q, status and ssl_mode filter the list, sort orders it, page/per_page
+// paginate it and fields (e.g. "id,domain,status") limits each proxy to the given JSON fields.
 func (h *Handler) GetProxies(w http.ResponseWriter, r *http.Request) {
+	options, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	// Get current Caddy configuration
 	config, err := h.CaddyClient.GetConfig()
 	if err != nil {
@@ -57,102 +90,67 @@ func (h *Handler) GetProxies(w http.ResponseWriter, r *http.Request) {
 	// Get all health statuses
 	healthStatuses := h.HealthService.GetAllHealthStatuses()
 
-	// Add health status to each proxy
+	// Add health status to each proxy, which filters may match on
 	for i := range proxies {
 		if status, exists := healthStatuses[proxies[i].ID]; exists {
 			proxies[i].Status = status.Status
+			proxies[i].DownDependencies = status.DownDependencies
+			proxies[i].ResponseTimeMs = status.ResponseTimeMs
+			proxies[i].AvgResponseTimeMs = status.AvgResponseTimeMs
 		} else if proxies[i].HealthCheckEnabled {
 			proxies[i].Status = "Pending"
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]any{
-		"proxies": proxies,
-		"count":   len(proxies),
-	}); err != nil {
-		// Log error if needed, but response is already written
+	filter := parseProxyFilter(r.URL.Query())
+	proxies = slices.DeleteFunc(proxies, func(proxy models.Proxy) bool { return !filter.matches(proxy) })
+
+	if err := sortList(proxies, options.sort, proxySortKeys); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
 		return
 	}
-}
+	for i := range proxies {
+		proxies[i] = proxies[i].Redacted()
+	}
 
-func (h *Handler) CreateProxy(w http.ResponseWriter, r *http.Request) {
-	var proxyReq struct {
-		Domain                    string            `json:"domain"`
-		TargetURL                 string            `json:"target_url"`
-		SSLMode                   string            `json:"ssl_mode"`
-		ChallengeType             string            `json:"challenge_type"`
-		DNSProvider               string            `json:"dns_provider"`
-		DNSCredentials            map[string]string `json:"dns_credentials"`
-		CustomHeaders             map[string]string `json:"custom_headers"`
-		BasicAuth                 *models.BasicAuth `json:"basic_auth"`
-		CustomCaddyJSON           string            `json:"custom_caddy_json"`
-		HealthCheckEnabled        bool              `json:"health_check_enabled"`
-		HealthCheckInterval       string            `json:"health_check_interval"`
-		HealthCheckPath           string            `json:"health_check_path"`
-		HealthCheckExpectedStatus int               `json:"health_check_expected_status"`
-		AllowedIPs                []string          `json:"allowed_ips"`
-		BlockedIPs                []string          `json:"blocked_ips"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&proxyReq); err != nil {
-		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+	response, err := listResponse("proxies", proxies, options)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	// Validate required fields
-	if proxyReq.Domain == "" || proxyReq.TargetURL == "" {
-		http.Error(w, `{"error": "Domain and target_url are required"}`, http.StatusBadRequest)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) CreateProxy(w http.ResponseWriter, r *http.Request) {
+	proxy, err := h.parseProxyRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
-	// Set defaults if not provided
-	if proxyReq.SSLMode == "" {
-		proxyReq.SSLMode = SSLModeAuto
-	}
-	if proxyReq.ChallengeType == "" {
-		proxyReq.ChallengeType = "http"
-	}
+	h.createProxy(w, r, proxy, "")
+}
 
-	// Validate DNS challenge configuration
-	if proxyReq.SSLMode == "auto" && proxyReq.ChallengeType == "dns" {
-		if proxyReq.DNSProvider == "" {
-			http.Error(w, `{"error": "DNS provider is required for DNS challenge"}`, http.StatusBadRequest)
-			return
-		}
+// createProxy adds a proxy built from a request to Caddy and answers with it. origin is appended to
+// the audit log entry, e.g. to name the template the proxy was created from.
+func (h *Handler) createProxy(w http.ResponseWriter, r *http.Request, proxy *models.Proxy, origin string) {
+	proxy.ID = h.CaddyClient.NewProxyID(proxy.Domain)
 
-		// Validate DNS credentials based on provider
-		if err := h.validateDNSCredentials(proxyReq.DNSProvider, proxyReq.DNSCredentials); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
-			return
-		}
+	if r.URL.Query().Get("dry_run") == "true" {
+		h.previewProxy(w, proxy)
+		return
 	}
 
-	// Create new proxy
-	proxy := models.NewProxy(proxyReq.Domain, proxyReq.TargetURL, proxyReq.SSLMode)
-	proxy.ChallengeType = proxyReq.ChallengeType
-	proxy.DNSProvider = proxyReq.DNSProvider
-	proxy.DNSCredentials = proxyReq.DNSCredentials
-	proxy.CustomHeaders = proxyReq.CustomHeaders
-	proxy.BasicAuth = proxyReq.BasicAuth
-	proxy.CustomCaddyJSON = proxyReq.CustomCaddyJSON
-	proxy.HealthCheckEnabled = proxyReq.HealthCheckEnabled
-	if proxyReq.HealthCheckInterval != "" {
-		proxy.HealthCheckInterval = proxyReq.HealthCheckInterval
-	}
-	if proxyReq.HealthCheckPath != "" {
-		proxy.HealthCheckPath = proxyReq.HealthCheckPath
-	}
-	if proxyReq.HealthCheckExpectedStatus != 0 {
-		proxy.HealthCheckExpectedStatus = proxyReq.HealthCheckExpectedStatus
+	if err := h.validateDependencies(proxy); err != nil {
+		writeRequestError(w, err)
+		return
 	}
-	proxy.AllowedIPs = proxyReq.AllowedIPs
-	proxy.BlockedIPs = proxyReq.BlockedIPs
 
 	// Add proxy to Caddy configuration
-	if err := h.CaddyClient.AddProxy(*proxy); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to add proxy to Caddy: %v"}`, err), http.StatusInternalServerError)
+	proxy.MarkApplied(requestUsername(r))
+	if err := h.caddyClientFor(r).AddProxy(*proxy); err != nil {
+		writeCaddyError(w, "Failed to add proxy to Caddy", err)
 		return
 	}
 
@@ -177,12 +175,13 @@ func (h *Handler) CreateProxy(w http.ResponseWriter, r *http.Request) {
 		if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
 			ipAddress = ip
 		}
-		h.AuditService.Log("CREATE_PROXY", fmt.Sprintf("Proxy '%s' created for domain '%s'", proxy.ID, proxy.Domain), userID, username, ipAddress)
+		h.AuditService.Log("CREATE_PROXY", fmt.Sprintf("Proxy '%s' created for domain '%s'%s", proxy.ID, proxy.Domain, origin), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionCreated, ProxyID: proxy.ID, Domain: proxy.Domain})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(proxy); err != nil {
+	if err := json.NewEncoder(w).Encode(proxy.Redacted()); err != nil {
 		// Log error if needed, but response is already written
 		return
 	}
@@ -195,83 +194,39 @@ func (h *Handler) UpdateProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var proxyReq struct {
-		Domain                    string            `json:"domain"`
-		TargetURL                 string            `json:"target_url"`
-		SSLMode                   string            `json:"ssl_mode"`
-		ChallengeType             string            `json:"challenge_type"`
-		DNSProvider               string            `json:"dns_provider"`
-		DNSCredentials            map[string]string `json:"dns_credentials"`
-		CustomHeaders             map[string]string `json:"custom_headers"`
-		BasicAuth                 *models.BasicAuth `json:"basic_auth"`
-		CustomCaddyJSON           string            `json:"custom_caddy_json"`
-		HealthCheckEnabled        bool              `json:"health_check_enabled"`
-		HealthCheckInterval       string            `json:"health_check_interval"`
-		HealthCheckPath           string            `json:"health_check_path"`
-		HealthCheckExpectedStatus int               `json:"health_check_expected_status"`
-		AllowedIPs                []string          `json:"allowed_ips"`
-		BlockedIPs                []string          `json:"blocked_ips"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&proxyReq); err != nil {
-		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+	proxy, err := h.parseProxyRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
 		return
 	}
+	h.replaceProxy(w, r, id, proxy)
+}
 
-	// Validate required fields
-	if proxyReq.Domain == "" || proxyReq.TargetURL == "" {
-		http.Error(w, `{"error": "Domain and target_url are required"}`, http.StatusBadRequest)
-		return
-	}
+// replaceProxy saves proxy as the proxy with the given ID, or previews it with dry_run=true, and
+// responds with the result
+func (h *Handler) replaceProxy(w http.ResponseWriter, r *http.Request, id string, proxy *models.Proxy) {
+	proxy.ID = id
+	proxy.UpdateTimestamp()
 
-	// Set defaults if not provided
-	if proxyReq.SSLMode == "" {
-		proxyReq.SSLMode = SSLModeAuto
-	}
-	if proxyReq.ChallengeType == "" {
-		proxyReq.ChallengeType = "http"
+	// Secrets are masked in responses, and sending them back that way keeps them unchanged
+	if previous, err := h.CaddyClient.GetProxy(id); err == nil {
+		proxy.RestoreRedacted(*previous)
 	}
 
-	// Validate DNS challenge configuration
-	if proxyReq.SSLMode == "auto" && proxyReq.ChallengeType == "dns" {
-		if proxyReq.DNSProvider == "" {
-			http.Error(w, `{"error": "DNS provider is required for DNS challenge"}`, http.StatusBadRequest)
-			return
-		}
-
-		// Validate DNS credentials based on provider
-		if err := h.validateDNSCredentials(proxyReq.DNSProvider, proxyReq.DNSCredentials); err != nil {
-			http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
-			return
-		}
+	if r.URL.Query().Get("dry_run") == "true" {
+		h.previewProxy(w, proxy)
+		return
 	}
 
-	// Create updated proxy
-	proxy := models.NewProxy(proxyReq.Domain, proxyReq.TargetURL, proxyReq.SSLMode)
-	proxy.ID = id
-	proxy.ChallengeType = proxyReq.ChallengeType
-	proxy.DNSProvider = proxyReq.DNSProvider
-	proxy.DNSCredentials = proxyReq.DNSCredentials
-	proxy.CustomHeaders = proxyReq.CustomHeaders
-	proxy.BasicAuth = proxyReq.BasicAuth
-	proxy.CustomCaddyJSON = proxyReq.CustomCaddyJSON
-	proxy.HealthCheckEnabled = proxyReq.HealthCheckEnabled
-	if proxyReq.HealthCheckInterval != "" {
-		proxy.HealthCheckInterval = proxyReq.HealthCheckInterval
-	}
-	if proxyReq.HealthCheckPath != "" {
-		proxy.HealthCheckPath = proxyReq.HealthCheckPath
-	}
-	if proxyReq.HealthCheckExpectedStatus != 0 {
-		proxy.HealthCheckExpectedStatus = proxyReq.HealthCheckExpectedStatus
-	}
-	proxy.AllowedIPs = proxyReq.AllowedIPs
-	proxy.BlockedIPs = proxyReq.BlockedIPs
-	proxy.UpdateTimestamp()
+	if err := h.validateDependencies(proxy); err != nil {
+		writeRequestError(w, err)
+		return
+	}
 
 	// Update proxy in Caddy configuration
-	if err := h.CaddyClient.UpdateProxy(*proxy); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to update proxy in Caddy: %v"}`, err), http.StatusInternalServerError)
+	proxy.MarkApplied(requestUsername(r))
+	if err := h.caddyClientFor(r).UpdateProxy(*proxy); err != nil {
+		writeCaddyError(w, "Failed to update proxy in Caddy", err)
 		return
 	}
 
@@ -299,15 +254,43 @@ func (h *Handler) UpdateProxy(w http.ResponseWriter, r *http.Request) {
 		}
 		h.AuditService.Log("UPDATE_PROXY", fmt.Sprintf("Proxy '%s' updated for domain '%s'", proxy.ID, proxy.Domain), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: proxy.ID, Domain: proxy.Domain})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(proxy); err != nil {
+	if err := json.NewEncoder(w).Encode(proxy.Redacted()); err != nil {
 		// Log error if needed, but response is already written
 		return
 	}
 }
 
+// previewProxy answers a dry run of creating or updating a proxy with the config it would generate
+// and any validation errors, leaving Caddy untouched
+func (h *Handler) previewProxy(w http.ResponseWriter, proxy *models.Proxy) {
+	validationErrors := []string{}
+	if err := h.validateDependencies(proxy); err != nil {
+		validationErrors = append(validationErrors, err.Error())
+	}
+
+	generated, err := h.CaddyClient.PreviewProxy(*proxy)
+	if err != nil {
+		validationErrors = append(validationErrors, err.Error())
+	}
+	masked, err := redact.JSON(generated)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to mask generated config: %v", err)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"dry_run":   true,
+		"valid":     len(validationErrors) == 0,
+		"errors":    validationErrors,
+		"proxy":     proxy.Redacted(),
+		"generated": masked,
+	})
+}
+
 func (h *Handler) DeleteProxy(w http.ResponseWriter, r *http.Request) {
 	id := extractIDFromPath(r.URL.Path)
 	if id == "" {
@@ -315,13 +298,35 @@ func (h *Handler) DeleteProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remove proxy from Caddy configuration
+	if err := h.caddyClientFor(r).DeleteProxy(id); err != nil {
+		writeCaddyError(w, "Failed to delete proxy from Caddy", err)
+		return
+	}
+
 	// Stop health checking for this proxy
 	h.HealthService.StopHealthCheck(id)
 
-	// Remove proxy from Caddy configuration
-	if err := h.CaddyClient.DeleteProxy(id); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete proxy from Caddy: %v"}`, err), http.StatusInternalServerError)
-		return
+	// Revoke the proxy's deploy hook token along with it
+	if err := h.CaddyClient.DeleteDeployToken(id); err != nil {
+		fmt.Printf("Warning: Failed to delete deploy token for proxy %s: %v\n", id, err)
+	}
+	h.CaddyClient.DeleteDebugCapture(id)
+	if err := h.CaddyClient.RemoveDependency(id); err != nil {
+		fmt.Printf("Warning: Failed to remove proxy %s from dependency lists: %v\n", id, err)
+	}
+
+	if err := h.CaddyClient.DeleteCustomCertificate(id); err != nil {
+		fmt.Printf("Warning: Failed to delete custom certificate for proxy %s: %v\n", id, err)
+	}
+	if err := h.CaddyClient.DeleteIPLists(id); err != nil {
+		fmt.Printf("Warning: Failed to delete IP lists for proxy %s: %v\n", id, err)
+	}
+	if err := h.CaddyClient.DeleteWAFLog(id); err != nil {
+		fmt.Printf("Warning: Failed to delete WAF log for proxy %s: %v\n", id, err)
+	}
+	if err := h.CaddyClient.DeleteAccessLog(id); err != nil {
+		fmt.Printf("Warning: Failed to delete access log for proxy %s: %v\n", id, err)
 	}
 
 	// Log delete proxy action
@@ -339,6 +344,7 @@ func (h *Handler) DeleteProxy(w http.ResponseWriter, r *http.Request) {
 		}
 		h.AuditService.Log("DELETE_PROXY", fmt.Sprintf("Proxy '%s' deleted", id), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionDeleted, ProxyID: id})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -369,35 +375,39 @@ func (h *Handler) GetProxyStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Status serves Caddy's upstream status from the poller's cache. Clients can send the returned
+// ETag in If-None-Match to get a 304 until the status actually changes.
 func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
-	// Check Caddy status
-	status, err := h.CaddyClient.GetStatus()
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if encErr := json.NewEncoder(w).Encode(map[string]any{
-			"caddy_status":    "error",
-			"caddy_reachable": false,
-			"error":           err.Error(),
-			"last_checked":    time.Now().Format(time.RFC3339),
-		}); encErr != nil {
-			// Log error if needed, but response is already written
-			return
-		}
+	snapshot := h.StatusPoller.Snapshot()
+
+	// Upgrade probes run with the health checks, so their results count towards the ETag as well
+	websockets := h.HealthService.WebSocketUpgrades()
+	probes, _ := json.Marshal(websockets)
+	etag := fmt.Sprintf(`"status-%d-%x"`, snapshot.Version, crc32.ChecksumIEEE(probes))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Last-Modified", snapshot.ChangedAt.UTC().Format(http.TimeFormat))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]any{
+	response := map[string]any{
 		"caddy_status":    "running",
-		"caddy_reachable": true,
-		"upstreams":       status,
-		"last_checked":    time.Now().Format(time.RFC3339),
-	}); err != nil {
-		// Log error if needed, but response is already written
-		return
+		"caddy_reachable": snapshot.Reachable,
+		"last_checked":    snapshot.LastChecked.Format(time.RFC3339),
+		"changed_at":      snapshot.ChangedAt.Format(time.RFC3339),
+		"next_check":      snapshot.LastChecked.Add(h.StatusPoller.Interval()).Format(time.RFC3339),
+		"websockets":      websockets,
+	}
+	if snapshot.Reachable {
+		response["upstreams"] = snapshot.Upstreams
+	} else {
+		response["caddy_status"] = "error"
+		response["error"] = snapshot.Error
 	}
+
+	writeJSON(w, http.StatusOK, response)
 }
 
 func (h *Handler) Reload(w http.ResponseWriter, r *http.Request) {
@@ -406,6 +416,10 @@ func (h *Handler) Reload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Don't make clients wait for the next poll to see the reloaded upstreams
+	h.StatusPoller.Refresh()
+	h.Events.Publish(events.TypeReload, nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if _, err := w.Write([]byte(`{"message": "Caddy configuration reloaded successfully"}`)); err != nil {
@@ -414,53 +428,19 @@ func (h *Handler) Reload(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// extractIDFromPath extracts ID from path like /api/proxies/proxy_example_com_1234567890
 // validateDNSCredentials validates DNS provider credentials with environment variable fallback
 func (h *Handler) validateDNSCredentials(provider string, credentials map[string]string) error {
-	switch provider {
-	case "cloudflare":
-		apiToken := credentials["api_token"]
-		// Check if token is provided in request or available as environment variable
-		if apiToken == "" && os.Getenv("CLOUDFLARE_API_TOKEN") == "" {
-			return fmt.Errorf("Cloudflare API token is required (provide in request or set CLOUDFLARE_API_TOKEN environment variable)")
-		}
-		// Optional email validation
-		if email := credentials["email"]; email != "" {
-			if !strings.Contains(email, "@") {
-				return fmt.Errorf("Invalid email format")
-			}
-		}
-	case "digitalocean":
-		authToken := credentials["auth_token"]
-		if authToken == "" && os.Getenv("DO_AUTH_TOKEN") == "" {
-			return fmt.Errorf("DigitalOcean auth token is required (provide in request or set DO_AUTH_TOKEN environment variable)")
-		}
-	case "duckdns":
-		token := credentials["token"]
-		if token == "" && os.Getenv("DUCKDNS_TOKEN") == "" {
-			return fmt.Errorf("DuckDNS token is required (provide in request or set DUCKDNS_TOKEN environment variable)")
-		}
-	case "hetzner":
-		apiToken := credentials["api_token"]
-		if apiToken == "" && os.Getenv("HETZNER_API_TOKEN") == "" {
-			return fmt.Errorf("Hetzner API token is required (provide in request or set HETZNER_API_TOKEN environment variable)")
-		}
-	case "gandi":
-		bearerToken := credentials["bearer_token"]
-		if bearerToken == "" && os.Getenv("GANDI_BEARER_TOKEN") == "" {
-			return fmt.Errorf("Gandi bearer token is required (provide in request or set GANDI_BEARER_TOKEN environment variable)")
-		}
-	case "dnsimple":
-		apiAccessToken := credentials["api_access_token"]
-		if apiAccessToken == "" && os.Getenv("DNSIMPLE_API_ACCESS_TOKEN") == "" {
-			return fmt.Errorf("DNSimple API access token is required (provide in request or set DNSIMPLE_API_ACCESS_TOKEN environment variable)")
-		}
-	default:
-		return fmt.Errorf("Unsupported DNS provider: %s", provider)
-	}
-	return nil
+	return caddy.ValidateDNSCredentials(provider, credentials)
+}
+
+// GetDNSProviders lists the supported DNS providers and the credentials each one needs
+func (h *Handler) GetDNSProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"providers": caddy.DNSProviders(),
+	})
 }
 
+// extractIDFromPath extracts ID from path like /api/proxies/proxy_example_com_1234567890
 func extractIDFromPath(path string) string {
 	parts := strings.Split(path, "/")
 	if len(parts) >= 4 {
@@ -488,8 +468,47 @@ func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetRedirects retrieves all redirect configurations
+// GetAuditAnalysis reports suspicious patterns in the audit log, such as repeated failed logins
+// from one address, within the window query parameter (default 24h, at most 90 days)
+func (h *Handler) GetAuditAnalysis(w http.ResponseWriter, r *http.Request) {
+	window := 24 * time.Hour
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > 90*24*time.Hour {
+			http.Error(w, `{"error": "window must be a duration such as 24h, up to 2160h"}`, http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	entries, err := h.AuditService.Entries()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to retrieve audit log: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	since := time.Now().Add(-window)
+	findings := audit.Analyze(entries, since)
+	if findings == nil {
+		findings = []audit.Finding{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"findings": findings,
+		"count":    len(findings),
+		"since":    since.Format(time.RFC3339),
+	})
+}
+
+// GetRedirects lists redirects, taking the same q, status, sort, page, per_page and fields
+// parameters as GetProxies
 func (h *Handler) GetRedirects(w http.ResponseWriter, r *http.Request) {
+	options, err := parseListOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	// Get current Caddy configuration
 	config, err := h.CaddyClient.GetConfig()
 	if err != nil {
@@ -500,15 +519,21 @@ func (h *Handler) GetRedirects(w http.ResponseWriter, r *http.Request) {
 	// Parse redirects from config
 	redirects := h.CaddyClient.ParseRedirectsFromConfig(config)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]any{
-		"redirects": redirects,
-		"count":     len(redirects),
-	}); err != nil {
-		// Log error if needed, but response is already written
+	filter := parseRedirectFilter(r.URL.Query())
+	redirects = slices.DeleteFunc(redirects, func(redirect models.Redirect) bool { return !filter.matches(redirect) })
+
+	if err := sortList(redirects, options.sort, redirectSortKeys); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
 		return
 	}
+
+	response, err := listResponse("redirects", redirects, options)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
 }
 
 // CreateRedirect creates a new redirect configuration
@@ -518,6 +543,8 @@ func (h *Handler) CreateRedirect(w http.ResponseWriter, r *http.Request) {
 		DestinationURL string   `json:"destination_url"`
 		RedirectCode   int      `json:"redirect_code"`
 		PreservePath   bool     `json:"preserve_path"`
+		Tags           []string `json:"tags"`
+		Notes          string   `json:"notes"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&redirectReq); err != nil {
@@ -542,12 +569,39 @@ func (h *Handler) CreateRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sourceDomains, err := hostname.NormalizeAll(redirectReq.SourceDomains, true)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	redirectReq.SourceDomains = sourceDomains
+
+	if redirectReq.Tags, err = normalizeTags(redirectReq.Tags); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if redirectReq.Notes, err = normalizeNotes(redirectReq.Notes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
 	// Create new redirect
 	redirect := models.NewRedirect(redirectReq.SourceDomains, redirectReq.DestinationURL, redirectReq.RedirectCode, redirectReq.PreservePath)
+	redirect.ID = h.CaddyClient.NewRedirectID(redirect.SourceDomains)
+	redirect.Tags = redirectReq.Tags
+	redirect.Notes = redirectReq.Notes
+
+	// Reject redirect loops and overly long chains before saving
+	warnings, err := h.checkRedirectChain(*redirect)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
 
 	// Add redirect to Caddy configuration
-	if err := h.CaddyClient.AddRedirect(*redirect); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to add redirect to Caddy: %v"}`, err), http.StatusInternalServerError)
+	redirect.MarkApplied(requestUsername(r))
+	if err := h.caddyClientFor(r).AddRedirect(*redirect); err != nil {
+		writeCaddyError(w, "Failed to add redirect to Caddy", err)
 		return
 	}
 
@@ -566,8 +620,10 @@ func (h *Handler) CreateRedirect(w http.ResponseWriter, r *http.Request) {
 		}
 		h.AuditService.Log("CREATE_REDIRECT", fmt.Sprintf("Redirect '%s' created from %v to '%s'", redirect.ID, redirect.SourceDomains, redirect.DestinationURL), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeRedirect, events.RedirectChange{Action: events.ActionCreated, RedirectID: redirect.ID, SourceDomains: redirect.SourceDomains})
 
 	w.Header().Set("Content-Type", "application/json")
+	redirect.Warnings = warnings
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(redirect); err != nil {
 		// Log error if needed, but response is already written
@@ -588,6 +644,8 @@ func (h *Handler) UpdateRedirect(w http.ResponseWriter, r *http.Request) {
 		DestinationURL string   `json:"destination_url"`
 		RedirectCode   int      `json:"redirect_code"`
 		PreservePath   bool     `json:"preserve_path"`
+		Tags           []string `json:"tags"`
+		Notes          string   `json:"notes"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&redirectReq); err != nil {
@@ -612,14 +670,40 @@ func (h *Handler) UpdateRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sourceDomains, err := hostname.NormalizeAll(redirectReq.SourceDomains, true)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	redirectReq.SourceDomains = sourceDomains
+
+	if redirectReq.Tags, err = normalizeTags(redirectReq.Tags); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if redirectReq.Notes, err = normalizeNotes(redirectReq.Notes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
 	// Create updated redirect
 	redirect := models.NewRedirect(redirectReq.SourceDomains, redirectReq.DestinationURL, redirectReq.RedirectCode, redirectReq.PreservePath)
 	redirect.ID = id
+	redirect.Tags = redirectReq.Tags
+	redirect.Notes = redirectReq.Notes
 	redirect.UpdateTimestamp()
 
+	// Reject redirect loops and overly long chains before saving
+	warnings, err := h.checkRedirectChain(*redirect)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	// Update redirect in Caddy configuration
-	if err := h.CaddyClient.UpdateRedirect(*redirect); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to update redirect in Caddy: %v"}`, err), http.StatusInternalServerError)
+	redirect.MarkApplied(requestUsername(r))
+	if err := h.caddyClientFor(r).UpdateRedirect(*redirect); err != nil {
+		writeCaddyError(w, "Failed to update redirect in Caddy", err)
 		return
 	}
 
@@ -638,7 +722,9 @@ func (h *Handler) UpdateRedirect(w http.ResponseWriter, r *http.Request) {
 		}
 		h.AuditService.Log("UPDATE_REDIRECT", fmt.Sprintf("Redirect '%s' updated from %v to '%s'", redirect.ID, redirect.SourceDomains, redirect.DestinationURL), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeRedirect, events.RedirectChange{Action: events.ActionUpdated, RedirectID: redirect.ID, SourceDomains: redirect.SourceDomains})
 
+	redirect.Warnings = warnings
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(redirect); err != nil {
@@ -656,8 +742,8 @@ func (h *Handler) DeleteRedirect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove redirect from Caddy configuration
-	if err := h.CaddyClient.DeleteRedirect(id); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete redirect from Caddy: %v"}`, err), http.StatusInternalServerError)
+	if err := h.caddyClientFor(r).DeleteRedirect(id); err != nil {
+		writeCaddyError(w, "Failed to delete redirect from Caddy", err)
 		return
 	}
 
@@ -676,6 +762,7 @@ func (h *Handler) DeleteRedirect(w http.ResponseWriter, r *http.Request) {
 		}
 		h.AuditService.Log("DELETE_REDIRECT", fmt.Sprintf("Redirect '%s' deleted", id), userID, username, ipAddress)
 	}
+	h.Events.Publish(events.TypeRedirect, events.RedirectChange{Action: events.ActionDeleted, RedirectID: id})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -684,3 +771,92 @@ func (h *Handler) DeleteRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// writeJSON encodes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
+
+// writeRequestError rejects an invalid request. Domain validation errors include their code, so
+// clients can act on the kind of failure.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var domainErr *hostname.Error
+	if errors.As(err, &domainErr) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":  domainErr.Message,
+			"code":   domainErr.Code,
+			"domain": domainErr.Domain,
+		})
+		return
+	}
+	// Encoded rather than formatted, as validation errors quote the offending value
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// caddyClientFor returns the Caddy client for a write request, forced to overwrite routes other
+// tools changed when the request has force=true
+func (h *Handler) caddyClientFor(r *http.Request) *caddy.Client {
+	if r.URL.Query().Get("force") == "true" {
+		return h.CaddyClient.Forced()
+	}
+	return h.CaddyClient
+}
+
+// writeCaddyError reports a failed Caddy update. Updates refused over routes changed by other tools
+// get a 409 listing them, so clients can ask before retrying with force=true. Updates refused during
+// shutdown get a 503.
+func writeCaddyError(w http.ResponseWriter, message string, err error) {
+	var foreignErr *caddy.ForeignChangeError
+	if errors.As(err, &foreignErr) {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":           fmt.Sprintf("%s: %v", message, err),
+			"foreign_changes": foreignErr.Changes,
+		})
+		return
+	}
+	// Caddy's errors quote addresses and paths, so they're encoded rather than formatted into JSON
+	body := map[string]string{"error": fmt.Sprintf("%s: %v", message, err)}
+	if errors.Is(err, caddy.ErrConfigConflict) {
+		writeJSON(w, http.StatusConflict, body)
+		return
+	}
+	if errors.Is(err, caddy.ErrDraining) {
+		w.Header().Set("Retry-After", drainRetryAfter)
+		writeJSON(w, http.StatusServiceUnavailable, body)
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, body)
+}
+
+// logAudit records an audit entry attributed to the user and client of the request
+func (h *Handler) logAudit(r *http.Request, action, details string) {
+	if h.AuditService == nil {
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	username := "unknown"
+	userID := "unknown"
+	if user != nil {
+		username = user.Username
+		userID = user.ID
+	}
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.AuditService.Log(action, details, userID, username, ipAddress)
+}
+
+// requestUsername returns the name of the user making the request, recorded as who applied a change
+func requestUsername(r *http.Request) string {
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		return user.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// pauseRequest pauses or resumes health checks
+type pauseRequest struct {
+	Paused bool `json:"paused"`
+}
+
+// GetHealthChecks reports whether all health checks are paused
+func (h *Handler) GetHealthChecks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"paused":          h.HealthService.Paused(),
+		"checked_proxies": len(h.HealthService.CheckedProxies()),
+	})
+}
+
+// PauseHealthChecks pauses or resumes every health check, e.g. during network maintenance. Paused
+// checks keep their last status; the switch lasts across restarts.
+func (h *Handler) PauseHealthChecks(w http.ResponseWriter, r *http.Request) {
+	var req pauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.CaddyClient.SetHealthChecksPaused(req.Paused); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to save health check state: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	h.HealthService.SetPaused(req.Paused)
+
+	if req.Paused {
+		h.logAudit(r, "PAUSE_HEALTH_CHECKS", "All health checks paused")
+	} else {
+		h.logAudit(r, "RESUME_HEALTH_CHECKS", "All health checks resumed")
+	}
+	h.GetHealthChecks(w, r)
+}
+
+// PauseProxyHealthCheck pauses or resumes the health check of one proxy. Like pausing all checks,
+// it keeps the check's last status.
+func (h *Handler) PauseProxyHealthCheck(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	var req pauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Only the flag changes: the route stays as it is and the check keeps its last status
+	if err := h.CaddyClient.SetProxyHealthCheckPaused(proxy.ID, req.Paused); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save health check state: %v", err)})
+		return
+	}
+	h.HealthService.PauseProxy(proxy.ID, req.Paused)
+
+	if req.Paused {
+		h.logAudit(r, "PAUSE_HEALTH_CHECKS", fmt.Sprintf("Health check of proxy '%s' (%s) paused", proxy.ID, proxy.Domain))
+	} else {
+		h.logAudit(r, "RESUME_HEALTH_CHECKS", fmt.Sprintf("Health check of proxy '%s' (%s) resumed", proxy.ID, proxy.Domain))
+	}
+
+	status, _ := h.HealthService.GetHealthStatus(proxy.ID)
+	writeJSON(w, http.StatusOK, status)
+}
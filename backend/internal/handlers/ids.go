@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/events"
+)
+
+// legacyIDPaths are the API paths followed by a proxy or redirect ID
+var legacyIDPaths = []string{"/api/proxies/", "/api/redirects/", "/api/hooks/deploy/"}
+
+// MigrateIDs renames proxies and redirects with timestamp IDs to readable slugs of their domains
+func (h *Handler) MigrateIDs(w http.ResponseWriter, r *http.Request) {
+	renames, err := h.CaddyClient.MigrateLegacyIDs()
+
+	proxies := 0
+	for _, rename := range renames {
+		if rename.Kind != "proxy" {
+			continue
+		}
+		proxies++
+		h.HealthService.StopHealthCheck(rename.OldID)
+		h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionDeleted, ProxyID: rename.OldID})
+		if proxy, err := h.CaddyClient.GetProxy(rename.NewID); err == nil {
+			h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionCreated, ProxyID: proxy.ID, Domain: proxy.Domain})
+		}
+	}
+	// Restart every check, as the dependencies of other proxies may name renamed ones
+	if config, err := h.CaddyClient.GetConfig(); err == nil && proxies > 0 {
+		for _, proxy := range h.CaddyClient.ParseProxiesFromConfig(config) {
+			if !proxy.HealthCheckEnabled {
+				continue
+			}
+			if err := h.HealthService.StartHealthCheck(proxy); err != nil {
+				fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+			}
+		}
+	}
+
+	if len(renames) > 0 {
+		h.logAudit(r, "MIGRATE_IDS", fmt.Sprintf("Renamed %d proxies and %d redirects to readable IDs", proxies, len(renames)-proxies))
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v (%d renamed before the failure)"}`, err, len(renames)), http.StatusInternalServerError)
+		return
+	}
+
+	if renames == nil {
+		renames = []caddy.IDRename{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"renamed": renames,
+	})
+}
+
+// RedirectLegacyIDs sends requests naming a proxy or redirect by an ID it was renamed from to the
+// same path with its current ID. The 308 status keeps the method and body, so deploy hooks and
+// scripts using old IDs keep working.
+func (h *Handler) RedirectLegacyIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range legacyIDPaths {
+			rest, found := strings.CutPrefix(r.URL.Path, prefix)
+			if !found {
+				continue
+			}
+
+			id, tail, hasTail := strings.Cut(rest, "/")
+			current, renamed := h.CaddyClient.ResolveIDAlias(id)
+			if !renamed {
+				break
+			}
+
+			target := *r.URL
+			target.Path = prefix + current
+			if hasTail {
+				target.Path += "/" + tail
+			}
+			target.RawPath = ""
+			http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+// GetInternalCA returns the internal ACME server state and the CA it issues from
+func (h *Handler) GetInternalCA(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{
+		"acme_server": h.CaddyClient.GetACMEServer(config),
+	}
+
+	// The CA only exists once Caddy has provisioned the PKI app
+	if info, err := h.CaddyClient.GetCAInfo(caddy.InternalCAID); err == nil {
+		response["ca"] = info
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// UpdateInternalCA enables or disables Caddy's built-in ACME server
+func (h *Handler) UpdateInternalCA(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled  bool   `json:"enabled"`
+		Host     string `json:"host"`
+		Lifetime string `json:"lifetime"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !req.Enabled {
+		if err := h.CaddyClient.DisableACMEServer(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to disable ACME server: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+
+		h.logAudit(r, "DISABLE_ACME_SERVER", "Internal ACME server disabled")
+		writeJSON(w, http.StatusOK, caddy.ACMEServerConfig{})
+		return
+	}
+
+	req.Host = strings.TrimSpace(req.Host)
+	if req.Host == "" {
+		http.Error(w, `{"error": "Host is required to enable the ACME server"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Lifetime != "" {
+		if _, err := time.ParseDuration(req.Lifetime); err != nil {
+			http.Error(w, `{"error": "Invalid lifetime duration"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.CaddyClient.EnableACMEServer(req.Host, req.Lifetime); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to enable ACME server: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "ENABLE_ACME_SERVER", fmt.Sprintf("Internal ACME server enabled on '%s'", req.Host))
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.CaddyClient.GetACMEServer(config))
+}
+
+// GetInternalCARoot serves the internal CA root certificate as a PEM download
+func (h *Handler) GetInternalCARoot(w http.ResponseWriter, r *http.Request) {
+	info, err := h.CaddyClient.GetCAInfo(caddy.InternalCAID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get internal CA: %v"}`, err), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="root.crt"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(info.RootCertificate)); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
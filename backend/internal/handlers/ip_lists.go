@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/events"
+	"github.com/sarat/caddyproxymanager/pkg/iplist"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// GetIPLists lists the IP lists imported into a proxy
+func (h *Handler) GetIPLists(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.CaddyClient.GetProxy(id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	lists := h.CaddyClient.IPLists(id)
+	if lists == nil {
+		lists = []models.IPListSource{}
+	}
+	writeJSON(w, http.StatusOK, lists)
+}
+
+// ImportIPList adds the entries of an uploaded list, or of a list fetched from a URL and refreshed
+// on a schedule, to a proxy's allow or block list
+func (h *Handler) ImportIPList(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		Name            string `json:"name"`
+		List            string `json:"list"`             // "allowed" or "blocked"
+		URL             string `json:"url"`              // fetched now and again every refresh_interval
+		RefreshInterval string `json:"refresh_interval"` // e.g. "24h", only for URLs
+		Content         string `json:"content"`          // text of an uploaded list
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, iplist.MaxBytes+64*1024)).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.List != models.IPListAllowed && req.List != models.IPListBlocked {
+		http.Error(w, fmt.Sprintf(`{"error": "list must be %s or %s"}`, models.IPListAllowed, models.IPListBlocked), http.StatusBadRequest)
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if (req.URL == "") == (req.Content == "") {
+		http.Error(w, `{"error": "Either url or content is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	listID, err := auth.GenerateID()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to generate ID: %v", err)})
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+	list := models.IPListSource{
+		ID:        listID,
+		Name:      strings.TrimSpace(req.Name),
+		List:      req.List,
+		UpdatedAt: now,
+	}
+
+	var entries []string
+	if req.URL != "" {
+		interval := caddy.DefaultIPListRefreshInterval
+		if req.RefreshInterval != "" {
+			interval, err = time.ParseDuration(req.RefreshInterval)
+			if err != nil || interval < caddy.MinIPListRefreshInterval {
+				http.Error(w, fmt.Sprintf(`{"error": "refresh_interval must be a duration of at least %s"}`, caddy.MinIPListRefreshInterval), http.StatusBadRequest)
+				return
+			}
+		}
+		list.URL = req.URL
+		list.RefreshInterval = interval.String()
+		list.LastFetchedAt = now
+		if list.Name == "" {
+			list.Name = req.URL
+		}
+
+		entries, err = iplist.NewFetcher(h.OutboundGuard).Fetch(r.Context(), req.URL)
+	} else {
+		if list.Name == "" {
+			list.Name = "Uploaded list"
+		}
+		entries, err = iplist.Parse(strings.NewReader(req.Content))
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid IP list: %v", err)})
+		return
+	}
+
+	if err := h.CaddyClient.SaveIPList(id, list, entries); err != nil {
+		writeCaddyError(w, "Failed to update proxy in Caddy", err)
+		return
+	}
+	list.Entries = len(entries)
+
+	h.logAudit(r, "IMPORT_IP_LIST", fmt.Sprintf("Imported IP list '%s' with %d entries into the %s list of proxy '%s'", list.Name, list.Entries, list.List, id))
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: id, Domain: proxy.Domain})
+
+	writeJSON(w, http.StatusCreated, list)
+}
+
+// RefreshIPList fetches a list imported from a URL now instead of waiting for its next refresh
+func (h *Handler) RefreshIPList(w http.ResponseWriter, r *http.Request) {
+	id, listID := r.PathValue("id"), r.PathValue("listID")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	fetcher := iplist.NewFetcher(h.OutboundGuard)
+	result := h.CaddyClient.RefreshIPList(id, listID, func(url string) ([]string, error) {
+		return fetcher.Fetch(r.Context(), url)
+	})
+	if result.List.ID == "" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": result.Err.Error()})
+		return
+	}
+	if result.Err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to refresh IP list: %v", result.Err)})
+		return
+	}
+
+	if result.Changed {
+		h.logAudit(r, "REFRESH_IP_LIST", fmt.Sprintf("Refreshed IP list '%s' of proxy '%s', now %d entries", result.List.Name, id, result.List.Entries))
+		h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: id, Domain: proxy.Domain})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ip_list": result.List,
+		"changed": result.Changed,
+	})
+}
+
+// DeleteIPList removes an imported list and its entries from a proxy
+func (h *Handler) DeleteIPList(w http.ResponseWriter, r *http.Request) {
+	id, listID := r.PathValue("id"), r.PathValue("listID")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := h.CaddyClient.DeleteIPList(id, listID); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Failed to delete IP list: %v", err)})
+		return
+	}
+
+	h.logAudit(r, "DELETE_IP_LIST", fmt.Sprintf("IP list '%s' removed from proxy '%s'", listID, id))
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: id, Domain: proxy.Domain})
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("IP list %s deleted", listID),
+	})
+}
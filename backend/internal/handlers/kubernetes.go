@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/discovery"
+	"github.com/sarat/caddyproxymanager/pkg/events"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// kubernetesSyncUser marks proxies created by the Kubernetes sync. Only proxies still applied by
+// it are updated, so editing a synced proxy takes it over.
+const kubernetesSyncUser = "kubernetes-sync"
+
+// Kubernetes sync statuses
+const (
+	KubernetesSyncCreated = "created"
+	KubernetesSyncUpdated = "updated"
+	KubernetesSyncInSync  = "in_sync"
+)
+
+// GetKubernetesServices lists the Services found in Kubernetes as candidate upstreams and the
+// Ingress hosts routed to them, with the sync status of annotated Services when sync is on
+func (h *Handler) GetKubernetesServices(w http.ResponseWriter, r *http.Request) {
+	if h.Kubernetes == nil {
+		http.Error(w, `{"error": "Kubernetes discovery is disabled, set KUBERNETES_DISCOVERY=true to enable it"}`, http.StatusForbidden)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Kubernetes.Snapshot())
+}
+
+// SyncKubernetesServices creates a proxy for every Service with a domain annotation and moves the
+// target of proxies it created before when the Service changes. Proxies are never deleted.
+func (h *Handler) SyncKubernetesServices(ctx context.Context, services []discovery.Service) map[string]string {
+	statuses := make(map[string]string)
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		log.Printf("Kubernetes sync skipped, failed to get Caddy config: %v", err)
+		for _, service := range services {
+			if service.Domain != "" {
+				statuses[service.Key()] = fmt.Sprintf("error: failed to get Caddy config: %v", err)
+			}
+		}
+		return statuses
+	}
+	proxies := make(map[string]models.Proxy)
+	for _, proxy := range h.CaddyClient.ParseProxiesFromConfig(config) {
+		proxies[proxy.Domain] = proxy
+	}
+
+	claimed := make(map[string]string)
+	for _, service := range services {
+		if service.Domain == "" || ctx.Err() != nil {
+			continue
+		}
+		key := service.Key()
+		if service.Error != "" {
+			statuses[key] = "error: " + service.Error
+			continue
+		}
+
+		domain, err := hostname.Normalize(service.Domain, true)
+		if err != nil {
+			statuses[key] = fmt.Sprintf("error: %v", err)
+			continue
+		}
+		if owner, exists := claimed[domain]; exists {
+			statuses[key] = fmt.Sprintf("skipped: %s is already synced from %s", domain, owner)
+			continue
+		}
+		claimed[domain] = key
+
+		proxy, exists := proxies[domain]
+		switch {
+		case !exists:
+			statuses[key] = h.createKubernetesProxy(service, domain)
+		case proxy.AppliedBy != kubernetesSyncUser:
+			statuses[key] = fmt.Sprintf("skipped: %s is served by proxy %s, which is managed outside the sync", domain, proxy.ID)
+		default:
+			statuses[key] = h.updateKubernetesProxy(proxy, service)
+		}
+	}
+	return statuses
+}
+
+func (h *Handler) createKubernetesProxy(service discovery.Service, domain string) string {
+	proxy, err := h.buildProxy(proxyRequest{
+		Domain:             domain,
+		TargetURL:          service.Target,
+		SSLMode:            service.SSLMode,
+		HealthCheckEnabled: service.HealthPath != "",
+		HealthCheckPath:    service.HealthPath,
+	})
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	proxy.ID = h.CaddyClient.NewProxyID(proxy.Domain)
+
+	proxy.MarkApplied(kubernetesSyncUser)
+	if err := h.CaddyClient.AddProxy(*proxy); err != nil {
+		log.Printf("Kubernetes sync failed to add proxy for %s: %v", service.Key(), err)
+		return fmt.Sprintf("error: failed to add proxy to Caddy: %v", err)
+	}
+
+	if proxy.HealthCheckEnabled {
+		if err := h.HealthService.StartHealthCheck(*proxy); err != nil {
+			fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+		}
+	}
+
+	if h.AuditService != nil {
+		h.AuditService.Log("KUBERNETES_SYNC", fmt.Sprintf("Proxy '%s' created for domain '%s' from Service %s", proxy.ID, proxy.Domain, service.Key()), kubernetesSyncUser, kubernetesSyncUser, "")
+	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionCreated, ProxyID: proxy.ID, Domain: proxy.Domain})
+	return KubernetesSyncCreated
+}
+
+func (h *Handler) updateKubernetesProxy(proxy models.Proxy, service discovery.Service) string {
+	target, err := caddy.NormalizeTargetURL(service.Target)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	if proxy.TargetURL == target {
+		return KubernetesSyncInSync
+	}
+
+	previousTarget := proxy.TargetURL
+	proxy.TargetURL = target
+	if len(proxy.TargetURLs) > 0 {
+		proxy.TargetURLs[0] = target
+	}
+
+	proxy.UpdateTimestamp()
+	proxy.MarkApplied(kubernetesSyncUser)
+	if err := h.CaddyClient.UpdateProxy(proxy); err != nil {
+		log.Printf("Kubernetes sync failed to update proxy %s: %v", proxy.ID, err)
+		return fmt.Sprintf("error: failed to update proxy in Caddy: %v", err)
+	}
+
+	if proxy.HealthCheckEnabled {
+		if err := h.HealthService.StartHealthCheck(proxy); err != nil {
+			fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+		}
+	}
+
+	if h.AuditService != nil {
+		h.AuditService.Log("KUBERNETES_SYNC", fmt.Sprintf("Proxy '%s' target changed from '%s' to '%s' by Service %s", proxy.ID, previousTarget, proxy.TargetURL, service.Key()), kubernetesSyncUser, kubernetesSyncUser, "")
+	}
+	h.Events.Publish(events.TypeProxy, events.ProxyChange{Action: events.ActionUpdated, ProxyID: proxy.ID, Domain: proxy.Domain})
+	return KubernetesSyncUpdated
+}
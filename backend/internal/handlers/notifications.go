@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/notify"
+)
+
+type NotificationHandler struct {
+	notifier     *notify.Notifier
+	caddyClient  *caddy.Client
+	auditService *audit.Service
+}
+
+func NewNotificationHandler(notifier *notify.Notifier, caddyClient *caddy.Client, auditService *audit.Service) *NotificationHandler {
+	return &NotificationHandler{
+		notifier:     notifier,
+		caddyClient:  caddyClient,
+		auditService: auditService,
+	}
+}
+
+// notificationChannelRequest is the body of requests creating or updating a notification channel
+type notificationChannelRequest struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Enabled  *bool             `json:"enabled"` // defaults to true
+	Events   []string          `json:"events"`
+	Settings map[string]string `json:"settings"`
+	Digest   string            `json:"digest"` // e.g. "1h", empty to send alerts as they happen
+}
+
+// notificationChannelResponse is a channel without its secret settings
+type notificationChannelResponse struct {
+	models.NotificationChannel
+	SecretsSet []string `json:"secrets_set"` // keys of the secret settings that are set
+}
+
+// notificationChannelResponseFor builds the response for a channel
+func notificationChannelResponseFor(channel models.NotificationChannel) notificationChannelResponse {
+	redacted, secretsSet := notify.Redacted(channel)
+	if redacted.Events == nil {
+		redacted.Events = []string{}
+	}
+	return notificationChannelResponse{NotificationChannel: redacted, SecretsSet: secretsSet}
+}
+
+// decodeNotificationChannelRequest reads a channel from the request body, validated against the
+// channel it replaces, if any
+func decodeNotificationChannelRequest(r *http.Request, previous *models.NotificationChannel) (*models.NotificationChannel, error) {
+	var channelReq notificationChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&channelReq); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+
+	enabled := channelReq.Enabled == nil || *channelReq.Enabled
+	channel := models.NewNotificationChannel(channelReq.Name, channelReq.Type, enabled, channelReq.Events, channelReq.Settings)
+	channel.Digest = channelReq.Digest
+	if err := notify.NormalizeChannel(channel, previous); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// GetNotificationTypes returns the supported channel types with their settings, and the alert and
+// configuration change events channels can subscribe to
+func (h *NotificationHandler) GetNotificationTypes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"types":         notify.ChannelTypes(),
+		"events":        notify.Events,
+		"change_events": notify.ChangeEvents,
+	})
+}
+
+// GetNotificationChannels returns the notification channels
+func (h *NotificationHandler) GetNotificationChannels(w http.ResponseWriter, r *http.Request) {
+	channels := h.caddyClient.NotificationChannels()
+	responses := make([]notificationChannelResponse, 0, len(channels))
+	for _, channel := range channels {
+		responses = append(responses, notificationChannelResponseFor(channel))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"channels": responses,
+		"count":    len(responses),
+	})
+}
+
+// GetNotificationChannel returns a single notification channel
+func (h *NotificationHandler) GetNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.caddyClient.GetNotificationChannel(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Notification channel not found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, notificationChannelResponseFor(*channel))
+}
+
+// CreateNotificationChannel creates a new notification channel
+func (h *NotificationHandler) CreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := decodeNotificationChannelRequest(r, nil)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	channel.ID = h.caddyClient.NewNotificationChannelID(channel.Name)
+
+	if err := h.caddyClient.SaveNotificationChannel(*channel); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save notification channel: %v", err)})
+		return
+	}
+
+	h.logAudit(r, "CREATE_NOTIFICATION_CHANNEL", fmt.Sprintf("Notification channel '%s' (%s) created", channel.ID, channel.Type))
+	writeJSON(w, http.StatusCreated, notificationChannelResponseFor(*channel))
+}
+
+// UpdateNotificationChannel replaces a notification channel. Secret settings left empty keep their
+// value.
+func (h *NotificationHandler) UpdateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, err := h.caddyClient.GetNotificationChannel(id)
+	if err != nil {
+		http.Error(w, `{"error": "Notification channel not found"}`, http.StatusNotFound)
+		return
+	}
+
+	channel, err := decodeNotificationChannelRequest(r, existing)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	channel.ID = id
+	channel.CreatedAt = existing.CreatedAt
+
+	if err := h.caddyClient.SaveNotificationChannel(*channel); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save notification channel: %v", err)})
+		return
+	}
+
+	h.logAudit(r, "UPDATE_NOTIFICATION_CHANNEL", fmt.Sprintf("Notification channel '%s' (%s) updated, enabled: %t", channel.ID, channel.Type, channel.Enabled))
+	writeJSON(w, http.StatusOK, notificationChannelResponseFor(*channel))
+}
+
+// DeleteNotificationChannel removes a notification channel
+func (h *NotificationHandler) DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.caddyClient.DeleteNotificationChannel(id); err != nil {
+		http.Error(w, `{"error": "Notification channel not found"}`, http.StatusNotFound)
+		return
+	}
+
+	h.logAudit(r, "DELETE_NOTIFICATION_CHANNEL", fmt.Sprintf("Notification channel '%s' deleted", id))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Notification channel %s deleted successfully", id),
+	})
+}
+
+// TestNotificationChannel sends a test alert to a channel, even a disabled one, and reports whether
+// it was delivered
+func (h *NotificationHandler) TestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.caddyClient.GetNotificationChannel(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Notification channel not found"}`, http.StatusNotFound)
+		return
+	}
+
+	err = h.notifier.Send(r.Context(), *channel, notify.Message{
+		Event: notify.EventTest,
+		Title: "Test notification from Caddy Proxy Manager",
+		Text:  fmt.Sprintf("Channel '%s' is set up to receive alerts.", channel.Name),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to send test notification: %v", err)})
+		return
+	}
+
+	h.logAudit(r, "TEST_NOTIFICATION_CHANNEL", fmt.Sprintf("Test notification sent to channel '%s'", channel.ID))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": "Test notification sent",
+	})
+}
+
+func (h *NotificationHandler) logAudit(r *http.Request, action, details string) {
+	if h.auditService == nil {
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	username := "unknown"
+	userID := "unknown"
+	if user != nil {
+		username = user.Username
+		userID = user.ID
+	}
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.auditService.Log(action, details, userID, username, ipAddress)
+}
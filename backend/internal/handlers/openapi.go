@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI is the OpenAPI 3.1 description of the management API. cmd/server's tests check that
+// it lists exactly the routes setupRoutes registers.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// GetOpenAPI serves the OpenAPI description, for generating clients and API docs
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(OpenAPI); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+)
+
+type PageHandler struct {
+	store        *pages.Store
+	caddyClient  *caddy.Client
+	auditService *audit.Service
+}
+
+func NewPageHandler(store *pages.Store, caddyClient *caddy.Client, auditService *audit.Service) *PageHandler {
+	return &PageHandler{
+		store:        store,
+		caddyClient:  caddyClient,
+		auditService: auditService,
+	}
+}
+
+// GetPages lists the stored page templates
+func (h *PageHandler) GetPages(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.store.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to list page templates: %v", err)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"templates": templates,
+		"kinds":     pages.Kinds,
+		"count":     len(templates),
+	})
+}
+
+// GetPage returns a page template with its body
+func (h *PageHandler) GetPage(w http.ResponseWriter, r *http.Request) {
+	template, err := h.store.Get(r.PathValue("kind"), strings.ToLower(r.PathValue("language")))
+	if pages.IsNotExist(err) {
+		http.Error(w, `{"error": "Page template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, template)
+}
+
+// SavePage stores a page template and has Caddy serve it
+func (h *PageHandler) SavePage(w http.ResponseWriter, r *http.Request) {
+	kind, language := r.PathValue("kind"), strings.ToLower(r.PathValue("language"))
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		http.Error(w, `{"error": "Template body is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Save(kind, language, req.Body); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	applied := h.apply()
+	h.logAudit(r, "SAVE_PAGE_TEMPLATE", fmt.Sprintf("Page template '%s/%s' saved", kind, language))
+
+	template, err := h.store.Get(kind, language)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"template": template,
+		"applied":  applied,
+	})
+}
+
+// DeletePage removes a page template, falling back to the default variant or Caddy's own response
+func (h *PageHandler) DeletePage(w http.ResponseWriter, r *http.Request) {
+	kind, language := r.PathValue("kind"), strings.ToLower(r.PathValue("language"))
+
+	err := h.store.Delete(kind, language)
+	if pages.IsNotExist(err) {
+		http.Error(w, `{"error": "Page template not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	applied := h.apply()
+	h.logAudit(r, "DELETE_PAGE_TEMPLATE", fmt.Sprintf("Page template '%s/%s' deleted", kind, language))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Page template deleted",
+		"applied": applied,
+	})
+}
+
+// apply loads the templates into Caddy. A failure isn't fatal since the next config change
+// applies them too.
+func (h *PageHandler) apply() bool {
+	if err := h.caddyClient.ApplyPages(); err != nil {
+		fmt.Printf("Warning: Failed to apply page templates: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func (h *PageHandler) logAudit(r *http.Request, action, details string) {
+	if h.auditService == nil {
+		return
+	}
+
+	user := auth.GetUserFromContext(r.Context())
+	username := "unknown"
+	userID := "unknown"
+	if user != nil {
+		username = user.Username
+		userID = user.ID
+	}
+	ipAddress := r.RemoteAddr
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		ipAddress = ip
+	}
+	h.auditService.Log(action, details, userID, username, ipAddress)
+}
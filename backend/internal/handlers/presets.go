@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/presets"
+)
+
+type PresetHandler struct {
+	store *presets.Store
+}
+
+func NewPresetHandler(store *presets.Store) *PresetHandler {
+	return &PresetHandler{
+		store: store,
+	}
+}
+
+// GetPresets lists the application presets loaded from the presets directory
+func (h *PresetHandler) GetPresets(w http.ResponseWriter, r *http.Request) {
+	list := h.store.List()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"presets": list,
+		"count":   len(list),
+	})
+}
+
+// GetPreset returns a single application preset
+func (h *PresetHandler) GetPreset(w http.ResponseWriter, r *http.Request) {
+	preset, exists := h.store.Get(r.PathValue("id"))
+	if !exists {
+		http.Error(w, `{"error": "Preset not found"}`, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preset)
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	defaultProxiesPerPage = 50
+	maxProxiesPerPage     = 500
+)
+
+// listOptions are the pagination, sorting and field selection query parameters of a list endpoint
+type listOptions struct {
+	paginate bool
+	page     int
+	perPage  int
+	sort     string   // key to order by, prefixed with "-" for descending order; config order when empty
+	fields   []string // JSON field names to include, all when empty
+}
+
+// parseListOptions reads page, per_page (or its alias limit), sort and fields. Pagination only
+// applies when page or per_page is given, so existing clients keep getting the full list.
+func parseListOptions(query url.Values) (listOptions, error) {
+	options := listOptions{page: 1, perPage: defaultProxiesPerPage, sort: strings.TrimSpace(query.Get("sort"))}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return options, fmt.Errorf("page must be a positive integer")
+		}
+		options.page = page
+		options.paginate = true
+	}
+
+	for _, name := range []string{"per_page", "limit"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 || perPage > maxProxiesPerPage {
+			return options, fmt.Errorf("%s must be between 1 and %d", name, maxProxiesPerPage)
+		}
+		options.perPage = perPage
+		options.paginate = true
+		break
+	}
+
+	for _, field := range strings.Split(query.Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			options.fields = append(options.fields, field)
+		}
+	}
+
+	return options, nil
+}
+
+// proxyFilter narrows the proxy list by the q, status, ssl_mode and tag query parameters
+type proxyFilter struct {
+	query    string          // case-insensitive substring of the ID, domain, targets, tags or notes
+	statuses map[string]bool // lower-cased health statuses, e.g. "unhealthy"
+	sslMode  string
+	tags     []string // tags a proxy must all carry
+}
+
+// proxyListParams are the query parameters the proxy list accepts, which saved searches may store
+var proxyListParams = []string{"q", "status", "ssl_mode", "tag", "sort", "fields", "per_page", "limit"}
+
+// parseProxyFilter reads the proxy list filters. status and tag take comma-separated lists.
+func parseProxyFilter(query url.Values) proxyFilter {
+	return proxyFilter{
+		query:    strings.ToLower(strings.TrimSpace(query.Get("q"))),
+		statuses: parseStatuses(query.Get("status")),
+		sslMode:  strings.TrimSpace(query.Get("ssl_mode")),
+		tags:     parseTagFilter(query.Get("tag")),
+	}
+}
+
+// parseTagFilter reads a comma-separated list of tags, in the form tags are stored in
+func parseTagFilter(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// hasTags reports whether tagged carries every tag in required
+func hasTags(tagged, required []string) bool {
+	for _, tag := range required {
+		if !slices.Contains(tagged, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseStatuses reads a comma-separated list of statuses, returning nil when it is empty
+func parseStatuses(value string) map[string]bool {
+	var statuses map[string]bool
+	for _, status := range strings.Split(value, ",") {
+		if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+			if statuses == nil {
+				statuses = make(map[string]bool)
+			}
+			statuses[status] = true
+		}
+	}
+	return statuses
+}
+
+// matches reports whether a proxy, with its health status set, passes the filter
+func (f proxyFilter) matches(proxy models.Proxy) bool {
+	if f.sslMode != "" && proxy.SSLMode != f.sslMode {
+		return false
+	}
+	if f.statuses != nil && !f.statuses[strings.ToLower(proxy.Status)] {
+		return false
+	}
+	if !hasTags(proxy.Tags, f.tags) {
+		return false
+	}
+	if f.query == "" {
+		return true
+	}
+
+	fields := append([]string{proxy.ID, proxy.Domain, proxy.DisplayDomain, proxy.TargetURL, proxy.Notes}, proxy.TargetURLs...)
+	fields = append(fields, proxy.Tags...)
+	return slices.ContainsFunc(fields, func(field string) bool {
+		return strings.Contains(strings.ToLower(field), f.query)
+	})
+}
+
+// redirectFilter narrows the redirect list by the q, status and tag query parameters
+type redirectFilter struct {
+	query    string          // case-insensitive substring of the ID, a source domain, the destination, tags or notes
+	statuses map[string]bool // lower-cased statuses, e.g. "active"
+	tags     []string        // tags a redirect must all carry
+}
+
+func parseRedirectFilter(query url.Values) redirectFilter {
+	return redirectFilter{
+		query:    strings.ToLower(strings.TrimSpace(query.Get("q"))),
+		statuses: parseStatuses(query.Get("status")),
+		tags:     parseTagFilter(query.Get("tag")),
+	}
+}
+
+func (f redirectFilter) matches(redirect models.Redirect) bool {
+	if f.statuses != nil && !f.statuses[strings.ToLower(redirect.Status)] {
+		return false
+	}
+	if !hasTags(redirect.Tags, f.tags) {
+		return false
+	}
+	if f.query == "" {
+		return true
+	}
+
+	fields := append([]string{redirect.ID, redirect.DestinationURL, redirect.Notes}, redirect.SourceDomains...)
+	fields = append(fields, redirect.Tags...)
+	return slices.ContainsFunc(fields, func(field string) bool {
+		return strings.Contains(strings.ToLower(field), f.query)
+	})
+}
+
+// proxySortKeys are the keys the proxy list can be sorted by
+var proxySortKeys = map[string]func(a, b models.Proxy) int{
+	"id":               func(a, b models.Proxy) int { return cmp.Compare(a.ID, b.ID) },
+	"domain":           func(a, b models.Proxy) int { return cmp.Compare(a.Domain, b.Domain) },
+	"status":           func(a, b models.Proxy) int { return cmp.Compare(a.Status, b.Status) },
+	"ssl_mode":         func(a, b models.Proxy) int { return cmp.Compare(a.SSLMode, b.SSLMode) },
+	"created_at":       func(a, b models.Proxy) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) },
+	"updated_at":       func(a, b models.Proxy) int { return cmp.Compare(a.UpdatedAt, b.UpdatedAt) },
+	"last_applied_at":  func(a, b models.Proxy) int { return cmp.Compare(a.LastAppliedAt, b.LastAppliedAt) },
+	"response_time_ms": func(a, b models.Proxy) int { return cmp.Compare(a.ResponseTimeMs, b.ResponseTimeMs) },
+}
+
+// redirectSortKeys are the keys the redirect list can be sorted by; domain is the first source domain
+var redirectSortKeys = map[string]func(a, b models.Redirect) int{
+	"id":              func(a, b models.Redirect) int { return cmp.Compare(a.ID, b.ID) },
+	"domain":          func(a, b models.Redirect) int { return cmp.Compare(firstDomain(a), firstDomain(b)) },
+	"destination_url": func(a, b models.Redirect) int { return cmp.Compare(a.DestinationURL, b.DestinationURL) },
+	"redirect_code":   func(a, b models.Redirect) int { return cmp.Compare(a.RedirectCode, b.RedirectCode) },
+	"created_at":      func(a, b models.Redirect) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) },
+	"updated_at":      func(a, b models.Redirect) int { return cmp.Compare(a.UpdatedAt, b.UpdatedAt) },
+	"last_applied_at": func(a, b models.Redirect) int { return cmp.Compare(a.LastAppliedAt, b.LastAppliedAt) },
+}
+
+func firstDomain(redirect models.Redirect) string {
+	if len(redirect.SourceDomains) == 0 {
+		return ""
+	}
+	return redirect.SourceDomains[0]
+}
+
+// sortList orders items by the sort option, keeping the config order of equal items. Unknown keys
+// are rejected.
+func sortList[T any](items []T, sort string, keys map[string]func(a, b T) int) error {
+	if sort == "" {
+		return nil
+	}
+
+	key, descending := strings.CutPrefix(sort, "-")
+	compare, exists := keys[key]
+	if !exists {
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("sort must be one of %s, prefixed with - for descending order", strings.Join(names, ", "))
+	}
+
+	slices.SortStableFunc(items, func(a, b T) int {
+		if descending {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+	return nil
+}
+
+// listResponse builds the response of a list endpoint: the requested page of items under key,
+// reduced to the selected fields, with the pagination totals when paginated
+func listResponse[T any](key string, items []T, options listOptions) (map[string]any, error) {
+	total := len(items)
+	start, end := options.pageBounds(total)
+	items = items[start:end]
+
+	response := map[string]any{
+		key:     items,
+		"count": len(items),
+	}
+
+	if len(options.fields) > 0 {
+		selected, err := selectFields(items, options.fields)
+		if err != nil {
+			return nil, err
+		}
+		response[key] = selected
+	}
+
+	if options.paginate {
+		response["page"] = options.page
+		response["per_page"] = options.perPage
+		response["total"] = total
+		response["total_pages"] = (total + options.perPage - 1) / options.perPage
+	}
+
+	return response, nil
+}
+
+// pageBounds returns the slice bounds of the requested page within total items
+func (o listOptions) pageBounds(total int) (int, int) {
+	if !o.paginate {
+		return 0, total
+	}
+
+	start := min((o.page-1)*o.perPage, total)
+	end := min(start+o.perPage, total)
+	return start, end
+}
+
+// selectFields reduces each item to the requested JSON fields. Unknown field names are rejected
+// so typos don't silently return empty objects.
+func selectFields[T any](items []T, fields []string) ([]map[string]any, error) {
+	known := jsonFieldNames(reflect.TypeFor[T]())
+	for _, field := range fields {
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field: %s", field)
+		}
+	}
+
+	selected := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var full map[string]any
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, err
+		}
+
+		partial := make(map[string]any, len(fields))
+		for _, field := range fields {
+			if value, exists := full[field]; exists {
+				partial[field] = value
+			}
+		}
+		selected = append(selected, partial)
+	}
+
+	return selected, nil
+}
+
+// jsonFieldNames returns the JSON names of a struct type's fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
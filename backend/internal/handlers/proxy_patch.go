@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PatchProxy changes some fields of a proxy, leaving the others as they are. The body is a JSON
+// merge patch (RFC 7396): fields set to null are reset to their default, and objects such as
+// custom_headers are merged key by key. The result is validated like a full update.
+func (h *Handler) PatchProxy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+		return
+	}
+
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		http.Error(w, `{"error": "Invalid JSON, expected an object of the fields to change"}`, http.StatusBadRequest)
+		return
+	}
+
+	current, err := json.Marshal(existing)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to encode proxy: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	var document map[string]any
+	if err := json.Unmarshal(current, &document); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to encode proxy: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to apply patch: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	var proxyReq proxyRequest
+	if err := json.Unmarshal(merged, &proxyReq); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid patch: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	proxy, err := h.buildProxy(proxyReq)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	h.replaceProxy(w, r, id, proxy)
+}
+
+// mergePatch applies a JSON merge patch to target: null removes a member, objects are merged
+// recursively and any other value replaces the member
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any)
+	}
+	for key, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(target, key)
+		case map[string]any:
+			existing, _ := target[key].(map[string]any)
+			target[key] = mergePatch(existing, value)
+		default:
+			target[key] = value
+		}
+	}
+	return target
+}
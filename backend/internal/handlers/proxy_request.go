@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/health"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// proxyRequest is the request body accepted when creating or updating a proxy
+type proxyRequest struct {
+	Domain                    string                 `json:"domain"`
+	TargetURL                 string                 `json:"target_url"`
+	TargetURLs                []string               `json:"target_urls"`
+	LBPolicy                  string                 `json:"lb_policy"`
+	BackupTargetURL           string                 `json:"backup_target_url"`
+	SSLMode                   string                 `json:"ssl_mode"`
+	ChallengeType             string                 `json:"challenge_type"`
+	DNSProvider               string                 `json:"dns_provider"`
+	DNSCredentials            map[string]string      `json:"dns_credentials"`
+	CustomHeaders             map[string]string      `json:"custom_headers"`
+	BasicAuth                 *models.BasicAuth      `json:"basic_auth"`
+	WAF                       *models.WAFSettings    `json:"waf"`
+	CustomCaddyJSON           string                 `json:"custom_caddy_json"`
+	HealthCheckEnabled        bool                   `json:"health_check_enabled"`
+	HealthCheckType           string                 `json:"health_check_type"`
+	HealthCheckInterval       string                 `json:"health_check_interval"`
+	HealthCheckPath           string                 `json:"health_check_path"`
+	HealthCheckExpectedStatus int                    `json:"health_check_expected_status"`
+	HealthCheckHeaders        map[string]string      `json:"health_check_headers"`
+	HealthCheckUserAgent      string                 `json:"health_check_user_agent"`
+	HealthCheckMethod         string                 `json:"health_check_method"`
+	HealthCheckRequestBody    string                 `json:"health_check_request_body"`
+	HealthCheckStatusRange    string                 `json:"health_check_status_range"`
+	HealthCheckExpectedBody   string                 `json:"health_check_expected_body"`
+	HealthCheckBodyRegex      bool                   `json:"health_check_body_regex"`
+	HealthCheckViaCaddy       bool                   `json:"health_check_via_caddy"`
+	HealthCheckPaused         bool                   `json:"health_check_paused"`
+	AccessLog                 string                 `json:"access_log"`
+	AllowedIPs                []string               `json:"allowed_ips"`
+	BlockedIPs                []string               `json:"blocked_ips"`
+	IPExceptionPaths          []string               `json:"ip_exception_paths"`
+	AccessListIDs             []string               `json:"access_list_ids"`
+	BandwidthLimit            int64                  `json:"bandwidth_limit"`
+	GRPC                      bool                   `json:"grpc"`
+	WebSocket                 *models.WebSocket      `json:"websocket"`
+	Timeouts                  *models.Timeouts       `json:"timeouts"`
+	UpstreamHealth            *models.UpstreamHealth `json:"upstream_health"`
+	FlushInterval             string                 `json:"flush_interval"`
+	MaxBodySize               int64                  `json:"max_body_size"`
+	UpstreamSNI               string                 `json:"upstream_sni"`
+	PreserveHost              bool                   `json:"preserve_host"`
+	UpstreamType              string                 `json:"upstream_type"`
+	FastCGIRoot               string                 `json:"fastcgi_root"`
+	CanonicalRedirect         bool                   `json:"canonical_redirect"`
+	DependsOn                 []string               `json:"depends_on"`
+	Links                     *models.ProxyLinks     `json:"links"`
+	Isolated                  bool                   `json:"isolated"`
+	IsolatedListen            []string               `json:"isolated_listen"`
+	PathRules                 []models.PathRule      `json:"path_rules"`
+	Tags                      []string               `json:"tags"`
+	Notes                     string                 `json:"notes"`
+}
+
+const (
+	maxTags       = 20
+	maxTagLength  = 64
+	maxNotesBytes = 4096
+)
+
+// parseProxyRequest decodes and validates a proxy request body, returning a new proxy built from it
+func (h *Handler) parseProxyRequest(r *http.Request) (*models.Proxy, error) {
+	var proxyReq proxyRequest
+	if err := json.NewDecoder(r.Body).Decode(&proxyReq); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+
+	return h.buildProxy(proxyReq)
+}
+
+// buildProxy validates a decoded proxy request and builds a new proxy from it
+func (h *Handler) buildProxy(proxyReq proxyRequest) (*models.Proxy, error) {
+	if err := normalizeTargets(&proxyReq); err != nil {
+		return nil, err
+	}
+
+	// Validate required fields
+	if proxyReq.Domain == "" || proxyReq.TargetURL == "" {
+		return nil, fmt.Errorf("Domain and target_url are required")
+	}
+	domain, err := hostname.Normalize(proxyReq.Domain, true)
+	if err != nil {
+		return nil, err
+	}
+	proxyReq.Domain = domain
+
+	// Set defaults if not provided
+	if proxyReq.SSLMode == "" {
+		proxyReq.SSLMode = SSLModeAuto
+	}
+	if proxyReq.ChallengeType == "" {
+		proxyReq.ChallengeType = "http"
+	}
+
+	switch proxyReq.SSLMode {
+	case SSLModeAuto, SSLModeNone, SSLModeCustom, SSLModeInternal, SSLModeExisting:
+	default:
+		return nil, fmt.Errorf("Unsupported SSL mode: %s", proxyReq.SSLMode)
+	}
+
+	// Validate DNS challenge configuration
+	if proxyReq.SSLMode == SSLModeAuto && proxyReq.ChallengeType == "dns" {
+		if proxyReq.DNSProvider == "" {
+			return nil, fmt.Errorf("DNS provider is required for DNS challenge")
+		}
+
+		// Use the domain's registered acme-dns account unless credentials were given
+		if proxyReq.DNSProvider == caddy.DNSProviderACMEDNS && len(proxyReq.DNSCredentials) == 0 {
+			if credentials, exists := h.CaddyClient.ACMEDNSCredentials(proxyReq.Domain); exists {
+				proxyReq.DNSCredentials = credentials
+			}
+		}
+
+		// Validate DNS credentials based on provider
+		if err := h.validateDNSCredentials(proxyReq.DNSProvider, proxyReq.DNSCredentials); err != nil {
+			return nil, err
+		}
+	}
+
+	switch proxyReq.UpstreamType {
+	case "", caddy.UpstreamTypeHTTP:
+		proxyReq.UpstreamType = caddy.UpstreamTypeHTTP
+	case caddy.UpstreamTypeFastCGI:
+		if proxyReq.FastCGIRoot == "" {
+			return nil, fmt.Errorf("fastcgi_root is required for fastcgi upstreams")
+		}
+		if proxyReq.GRPC {
+			return nil, fmt.Errorf("gRPC cannot be combined with fastcgi upstreams")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported upstream type: %s", proxyReq.UpstreamType)
+	}
+
+	if proxyReq.UpstreamType == caddy.UpstreamTypeHTTP {
+		if err := normalizeTargetURLs(&proxyReq); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(proxyReq.TargetURLs) > 1 {
+		if proxyReq.UpstreamType == caddy.UpstreamTypeFastCGI {
+			return nil, fmt.Errorf("multiple target URLs are not supported for fastcgi upstreams")
+		}
+		if proxyReq.BackupTargetURL != "" {
+			return nil, fmt.Errorf("backup_target_url cannot be combined with multiple target URLs")
+		}
+		// All upstreams share one transport, so they must use the same scheme
+		for _, target := range proxyReq.TargetURLs[1:] {
+			if strings.HasPrefix(proxyReq.TargetURL, "https://") != strings.HasPrefix(target, "https://") {
+				return nil, fmt.Errorf("target URLs must all use http or all use https")
+			}
+		}
+	}
+
+	if proxyReq.BackupTargetURL != "" {
+		if proxyReq.UpstreamType == caddy.UpstreamTypeFastCGI {
+			return nil, fmt.Errorf("backup targets are not supported for fastcgi upstreams")
+		}
+		// Primary and backup share one transport, so they must use the same scheme
+		if strings.HasPrefix(proxyReq.TargetURL, "https://") != strings.HasPrefix(proxyReq.BackupTargetURL, "https://") {
+			return nil, fmt.Errorf("target_url and backup_target_url must both use http or both use https")
+		}
+	}
+
+	for _, path := range proxyReq.IPExceptionPaths {
+		if !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			return nil, fmt.Errorf("IP exception paths must start with /: %s", path)
+		}
+	}
+
+	for _, id := range proxyReq.AccessListIDs {
+		if _, err := h.CaddyClient.GetAccessList(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if proxyReq.CanonicalRedirect && caddy.CanonicalPartnerDomain(proxyReq.Domain) == "" {
+		return nil, fmt.Errorf("canonical_redirect needs a domain name with a www/apex counterpart")
+	}
+
+	for name := range proxyReq.HealthCheckHeaders {
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("Invalid health check header name: %s", name)
+		}
+	}
+
+	proxyReq.UpstreamSNI = strings.TrimSpace(proxyReq.UpstreamSNI)
+	if proxyReq.UpstreamSNI != "" {
+		if proxyReq.UpstreamType == caddy.UpstreamTypeFastCGI || !strings.HasPrefix(proxyReq.TargetURL, "https://") {
+			return nil, fmt.Errorf("upstream_sni needs an https target URL")
+		}
+		if strings.ContainsAny(proxyReq.UpstreamSNI, " /:") {
+			return nil, fmt.Errorf("upstream_sni must be a host name without scheme or port")
+		}
+	}
+
+	if proxyReq.Links, err = normalizeProxyLinks(proxyReq.Links); err != nil {
+		return nil, err
+	}
+
+	if err := caddy.NormalizeWebSocket(proxyReq.WebSocket, proxyReq.UpstreamType); err != nil {
+		return nil, err
+	}
+
+	if err := caddy.NormalizeWAF(proxyReq.WAF); err != nil {
+		return nil, err
+	}
+
+	if proxyReq.Tags, err = normalizeTags(proxyReq.Tags); err != nil {
+		return nil, err
+	}
+	if proxyReq.Notes, err = normalizeNotes(proxyReq.Notes); err != nil {
+		return nil, err
+	}
+
+	if proxyReq.BandwidthLimit < 0 {
+		return nil, fmt.Errorf("bandwidth_limit must not be negative")
+	}
+	if !caddy.ValidAccessLog(proxyReq.AccessLog) {
+		return nil, fmt.Errorf("Unsupported access_log: %s (use %s, or leave it empty for none)", proxyReq.AccessLog, strings.Join(caddy.AccessLogModes, ", "))
+	}
+
+	if proxyReq.Isolated {
+		if err := caddy.ValidateListenAddresses(proxyReq.IsolatedListen); err != nil {
+			return nil, err
+		}
+	} else {
+		proxyReq.IsolatedListen = nil
+	}
+
+	proxy := models.NewProxy(proxyReq.Domain, proxyReq.TargetURL, proxyReq.SSLMode)
+	if display := hostname.Display(proxy.Domain); display != proxy.Domain {
+		proxy.DisplayDomain = display
+	}
+	proxy.BackupTargetURL = proxyReq.BackupTargetURL
+	proxy.TargetURLs = proxyReq.TargetURLs
+	proxy.LBPolicy = proxyReq.LBPolicy
+	proxy.ChallengeType = proxyReq.ChallengeType
+	proxy.DNSProvider = proxyReq.DNSProvider
+	proxy.DNSCredentials = proxyReq.DNSCredentials
+	proxy.CustomHeaders = proxyReq.CustomHeaders
+	proxy.BasicAuth = proxyReq.BasicAuth
+	proxy.WAF = proxyReq.WAF
+	proxy.CustomCaddyJSON = proxyReq.CustomCaddyJSON
+	proxy.HealthCheckEnabled = proxyReq.HealthCheckEnabled
+	proxy.HealthCheckType = proxyReq.HealthCheckType
+	if proxyReq.HealthCheckInterval != "" {
+		proxy.HealthCheckInterval = proxyReq.HealthCheckInterval
+	}
+	if proxyReq.HealthCheckPath != "" {
+		proxy.HealthCheckPath = proxyReq.HealthCheckPath
+	}
+	if proxyReq.HealthCheckExpectedStatus != 0 {
+		proxy.HealthCheckExpectedStatus = proxyReq.HealthCheckExpectedStatus
+	}
+	proxy.HealthCheckHeaders = proxyReq.HealthCheckHeaders
+	proxy.HealthCheckUserAgent = proxyReq.HealthCheckUserAgent
+	proxy.HealthCheckMethod = proxyReq.HealthCheckMethod
+	proxy.HealthCheckRequestBody = proxyReq.HealthCheckRequestBody
+	proxy.HealthCheckStatusRange = proxyReq.HealthCheckStatusRange
+	proxy.HealthCheckExpectedBody = proxyReq.HealthCheckExpectedBody
+	proxy.HealthCheckBodyRegex = proxyReq.HealthCheckBodyRegex
+	proxy.HealthCheckViaCaddy = proxyReq.HealthCheckViaCaddy
+	proxy.HealthCheckPaused = proxyReq.HealthCheckPaused
+	proxy.AccessLog = proxyReq.AccessLog
+	proxy.AllowedIPs = proxyReq.AllowedIPs
+	proxy.BlockedIPs = proxyReq.BlockedIPs
+	proxy.IPExceptionPaths = proxyReq.IPExceptionPaths
+	proxy.AccessListIDs = proxyReq.AccessListIDs
+	proxy.BandwidthLimit = proxyReq.BandwidthLimit
+	proxy.GRPC = proxyReq.GRPC
+	proxy.WebSocket = proxyReq.WebSocket
+	proxy.Timeouts = proxyReq.Timeouts
+	proxy.UpstreamHealth = proxyReq.UpstreamHealth
+	proxy.FlushInterval = proxyReq.FlushInterval
+	proxy.MaxBodySize = proxyReq.MaxBodySize
+	proxy.UpstreamSNI = proxyReq.UpstreamSNI
+	proxy.PreserveHost = proxyReq.PreserveHost
+	proxy.UpstreamType = proxyReq.UpstreamType
+	proxy.FastCGIRoot = proxyReq.FastCGIRoot
+	proxy.CanonicalRedirect = proxyReq.CanonicalRedirect
+	proxy.DependsOn = proxyReq.DependsOn
+	proxy.Links = proxyReq.Links
+	proxy.Isolated = proxyReq.Isolated
+	proxy.IsolatedListen = proxyReq.IsolatedListen
+	proxy.Tags = proxyReq.Tags
+	proxy.Notes = proxyReq.Notes
+	if proxy.PathRules, err = caddy.NormalizePathRules(proxyReq.PathRules); err != nil {
+		return nil, err
+	}
+	if err := caddy.NormalizeTimeouts(proxy); err != nil {
+		return nil, err
+	}
+	if err := health.NormalizeCheckType(proxy); err != nil {
+		return nil, err
+	}
+	if err := caddy.NormalizeUpstreamHealth(proxy); err != nil {
+		return nil, err
+	}
+	if err := health.NormalizeCheckMatching(proxy); err != nil {
+		return nil, err
+	}
+
+	// Catch bad templates and unset environment variables before they reach Caddy
+	if _, err := caddy.ResolveTemplates(*proxy); err != nil {
+		return nil, err
+	}
+
+	return proxy, nil
+}
+
+// normalizeTags lower-cases and de-duplicates tags, dropping empty ones. Tags are limited to
+// letters, digits and - _ . : / so they can be listed comma-separated in the tag filter.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters: %s", maxTagLength, tag)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:/", r) {
+				return nil, fmt.Errorf("tags may only contain letters, digits and - _ . : /: %s", tag)
+			}
+		}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	return normalized, nil
+}
+
+// normalizeNotes trims notes and limits their size, as they are kept in the metadata
+func normalizeNotes(notes string) (string, error) {
+	notes = strings.TrimSpace(notes)
+	if len(notes) > maxNotesBytes {
+		return "", fmt.Errorf("notes must be at most %d bytes", maxNotesBytes)
+	}
+	return notes, nil
+}
+
+// normalizeTargetURLs brings the http targets of a proxy request into the form Caddy dials, e.g.
+// with a scheme and punycode hostnames
+func normalizeTargetURLs(proxyReq *proxyRequest) error {
+	var err error
+	if proxyReq.TargetURL, err = caddy.NormalizeTargetURL(proxyReq.TargetURL); err != nil {
+		return err
+	}
+	for i := range proxyReq.TargetURLs {
+		if proxyReq.TargetURLs[i], err = caddy.NormalizeTargetURL(proxyReq.TargetURLs[i]); err != nil {
+			return err
+		}
+	}
+	if proxyReq.BackupTargetURL, err = caddy.NormalizeTargetURL(proxyReq.BackupTargetURL); err != nil {
+		return fmt.Errorf("backup_target_url: %v", err)
+	}
+	return nil
+}
+
+// normalizeTargets trims target_urls and reconciles it with target_url, which always holds the first
+// upstream. A single target is stored as target_url alone.
+func normalizeTargets(proxyReq *proxyRequest) error {
+	var targets []string
+	for _, target := range proxyReq.TargetURLs {
+		if target = strings.TrimSpace(target); target != "" {
+			targets = append(targets, target)
+		}
+	}
+
+	if len(targets) > 0 {
+		if proxyReq.TargetURL == "" {
+			proxyReq.TargetURL = targets[0]
+		} else if proxyReq.TargetURL != targets[0] {
+			return fmt.Errorf("target_url must be the first entry of target_urls")
+		}
+	}
+	if len(targets) <= 1 {
+		targets = nil
+	}
+	proxyReq.TargetURLs = targets
+
+	if proxyReq.LBPolicy != "" && !caddy.ValidLBPolicy(proxyReq.LBPolicy) {
+		return fmt.Errorf("Unsupported lb_policy: %s (use %s)", proxyReq.LBPolicy, strings.Join(caddy.LBPolicies, ", "))
+	}
+	if len(targets) == 0 {
+		proxyReq.LBPolicy = ""
+	} else if proxyReq.LBPolicy == "" {
+		proxyReq.LBPolicy = caddy.DefaultLBPolicy
+	}
+
+	return nil
+}
+
+// normalizeProxyLinks trims a proxy's links, which must be absolute http(s) URLs so the UI can open
+// them safely. Links without any URL are dropped.
+func normalizeProxyLinks(links *models.ProxyLinks) (*models.ProxyLinks, error) {
+	if links == nil {
+		return nil, nil
+	}
+
+	for _, link := range []struct {
+		name  string
+		value *string
+	}{{"dashboard", &links.Dashboard}, {"runbook", &links.Runbook}, {"repository", &links.Repository}} {
+		*link.value = strings.TrimSpace(*link.value)
+		if *link.value == "" {
+			continue
+		}
+		parsed, err := url.Parse(*link.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("links.%s must be an http or https URL", link.name)
+		}
+	}
+
+	if *links == (models.ProxyLinks{}) {
+		return nil, nil
+	}
+	return links, nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name (an RFC 7230 token)
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
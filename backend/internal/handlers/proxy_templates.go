@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// templateProxyOnlyFields are the proxy fields given when a template is used, never by the template
+var templateProxyOnlyFields = []string{"domain", "target_url", "target_urls", "backup_target_url"}
+
+// templateCheckDomain is the domain a template's settings are validated with, as they are checked
+// the way a proxy's would be
+const templateCheckDomain = "template.example.com"
+
+// proxyTemplateRequest is the body of requests creating or updating a proxy template
+type proxyTemplateRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Proxy       json.RawMessage `json:"proxy"`
+}
+
+// decodeProxyTemplateRequest reads a proxy template from the request body. Its proxy settings must
+// be valid proxy request fields that, with a domain and target added, make a valid proxy.
+func (h *Handler) decodeProxyTemplateRequest(r *http.Request) (*models.ProxyTemplate, error) {
+	var templateReq proxyTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&templateReq); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+
+	templateReq.Name = strings.TrimSpace(templateReq.Name)
+	if templateReq.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	proxy := bytes.TrimSpace(templateReq.Proxy)
+	if len(proxy) == 0 || bytes.Equal(proxy, []byte("null")) {
+		proxy = []byte("{}")
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(proxy, &fields); err != nil {
+		return nil, fmt.Errorf("proxy must be an object of proxy settings")
+	}
+	for _, field := range templateProxyOnlyFields {
+		if _, exists := fields[field]; exists {
+			return nil, fmt.Errorf("proxy must not set %s, it is given when the template is used", field)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(proxy))
+	decoder.DisallowUnknownFields()
+	var proxyReq proxyRequest
+	if err := decoder.Decode(&proxyReq); err != nil {
+		return nil, fmt.Errorf("invalid proxy settings: %v", err)
+	}
+
+	proxyReq.Domain = templateCheckDomain
+	proxyReq.TargetURL = "http://localhost"
+	if proxyReq.UpstreamSNI != "" {
+		proxyReq.TargetURL = "https://localhost"
+	}
+	if _, err := h.buildProxy(proxyReq); err != nil {
+		return nil, fmt.Errorf("invalid proxy settings: %v", err)
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, proxy); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+	return models.NewProxyTemplate(templateReq.Name, strings.TrimSpace(templateReq.Description), compacted.Bytes()), nil
+}
+
+// GetProxyTemplates returns the proxy templates
+func (h *Handler) GetProxyTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := h.CaddyClient.ProxyTemplates()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// GetProxyTemplate returns a single proxy template
+func (h *Handler) GetProxyTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.CaddyClient.GetProxyTemplate(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, template)
+}
+
+// CreateProxyTemplate creates a new proxy template
+func (h *Handler) CreateProxyTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.decodeProxyTemplateRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	template.ID = h.CaddyClient.NewProxyTemplateID(template.Name)
+
+	if err := h.CaddyClient.SaveProxyTemplate(*template); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to save template: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "CREATE_PROXY_TEMPLATE", fmt.Sprintf("Proxy template '%s' created", template.ID))
+	writeJSON(w, http.StatusCreated, template)
+}
+
+// UpdateProxyTemplate replaces a proxy template. Proxies created from it keep their settings.
+func (h *Handler) UpdateProxyTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, err := h.CaddyClient.GetProxyTemplate(id)
+	if err != nil {
+		http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+		return
+	}
+
+	template, err := h.decodeProxyTemplateRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	template.ID = id
+	template.CreatedAt = existing.CreatedAt
+
+	if err := h.CaddyClient.SaveProxyTemplate(*template); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to save template: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "UPDATE_PROXY_TEMPLATE", fmt.Sprintf("Proxy template '%s' updated", template.ID))
+	writeJSON(w, http.StatusOK, template)
+}
+
+// DeleteProxyTemplate removes a proxy template. Proxies created from it are kept.
+func (h *Handler) DeleteProxyTemplate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.CaddyClient.GetProxyTemplate(id); err != nil {
+		http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if err := h.CaddyClient.DeleteProxyTemplate(id); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to delete template: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, "DELETE_PROXY_TEMPLATE", fmt.Sprintf("Proxy template '%s' deleted", id))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Template %s deleted successfully", id),
+	})
+}
+
+// CreateProxyFromTemplate creates a proxy from a template. The body needs just domain and
+// target_url; any other proxy field given overrides the template's, with header maps merged into
+// the template's headers.
+func (h *Handler) CreateProxyFromTemplate(w http.ResponseWriter, r *http.Request) {
+	template, err := h.CaddyClient.GetProxyTemplate(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Template not found"}`, http.StatusNotFound)
+		return
+	}
+
+	var proxyReq proxyRequest
+	if err := json.Unmarshal(template.Proxy, &proxyReq); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Stored template is invalid: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&proxyReq); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	proxy, err := h.buildProxy(proxyReq)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	h.createProxy(w, r, proxy, fmt.Sprintf(" from template '%s'", template.ID))
+}
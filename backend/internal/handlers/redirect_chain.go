@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxRedirectHops is the longest redirect chain accepted when saving a redirect
+const maxRedirectHops = 5
+
+// redirectDestinationHost returns the lowercased host a redirect sends clients to
+func redirectDestinationHost(destinationURL string) string {
+	if !strings.Contains(destinationURL, "://") {
+		destinationURL = "https://" + destinationURL
+	}
+
+	u, err := url.Parse(destinationURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// checkRedirectChain checks a redirect against the existing ones before it is saved. It returns an
+// error for loops or chains longer than maxRedirectHops, and warnings for shorter chains.
+func (h *Handler) checkRedirectChain(redirect models.Redirect) ([]string, error) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		return nil, nil // Nothing to compare against yet
+	}
+
+	// Map each source host to where it redirects, with the saved redirect replacing its old version
+	next := make(map[string]string)
+	for _, existing := range h.CaddyClient.ParseRedirectsFromConfig(config) {
+		if existing.ID == redirect.ID {
+			continue
+		}
+		for _, source := range existing.SourceDomains {
+			next[strings.ToLower(hostWithoutPort(source))] = redirectDestinationHost(existing.DestinationURL)
+		}
+	}
+
+	var sources []string
+	for _, source := range redirect.SourceDomains {
+		source = strings.ToLower(hostWithoutPort(source))
+		sources = append(sources, source)
+		next[source] = redirectDestinationHost(redirect.DestinationURL)
+	}
+
+	// Every chain through the saved redirect passes one of its sources, so walk from each host
+	// and look at the chains that include them
+	var warnings []string
+	for start := range next {
+		chain := []string{start}
+		for host := next[start]; ; host = next[host] {
+			if slices.Contains(chain, host) {
+				if slices.ContainsFunc(chain, func(h string) bool { return slices.Contains(sources, h) }) {
+					return nil, fmt.Errorf("redirect loop: %s -> %s", strings.Join(chain, " -> "), host)
+				}
+				break
+			}
+			chain = append(chain, host)
+			if _, redirects := next[host]; !redirects {
+				break
+			}
+		}
+
+		hops := len(chain) - 1
+		if hops < 2 || !slices.ContainsFunc(chain, func(h string) bool { return slices.Contains(sources, h) }) {
+			continue
+		}
+		if hops > maxRedirectHops {
+			return nil, fmt.Errorf("redirect chain of %d hops exceeds the limit of %d: %s", hops, maxRedirectHops, strings.Join(chain, " -> "))
+		}
+		warnings = append(warnings, fmt.Sprintf("%s takes %d redirects to reach %s: %s", start, hops, chain[len(chain)-1], strings.Join(chain, " -> ")))
+	}
+
+	slices.Sort(warnings)
+	return warnings, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+type savedSearchRequest struct {
+	Name    string            `json:"name"`
+	Filters map[string]string `json:"filters"`
+	Shared  bool              `json:"shared"`
+}
+
+// validate trims the request and checks its filters are proxy list parameters GET /api/proxies
+// would accept
+func (req *savedSearchRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+
+	query := url.Values{}
+	for key, value := range req.Filters {
+		if !slices.Contains(proxyListParams, key) {
+			return fmt.Errorf("Unknown filter %q, expected one of %s", key, strings.Join(proxyListParams, ", "))
+		}
+		if value = strings.TrimSpace(value); value == "" {
+			delete(req.Filters, key)
+			continue
+		}
+		req.Filters[key] = value
+		query.Set(key, value)
+	}
+	if len(req.Filters) == 0 {
+		return fmt.Errorf("At least one filter is required")
+	}
+
+	options, err := parseListOptions(query)
+	if err != nil {
+		return err
+	}
+	known := jsonFieldNames(reflect.TypeFor[models.Proxy]())
+	for _, field := range options.fields {
+		if !known[field] {
+			return fmt.Errorf("Unknown field: %s", field)
+		}
+	}
+	return nil
+}
+
+// GetSavedSearches lists the user's saved searches and those other users shared
+func (h *AuthHandler) GetSavedSearches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		h.unauthorized(w, "Not authenticated")
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"saved_searches": h.storage.ListSavedSearches(user.ID),
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// CreateSavedSearch saves a named set of proxy list filters for the user
+func (h *AuthHandler) CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		h.unauthorized(w, "Not authenticated")
+		return
+	}
+
+	var req savedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		h.badRequest(w, err.Error())
+		return
+	}
+
+	search, err := h.storage.CreateSavedSearch(user.ID, req.Name, req.Filters, req.Shared)
+	if err != nil {
+		h.internalError(w, "Failed to create saved search")
+		return
+	}
+
+	h.logAudit(r, "CREATE_SAVED_SEARCH", fmt.Sprintf("Saved search '%s' created (shared: %t)", search.Name, search.Shared))
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"saved_search": search,
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// UpdateSavedSearch replaces a saved search; only its owner and admins may change it
+func (h *AuthHandler) UpdateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.ownedSavedSearch(w, r); !ok {
+		return
+	}
+
+	var req savedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+	if err := req.validate(); err != nil {
+		h.badRequest(w, err.Error())
+		return
+	}
+
+	search, err := h.storage.UpdateSavedSearch(r.PathValue("id"), req.Name, req.Filters, req.Shared)
+	if !h.savedSearchChangeSucceeded(w, err) {
+		return
+	}
+
+	h.logAudit(r, "UPDATE_SAVED_SEARCH", fmt.Sprintf("Saved search '%s' updated (shared: %t)", search.Name, search.Shared))
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"saved_search": search,
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// DeleteSavedSearch removes a saved search; only its owner and admins may delete it
+func (h *AuthHandler) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, ok := h.ownedSavedSearch(w, r); !ok {
+		return
+	}
+
+	search, err := h.storage.DeleteSavedSearch(r.PathValue("id"))
+	if !h.savedSearchChangeSucceeded(w, err) {
+		return
+	}
+
+	h.logAudit(r, "DELETE_SAVED_SEARCH", fmt.Sprintf("Saved search '%s' deleted", search.Name))
+
+	if err := json.NewEncoder(w).Encode(models.AuthResponse{
+		Success: true,
+		Message: "Saved search deleted",
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// ownedSavedSearch returns the saved search in the path when the user may change it, writing the
+// error response otherwise. Others' searches are reported as missing unless they're shared.
+func (h *AuthHandler) ownedSavedSearch(w http.ResponseWriter, r *http.Request) (*models.SavedSearch, bool) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		h.unauthorized(w, "Not authenticated")
+		return nil, false
+	}
+
+	search, err := h.storage.GetSavedSearch(r.PathValue("id"))
+	if err == nil && search.UserID != user.ID && !search.Shared && user.EffectiveRole() != models.RoleAdmin {
+		err = auth.ErrSavedSearchNotFound
+	}
+	if !h.savedSearchChangeSucceeded(w, err) {
+		return nil, false
+	}
+
+	if search.UserID != user.ID && user.EffectiveRole() != models.RoleAdmin {
+		h.forbidden(w, "Only the owner of a saved search can change it")
+		return nil, false
+	}
+	return search, true
+}
+
+// savedSearchChangeSucceeded writes the error response for a failed saved search change
+func (h *AuthHandler) savedSearchChangeSucceeded(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrSavedSearchNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(models.AuthResponse{
+			Success: false,
+			Message: "Saved search not found",
+		}); err != nil {
+			// Log error if needed, but response is already written
+		}
+	default:
+		h.internalError(w, err.Error())
+	}
+	return false
+}
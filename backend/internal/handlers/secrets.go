@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Kinds of generated secret
+const (
+	SecretTypePassword = "password"
+	SecretTypeToken    = "token"
+	SecretTypeHtpasswd = "htpasswd"
+)
+
+const (
+	defaultPasswordLength = 24
+	minPasswordLength     = 12
+	maxPasswordLength     = 128
+)
+
+// GenerateSecret returns a random password, a 256-bit hex token, or for htpasswd a password with
+// its bcrypt hash and an htpasswd line for username. Nothing is stored.
+func (h *Handler) GenerateSecret(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	secretType := query.Get("type")
+	if secretType == "" {
+		secretType = SecretTypePassword
+	}
+
+	length := defaultPasswordLength
+	if value := query.Get("length"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < minPasswordLength || n > maxPasswordLength {
+			http.Error(w, fmt.Sprintf(`{"error": "length must be between %d and %d"}`, minPasswordLength, maxPasswordLength), http.StatusBadRequest)
+			return
+		}
+		length = n
+	}
+
+	response := map[string]any{"type": secretType}
+	switch secretType {
+	case SecretTypePassword, SecretTypeHtpasswd:
+		password, err := auth.GeneratePassword(length)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to generate password: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		response["value"] = password
+
+		if secretType == SecretTypeHtpasswd {
+			username := strings.TrimSpace(query.Get("username"))
+			if username == "" {
+				username = "admin"
+			}
+			if strings.ContainsAny(username, ": \t") {
+				http.Error(w, `{"error": "username can't contain colons or whitespace"}`, http.StatusBadRequest)
+				return
+			}
+
+			hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error": "Failed to hash password: %v"}`, err), http.StatusInternalServerError)
+				return
+			}
+			response["username"] = username
+			response["hash"] = string(hash)
+			response["htpasswd"] = username + ":" + string(hash)
+		}
+	case SecretTypeToken:
+		token, err := auth.GenerateToken()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to generate token: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		response["value"] = token
+	default:
+		http.Error(w, fmt.Sprintf(`{"error": "type must be %s, %s or %s"}`, SecretTypePassword, SecretTypeToken, SecretTypeHtpasswd), http.StatusBadRequest)
+		return
+	}
+
+	// Generated secrets must not end up in browser or proxy caches
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, response)
+}
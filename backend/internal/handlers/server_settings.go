@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// serverSettingsResponse is the protocol settings along with the servers they can override
+type serverSettingsResponse struct {
+	models.ServerSettings
+	AvailableServers []string `json:"available_servers"`
+}
+
+// writeServerSettings responds with the current protocol settings
+func (h *Handler) writeServerSettings(w http.ResponseWriter, status int) {
+	servers, err := h.CaddyClient.ServerNames()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if servers == nil {
+		servers = []string{}
+	}
+
+	writeJSON(w, status, serverSettingsResponse{
+		ServerSettings:   h.CaddyClient.ServerSettings(),
+		AvailableServers: servers,
+	})
+}
+
+// GetServerSettings returns the HTTP versions the Caddy servers accept
+func (h *Handler) GetServerSettings(w http.ResponseWriter, r *http.Request) {
+	h.writeServerSettings(w, http.StatusOK)
+}
+
+// UpdateServerSettings replaces the HTTP versions the Caddy servers accept, by default and per
+// server, and applies them to Caddy
+func (h *Handler) UpdateServerSettings(w http.ResponseWriter, r *http.Request) {
+	var settings models.ServerSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := caddy.NormalizeServerSettings(&settings); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := h.caddyClientFor(r).SaveServerSettings(settings); err != nil {
+		writeCaddyError(w, "Failed to apply server settings", err)
+		return
+	}
+
+	h.logAudit(r, "UPDATE_SERVER_SETTINGS", fmt.Sprintf("Server protocols set to %s by default with %d server overrides", protocolList(settings.Default), len(settings.Servers)))
+	h.writeServerSettings(w, http.StatusOK)
+}
+
+// protocolList describes protocols for the audit log
+func protocolList(protocols models.ServerProtocols) string {
+	list := protocols.CaddyProtocols()
+	if list == nil {
+		return "h1, h2, h3 (Caddy's defaults)"
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// writeStorageSettings responds with the current certificate storage settings
+func (h *Handler) writeStorageSettings(w http.ResponseWriter, status int) {
+	settings, err := h.CaddyClient.StorageSettings()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status, settings)
+}
+
+// GetStorageSettings returns where Caddy keeps certificates, without the password or token
+func (h *Handler) GetStorageSettings(w http.ResponseWriter, r *http.Request) {
+	h.writeStorageSettings(w, http.StatusOK)
+}
+
+// UpdateStorageSettings points Caddy at a file system directory, or at Redis or Consul when Caddy is
+// built with their storage module, so clustered Caddy instances can share certificates
+func (h *Handler) UpdateStorageSettings(w http.ResponseWriter, r *http.Request) {
+	var settings models.StorageSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := caddy.NormalizeStorageSettings(&settings); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := h.caddyClientFor(r).SaveStorageSettings(settings); err != nil {
+		writeCaddyError(w, "Failed to apply storage settings", err)
+		return
+	}
+
+	h.logAudit(r, "UPDATE_STORAGE_SETTINGS", fmt.Sprintf("Certificate storage set to %s", storageLocation(settings)))
+	h.writeStorageSettings(w, http.StatusOK)
+}
+
+// storageLocation describes storage settings for the audit log
+func storageLocation(settings models.StorageSettings) string {
+	switch {
+	case settings.Module == models.StorageFileSystem && settings.Root == "":
+		return "file_system (Caddy's default directory)"
+	case settings.Module == models.StorageFileSystem:
+		return fmt.Sprintf("file_system at %s", settings.Root)
+	default:
+		return fmt.Sprintf("%s at %s", settings.Module, strings.Join(settings.Addresses, ", "))
+	}
+}
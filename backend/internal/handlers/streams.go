@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/portcheck"
+)
+
+// streamRequest is the body of requests creating or updating a stream proxy
+type streamRequest struct {
+	Name      string   `json:"name"`
+	Protocol  string   `json:"protocol"`
+	Listen    string   `json:"listen"`
+	Upstreams []string `json:"upstreams"`
+}
+
+// decodeStreamRequest reads and validates a stream proxy from the request body
+func decodeStreamRequest(r *http.Request) (*models.StreamProxy, error) {
+	var streamReq streamRequest
+	if err := json.NewDecoder(r.Body).Decode(&streamReq); err != nil {
+		return nil, fmt.Errorf("Invalid JSON")
+	}
+
+	stream := models.NewStreamProxy(strings.TrimSpace(streamReq.Name), streamReq.Protocol, streamReq.Listen, streamReq.Upstreams)
+	if err := caddy.NormalizeStream(stream); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// GetStreams retrieves all TCP and UDP stream proxies
+func (h *Handler) GetStreams(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	streams := h.CaddyClient.ParseStreamsFromConfig(config)
+	if streams == nil {
+		streams = []models.StreamProxy{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"streams": streams,
+		"count":   len(streams),
+	})
+}
+
+// GetStream returns a single stream proxy
+func (h *Handler) GetStream(w http.ResponseWriter, r *http.Request) {
+	stream, err := h.CaddyClient.GetStream(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Stream not found"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, stream)
+}
+
+// CreateStream creates a new stream proxy
+func (h *Handler) CreateStream(w http.ResponseWriter, r *http.Request) {
+	stream, err := decodeStreamRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	stream.ID = h.CaddyClient.NewStreamID(*stream)
+
+	if err := h.caddyClientFor(r).AddStream(*stream); err != nil {
+		writeCaddyError(w, "Failed to add stream to Caddy", err)
+		return
+	}
+
+	h.logAudit(r, "CREATE_STREAM", fmt.Sprintf("Stream '%s' created on %s/%s to %v", stream.ID, stream.Protocol, stream.Listen, stream.Upstreams))
+	writeJSON(w, http.StatusCreated, stream)
+}
+
+// UpdateStream updates an existing stream proxy
+func (h *Handler) UpdateStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, err := h.CaddyClient.GetStream(id)
+	if err != nil {
+		http.Error(w, `{"error": "Stream not found"}`, http.StatusNotFound)
+		return
+	}
+
+	stream, err := decodeStreamRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	stream.ID = id
+	stream.CreatedAt = existing.CreatedAt
+
+	if err := h.caddyClientFor(r).UpdateStream(*stream); err != nil {
+		writeCaddyError(w, "Failed to update stream in Caddy", err)
+		return
+	}
+
+	h.logAudit(r, "UPDATE_STREAM", fmt.Sprintf("Stream '%s' updated on %s/%s to %v", stream.ID, stream.Protocol, stream.Listen, stream.Upstreams))
+	writeJSON(w, http.StatusOK, stream)
+}
+
+// DeleteStream removes a stream proxy
+func (h *Handler) DeleteStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := h.caddyClientFor(r).DeleteStream(id); err != nil {
+		writeCaddyError(w, "Failed to delete stream from Caddy", err)
+		return
+	}
+
+	h.logAudit(r, "DELETE_STREAM", fmt.Sprintf("Stream '%s' deleted", id))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("Stream %s deleted successfully", id),
+	})
+}
+
+// portForwardRequest is the body of a port forward quick-create: one external port passed on to
+// one internal address
+type portForwardRequest struct {
+	Name         string `json:"name"`
+	Protocol     string `json:"protocol"`
+	ExternalPort int    `json:"external_port"`
+	Target       string `json:"target"` // internal host:port
+}
+
+// CreatePortForward creates a stream listening on an external port with a single internal upstream
+// and reports whether the port can be reached from outside
+func (h *Handler) CreatePortForward(w http.ResponseWriter, r *http.Request) {
+	var req portForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
+		return
+	}
+	if req.ExternalPort < 1 || req.ExternalPort > 65535 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "external_port must be between 1 and 65535"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = fmt.Sprintf("Port forward %d", req.ExternalPort)
+	}
+	stream := models.NewStreamProxy(name, req.Protocol, fmt.Sprintf(":%d", req.ExternalPort), []string{req.Target})
+	if err := caddy.NormalizeStream(stream); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	stream.ID = h.CaddyClient.NewStreamID(*stream)
+
+	if err := h.caddyClientFor(r).AddStream(*stream); err != nil {
+		writeCaddyError(w, "Failed to add stream to Caddy", err)
+		return
+	}
+
+	h.logAudit(r, "CREATE_STREAM", fmt.Sprintf("Port forward '%s' created from %s/%s to %s", stream.ID, stream.Protocol, stream.Listen, stream.Upstreams[0]))
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"stream":       stream,
+		"reachability": h.checkPort(r.Context(), stream.Protocol, req.ExternalPort),
+	})
+}
+
+// GetStreamReachability checks whether a stream's port can be reached from outside, e.g. again
+// after opening it in a firewall
+func (h *Handler) GetStreamReachability(w http.ResponseWriter, r *http.Request) {
+	stream, err := h.CaddyClient.GetStream(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Stream not found"})
+		return
+	}
+
+	_, portValue, err := net.SplitHostPort(stream.Listen)
+	port, convErr := strconv.Atoi(portValue)
+	if err != nil || convErr != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Reachability can only be checked for a single port, not %s", stream.Listen)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.checkPort(r.Context(), stream.Protocol, port))
+}
+
+// checkPort asks the configured check service about a port, or returns the hints alone without one
+func (h *Handler) checkPort(ctx context.Context, protocol string, port int) portcheck.Result {
+	if h.PortCheck == nil {
+		return portcheck.Unchecked(protocol, port)
+	}
+	return h.PortCheck.Check(ctx, protocol, port)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/stats"
+)
+
+// GetProxyStats returns a proxy's traffic over ?window= (5m, 1h, 6h or 24h, default 1h): requests
+// and bytes per second, status codes, the most requested paths and a per-minute series
+func (h *Handler) GetProxyStats(w http.ResponseWriter, r *http.Request) {
+	if h.Stats == nil {
+		http.Error(w, `{"error": "Traffic statistics are disabled, set TRAFFIC_STATS=true to enable them"}`, http.StatusForbidden)
+		return
+	}
+
+	id := r.PathValue("id")
+	proxy, err := h.CaddyClient.GetProxy(id)
+	if err != nil {
+		http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "1h"
+	}
+	if _, ok := stats.Windows[window]; !ok {
+		http.Error(w, fmt.Sprintf(`{"error": "Invalid window %q, expected 5m, 1h, 6h or 24h"}`, window), http.StatusBadRequest)
+		return
+	}
+
+	summary := h.Stats.Summarize(domainMatcher(proxy.Domain), window)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"proxy_id": proxy.ID,
+		"stats":    summary,
+	})
+}
+
+// domainMatcher matches the hosts a proxy domain serves: the domain itself or, for wildcard
+// domains, every host one label below it
+func domainMatcher(domain string) func(host string) bool {
+	domain = strings.ToLower(domain)
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		domain = host
+	}
+
+	if suffix, ok := strings.CutPrefix(domain, "*"); ok {
+		return func(host string) bool {
+			label, found := strings.CutSuffix(host, suffix)
+			return found && label != "" && !strings.Contains(label, ".")
+		}
+	}
+	return func(host string) bool {
+		return host == domain
+	}
+}
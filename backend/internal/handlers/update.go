@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/update"
+)
+
+type UpdateHandler struct {
+	updater      *update.Updater
+	auditService *audit.Service
+	restart      func() // nil when self-update is disabled
+}
+
+// NewUpdateHandler creates the version and self-update handler. Passing a nil restart function
+// disables the self-update endpoint.
+func NewUpdateHandler(updater *update.Updater, auditService *audit.Service, restart func()) *UpdateHandler {
+	return &UpdateHandler{
+		updater:      updater,
+		auditService: auditService,
+		restart:      restart,
+	}
+}
+
+// GetVersion returns the running version and the latest release. ?refresh=true bypasses the cache.
+func (h *UpdateHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	info := h.updater.Check(r.Context(), r.URL.Query().Get("refresh") == "true")
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"version":             info,
+		"self_update_enabled": h.restart != nil,
+	})
+}
+
+// ApplyUpdate installs the latest release binary and restarts the server
+func (h *UpdateHandler) ApplyUpdate(w http.ResponseWriter, r *http.Request) {
+	if h.restart == nil {
+		http.Error(w, `{"error": "Self-update is disabled, set SELF_UPDATE=true to enable it"}`, http.StatusForbidden)
+		return
+	}
+
+	previous := update.Version
+	version, err := h.updater.Apply(r.Context())
+	if errors.Is(err, update.ErrNoUpdate) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"updated": false,
+			"version": previous,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Update failed: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if h.auditService != nil {
+		user := auth.GetUserFromContext(r.Context())
+		username := "unknown"
+		userID := "unknown"
+		if user != nil {
+			username = user.Username
+			userID = user.ID
+		}
+		ipAddress := r.RemoteAddr
+		if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+			ipAddress = ip
+		}
+		h.auditService.Log("SELF_UPDATE", fmt.Sprintf("Updated from '%s' to '%s'", previous, version), userID, username, ipAddress)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated":    true,
+		"version":    version,
+		"restarting": true,
+	})
+
+	// Restart once the response has gone out; shutdown waits for in-flight requests
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	go h.restart()
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/stats"
+)
+
+const (
+	redirectCheckTimeout     = 10 * time.Second
+	redirectCheckConcurrency = 8
+	// idleWindow is how long a proxy must go without requests to be reported as idle
+	idleWindow = "24h"
+)
+
+// brokenRedirect is a redirect whose destination fails to load
+type brokenRedirect struct {
+	ID             string   `json:"id"`
+	SourceDomains  []string `json:"source_domains"`
+	DestinationURL string   `json:"destination_url"`
+	StatusCode     int      `json:"status_code,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// unhealthyProxy is a proxy whose health check is failing
+type unhealthyProxy struct {
+	ID               string   `json:"id"`
+	Domain           string   `json:"domain"`
+	Message          string   `json:"message"`
+	LastChecked      string   `json:"last_checked"`
+	DownDependencies []string `json:"down_dependencies,omitempty"`
+}
+
+// idleProxy is a proxy that served no requests over idleWindow
+type idleProxy struct {
+	ID        string `json:"id"`
+	Domain    string `json:"domain"`
+	CreatedAt string `json:"created_at"`
+}
+
+// unusedAccessList is a shared access list no proxy references
+type unusedAccessList struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// unusedACMEDNSAccount is an acme-dns account no proxy or pre-provisioned certificate uses
+type unusedACMEDNSAccount struct {
+	Domain       string `json:"domain"`
+	RegisteredAt string `json:"registered_at"`
+}
+
+// GetUsageReport lists resources that are likely cruft: redirects whose destination returns an error,
+// proxies failing their health check, proxies without requests for a day (with traffic statistics on)
+// and access lists and acme-dns accounts nothing uses. Redirect destinations are requested unless
+// check_redirects=false.
+func (h *Handler) GetUsageReport(w http.ResponseWriter, r *http.Request) {
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	proxies := h.CaddyClient.ParseProxiesFromConfig(config)
+	redirects := h.CaddyClient.ParseRedirectsFromConfig(config)
+
+	checkRedirects := r.URL.Query().Get("check_redirects") != "false"
+	brokenRedirects := []brokenRedirect{}
+	if checkRedirects {
+		brokenRedirects = h.brokenRedirects(r.Context(), redirects)
+	}
+
+	unhealthyProxies := []unhealthyProxy{}
+	for _, proxy := range proxies {
+		status, exists := h.HealthService.GetHealthStatus(proxy.ID)
+		if !exists || status.Status != "Unhealthy" {
+			continue
+		}
+		unhealthyProxies = append(unhealthyProxies, unhealthyProxy{
+			ID:               proxy.ID,
+			Domain:           proxy.Domain,
+			Message:          status.Message,
+			LastChecked:      status.LastChecked,
+			DownDependencies: status.DownDependencies,
+		})
+	}
+
+	// Proxies can't be disabled, and traffic is only known with traffic statistics on
+	unavailable := []string{"disabled_resources"}
+	idleProxies := []idleProxy{}
+	if h.Stats != nil {
+		idleProxies = h.idleProxies(proxies)
+	} else {
+		unavailable = append([]string{"traffic"}, unavailable...)
+	}
+
+	accessLists := h.CaddyClient.AccessLists()
+	unusedAccessLists := []unusedAccessList{}
+	for _, list := range accessLists {
+		if len(h.CaddyClient.AccessListUsers(list.ID)) == 0 {
+			unusedAccessLists = append(unusedAccessLists, unusedAccessList{
+				ID:        list.ID,
+				Name:      list.Name,
+				CreatedAt: list.CreatedAt,
+			})
+		}
+	}
+
+	usedDomains := make(map[string]bool)
+	for _, proxy := range proxies {
+		usedDomains[strings.ToLower(hostWithoutPort(proxy.Domain))] = true
+	}
+	for _, domain := range h.CaddyClient.PreprovisionedDomains(config) {
+		usedDomains[strings.ToLower(domain)] = true
+	}
+	unusedAccounts := []unusedACMEDNSAccount{}
+	for _, account := range h.CaddyClient.ACMEDNSAccounts() {
+		if !usedDomains[strings.ToLower(account.Domain)] {
+			unusedAccounts = append(unusedAccounts, unusedACMEDNSAccount{
+				Domain:       account.Domain,
+				RegisteredAt: account.RegisteredAt,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"broken_redirects":         brokenRedirects,
+		"redirects_checked":        checkRedirects,
+		"unhealthy_proxies":        unhealthyProxies,
+		"idle_proxies":             idleProxies,
+		"unused_access_lists":      unusedAccessLists,
+		"unused_acme_dns_accounts": unusedAccounts,
+		"summary": map[string]int{
+			"proxies":                  len(proxies),
+			"redirects":                len(redirects),
+			"broken_redirects":         len(brokenRedirects),
+			"unhealthy_proxies":        len(unhealthyProxies),
+			"idle_proxies":             len(idleProxies),
+			"access_lists":             len(accessLists),
+			"unused_access_lists":      len(unusedAccessLists),
+			"unused_acme_dns_accounts": len(unusedAccounts),
+		},
+		"unavailable":  unavailable,
+		"generated_at": time.Now().Format(time.RFC3339),
+	})
+}
+
+// idleProxies returns the proxies without requests over idleWindow. Proxies created within the
+// window haven't had the chance to serve any yet and are left out.
+func (h *Handler) idleProxies(proxies []models.Proxy) []idleProxy {
+	cutoff := time.Now().Add(-stats.Windows[idleWindow])
+	idle := []idleProxy{}
+	for _, proxy := range proxies {
+		if created, err := time.Parse(time.RFC3339, proxy.CreatedAt); err == nil && created.After(cutoff) {
+			continue
+		}
+		if h.Stats.Summarize(domainMatcher(proxy.Domain), idleWindow).Requests > 0 {
+			continue
+		}
+		idle = append(idle, idleProxy{ID: proxy.ID, Domain: proxy.Domain, CreatedAt: proxy.CreatedAt})
+	}
+
+	slices.SortFunc(idle, func(a, b idleProxy) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return idle
+}
+
+// brokenRedirects requests every redirect destination, returning those that fail or respond with
+// an error status
+func (h *Handler) brokenRedirects(ctx context.Context, redirects []models.Redirect) []brokenRedirect {
+	client := &http.Client{Timeout: redirectCheckTimeout}
+	if h.OutboundGuard != nil {
+		client.Transport = h.OutboundGuard.Transport()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, redirectCheckConcurrency)
+	broken := []brokenRedirect{}
+
+	for _, redirect := range redirects {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			statusCode, err := checkRedirectDestination(ctx, client, redirect.DestinationURL)
+			if err == nil && statusCode < http.StatusBadRequest {
+				return
+			}
+
+			result := brokenRedirect{
+				ID:             redirect.ID,
+				SourceDomains:  redirect.SourceDomains,
+				DestinationURL: redirect.DestinationURL,
+				StatusCode:     statusCode,
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			broken = append(broken, result)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(broken, func(a, b brokenRedirect) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return broken
+}
+
+// checkRedirectDestination returns the status code a destination responds with, after following
+// its own redirects
+func checkRedirectDestination(ctx context.Context, client *http.Client, destination string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "caddyproxymanager-usage-report")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
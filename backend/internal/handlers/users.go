@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/auth"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// GetUsers lists the users and their roles
+func (h *AuthHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	users := h.storage.ListUsers()
+	list := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		list = append(list, publicUser(user))
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"users":   list,
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// CreateUser adds a user with a role, read_only unless admin is requested
+func (h *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if strings.TrimSpace(req.Username) == "" || strings.TrimSpace(req.Password) == "" {
+		h.badRequest(w, "Username and password are required")
+		return
+	}
+	if len(req.Password) < 6 {
+		h.badRequest(w, "Password must be at least 6 characters")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleReadOnly
+	}
+	if !models.ValidRole(req.Role) {
+		h.badRequest(w, fmt.Sprintf("Role must be %q, %q or %q", models.RoleAdmin, models.RoleReadOnly, models.RoleConfigViewer))
+		return
+	}
+
+	user, err := h.storage.CreateUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		h.badRequest(w, "Failed to create user: "+err.Error())
+		return
+	}
+
+	h.logAudit(r, "CREATE_USER", fmt.Sprintf("User '%s' created with role '%s'", user.Username, user.Role))
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    publicUser(user),
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// GetUser returns a single user
+func (h *AuthHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := h.storage.GetUserByID(r.PathValue("id"))
+	if err != nil {
+		h.userChangeSucceeded(w, auth.ErrUserNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    publicUser(user),
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// UpdateUser changes a user's role, resets their password or disables them. Omitted fields are
+// left unchanged.
+func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Role     *string `json:"role"`
+		Password *string `json:"password"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.badRequest(w, "Invalid request body")
+		return
+	}
+	if req.Role != nil && !models.ValidRole(*req.Role) {
+		h.badRequest(w, fmt.Sprintf("Role must be %q, %q or %q", models.RoleAdmin, models.RoleReadOnly, models.RoleConfigViewer))
+		return
+	}
+	if req.Password != nil && len(*req.Password) < 6 {
+		h.badRequest(w, "Password must be at least 6 characters")
+		return
+	}
+
+	user, err := h.storage.UpdateUser(r.PathValue("id"), auth.UserUpdate{
+		Role:     req.Role,
+		Password: req.Password,
+		Disabled: req.Disabled,
+	})
+	if !h.userChangeSucceeded(w, err) {
+		return
+	}
+
+	var changes []string
+	if req.Role != nil {
+		changes = append(changes, "role set to '"+user.EffectiveRole()+"'")
+	}
+	if req.Password != nil {
+		changes = append(changes, "password reset")
+	}
+	if req.Disabled != nil {
+		changes = append(changes, fmt.Sprintf("disabled set to %t", user.Disabled))
+	}
+	h.logAudit(r, "UPDATE_USER", fmt.Sprintf("User '%s' updated: %s", user.Username, strings.Join(changes, ", ")))
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"user":    publicUser(user),
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// DeleteUser removes a user and revokes their API tokens
+func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := h.storage.DeleteUser(r.PathValue("id"))
+	if !h.userChangeSucceeded(w, err) {
+		return
+	}
+
+	h.logAudit(r, "DELETE_USER", fmt.Sprintf("User '%s' deleted", user.Username))
+
+	if err := json.NewEncoder(w).Encode(models.AuthResponse{
+		Success: true,
+		Message: "User deleted",
+	}); err != nil {
+		// Log error if needed, but response is already written
+	}
+}
+
+// userChangeSucceeded writes the error response for a failed user change
+func (h *AuthHandler) userChangeSucceeded(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrUserNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(models.AuthResponse{
+			Success: false,
+			Message: "User not found",
+		}); err != nil {
+			// Log error if needed, but response is already written
+		}
+	case errors.Is(err, auth.ErrLastAdmin):
+		h.badRequest(w, "At least one active admin is required")
+	default:
+		h.internalError(w, err.Error())
+	}
+	return false
+}
+
+// publicUser returns a user's details without the password hash
+func publicUser(user *models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       user.ID,
+		"username": user.Username,
+		"role":     user.EffectiveRole(),
+		"disabled": user.Disabled,
+		"created":  user.Created,
+		"updated":  user.Updated,
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+const (
+	defaultWAFViolations = 100
+	maxWAFViolations     = 1000
+)
+
+// GetWAFViolations returns the latest requests the WAF matched rules for, newest first. The proxy
+// parameter limits them to one proxy, otherwise every proxy with the WAF enabled is included.
+func (h *Handler) GetWAFViolations(w http.ResponseWriter, r *http.Request) {
+	limit := defaultWAFViolations
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxWAFViolations {
+			http.Error(w, fmt.Sprintf(`{"error": "limit must be between 1 and %d"}`, maxWAFViolations), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var proxyIDs []string
+	if id := r.URL.Query().Get("proxy"); id != "" {
+		if _, err := h.CaddyClient.GetProxy(id); err != nil {
+			http.Error(w, `{"error": "Proxy not found"}`, http.StatusNotFound)
+			return
+		}
+		proxyIDs = []string{id}
+	} else {
+		config, err := h.CaddyClient.GetConfig()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error": "Failed to get Caddy config: %v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		for _, proxy := range h.CaddyClient.ParseProxiesFromConfig(config) {
+			if proxy.WAF != nil && proxy.WAF.Enabled {
+				proxyIDs = append(proxyIDs, proxy.ID)
+			}
+		}
+	}
+
+	violations, err := h.CaddyClient.WAFViolations(proxyIDs, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Failed to read WAF logs: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if violations == nil {
+		violations = []caddy.WAFViolation{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"violations": violations,
+		"count":      len(violations),
+	})
+}
@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Proxy wizard steps, validated in this order
+const (
+	WizardStepDomain = "domain"
+	WizardStepDNS    = "dns"
+	WizardStepTarget = "target"
+	WizardStepSSL    = "ssl"
+
+	wizardCheckTimeout = 5 * time.Second
+)
+
+var wizardSteps = []string{WizardStepDomain, WizardStepDNS, WizardStepTarget, WizardStepSSL}
+
+// Check results
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// wizardCheck is the outcome of a single validation with guidance on how to fix it
+type wizardCheck struct {
+	Step     string `json:"step"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Guidance string `json:"guidance,omitempty"`
+}
+
+type wizardRequest struct {
+	proxyRequest
+	ID   string `json:"id"`   // Set when editing, so the proxy doesn't conflict with itself
+	Step string `json:"step"` // Empty validates every step
+}
+
+// ValidateProxyStep validates one step of the proxy creation wizard (or all of them) and
+// returns per-check results with guidance, without changing any configuration
+func (h *Handler) ValidateProxyStep(w http.ResponseWriter, r *http.Request) {
+	var req wizardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	steps := wizardSteps
+	if req.Step != "" {
+		if !slices.Contains(wizardSteps, req.Step) {
+			http.Error(w, fmt.Sprintf(`{"error": "Unknown step: %s (expected one of %s)"}`, req.Step, strings.Join(wizardSteps, ", ")), http.StatusBadRequest)
+			return
+		}
+		steps = []string{req.Step}
+	}
+
+	// Check the ASCII form of internationalized domains, as that is what gets saved and resolved
+	domain := strings.TrimSpace(req.Domain)
+	if normalized, err := hostname.Normalize(domain, true); err == nil {
+		domain = normalized
+	}
+
+	proxy := models.NewProxy(domain, strings.TrimSpace(req.TargetURL), req.SSLMode)
+	proxy.BackupTargetURL = req.BackupTargetURL
+	proxy.ChallengeType = req.ChallengeType
+	proxy.DNSProvider = req.DNSProvider
+	proxy.DNSCredentials = req.DNSCredentials
+	proxy.UpstreamType = req.UpstreamType
+	if proxy.SSLMode == "" {
+		proxy.SSLMode = SSLModeAuto
+	}
+	if proxy.ChallengeType == "" {
+		proxy.ChallengeType = "http"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*wizardCheckTimeout)
+	defer cancel()
+
+	var checks []wizardCheck
+	for _, step := range steps {
+		switch step {
+		case WizardStepDomain:
+			checks = append(checks, h.checkWizardDomain(proxy, req.ID)...)
+		case WizardStepDNS:
+			checks = append(checks, h.checkWizardDNS(ctx, proxy)...)
+		case WizardStepTarget:
+			checks = append(checks, h.checkWizardTarget(ctx, proxy)...)
+		case WizardStepSSL:
+			checks = append(checks, h.checkWizardSSL(ctx, proxy, req.proxyRequest)...)
+		}
+	}
+
+	valid := !slices.ContainsFunc(checks, func(check wizardCheck) bool { return check.Status == CheckFail })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"valid":  valid,
+		"checks": checks,
+	})
+}
+
+// checkWizardDomain checks the domain is well formed and not already managed
+func (h *Handler) checkWizardDomain(proxy *models.Proxy, editingID string) []wizardCheck {
+	if proxy.Domain == "" {
+		return []wizardCheck{{
+			Step: WizardStepDomain, Name: "format", Status: CheckFail,
+			Message:  "Domain is required",
+			Guidance: "Enter the hostname visitors will use, e.g. app.example.com",
+		}}
+	}
+	if strings.Contains(proxy.Domain, "://") || strings.Contains(proxy.Domain, "/") {
+		return []wizardCheck{{
+			Step: WizardStepDomain, Name: "format", Status: CheckFail,
+			Message:  "Domain must be a hostname, not a URL",
+			Guidance: "Remove the scheme and path, e.g. use app.example.com instead of https://app.example.com/",
+		}}
+	}
+	if _, err := hostname.Normalize(proxy.Domain, true); err != nil {
+		return []wizardCheck{{
+			Step: WizardStepDomain, Name: "format", Status: CheckFail,
+			Message:  err.Error(),
+			Guidance: "Use letters, digits and hyphens in each label, e.g. app.example.com. Internationalized names such as bücher.example are converted automatically",
+		}}
+	}
+
+	checks := []wizardCheck{{Step: WizardStepDomain, Name: "format", Status: CheckPass, Message: "Domain is well formed"}}
+
+	config, err := h.CaddyClient.GetConfig()
+	if err != nil {
+		return append(checks, wizardCheck{
+			Step: WizardStepDomain, Name: "conflicts", Status: CheckWarn,
+			Message:  fmt.Sprintf("Could not check for conflicts: %v", err),
+			Guidance: "Make sure Caddy's admin API is reachable",
+		})
+	}
+
+	host := hostWithoutPort(proxy.Domain)
+	for _, existing := range h.CaddyClient.ParseProxiesFromConfig(config) {
+		if existing.ID != editingID && strings.EqualFold(hostWithoutPort(existing.Domain), host) {
+			return append(checks, wizardCheck{
+				Step: WizardStepDomain, Name: "conflicts", Status: CheckFail,
+				Message:  fmt.Sprintf("%s is already served by proxy %s", proxy.Domain, existing.ID),
+				Guidance: "Edit the existing proxy instead, or choose another domain",
+			})
+		}
+	}
+	for _, redirect := range h.CaddyClient.ParseRedirectsFromConfig(config) {
+		if slices.ContainsFunc(redirect.SourceDomains, func(source string) bool { return strings.EqualFold(source, host) }) {
+			return append(checks, wizardCheck{
+				Step: WizardStepDomain, Name: "conflicts", Status: CheckFail,
+				Message:  fmt.Sprintf("%s is already a source of redirect %s", proxy.Domain, redirect.ID),
+				Guidance: "Remove the domain from the redirect first",
+			})
+		}
+	}
+
+	return append(checks, wizardCheck{Step: WizardStepDomain, Name: "conflicts", Status: CheckPass, Message: "Domain is not used by another proxy or redirect"})
+}
+
+// checkWizardDNS checks the domain resolves, and to this server when PUBLIC_IPS is configured
+func (h *Handler) checkWizardDNS(ctx context.Context, proxy *models.Proxy) []wizardCheck {
+	host := hostWithoutPort(proxy.Domain)
+	if ip := net.ParseIP(host); ip != nil {
+		return []wizardCheck{{Step: WizardStepDNS, Name: "resolves", Status: CheckPass, Message: "Domain is an IP address, no DNS needed"}}
+	}
+
+	// Internal and plain HTTP proxies are often reached through local DNS or hosts files
+	failStatus := CheckFail
+	if proxy.SSLMode == SSLModeInternal || proxy.SSLMode == SSLModeNone {
+		failStatus = CheckWarn
+	}
+
+	addrs, err := lookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return []wizardCheck{{
+			Step: WizardStepDNS, Name: "resolves", Status: failStatus,
+			Message:  fmt.Sprintf("%s does not resolve: %v", host, err),
+			Guidance: "Create an A or AAAA record for the domain pointing at this server's IP address. New records can take a few minutes to propagate",
+		}}
+	}
+
+	resolved := make([]string, len(addrs))
+	for i, addr := range addrs {
+		resolved[i] = addr.String()
+	}
+	checks := []wizardCheck{{
+		Step: WizardStepDNS, Name: "resolves", Status: CheckPass,
+		Message: fmt.Sprintf("%s resolves to %s", host, strings.Join(resolved, ", ")),
+	}}
+
+	publicIPs := strings.FieldsFunc(os.Getenv("PUBLIC_IPS"), func(r rune) bool { return r == ',' || r == ' ' })
+	if len(publicIPs) == 0 {
+		return append(checks, wizardCheck{
+			Step: WizardStepDNS, Name: "points_here", Status: CheckWarn,
+			Message:  "Can't tell whether the domain points at this server",
+			Guidance: "Set the PUBLIC_IPS environment variable to this server's public addresses to enable this check",
+		})
+	}
+
+	for _, addr := range addrs {
+		if slices.Contains(publicIPs, addr.String()) {
+			return append(checks, wizardCheck{Step: WizardStepDNS, Name: "points_here", Status: CheckPass, Message: "Domain points at this server"})
+		}
+	}
+
+	return append(checks, wizardCheck{
+		Step: WizardStepDNS, Name: "points_here", Status: failStatus,
+		Message:  fmt.Sprintf("Domain points at %s, but this server is %s", strings.Join(resolved, ", "), strings.Join(publicIPs, ", ")),
+		Guidance: "Update the domain's A/AAAA records, or disable any CDN proxying that hides the real address",
+	})
+}
+
+// checkWizardTarget checks the manager can open a connection to each upstream
+func (h *Handler) checkWizardTarget(ctx context.Context, proxy *models.Proxy) []wizardCheck {
+	if proxy.TargetURL == "" {
+		return []wizardCheck{{
+			Step: WizardStepTarget, Name: "reachable", Status: CheckFail,
+			Message:  "Target URL is required",
+			Guidance: "Enter the address of the service to proxy to, e.g. http://app:8080",
+		}}
+	}
+
+	addresses, err := caddy.UpstreamDialAddresses(*proxy)
+	if err != nil {
+		return []wizardCheck{{
+			Step: WizardStepTarget, Name: "reachable", Status: CheckFail,
+			Message:  fmt.Sprintf("Invalid target URL: %v", err),
+			Guidance: "Use a URL like http://host:port or https://host:port",
+		}}
+	}
+
+	var checks []wizardCheck
+	for _, address := range addresses {
+		network := "tcp"
+		if socket, ok := strings.CutPrefix(address, "unix/"); ok {
+			network, address = "unix", socket
+		}
+
+		conn, err := h.dialTarget(ctx, network, address)
+		if err != nil {
+			checks = append(checks, wizardCheck{
+				Step: WizardStepTarget, Name: "reachable", Status: CheckFail,
+				Message:  fmt.Sprintf("Could not connect to %s: %v", address, err),
+				Guidance: "Check the service is running and listening on that port. In Docker, use the container name on a shared network rather than localhost",
+			})
+			continue
+		}
+		conn.Close()
+
+		checks = append(checks, wizardCheck{Step: WizardStepTarget, Name: "reachable", Status: CheckPass, Message: fmt.Sprintf("Connected to %s", address)})
+	}
+
+	return checks
+}
+
+// checkWizardSSL checks whether the selected SSL mode can obtain a certificate for the domain
+func (h *Handler) checkWizardSSL(ctx context.Context, proxy *models.Proxy, req proxyRequest) []wizardCheck {
+	switch proxy.SSLMode {
+	case SSLModeNone:
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "certificate", Status: CheckWarn,
+			Message:  "Traffic to this proxy will not be encrypted",
+			Guidance: "Use automatic HTTPS for public domains or internal certificates for LAN-only domains",
+		}}
+	case SSLModeInternal:
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "certificate", Status: CheckPass,
+			Message:  "Certificate will be issued by the local CA",
+			Guidance: "Install the root certificate from /api/ca/root.crt on clients so browsers trust it",
+		}}
+	case SSLModeExisting:
+		host := hostWithoutPort(proxy.Domain)
+		if status := h.CaddyClient.CertificateStatus(host); status.Status != caddy.CertificateIssued {
+			return []wizardCheck{{
+				Step: WizardStepSSL, Name: "certificate", Status: CheckWarn,
+				Message:  fmt.Sprintf("Caddy doesn't hold a valid certificate for %s yet", host),
+				Guidance: "Pre-provision or upload (on another proxy) a wildcard certificate covering the domain, or use SSL mode custom to upload one for this proxy. No certificate will be requested via ACME",
+			}}
+		}
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "certificate", Status: CheckPass,
+			Message: fmt.Sprintf("Caddy already serves a valid certificate for %s", host),
+		}}
+	case SSLModeAuto:
+	default:
+		if _, err := h.buildProxy(req); err != nil {
+			return []wizardCheck{{Step: WizardStepSSL, Name: "certificate", Status: CheckFail, Message: err.Error()}}
+		}
+		return []wizardCheck{{Step: WizardStepSSL, Name: "certificate", Status: CheckPass, Message: "SSL settings are valid"}}
+	}
+
+	if proxy.ChallengeType == "dns" {
+		if proxy.DNSProvider == "" {
+			return []wizardCheck{{
+				Step: WizardStepSSL, Name: "dns_challenge", Status: CheckFail,
+				Message:  "DNS provider is required for the DNS challenge",
+				Guidance: "Choose the provider hosting the domain's DNS zone",
+			}}
+		}
+		if err := h.validateDNSCredentials(proxy.DNSProvider, proxy.DNSCredentials); err != nil {
+			return []wizardCheck{{
+				Step: WizardStepSSL, Name: "dns_challenge", Status: CheckFail,
+				Message:  err.Error(),
+				Guidance: "Create an API token with permission to edit DNS records for the zone",
+			}}
+		}
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "dns_challenge", Status: CheckPass,
+			Message: fmt.Sprintf("Certificate will be issued via the %s DNS challenge", proxy.DNSProvider),
+		}}
+	}
+
+	// The HTTP challenge needs a public name that the CA can reach on port 80
+	host := hostWithoutPort(proxy.Domain)
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") || isLocalOnlyDomain(host) {
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "http_challenge", Status: CheckFail,
+			Message:  fmt.Sprintf("Public certificate authorities won't issue certificates for %s", host),
+			Guidance: "Use internal certificates for LAN-only names, or the DNS challenge for a real domain that isn't publicly reachable",
+		}}
+	}
+
+	addrs, err := lookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return []wizardCheck{{
+			Step: WizardStepSSL, Name: "http_challenge", Status: CheckFail,
+			Message:  "The HTTP challenge needs the domain to resolve publicly",
+			Guidance: "Fix DNS first, or use the DNS challenge",
+		}}
+	}
+	for _, addr := range addrs {
+		if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			return []wizardCheck{{
+				Step: WizardStepSSL, Name: "http_challenge", Status: CheckFail,
+				Message:  fmt.Sprintf("%s resolves to the private address %s, which the certificate authority can't reach", host, addr),
+				Guidance: "Use the DNS challenge or internal certificates for private services",
+			}}
+		}
+	}
+
+	return []wizardCheck{{
+		Step: WizardStepSSL, Name: "http_challenge", Status: CheckPass,
+		Message:  "Certificate can be issued via the HTTP challenge",
+		Guidance: "Make sure port 80 is forwarded to this server; the challenge is answered there",
+	}}
+}
+
+// dialTarget opens a test connection, going through the outbound guard for TCP targets
+func (h *Handler) dialTarget(ctx context.Context, network, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, wizardCheckTimeout)
+	defer cancel()
+
+	if network == "tcp" && h.OutboundGuard != nil {
+		return h.OutboundGuard.DialContext(ctx, network, address)
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// lookupHost resolves a hostname with the wizard's check timeout
+func lookupHost(ctx context.Context, host string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, wizardCheckTimeout)
+	defer cancel()
+
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// hostWithoutPort strips an optional port from a domain
+func hostWithoutPort(domain string) string {
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		return host
+	}
+	return domain
+}
+
+// isLocalOnlyDomain reports whether a domain uses a suffix that never resolves publicly
+func isLocalOnlyDomain(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, suffix := range []string{".local", ".lan", ".home", ".internal", ".localhost", ".home.arpa", ".test"} {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return host == "localhost"
+}
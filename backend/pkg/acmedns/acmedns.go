@@ -0,0 +1,67 @@
+// Package acmedns registers accounts with an acme-dns server (https://github.com/joohoi/acme-dns),
+// which answers DNS-01 challenges for domains whose DNS host has no API. Each domain delegates its
+// _acme-challenge record to the account's subdomain with a CNAME.
+package acmedns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultServerURL is the public acme-dns instance
+const DefaultServerURL = "https://auth.acme-dns.io"
+
+// Registration is the account returned by an acme-dns server's /register endpoint
+type Registration struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Subdomain  string `json:"subdomain"`
+	FullDomain string `json:"fulldomain"`
+}
+
+// Register creates a new account on the acme-dns server at serverURL
+func Register(ctx context.Context, client *http.Client, serverURL string) (*Registration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(serverURL, "/")+"/register", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach acme-dns server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("acme-dns server returned status %d", resp.StatusCode)
+	}
+
+	var registration Registration
+	if err := json.NewDecoder(resp.Body).Decode(&registration); err != nil {
+		return nil, fmt.Errorf("invalid acme-dns response: %v", err)
+	}
+	if registration.Username == "" || registration.Password == "" || registration.FullDomain == "" {
+		return nil, fmt.Errorf("acme-dns response is missing account details")
+	}
+
+	return &registration, nil
+}
+
+// ChallengeRecord returns the record name that must be a CNAME to the account's full domain. A
+// wildcard shares the challenge record of its base domain.
+func ChallengeRecord(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+}
+
+// CNAMEConfigured reports whether the domain's challenge record points at fullDomain
+func CNAMEConfigured(ctx context.Context, domain, fullDomain string) bool {
+	target, err := net.DefaultResolver.LookupCNAME(ctx, ChallengeRecord(domain))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSuffix(target, "."), strings.TrimSuffix(fullDomain, "."))
+}
@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Finding kinds
+const (
+	FindingFailedLogins = "failed_logins" // Repeated failed logins from one IP address
+	FindingNewIPLogin   = "new_ip_login"  // A user logged in from an address they never used before
+	FindingMassDeletion = "mass_deletion" // One user deleted many resources
+)
+
+// Thresholds for raising findings within the analysed window
+const (
+	FailedLoginThreshold  = 5
+	MassDeletionThreshold = 10
+)
+
+// ActionSecurityAlert is the audit action recorded for findings, which the analysis skips
+const ActionSecurityAlert = "SECURITY_ALERT"
+
+// failedActions are audit actions counted as failed authentication attempts
+var failedActions = map[string]bool{
+	"LOGIN_FAILED":           true,
+	"SETUP_FAILED":           true,
+	"CHANGE_PASSWORD_FAILED": true,
+}
+
+// Finding is a suspicious pattern in the audit log
+type Finding struct {
+	Kind      string    `json:"kind"`
+	Severity  string    `json:"severity"` // "warning" or "critical"
+	IPAddress string    `json:"ip_address,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Count     int       `json:"count"`
+	First     time.Time `json:"first"`
+	Last      time.Time `json:"last"`
+	Details   string    `json:"details"`
+}
+
+// Analyze looks for suspicious patterns in the entries logged since since. Entries must be oldest
+// first; earlier entries only serve as history, e.g. the addresses a user logged in from before.
+func Analyze(entries []Entry, since time.Time) []Finding {
+	knownIPs := make(map[string]map[string]bool) // username -> addresses of earlier logins
+	failed := make(map[string]*Finding)          // keyed by address
+	deletions := make(map[string]*Finding)       // keyed by username
+	var findings []Finding
+
+	for _, entry := range entries {
+		ip := clientIP(entry.IPAddress)
+
+		if entry.Timestamp.Before(since) {
+			if entry.Action == "LOGIN_SUCCESS" && ip != "" {
+				remember(knownIPs, entry.Username, ip)
+			}
+			continue
+		}
+
+		switch {
+		case failedActions[entry.Action] && ip != "":
+			count(failed, ip, entry, Finding{Kind: FindingFailedLogins, IPAddress: ip})
+
+		case entry.Action == "LOGIN_SUCCESS" && ip != "":
+			known, seen := knownIPs[entry.Username]
+			if seen && !known[ip] {
+				findings = append(findings, Finding{
+					Kind:      FindingNewIPLogin,
+					Severity:  "warning",
+					IPAddress: ip,
+					Username:  entry.Username,
+					Count:     1,
+					First:     entry.Timestamp,
+					Last:      entry.Timestamp,
+					Details:   fmt.Sprintf("User '%s' logged in from new address %s", entry.Username, ip),
+				})
+			}
+			remember(knownIPs, entry.Username, ip)
+
+		case strings.HasPrefix(entry.Action, "DELETE_") && entry.Username != "":
+			count(deletions, entry.Username, entry, Finding{Kind: FindingMassDeletion, Username: entry.Username})
+		}
+	}
+
+	for _, finding := range failed {
+		if finding.Count < FailedLoginThreshold {
+			continue
+		}
+		finding.Severity = "warning"
+		if finding.Count >= 4*FailedLoginThreshold {
+			finding.Severity = "critical"
+		}
+		finding.Details = fmt.Sprintf("%d failed login attempts from %s", finding.Count, finding.IPAddress)
+		findings = append(findings, *finding)
+	}
+
+	for _, finding := range deletions {
+		if finding.Count < MassDeletionThreshold {
+			continue
+		}
+		finding.Severity = "critical"
+		finding.Details = fmt.Sprintf("User '%s' deleted %d resources", finding.Username, finding.Count)
+		findings = append(findings, *finding)
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Last.After(findings[j].Last)
+	})
+	return findings
+}
+
+// count adds an entry to the finding tracked under key, starting it from template
+func count(findings map[string]*Finding, key string, entry Entry, template Finding) {
+	finding, exists := findings[key]
+	if !exists {
+		template.First = entry.Timestamp
+		finding = &template
+		findings[key] = finding
+	}
+	finding.Count++
+	finding.Last = entry.Timestamp
+}
+
+func remember(known map[string]map[string]bool, username, ip string) {
+	if known[username] == nil {
+		known[username] = make(map[string]bool)
+	}
+	known[username][ip] = true
+}
+
+// clientIP reduces a logged address, a RemoteAddr with port or an X-Forwarded-For list, to the
+// client's IP
+func clientIP(address string) string {
+	address = strings.TrimSpace(strings.Split(address, ",")[0])
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
@@ -1,15 +1,17 @@
 package audit
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/store"
 )
 
+// logName is the log audit entries are appended to
+const logName = "audit.log"
+
 // Entry represents a single audit log entry
 type Entry struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -22,17 +24,20 @@ type Entry struct {
 
 // Service handles audit logging
 type Service struct {
-	mu       sync.RWMutex
-	dataDir  string
-	filename string
+	mu    sync.RWMutex
+	store store.Store
 }
 
-// NewService creates a new audit log service
+// NewService creates a new audit log service writing to audit.log in dataDir
 func NewService(dataDir string) *Service {
-	return &Service{
-		dataDir:  dataDir,
-		filename: filepath.Join(dataDir, "audit.log"),
-	}
+	return &Service{store: store.NewFile(dataDir)}
+}
+
+// SetStore appends entries to the audit log in st instead of the data directory
+func (s *Service) SetStore(st store.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
 }
 
 // Log writes an audit log entry
@@ -40,11 +45,6 @@ func (s *Service) Log(action, details, userID, username, ipAddress string) error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Ensure data directory exists
-	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
-	}
-
 	// Create entry
 	entry := Entry{
 		Timestamp: time.Now(),
@@ -61,17 +61,8 @@ func (s *Service) Log(action, details, userID, username, ipAddress string) error
 		return fmt.Errorf("failed to marshal audit entry: %w", err)
 	}
 
-	// Open file for appending
-	file, err := os.OpenFile(s.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open audit log file: %w", err)
-	}
-	defer file.Close()
-
-	// Write entry as JSONL (JSON Line)
-	_, err = file.Write(append(data, '\n'))
-	if err != nil {
-		return fmt.Errorf("failed to write to audit log file: %w", err)
+	if err := s.store.Append(logName, data); err != nil {
+		return fmt.Errorf("failed to write to audit log: %w", err)
 	}
 
 	return nil
@@ -79,33 +70,38 @@ func (s *Service) Log(action, details, userID, username, ipAddress string) error
 
 // GetRecentEntries retrieves the most recent audit log entries
 func (s *Service) GetRecentEntries(limit int) ([]Entry, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse the slice to get most recent first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	// Limit results
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Entries retrieves every audit log entry, oldest first
+func (s *Service) Entries() ([]Entry, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Open file for reading
-	file, err := os.Open(s.filename)
+	records, err := s.store.Records(logName)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []Entry{}, nil // Return empty slice if file doesn't exist
-		}
-		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
 	}
-	defer file.Close()
 
-	// Read file in reverse to get most recent entries first
-	scanner := bufio.NewScanner(file)
 	entries := []Entry{}
-
-	// For simplicity, we'll read all entries and then limit
-	// In a production system, you might want to implement a more efficient approach
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-
+	for _, record := range records {
 		var entry Entry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		if err := json.Unmarshal(record, &entry); err != nil {
 			// Skip malformed entries
 			continue
 		}
@@ -113,19 +109,5 @@ func (s *Service) GetRecentEntries(limit int) ([]Entry, error) {
 		entries = append(entries, entry)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading audit log file: %w", err)
-	}
-
-	// Reverse the slice to get most recent first
-	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
-		entries[i], entries[j] = entries[j], entries[i]
-	}
-
-	// Limit results
-	if len(entries) > limit {
-		entries = entries[:limit]
-	}
-
 	return entries, nil
 }
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/store"
+)
+
+const (
+	// APITokenPrefix starts every API token, telling them apart from session tokens
+	APITokenPrefix = "cpm_"
+
+	ScopeRead  = "read"  // GET requests
+	ScopeWrite = "write" // All other requests
+
+	// lastUsedResolution limits how often token use is written to disk
+	lastUsedResolution = time.Minute
+
+	// proxyRoutes starts the path pattern of every route of a single proxy
+	proxyRoutes = "/api/proxies/{id}"
+)
+
+// ValidScope reports whether scope is a known API token scope
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite
+}
+
+// ScopeAllows reports whether an API token's scopes permit a request method
+func ScopeAllows(scopes []string, method string) bool {
+	if method == http.MethodGet || method == http.MethodHead {
+		return slices.Contains(scopes, ScopeRead) || slices.Contains(scopes, ScopeWrite)
+	}
+	return slices.Contains(scopes, ScopeWrite)
+}
+
+// ProxyLimited reports whether an API token is limited to some proxies
+func ProxyLimited(token *models.APIToken) bool {
+	return len(token.ProxyIDs) > 0 || len(token.Tags) > 0
+}
+
+// ProxyScopeAllows reports whether an API token may make a request. Tokens limited to proxies may
+// only use the routes of a single proxy listed by ID or carrying one of their tags; proxyTags looks
+// up a proxy's tags.
+func ProxyScopeAllows(token *models.APIToken, r *http.Request, proxyTags func(proxyID string) []string) bool {
+	if !ProxyLimited(token) {
+		return true
+	}
+
+	_, path, _ := strings.Cut(r.Pattern, " ")
+	if path != proxyRoutes && !strings.HasPrefix(path, proxyRoutes+"/") {
+		return false
+	}
+
+	proxyID := r.PathValue("id")
+	if slices.Contains(token.ProxyIDs, proxyID) {
+		return true
+	}
+	if len(token.Tags) == 0 || proxyTags == nil {
+		return false
+	}
+	return slices.ContainsFunc(proxyTags(proxyID), func(tag string) bool {
+		return slices.Contains(token.Tags, tag)
+	})
+}
+
+// CreateAPIToken creates a token for a user, returning it along with the token value, which is
+// not stored and can't be shown again. A zero expires creates a token that doesn't expire, and
+// proxyIDs and tags limit it to those proxies.
+func (s *Storage) CreateAPIToken(userID, name string, scopes, proxyIDs, tags []string, expires time.Time) (*models.APIToken, string, error) {
+	id, err := GenerateID()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	secret, err := GenerateToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	value := APITokenPrefix + secret
+
+	token := &models.APIToken{
+		ID:        id,
+		Name:      name,
+		UserID:    userID,
+		TokenHash: HashToken(value),
+		Prefix:    value[:len(APITokenPrefix)+8],
+		Scopes:    scopes,
+		ProxyIDs:  proxyIDs,
+		Tags:      tags,
+		Created:   time.Now(),
+		Expires:   expires,
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiTokens[id] = token
+	if err := s.saveAPITokens(); err != nil {
+		delete(s.apiTokens, id)
+		return nil, "", fmt.Errorf("failed to save API token: %w", err)
+	}
+
+	return publicAPIToken(token), value, nil
+}
+
+// ListAPITokens returns all API tokens, newest first, without their hashes
+func (s *Storage) ListAPITokens() []*models.APIToken {
+	if err := s.refresh(); err != nil {
+		fmt.Printf("Warning: Listing API tokens without changes from other replicas: %v\n", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]*models.APIToken, 0, len(s.apiTokens))
+	for _, token := range s.apiTokens {
+		tokens = append(tokens, publicAPIToken(token))
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Created.After(tokens[j].Created)
+	})
+	return tokens
+}
+
+// DeleteAPIToken revokes an API token
+func (s *Storage) DeleteAPIToken(id string) (*models.APIToken, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.apiTokens[id]
+	if !exists {
+		return nil, fmt.Errorf("API token not found")
+	}
+
+	delete(s.apiTokens, id)
+	if err := s.saveAPITokens(); err != nil {
+		s.apiTokens[id] = token
+		return nil, fmt.Errorf("failed to save API tokens: %w", err)
+	}
+
+	return publicAPIToken(token), nil
+}
+
+// deleteUserAPITokens revokes every token of a user; the caller must hold the write lock
+func (s *Storage) deleteUserAPITokens(userID string) {
+	deleted := false
+	for id, token := range s.apiTokens {
+		if token.UserID == userID {
+			delete(s.apiTokens, id)
+			deleted = true
+		}
+	}
+
+	if deleted {
+		if err := s.saveAPITokens(); err != nil {
+			fmt.Printf("Warning: Failed to save API tokens: %v\n", err)
+		}
+	}
+}
+
+// GetAPIToken returns the API token matching value, recording its use. Expired tokens are rejected,
+// as are tokens revoked on another replica.
+func (s *Storage) GetAPIToken(value string) (*models.APIToken, error) {
+	if !strings.HasPrefix(value, APITokenPrefix) {
+		return nil, fmt.Errorf("not an API token")
+	}
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.apiTokens {
+		if !TokenMatchesHash(value, token.TokenHash) {
+			continue
+		}
+		if !token.Expires.IsZero() && IsSessionExpired(token.Expires) {
+			return nil, fmt.Errorf("API token expired")
+		}
+
+		if now := time.Now(); now.Sub(token.LastUsed) > lastUsedResolution {
+			token.LastUsed = now
+			if err := s.saveAPITokens(); err != nil {
+				fmt.Printf("Warning: Failed to record API token use: %v\n", err)
+			}
+		}
+		return publicAPIToken(token), nil
+	}
+
+	return nil, fmt.Errorf("invalid API token")
+}
+
+// publicAPIToken returns a copy of token without its hash
+func publicAPIToken(token *models.APIToken) *models.APIToken {
+	public := *token
+	public.TokenHash = ""
+	return &public
+}
+
+func (s *Storage) loadAPITokens() error {
+	data, err := s.store.Load(apiTokensDocument)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil // Never saved, that's OK
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read API tokens: %w", err)
+	}
+
+	var tokens map[string]*models.APIToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("failed to unmarshal API tokens: %w", err)
+	}
+
+	if tokens != nil {
+		s.apiTokens = tokens
+	}
+	return nil
+}
+
+// saveAPITokens persists the tokens; the caller must hold the write lock
+func (s *Storage) saveAPITokens() error {
+	data, err := json.MarshalIndent(s.apiTokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal API tokens: %w", err)
+	}
+
+	if err := s.store.Save(apiTokensDocument, data); err != nil {
+		return fmt.Errorf("failed to write API tokens: %w", err)
+	}
+
+	return nil
+}
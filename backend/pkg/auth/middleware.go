@@ -13,13 +13,26 @@ import (
 type contextKey string
 
 const (
-	UserContextKey    contextKey = "user"
-	SessionContextKey contextKey = "session"
-	AuthTrue          string     = "true"
+	UserContextKey     contextKey = "user"
+	SessionContextKey  contextKey = "session"
+	APITokenContextKey contextKey = "api_token"
+	AuthTrue           string     = "true"
 )
 
+// configViewerRoutes are the only routes config viewers may use: the masked config export and
+// their own account
+var configViewerRoutes = map[string]bool{
+	"GET /api/config/export":         true,
+	"GET /api/auth/me":               true,
+	"POST /api/auth/change-password": true,
+}
+
 type Middleware struct {
 	storage *Storage
+
+	// ProxyTags looks up the tags of a proxy, for API tokens limited to tags. Without it those
+	// tokens only reach the proxies they list by ID.
+	ProxyTags func(proxyID string) []string
 }
 
 func NewMiddleware(storage *Storage) *Middleware {
@@ -54,6 +67,11 @@ func (m *Middleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(token, APITokenPrefix) {
+			m.requireAPIToken(w, r, token, next)
+			return
+		}
+
 		// Validate session
 		session, err := m.storage.GetSession(token)
 		if err != nil {
@@ -61,19 +79,92 @@ func (m *Middleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// Get user (optional, for additional context)
-		user, _ := m.storage.GetUserByID(session.UserID)
+		// Sessions of deleted or disabled users are no longer valid
+		user, valid := m.storage.UserSessionValid(session)
+		if !valid {
+			m.unauthorized(w, "Invalid or expired session")
+			return
+		}
+
+		if !roleAllowsRoute(user, r) {
+			m.forbidden(w, "Your role does not allow this action")
+			return
+		}
 
 		// Add to context
 		ctx := context.WithValue(r.Context(), SessionContextKey, session)
-		if user != nil {
-			ctx = context.WithValue(ctx, UserContextKey, user)
-		}
+		ctx = context.WithValue(ctx, UserContextKey, user)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// RequireRole authenticates the request like RequireAuth and then rejects users without role.
+// Admins pass every role check.
+func (m *Middleware) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return m.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		// Check if auth is disabled
+		if os.Getenv("DISABLE_AUTH") == AuthTrue {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := GetUserFromContext(r.Context())
+		if user == nil {
+			m.unauthorized(w, "Not authenticated")
+			return
+		}
+
+		if userRole := user.EffectiveRole(); userRole != role && userRole != models.RoleAdmin {
+			m.forbidden(w, "Your role does not allow this action")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIToken authenticates a request made with an API token, checking its scopes
+func (m *Middleware) requireAPIToken(w http.ResponseWriter, r *http.Request, token string, next http.HandlerFunc) {
+	apiToken, err := m.storage.GetAPIToken(token)
+	if err != nil {
+		m.unauthorized(w, "Invalid or expired API token")
+		return
+	}
+
+	if !ScopeAllows(apiToken.Scopes, r.Method) {
+		m.forbidden(w, "API token does not have the write scope")
+		return
+	}
+
+	if !ProxyScopeAllows(apiToken, r, m.ProxyTags) {
+		m.forbidden(w, "API token is limited to other proxies")
+		return
+	}
+
+	user, err := m.storage.GetUserByID(apiToken.UserID)
+	if err != nil || user.Disabled {
+		m.unauthorized(w, "API token owner no longer exists or is disabled")
+		return
+	}
+
+	if !roleAllowsRoute(user, r) {
+		m.forbidden(w, "Your role does not allow this action")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), APITokenContextKey, apiToken)
+	ctx = context.WithValue(ctx, UserContextKey, user)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// roleAllowsRoute reports whether the user's role may use the request's route. Config viewers are
+// limited to configViewerRoutes; other roles are checked by RequireRole.
+func roleAllowsRoute(user *models.User, r *http.Request) bool {
+	return user.EffectiveRole() != models.RoleConfigViewer || configViewerRoutes[r.Pattern]
+}
+
 func (m *Middleware) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if auth is disabled
@@ -91,15 +182,12 @@ func (m *Middleware) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 				if token != "" {
 					// Validate session
 					if session, err := m.storage.GetSession(token); err == nil {
-						// Get user
-						user, _ := m.storage.GetUserByID(session.UserID)
-
-						// Add to context
-						ctx := context.WithValue(r.Context(), SessionContextKey, session)
-						if user != nil {
+						// Get user, ignoring sessions of deleted or disabled users
+						if user, valid := m.storage.UserSessionValid(session); valid {
+							ctx := context.WithValue(r.Context(), SessionContextKey, session)
 							ctx = context.WithValue(ctx, UserContextKey, user)
+							r = r.WithContext(ctx)
 						}
-						r = r.WithContext(ctx)
 					}
 				}
 			}
@@ -146,7 +234,7 @@ func (m *Middleware) CheckSetup(next http.HandlerFunc) http.HandlerFunc {
 func (m *Middleware) CORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
@@ -194,3 +282,10 @@ func GetSessionFromContext(ctx context.Context) *models.Session {
 	}
 	return nil
 }
+
+func GetAPITokenFromContext(ctx context.Context) *models.APIToken {
+	if token, ok := ctx.Value(APITokenContextKey).(*models.APIToken); ok {
+		return token
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	argon2Prefix     = "$argon2id$"
+)
+
+// PasswordHashing configures how new passwords are hashed. Existing hashes of either algorithm are
+// still accepted, and are upgraded when a user logs in.
+type PasswordHashing struct {
+	Algorithm     string // HashBcrypt or HashArgon2id
+	BcryptCost    int
+	Argon2Time    uint32 // Iterations
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+}
+
+// DefaultPasswordHashing is bcrypt at its default cost, with the Argon2id parameters recommended by
+// RFC 9106 for when Argon2id is chosen
+var DefaultPasswordHashing = PasswordHashing{
+	Algorithm:     HashBcrypt,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+}
+
+var (
+	hashingMu sync.RWMutex
+	hashing   = DefaultPasswordHashing
+)
+
+// SetPasswordHashing validates and applies the hashing settings used for new passwords
+func SetPasswordHashing(config PasswordHashing) error {
+	switch config.Algorithm {
+	case HashBcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case HashArgon2id:
+		if config.Argon2Time < 1 {
+			return fmt.Errorf("argon2id time must be at least 1")
+		}
+		if config.Argon2Memory < 8*uint32(config.Argon2Threads) || config.Argon2Memory < 1024 {
+			return fmt.Errorf("argon2id memory must be at least 1024 KiB and 8 KiB per thread")
+		}
+		if config.Argon2Threads < 1 {
+			return fmt.Errorf("argon2id threads must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q, expected %s or %s", config.Algorithm, HashBcrypt, HashArgon2id)
+	}
+
+	hashingMu.Lock()
+	hashing = config
+	hashingMu.Unlock()
+	return nil
+}
+
+func currentHashing() PasswordHashing {
+	hashingMu.RLock()
+	defer hashingMu.RUnlock()
+	return hashing
+}
+
+func HashPassword(password string) (string, error) {
+	config := currentHashing()
+	if config.Algorithm == HashArgon2id {
+		return hashArgon2id(password, config)
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost)
+	return string(bytes), err
+}
+
+func CheckPassword(password, hash string) bool {
+	if strings.HasPrefix(hash, argon2Prefix) {
+		return checkArgon2id(password, hash)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other parameters than the
+// current settings
+func NeedsRehash(hash string) bool {
+	config := currentHashing()
+
+	if strings.HasPrefix(hash, argon2Prefix) {
+		if config.Algorithm != HashArgon2id {
+			return true
+		}
+		params, _, _, err := parseArgon2id(hash)
+		return err != nil || params.Argon2Time != config.Argon2Time || params.Argon2Memory != config.Argon2Memory || params.Argon2Threads != config.Argon2Threads
+	}
+
+	if config.Algorithm != HashBcrypt {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != config.BcryptCost
+}
+
+// hashArgon2id encodes an Argon2id hash in the PHC string format,
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+func hashArgon2id(password string, config PasswordHashing) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, config.Argon2Time, config.Argon2Memory, config.Argon2Threads, argon2KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, config.Argon2Memory, config.Argon2Time, config.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkArgon2id(password, hash string) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+func parseArgon2id(hash string) (PasswordHashing, []byte, []byte, error) {
+	params := PasswordHashing{Algorithm: HashArgon2id}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+	if params.Argon2Time < 1 || params.Argon2Threads < 1 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key")
+	}
+
+	return params, salt, key, nil
+}
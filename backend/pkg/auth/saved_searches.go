@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/store"
+)
+
+// ErrSavedSearchNotFound is returned when a saved search ID doesn't exist
+var ErrSavedSearchNotFound = errors.New("saved search not found")
+
+// ListSavedSearches returns a user's own saved searches and those shared by others, sorted by name
+func (s *Storage) ListSavedSearches(userID string) []*models.SavedSearch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	searches := make([]*models.SavedSearch, 0)
+	for _, search := range s.savedSearches {
+		if search.UserID == userID || search.Shared {
+			copied := *search
+			searches = append(searches, &copied)
+		}
+	}
+
+	sort.Slice(searches, func(i, j int) bool {
+		if a, b := strings.ToLower(searches[i].Name), strings.ToLower(searches[j].Name); a != b {
+			return a < b
+		}
+		return searches[i].ID < searches[j].ID
+	})
+	return searches
+}
+
+// GetSavedSearch returns a saved search by ID
+func (s *Storage) GetSavedSearch(id string) (*models.SavedSearch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search, exists := s.savedSearches[id]
+	if !exists {
+		return nil, ErrSavedSearchNotFound
+	}
+	copied := *search
+	return &copied, nil
+}
+
+// CreateSavedSearch stores a new saved search owned by userID
+func (s *Storage) CreateSavedSearch(userID, name string, filters map[string]string, shared bool) (*models.SavedSearch, error) {
+	id, err := GenerateID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate saved search ID: %w", err)
+	}
+
+	now := time.Now()
+	search := &models.SavedSearch{
+		ID:      id,
+		Name:    name,
+		UserID:  userID,
+		Filters: filters,
+		Shared:  shared,
+		Created: now,
+		Updated: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.savedSearches[id] = search
+	if err := s.saveSavedSearches(); err != nil {
+		delete(s.savedSearches, id)
+		return nil, fmt.Errorf("failed to save saved search: %w", err)
+	}
+
+	copied := *search
+	return &copied, nil
+}
+
+// UpdateSavedSearch replaces the name, filters and sharing of a saved search
+func (s *Storage) UpdateSavedSearch(id, name string, filters map[string]string, shared bool) (*models.SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search, exists := s.savedSearches[id]
+	if !exists {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	updated := *search
+	updated.Name = name
+	updated.Filters = filters
+	updated.Shared = shared
+	updated.Updated = time.Now()
+
+	s.savedSearches[id] = &updated
+	if err := s.saveSavedSearches(); err != nil {
+		s.savedSearches[id] = search
+		return nil, fmt.Errorf("failed to save saved search: %w", err)
+	}
+
+	copied := updated
+	return &copied, nil
+}
+
+// DeleteSavedSearch removes a saved search
+func (s *Storage) DeleteSavedSearch(id string) (*models.SavedSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	search, exists := s.savedSearches[id]
+	if !exists {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	delete(s.savedSearches, id)
+	if err := s.saveSavedSearches(); err != nil {
+		s.savedSearches[id] = search
+		return nil, fmt.Errorf("failed to save saved searches: %w", err)
+	}
+
+	return search, nil
+}
+
+// deleteUserSavedSearches removes every saved search of a user, shared ones included; the caller
+// must hold the write lock
+func (s *Storage) deleteUserSavedSearches(userID string) {
+	deleted := false
+	for id, search := range s.savedSearches {
+		if search.UserID == userID {
+			delete(s.savedSearches, id)
+			deleted = true
+		}
+	}
+
+	if deleted {
+		if err := s.saveSavedSearches(); err != nil {
+			fmt.Printf("Warning: Failed to save saved searches: %v\n", err)
+		}
+	}
+}
+
+func (s *Storage) loadSavedSearches() error {
+	data, err := s.store.Load(savedSearchesDocument)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil // Never saved, that's OK
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read saved searches: %w", err)
+	}
+
+	var searches map[string]*models.SavedSearch
+	if err := json.Unmarshal(data, &searches); err != nil {
+		return fmt.Errorf("failed to unmarshal saved searches: %w", err)
+	}
+
+	if searches != nil {
+		s.savedSearches = searches
+	}
+	return nil
+}
+
+// saveSavedSearches persists the saved searches; the caller must hold the write lock
+func (s *Storage) saveSavedSearches() error {
+	data, err := json.MarshalIndent(s.savedSearches, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved searches: %w", err)
+	}
+
+	if err := s.store.Save(savedSearchesDocument, data); err != nil {
+		return fmt.Errorf("failed to write saved searches: %w", err)
+	}
+
+	return nil
+}
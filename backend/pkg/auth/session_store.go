@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// SessionStore persists authentication sessions keyed by token
+type SessionStore interface {
+	// Initialize prepares the store, loading or connecting as needed
+	Initialize() error
+	// Save stores a session, replacing any existing session with the same token
+	Save(session *models.Session) error
+	// Get returns the session for a token, or an error if it doesn't exist
+	Get(token string) (*models.Session, error)
+	// Delete removes the session for a token
+	Delete(token string) error
+	// DeleteExpired removes all expired sessions
+	DeleteExpired() error
+}
+
+// FileSessionStore keeps sessions in memory and persists them to sessions.json in the data directory
+type FileSessionStore struct {
+	mu       sync.RWMutex
+	filePath string
+	sessions map[string]*models.Session
+}
+
+// NewFileSessionStore creates a session store backed by a JSON file in dataDir
+func NewFileSessionStore(dataDir string) *FileSessionStore {
+	return &FileSessionStore{
+		filePath: filepath.Join(dataDir, "sessions.json"),
+		sessions: make(map[string]*models.Session),
+	}
+}
+
+func (f *FileSessionStore) Initialize() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := os.Stat(f.filePath); os.IsNotExist(err) {
+		return nil // File doesn't exist, that's OK
+	}
+
+	data, err := os.ReadFile(f.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions file: %w", err)
+	}
+
+	var sessions map[string]*models.Session
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return fmt.Errorf("failed to unmarshal sessions: %w", err)
+	}
+
+	if sessions != nil {
+		f.sessions = sessions
+	}
+	return nil
+}
+
+func (f *FileSessionStore) Save(session *models.Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sessions[session.Token] = session
+	return f.save()
+}
+
+func (f *FileSessionStore) Get(token string) (*models.Session, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	session, exists := f.sessions[token]
+	if !exists {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	return session, nil
+}
+
+func (f *FileSessionStore) Delete(token string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.sessions, token)
+	return f.save()
+}
+
+func (f *FileSessionStore) DeleteExpired() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for token, session := range f.sessions {
+		if IsSessionExpired(session.Expires) {
+			delete(f.sessions, token)
+		}
+	}
+
+	return f.save()
+}
+
+func (f *FileSessionStore) save() error {
+	data, err := json.MarshalIndent(f.sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sessions: %w", err)
+	}
+
+	if err := os.WriteFile(f.filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sessions file: %w", err)
+	}
+
+	return nil
+}
@@ -2,43 +2,141 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/store"
+)
+
+// Documents the storage keeps its state in
+const (
+	usersDocument         = "users.json"
+	apiTokensDocument     = "api_tokens.json"
+	savedSearchesDocument = "saved_searches.json"
+)
+
+var (
+	// ErrAlreadySetup is returned when creating the initial user after setup has completed
+	ErrAlreadySetup = errors.New("system already setup")
+	// ErrUserNotFound is returned when a user ID doesn't exist
+	ErrUserNotFound = errors.New("user not found")
+	// ErrLastAdmin is returned when a change would leave no enabled admin
+	ErrLastAdmin = errors.New("at least one active admin is required")
 )
 
 type Storage struct {
-	mu       sync.RWMutex
-	dataDir  string
-	users    map[string]*models.User
-	sessions map[string]*models.Session
+	mu            sync.RWMutex
+	store         store.Store
+	users         map[string]*models.User
+	sessions      SessionStore
+	apiTokens     map[string]*models.APIToken    // keyed by token ID
+	savedSearches map[string]*models.SavedSearch // keyed by saved search ID
+
+	// Store versions of the loaded users and API tokens, for noticing changes made by other replicas
+	usersVersion     string
+	apiTokensVersion string
 }
 
-func NewStorage(dataDir string) *Storage {
+// NewStorage creates auth storage in dataDir. Sessions are kept in the given store,
+// or in a sessions.json file in dataDir when sessions is nil.
+func NewStorage(dataDir string, sessions SessionStore) *Storage {
+	if sessions == nil {
+		sessions = NewFileSessionStore(dataDir)
+	}
+
 	return &Storage{
-		dataDir:  dataDir,
-		users:    make(map[string]*models.User),
-		sessions: make(map[string]*models.Session),
+		store:         store.NewFile(dataDir),
+		users:         make(map[string]*models.User),
+		sessions:      sessions,
+		apiTokens:     make(map[string]*models.APIToken),
+		savedSearches: make(map[string]*models.SavedSearch),
 	}
 }
 
+// SetStore keeps users, API tokens and saved searches in st instead of the data directory. It must be
+// called before Initialize.
+func (s *Storage) SetStore(st store.Store) {
+	s.store = st
+}
+
 func (s *Storage) Initialize() error {
-	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	if err := s.store.Init(); err != nil {
+		return err
 	}
 
 	if err := s.loadUsers(); err != nil {
 		return fmt.Errorf("failed to load users: %w", err)
 	}
 
-	if err := s.loadSessions(); err != nil {
+	if err := s.sessions.Initialize(); err != nil {
 		return fmt.Errorf("failed to load sessions: %w", err)
 	}
 
+	if err := s.loadAPITokens(); err != nil {
+		return fmt.Errorf("failed to load API tokens: %w", err)
+	}
+
+	if err := s.loadSavedSearches(); err != nil {
+		return fmt.Errorf("failed to load saved searches: %w", err)
+	}
+
+	return nil
+}
+
+// Reload replaces the users, API tokens and saved searches with those in the store, e.g. after a restore. Sessions are
+// kept, but only remain valid for users that still exist.
+func (s *Storage) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = make(map[string]*models.User)
+	s.apiTokens = make(map[string]*models.APIToken)
+	s.savedSearches = make(map[string]*models.SavedSearch)
+
+	if err := s.loadUsers(); err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	if err := s.loadAPITokens(); err != nil {
+		return fmt.Errorf("failed to load API tokens: %w", err)
+	}
+	if err := s.loadSavedSearches(); err != nil {
+		return fmt.Errorf("failed to load saved searches: %w", err)
+	}
+	return nil
+}
+
+// refresh reloads the users and API tokens when another replica sharing the store changed them, so
+// disabling a user, changing a password or revoking a token takes effect on every replica at once.
+// The caller must not hold the lock.
+func (s *Storage) refresh() error {
+	usersVersion, err := s.store.Version(usersDocument)
+	if err != nil {
+		return fmt.Errorf("failed to check users: %w", err)
+	}
+	apiTokensVersion, err := s.store.Version(apiTokensDocument)
+	if err != nil {
+		return fmt.Errorf("failed to check API tokens: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if usersVersion != s.usersVersion {
+		if err := s.loadUsers(); err != nil {
+			return fmt.Errorf("failed to load users: %w", err)
+		}
+		s.usersVersion = usersVersion
+	}
+	if apiTokensVersion != s.apiTokensVersion {
+		if err := s.loadAPITokens(); err != nil {
+			return fmt.Errorf("failed to load API tokens: %w", err)
+		}
+		s.apiTokensVersion = apiTokensVersion
+	}
 	return nil
 }
 
@@ -48,10 +146,36 @@ func (s *Storage) IsSetup() bool {
 	return len(s.users) > 0
 }
 
-func (s *Storage) CreateUser(username, password string) (*models.User, error) {
+// CreateInitialUser creates the first admin account, failing if any user already exists so two
+// concurrent setup requests can't both succeed
+func (s *Storage) CreateInitialUser(username, password string) (*models.User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if len(s.users) > 0 {
+		return nil, ErrAlreadySetup
+	}
+
+	return s.createUser(username, password, models.RoleAdmin)
+}
+
+func (s *Storage) CreateUser(username, password, role string) (*models.User, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createUser(username, password, role)
+}
+
+// createUser adds a user; the caller must hold the write lock
+func (s *Storage) createUser(username, password, role string) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+
 	// Check if user already exists
 	for _, user := range s.users {
 		if user.Username == username {
@@ -73,6 +197,7 @@ func (s *Storage) CreateUser(username, password string) (*models.User, error) {
 		ID:       id,
 		Username: username,
 		Password: hashedPassword,
+		Role:     role,
 		Created:  time.Now(),
 		Updated:  time.Now(),
 	}
@@ -87,6 +212,10 @@ func (s *Storage) CreateUser(username, password string) (*models.User, error) {
 }
 
 func (s *Storage) GetUserByUsername(username string) (*models.User, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -110,10 +239,180 @@ func (s *Storage) GetUserByID(id string) (*models.User, error) {
 	return nil, fmt.Errorf("user not found")
 }
 
-func (s *Storage) CreateSession(userID string) (*models.Session, error) {
+// ListUsers returns all users sorted by username
+func (s *Storage) ListUsers() []*models.User {
+	if err := s.refresh(); err != nil {
+		fmt.Printf("Warning: Listing users without changes from other replicas: %v\n", err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
+// UserUpdate holds the user fields to change; nil fields are left as they are
+type UserUpdate struct {
+	Role     *string
+	Password *string
+	Disabled *bool
+}
+
+// UpdateUser changes a user's role, password or disabled state. A new password or disabling the
+// user ends their sessions. The last active admin can't be demoted or disabled, so someone can
+// always manage users.
+func (s *Storage) UpdateUser(id string, update UserUpdate) (*models.User, error) {
+	if update.Role != nil && !models.ValidRole(*update.Role) {
+		return nil, fmt.Errorf("invalid role: %s", *update.Role)
+	}
+
+	var hashedPassword string
+	if update.Password != nil {
+		var err error
+		if hashedPassword, err = HashPassword(*update.Password); err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	updated := *user
+	if update.Role != nil {
+		updated.Role = *update.Role
+	}
+	if update.Disabled != nil {
+		updated.Disabled = *update.Disabled
+	}
+	if update.Password != nil {
+		updated.Password = hashedPassword
+	}
+	if update.Password != nil || (updated.Disabled && !user.Disabled) {
+		updated.SessionsValidAfter = time.Now()
+	}
+	updated.Updated = time.Now()
+
+	if isActiveAdmin(user) && !isActiveAdmin(&updated) && s.isLastAdmin(user) {
+		return nil, ErrLastAdmin
+	}
+
+	s.users[id] = &updated
+	if err := s.saveUsers(); err != nil {
+		s.users[id] = user
+		return nil, fmt.Errorf("failed to save users: %w", err)
+	}
+
+	return &updated, nil
+}
+
+// UpgradePasswordHash rehashes a user's password with the current hashing settings. Unlike a
+// password change, the user's sessions are kept.
+func (s *Storage) UpgradePasswordHash(id, password string) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	updated := *user
+	updated.Password = hashedPassword
+	s.users[id] = &updated
+	if err := s.saveUsers(); err != nil {
+		s.users[id] = user
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser removes a user along with their API tokens and saved searches. The last active admin can't be deleted.
+func (s *Storage) DeleteUser(id string) (*models.User, error) {
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+	if s.isLastAdmin(user) {
+		return nil, ErrLastAdmin
+	}
+
+	delete(s.users, id)
+	if err := s.saveUsers(); err != nil {
+		s.users[id] = user
+		return nil, fmt.Errorf("failed to save users: %w", err)
+	}
+
+	s.deleteUserAPITokens(id)
+	s.deleteUserSavedSearches(id)
+
+	return user, nil
+}
+
+// isLastAdmin reports whether user is the only active admin; the caller must hold the lock
+func (s *Storage) isLastAdmin(user *models.User) bool {
+	if !isActiveAdmin(user) {
+		return false
+	}
+	for _, other := range s.users {
+		if other.ID != user.ID && isActiveAdmin(other) {
+			return false
+		}
+	}
+	return true
+}
+
+func isActiveAdmin(user *models.User) bool {
+	return user.EffectiveRole() == models.RoleAdmin && !user.Disabled
+}
+
+// UserSessionValid reports whether a session still belongs to an enabled user, returning the user.
+// Users are checked against the store, so a session ended on one replica is rejected by all of them.
+func (s *Storage) UserSessionValid(session *models.Session) (*models.User, bool) {
+	if err := s.refresh(); err != nil {
+		fmt.Printf("Warning: Rejecting session, users can't be checked: %v\n", err)
+		return nil, false
+	}
+
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil || user.Disabled || session.Created.Before(user.SessionsValidAfter) {
+		return nil, false
+	}
+	return user, true
+}
+
+func (s *Storage) CreateSession(userID string) (*models.Session, error) {
 	id, err := GenerateID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate session ID: %w", err)
@@ -132,9 +431,7 @@ func (s *Storage) CreateSession(userID string) (*models.Session, error) {
 		Expires: time.Now().Add(GetSessionDuration()),
 	}
 
-	s.sessions[token] = session
-
-	if err := s.saveSessions(); err != nil {
+	if err := s.sessions.Save(session); err != nil {
 		return nil, fmt.Errorf("failed to save session: %w", err)
 	}
 
@@ -142,12 +439,9 @@ func (s *Storage) CreateSession(userID string) (*models.Session, error) {
 }
 
 func (s *Storage) GetSession(token string) (*models.Session, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	session, exists := s.sessions[token]
-	if !exists {
-		return nil, fmt.Errorf("session not found")
+	session, err := s.sessions.Get(token)
+	if err != nil {
+		return nil, err
 	}
 
 	if IsSessionExpired(session.Expires) {
@@ -158,36 +452,20 @@ func (s *Storage) GetSession(token string) (*models.Session, error) {
 }
 
 func (s *Storage) DeleteSession(token string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	delete(s.sessions, token)
-	return s.saveSessions()
+	return s.sessions.Delete(token)
 }
 
 func (s *Storage) CleanExpiredSessions() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for token, session := range s.sessions {
-		if IsSessionExpired(session.Expires) {
-			delete(s.sessions, token)
-		}
-	}
-
-	return s.saveSessions()
+	return s.sessions.DeleteExpired()
 }
 
 func (s *Storage) loadUsers() error {
-	filePath := filepath.Join(s.dataDir, "users.json")
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil // File doesn't exist, that's OK
+	data, err := s.store.Load(usersDocument)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil // Never saved, that's OK
 	}
-
-	data, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read users file: %w", err)
+		return fmt.Errorf("failed to read users: %w", err)
 	}
 
 	var users map[string]*models.User
@@ -195,56 +473,20 @@ func (s *Storage) loadUsers() error {
 		return fmt.Errorf("failed to unmarshal users: %w", err)
 	}
 
-	s.users = users
+	if users != nil {
+		s.users = users
+	}
 	return nil
 }
 
 func (s *Storage) saveUsers() error {
-	filePath := filepath.Join(s.dataDir, "users.json")
-
 	data, err := json.MarshalIndent(s.users, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal users: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write users file: %w", err)
-	}
-
-	return nil
-}
-
-func (s *Storage) loadSessions() error {
-	filePath := filepath.Join(s.dataDir, "sessions.json")
-
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil // File doesn't exist, that's OK
-	}
-
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read sessions file: %w", err)
-	}
-
-	var sessions map[string]*models.Session
-	if err := json.Unmarshal(data, &sessions); err != nil {
-		return fmt.Errorf("failed to unmarshal sessions: %w", err)
-	}
-
-	s.sessions = sessions
-	return nil
-}
-
-func (s *Storage) saveSessions() error {
-	filePath := filepath.Join(s.dataDir, "sessions.json")
-
-	data, err := json.MarshalIndent(s.sessions, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal sessions: %w", err)
-	}
-
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write sessions file: %w", err)
+	if err := s.store.Save(usersDocument, data); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
 	}
 
 	return nil
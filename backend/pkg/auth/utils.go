@@ -2,21 +2,16 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"math/big"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
-}
-
-func CheckPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
+// passwordAlphabet leaves out characters that are easily confused (0/O, 1/l/I) or need quoting in
+// shells and config files
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.~!@#%^*+="
 
 func GenerateToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -27,6 +22,20 @@ func GenerateToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// GeneratePassword returns a random password of length characters
+func GeneratePassword(length int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(passwordAlphabet)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
 func GenerateID() (string, error) {
 	bytes := make([]byte, 16)
 	_, err := rand.Read(bytes)
@@ -43,3 +52,14 @@ func IsSessionExpired(expires time.Time) bool {
 func GetSessionDuration() time.Duration {
 	return 24 * time.Hour // 24 hours
 }
+
+// HashToken returns the hex-encoded SHA-256 hash of a token for storage at rest
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatchesHash reports whether token hashes to tokenHash, in constant time
+func TokenMatchesHash(token, tokenHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(tokenHash)) == 1
+}
@@ -0,0 +1,282 @@
+// Package backup packs the manager's state in the data directory into a single archive and
+// restores it, so an install can be moved or rebuilt after losing its disk.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// FormatVersion is the archive layout version written to the manifest
+const FormatVersion = 1
+
+// maxArchiveSize caps the uncompressed size of a restored archive
+const maxArchiveSize = 100 << 20
+
+const (
+	manifestName      = "manifest.json"
+	configName        = "caddy-config.json" // Managed Caddy config, including redirects
+	metadataName      = "caddy-config-metadata.json"
+	usersName         = "users.json"
+	apiTokensName     = "api_tokens.json"
+	savedSearchesName = "saved_searches.json"
+	secretsKeyName    = "secret.key" // Decrypts the secrets stored in the metadata
+)
+
+// files and dirs are the data directory entries making up the manager's state. Sessions, the
+// audit log and Caddy's own storage are left out.
+var (
+	files = []string{configName, metadataName, usersName, apiTokensName, savedSearchesName, secretsKeyName}
+	dirs  = []string{"certs", "pages", "ip-lists"}
+)
+
+// Manifest describes an archive
+type Manifest struct {
+	Format  int       `json:"format"`
+	Created time.Time `json:"created"`
+	Files   []string  `json:"files"`
+}
+
+// Archive is a backup read into memory
+type Archive struct {
+	Manifest Manifest
+	Files    map[string][]byte // Keyed by slash-separated path relative to the data directory
+}
+
+// Write packs the state in dataDir into a gzipped tar archive
+func Write(w io.Writer, dataDir string) error {
+	contents := make(map[string][]byte)
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(dataDir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		contents[name] = data
+	}
+
+	for _, dir := range dirs {
+		root := filepath.Join(dataDir, dir)
+		err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && file == root {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil // Directories are implied and symlinks aren't followed
+			}
+
+			rel, err := filepath.Rel(dataDir, file)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			contents[filepath.ToSlash(rel)] = data
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+	}
+
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	manifest, err := json.MarshalIndent(Manifest{Format: FormatVersion, Created: time.Now().UTC(), Files: names}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add(manifestName, manifest); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := add(name, contents[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read unpacks an archive written by Write, rejecting entries outside the manager's state
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gzipped archive: %w", err)
+	}
+	defer gz.Close()
+
+	archive := &Archive{Files: make(map[string][]byte)}
+	var manifest []byte
+	var total int64
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unsupported entry %s", header.Name)
+		}
+
+		name := path.Clean(header.Name)
+		if name != manifestName && !allowed(name) {
+			return nil, fmt.Errorf("unexpected entry %s", header.Name)
+		}
+
+		total += header.Size
+		if total > maxArchiveSize {
+			return nil, fmt.Errorf("archive is larger than %d MB", maxArchiveSize>>20)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, header.Size))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		if name == manifestName {
+			manifest = data
+		} else {
+			archive.Files[name] = data
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("archive has no %s", manifestName)
+	}
+	if err := json.Unmarshal(manifest, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", manifestName, err)
+	}
+
+	return archive, nil
+}
+
+// allowed reports whether name is a state file or lies inside a state directory
+func allowed(name string) bool {
+	if strings.HasPrefix(name, "/") || name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+	if slices.Contains(files, name) {
+		return true
+	}
+	for _, dir := range dirs {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the archive's files can be loaded before anything is replaced
+func (a *Archive) Validate() error {
+	if a.Manifest.Format != FormatVersion {
+		return fmt.Errorf("unsupported backup format %d, expected %d", a.Manifest.Format, FormatVersion)
+	}
+
+	data, exists := a.Files[configName]
+	if !exists {
+		return fmt.Errorf("archive has no %s", configName)
+	}
+	var config models.CaddyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("invalid %s: %w", configName, err)
+	}
+
+	if data, exists := a.Files[metadataName]; exists {
+		var metadata models.MetadataStore
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return fmt.Errorf("invalid %s: %w", metadataName, err)
+		}
+	}
+
+	if data, exists := a.Files[usersName]; exists {
+		var users map[string]*models.User
+		if err := json.Unmarshal(data, &users); err != nil {
+			return fmt.Errorf("invalid %s: %w", usersName, err)
+		}
+		if len(users) > 0 && !slices.ContainsFunc(slices.Collect(maps.Values(users)), activeAdmin) {
+			return fmt.Errorf("%s has no active admin", usersName)
+		}
+	}
+
+	if data, exists := a.Files[apiTokensName]; exists {
+		var tokens map[string]*models.APIToken
+		if err := json.Unmarshal(data, &tokens); err != nil {
+			return fmt.Errorf("invalid %s: %w", apiTokensName, err)
+		}
+	}
+
+	if data, exists := a.Files[savedSearchesName]; exists {
+		var searches map[string]*models.SavedSearch
+		if err := json.Unmarshal(data, &searches); err != nil {
+			return fmt.Errorf("invalid %s: %w", savedSearchesName, err)
+		}
+	}
+
+	if data, exists := a.Files[secretsKeyName]; exists {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("invalid %s", secretsKeyName)
+		}
+	}
+
+	return nil
+}
+
+// HasSecretsKey reports whether the archive carries the key encrypting the metadata's secrets
+func (a *Archive) HasSecretsKey() bool {
+	_, exists := a.Files[secretsKeyName]
+	return exists
+}
+
+func activeAdmin(user *models.User) bool {
+	return user != nil && user.EffectiveRole() == models.RoleAdmin && !user.Disabled
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic starts every passphrase-encrypted archive, followed by the salt, the nonce and the
+// AES-GCM sealed archive
+const encryptedMagic = "CPMBAK1\n"
+
+const saltSize = 16
+
+// scrypt parameters deriving the archive key from the passphrase
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrPassphraseRequired is returned when opening an encrypted archive without a passphrase
+var ErrPassphraseRequired = errors.New("archive is encrypted, a passphrase is required")
+
+// Encrypt seals an archive written by Write with a key derived from passphrase
+func Encrypt(archive []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := passphraseCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append([]byte(encryptedMagic), salt...)
+	sealed = append(sealed, nonce...)
+	return gcm.Seal(sealed, nonce, archive, []byte(encryptedMagic)), nil
+}
+
+// Decrypt opens an archive sealed by Encrypt
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !Encrypted(data) {
+		return nil, errors.New("archive is not encrypted")
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	data = data[len(encryptedMagic):]
+	if len(data) < saltSize {
+		return nil, errors.New("encrypted archive is truncated")
+	}
+	gcm, err := passphraseCipher(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted archive is truncated")
+	}
+
+	archive, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(encryptedMagic))
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted archive")
+	}
+	return archive, nil
+}
+
+// Encrypted reports whether data is an archive sealed by Encrypt
+func Encrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// Open reads an archive that may be encrypted, decrypting it with passphrase first
+func Open(data []byte, passphrase string) (*Archive, error) {
+	if Encrypted(data) {
+		var err error
+		if data, err = Decrypt(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	return Read(bytes.NewReader(data))
+}
+
+func passphraseCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archivePrefix starts the name of every archive the manager uploads, so pruning leaves other
+// files on the target alone
+const archivePrefix = "caddyproxymanager-backup-"
+
+// Target is remote storage archives are uploaded to, such as an S3 bucket or a WebDAV share
+type Target interface {
+	Kind() string // "s3" or "webdav"
+	Upload(ctx context.Context, name string, data []byte) error
+	Download(ctx context.Context, name string) ([]byte, error)
+	List(ctx context.Context) ([]RemoteArchive, error) // Entries starting with archivePrefix
+	Delete(ctx context.Context, name string) error
+}
+
+// RemoteArchive is an archive stored on a target
+type RemoteArchive struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Remote uploads encrypted archives to a target on a schedule, keeping the newest ones
+type Remote struct {
+	dataDir    string
+	target     Target
+	passphrase string
+	keep       int
+	interval   time.Duration
+	mu         sync.Mutex // One upload at a time
+}
+
+// NewRemote creates a schedule uploading archives of dataDir to target every interval, encrypted
+// with passphrase, and deleting all but the newest keep
+func NewRemote(dataDir string, target Target, passphrase string, keep int, interval time.Duration) (*Remote, error) {
+	if passphrase == "" {
+		return nil, errors.New("remote backups need a passphrase, as archives include the secrets key")
+	}
+	if keep < 1 {
+		return nil, fmt.Errorf("retention must keep at least one archive, got %d", keep)
+	}
+
+	return &Remote{dataDir: dataDir, target: target, passphrase: passphrase, keep: keep, interval: interval}, nil
+}
+
+// Kind returns the kind of target archives are uploaded to
+func (r *Remote) Kind() string {
+	return r.target.Kind()
+}
+
+// Keep returns how many archives are kept
+func (r *Remote) Keep() int {
+	return r.keep
+}
+
+// Interval returns how often archives are uploaded, 0 when only on demand
+func (r *Remote) Interval() time.Duration {
+	return r.interval
+}
+
+// Passphrase returns the passphrase archives are encrypted with, which also opens uploaded ones
+func (r *Remote) Passphrase() string {
+	return r.passphrase
+}
+
+// Run uploads an archive every interval until ctx is cancelled. shouldUpload, if set, skips
+// uploads when it returns false, e.g. on HA followers; uploaded is told about every attempt.
+func (r *Remote) Run(ctx context.Context, shouldUpload func() bool, uploaded func(name string, err error)) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if shouldUpload != nil && !shouldUpload() {
+				continue
+			}
+			name, err := r.Upload(ctx)
+			if err != nil {
+				log.Printf("Failed to upload backup: %v", err)
+			}
+			if uploaded != nil {
+				uploaded(name, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Upload writes an encrypted archive to the target and prunes the oldest ones past the retention.
+// A failed prune is logged, as the upload itself succeeded.
+func (r *Remote) Upload(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var archive bytes.Buffer
+	if err := Write(&archive, r.dataDir); err != nil {
+		return "", fmt.Errorf("failed to create backup: %w", err)
+	}
+	sealed, err := Encrypt(archive.Bytes(), r.passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+
+	name := archivePrefix + time.Now().UTC().Format("20060102-150405") + ".tar.gz.enc"
+	if err := r.target.Upload(ctx, name, sealed); err != nil {
+		return "", fmt.Errorf("failed to upload %s to %s: %w", name, r.target.Kind(), err)
+	}
+
+	if err := r.prune(ctx); err != nil {
+		log.Printf("Warning: Failed to prune old backups: %v", err)
+	}
+	return name, nil
+}
+
+// List returns the archives on the target, newest first
+func (r *Remote) List(ctx context.Context) ([]RemoteArchive, error) {
+	archives, err := r.target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archives = slices.DeleteFunc(archives, func(archive RemoteArchive) bool {
+		return !strings.HasPrefix(archive.Name, archivePrefix)
+	})
+	// Names carry the UTC creation time, so they sort in creation order
+	slices.SortFunc(archives, func(a, b RemoteArchive) int { return strings.Compare(b.Name, a.Name) })
+	return archives, nil
+}
+
+// Download fetches an archive from the target and opens it
+func (r *Remote) Download(ctx context.Context, name string) (*Archive, error) {
+	if !strings.HasPrefix(name, archivePrefix) || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid backup name %q", name)
+	}
+
+	data, err := r.target.Download(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s from %s: %w", name, r.target.Kind(), err)
+	}
+	return Open(data, r.passphrase)
+}
+
+// prune deletes all but the newest keep archives
+func (r *Remote) prune(ctx context.Context) error {
+	archives, err := r.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(archives) <= r.keep {
+		return nil
+	}
+
+	var errs []error
+	for _, archive := range archives[r.keep:] {
+		if err := r.target.Delete(ctx, archive.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", archive.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
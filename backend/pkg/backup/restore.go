@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Restore is an archive whose files have been swapped into the data directory. The previous
+// state is kept until Commit, so Rollback can put it back if Caddy rejects the restored config.
+type Restore struct {
+	dataDir  string
+	staging  string
+	previous string
+	swapped  []string // Entries moved aside, the only ones Rollback touches
+}
+
+// Apply writes the archive into a staging directory and then swaps every state file and
+// directory into dataDir. State entries missing from the archive are removed.
+func (a *Archive) Apply(dataDir string) (*Restore, error) {
+	staging, err := os.MkdirTemp(dataDir, ".restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	for name, data := range a.Files {
+		file := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			os.RemoveAll(staging)
+			return nil, fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			os.RemoveAll(staging)
+			return nil, fmt.Errorf("failed to stage %s: %w", name, err)
+		}
+	}
+
+	previous, err := os.MkdirTemp(dataDir, ".restore-previous-")
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to create directory for the previous state: %w", err)
+	}
+
+	restore := &Restore{dataDir: dataDir, staging: staging, previous: previous}
+	for _, entry := range entries() {
+		if err := moveIfExists(filepath.Join(dataDir, entry), filepath.Join(previous, entry)); err != nil {
+			restore.Rollback()
+			return nil, fmt.Errorf("failed to move aside %s: %w", entry, err)
+		}
+		restore.swapped = append(restore.swapped, entry)
+		if err := moveIfExists(filepath.Join(staging, entry), filepath.Join(dataDir, entry)); err != nil {
+			restore.Rollback()
+			return nil, fmt.Errorf("failed to restore %s: %w", entry, err)
+		}
+	}
+
+	return restore, nil
+}
+
+// Commit discards the previous state
+func (r *Restore) Commit() {
+	os.RemoveAll(r.previous)
+	os.RemoveAll(r.staging)
+}
+
+// Rollback puts the previous state back
+func (r *Restore) Rollback() error {
+	var errs []error
+	for _, entry := range r.swapped {
+		target := filepath.Join(r.dataDir, entry)
+		saved := filepath.Join(r.previous, entry)
+		if _, err := os.Lstat(saved); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+
+		if err := os.RemoveAll(target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := moveIfExists(saved, target); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		os.RemoveAll(r.previous)
+	}
+	os.RemoveAll(r.staging)
+	return errors.Join(errs...)
+}
+
+func entries() []string {
+	return append(append([]string{}, files...), dirs...)
+}
+
+func moveIfExists(from, to string) error {
+	if _, err := os.Lstat(from); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return os.Rename(from, to)
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config locates a bucket on AWS S3 or an S3-compatible store such as MinIO or R2
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com
+	Bucket    string
+	Region    string // Optional for stores that don't use regions
+	AccessKey string
+	SecretKey string
+	Prefix    string // Optional folder archives are stored under
+}
+
+// S3 stores archives in an S3 bucket
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 creates a target for the bucket in config
+func NewS3(config S3Config) (*S3, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "https" && endpoint.Scheme != "http") {
+		return nil, fmt.Errorf("S3 endpoint must be an http(s) URL, got %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: endpoint.Scheme == "https",
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{client: client, bucket: config.Bucket, prefix: prefix}, nil
+}
+
+func (s *S3) Kind() string {
+	return "s3"
+}
+
+func (s *S3) Upload(ctx context.Context, name string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+name, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+func (s *S3) Download(ctx context.Context, name string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	return readArchive(object)
+}
+
+func (s *S3) List(ctx context.Context) ([]RemoteArchive, error) {
+	var archives []RemoteArchive
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix + archivePrefix}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		archives = append(archives, RemoteArchive{Name: path.Base(object.Key), Size: object.Size, Modified: object.LastModified})
+	}
+	return archives, nil
+}
+
+func (s *S3) Delete(ctx context.Context, name string) error {
+	return s.client.RemoveObject(ctx, s.bucket, s.prefix+name, minio.RemoveObjectOptions{})
+}
+
+// readArchive reads a downloaded archive, refusing one larger than any archive Read accepts
+func readArchive(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveSize {
+		return nil, fmt.Errorf("archive is larger than %d MB", maxArchiveSize>>20)
+	}
+	return data, nil
+}
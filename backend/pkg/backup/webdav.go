@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// webDAVTimeout bounds every WebDAV request, as the client doesn't take a context
+const webDAVTimeout = 5 * time.Minute
+
+// WebDAVConfig locates a WebDAV folder, e.g. on Nextcloud
+type WebDAVConfig struct {
+	URL      string // Folder archives are stored in
+	Username string
+	Password string
+}
+
+// WebDAV stores archives in a WebDAV folder
+type WebDAV struct {
+	client *gowebdav.Client
+}
+
+// NewWebDAV creates a target for the folder in config
+func NewWebDAV(config WebDAVConfig) (*WebDAV, error) {
+	folder, err := url.Parse(config.URL)
+	if err != nil || folder.Host == "" || (folder.Scheme != "https" && folder.Scheme != "http") {
+		return nil, fmt.Errorf("WebDAV URL must be an http(s) URL, got %q", config.URL)
+	}
+
+	client := gowebdav.NewClient(strings.TrimSuffix(config.URL, "/")+"/", config.Username, config.Password)
+	client.SetTimeout(webDAVTimeout)
+	return &WebDAV{client: client}, nil
+}
+
+func (d *WebDAV) Kind() string {
+	return "webdav"
+}
+
+func (d *WebDAV) Upload(ctx context.Context, name string, data []byte) error {
+	return d.client.Write(name, data, 0600)
+}
+
+func (d *WebDAV) Download(ctx context.Context, name string) ([]byte, error) {
+	stream, err := d.client.ReadStream(name)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	return readArchive(stream)
+}
+
+func (d *WebDAV) List(ctx context.Context) ([]RemoteArchive, error) {
+	entries, err := d.client.ReadDir("/")
+	if gowebdav.IsErrNotFound(err) {
+		return nil, nil // Created with the first upload
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []RemoteArchive
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), archivePrefix) {
+			continue
+		}
+		archives = append(archives, RemoteArchive{Name: entry.Name(), Size: entry.Size(), Modified: entry.ModTime()})
+	}
+	return archives, nil
+}
+
+func (d *WebDAV) Delete(ctx context.Context, name string) error {
+	return d.client.Remove(name)
+}
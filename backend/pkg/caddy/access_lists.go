@@ -0,0 +1,242 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/iplist"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccessLists returns the shared access lists, sorted by name
+func (c *Client) AccessLists() []models.AccessList {
+	lists := make([]models.AccessList, 0, len(c.metadata.AccessLists))
+	for _, list := range c.metadata.AccessLists {
+		lists = append(lists, list)
+	}
+	slices.SortFunc(lists, func(a, b models.AccessList) int {
+		if n := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return lists
+}
+
+// GetAccessList retrieves a shared access list by ID
+func (c *Client) GetAccessList(id string) (*models.AccessList, error) {
+	list, exists := c.metadata.GetAccessList(id)
+	if !exists {
+		return nil, fmt.Errorf("access list %s not found", id)
+	}
+	return &list, nil
+}
+
+// AccessListUsers returns the IDs of the proxies referencing an access list
+func (c *Client) AccessListUsers(id string) []string {
+	return c.metadata.AccessListUsers(id)
+}
+
+// NewAccessListID returns an unused ID for a new access list named name
+func (c *Client) NewAccessListID(name string) string {
+	base := models.GenerateAccessListSlug(name)
+	id := base
+	for n := 2; ; n++ {
+		if _, exists := c.metadata.GetAccessList(id); !exists {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// NormalizeAccessList validates an access list and hashes the passwords of its users. Users sent
+// without a password keep the one they have in previous, so clients don't need to know it.
+func NormalizeAccessList(list *models.AccessList, previous *models.AccessList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	list.AllowedIPs = trimEntries(list.AllowedIPs)
+	list.BlockedIPs = trimEntries(list.BlockedIPs)
+	if err := validateIPList(list.AllowedIPs); err != nil {
+		return fmt.Errorf("invalid allowed IPs: %v", err)
+	}
+	if err := validateIPList(list.BlockedIPs); err != nil {
+		return fmt.Errorf("invalid blocked IPs: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, user := range list.Users {
+		user.Username = strings.TrimSpace(user.Username)
+		if user.Username == "" {
+			return fmt.Errorf("every user needs a username")
+		}
+		if seen[user.Username] {
+			return fmt.Errorf("user %s is listed twice", user.Username)
+		}
+		seen[user.Username] = true
+
+		switch {
+		case user.Password != "":
+			hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("failed to hash password: %v", err)
+			}
+			user.PasswordHash = string(hash)
+		case previous != nil:
+			j := slices.IndexFunc(previous.Users, func(u models.AccessListUser) bool { return u.Username == user.Username })
+			if j < 0 {
+				return fmt.Errorf("user %s needs a password", user.Username)
+			}
+			user.PasswordHash = previous.Users[j].PasswordHash
+		default:
+			return fmt.Errorf("user %s needs a password", user.Username)
+		}
+		user.Password = ""
+		list.Users[i] = user
+	}
+
+	if len(list.AllowedIPs) == 0 && len(list.BlockedIPs) == 0 && len(list.Users) == 0 {
+		return fmt.Errorf("an access list needs allowed IPs, blocked IPs or users")
+	}
+	return nil
+}
+
+// SaveAccessList stores an access list and rebuilds the routes of the proxies using it. Nothing is
+// kept when Caddy rejects the result.
+func (c *Client) SaveAccessList(list models.AccessList) error {
+	previous, existed := c.metadata.GetAccessList(list.ID)
+	c.metadata.SetAccessList(list)
+
+	if err := c.rebuildProxyRoutes(c.metadata.AccessListUsers(list.ID)); err != nil {
+		if existed {
+			c.metadata.SetAccessList(previous)
+		} else {
+			c.metadata.DeleteAccessList(list.ID)
+		}
+		return err
+	}
+
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// DeleteAccessList removes an access list that no proxy uses anymore
+func (c *Client) DeleteAccessList(id string) error {
+	if _, exists := c.metadata.GetAccessList(id); !exists {
+		return fmt.Errorf("access list %s not found", id)
+	}
+	if users := c.metadata.AccessListUsers(id); len(users) > 0 {
+		return fmt.Errorf("access list %s is used by %s", id, strings.Join(users, ", "))
+	}
+
+	c.metadata.DeleteAccessList(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// rebuildProxyRoutes rebuilds the routes of the given proxies from their stored settings and applies
+// them in a single config update
+func (c *Client) rebuildProxyRoutes(proxyIDs []string) error {
+	if len(proxyIDs) == 0 {
+		return nil
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	proxies := c.ParseProxiesFromConfig(config)
+	for _, id := range proxyIDs {
+		i := slices.IndexFunc(proxies, func(p models.Proxy) bool { return p.ID == id })
+		if i < 0 {
+			continue
+		}
+		removeProxyFromConfig(config, id)
+		if err := c.addProxyToConfig(config, proxies[i]); err != nil {
+			return fmt.Errorf("proxy %s: %v", id, err)
+		}
+	}
+
+	return c.updateConfig(config)
+}
+
+// proxyAccessLists returns the access lists a proxy references
+func (c *Client) proxyAccessLists(proxy models.Proxy) ([]models.AccessList, error) {
+	lists := make([]models.AccessList, 0, len(proxy.AccessListIDs))
+	for _, id := range proxy.AccessListIDs {
+		list, exists := c.metadata.GetAccessList(id)
+		if !exists {
+			return nil, fmt.Errorf("access list %s not found", id)
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// proxyBasicAuthAccounts returns the basic auth accounts of a proxy: its own user followed by the
+// users of its access lists. The first account with a username wins, as Caddy rejects duplicates.
+func (c *Client) proxyBasicAuthAccounts(proxy models.Proxy) ([]models.CaddyAccount, error) {
+	var accounts []models.CaddyAccount
+	seen := make(map[string]bool)
+
+	if proxy.BasicAuth != nil && proxy.BasicAuth.Enabled && proxy.BasicAuth.Username != "" && proxy.BasicAuth.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(proxy.BasicAuth.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %v", err)
+		}
+		accounts = append(accounts, models.CaddyAccount{Username: proxy.BasicAuth.Username, Password: string(hashedPassword)})
+		seen[proxy.BasicAuth.Username] = true
+	}
+
+	lists, err := c.proxyAccessLists(proxy)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		for _, user := range list.Users {
+			if seen[user.Username] || user.PasswordHash == "" {
+				continue
+			}
+			accounts = append(accounts, models.CaddyAccount{Username: user.Username, Password: user.PasswordHash})
+			seen[user.Username] = true
+		}
+	}
+	return accounts, nil
+}
+
+// accessListIPRanges adds the ranges of a proxy's access lists to its allowed and blocked ranges
+func (c *Client) accessListIPRanges(proxy models.Proxy, allowed, blocked []string) ([]string, []string, error) {
+	lists, err := c.proxyAccessLists(proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, list := range lists {
+		allowed = iplist.Merge(allowed, list.AllowedIPs)
+		blocked = iplist.Merge(blocked, list.BlockedIPs)
+	}
+	return allowed, blocked, nil
+}
+
+// trimEntries trims list entries, dropping empty ones
+func trimEntries(entries []string) []string {
+	var trimmed []string
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			trimmed = append(trimmed, entry)
+		}
+	}
+	return trimmed
+}
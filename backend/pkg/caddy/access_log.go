@@ -0,0 +1,226 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Access log settings of a proxy, choosing which of its requests Caddy writes to its access log
+const (
+	AccessLogAll    = "all"    // every request
+	AccessLogErrors = "errors" // requests answered with a status of 400 or above
+)
+
+// AccessLogModes lists the supported access log settings; an empty one keeps no log
+var AccessLogModes = []string{AccessLogAll, AccessLogErrors}
+
+// ValidAccessLog reports whether mode is a supported access log setting
+func ValidAccessLog(mode string) bool {
+	return mode == "" || slices.Contains(AccessLogModes, mode)
+}
+
+const (
+	accessLogLoggerPrefix = "cpm_access_"
+	// accessLogOKSuffix names the logger responses below 400 are moved to in errors mode. As a
+	// child of the proxy's logger it still reaches the traffic log and debug captures.
+	accessLogOKSuffix   = ".ok"
+	accessLogRollSizeMB = 50
+	accessLogRollKeep   = 3
+)
+
+// accessLogLoggerName returns the Caddy logger name a proxy's requests are logged under
+func accessLogLoggerName(proxyID string) string {
+	return accessLogLoggerPrefix + proxyID
+}
+
+// AccessLogFile returns the path of a proxy's access log
+func (c *Client) AccessLogFile(proxyID string) (string, error) {
+	if proxyID == "" || filepath.Base(proxyID) != proxyID {
+		return "", fmt.Errorf("invalid proxy ID")
+	}
+
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "access-logs"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve access log directory: %v", err)
+	}
+
+	return filepath.Join(dir, proxyID+".log"), nil
+}
+
+// applyAccessLog adds a file log for the proxy and maps its host to it on every server that serves
+// the proxy route, or removes them when the proxy keeps no access log. Caddy logs 4xx responses at
+// INFO, so errors mode can't filter by level: logOnlyErrors moves the other responses to a child
+// logger, which the proxy's log excludes.
+func (c *Client) applyAccessLog(config *models.CaddyConfig, proxy models.Proxy) error {
+	if proxy.AccessLog == "" {
+		removeAccessLog(config, proxy.ID)
+		return nil
+	}
+
+	file, err := c.AccessLogFile(proxy.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create access log directory: %v", err)
+	}
+
+	loggerName := accessLogLoggerName(proxy.ID)
+	accessLogger := accessLoggerPrefix + loggerName
+
+	if config.Logging == nil {
+		config.Logging = &models.CaddyLogging{}
+	}
+	if config.Logging.Logs == nil {
+		config.Logging.Logs = make(map[string]models.CaddyLog)
+	}
+
+	roll := true
+	proxyLog := models.CaddyLog{
+		Writer: &models.CaddyLogWriter{
+			Output:     "file",
+			Filename:   file,
+			Roll:       &roll,
+			RollSizeMB: accessLogRollSizeMB,
+			RollKeep:   accessLogRollKeep,
+		},
+		Encoder: &models.CaddyLogEncoder{Format: "json"},
+		Include: []string{accessLogger},
+	}
+	if proxy.AccessLog == AccessLogErrors {
+		proxyLog.Exclude = []string{accessLogger + accessLogOKSuffix}
+	}
+	config.Logging.Logs[loggerName] = proxyLog
+
+	// Keep the proxy's requests out of the default log
+	defaultLog := config.Logging.Logs[defaultLogName]
+	if !slices.Contains(defaultLog.Exclude, accessLogger) {
+		defaultLog.Exclude = append(defaultLog.Exclude, accessLogger)
+	}
+	config.Logging.Logs[defaultLogName] = defaultLog
+
+	host := proxy.Domain
+	if h, _, err := net.SplitHostPort(proxy.Domain); err == nil {
+		host = h
+	}
+
+	// Drop the mapping of a previous domain or server
+	removeAccessLogHosts(config, loggerName, host)
+
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if !slices.ContainsFunc(server.Routes, func(route models.CaddyRoute) bool { return route.ID == proxy.ID }) {
+			continue
+		}
+
+		if server.Logs == nil {
+			// Only log the proxy's host, not every site on the server
+			server.Logs = &models.CaddyServerLogs{SkipUnmappedHosts: true}
+		}
+		if server.Logs.LoggerNames == nil {
+			server.Logs.LoggerNames = make(map[string]string)
+		}
+		server.Logs.LoggerNames[host] = loggerName
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+
+	return nil
+}
+
+// removeAccessLog undoes applyAccessLog, dropping logging config that only existed for it
+func removeAccessLog(config *models.CaddyConfig, proxyID string) {
+	loggerName := accessLogLoggerName(proxyID)
+	accessLogger := accessLoggerPrefix + loggerName
+
+	if config.Logging != nil {
+		delete(config.Logging.Logs, loggerName)
+
+		if defaultLog, exists := config.Logging.Logs[defaultLogName]; exists {
+			defaultLog.Exclude = slices.DeleteFunc(defaultLog.Exclude, func(name string) bool { return name == accessLogger })
+			if defaultLog.Writer == nil && defaultLog.Encoder == nil && defaultLog.Level == "" &&
+				len(defaultLog.Include) == 0 && len(defaultLog.Exclude) == 0 {
+				delete(config.Logging.Logs, defaultLogName)
+			} else {
+				config.Logging.Logs[defaultLogName] = defaultLog
+			}
+		}
+
+		if len(config.Logging.Logs) == 0 {
+			config.Logging = nil
+		}
+	}
+
+	removeAccessLogHosts(config, loggerName, "")
+}
+
+// removeAccessLogHosts removes the mappings of hosts other than keep to loggerName from every
+// server, dropping server logging that only existed for them
+func removeAccessLogHosts(config *models.CaddyConfig, loggerName, keep string) {
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.Logs == nil {
+			continue
+		}
+
+		serves := slices.ContainsFunc(server.Routes, func(route models.CaddyRoute) bool {
+			return accessLogLoggerName(route.ID) == loggerName
+		})
+		changed := false
+		for host, name := range server.Logs.LoggerNames {
+			if name == loggerName && (host != keep || !serves) {
+				delete(server.Logs.LoggerNames, host)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if len(server.Logs.LoggerNames) == 0 && server.Logs.DefaultLoggerName == "" && len(server.Logs.SkipHosts) == 0 {
+			server.Logs = nil
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+}
+
+// logOnlyErrors has every reverse_proxy handler in route log upstream responses below 400 to the
+// child logger the proxy's access log excludes. Errors Caddy answers itself, such as a 502 for an
+// unreachable upstream, keep the proxy's logger.
+func logOnlyErrors(route *models.CaddyRoute, proxyID string) {
+	okLogger := accessLogLoggerName(proxyID) + accessLogOKSuffix
+
+	for i := range route.Handle {
+		handler := &route.Handle[i]
+		if handler.Handler == "reverse_proxy" {
+			handler.HandleResponse = append(handler.HandleResponse, models.CaddyResponseHandler{
+				Match: &models.CaddyResponseMatch{StatusCode: []int{1, 2, 3}},
+				Routes: []models.CaddyRoute{{
+					Handle: []models.CaddyHandler{
+						{Handler: "vars", AccessLoggerNames: []string{okLogger}},
+						{Handler: "copy_response"},
+					},
+				}},
+			})
+		}
+		for j := range handler.Routes {
+			logOnlyErrors(&handler.Routes[j], proxyID)
+		}
+	}
+}
+
+// DeleteAccessLog removes a deleted proxy's access log
+func (c *Client) DeleteAccessLog(proxyID string) error {
+	file, err := c.AccessLogFile(proxyID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
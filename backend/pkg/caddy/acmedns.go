@@ -0,0 +1,85 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// DNSProviderACMEDNS delegates DNS challenges to an acme-dns server
+const DNSProviderACMEDNS = "acmedns"
+
+// SaveACMEDNSAccount stores an acme-dns account, encrypting its password
+func (c *Client) SaveACMEDNSAccount(account models.ACMEDNSAccount) error {
+	if c.secrets != nil {
+		encrypted, err := c.secrets.Encrypt(account.Password)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt acme-dns password: %v", err)
+		}
+		account.Password = encrypted
+	}
+
+	c.metadata.SetACMEDNSAccount(account)
+	return c.saveMetadataToFile()
+}
+
+// ACMEDNSAccount returns the acme-dns account registered for a domain, with its password decrypted
+func (c *Client) ACMEDNSAccount(domain string) (models.ACMEDNSAccount, bool) {
+	account, exists := c.metadata.GetACMEDNSAccount(domain)
+	if !exists {
+		return account, false
+	}
+
+	if c.secrets != nil {
+		password, err := c.secrets.Decrypt(account.Password)
+		if err != nil {
+			log.Printf("Warning: Failed to decrypt acme-dns password for %s: %v", domain, err)
+			return account, false
+		}
+		account.Password = password
+	}
+	return account, true
+}
+
+// ACMEDNSAccounts returns all registered acme-dns accounts sorted by domain, without passwords
+func (c *Client) ACMEDNSAccounts() []models.ACMEDNSAccount {
+	accounts := make([]models.ACMEDNSAccount, 0, len(c.metadata.ACMEDNSAccounts))
+	for _, account := range c.metadata.ACMEDNSAccounts {
+		account.Password = ""
+		accounts = append(accounts, account)
+	}
+
+	slices.SortFunc(accounts, func(a, b models.ACMEDNSAccount) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return accounts
+}
+
+// DeleteACMEDNSAccount forgets the acme-dns account for a domain. The account remains on the
+// acme-dns server, which has no API to remove it.
+func (c *Client) DeleteACMEDNSAccount(domain string) error {
+	if _, exists := c.metadata.GetACMEDNSAccount(domain); !exists {
+		return fmt.Errorf("no acme-dns account registered for %s", domain)
+	}
+
+	c.metadata.DeleteACMEDNSAccount(domain)
+	return c.saveMetadataToFile()
+}
+
+// ACMEDNSCredentials returns the dns_credentials for a domain's registered acme-dns account
+func (c *Client) ACMEDNSCredentials(domain string) (map[string]string, bool) {
+	account, exists := c.ACMEDNSAccount(domain)
+	if !exists {
+		return nil, false
+	}
+
+	return map[string]string{
+		"username":   account.Username,
+		"password":   account.Password,
+		"subdomain":  account.Subdomain,
+		"server_url": account.ServerURL,
+	}, true
+}
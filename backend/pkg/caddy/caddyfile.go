@@ -0,0 +1,628 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// caddyfileWriter writes Caddyfile lines, indenting them by block depth
+type caddyfileWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+func (w *caddyfileWriter) line(format string, args ...any) {
+	if format == "" {
+		w.b.WriteString("\n")
+		return
+	}
+	w.b.WriteString(strings.Repeat("\t", w.depth))
+	fmt.Fprintf(&w.b, format, args...)
+	w.b.WriteString("\n")
+}
+
+func (w *caddyfileWriter) open(format string, args ...any) {
+	w.line(format+" {", args...)
+	w.depth++
+}
+
+func (w *caddyfileWriter) close() {
+	w.depth--
+	w.line("}")
+}
+
+// Caddyfile renders the managed proxies and redirects as a Caddyfile, for users who want to leave
+// the manager or review their setup in familiar syntax. Settings a Caddyfile can't express are
+// noted in comments where they apply, and DNS provider credentials are read from environment
+// variables instead of being written out.
+func (c *Client) Caddyfile() (string, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	w := &caddyfileWriter{}
+	w.line("# Caddyfile generated by Caddy Proxy Manager on %s", time.Now().UTC().Format(time.RFC3339))
+	w.line("# Health checks, custom error pages and debug captures are run by the manager and aren't")
+	w.line("# included. DNS provider credentials are read from the environment variables named below.")
+
+	if domains := c.PreprovisionedDomains(config); len(domains) > 0 {
+		w.line("#")
+		w.line("# Not converted: certificates pre-provisioned without a site: %s", strings.Join(domains, ", "))
+	}
+
+	proxies := c.ParseProxiesFromConfig(config)
+	streams := c.ParseStreamsFromConfig(config)
+	waf := slices.ContainsFunc(proxies, wafEnabled)
+
+	serverOptions := c.caddyfileServerOptions(config)
+	storage, err := storageSettingsFromConfig(config)
+	if err != nil {
+		return "", err
+	}
+	customStorage := storage.Module != models.StorageFileSystem || storage.Root != ""
+
+	// Stream proxies, server protocols, storage and the WAF's handler order live in the global options
+	// block, which has to come before every site
+	if len(streams) > 0 || waf || len(serverOptions) > 0 || customStorage {
+		w.line("")
+		w.line("{")
+		w.depth++
+		if waf {
+			w.line("order coraza_waf first")
+		}
+		switch {
+		case storage.Module == models.StorageFileSystem && storage.Root != "":
+			w.line("storage file_system %s", caddyfileToken(storage.Root))
+		case customStorage:
+			w.line("# Not converted: %s certificate storage at %s, configure it with the module's storage options", storage.Module, strings.Join(storage.Addresses, ", "))
+		}
+		for _, option := range serverOptions {
+			w.open("%s", option.block)
+			w.line("protocols %s", strings.Join(option.protocols, " "))
+			w.close()
+		}
+		if len(streams) > 0 {
+			w.open("layer4")
+			for _, stream := range streams {
+				address := stream.Listen
+				if stream.Protocol == models.StreamProtocolUDP {
+					address = "udp/" + address
+				}
+				w.line("# %s", stream.ID)
+				w.open("%s", address)
+				w.open("route")
+				w.line("proxy %s", strings.Join(streamDials(stream), " "))
+				w.close()
+				w.close()
+			}
+			w.close()
+		}
+		w.close()
+	}
+
+	for _, proxy := range proxies {
+		w.line("")
+		if err := c.writeCaddyfileProxy(w, proxy); err != nil {
+			return "", fmt.Errorf("proxy %s: %v", proxy.ID, err)
+		}
+	}
+
+	for _, redirect := range c.ParseRedirectsFromConfig(config) {
+		destination := redirect.DestinationURL
+		if redirect.PreservePath {
+			destination += "{uri}"
+		}
+
+		w.line("")
+		w.line("# %s", redirect.ID)
+		w.open("%s", strings.Join(redirect.SourceDomains, ", "))
+		w.line("redir %s %d", caddyfileToken(destination), redirect.RedirectCode)
+		w.close()
+	}
+
+	return w.b.String(), nil
+}
+
+// writeCaddyfileProxy writes the site block of a proxy, followed by its canonical redirect site
+func (c *Client) writeCaddyfileProxy(w *caddyfileWriter, proxy models.Proxy) error {
+	upstream, err := ResolveTemplates(proxy)
+	if err != nil {
+		return err
+	}
+
+	// Imported IP lists and access lists are written out along with the proxy's own entries
+	if proxy.AllowedIPs, proxy.BlockedIPs, err = c.proxyIPRanges(proxy); err != nil {
+		return err
+	}
+
+	addresses, binds := caddyfileSiteAddresses(proxy, proxy.Domain)
+
+	w.line("# Proxy %s", proxy.ID)
+	w.open("%s", strings.Join(addresses, ", "))
+	if len(binds) > 0 {
+		w.line("bind %s", strings.Join(binds, " "))
+	}
+	c.writeCaddyfileTLS(w, proxy)
+
+	if proxy.AccessLog != "" {
+		logFile, err := c.AccessLogFile(proxy.ID)
+		if err != nil {
+			return err
+		}
+		if proxy.AccessLog == AccessLogErrors {
+			w.line("# Not converted: logging only errors, which needs logger exclusions; the log below gets every request")
+		}
+		w.open("log")
+		w.open("output file %s", caddyfileToken(logFile))
+		w.line("roll_size %dMiB", accessLogRollSizeMB)
+		w.line("roll_keep %d", accessLogRollKeep)
+		w.close()
+		w.line("format json")
+		w.close()
+	}
+
+	if wafEnabled(proxy) {
+		logFile, err := c.WAFLogFile(proxy.ID)
+		if err != nil {
+			return err
+		}
+		w.open("coraza_waf")
+		w.line("load_owasp_crs")
+		w.line("directives `")
+		for _, directive := range strings.Split(wafDirectives(*proxy.WAF, logFile), "\n") {
+			w.line("\t%s", directive)
+		}
+		w.line("`")
+		w.close()
+	}
+
+	// IP lists and basic auth must run before the upstream in the order written, which route keeps
+	restricted := len(proxy.AllowedIPs) > 0 || len(proxy.BlockedIPs) > 0
+	if restricted {
+		w.open("route")
+		writeCaddyfileIPFilter(w, proxy)
+	}
+
+	accounts, err := c.proxyBasicAuthAccounts(proxy)
+	if err != nil {
+		return err
+	}
+	if len(accounts) > 0 {
+		w.open("basic_auth")
+		for _, account := range accounts {
+			w.line("%s %s", caddyfileToken(account.Username), account.Password)
+		}
+		w.close()
+	}
+
+	if proxy.BandwidthLimit > 0 {
+		w.line("# Not converted: bandwidth limit of %d bytes/s, which needs the %q handler module", proxy.BandwidthLimit, BandwidthHandler)
+	}
+	if proxy.MaxBodySize > 0 {
+		w.open("request_body")
+		w.line("max_size %d", proxy.MaxBodySize)
+		w.close()
+	}
+	if timeouts := isolatedServerTimeouts(proxy); proxy.Isolated && timeouts != nil {
+		w.line("# Not converted: server timeouts of the isolated server (read_body %q, write %q), set them in a servers block", timeouts.ReadBody, timeouts.Write)
+	}
+
+	// Path rules take their prefixes, the proxy's own upstream handles everything else
+	pathRules := len(upstream.PathRules) > 0
+	if pathRules {
+		for i, rule := range orderedPathRules(upstream.PathRules) {
+			w.line("@path_%d path %s %s/*", i, caddyfileToken(rule.Path), caddyfileToken(rule.Path))
+			w.open("handle @path_%d", i)
+			if rule.StripPrefix {
+				w.line("uri strip_prefix %s", caddyfileToken(rule.Path))
+			}
+			if err := writeCaddyfileReverseProxy(w, pathRuleUpstream(upstream, rule)); err != nil {
+				return fmt.Errorf("path rule %s: %v", rule.Path, err)
+			}
+			w.close()
+		}
+		w.open("handle")
+	}
+
+	if proxy.UpstreamType == UpstreamTypeFastCGI {
+		if err := writeCaddyfileFastCGI(w, upstream); err != nil {
+			return err
+		}
+	} else if err := writeCaddyfileReverseProxy(w, upstream); err != nil {
+		return err
+	}
+
+	if pathRules {
+		w.close()
+	}
+	if restricted {
+		w.close()
+	}
+
+	if proxy.CustomCaddyJSON != "" {
+		w.line("# Not converted: custom Caddy JSON merged into the route:")
+		for _, line := range strings.Split(strings.TrimSpace(proxy.CustomCaddyJSON), "\n") {
+			w.line("#   %s", line)
+		}
+	}
+	w.close()
+
+	if proxy.CanonicalRedirect {
+		partner := CanonicalPartnerDomain(proxy.Domain)
+		scheme := "https"
+		if proxy.SSLMode == SSLModeNone {
+			scheme = "http"
+		}
+
+		partnerAddresses, partnerBinds := caddyfileSiteAddresses(proxy, partner)
+		w.line("")
+		w.line("# Canonical redirect of proxy %s", proxy.ID)
+		w.open("%s", strings.Join(partnerAddresses, ", "))
+		if len(partnerBinds) > 0 {
+			w.line("bind %s", strings.Join(partnerBinds, " "))
+		}
+		partnerProxy := proxy
+		partnerProxy.Domain = partner
+		if proxy.SSLMode != SSLModeCustom {
+			c.writeCaddyfileTLS(w, partnerProxy)
+		}
+		w.line("redir %s://%s{uri} 301", scheme, proxy.Domain)
+		w.close()
+	}
+
+	return nil
+}
+
+// caddyfileSiteAddresses returns the site addresses serving domain for a proxy, with the
+// interfaces to bind to when an isolated proxy listens on specific addresses
+func caddyfileSiteAddresses(proxy models.Proxy, domain string) ([]string, []string) {
+	prefix := ""
+	if proxy.SSLMode == SSLModeNone {
+		prefix = "http://"
+	}
+
+	if !proxy.Isolated {
+		return []string{prefix + domain}, nil
+	}
+
+	var addresses, binds []string
+	for _, listen := range proxy.IsolatedListen {
+		host, port, err := net.SplitHostPort(listen)
+		if err != nil {
+			continue
+		}
+		address := prefix + net.JoinHostPort(HostOnly(domain), port)
+		if !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+		if host != "" && !slices.Contains(binds, host) {
+			binds = append(binds, host)
+		}
+	}
+	return addresses, binds
+}
+
+// writeCaddyfileTLS writes the tls directive for a proxy's SSL mode
+func (c *Client) writeCaddyfileTLS(w *caddyfileWriter, proxy models.Proxy) {
+	switch proxy.SSLMode {
+	case SSLModeInternal:
+		w.line("tls internal")
+	case SSLModeCustom:
+		certFile, keyFile, err := c.CustomCertificateFiles(proxy.ID)
+		if _, statErr := os.Stat(certFile); err != nil || statErr != nil {
+			w.line("# SSL mode custom: no certificate has been uploaded, add tls <cert_file> <key_file>")
+			return
+		}
+		w.line("tls %s %s", caddyfileToken(certFile), caddyfileToken(keyFile))
+	case SSLModeExisting:
+		w.line("# Not converted: SSL mode existing. Caddy will request a certificate for this site; load")
+		w.line("# the wildcard certificate it should use with tls <cert_file> <key_file> instead")
+	case SSLModeAuto:
+		if proxy.ChallengeType != "dns" || proxy.DNSProvider == "" {
+			return
+		}
+		w.open("tls")
+		writeCaddyfileDNSProvider(w, proxy)
+		w.close()
+	}
+}
+
+// writeCaddyfileDNSProvider writes the dns subdirective of a DNS challenge, with every credential
+// taken from an environment variable
+func writeCaddyfileDNSProvider(w *caddyfileWriter, proxy models.Proxy) {
+	provider, ok := LookupDNSProvider(proxy.DNSProvider)
+	if !ok {
+		w.line("# Not converted: unsupported DNS provider %s", proxy.DNSProvider)
+		return
+	}
+
+	module := provider.Module
+	var fields []DNSCredentialField
+	if provider.Name == DNSProviderCustom {
+		var custom map[string]any
+		if err := json.Unmarshal([]byte(proxy.DNSCredentials[DNSCustomProviderKey]), &custom); err != nil {
+			w.line("# Not converted: custom DNS provider JSON is invalid")
+			return
+		}
+		module, _ = custom["name"].(string)
+		for key := range custom {
+			if key != "name" {
+				fields = append(fields, DNSCredentialField{Key: key, EnvVar: strings.ToUpper(module + "_" + key)})
+			}
+		}
+		slices.SortFunc(fields, func(a, b DNSCredentialField) int { return strings.Compare(a.Key, b.Key) })
+	} else {
+		for _, field := range provider.Fields {
+			if getCredential(proxy, field.Key, field.EnvVar) != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	w.line("# The provider's Caddyfile syntax may differ; check its documentation")
+	w.open("dns %s", module)
+	for _, field := range fields {
+		w.line("%s {env.%s}", field.Key, field.EnvVar)
+	}
+	w.close()
+}
+
+// writeCaddyfileIPFilter rejects requests the proxy's IP lists don't allow, letting exception
+// paths through from anywhere
+func writeCaddyfileIPFilter(w *caddyfileWriter, proxy models.Proxy) {
+	w.open("@blocked")
+	if len(proxy.AllowedIPs) > 0 {
+		w.line("not remote_ip %s", strings.Join(proxy.AllowedIPs, " "))
+	} else {
+		w.line("remote_ip %s", strings.Join(proxy.BlockedIPs, " "))
+	}
+	if len(proxy.IPExceptionPaths) > 0 {
+		w.line("not path %s", strings.Join(proxy.IPExceptionPaths, " "))
+	}
+	w.close()
+	w.line("respond @blocked 403")
+}
+
+// writeCaddyfileReverseProxy writes the reverse_proxy directive matching buildReverseProxyHandler
+func writeCaddyfileReverseProxy(w *caddyfileWriter, proxy models.Proxy) error {
+	targets := ProxyTargets(proxy)
+	dials := make([]string, 0, len(targets)+1)
+	var useHTTPS bool
+	var targetHost string
+	for i, target := range targets {
+		dial, https, host, err := parseTargetURL(target)
+		if err != nil {
+			return fmt.Errorf("invalid target URL %s: %v", target, err)
+		}
+		if i == 0 {
+			useHTTPS, targetHost = https, host
+		}
+		dials = append(dials, dial)
+	}
+	if proxy.BackupTargetURL != "" {
+		dial, _, _, err := parseTargetURL(proxy.BackupTargetURL)
+		if err != nil {
+			return fmt.Errorf("invalid backup target URL: %v", err)
+		}
+		dials = append(dials, dial)
+	}
+
+	w.open("reverse_proxy %s", strings.Join(dials, " "))
+	switch {
+	case proxy.PreserveHost:
+	case len(targets) > 1:
+		w.line("header_up Host {http.reverse_proxy.upstream.host}")
+	default:
+		w.line("header_up Host %s", caddyfileToken(targetHost))
+	}
+	for _, key := range sortedKeys(proxy.CustomHeaders) {
+		w.line("header_up %s %s", key, caddyfileToken(proxy.CustomHeaders[key]))
+	}
+
+	if len(targets) > 1 || proxy.BackupTargetURL != "" {
+		policy := "first"
+		if proxy.BackupTargetURL == "" {
+			policy = proxy.LBPolicy
+			if policy == "" {
+				policy = DefaultLBPolicy
+			}
+		}
+		w.line("lb_policy %s", policy)
+		w.line("lb_try_duration %s", failoverTryDuration)
+	}
+	writeCaddyfileHealthChecks(w, proxy, len(targets) > 1 || proxy.BackupTargetURL != "")
+
+	ws := proxy.WebSocket
+	if !webSocketEnabled(proxy) {
+		ws = &models.WebSocket{}
+	}
+	timeouts := proxy.Timeouts
+	if timeouts == nil {
+		timeouts = &models.Timeouts{}
+	}
+	if useHTTPS || proxy.GRPC || ws.ReadBufferSize > 0 || ws.WriteBufferSize > 0 || *timeouts != (models.Timeouts{}) {
+		w.open("transport http")
+		if useHTTPS {
+			w.line("tls")
+			if proxy.UpstreamSNI != "" {
+				w.line("tls_server_name %s", caddyfileToken(proxy.UpstreamSNI))
+			}
+		}
+		switch {
+		case proxy.GRPC && useHTTPS:
+			w.line("versions 2")
+		case proxy.GRPC:
+			w.line("versions h2c 2")
+		}
+		if ws.ReadBufferSize > 0 {
+			w.line("read_buffer %d", ws.ReadBufferSize)
+		}
+		if ws.WriteBufferSize > 0 {
+			w.line("write_buffer %d", ws.WriteBufferSize)
+		}
+		writeCaddyfileTimeouts(w, *timeouts)
+		w.close()
+	}
+	switch {
+	case ws.FlushInterval != "":
+		w.line("flush_interval %s", ws.FlushInterval)
+	case proxy.FlushInterval != "":
+		w.line("flush_interval %s", proxy.FlushInterval)
+	case proxy.GRPC:
+		w.line("flush_interval -1")
+	}
+	w.close()
+
+	return nil
+}
+
+// writeCaddyfileFastCGI writes the php_fastcgi directive buildFastCGIHandlers is modelled on
+func writeCaddyfileFastCGI(w *caddyfileWriter, proxy models.Proxy) error {
+	dial := fastCGIDialAddress(proxy.TargetURL)
+	if dial == "" {
+		return fmt.Errorf("invalid fastcgi target: %s", proxy.TargetURL)
+	}
+
+	w.line("root * %s", caddyfileToken(proxy.FastCGIRoot))
+	if len(proxy.CustomHeaders) == 0 && proxy.Timeouts == nil && proxy.FlushInterval == "" {
+		w.line("php_fastcgi %s", dial)
+		return nil
+	}
+
+	w.open("php_fastcgi %s", dial)
+	for _, key := range sortedKeys(proxy.CustomHeaders) {
+		w.line("header_up %s %s", key, caddyfileToken(proxy.CustomHeaders[key]))
+	}
+	if proxy.Timeouts != nil {
+		writeCaddyfileTimeouts(w, *proxy.Timeouts)
+	}
+	if proxy.FlushInterval != "" {
+		w.line("flush_interval %s", proxy.FlushInterval)
+	}
+	w.close()
+	return nil
+}
+
+// writeCaddyfileTimeouts writes the upstream timeouts that are set, as transport or php_fastcgi
+// subdirectives
+func writeCaddyfileTimeouts(w *caddyfileWriter, timeouts models.Timeouts) {
+	if timeouts.Dial != "" {
+		w.line("dial_timeout %s", timeouts.Dial)
+	}
+	if timeouts.Read != "" {
+		w.line("read_timeout %s", timeouts.Read)
+	}
+	if timeouts.Write != "" {
+		w.line("write_timeout %s", timeouts.Write)
+	}
+}
+
+// caddyfileToken quotes a value when it would otherwise be split into several Caddyfile tokens
+func caddyfileToken(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\"") {
+		return value
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// caddyfileServerOption is a servers block of the global options setting a listener's protocols
+type caddyfileServerOption struct {
+	block     string
+	protocols []string
+}
+
+// caddyfileServerOptions returns the servers blocks for protocols that differ from Caddy's defaults:
+// one for the default, then one per listener of each server with its own protocols
+func (c *Client) caddyfileServerOptions(config *models.CaddyConfig) []caddyfileServerOption {
+	if c.metadata.ServerSettings == nil {
+		return nil
+	}
+	settings := *c.metadata.ServerSettings
+
+	var options []caddyfileServerOption
+	if protocols := settings.Default.CaddyProtocols(); protocols != nil {
+		options = append(options, caddyfileServerOption{block: "servers", protocols: protocols})
+	}
+
+	for _, serverName := range slices.Sorted(maps.Keys(config.Apps.HTTP.Servers)) {
+		override, exists := settings.Servers[serverName]
+		if !exists {
+			continue
+		}
+		protocols := override.CaddyProtocols()
+		if protocols == nil {
+			protocols = []string{"h1", "h2", "h3"}
+		}
+		for _, listen := range config.Apps.HTTP.Servers[serverName].Listen {
+			options = append(options, caddyfileServerOption{block: "servers " + listen, protocols: protocols})
+		}
+	}
+	return options
+}
+
+// writeCaddyfileHealthChecks writes a proxy's Caddy health checks. Load balancing and failover use
+// passive checks even when the proxy doesn't configure them.
+func writeCaddyfileHealthChecks(w *caddyfileWriter, proxy models.Proxy, balanced bool) {
+	health := proxy.UpstreamHealth
+	if health == nil {
+		health = &models.UpstreamHealth{}
+	}
+
+	if health.Active {
+		active := activeHealthChecks(proxy)
+		w.line("health_uri %s", caddyfileToken(active.URI))
+		if active.Interval != "" {
+			w.line("health_interval %s", active.Interval)
+		}
+		if active.Method != "" {
+			w.line("health_method %s", active.Method)
+		}
+		if active.Body != "" {
+			w.line("health_request_body %s", caddyfileToken(active.Body))
+		}
+		switch {
+		case active.ExpectStatus >= 1 && active.ExpectStatus <= 5:
+			w.line("health_status %dxx", active.ExpectStatus)
+		case active.ExpectStatus != 0:
+			w.line("health_status %d", active.ExpectStatus)
+		}
+		if active.ExpectBody != "" {
+			w.line("health_body %s", caddyfileToken(active.ExpectBody))
+		}
+		if userAgent := active.Headers["User-Agent"]; len(userAgent) > 0 {
+			w.open("health_headers")
+			w.line("User-Agent %s", caddyfileToken(userAgent[0]))
+			w.close()
+		}
+	}
+
+	if health.Passive || balanced {
+		passive := passiveHealthChecks(*health)
+		statuses := make([]string, len(passive.UnhealthyStatus))
+		for i, status := range passive.UnhealthyStatus {
+			statuses[i] = strconv.Itoa(status)
+		}
+		w.line("fail_duration %s", passive.FailDuration)
+		w.line("max_fails %d", passive.MaxFails)
+		w.line("unhealthy_status %s", strings.Join(statuses, " "))
+	}
+}
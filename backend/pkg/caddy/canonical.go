@@ -0,0 +1,65 @@
+package caddy
+
+import (
+	"net"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// canonicalRouteSuffix is appended to a proxy's ID for the route redirecting its www/apex partner
+const canonicalRouteSuffix = "_canonical"
+
+// CanonicalPartnerDomain returns the www/apex counterpart of a domain, e.g. www.example.com for
+// example.com and the reverse. Returns "" for IPs and names without a counterpart.
+func CanonicalPartnerDomain(domain string) string {
+	host, port, err := net.SplitHostPort(domain)
+	if err != nil {
+		host, port = domain, ""
+	}
+
+	host = strings.ToLower(host)
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return ""
+	}
+
+	partner := "www." + host
+	if apex, ok := strings.CutPrefix(host, "www."); ok {
+		if !strings.Contains(apex, ".") {
+			return ""
+		}
+		partner = apex
+	}
+
+	if port != "" {
+		return net.JoinHostPort(partner, port)
+	}
+	return partner
+}
+
+// buildCanonicalRoute creates the route that permanently redirects a proxy's partner domain to it
+func buildCanonicalRoute(proxy models.Proxy) *models.CaddyRoute {
+	scheme := "https"
+	if proxy.SSLMode == SSLModeNone {
+		scheme = "http"
+	}
+
+	return &models.CaddyRoute{
+		ID:    proxy.ID + canonicalRouteSuffix,
+		Match: []models.CaddyMatch{{Host: []string{CanonicalPartnerDomain(proxy.Domain)}}},
+		Handle: []models.CaddyHandler{
+			{
+				Handler: "headers",
+				Response: &models.CaddyHeadersResponse{
+					Set: map[string][]string{
+						"Location": {scheme + "://" + proxy.Domain + "{http.request.uri}"},
+					},
+				},
+			},
+			{
+				Handler:    "static_response",
+				StatusCode: 301,
+			},
+		},
+	}
+}
@@ -0,0 +1,257 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// DefaultDebugCaptureDuration is used when a capture is started without a duration
+	DefaultDebugCaptureDuration = 10 * time.Minute
+	// MaxDebugCaptureDuration bounds how long a capture may run before it is disabled
+	MaxDebugCaptureDuration = time.Hour
+
+	debugCaptureLoggerPrefix = "debug_capture_"
+	accessLoggerPrefix       = "http.log.access."
+	defaultLogName           = "default"
+	debugCaptureRollSizeMB   = 10
+)
+
+// debugCaptureLoggerName returns the Caddy logger name used for a proxy's capture
+func debugCaptureLoggerName(proxyID string) string {
+	return debugCaptureLoggerPrefix + proxyID
+}
+
+// DebugCaptureFile returns the path of the capture file for a proxy. The file is kept after
+// the capture ends so it can still be downloaded.
+func (c *Client) DebugCaptureFile(proxyID string) (string, error) {
+	if proxyID == "" || filepath.Base(proxyID) != proxyID {
+		return "", fmt.Errorf("invalid proxy ID")
+	}
+
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "captures"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve capture directory: %v", err)
+	}
+
+	return filepath.Join(dir, proxyID+".log"), nil
+}
+
+// StartDebugCapture enables access logging for a single proxy into its capture file for the
+// given duration, replacing any previous capture for that proxy
+func (c *Client) StartDebugCapture(proxyID string, duration time.Duration) (*models.DebugCapture, error) {
+	proxy, err := c.GetProxy(proxyID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := c.DebugCaptureFile(proxyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %v", err)
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove previous capture: %v", err)
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	now := time.Now()
+	capture := models.DebugCapture{
+		ProxyID:   proxyID,
+		File:      file,
+		StartedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(duration).Format(time.RFC3339),
+	}
+	applyDebugCapture(config, capture, proxy.Domain)
+
+	if err := c.updateConfig(config); err != nil {
+		return nil, err
+	}
+
+	c.metadata.SetDebugCapture(capture)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+
+	return &capture, nil
+}
+
+// GetDebugCapture returns the active debug capture for a proxy, if any
+func (c *Client) GetDebugCapture(proxyID string) (models.DebugCapture, bool) {
+	return c.metadata.GetDebugCapture(proxyID)
+}
+
+// StopDebugCapture disables the debug capture for a proxy, keeping the captured file
+func (c *Client) StopDebugCapture(proxyID string) error {
+	if _, exists := c.metadata.GetDebugCapture(proxyID); !exists {
+		return fmt.Errorf("no debug capture running for proxy %s", proxyID)
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	removeDebugCapture(config, proxyID)
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.DeleteDebugCapture(proxyID)
+	return c.saveMetadataToFile()
+}
+
+// DeleteDebugCapture stops any running capture for a deleted proxy and removes its file
+func (c *Client) DeleteDebugCapture(proxyID string) {
+	if _, exists := c.metadata.GetDebugCapture(proxyID); exists {
+		if err := c.StopDebugCapture(proxyID); err != nil {
+			log.Printf("Warning: Failed to stop debug capture for proxy %s: %v", proxyID, err)
+		}
+	}
+
+	if file, err := c.DebugCaptureFile(proxyID); err == nil {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to remove debug capture file: %v", err)
+		}
+	}
+}
+
+// ExpireDebugCaptures stops all captures whose duration has elapsed
+func (c *Client) ExpireDebugCaptures() {
+	now := time.Now()
+	for proxyID, capture := range c.metadata.DebugCaptures {
+		expiresAt, err := time.Parse(time.RFC3339, capture.ExpiresAt)
+		if err == nil && now.Before(expiresAt) {
+			continue
+		}
+
+		if err := c.StopDebugCapture(proxyID); err != nil {
+			log.Printf("Warning: Failed to stop expired debug capture for proxy %s: %v", proxyID, err)
+			continue
+		}
+		log.Printf("Debug capture for proxy %s expired\n", proxyID)
+	}
+}
+
+// applyDebugCapture adds a file log for the capture and maps the proxy's host to it on every
+// server that serves the proxy route
+func applyDebugCapture(config *models.CaddyConfig, capture models.DebugCapture, domain string) {
+	loggerName := debugCaptureLoggerName(capture.ProxyID)
+	accessLogger := accessLoggerPrefix + loggerName
+
+	if config.Logging == nil {
+		config.Logging = &models.CaddyLogging{}
+	}
+	if config.Logging.Logs == nil {
+		config.Logging.Logs = make(map[string]models.CaddyLog)
+	}
+
+	roll := true
+	config.Logging.Logs[loggerName] = models.CaddyLog{
+		Writer: &models.CaddyLogWriter{
+			Output:     "file",
+			Filename:   capture.File,
+			Roll:       &roll,
+			RollSizeMB: debugCaptureRollSizeMB,
+			RollKeep:   1,
+		},
+		Encoder: &models.CaddyLogEncoder{Format: "json"},
+		// A proxy with an access log keeps its host mapped to that, which the capture listens to
+		Include: []string{accessLogger, accessLoggerPrefix + accessLogLoggerName(capture.ProxyID)},
+	}
+
+	// Keep captured requests out of the default log
+	defaultLog := config.Logging.Logs[defaultLogName]
+	if !slices.Contains(defaultLog.Exclude, accessLogger) {
+		defaultLog.Exclude = append(defaultLog.Exclude, accessLogger)
+	}
+	config.Logging.Logs[defaultLogName] = defaultLog
+
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if !slices.ContainsFunc(server.Routes, func(route models.CaddyRoute) bool { return route.ID == capture.ProxyID }) {
+			continue
+		}
+
+		if server.Logs == nil {
+			// Only log the captured hosts, not every site on the server
+			server.Logs = &models.CaddyServerLogs{SkipUnmappedHosts: true}
+		}
+		if server.Logs.LoggerNames == nil {
+			server.Logs.LoggerNames = make(map[string]string)
+		}
+		if server.Logs.LoggerNames[host] != accessLogLoggerName(capture.ProxyID) {
+			server.Logs.LoggerNames[host] = loggerName
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+}
+
+// removeDebugCapture undoes applyDebugCapture, dropping logging config that only existed for it
+func removeDebugCapture(config *models.CaddyConfig, proxyID string) {
+	loggerName := debugCaptureLoggerName(proxyID)
+	accessLogger := accessLoggerPrefix + loggerName
+
+	if config.Logging != nil {
+		delete(config.Logging.Logs, loggerName)
+
+		if defaultLog, exists := config.Logging.Logs[defaultLogName]; exists {
+			defaultLog.Exclude = slices.DeleteFunc(defaultLog.Exclude, func(name string) bool { return name == accessLogger })
+			if defaultLog.Writer == nil && defaultLog.Encoder == nil && defaultLog.Level == "" &&
+				len(defaultLog.Include) == 0 && len(defaultLog.Exclude) == 0 {
+				delete(config.Logging.Logs, defaultLogName)
+			} else {
+				config.Logging.Logs[defaultLogName] = defaultLog
+			}
+		}
+
+		if len(config.Logging.Logs) == 0 {
+			config.Logging = nil
+		}
+	}
+
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.Logs == nil {
+			continue
+		}
+
+		changed := false
+		for host, name := range server.Logs.LoggerNames {
+			if name == loggerName {
+				delete(server.Logs.LoggerNames, host)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if len(server.Logs.LoggerNames) == 0 && server.Logs.DefaultLoggerName == "" && len(server.Logs.SkipHosts) == 0 {
+			server.Logs = nil
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+}
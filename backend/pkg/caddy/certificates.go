@@ -0,0 +1,249 @@
+package caddy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Certificate status values reported for pre-provisioned domains
+const (
+	CertificateIssued  = "issued"
+	CertificatePending = "pending"
+	CertificateUnknown = "unknown"
+
+	certificateCheckTimeout = 5 * time.Second
+
+	// Caddy renews certificates with a third of their lifetime left (30 days for 90-day
+	// certificates), so one still this close to expiry has likely failed to renew
+	renewalOverdueDays = 20
+)
+
+// CertificateStatus describes the certificate Caddy currently serves for a domain
+type CertificateStatus struct {
+	Domain       string `json:"domain"`
+	Status       string `json:"status"`
+	Issuer       string `json:"issuer,omitempty"`
+	NotAfter     string `json:"not_after,omitempty"`
+	DaysToExpiry int    `json:"days_to_expiry"` // Only meaningful when not_after is set
+	Message      string `json:"message,omitempty"`
+}
+
+// RenewalOverdue reports whether a certificate is close enough to expiry that Caddy has likely failed
+// to renew it
+func (s CertificateStatus) RenewalOverdue() bool {
+	return s.Status == CertificateIssued && s.DaysToExpiry < renewalOverdueDays
+}
+
+// PreprovisionCertificates asks Caddy to obtain certificates for domains that have no route yet,
+// so DNS can be switched to this server without a window of certificate errors. With a DNS
+// challenge in challenge, it is applied to each domain; otherwise Caddy's default issuers are used.
+func (c *Client) PreprovisionCertificates(domains []string, challenge models.Proxy) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	if config.Apps.TLS == nil {
+		config.Apps.TLS = &models.CaddyTLS{}
+	}
+	if config.Apps.TLS.Certificates == nil {
+		config.Apps.TLS.Certificates = &models.CaddyTLSCertificates{}
+	}
+
+	for _, domain := range domains {
+		if !slices.Contains(config.Apps.TLS.Certificates.Automate, domain) {
+			config.Apps.TLS.Certificates.Automate = append(config.Apps.TLS.Certificates.Automate, domain)
+		}
+
+		if challenge.ChallengeType == "dns" {
+			policyProxy := challenge
+			policyProxy.Domain = domain
+			c.configureDNSChallenge(config, policyProxy)
+		}
+	}
+
+	return c.updateConfig(config)
+}
+
+// PreprovisionedDomains returns the domains Caddy manages certificates for without a route
+func (c *Client) PreprovisionedDomains(config *models.CaddyConfig) []string {
+	if config.Apps.TLS == nil || config.Apps.TLS.Certificates == nil {
+		return nil
+	}
+	return config.Apps.TLS.Certificates.Automate
+}
+
+// RemovePreprovisionedDomain stops managing a pre-provisioned certificate. The DNS challenge policy
+// is kept if a proxy now serves the domain, since the proxy relies on it.
+func (c *Client) RemovePreprovisionedDomain(domain string) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	if !slices.Contains(c.PreprovisionedDomains(config), domain) {
+		return fmt.Errorf("domain %s is not pre-provisioned", domain)
+	}
+
+	certificates := config.Apps.TLS.Certificates
+	certificates.Automate = slices.DeleteFunc(certificates.Automate, func(name string) bool { return name == domain })
+	if len(certificates.Automate) == 0 {
+		config.Apps.TLS.Certificates = nil
+	}
+
+	servedByProxy := slices.ContainsFunc(c.ParseProxiesFromConfig(config), func(proxy models.Proxy) bool { return proxy.Domain == domain })
+	if !servedByProxy && config.Apps.TLS.Automation != nil {
+		config.Apps.TLS.Automation.Policies = slices.DeleteFunc(config.Apps.TLS.Automation.Policies, func(policy models.CaddyAutomationPolicy) bool {
+			return len(policy.Subjects) == 1 && policy.Subjects[0] == domain
+		})
+	}
+
+	return c.updateConfig(config)
+}
+
+// CertificateStatus checks which certificate Caddy serves for domain by making a TLS handshake
+// to Caddy's HTTPS port with the domain as SNI
+func (c *Client) CertificateStatus(domain string) CertificateStatus {
+	status := CertificateStatus{Domain: domain, Status: CertificateUnknown}
+
+	host := "localhost"
+	if u, err := url.Parse(c.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	dialer := &net.Dialer{Timeout: certificateCheckTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, "443"), &tls.Config{
+		ServerName:         domain,
+		InsecureSkipVerify: true, // We only inspect the certificate; it isn't used to send anything
+	})
+	if err != nil {
+		// A failed dial means nothing listens on 443; a failed handshake means no certificate yet
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			status.Message = fmt.Sprintf("Caddy is not serving HTTPS yet: %v", err)
+			return status
+		}
+		status.Status = CertificatePending
+		status.Message = fmt.Sprintf("No certificate available yet: %v", err)
+		return status
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		status.Status = CertificatePending
+		status.Message = "No certificate presented"
+		return status
+	}
+
+	leaf := certs[0]
+	status.Issuer = leaf.Issuer.CommonName
+	status.NotAfter = leaf.NotAfter.Format(time.RFC3339)
+	status.DaysToExpiry = int(time.Until(leaf.NotAfter).Hours() / 24)
+
+	if err := leaf.VerifyHostname(domain); err != nil || time.Now().After(leaf.NotAfter) || isSelfSigned(leaf) {
+		status.Status = CertificatePending
+		status.Message = "Caddy is not serving a valid certificate for this domain yet"
+		return status
+	}
+
+	status.Status = CertificateIssued
+	return status
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.Issuer.String() == cert.Subject.String()
+}
+
+// CertificateReport checks the certificate served for every domain managed through the manager
+func (c *Client) CertificateReport() ([]CertificateStatus, error) {
+	domains, err := c.CertificateDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]CertificateStatus, 0, len(domains))
+	for _, domain := range domains {
+		status := c.CertificateStatus(domain)
+		if status.RenewalOverdue() {
+			status.Message = fmt.Sprintf("Expires in %d days; renewal appears to be failing, check Caddy's logs", status.DaysToExpiry)
+		}
+		report = append(report, status)
+	}
+
+	return report, nil
+}
+
+// CertificateDomains returns the sorted domains Caddy should hold certificates for: proxy domains
+// with TLS, their canonical partners and pre-provisioned domains
+func (c *Client) CertificateDomains() ([]string, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	var domains []string
+	addDomain := func(domain string) {
+		if host, _, err := net.SplitHostPort(domain); err == nil {
+			domain = host
+		}
+		if domain != "" && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	for _, proxy := range c.ParseProxiesFromConfig(config) {
+		if proxy.SSLMode == SSLModeNone {
+			continue
+		}
+		addDomain(proxy.Domain)
+		if proxy.CanonicalRedirect {
+			addDomain(CanonicalPartnerDomain(proxy.Domain))
+		}
+	}
+	for _, domain := range c.PreprovisionedDomains(config) {
+		addDomain(domain)
+	}
+	slices.Sort(domains)
+
+	return domains, nil
+}
+
+// SummarizeCertificateReport formats a report as a short human readable summary
+func SummarizeCertificateReport(report []CertificateStatus) string {
+	var b strings.Builder
+	problems := 0
+	for _, status := range report {
+		if status.Status != CertificateIssued || status.DaysToExpiry < renewalOverdueDays {
+			problems++
+		}
+	}
+	fmt.Fprintf(&b, "Certificate report: %d certificates, %d need attention", len(report), problems)
+
+	for _, status := range report {
+		switch {
+		case status.Status != CertificateIssued:
+			fmt.Fprintf(&b, "\n- %s: %s (%s)", status.Domain, status.Status, status.Message)
+		case status.Message != "":
+			fmt.Fprintf(&b, "\n- %s: %s", status.Domain, status.Message)
+		default:
+			fmt.Fprintf(&b, "\n- %s: expires in %d days (%s)", status.Domain, status.DaysToExpiry, status.Issuer)
+		}
+	}
+
+	return b.String()
+}
@@ -3,26 +3,41 @@ package caddy
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
 	"github.com/sarat/caddyproxymanager/pkg/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+	"github.com/sarat/caddyproxymanager/pkg/secrets"
+	"github.com/sarat/caddyproxymanager/pkg/store"
 )
 
 // Constants for repeated strings
 const (
-	SSLModeAuto = "auto"
-	SSLModeNone = "none"
+	SSLModeAuto     = "auto"
+	SSLModeNone     = "none"
+	SSLModeInternal = "internal" // certificates issued by Caddy's local CA
+	SSLModeCustom   = "custom"   // uploaded certificates loaded from disk
+	SSLModeExisting = "existing" // HTTPS with a certificate Caddy already holds, e.g. a wildcard; nothing is requested via ACME
+
+	// BandwidthHandler is the Caddy handler module used for per-proxy throughput limits.
+	// It is not part of standard Caddy, so Caddy must be built with a module providing it.
+	BandwidthHandler = "bandwidth"
+
+	// Failover timings used when a proxy has a backup target
+	failoverTryDuration  = "5s"
+	failoverFailDuration = "30s"
 )
 
 // Client handles communication with Caddy Admin API
@@ -32,6 +47,15 @@ type Client struct {
 	ConfigFile   string
 	MetadataFile string
 	metadata     *models.MetadataStore
+	store        store.Store    // keeps the metadata instead of MetadataFile when set
+	secrets      *secrets.Box   // encrypts sensitive metadata at rest, nil stores it as plain text
+	pages        *pages.Store   // templates for responses Caddy serves itself, nil leaves Caddy's defaults
+	idStrategy   string         // how new proxies and redirects are named
+	force        bool           // overwrite routes changed by other tools instead of refusing
+	writes       *writeGate     // config and metadata writes in flight, closed by Drain
+	configMu     *sync.Mutex    // serializes read-modify-write cycles on Caddy's config, shared by Forced copies
+	probe        *upstreamProbe // nil disables upstream probes
+	trafficLog   string         // file Caddy writes access logs to for traffic statistics, empty when off
 }
 
 // New creates a new Caddy API client
@@ -44,6 +68,9 @@ func New(baseURL, configFile string) *Client {
 		ConfigFile:   configFile,
 		MetadataFile: metadataFile,
 		metadata:     models.NewMetadataStore(),
+		idStrategy:   IDStrategySlug,
+		writes:       &writeGate{},
+		configMu:     &sync.Mutex{},
 		Client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -57,6 +84,61 @@ func New(baseURL, configFile string) *Client {
 	return client
 }
 
+// SetStore keeps the metadata in st instead of MetadataFile and loads it from there
+func (c *Client) SetStore(st store.Store) error {
+	c.store = st
+	return c.ReloadMetadata()
+}
+
+// SetSecrets sets the box used to encrypt sensitive metadata such as health check headers
+func (c *Client) SetSecrets(box *secrets.Box) {
+	c.secrets = box
+}
+
+// sealHeaders returns a copy of headers with each value encrypted. Values that already are, such as
+// those of a proxy read back from the metadata, are kept as they are.
+func (c *Client) sealHeaders(headers map[string]string) map[string]string {
+	if c.secrets == nil || len(headers) == 0 {
+		return headers
+	}
+
+	sealed := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if secrets.IsEncrypted(value) {
+			sealed[name] = value
+			continue
+		}
+		encrypted, err := c.secrets.Encrypt(value)
+		if err != nil {
+			log.Printf("Warning: Failed to encrypt header %s, storing it unencrypted: %v", name, err)
+			encrypted = value
+		}
+		sealed[name] = encrypted
+	}
+	return sealed
+}
+
+// OpenHealthCheckHeaders returns a copy of a proxy's health check headers with each value
+// decrypted, for the health checks sending them. Proxies read from the config keep the values
+// encrypted, so they don't end up in API responses. Values that can't be decrypted are dropped
+// rather than sent upstream as ciphertext.
+func (c *Client) OpenHealthCheckHeaders(proxyID string, headers map[string]string) map[string]string {
+	if c.secrets == nil || len(headers) == 0 {
+		return headers
+	}
+
+	opened := make(map[string]string, len(headers))
+	for name, value := range headers {
+		decrypted, err := c.secrets.Decrypt(value)
+		if err != nil {
+			log.Printf("Warning: Failed to decrypt health check header %s for proxy %s: %v", name, proxyID, err)
+			continue
+		}
+		opened[name] = decrypted
+	}
+	return opened
+}
+
 // validateIPOrCIDR validates if a string is a valid IP address or CIDR range
 func validateIPOrCIDR(ipOrCIDR string) error {
 	// Try parsing as IP address first
@@ -92,68 +174,51 @@ func getCredential(proxy models.Proxy, key, envVar string) string {
 	return os.Getenv(envVar)
 }
 
-// dnsConfigurators maps DNS provider names to their configuration functions
-var dnsConfigurators = map[string]func(*models.CaddyDNSProvider, models.Proxy){
-	"cloudflare": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.APIToken = getCredential(p, "api_token", "CLOUDFLARE_API_TOKEN")
-		dp.Email = getCredential(p, "email", "CLOUDFLARE_EMAIL")
-	},
-	"digitalocean": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.AuthToken = getCredential(p, "auth_token", "DO_AUTH_TOKEN")
-	},
-	"duckdns": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.Token = getCredential(p, "token", "DUCKDNS_TOKEN")
-	},
-	"hetzner": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.APIToken = getCredential(p, "api_token", "HETZNER_API_TOKEN")
-	},
-	"gandi": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.BearerToken = getCredential(p, "bearer_token", "GANDI_BEARER_TOKEN")
-	},
-	"dnsimple": func(dp *models.CaddyDNSProvider, p models.Proxy) {
-		dp.APIAccessToken = getCredential(p, "api_access_token", "DNSIMPLE_API_ACCESS_TOKEN")
-	},
-}
-
-// configureDNSProviderCredentials configures DNS provider credentials with environment fallback
-func configureDNSProviderCredentials(dnsProvider *models.CaddyDNSProvider, proxy models.Proxy) {
-	if configurator, ok := dnsConfigurators[proxy.DNSProvider]; ok {
-		configurator(dnsProvider, proxy)
-	}
-}
+// ErrConfigConflict is returned by writes refused because Caddy's config changed after it was read,
+// e.g. by another request saving at the same time
+var ErrConfigConflict = errors.New("Caddy's config was changed by another request in the meantime, reload and try again")
 
-// GetConfig retrieves the current Caddy configuration
+// GetConfig retrieves the current Caddy configuration, along with its ETag so writing it back fails
+// with ErrConfigConflict when the config changed in between
 func (c *Client) GetConfig() (*models.CaddyConfig, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/config/")
+	raw, etag, err := c.fetchConfig()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("caddy API returned status %d", resp.StatusCode)
-	}
 
 	var config models.CaddyConfig
-	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+	if err := json.Unmarshal(raw, &config); err != nil {
 		return nil, err
 	}
+	config.ETag = etag
 
 	return &config, nil
 }
 
-// AddRedirect adds a new redirect configuration to Caddy
-func (c *Client) AddRedirect(redirect models.Redirect) error {
-	// Validate redirect
-	if err := redirect.Validate(); err != nil {
-		return fmt.Errorf("invalid redirect: %v", err)
+// fetchConfig fetches Caddy's running config as JSON with its ETag, which is empty for Caddy
+// versions before 2.7
+func (c *Client) fetchConfig() ([]byte, string, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/config/")
+	if err != nil {
+		return nil, "", err
 	}
+	defer resp.Body.Close()
 
-	// Build the redirect route
-	newRoute, err := c.buildRedirectRoute(redirect)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("caddy API returned status %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to build redirect route: %v", err)
+		return nil, "", err
 	}
+	return raw, resp.Header.Get("Etag"), nil
+}
+
+// AddRedirect adds a new redirect configuration to Caddy
+func (c *Client) AddRedirect(redirect models.Redirect) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
 
 	// Get current config
 	config, err := c.GetConfig()
@@ -168,6 +233,35 @@ func (c *Client) AddRedirect(redirect models.Redirect) error {
 		}
 	}
 
+	if err := c.addRedirectToConfig(config, redirect); err != nil {
+		return err
+	}
+
+	// Update Caddy configuration
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.SetRedirect(redirect)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// addRedirectToConfig adds a redirect's route to config without applying it
+func (c *Client) addRedirectToConfig(config *models.CaddyConfig, redirect models.Redirect) error {
+	// Validate redirect
+	if err := redirect.Validate(); err != nil {
+		return fmt.Errorf("invalid redirect: %v", err)
+	}
+
+	// Build the redirect route
+	newRoute, err := c.buildRedirectRoute(redirect)
+	if err != nil {
+		return fmt.Errorf("failed to build redirect route: %v", err)
+	}
+
 	// Redirects always use the https_enabled server to handle both HTTP and HTTPS
 	serverName := "https_enabled"
 	listenPorts := []string{":80", ":443"}
@@ -194,8 +288,7 @@ func (c *Client) AddRedirect(redirect models.Redirect) error {
 		config.Apps.HTTP.Servers[serverName] = newServer
 	}
 
-	// Update Caddy configuration
-	return c.updateConfig(config)
+	return nil
 }
 
 // buildRedirectRoute creates a Caddy route for a redirect
@@ -248,21 +341,61 @@ func (c *Client) buildRedirectRoute(redirect models.Redirect) (*models.CaddyRout
 
 // UpdateRedirect updates an existing redirect configuration in Caddy
 func (c *Client) UpdateRedirect(redirect models.Redirect) error {
-	// For now, delete and re-add (more sophisticated update logic can be added later)
-	if err := c.DeleteRedirect(redirect.ID); err != nil {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil || config.Apps.HTTP.Servers == nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	// Replace the route in a single config update, so the redirect is never missing
+	if !removeRedirectFromConfig(config, redirect.ID) {
+		return fmt.Errorf("redirect with ID %s not found", redirect.ID)
+	}
+	if err := c.addRedirectToConfig(config, redirect); err != nil {
 		return err
 	}
-	return c.AddRedirect(redirect)
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.SetRedirect(redirect)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
 }
 
 // DeleteRedirect removes a redirect configuration from Caddy
 func (c *Client) DeleteRedirect(id string) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
 	// Get current config to find which server contains the route
 	config, err := c.GetConfig()
 	if err != nil || config.Apps.HTTP.Servers == nil {
 		return fmt.Errorf("failed to get current config: %v", err)
 	}
 
+	if !removeRedirectFromConfig(config, id) {
+		return fmt.Errorf("redirect with ID %s not found", id)
+	}
+
+	// Update entire configuration
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.DeleteRedirect(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// removeRedirectFromConfig removes a redirect's route from config, reporting whether it was found
+func removeRedirectFromConfig(config *models.CaddyConfig, id string) bool {
 	// Find and remove the route from all servers
 	for serverName, server := range config.Apps.HTTP.Servers {
 		var filteredRoutes []models.CaddyRoute
@@ -285,13 +418,11 @@ func (c *Client) DeleteRedirect(id string) error {
 			if len(filteredRoutes) == 0 {
 				delete(config.Apps.HTTP.Servers, serverName)
 			}
-
-			// Update entire configuration
-			return c.updateConfig(config)
+			return true
 		}
 	}
 
-	return fmt.Errorf("redirect with ID %s not found", id)
+	return false
 }
 
 // ParseRedirectsFromConfig extracts redirect configurations from Caddy config
@@ -360,6 +491,7 @@ func (c *Client) ParseRedirectsFromConfig(config *models.CaddyConfig) []models.R
 					redirect.SourceDomains = append(redirect.SourceDomains, match.Host...)
 				}
 			}
+			c.metadata.ApplyToRedirect(&redirect)
 
 			redirects = append(redirects, redirect)
 		}
@@ -370,6 +502,56 @@ func (c *Client) ParseRedirectsFromConfig(config *models.CaddyConfig) []models.R
 
 // AddProxy adds a new proxy configuration to Caddy
 func (c *Client) AddProxy(proxy models.Proxy) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	// Get current config
+	config, err := c.GetConfig()
+	if err != nil || config.Apps.HTTP.Servers == nil {
+		// If no config exists or servers is null, create a new one
+		config = &models.CaddyConfig{
+			Apps: models.CaddyApps{
+				HTTP: models.CaddyHTTP{
+					Servers: map[string]models.CaddyServer{},
+				},
+			},
+		}
+	}
+
+	if err := c.addProxyToConfig(config, proxy); err != nil {
+		return err
+	}
+
+	// Update Caddy configuration
+	if err := c.updateConfig(config); err != nil {
+		return proxyApplyError(proxy, err)
+	}
+
+	// Save metadata once Caddy took the proxy, so a rejected one leaves nothing behind, with health
+	// check header values encrypted
+	stored := proxy
+	stored.HealthCheckHeaders = c.sealHeaders(proxy.HealthCheckHeaders)
+	c.metadata.Set(stored)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+
+	return nil
+}
+
+// proxyApplyError explains Caddy refusing a proxy for a missing module the proxy's settings need
+func proxyApplyError(proxy models.Proxy, err error) error {
+	if proxy.BandwidthLimit > 0 && strings.Contains(err.Error(), "unknown module") {
+		return fmt.Errorf("%v (bandwidth limits require Caddy to be built with the %q handler module)", err, BandwidthHandler)
+	}
+	if wafEnabled(proxy) && strings.Contains(err.Error(), "unknown module") {
+		return fmt.Errorf("%v (the WAF requires Caddy to be built with the Coraza module, github.com/corazawaf/coraza-caddy)", err)
+	}
+	return err
+}
+
+// addProxyToConfig adds a proxy's route, server and TLS settings to config without applying it
+func (c *Client) addProxyToConfig(config *models.CaddyConfig, proxy models.Proxy) error {
 	// Validate IP lists
 	if err := validateIPList(proxy.AllowedIPs); err != nil {
 		return fmt.Errorf("invalid allowed IPs: %v", err)
@@ -378,44 +560,53 @@ func (c *Client) AddProxy(proxy models.Proxy) error {
 		return fmt.Errorf("invalid blocked IPs: %v", err)
 	}
 
+	// Imported IP lists only take effect in the route; the proxy's own lists are stored as entered
+	allowed, blocked, err := c.proxyIPRanges(proxy)
+	if err != nil {
+		return err
+	}
+	proxy.AllowedIPs, proxy.BlockedIPs = allowed, blocked
+
 	// Build the route from the proxy model
 	newRoute, err := c.buildProxyRoute(proxy)
 	if err != nil {
 		return fmt.Errorf("failed to build proxy route: %v", err)
 	}
-
-	// Get current config
-	config, err := c.GetConfig()
-	if err != nil || config.Apps.HTTP.Servers == nil {
-		// If no config exists or servers is null, create a new one
-		config = &models.CaddyConfig{
-			Apps: models.CaddyApps{
-				HTTP: models.CaddyHTTP{
-					Servers: map[string]models.CaddyServer{},
-				},
-			},
-		}
+	if proxy.AccessLog == AccessLogErrors {
+		logOnlyErrors(newRoute, proxy.ID)
+	}
+	newRoutes := []models.CaddyRoute{*newRoute}
+	if proxy.CanonicalRedirect {
+		newRoutes = append(newRoutes, *buildCanonicalRoute(proxy))
 	}
 
 	// Determine server name and listen ports based on SSL mode
 	var serverName string
 	var listenPorts []string
 
-	if proxy.SSLMode == SSLModeNone {
+	switch {
+	case proxy.Isolated:
+		// The proxy gets a server of its own, so server-level options only affect its host
+		serverName = IsolatedServerName(proxy.ID)
+		listenPorts = slices.Clone(proxy.IsolatedListen)
+		if err := checkListenerConflicts(config, serverName, listenPorts); err != nil {
+			return err
+		}
+	case proxy.SSLMode == SSLModeNone:
 		serverName = "http_only"
 		listenPorts = []string{":80"}
-	} else {
+	default:
 		serverName = "https_enabled"
 		listenPorts = []string{":80", ":443"}
 	}
 	// Add specific port if domain includes port number
-	if _, port, err := net.SplitHostPort(proxy.Domain); err == nil {
+	if _, port, err := net.SplitHostPort(proxy.Domain); err == nil && !proxy.Isolated {
 		listenPorts = append(listenPorts, ":"+port)
 	}
 
 	// Add route to appropriate server
 	if server, exists := config.Apps.HTTP.Servers[serverName]; exists {
-		server.Routes = append(server.Routes, *newRoute)
+		server.Routes = append(server.Routes, newRoutes...)
 
 		// Add any new ports to the listen array
 		for _, port := range listenPorts {
@@ -431,7 +622,7 @@ func (c *Client) AddProxy(proxy models.Proxy) error {
 		// Create new server
 		newServer := models.CaddyServer{
 			Listen: listenPorts,
-			Routes: []models.CaddyRoute{*newRoute},
+			Routes: newRoutes,
 		}
 
 		// Disable automatic HTTPS for HTTP-only servers
@@ -446,6 +637,12 @@ func (c *Client) AddProxy(proxy models.Proxy) error {
 		config.Apps.HTTP.Servers[serverName] = newServer
 	}
 
+	if proxy.Isolated {
+		server := config.Apps.HTTP.Servers[serverName]
+		server.Timeouts = isolatedServerTimeouts(proxy)
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+
 	// Configure global TLS settings for DNS challenges
 	if proxy.SSLMode == "auto" && proxy.ChallengeType == "dns" {
 		if config.Apps.TLS == nil {
@@ -454,48 +651,115 @@ func (c *Client) AddProxy(proxy models.Proxy) error {
 		c.configureDNSChallenge(config, proxy)
 	}
 
-	// Save metadata
-	c.metadata.Set(proxy)
-	if err := c.saveMetadataToFile(); err != nil {
-		log.Printf("Warning: Failed to save metadata: %v", err)
+	// Issue certificates for internal-only domains from Caddy's local CA
+	if proxy.SSLMode == SSLModeInternal {
+		c.configureInternalIssuer(config, proxy.Domain)
 	}
 
-	// Update Caddy configuration
-	return c.updateConfig(config)
+	// Serve the uploaded certificate instead of one from ACME
+	if proxy.SSLMode == SSLModeCustom {
+		c.configureCustomCertificate(config, serverName, proxy)
+	}
+
+	// Serve whichever certificate Caddy already has for the host without requesting one
+	if proxy.SSLMode == SSLModeExisting {
+		skipCertificateAutomation(config, serverName, proxy.Domain)
+	}
+
+	// The partner domain needs a certificate from the same issuer as the proxy's domain
+	if proxy.CanonicalRedirect {
+		partner := proxy
+		partner.Domain = CanonicalPartnerDomain(proxy.Domain)
+		switch {
+		case proxy.SSLMode == SSLModeAuto && proxy.ChallengeType == "dns":
+			c.configureDNSChallenge(config, partner)
+		case proxy.SSLMode == SSLModeInternal:
+			c.configureInternalIssuer(config, partner.Domain)
+		case proxy.SSLMode == SSLModeExisting:
+			skipCertificateAutomation(config, serverName, partner.Domain)
+		}
+	}
+
+	if err := c.applyAccessLog(config, proxy); err != nil {
+		return err
+	}
+
+	// Keep a running debug capture attached when the proxy is re-added on update
+	if capture, exists := c.metadata.GetDebugCapture(proxy.ID); exists {
+		applyDebugCapture(config, capture, proxy.Domain)
+	}
+
+	return nil
 }
 
 // buildProxyRoute creates a Caddy route from a proxy model
 func (c *Client) buildProxyRoute(proxy models.Proxy) (*models.CaddyRoute, error) {
 	var handlers []models.CaddyHandler
 
-	// Add basic auth handler if enabled
-	if proxy.BasicAuth != nil && proxy.BasicAuth.Enabled && proxy.BasicAuth.Username != "" && proxy.BasicAuth.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(proxy.BasicAuth.Password), bcrypt.DefaultCost)
+	// Inspect requests with the WAF before anything else sees them
+	if wafEnabled(proxy) {
+		wafHandler, err := c.buildWAFHandler(proxy)
 		if err != nil {
-			return nil, fmt.Errorf("failed to hash password: %v", err)
+			return nil, err
 		}
+		handlers = append(handlers, *wafHandler)
+	}
+
+	// Add basic auth handler if the proxy or its access lists have users
+	accounts, err := c.proxyBasicAuthAccounts(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) > 0 {
 		basicAuthHandler := models.CaddyHandler{
 			Handler: "authentication",
 			Providers: map[string]models.CaddyAuthProvider{
 				"http_basic": {
-					Accounts: []models.CaddyAccount{
-						{
-							Username: proxy.BasicAuth.Username,
-							Password: string(hashedPassword),
-						},
-					},
+					Accounts: accounts,
 				},
 			},
 		}
 		handlers = append(handlers, basicAuthHandler)
 	}
 
-	// Build and add the reverse proxy handler
-	reverseProxyHandler, err := c.buildReverseProxyHandler(proxy)
+	// Add bandwidth limiting handler if a limit is set
+	if proxy.BandwidthLimit > 0 {
+		handlers = append(handlers, models.CaddyHandler{
+			Handler: BandwidthHandler,
+			Limit:   proxy.BandwidthLimit,
+		})
+	}
+
+	// Refuse oversized request bodies before they reach the upstream
+	if bodyHandler := requestBodyHandler(proxy); bodyHandler != nil {
+		handlers = append(handlers, *bodyHandler)
+	}
+
+	// Build and add the upstream handlers, with templates in targets and headers expanded
+	upstream, err := ResolveTemplates(proxy)
 	if err != nil {
 		return nil, err
 	}
-	handlers = append(handlers, *reverseProxyHandler)
+	if len(upstream.PathRules) > 0 {
+		pathRuleHandler, err := c.buildPathRuleHandler(upstream)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, *pathRuleHandler)
+	}
+	if proxy.UpstreamType == UpstreamTypeFastCGI {
+		fastCGIHandlers, err := c.buildFastCGIHandlers(upstream)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, fastCGIHandlers...)
+	} else {
+		reverseProxyHandler, err := c.buildReverseProxyHandler(upstream)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, *reverseProxyHandler)
+	}
 
 	// Build matchers for the route
 	matchers := c.buildRouteMatchers(proxy)
@@ -550,7 +814,8 @@ func (c *Client) buildProxyRoute(proxy models.Proxy) (*models.CaddyRoute, error)
 
 // buildReverseProxyHandler creates a Caddy reverse_proxy handler from a proxy model
 func (c *Client) buildReverseProxyHandler(proxy models.Proxy) (*models.CaddyHandler, error) {
-	dialAddr, useHTTPS, targetHost, err := parseTargetURL(proxy.TargetURL)
+	targets := ProxyTargets(proxy)
+	dialAddr, useHTTPS, targetHost, err := parseTargetURL(targets[0])
 	if err != nil {
 		return nil, fmt.Errorf("invalid target URL: %v", err)
 	}
@@ -570,21 +835,99 @@ func (c *Client) buildReverseProxyHandler(proxy models.Proxy) (*models.CaddyHand
 		},
 	}
 
+	// Spread requests over all targets. They share one transport, so they must agree on the scheme,
+	// and each gets its own Host header from the upstream it's sent to.
+	if len(targets) > 1 {
+		for _, target := range targets[1:] {
+			upstreamDialAddr, upstreamHTTPS, _, err := parseTargetURL(target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid target URL %s: %v", target, err)
+			}
+			if upstreamHTTPS != useHTTPS {
+				return nil, fmt.Errorf("all target URLs must use the same scheme")
+			}
+			handler.Upstreams = append(handler.Upstreams, models.CaddyUpstream{Dial: upstreamDialAddr})
+		}
+
+		policy := proxy.LBPolicy
+		if policy == "" {
+			policy = DefaultLBPolicy
+		}
+		handler.Headers.Request.Set["Host"] = []string{"{http.reverse_proxy.upstream.host}"}
+		handler.LoadBalancing = &models.CaddyLoadBalancing{
+			SelectionPolicy: &models.CaddySelectionPolicy{Policy: policy},
+			TryDuration:     failoverTryDuration,
+		}
+		handler.HealthChecks = &models.CaddyHealthChecks{
+			Passive: &models.CaddyPassiveHealthChecks{
+				FailDuration:    failoverFailDuration,
+				MaxFails:        1,
+				UnhealthyStatus: defaultUnhealthyStatus,
+			},
+		}
+	}
+
+	// Caddy passes the client's Host header on unless it's overridden
+	if proxy.PreserveHost {
+		delete(handler.Headers.Request.Set, "Host")
+	}
+
 	// Add custom headers
 	if len(proxy.CustomHeaders) > 0 {
 		for key, value := range proxy.CustomHeaders {
 			handler.Headers.Request.Set[key] = []string{value}
 		}
 	}
+	if len(handler.Headers.Request.Set) == 0 {
+		handler.Headers = nil
+	}
 
 	// Configure HTTPS transport if the target is HTTPS
 	if useHTTPS {
 		handler.Transport = &models.CaddyTransport{
 			Protocol: "http",
-			TLS:      &struct{}{},
+			TLS:      &models.CaddyTransportTLS{ServerName: proxy.UpstreamSNI},
+		}
+	}
+
+	// Fail over to the backup target while the primary is down. The "first" policy always prefers
+	// the primary, and passive health checks take it out of rotation after a failed request.
+	if proxy.BackupTargetURL != "" {
+		backupDialAddr, _, _, err := parseTargetURL(proxy.BackupTargetURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup target URL: %v", err)
+		}
+		handler.Upstreams = append(handler.Upstreams, models.CaddyUpstream{Dial: backupDialAddr})
+		handler.LoadBalancing = &models.CaddyLoadBalancing{
+			SelectionPolicy: &models.CaddySelectionPolicy{Policy: "first"},
+			TryDuration:     failoverTryDuration,
+		}
+		handler.HealthChecks = &models.CaddyHealthChecks{
+			Passive: &models.CaddyPassiveHealthChecks{
+				FailDuration:    failoverFailDuration,
+				MaxFails:        1,
+				UnhealthyStatus: defaultUnhealthyStatus,
+			},
+		}
+	}
+
+	// gRPC needs HTTP/2 to the upstream (h2c when plaintext) and unbuffered streaming responses
+	if proxy.GRPC {
+		if handler.Transport == nil {
+			handler.Transport = &models.CaddyTransport{Protocol: "http"}
+		}
+		if useHTTPS {
+			handler.Transport.Versions = []string{"2"}
+		} else {
+			handler.Transport.Versions = []string{"h2c", "2"}
 		}
+		handler.FlushInterval = "-1s"
 	}
 
+	applyTimeouts(&handler, proxy)
+	applyWebSocket(&handler, proxy)
+	applyUpstreamHealth(&handler, proxy)
+
 	return &handler, nil
 }
 
@@ -627,6 +970,22 @@ func (c *Client) buildRouteMatchers(proxy models.Proxy) []models.CaddyMatch {
 		}
 	}
 
+	// Matcher sets are ORed, so an extra set without remote_ip lets exception paths through
+	// from any address while the rest of the host stays restricted
+	if len(routeMatches) > 0 {
+		var exceptionPaths []string
+		for _, path := range proxy.IPExceptionPaths {
+			if path = strings.TrimSpace(path); path != "" {
+				exceptionPaths = append(exceptionPaths, path)
+			}
+		}
+		if len(exceptionPaths) > 0 {
+			exceptionMatch := baseMatch
+			exceptionMatch.Path = exceptionPaths
+			routeMatches = append(routeMatches, exceptionMatch)
+		}
+	}
+
 	// If no IP filtering was applied but we have a host, use the base match
 	if len(routeMatches) == 0 && len(baseMatch.Host) > 0 {
 		routeMatches = append(routeMatches, baseMatch)
@@ -635,38 +994,151 @@ func (c *Client) buildRouteMatchers(proxy models.Proxy) []models.CaddyMatch {
 	return routeMatches
 }
 
+// GetProxy retrieves a single proxy by ID from the current Caddy configuration
+func (c *Client) GetProxy(id string) (*models.Proxy, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proxy := range c.ParseProxiesFromConfig(config) {
+		if proxy.ID == id {
+			return &proxy, nil
+		}
+	}
+
+	return nil, fmt.Errorf("proxy with ID %s not found", id)
+}
+
+// ProxyTags returns the tags of a proxy, read from its metadata without fetching the Caddy config
+func (c *Client) ProxyTags(id string) []string {
+	metadata, _ := c.metadata.Get(id)
+	return metadata.Tags
+}
+
+// SetDeployToken stores the hashed deploy hook token for a proxy
+func (c *Client) SetDeployToken(proxyID, tokenHash string) error {
+	c.metadata.SetDeployToken(proxyID, tokenHash)
+	return c.saveMetadataToFile()
+}
+
+// GetDeployToken returns the hashed deploy hook token for a proxy, if one exists
+func (c *Client) GetDeployToken(proxyID string) (string, bool) {
+	return c.metadata.GetDeployToken(proxyID)
+}
+
+// DeleteDeployToken removes the deploy hook token for a proxy
+func (c *Client) DeleteDeployToken(proxyID string) error {
+	c.metadata.DeleteDeployToken(proxyID)
+	return c.saveMetadataToFile()
+}
+
+// RemoveDependency removes a deleted proxy from the dependency lists of the remaining proxies
+func (c *Client) RemoveDependency(proxyID string) error {
+	c.metadata.RemoveDependency(proxyID)
+	return c.saveMetadataToFile()
+}
+
 // UpdateProxy updates an existing proxy configuration in Caddy
 func (c *Client) UpdateProxy(proxy models.Proxy) error {
-	// For now, delete and re-add (more sophisticated update logic can be added later)
-	if err := c.DeleteProxy(proxy.ID); err != nil {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	// Decode the running config twice: once as Caddy runs it, once to build the update in
+	raw, etag, err := c.fetchConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	var running, updated models.CaddyConfig
+	if err := json.Unmarshal(raw, &running); err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if err := json.Unmarshal(raw, &updated); err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	running.ETag, updated.ETag = etag, etag
+	if updated.Apps.HTTP.Servers == nil {
+		return fmt.Errorf("route with ID %s not found", proxy.ID)
+	}
+
+	if !removeProxyFromConfig(&updated, proxy.ID) {
+		return fmt.Errorf("route with ID %s not found", proxy.ID)
+	}
+	if err := c.addProxyToConfig(&updated, proxy); err != nil {
 		return err
 	}
-	return c.AddProxy(proxy)
-}
 
-// DeleteProxy removes a proxy configuration from Caddy
-func (c *Client) DeleteProxy(id string) error {
-	// Remove metadata
-	c.metadata.Delete(id)
+	// When only the proxy's route changes, replace it in place rather than reloading every route,
+	// which would briefly drop traffic and overwrite routes changed in the meantime
+	delete(running.Apps.HTTP.Servers, probeServer)
+	c.prepareConfig(&updated)
+	if patch, ok := planRoutePatch(&running, &updated, proxy.ID, proxy.ID+canonicalRouteSuffix); ok {
+		err = c.patchRoute(patch)
+	} else {
+		err = c.updateConfig(&updated)
+	}
+	if err != nil {
+		return proxyApplyError(proxy, err)
+	}
+
+	// Save metadata once Caddy took the update, with health check header values encrypted
+	stored := proxy
+	stored.HealthCheckHeaders = c.sealHeaders(proxy.HealthCheckHeaders)
+	c.metadata.Set(stored)
 	if err := c.saveMetadataToFile(); err != nil {
 		log.Printf("Warning: Failed to save metadata: %v", err)
 	}
+	return nil
+}
+
+// DeleteProxy removes a proxy configuration from Caddy
+func (c *Client) DeleteProxy(id string) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
 	// Get current config to find which server contains the route
 	config, err := c.GetConfig()
 	if err != nil || config.Apps.HTTP.Servers == nil {
 		return fmt.Errorf("failed to get current config: %v", err)
 	}
 
+	if !removeProxyFromConfig(config, id) {
+		return fmt.Errorf("route with ID %s not found", id)
+	}
+	removeAccessLog(config, id)
+
+	// Update entire configuration
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	// Remove metadata once the route is gone, so a refused update keeps the proxy intact
+	c.metadata.Delete(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// removeProxyFromConfig removes a proxy's routes and the TLS settings made for its domains from
+// config, reporting whether the proxy was found
+func removeProxyFromConfig(config *models.CaddyConfig, id string) bool {
 	// Find and remove the route from all servers
 	for serverName, server := range config.Apps.HTTP.Servers {
 		var filteredRoutes []models.CaddyRoute
 		found := false
 
+		var removedHosts []string
+
 		for _, route := range server.Routes {
-			if route.ID != id {
+			// The canonical redirect route is removed along with its proxy
+			if route.ID != id && route.ID != id+canonicalRouteSuffix {
 				filteredRoutes = append(filteredRoutes, route)
 			} else {
 				found = true
+				for _, match := range route.Match {
+					removedHosts = append(removedHosts, match.Host...)
+				}
 			}
 		}
 
@@ -680,12 +1152,18 @@ func (c *Client) DeleteProxy(id string) error {
 				delete(config.Apps.HTTP.Servers, serverName)
 			}
 
-			// Update entire configuration
-			return c.updateConfig(config)
+			// Remove any local CA issuer policy or uploaded certificate set up for the proxy's domain
+			for _, host := range removedHosts {
+				removeInternalIssuerPolicy(config, host)
+				removeSkippedCertificate(config, host)
+			}
+			removeCustomCertificate(config, id)
+
+			return true
 		}
 	}
 
-	return fmt.Errorf("route with ID %s not found", id)
+	return false
 }
 
 // GetStatus retrieves Caddy reverse proxy status
@@ -726,16 +1204,37 @@ func (c *Client) Reload() error {
 
 // updateConfig updates the entire Caddy configuration and saves it to file
 func (c *Client) updateConfig(config *models.CaddyConfig) error {
+	if err := c.writes.begin(); err != nil {
+		return err
+	}
+	defer c.writes.end()
+
+	// Don't silently undo what scripts or other panels changed in the managed routes
+	if err := c.checkForeignChanges(); err != nil {
+		return err
+	}
+
+	c.prepareConfig(config)
+
 	configJSON, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL+"/load", bytes.NewBuffer(configJSON))
+	// A config read from Caddy replaces the one it was read from only; /load ignores If-Match, so
+	// it is posted to /config/ instead
+	endpoint := "/load"
+	if config.ETag != "" {
+		endpoint = "/config/"
+	}
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+endpoint, bytes.NewBuffer(configJSON))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if config.ETag != "" {
+		req.Header.Set("If-Match", config.ETag)
+	}
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -743,6 +1242,9 @@ func (c *Client) updateConfig(config *models.CaddyConfig) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return ErrConfigConflict
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("failed to update config: %s", string(body))
@@ -757,6 +1259,20 @@ func (c *Client) updateConfig(config *models.CaddyConfig) error {
 	return nil
 }
 
+// prepareConfig brings a config about to be loaded in line with the manager's server-wide settings
+func (c *Client) prepareConfig(config *models.CaddyConfig) {
+	// A probe running while the config was read must not become part of it
+	delete(config.Apps.HTTP.Servers, probeServer)
+
+	// Servers created along with a proxy or redirect get the error pages and protocols too
+	c.applyErrorPages(config)
+	c.applyServerProtocols(config)
+	c.applyTrafficLog(config)
+
+	// Catch-all routes go after the routes just added, so they only get unmanaged hostnames
+	c.applyDefaultSite(config)
+}
+
 // saveConfigToFile saves the configuration to a JSON file
 func (c *Client) saveConfigToFile(config *models.CaddyConfig) error {
 	if c.ConfigFile == "" {
@@ -805,6 +1321,9 @@ func (c *Client) RestoreConfigFromFile() error {
 		return nil // Config file doesn't exist, nothing to restore
 	}
 
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
 	config, err := c.LoadConfigFromFile()
 	if err != nil {
 		return fmt.Errorf("failed to load config from file: %v", err)
@@ -851,14 +1370,9 @@ func (c *Client) ParseProxiesFromConfig(config *models.CaddyConfig) []models.Pro
 				continue
 			}
 
-			// Find the reverse_proxy handler (might not be the first one due to authentication)
-			var reverseProxyHandler *models.CaddyHandler
-			for i := range route.Handle {
-				if route.Handle[i].Handler == "reverse_proxy" {
-					reverseProxyHandler = &route.Handle[i]
-					break
-				}
-			}
+			// Find the reverse_proxy handler (might not be the first one due to authentication,
+			// and is nested in a subroute for FastCGI upstreams)
+			reverseProxyHandler := findReverseProxyHandler(route.Handle)
 
 			// Skip if no reverse_proxy handler found
 			if reverseProxyHandler == nil {
@@ -878,7 +1392,7 @@ func (c *Client) ParseProxiesFromConfig(config *models.CaddyConfig) []models.Pro
 			// Extract domain from match or proxy ID
 			if len(route.Match) > 0 && len(route.Match[0].Host) > 0 {
 				proxy.Domain = route.Match[0].Host[0]
-			} else {
+			} else if proxy.Domain == "" {
 				// For port-based proxies, extract domain from ID
 				// ID format: "proxy_localhost:9801_1755490936"
 				if strings.HasPrefix(route.ID, "proxy_") {
@@ -892,23 +1406,42 @@ func (c *Client) ParseProxiesFromConfig(config *models.CaddyConfig) []models.Pro
 					}
 				}
 			}
+			if display := hostname.Display(proxy.Domain); display != proxy.Domain {
+				proxy.DisplayDomain = display
+			}
+			proxy.IPLists = c.metadata.GetIPLists(proxy.ID)
 
-			// Extract target URL from upstreams
-			if len(reverseProxyHandler.Upstreams) > 0 {
+			// Extract target URL from upstreams; a templated target is restored from metadata instead
+			if proxy.TargetURL == "" && len(reverseProxyHandler.Upstreams) > 0 && proxy.UpstreamType == UpstreamTypeFastCGI {
+				proxy.TargetURL = reverseProxyHandler.Upstreams[0].Dial
+			} else if proxy.TargetURL == "" && len(reverseProxyHandler.Upstreams) > 0 {
 				dial := reverseProxyHandler.Upstreams[0].Dial
-				// Determine scheme based on port or default to http
+				// Determine scheme based on transport TLS or port, defaulting to http
 				scheme := "http"
-				if strings.HasSuffix(dial, ":443") {
+				if strings.HasSuffix(dial, ":443") || (reverseProxyHandler.Transport != nil && reverseProxyHandler.Transport.TLS != nil) {
 					scheme = "https"
 				}
 				proxy.TargetURL = fmt.Sprintf("%s://%s", scheme, dial)
 			}
 
-			// Determine SSL mode based on server configuration
+			// Determine SSL mode based on server configuration. Isolated servers listen on ports of
+			// their own choosing and only turn automatic HTTPS off for SSL mode none.
 			hasHTTPS := slices.Contains(server.Listen, ":443")
+			if isIsolatedServer(serverName) {
+				proxy.Isolated = true
+				proxy.IsolatedListen = server.Listen
+				hasHTTPS = server.AutomaticHTTPS == nil || !server.AutomaticHTTPS.Disable
+			}
 
 			if serverName == "http_only" || !hasHTTPS {
 				proxy.SSLMode = "none"
+			} else if hasInternalIssuerPolicy(config, proxy.Domain) {
+				proxy.SSLMode = SSLModeInternal
+			} else if usesCustomCertificate(server, proxy.Domain) {
+				// Both modes turn automation off for the host; only the saved mode tells them apart
+				if proxy.SSLMode != SSLModeExisting {
+					proxy.SSLMode = SSLModeCustom
+				}
 			} else {
 				proxy.SSLMode = "auto"
 			}
@@ -920,42 +1453,58 @@ func (c *Client) ParseProxiesFromConfig(config *models.CaddyConfig) []models.Pro
 	return proxies
 }
 
-// parseTargetURL parses the target URL and returns the dial address with proper port, whether to use HTTPS, and target hostname
-func parseTargetURL(targetURL string) (string, bool, string, error) {
-	originalURL := targetURL
+// UpstreamDialAddresses returns the addresses Caddy dials for a proxy's targets and backup target.
+// FastCGI unix sockets are returned in Caddy's "unix//path" form.
+func UpstreamDialAddresses(proxy models.Proxy) ([]string, error) {
+	proxy, err := ResolveTemplates(proxy)
+	if err != nil {
+		return nil, err
+	}
 
-	// If the URL doesn't contain ://, add http:// prefix for parsing
-	if !strings.Contains(targetURL, "://") {
-		targetURL = "http://" + targetURL
+	if proxy.UpstreamType == UpstreamTypeFastCGI {
+		return []string{fastCGIDialAddress(proxy.TargetURL)}, nil
 	}
 
-	// Parse the URL
-	u, err := url.Parse(targetURL)
-	if err != nil {
-		return "", false, "", fmt.Errorf("failed to parse URL: %v", err)
+	var addresses []string
+	for _, target := range append(slices.Clone(ProxyTargets(proxy)), proxy.BackupTargetURL) {
+		if target == "" {
+			continue
+		}
+		dialAddr, _, _, err := parseTargetURL(target)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, dialAddr)
 	}
 
-	// Determine if original URL specified HTTPS
-	useHTTPS := strings.HasPrefix(originalURL, "https://")
+	return addresses, nil
+}
+
+// parseTargetURL parses the target URL and returns the dial address with proper port, whether to use
+// HTTPS, and the target host as sent in the Host header, with IPv6 addresses in brackets
+func parseTargetURL(targetURL string) (string, bool, string, error) {
+	u, err := parseTarget(targetURL)
+	if err != nil {
+		return "", false, "", err
+	}
 
 	// Get the host and port
 	host := u.Hostname()
 	port := u.Port()
 
-	// If no port is specified, use default based on scheme
+	// If no port is specified, use the scheme's default
 	if port == "" {
-		switch u.Scheme {
-		case "https":
+		port = "80"
+		if u.Scheme == "https" {
 			port = "443"
-		case "http":
-			port = "80"
-		default:
-			// If no scheme, assume http and use port 80
-			port = "80"
 		}
 	}
 
-	return fmt.Sprintf("%s:%s", host, port), useHTTPS, host, nil
+	targetHost := host
+	if strings.Contains(host, ":") {
+		targetHost = "[" + host + "]"
+	}
+	return net.JoinHostPort(host, port), u.Scheme == "https", targetHost, nil
 }
 
 // configureDNSChallenge configures DNS challenge using TLS automation policies
@@ -969,14 +1518,13 @@ func (c *Client) configureDNSChallenge(config *models.CaddyConfig, proxy models.
 		config.Apps.TLS.Automation = &models.CaddyTLSAutomation{}
 	}
 
-	// Create DNS provider configuration
-	dnsProvider := models.CaddyDNSProvider{
-		Name: proxy.DNSProvider,
+	// Create DNS provider configuration with environment variable fallback for credentials
+	dnsProvider, err := buildDNSProvider(proxy)
+	if err != nil {
+		log.Printf("Warning: Skipping DNS challenge for %s: %v\n", proxy.Domain, err)
+		return
 	}
 
-	// Set provider-specific credentials with environment variable fallback
-	configureDNSProviderCredentials(&dnsProvider, proxy)
-
 	// Create ACME issuer with DNS challenge
 	issuer := models.CaddyIssuer{
 		Module: "acme",
@@ -1017,7 +1565,17 @@ func (c *Client) configureDNSChallenge(config *models.CaddyConfig, proxy models.
 
 // saveMetadataToFile saves the metadata to a JSON file
 func (c *Client) saveMetadataToFile() error {
-	if c.MetadataFile == "" {
+	if err := c.writes.begin(); err != nil {
+		return err
+	}
+	defer c.writes.end()
+
+	return c.writeMetadataFile()
+}
+
+// writeMetadataFile writes the metadata to its file, or the store, without going through the write gate
+func (c *Client) writeMetadataFile() error {
+	if c.store == nil && c.MetadataFile == "" {
 		return nil // No metadata file specified
 	}
 
@@ -1026,6 +1584,13 @@ func (c *Client) saveMetadataToFile() error {
 		return fmt.Errorf("failed to marshal metadata: %v", err)
 	}
 
+	if c.store != nil {
+		if err := c.store.Save(c.metadataDocument(), metadataJSON); err != nil {
+			return fmt.Errorf("failed to save metadata: %v", err)
+		}
+		return nil
+	}
+
 	if err := os.WriteFile(c.MetadataFile, metadataJSON, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata file: %v", err)
 	}
@@ -1033,20 +1598,22 @@ func (c *Client) saveMetadataToFile() error {
 	return nil
 }
 
-// loadMetadataFromFile loads the metadata from a JSON file
-func (c *Client) loadMetadataFromFile() error {
-	if c.MetadataFile == "" {
-		return nil // No metadata file specified
-	}
+// metadataDocument names the metadata in the store after the file it would otherwise be kept in
+func (c *Client) metadataDocument() string {
+	return filepath.Base(c.MetadataFile)
+}
 
-	// Check if metadata file exists
-	if _, err := os.Stat(c.MetadataFile); os.IsNotExist(err) {
-		return nil // Metadata file doesn't exist, use empty store
-	}
+// ReloadMetadata replaces the in-memory metadata with the metadata file or store, e.g. after a restore
+func (c *Client) ReloadMetadata() error {
+	c.metadata = models.NewMetadataStore()
+	return c.loadMetadataFromFile()
+}
 
-	data, err := os.ReadFile(c.MetadataFile)
-	if err != nil {
-		return fmt.Errorf("failed to read metadata file: %v", err)
+// loadMetadataFromFile loads the metadata from a JSON file, or the store when one is set
+func (c *Client) loadMetadataFromFile() error {
+	data, err := c.readMetadata()
+	if err != nil || data == nil {
+		return err
 	}
 
 	var metadata models.MetadataStore
@@ -1058,6 +1625,35 @@ func (c *Client) loadMetadataFromFile() error {
 	return nil
 }
 
+// readMetadata returns the saved metadata, or nil if there is none yet
+func (c *Client) readMetadata() ([]byte, error) {
+	if c.store != nil {
+		data, err := c.store.Load(c.metadataDocument())
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil // Never saved, use empty store
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load metadata: %v", err)
+		}
+		return data, nil
+	}
+
+	if c.MetadataFile == "" {
+		return nil, nil // No metadata file specified
+	}
+
+	// Check if metadata file exists
+	if _, err := os.Stat(c.MetadataFile); os.IsNotExist(err) {
+		return nil, nil // Metadata file doesn't exist, use empty store
+	}
+
+	data, err := os.ReadFile(c.MetadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata file: %v", err)
+	}
+	return data, nil
+}
+
 // validateCustomCaddyJSON validates that the provided string is valid JSON
 func validateCustomCaddyJSON(jsonStr string) error {
 	if jsonStr == "" {
@@ -0,0 +1,307 @@
+package caddy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// DriftState reports whether Caddy's running config has changed underneath the manager
+type DriftState struct {
+	Dirty       bool           `json:"dirty"`                  // Caddy's config differs from the manager's saved config
+	DetectedAt  time.Time      `json:"detected_at,omitzero"`   // When the current drift was first seen
+	CaddyHash   string         `json:"caddy_hash,omitempty"`   // Hash of the config Caddy is running
+	ManagedHash string         `json:"managed_hash,omitempty"` // Hash of the config the manager last wrote
+	LastChecked time.Time      `json:"last_checked,omitzero"`
+	Error       string         `json:"error,omitempty"` // Set when Caddy couldn't be polled
+	LastReapply *ReapplyResult `json:"last_reapply,omitempty"`
+}
+
+// ReapplyResult describes an automatic re-apply of the managed config after Caddy came back from a
+// restart without it
+type ReapplyResult struct {
+	Time   time.Time `json:"time"`
+	Routes int       `json:"routes"` // Managed routes pushed back to Caddy
+	Error  string    `json:"error,omitempty"`
+}
+
+// ConfigWatcher polls Caddy's config and flags drift from the config the manager saved, e.g. after
+// someone edits Caddy through its admin API or a Caddyfile reload
+type ConfigWatcher struct {
+	mu        sync.RWMutex
+	client    *Client
+	interval  time.Duration
+	state     DriftState
+	baseline  string // Hash used when the manager hasn't saved a config file yet
+	candidate string // Mismatching hash seen on the previous poll, not yet reported
+	listeners []func(DriftState)
+	reapply   func() bool // Reports whether this watcher may re-apply a lost config; nil disables it
+	reapplied []func(ReapplyResult)
+}
+
+// NewConfigWatcher creates a watcher that checks client's Caddy config every interval
+func NewConfigWatcher(client *Client, interval time.Duration) *ConfigWatcher {
+	return &ConfigWatcher{
+		client:   client,
+		interval: interval,
+	}
+}
+
+// OnDrift registers a callback run when drift is first detected
+func (w *ConfigWatcher) OnDrift(listener func(DriftState)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+// EnableReapply makes the watcher push the managed config back to Caddy when Caddy is running none
+// of the managed routes, as happens when it restarts without its own persisted config. allowed is
+// asked before each attempt, so only one replica re-applies.
+func (w *ConfigWatcher) EnableReapply(allowed func() bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.reapply = allowed
+}
+
+// OnReapply registers a callback run when the managed config is re-applied, or when a re-apply
+// first fails. Failures are retried on every check without calling back again.
+func (w *ConfigWatcher) OnReapply(listener func(ReapplyResult)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.reapplied = append(w.reapplied, listener)
+}
+
+// Run polls Caddy until ctx is cancelled
+func (w *ConfigWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.Check()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check compares Caddy's config with the managed config and returns the updated state
+func (w *ConfigWatcher) Check() DriftState {
+	running, caddyErr := w.client.getRawConfig()
+	managed, managedErr := w.client.readManagedConfig()
+
+	var reapplied *ReapplyResult
+	if caddyErr == nil && managedErr == nil && w.configLost(running, managed) {
+		reapplied = w.reapplyConfig(managed)
+		if reapplied.Error == "" {
+			running, caddyErr = w.client.getRawConfig()
+		}
+	}
+
+	var caddyHash, managedHash string
+	if caddyErr == nil {
+		caddyHash, caddyErr = configHash(running)
+	}
+	if managedErr == nil {
+		managedHash, managedErr = configHash(managed)
+	}
+	now := time.Now()
+
+	w.mu.Lock()
+
+	var notifyReapply []func(ReapplyResult)
+	if reapplied != nil {
+		// A failing re-apply is only reported once, not on every retry
+		if reapplied.Error == "" || w.state.LastReapply == nil || w.state.LastReapply.Error == "" {
+			notifyReapply = w.reapplied
+		}
+		w.state.LastReapply = reapplied
+	}
+
+	w.state.LastChecked = now
+	if caddyErr != nil {
+		w.state.Error = caddyErr.Error()
+		state := w.state
+		w.mu.Unlock()
+		for _, listener := range notifyReapply {
+			listener(*reapplied)
+		}
+		return state
+	}
+	w.state.Error = ""
+
+	if managedErr != nil {
+		// Nothing saved yet: the first config seen is what the manager starts from
+		if w.baseline == "" {
+			w.baseline = caddyHash
+		}
+		managedHash = w.baseline
+	}
+
+	w.state.CaddyHash = caddyHash
+	w.state.ManagedHash = managedHash
+
+	var notify []func(DriftState)
+	switch {
+	case caddyHash == managedHash:
+		w.state.Dirty = false
+		w.state.DetectedAt = time.Time{}
+		w.candidate = ""
+	case w.state.Dirty:
+		// Already reported
+	case w.candidate != caddyHash:
+		// The manager saves its file just after loading a config into Caddy, so only report drift
+		// once the same mismatch shows up on two polls in a row
+		w.candidate = caddyHash
+	default:
+		w.state.Dirty = true
+		w.state.DetectedAt = now
+		notify = w.listeners
+	}
+
+	state := w.state
+	w.mu.Unlock()
+
+	for _, listener := range notifyReapply {
+		listener(*reapplied)
+	}
+	for _, listener := range notify {
+		listener(state)
+	}
+
+	return state
+}
+
+// configLost reports whether Caddy has lost the managed config: it runs none of the routes the
+// manager saved, e.g. after restarting without --resume or with an empty config
+func (w *ConfigWatcher) configLost(running, managed []byte) bool {
+	w.mu.RLock()
+	allowed := w.reapply
+	w.mu.RUnlock()
+
+	if allowed == nil || managedRouteCount(managed) == 0 || managedRouteCount(running) > 0 {
+		return false
+	}
+	return allowed()
+}
+
+// reapplyConfig loads the managed config into Caddy again
+func (w *ConfigWatcher) reapplyConfig(managed []byte) *ReapplyResult {
+	result := &ReapplyResult{
+		Time:   time.Now(),
+		Routes: managedRouteCount(managed),
+	}
+	if err := w.client.RestoreConfigFromFile(); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// managedRouteCount counts the routes in a JSON config that the manager created, which all carry an
+// @id. Configs that can't be parsed count as having none.
+func managedRouteCount(raw []byte) int {
+	var config models.CaddyConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, server := range config.Apps.HTTP.Servers {
+		for _, route := range server.Routes {
+			if route.ID != "" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// State returns the most recent drift state
+func (w *ConfigWatcher) State() DriftState {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.state
+}
+
+// Adopt accepts Caddy's running config as the managed config
+func (w *ConfigWatcher) Adopt() error {
+	if err := w.client.AdoptRunningConfig(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.baseline = ""
+	w.mu.Unlock()
+
+	w.Check()
+	return nil
+}
+
+// Restore pushes the managed config back to Caddy, discarding the changes made outside the manager
+func (w *ConfigWatcher) Restore() error {
+	if err := w.client.RestoreConfigFromFile(); err != nil {
+		return err
+	}
+
+	w.Check()
+	return nil
+}
+
+// getRawConfig fetches Caddy's running config as JSON
+func (c *Client) getRawConfig() ([]byte, error) {
+	raw, _, err := c.fetchConfig()
+	return raw, err
+}
+
+// readManagedConfig reads the config the manager last saved
+func (c *Client) readManagedConfig() ([]byte, error) {
+	if c.ConfigFile == "" {
+		return nil, fmt.Errorf("no config file specified")
+	}
+	return os.ReadFile(c.ConfigFile)
+}
+
+// AdoptRunningConfig saves Caddy's running config, as is, as the managed config
+func (c *Client) AdoptRunningConfig() error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	raw, err := c.getRawConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if c.ConfigFile == "" {
+		return fmt.Errorf("no config file specified")
+	}
+
+	if err := os.WriteFile(c.ConfigFile, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// configHash hashes a JSON config independently of formatting and key order
+func configHash(raw []byte) (string, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("invalid config JSON: %v", err)
+	}
+
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,208 @@
+package caddy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// customCertTagPrefix tags the load_files entries the manager adds, followed by the proxy ID
+const customCertTagPrefix = "cpm-proxy-"
+
+// CustomCertificateInfo describes an uploaded certificate without exposing its key
+type CustomCertificateInfo struct {
+	Subject   string   `json:"subject"`
+	Issuer    string   `json:"issuer"`
+	SANs      []string `json:"sans"`
+	NotBefore string   `json:"not_before"`
+	NotAfter  string   `json:"not_after"`
+}
+
+// CustomCertificateFiles returns where a proxy's uploaded certificate and key are stored
+func (c *Client) CustomCertificateFiles(proxyID string) (string, string, error) {
+	if proxyID == "" || filepath.Base(proxyID) != proxyID {
+		return "", "", fmt.Errorf("invalid proxy ID")
+	}
+
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "certs"))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve certificate directory: %v", err)
+	}
+
+	return filepath.Join(dir, proxyID+".crt"), filepath.Join(dir, proxyID+".key"), nil
+}
+
+// SaveCustomCertificate validates a PEM certificate chain and key for a proxy's domain and stores
+// them. The proxy must be re-added for Caddy to load them.
+func (c *Client) SaveCustomCertificate(proxy models.Proxy, certPEM, keyPEM []byte) (*CustomCertificateInfo, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname(HostOnly(proxy.Domain)); err != nil {
+		return nil, fmt.Errorf("certificate does not cover %s: %v", proxy.Domain, err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	certFile, keyFile, err := c.CustomCertificateFiles(proxy.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create certificate directory: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key: %v", err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write certificate: %v", err)
+	}
+
+	return certificateInfo(leaf), nil
+}
+
+// CustomCertificate returns the certificate uploaded for a proxy
+func (c *Client) CustomCertificate(proxyID string) (*CustomCertificateInfo, error) {
+	certFile, keyFile, err := c.CustomCertificateFiles(proxyID)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return certificateInfo(leaf), nil
+}
+
+// DeleteCustomCertificate removes the certificate and key uploaded for a proxy
+func (c *Client) DeleteCustomCertificate(proxyID string) error {
+	certFile, keyFile, err := c.CustomCertificateFiles(proxyID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// configureCustomCertificate loads a proxy's uploaded certificate into Caddy and stops automatic
+// HTTPS from obtaining one for its domain. Without an upload the domain is served with no
+// certificate rather than one from ACME.
+func (c *Client) configureCustomCertificate(config *models.CaddyConfig, serverName string, proxy models.Proxy) {
+	removeCustomCertificate(config, proxy.ID)
+	skipCertificateAutomation(config, serverName, proxy.Domain)
+
+	certFile, keyFile, err := c.CustomCertificateFiles(proxy.ID)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(certFile); err != nil {
+		return
+	}
+
+	if config.Apps.TLS == nil {
+		config.Apps.TLS = &models.CaddyTLS{}
+	}
+	if config.Apps.TLS.Certificates == nil {
+		config.Apps.TLS.Certificates = &models.CaddyTLSCertificates{}
+	}
+	config.Apps.TLS.Certificates.LoadFiles = append(config.Apps.TLS.Certificates.LoadFiles, models.CaddyCertificateFile{
+		Certificate: certFile,
+		Key:         keyFile,
+		Tags:        []string{customCertTagPrefix + proxy.ID},
+	})
+}
+
+// skipCertificateAutomation stops automatic HTTPS from obtaining a certificate for a domain on a
+// server. Caddy still serves HTTPS for it with any certificate it holds that covers the host.
+func skipCertificateAutomation(config *models.CaddyConfig, serverName, domain string) {
+	server := config.Apps.HTTP.Servers[serverName]
+	if server.AutomaticHTTPS == nil {
+		server.AutomaticHTTPS = &models.CaddyAutomaticHTTPS{}
+	}
+	if host := HostOnly(domain); !slices.Contains(server.AutomaticHTTPS.SkipCertificates, host) {
+		server.AutomaticHTTPS.SkipCertificates = append(server.AutomaticHTTPS.SkipCertificates, host)
+	}
+	config.Apps.HTTP.Servers[serverName] = server
+}
+
+// removeCustomCertificate unloads a proxy's uploaded certificate from the TLS app
+func removeCustomCertificate(config *models.CaddyConfig, proxyID string) {
+	if config.Apps.TLS == nil || config.Apps.TLS.Certificates == nil {
+		return
+	}
+
+	certificates := config.Apps.TLS.Certificates
+	certificates.LoadFiles = slices.DeleteFunc(certificates.LoadFiles, func(file models.CaddyCertificateFile) bool {
+		return slices.Contains(file.Tags, customCertTagPrefix+proxyID)
+	})
+	if len(certificates.LoadFiles) == 0 && len(certificates.Automate) == 0 {
+		config.Apps.TLS.Certificates = nil
+	}
+}
+
+// removeSkippedCertificate lets automatic HTTPS manage a host's certificate again
+func removeSkippedCertificate(config *models.CaddyConfig, host string) {
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.AutomaticHTTPS == nil || !slices.Contains(server.AutomaticHTTPS.SkipCertificates, host) {
+			continue
+		}
+		server.AutomaticHTTPS.SkipCertificates = slices.DeleteFunc(server.AutomaticHTTPS.SkipCertificates, func(name string) bool { return name == host })
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+}
+
+// usesCustomCertificate reports whether automatic HTTPS is turned off for a host on a server,
+// which the manager does for proxies with custom certificates
+func usesCustomCertificate(server models.CaddyServer, host string) bool {
+	return server.AutomaticHTTPS != nil && slices.Contains(server.AutomaticHTTPS.SkipCertificates, HostOnly(host))
+}
+
+// HostOnly strips an optional port from a domain
+func HostOnly(domain string) string {
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		return host
+	}
+	return domain
+}
+
+func certificateInfo(leaf *x509.Certificate) *CustomCertificateInfo {
+	info := &CustomCertificateInfo{
+		Subject:   leaf.Subject.CommonName,
+		Issuer:    leaf.Issuer.CommonName,
+		SANs:      leaf.DNSNames,
+		NotBefore: leaf.NotBefore.Format(time.RFC3339),
+		NotAfter:  leaf.NotAfter.Format(time.RFC3339),
+	}
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	return info
+}
@@ -0,0 +1,175 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+)
+
+// defaultSiteRoutePrefix marks the catch-all routes serving the default site
+const defaultSiteRoutePrefix = "cpm-default-site-"
+
+// defaultSiteServers are the shared servers unmanaged hostnames reach. Isolated proxies have
+// listeners of their own and are left alone.
+var defaultSiteServers = []string{"https_enabled", "http_only"}
+
+// defaultSiteBody is served for unmanaged hostnames until a default-site page template is saved
+const defaultSiteBody = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Site not found</title></head>
+<body>
+<h1>Site not found</h1>
+<p>No site is configured for this address.</p>
+</body>
+</html>
+`
+
+// DefaultSite returns how requests for unmanaged hostnames are answered, disabled when never set
+func (c *Client) DefaultSite() models.DefaultSite {
+	if c.metadata.DefaultSite == nil {
+		return models.DefaultSite{Mode: models.DefaultSitePage, StatusCode: 404}
+	}
+	return *c.metadata.DefaultSite
+}
+
+// NormalizeDefaultSite validates default site settings, filling in the page mode and a 404
+func NormalizeDefaultSite(site *models.DefaultSite) error {
+	switch site.Mode {
+	case "":
+		site.Mode = models.DefaultSitePage
+	case models.DefaultSitePage, models.DefaultSiteAbort:
+	default:
+		return fmt.Errorf("unsupported mode %s (use %s or %s)", site.Mode, models.DefaultSitePage, models.DefaultSiteAbort)
+	}
+
+	if site.StatusCode == 0 {
+		site.StatusCode = 404
+	}
+	// Redirects need a Location, which the default site doesn't have
+	if site.StatusCode < 200 || site.StatusCode > 599 || (site.StatusCode >= 300 && site.StatusCode < 400) {
+		return fmt.Errorf("status_code must be a 2xx, 4xx or 5xx status")
+	}
+	return nil
+}
+
+// SaveDefaultSite stores the default site settings and applies them to the shared servers. Servers
+// created later get the default site when they're added.
+func (c *Client) SaveDefaultSite(site models.DefaultSite) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	previous := c.metadata.DefaultSite
+	c.metadata.DefaultSite = &site
+
+	config, err := c.GetConfig()
+	if err != nil {
+		c.metadata.DefaultSite = previous
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if c.applyDefaultSite(config) {
+		if err := c.updateConfig(config); err != nil {
+			c.metadata.DefaultSite = previous
+			return err
+		}
+	}
+
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// applyDefaultSite moves the default site routes to the end of the shared servers' routes, after
+// every host route, or removes them when the default site is disabled. It reports whether anything
+// changed.
+func (c *Client) applyDefaultSite(config *models.CaddyConfig) bool {
+	var routes []models.CaddyRoute
+	if site := c.metadata.DefaultSite; site != nil && site.Enabled {
+		routes = c.defaultSiteRoutes(*site)
+	}
+
+	changed := false
+	for _, serverName := range defaultSiteServers {
+		server, exists := config.Apps.HTTP.Servers[serverName]
+		if !exists {
+			continue // The default site is added along with the server's first proxy or redirect
+		}
+
+		updated := slices.DeleteFunc(slices.Clone(server.Routes), func(route models.CaddyRoute) bool {
+			return strings.HasPrefix(route.ID, defaultSiteRoutePrefix)
+		})
+		updated = append(updated, routes...)
+
+		if slices.EqualFunc(server.Routes, updated, func(a, b models.CaddyRoute) bool { return routeJSON(a) == routeJSON(b) }) {
+			continue
+		}
+		changed = true
+
+		if len(updated) == 0 {
+			// The server only held the default site
+			delete(config.Apps.HTTP.Servers, serverName)
+			continue
+		}
+		server.Routes = updated
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+	return changed
+}
+
+// defaultSiteRoutes builds the catch-all routes of the default site: one closing the connection,
+// or one per page variant, matching language variants on the client's preferred language
+func (c *Client) defaultSiteRoutes(site models.DefaultSite) []models.CaddyRoute {
+	if site.Mode == models.DefaultSiteAbort {
+		return []models.CaddyRoute{{
+			ID:     defaultSiteRoutePrefix + models.DefaultSiteAbort,
+			Handle: []models.CaddyHandler{{Handler: "static_response", Abort: true}},
+		}}
+	}
+
+	var rendered []pages.Page
+	if c.pages != nil {
+		var errs []error
+		rendered, errs = c.pages.RenderStatus(pages.KindDefaultSite, site.StatusCode)
+		for _, err := range errs {
+			log.Printf("Warning: Skipping default site page: %v", err)
+		}
+	}
+	if !slices.ContainsFunc(rendered, func(page pages.Page) bool { return page.Language == pages.DefaultLanguage }) {
+		rendered = append(rendered, pages.Page{Language: pages.DefaultLanguage, Body: defaultSiteBody})
+	}
+
+	routes := make([]models.CaddyRoute, 0, len(rendered))
+	for _, page := range rendered {
+		route := models.CaddyRoute{
+			ID: defaultSiteRoutePrefix + page.Language,
+			Handle: []models.CaddyHandler{
+				{
+					Handler: "headers",
+					Response: &models.CaddyHeadersResponse{
+						Set: map[string][]string{"Content-Type": {"text/html; charset=utf-8"}},
+					},
+				},
+				{
+					Handler:    "static_response",
+					StatusCode: site.StatusCode,
+					Body:       page.Body,
+				},
+			},
+		}
+
+		if page.Language != pages.DefaultLanguage {
+			route.Match = []models.CaddyMatch{{
+				HeaderRegexp: map[string]models.CaddyRegexp{
+					"Accept-Language": {Pattern: preferredLanguagePattern(page.Language)},
+				},
+			}}
+		}
+
+		routes = append(routes, route)
+	}
+	return routes
+}
@@ -0,0 +1,171 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// DNSProviderCustom accepts a raw Caddy DNS provider object for providers without a registry entry
+	DNSProviderCustom = "custom"
+	// DNSCustomProviderKey is the credential holding the raw provider JSON for the custom provider
+	DNSCustomProviderKey = "provider_json"
+)
+
+// DNSCredentialField is a credential a DNS provider module needs
+type DNSCredentialField struct {
+	Key      string `json:"key"`               // JSON key in the Caddy provider config and in dns_credentials
+	Label    string `json:"label"`             // Shown in the UI and in validation errors
+	Type     string `json:"type"`              // Input type: "password", "email" or "text"
+	Required bool   `json:"required"`          // Must be set in the request or via EnvVar
+	EnvVar   string `json:"env_var,omitempty"` // Environment variable used when the request leaves it empty
+}
+
+// DNSProvider describes a Caddy DNS provider module. Caddy must be built with the module
+// (github.com/caddy-dns/<module>) for certificates to be issued through it.
+type DNSProvider struct {
+	Name   string               `json:"name"`   // Value of dns_provider
+	Label  string               `json:"label"`  // Display name
+	Module string               `json:"module"` // Caddy module name, e.g. "cloudflare" for dns.providers.cloudflare
+	Fields []DNSCredentialField `json:"fields"`
+}
+
+// dnsProviders is the registry of supported DNS providers, keyed by name
+var dnsProviders = map[string]DNSProvider{}
+
+func init() {
+	RegisterDNSProvider(DNSProvider{Name: "cloudflare", Label: "Cloudflare", Module: "cloudflare", Fields: []DNSCredentialField{
+		{Key: "api_token", Label: "API Token", Type: "password", Required: true, EnvVar: "CLOUDFLARE_API_TOKEN"},
+		{Key: "email", Label: "Email (optional)", Type: "email", EnvVar: "CLOUDFLARE_EMAIL"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: "digitalocean", Label: "DigitalOcean", Module: "digitalocean", Fields: []DNSCredentialField{
+		{Key: "auth_token", Label: "Auth Token", Type: "password", Required: true, EnvVar: "DO_AUTH_TOKEN"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: "duckdns", Label: "DuckDNS", Module: "duckdns", Fields: []DNSCredentialField{
+		{Key: "token", Label: "Token", Type: "password", Required: true, EnvVar: "DUCKDNS_TOKEN"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: "hetzner", Label: "Hetzner", Module: "hetzner", Fields: []DNSCredentialField{
+		{Key: "api_token", Label: "API Token", Type: "password", Required: true, EnvVar: "HETZNER_API_TOKEN"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: "gandi", Label: "Gandi", Module: "gandi", Fields: []DNSCredentialField{
+		{Key: "bearer_token", Label: "Bearer Token", Type: "password", Required: true, EnvVar: "GANDI_BEARER_TOKEN"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: "dnsimple", Label: "DNSimple", Module: "dnsimple", Fields: []DNSCredentialField{
+		{Key: "api_access_token", Label: "API Access Token", Type: "password", Required: true, EnvVar: "DNSIMPLE_API_ACCESS_TOKEN"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: DNSProviderACMEDNS, Label: "acme-dns (no DNS API needed)", Module: "acmedns", Fields: []DNSCredentialField{
+		{Key: "username", Label: "Username", Type: "text", Required: true, EnvVar: "ACMEDNS_USERNAME"},
+		{Key: "password", Label: "Password", Type: "password", Required: true, EnvVar: "ACMEDNS_PASSWORD"},
+		{Key: "subdomain", Label: "Subdomain", Type: "text", Required: true, EnvVar: "ACMEDNS_SUBDOMAIN"},
+		{Key: "server_url", Label: "Server URL", Type: "text", Required: true, EnvVar: "ACMEDNS_SERVER_URL"},
+	}})
+	RegisterDNSProvider(DNSProvider{Name: DNSProviderCustom, Label: "Custom (raw provider JSON)", Fields: []DNSCredentialField{
+		{Key: DNSCustomProviderKey, Label: "Provider JSON", Type: "text", Required: true},
+	}})
+}
+
+// RegisterDNSProvider adds a provider to the registry, replacing any provider with the same name
+func RegisterDNSProvider(provider DNSProvider) {
+	dnsProviders[provider.Name] = provider
+}
+
+// LookupDNSProvider returns the registered provider with the given name
+func LookupDNSProvider(name string) (DNSProvider, bool) {
+	provider, ok := dnsProviders[name]
+	return provider, ok
+}
+
+// DNSProviders returns all registered providers sorted by label, with the custom provider last
+func DNSProviders() []DNSProvider {
+	providers := make([]DNSProvider, 0, len(dnsProviders))
+	for _, provider := range dnsProviders {
+		providers = append(providers, provider)
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		if (providers[i].Name == DNSProviderCustom) != (providers[j].Name == DNSProviderCustom) {
+			return providers[j].Name == DNSProviderCustom
+		}
+		return strings.ToLower(providers[i].Label) < strings.ToLower(providers[j].Label)
+	})
+	return providers
+}
+
+// ValidateDNSCredentials checks that credentials, with environment variable fallback, satisfy the
+// provider's fields
+func ValidateDNSCredentials(name string, credentials map[string]string) error {
+	provider, ok := LookupDNSProvider(name)
+	if !ok {
+		return fmt.Errorf("Unsupported DNS provider: %s", name)
+	}
+
+	if provider.Name == DNSProviderCustom {
+		_, err := parseCustomDNSProvider(credentials[DNSCustomProviderKey])
+		return err
+	}
+
+	for _, field := range provider.Fields {
+		value := credentials[field.Key]
+		if value == "" && field.EnvVar != "" {
+			value = os.Getenv(field.EnvVar)
+		}
+
+		if value == "" {
+			if field.Required {
+				return fmt.Errorf("%s %s is required (provide in request or set %s environment variable)", provider.Label, field.Label, field.EnvVar)
+			}
+			continue
+		}
+
+		if field.Type == "email" && !strings.Contains(value, "@") {
+			return fmt.Errorf("Invalid email format")
+		}
+	}
+
+	return nil
+}
+
+// buildDNSProvider creates the Caddy DNS provider config for a proxy, filling in each credential
+// from the proxy or its environment variable
+func buildDNSProvider(proxy models.Proxy) (models.CaddyDNSProvider, error) {
+	provider, ok := LookupDNSProvider(proxy.DNSProvider)
+	if !ok {
+		return nil, fmt.Errorf("unsupported DNS provider: %s", proxy.DNSProvider)
+	}
+
+	if provider.Name == DNSProviderCustom {
+		return parseCustomDNSProvider(proxy.DNSCredentials[DNSCustomProviderKey])
+	}
+
+	dnsProvider := models.CaddyDNSProvider{"name": provider.Module}
+	for _, field := range provider.Fields {
+		if value := getCredential(proxy, field.Key, field.EnvVar); value != "" {
+			dnsProvider[field.Key] = value
+		}
+	}
+
+	return dnsProvider, nil
+}
+
+// parseCustomDNSProvider decodes raw provider JSON, which must name the Caddy module
+func parseCustomDNSProvider(raw string) (models.CaddyDNSProvider, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, fmt.Errorf("Provider JSON is required for the custom DNS provider")
+	}
+
+	var dnsProvider models.CaddyDNSProvider
+	if err := json.Unmarshal([]byte(raw), &dnsProvider); err != nil {
+		return nil, fmt.Errorf("Invalid provider JSON: %v", err)
+	}
+
+	if name, ok := dnsProvider["name"].(string); !ok || name == "" {
+		return nil, fmt.Errorf("Provider JSON must include the module \"name\", e.g. {\"name\": \"route53\", ...}")
+	}
+
+	return dnsProvider, nil
+}
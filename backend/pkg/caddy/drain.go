@@ -0,0 +1,57 @@
+package caddy
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrDraining is returned by writes attempted after the client was drained for shutdown
+var ErrDraining = errors.New("the manager is shutting down, configuration changes are no longer accepted")
+
+// writeGate tracks config and metadata writes in flight, so shutdown can wait for them before
+// refusing new ones. It is shared by the copies Forced makes.
+type writeGate struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+// begin registers a write, failing once the gate is closed
+func (g *writeGate) begin() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return ErrDraining
+	}
+	g.inFlight.Add(1)
+	return nil
+}
+
+// end marks a write registered by begin as finished
+func (g *writeGate) end() {
+	g.inFlight.Done()
+}
+
+// Drain stops accepting config and metadata writes, waits for the ones in flight until ctx is done,
+// then saves the metadata a final time so nothing held in memory is lost
+func (c *Client) Drain(ctx context.Context) error {
+	c.writes.mu.Lock()
+	c.writes.closed = true
+	c.writes.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.writes.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.New("timed out waiting for configuration writes to finish")
+	}
+
+	return c.writeMetadataFile()
+}
@@ -0,0 +1,132 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+)
+
+// errorPageRoutePrefix marks the error routes the manager adds, so routes added by hand are kept
+const errorPageRoutePrefix = "cpm-error-page-"
+
+// SetPages sets the store of page templates served by Caddy, such as error pages
+func (c *Client) SetPages(store *pages.Store) {
+	c.pages = store
+}
+
+// ApplyPages renders the page templates into Caddy: the error pages into every server's error
+// routes and the default site into its catch-all routes. Caddy is only reloaded when the rendered
+// routes changed.
+func (c *Client) ApplyPages() error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if len(config.Apps.HTTP.Servers) == 0 {
+		return nil // Pages are added along with the first proxy or redirect
+	}
+
+	changed := c.applyErrorPages(config)
+	if c.applyDefaultSite(config) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return c.updateConfig(config)
+}
+
+// applyErrorPages replaces the manager's error routes on every server with the rendered error
+// pages, reporting whether anything changed
+func (c *Client) applyErrorPages(config *models.CaddyConfig) bool {
+	if c.pages == nil {
+		return false
+	}
+
+	rendered, errs := c.pages.Render(pages.KindError)
+	for _, err := range errs {
+		log.Printf("Warning: Skipping error page: %v", err)
+	}
+	routes := errorPageRoutes(rendered)
+
+	changed := false
+	for serverName, server := range config.Apps.HTTP.Servers {
+		var existing []models.CaddyErrorRoute
+		if server.Errors != nil {
+			existing = server.Errors.Routes
+		}
+
+		updated := slices.DeleteFunc(slices.Clone(existing), func(route models.CaddyErrorRoute) bool {
+			return strings.HasPrefix(route.ID, errorPageRoutePrefix)
+		})
+		updated = append(updated, routes...)
+
+		if sameErrorRoutes(existing, updated) {
+			continue
+		}
+		changed = true
+
+		if len(updated) == 0 {
+			server.Errors = nil
+		} else {
+			server.Errors = &models.CaddyServerErrors{Routes: updated}
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+
+	return changed
+}
+
+// errorPageRoutes builds an error route per page, matching language variants on the client's
+// preferred language and serving the default variant to everyone else
+func errorPageRoutes(rendered []pages.Page) []models.CaddyErrorRoute {
+	routes := make([]models.CaddyErrorRoute, 0, len(rendered))
+	for _, page := range rendered {
+		route := models.CaddyErrorRoute{
+			ID: errorPageRoutePrefix + page.Language,
+			Handle: []models.CaddyErrorHandler{{
+				Handler:    "static_response",
+				StatusCode: "{http.error.status_code}",
+				Headers:    map[string][]string{"Content-Type": {"text/html; charset=utf-8"}},
+				Body:       page.Body,
+			}},
+		}
+
+		if page.Language != pages.DefaultLanguage {
+			route.Match = []models.CaddyErrorMatch{{
+				HeaderRegexp: map[string]models.CaddyRegexp{
+					"Accept-Language": {Pattern: preferredLanguagePattern(page.Language)},
+				},
+			}}
+		}
+
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// preferredLanguagePattern matches an Accept-Language header whose first language, the client's
+// preferred one, is language
+func preferredLanguagePattern(language string) string {
+	return `(?i)^\s*` + regexp.QuoteMeta(language) + `([-;,\s]|$)`
+}
+
+func sameErrorRoutes(a, b []models.CaddyErrorRoute) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
@@ -0,0 +1,113 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Upstream types supported by proxies
+const (
+	UpstreamTypeHTTP    = "http"
+	UpstreamTypeFastCGI = "fastcgi"
+)
+
+// buildFastCGIHandlers creates the handler chain equivalent to Caddy's php_fastcgi directive:
+// PHP requests are passed to the FastCGI upstream and all other files are served from the root.
+func (c *Client) buildFastCGIHandlers(proxy models.Proxy) ([]models.CaddyHandler, error) {
+	if proxy.FastCGIRoot == "" {
+		return nil, fmt.Errorf("fastcgi upstreams require a document root")
+	}
+
+	dialAddr := fastCGIDialAddress(proxy.TargetURL)
+	if dialAddr == "" {
+		return nil, fmt.Errorf("invalid fastcgi target: %s", proxy.TargetURL)
+	}
+
+	phpHandler := models.CaddyHandler{
+		Handler: "reverse_proxy",
+		Upstreams: []models.CaddyUpstream{
+			{Dial: dialAddr},
+		},
+		Transport: &models.CaddyTransport{
+			Protocol:  "fastcgi",
+			SplitPath: []string{".php"},
+		},
+	}
+
+	applyTimeouts(&phpHandler, proxy)
+
+	// Add custom headers
+	if len(proxy.CustomHeaders) > 0 {
+		phpHandler.Headers = &models.CaddyHeaders{
+			Request: &models.CaddyHeadersRequest{Set: map[string][]string{}},
+		}
+		for key, value := range proxy.CustomHeaders {
+			phpHandler.Headers.Request.Set[key] = []string{value}
+		}
+	}
+
+	routes := []models.CaddyRoute{
+		{
+			// Rewrite to index.php when the requested file doesn't exist (front controller pattern)
+			Match: []models.CaddyMatch{{
+				File: &models.CaddyFileMatch{
+					TryFiles:  []string{"{http.request.uri.path}", "{http.request.uri.path}/index.php", "index.php"},
+					SplitPath: []string{".php"},
+				},
+			}},
+			Handle: []models.CaddyHandler{{
+				Handler: "rewrite",
+				URI:     "{http.matchers.file.relative}",
+			}},
+		},
+		{
+			Match:  []models.CaddyMatch{{Path: []string{"*.php"}}},
+			Handle: []models.CaddyHandler{phpHandler},
+		},
+		{
+			Handle: []models.CaddyHandler{{Handler: "file_server"}},
+		},
+	}
+
+	return []models.CaddyHandler{
+		{Handler: "vars", Root: proxy.FastCGIRoot},
+		{Handler: "subroute", Routes: routes},
+	}, nil
+}
+
+// fastCGIDialAddress converts a fastcgi target such as "fastcgi://php:9000" or
+// "unix//run/php/php-fpm.sock" into a Caddy dial address
+func fastCGIDialAddress(target string) string {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "fastcgi://")
+	if strings.HasPrefix(target, "unix/") {
+		return target
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return net.JoinHostPort(strings.Trim(target, "[]"), "9000") // Default PHP-FPM port
+	}
+	return target
+}
+
+// findReverseProxyHandler returns the first reverse_proxy handler, looking inside subroutes. The
+// upstreams of path rules, whose nested routes carry an @id, are skipped.
+func findReverseProxyHandler(handlers []models.CaddyHandler) *models.CaddyHandler {
+	for i := range handlers {
+		if handlers[i].Handler == "reverse_proxy" {
+			return &handlers[i]
+		}
+		if handlers[i].Handler == "subroute" {
+			for j := range handlers[i].Routes {
+				if handlers[i].Routes[j].ID != "" {
+					continue
+				}
+				if found := findReverseProxyHandler(handlers[i].Routes[j].Handle); found != nil {
+					return found
+				}
+			}
+		}
+	}
+	return nil
+}
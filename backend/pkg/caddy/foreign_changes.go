@@ -0,0 +1,138 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of foreign change to a managed route
+const (
+	ForeignChangeModified = "modified"
+	ForeignChangeRemoved  = "removed"
+)
+
+// ForeignChange is a managed route that another tool changed or removed in Caddy since the manager
+// last wrote it
+type ForeignChange struct {
+	RouteID string `json:"route_id"`
+	App     string `json:"app"` // "http" or "layer4"
+	Server  string `json:"server"`
+	Change  string `json:"change"` // ForeignChangeModified or ForeignChangeRemoved
+}
+
+// ForeignChangeError is returned by writes refused because they would overwrite foreign changes
+type ForeignChangeError struct {
+	Changes []ForeignChange
+}
+
+func (e *ForeignChangeError) Error() string {
+	ids := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		ids[i] = fmt.Sprintf("%s (%s)", change.RouteID, change.Change)
+	}
+	return fmt.Sprintf("managed routes were changed in Caddy by another tool: %s; pass force=true to overwrite them", strings.Join(ids, ", "))
+}
+
+// managedRoute is a route found in a raw Caddy config, in canonical JSON form
+type managedRoute struct {
+	App    string
+	Server string
+	JSON   string
+}
+
+// Forced returns a client whose writes overwrite foreign changes instead of being refused
+func (c *Client) Forced() *Client {
+	forced := *c
+	forced.force = true
+	return &forced
+}
+
+// ForeignChanges compares the managed routes, the ones with an @id, in the config the manager last
+// wrote with those Caddy is running, reporting routes another tool modified or removed since. No
+// changes are reported before the manager has written a config.
+func (c *Client) ForeignChanges() ([]ForeignChange, error) {
+	managedRaw, err := c.readManagedConfig()
+	if err != nil {
+		return nil, nil
+	}
+	runningRaw, err := c.getRawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	managed, err := managedRoutes(managedRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed config: %v", err)
+	}
+	running, err := managedRoutes(runningRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Caddy config: %v", err)
+	}
+
+	var changes []ForeignChange
+	for id, route := range managed {
+		current, exists := running[id]
+		switch {
+		case !exists:
+			changes = append(changes, ForeignChange{RouteID: id, App: route.App, Server: route.Server, Change: ForeignChangeRemoved})
+		case current.JSON != route.JSON:
+			changes = append(changes, ForeignChange{RouteID: id, App: current.App, Server: current.Server, Change: ForeignChangeModified})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b ForeignChange) int { return strings.Compare(a.RouteID, b.RouteID) })
+	return changes, nil
+}
+
+// checkForeignChanges refuses a write when managed routes were changed by another tool, unless the
+// client is forced. Changes that can't be checked don't block writes.
+func (c *Client) checkForeignChanges() error {
+	if c.force {
+		return nil
+	}
+
+	changes, err := c.ForeignChanges()
+	if err != nil || len(changes) == 0 {
+		return nil
+	}
+	return &ForeignChangeError{Changes: changes}
+}
+
+// managedRoutes indexes the routes with an @id in a raw Caddy config by ID. Routes are compared as
+// canonical JSON, so key order and formatting don't count as changes.
+func managedRoutes(raw []byte) (map[string]managedRoute, error) {
+	var config struct {
+		Apps map[string]struct {
+			Servers map[string]struct {
+				Routes []map[string]any `json:"routes"`
+			} `json:"servers"`
+		} `json:"apps"`
+	}
+	if len(bytes.TrimSpace(raw)) > 0 {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	routes := make(map[string]managedRoute)
+	for _, app := range []string{"http", "layer4"} {
+		for serverName, server := range config.Apps[app].Servers {
+			for _, route := range server.Routes {
+				id, _ := route["@id"].(string)
+				if id == "" {
+					continue
+				}
+
+				canonical, err := json.Marshal(route)
+				if err != nil {
+					return nil, err
+				}
+				routes[id] = managedRoute{App: app, Server: serverName, JSON: string(canonical)}
+			}
+		}
+	}
+	return routes, nil
+}
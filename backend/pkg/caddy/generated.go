@@ -0,0 +1,128 @@
+package caddy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// GeneratedConfig is the part of Caddy's running config the manager generated for one proxy
+type GeneratedConfig struct {
+	ProxyID string `json:"proxy_id"`
+	Domain  string `json:"domain"`
+	// Servers holds the servers with the proxy's routes. Routes of other proxies are left out,
+	// but server-level settings are shown as-is since they apply to every host on the server.
+	Servers map[string]models.CaddyServer `json:"servers"`
+	// TLSPolicies are the automation policies covering the proxy's domain or its canonical partner
+	TLSPolicies []models.CaddyAutomationPolicy `json:"tls_automation_policies,omitempty"`
+	// Certificates are the uploaded certificates Caddy loads for the proxy
+	Certificates []models.CaddyCertificateFile `json:"tls_certificates,omitempty"`
+	// CertificateAuthorities are the local CAs the proxy's internal issuer policies refer to
+	CertificateAuthorities map[string]models.CaddyPKICA `json:"pki_certificate_authorities,omitempty"`
+	// Logs are the logs set up for the proxy, such as a running debug capture
+	Logs map[string]models.CaddyLog `json:"logs,omitempty"`
+}
+
+// GeneratedConfig extracts the routes, TLS policies and global fragments generated for a proxy
+// from Caddy's running config
+func (c *Client) GeneratedConfig(id string) (*GeneratedConfig, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proxy := range c.ParseProxiesFromConfig(config) {
+		if proxy.ID == id {
+			return generatedFor(config, proxy), nil
+		}
+	}
+
+	return nil, fmt.Errorf("proxy with ID %s not found", id)
+}
+
+// PreviewProxy returns the config adding a proxy would generate, without applying it. A proxy
+// with the same ID is replaced, as UpdateProxy does.
+func (c *Client) PreviewProxy(proxy models.Proxy) (*GeneratedConfig, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+	if config.Apps.HTTP.Servers == nil {
+		config.Apps.HTTP.Servers = map[string]models.CaddyServer{}
+	}
+
+	removeProxyFromConfig(config, proxy.ID)
+	if err := c.addProxyToConfig(config, proxy); err != nil {
+		return nil, err
+	}
+	c.applyErrorPages(config)
+
+	return generatedFor(config, proxy), nil
+}
+
+// generatedFor extracts the parts of config generated for a proxy
+func generatedFor(config *models.CaddyConfig, proxy models.Proxy) *GeneratedConfig {
+	id := proxy.ID
+	generated := &GeneratedConfig{
+		ProxyID: proxy.ID,
+		Domain:  proxy.Domain,
+		Servers: map[string]models.CaddyServer{},
+	}
+
+	for serverName, server := range config.Apps.HTTP.Servers {
+		routes := slices.DeleteFunc(slices.Clone(server.Routes), func(route models.CaddyRoute) bool {
+			return route.ID != id && route.ID != id+canonicalRouteSuffix
+		})
+		if len(routes) == 0 {
+			continue
+		}
+		server.Routes = routes
+		generated.Servers[serverName] = server
+	}
+
+	subjects := []string{proxy.Domain}
+	if proxy.CanonicalRedirect {
+		subjects = append(subjects, CanonicalPartnerDomain(proxy.Domain))
+	}
+
+	if tlsApp := config.Apps.TLS; tlsApp != nil {
+		if tlsApp.Automation != nil {
+			for _, policy := range tlsApp.Automation.Policies {
+				if slices.ContainsFunc(policy.Subjects, func(subject string) bool { return slices.Contains(subjects, subject) }) {
+					generated.TLSPolicies = append(generated.TLSPolicies, policy)
+				}
+			}
+		}
+		if tlsApp.Certificates != nil {
+			for _, file := range tlsApp.Certificates.LoadFiles {
+				if slices.Contains(file.Tags, customCertTagPrefix+id) {
+					generated.Certificates = append(generated.Certificates, file)
+				}
+			}
+		}
+	}
+
+	if config.Apps.PKI != nil {
+		for _, policy := range generated.TLSPolicies {
+			for _, issuer := range policy.Issuers {
+				ca, exists := config.Apps.PKI.CertificateAuthorities[issuer.CA]
+				if issuer.Module != "internal" || !exists {
+					continue
+				}
+				if generated.CertificateAuthorities == nil {
+					generated.CertificateAuthorities = map[string]models.CaddyPKICA{}
+				}
+				generated.CertificateAuthorities[issuer.CA] = ca
+			}
+		}
+	}
+
+	if config.Logging != nil {
+		if captureLog, exists := config.Logging.Logs[debugCaptureLoggerName(id)]; exists {
+			generated.Logs = map[string]models.CaddyLog{debugCaptureLoggerName(id): captureLog}
+		}
+	}
+
+	return generated
+}
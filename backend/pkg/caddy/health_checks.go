@@ -0,0 +1,37 @@
+package caddy
+
+import "fmt"
+
+// HealthChecksPaused reports whether all health checks were paused, which lasts across restarts
+func (c *Client) HealthChecksPaused() bool {
+	return c.metadata.ChecksPaused
+}
+
+// SetHealthChecksPaused stores whether all health checks are paused
+func (c *Client) SetHealthChecksPaused(paused bool) error {
+	previous := c.metadata.ChecksPaused
+	c.metadata.ChecksPaused = paused
+	if err := c.saveMetadataToFile(); err != nil {
+		c.metadata.ChecksPaused = previous
+		return err
+	}
+	return nil
+}
+
+// SetProxyHealthCheckPaused stores whether one proxy's health check is paused. Only the metadata
+// changes, as the flag plays no part in the proxy's route.
+func (c *Client) SetProxyHealthCheckPaused(proxyID string, paused bool) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	metadata, exists := c.metadata.Get(proxyID)
+	if !exists {
+		return fmt.Errorf("proxy %s not found", proxyID)
+	}
+	c.metadata.SetHealthCheckPaused(proxyID, paused)
+	if err := c.saveMetadataToFile(); err != nil {
+		c.metadata.SetHealthCheckPaused(proxyID, metadata.HealthCheckPaused)
+		return err
+	}
+	return nil
+}
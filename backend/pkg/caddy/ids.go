@@ -0,0 +1,280 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// How new proxies and redirects are named
+const (
+	IDStrategySlug      = "slug"      // readable IDs from the domain, e.g. nextcloud-example-com
+	IDStrategyTimestamp = "timestamp" // the original proxy_<domain>_<unix time> IDs
+)
+
+// legacyIDPattern matches IDs made by the timestamp strategy
+var legacyIDPattern = regexp.MustCompile(`^(proxy|redirect)_.+_\d+$`)
+
+// IDRename describes a proxy or redirect that was given a new ID
+type IDRename struct {
+	Kind  string `json:"kind"` // "proxy" or "redirect"
+	OldID string `json:"old_id"`
+	NewID string `json:"new_id"`
+}
+
+// ValidIDStrategy reports whether strategy is a known ID strategy
+func ValidIDStrategy(strategy string) bool {
+	return strategy == IDStrategySlug || strategy == IDStrategyTimestamp
+}
+
+// SetIDStrategy sets how new proxies and redirects are named
+func (c *Client) SetIDStrategy(strategy string) {
+	c.idStrategy = strategy
+}
+
+// NewProxyID returns an unused ID for a new proxy serving domain
+func (c *Client) NewProxyID(domain string) string {
+	if c.idStrategy == IDStrategyTimestamp {
+		return c.uniqueID(models.GenerateProxyID(domain))
+	}
+	return c.uniqueID(models.GenerateProxySlug(domain))
+}
+
+// NewRedirectID returns an unused ID for a new redirect, named after the first of its source domains
+func (c *Client) NewRedirectID(sourceDomains []string) string {
+	domain := firstSourceDomain(sourceDomains)
+	if c.idStrategy == IDStrategyTimestamp {
+		return c.uniqueID(models.GenerateRedirectID(domain))
+	}
+	return c.uniqueID(models.GenerateRedirectSlug(domain))
+}
+
+// firstSourceDomain returns the domain a redirect is named after
+func firstSourceDomain(sourceDomains []string) string {
+	if len(sourceDomains) == 0 {
+		return "redirect"
+	}
+	return sourceDomains[0]
+}
+
+// ResolveIDAlias returns the current ID of a proxy or redirect renamed from id
+func (c *Client) ResolveIDAlias(id string) (string, bool) {
+	return c.metadata.ResolveIDAlias(id)
+}
+
+// uniqueID returns base, or base with the lowest numbered suffix that makes it unused
+func (c *Client) uniqueID(base string) string {
+	used := c.usedIDs()
+	id := base
+	for n := 2; used[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// usedIDs returns every ID a new proxy, redirect or stream must not take: those of existing routes
+// and metadata, and the legacy IDs that redirect to renamed ones
+func (c *Client) usedIDs() map[string]bool {
+	used := make(map[string]bool)
+	if config, err := c.GetConfig(); err == nil {
+		for _, server := range config.Apps.HTTP.Servers {
+			for _, route := range server.Routes {
+				if route.ID != "" {
+					used[route.ID] = true
+				}
+			}
+		}
+		for _, stream := range c.ParseStreamsFromConfig(config) {
+			used[stream.ID] = true
+		}
+	}
+	for id := range c.metadata.Data {
+		used[id] = true
+	}
+	for id := range c.metadata.Streams {
+		used[id] = true
+	}
+	for alias, id := range c.metadata.IDAliases {
+		used[alias] = true
+		used[id] = true
+	}
+	return used
+}
+
+// MigrateLegacyIDs renames every proxy and redirect with a timestamp ID to a slug of its domain. The
+// old IDs are kept as aliases, so API requests using them are redirected. Renaming stops at the
+// first failure; the renames made until then are returned along with the error.
+func (c *Client) MigrateLegacyIDs() ([]IDRename, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	var renames []IDRename
+	for _, proxy := range c.ParseProxiesFromConfig(config) {
+		if !legacyIDPattern.MatchString(proxy.ID) {
+			continue
+		}
+		newID := c.uniqueID(models.GenerateProxySlug(proxy.Domain))
+		if err := c.RenameProxy(proxy.ID, newID); err != nil {
+			return renames, fmt.Errorf("failed to rename proxy %s: %v", proxy.ID, err)
+		}
+		renames = append(renames, IDRename{Kind: "proxy", OldID: proxy.ID, NewID: newID})
+	}
+
+	for _, redirect := range c.ParseRedirectsFromConfig(config) {
+		if !legacyIDPattern.MatchString(redirect.ID) {
+			continue
+		}
+		newID := c.uniqueID(models.GenerateRedirectSlug(firstSourceDomain(redirect.SourceDomains)))
+		if err := c.RenameRedirect(redirect.ID, newID); err != nil {
+			return renames, fmt.Errorf("failed to rename redirect %s: %v", redirect.ID, err)
+		}
+		renames = append(renames, IDRename{Kind: "redirect", OldID: redirect.ID, NewID: newID})
+	}
+
+	return renames, nil
+}
+
+// RenameProxy gives a proxy a new ID, moving its metadata, uploaded certificate and imported IP
+// lists along. A running debug capture is stopped. Nothing changes when Caddy rejects the result.
+func (c *Client) RenameProxy(oldID, newID string) error {
+	proxy, err := c.GetProxy(oldID)
+	if err != nil {
+		return err
+	}
+	if c.usedIDs()[newID] {
+		return fmt.Errorf("ID %s is already in use", newID)
+	}
+	c.DeleteDebugCapture(oldID)
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	// The rebuilt route reads the certificate and IP lists from under the new ID
+	if err := c.moveProxyFiles(oldID, newID); err != nil {
+		return err
+	}
+	c.metadata.RenameProxy(oldID, newID)
+
+	// Saving the metadata again keeps domains that only a legacy ID held
+	removeProxyFromConfig(config, oldID)
+	removeAccessLog(config, oldID)
+	proxy.ID = newID
+	stored := *proxy
+	stored.HealthCheckHeaders = c.sealHeaders(proxy.HealthCheckHeaders)
+	c.metadata.Set(stored)
+	err = c.addProxyToConfig(config, *proxy)
+	if err == nil {
+		err = c.updateConfig(config)
+	}
+	if err != nil {
+		c.metadata.RenameProxy(newID, oldID)
+		if err := c.moveProxyFiles(newID, oldID); err != nil {
+			log.Printf("Warning: Failed to move files of proxy %s back: %v", oldID, err)
+		}
+		return err
+	}
+
+	c.metadata.SetIDAlias(oldID, newID)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// RenameRedirect gives a redirect a new ID
+func (c *Client) RenameRedirect(oldID, newID string) error {
+	if c.usedIDs()[newID] {
+		return fmt.Errorf("ID %s is already in use", newID)
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil || config.Apps.HTTP.Servers == nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	found := false
+	for serverName, server := range config.Apps.HTTP.Servers {
+		for i, route := range server.Routes {
+			if route.ID == oldID {
+				server.Routes[i].ID = newID
+				config.Apps.HTTP.Servers[serverName] = server
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("redirect with ID %s not found", oldID)
+	}
+
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.RenameRedirect(oldID, newID)
+	c.metadata.SetIDAlias(oldID, newID)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// moveProxyFiles moves the files kept under a proxy's ID to newID
+func (c *Client) moveProxyFiles(oldID, newID string) error {
+	oldCert, oldKey, err := c.CustomCertificateFiles(oldID)
+	if err != nil {
+		return err
+	}
+	newCert, newKey, err := c.CustomCertificateFiles(newID)
+	if err != nil {
+		return err
+	}
+
+	oldWAFLog, err := c.WAFLogFile(oldID)
+	if err != nil {
+		return err
+	}
+	newWAFLog, err := c.WAFLogFile(newID)
+	if err != nil {
+		return err
+	}
+
+	oldAccessLog, err := c.AccessLogFile(oldID)
+	if err != nil {
+		return err
+	}
+	newAccessLog, err := c.AccessLogFile(newID)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(filepath.Dir(c.ConfigFile), "ip-lists")
+	moves := [][2]string{
+		{oldCert, newCert},
+		{oldKey, newKey},
+		{filepath.Join(dir, oldID), filepath.Join(dir, newID)},
+		{oldWAFLog, newWAFLog},
+		{oldAccessLog, newAccessLog},
+	}
+	for _, move := range moves {
+		if err := os.Rename(move[0], move[1]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to move %s: %v", filepath.Base(move[0]), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package caddy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/iplist"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// DefaultIPListRefreshInterval is how often a list imported from a URL is fetched again
+	DefaultIPListRefreshInterval = 24 * time.Hour
+	// MinIPListRefreshInterval keeps scheduled fetches from hammering list publishers
+	MinIPListRefreshInterval = 15 * time.Minute
+	// ipListRetryInterval is how soon a failed fetch is retried when the list refreshes less often
+	ipListRetryInterval = time.Hour
+)
+
+// IPListRefresh is the outcome of fetching an imported IP list again
+type IPListRefresh struct {
+	ProxyID string
+	List    models.IPListSource
+	Changed bool // the entries differ from the previous fetch and were applied
+	Err     error
+}
+
+// ipListFile returns where the entries of a proxy's imported IP list are stored
+func (c *Client) ipListFile(proxyID, listID string) (string, error) {
+	if proxyID == "" || filepath.Base(proxyID) != proxyID || listID == "" || filepath.Base(listID) != listID {
+		return "", fmt.Errorf("invalid IP list ID")
+	}
+
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "ip-lists", proxyID))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve IP list directory: %v", err)
+	}
+
+	return filepath.Join(dir, listID+".txt"), nil
+}
+
+// IPLists returns the IP lists imported into a proxy
+func (c *Client) IPLists(proxyID string) []models.IPListSource {
+	return c.metadata.GetIPLists(proxyID)
+}
+
+// IPListEntries reads the entries of an imported IP list
+func (c *Client) IPListEntries(proxyID, listID string) ([]string, error) {
+	file, err := c.ipListFile(proxyID, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// SaveIPList stores the entries of an imported IP list, replacing the proxy's list with the same
+// ID, and applies them to the proxy's route. Nothing is kept when Caddy rejects the result.
+func (c *Client) SaveIPList(proxyID string, list models.IPListSource, entries []string) error {
+	proxy, err := c.GetProxy(proxyID)
+	if err != nil {
+		return err
+	}
+
+	file, err := c.ipListFile(proxyID, list.ID)
+	if err != nil {
+		return err
+	}
+	previousEntries, readErr := os.ReadFile(file)
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read IP list: %v", readErr)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create IP list directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(entries, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write IP list: %v", err)
+	}
+
+	previous := c.metadata.GetIPLists(proxyID)
+	list.Entries = len(entries)
+	lists := slices.Clone(previous)
+	if i := slices.IndexFunc(lists, func(l models.IPListSource) bool { return l.ID == list.ID }); i >= 0 {
+		lists[i] = list
+	} else {
+		lists = append(lists, list)
+	}
+	c.metadata.SetIPLists(proxyID, lists)
+
+	if err := c.UpdateProxy(*proxy); err != nil {
+		c.metadata.SetIPLists(proxyID, previous)
+		if readErr == nil {
+			os.WriteFile(file, previousEntries, 0600)
+		} else {
+			os.Remove(file)
+		}
+		if err := c.saveMetadataToFile(); err != nil {
+			log.Printf("Warning: Failed to save metadata: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteIPList removes an imported IP list and applies the proxy's route without its entries
+func (c *Client) DeleteIPList(proxyID, listID string) error {
+	proxy, err := c.GetProxy(proxyID)
+	if err != nil {
+		return err
+	}
+
+	lists := c.metadata.GetIPLists(proxyID)
+	i := slices.IndexFunc(lists, func(l models.IPListSource) bool { return l.ID == listID })
+	if i < 0 {
+		return fmt.Errorf("IP list %s not found", listID)
+	}
+
+	c.metadata.SetIPLists(proxyID, slices.Delete(slices.Clone(lists), i, i+1))
+	if err := c.UpdateProxy(*proxy); err != nil {
+		c.metadata.SetIPLists(proxyID, lists)
+		if err := c.saveMetadataToFile(); err != nil {
+			log.Printf("Warning: Failed to save metadata: %v", err)
+		}
+		return err
+	}
+
+	if file, err := c.ipListFile(proxyID, listID); err == nil {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: Failed to remove IP list file %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// DeleteIPLists removes every list imported into a deleted proxy
+func (c *Client) DeleteIPLists(proxyID string) error {
+	if _, err := c.ipListFile(proxyID, "list"); err != nil {
+		return err
+	}
+
+	c.metadata.DeleteIPLists(proxyID)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+
+	return os.RemoveAll(filepath.Join(filepath.Dir(c.ConfigFile), "ip-lists", proxyID))
+}
+
+// RefreshIPList fetches an imported list's URL again, applying the entries when they changed. A
+// failed fetch is recorded on the list and its previous entries stay in use.
+func (c *Client) RefreshIPList(proxyID, listID string, fetch func(url string) ([]string, error)) IPListRefresh {
+	lists := c.metadata.GetIPLists(proxyID)
+	i := slices.IndexFunc(lists, func(l models.IPListSource) bool { return l.ID == listID })
+	if i < 0 {
+		return IPListRefresh{ProxyID: proxyID, Err: fmt.Errorf("IP list %s not found", listID)}
+	}
+	list := lists[i]
+	result := IPListRefresh{ProxyID: proxyID, List: list}
+	if list.URL == "" {
+		result.Err = fmt.Errorf("IP list %s was uploaded and has no URL to refresh from", listID)
+		return result
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	list.LastFetchedAt = now
+	entries, err := fetch(list.URL)
+	if err == nil {
+		var previous []string
+		previous, err = c.IPListEntries(proxyID, listID)
+		if err == nil && slices.Equal(previous, entries) {
+			list.LastError = ""
+			result.List = list
+			c.updateIPListMetadata(proxyID, list)
+			return result
+		}
+
+		list.LastError = ""
+		list.UpdatedAt = now
+		if err = c.SaveIPList(proxyID, list, entries); err == nil {
+			list.Entries = len(entries)
+			result.List = list
+			result.Changed = true
+			return result
+		}
+	}
+
+	list.LastError = err.Error()
+	result.List = list
+	result.Err = err
+	c.updateIPListMetadata(proxyID, list)
+	return result
+}
+
+// RefreshDueIPLists refreshes every list imported from a URL whose refresh interval has passed.
+// Lists whose last fetch failed are retried sooner, after at most ipListRetryInterval.
+func (c *Client) RefreshDueIPLists(fetch func(url string) ([]string, error)) []IPListRefresh {
+	now := time.Now()
+
+	var results []IPListRefresh
+	for proxyID, lists := range c.metadata.IPLists {
+		for _, list := range lists {
+			if list.URL == "" || !ipListDue(list, now) {
+				continue
+			}
+			results = append(results, c.RefreshIPList(proxyID, list.ID, fetch))
+		}
+	}
+	return results
+}
+
+// ipListDue reports whether a list imported from a URL should be fetched again
+func ipListDue(list models.IPListSource, now time.Time) bool {
+	lastFetched, err := time.Parse(time.RFC3339, list.LastFetchedAt)
+	if err != nil {
+		return true
+	}
+
+	interval, err := time.ParseDuration(list.RefreshInterval)
+	if err != nil || interval < MinIPListRefreshInterval {
+		interval = DefaultIPListRefreshInterval
+	}
+	if list.LastError != "" {
+		interval = min(interval, ipListRetryInterval)
+	}
+
+	return !now.Before(lastFetched.Add(interval))
+}
+
+// updateIPListMetadata saves a list's fetch status without touching its entries
+func (c *Client) updateIPListMetadata(proxyID string, list models.IPListSource) {
+	lists := slices.Clone(c.metadata.GetIPLists(proxyID))
+	i := slices.IndexFunc(lists, func(l models.IPListSource) bool { return l.ID == list.ID })
+	if i < 0 {
+		return
+	}
+
+	lists[i] = list
+	c.metadata.SetIPLists(proxyID, lists)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+}
+
+// proxyIPRanges returns a proxy's allowed and blocked ranges together with the entries of the lists
+// imported into it and the ranges of its access lists
+func (c *Client) proxyIPRanges(proxy models.Proxy) ([]string, []string, error) {
+	allowed, blocked := proxy.AllowedIPs, proxy.BlockedIPs
+	for _, list := range c.metadata.GetIPLists(proxy.ID) {
+		entries, err := c.IPListEntries(proxy.ID, list.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read IP list %s: %v", list.Name, err)
+		}
+
+		if list.List == models.IPListAllowed {
+			allowed = iplist.Merge(allowed, entries)
+		} else {
+			blocked = iplist.Merge(blocked, entries)
+		}
+	}
+	return c.accessListIPRanges(proxy, allowed, blocked)
+}
@@ -0,0 +1,82 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// isolatedServerPrefix starts the name of the Caddy server holding a single isolated proxy
+const isolatedServerPrefix = "isolated_"
+
+// IsolatedServerName returns the name of the Caddy server an isolated proxy gets to itself
+func IsolatedServerName(proxyID string) string {
+	return isolatedServerPrefix + proxyID
+}
+
+// isIsolatedServer reports whether a server holds a single isolated proxy
+func isIsolatedServer(serverName string) bool {
+	return strings.HasPrefix(serverName, isolatedServerPrefix)
+}
+
+// ValidateListenAddresses checks addresses are Caddy listen addresses such as ":8443" or
+// "192.168.1.10:443"
+func ValidateListenAddresses(addresses []string) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("an isolated proxy needs at least one listen address")
+	}
+
+	for _, address := range addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q, expected host:port or :port", address)
+		}
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return fmt.Errorf("invalid port in listen address %q", address)
+		}
+		if host != "" && net.ParseIP(host) == nil {
+			return fmt.Errorf("listen address %q must use an IP address, not a host name", address)
+		}
+	}
+	return nil
+}
+
+// checkListenerConflicts fails when an isolated proxy's listen addresses are already used by another
+// server or a stream proxy, since Caddy can't bind one address for two servers
+func checkListenerConflicts(config *models.CaddyConfig, serverName string, addresses []string) error {
+	for _, stream := range streamListeners(config) {
+		for _, address := range addresses {
+			if listenersOverlap(address, stream.Listen) {
+				return fmt.Errorf("listen address %s conflicts with %s used by stream %s", address, stream.Listen, stream.ID)
+			}
+		}
+	}
+
+	for otherName, server := range config.Apps.HTTP.Servers {
+		if otherName == serverName {
+			continue
+		}
+		for _, address := range addresses {
+			for _, used := range server.Listen {
+				if listenersOverlap(address, used) {
+					return fmt.Errorf("listen address %s conflicts with %s used by server %s", address, used, otherName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// listenersOverlap reports whether two listen addresses bind the same port on a shared interface.
+// An empty host binds every interface.
+func listenersOverlap(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || portA != portB {
+		return false
+	}
+	return hostA == "" || hostB == "" || hostA == hostB
+}
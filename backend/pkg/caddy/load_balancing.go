@@ -0,0 +1,34 @@
+package caddy
+
+import (
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Load balancing policies for proxies with several upstreams, named after Caddy's selection policies
+const (
+	LBPolicyRoundRobin = "round_robin"
+	LBPolicyLeastConn  = "least_conn"
+	LBPolicyIPHash     = "ip_hash"
+
+	// DefaultLBPolicy is used when a proxy with several upstreams doesn't choose a policy
+	DefaultLBPolicy = LBPolicyRoundRobin
+)
+
+// LBPolicies lists the supported load balancing policies
+var LBPolicies = []string{LBPolicyRoundRobin, LBPolicyLeastConn, LBPolicyIPHash}
+
+// ValidLBPolicy reports whether policy is a supported load balancing policy
+func ValidLBPolicy(policy string) bool {
+	return slices.Contains(LBPolicies, policy)
+}
+
+// ProxyTargets returns the upstream URLs of a proxy: its TargetURLs when load balancing, otherwise
+// its single TargetURL
+func ProxyTargets(proxy models.Proxy) []string {
+	if len(proxy.TargetURLs) > 0 {
+		return proxy.TargetURLs
+	}
+	return []string{proxy.TargetURL}
+}
@@ -0,0 +1,75 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Optional Caddy modules the manager can use, which standard Caddy builds don't include
+var optionalModules = []string{
+	"http.handlers." + BandwidthHandler,
+	"http.handlers." + WAFHandler,
+	"layer4",
+	"caddy.storage.redis",
+	"caddy.storage.consul",
+}
+
+// ModulesInUse lists the optional modules and DNS provider modules, such as
+// "dns.providers.cloudflare", that Caddy's running config uses. Caddy refuses configs with modules
+// it wasn't built with, so each of them is known to be available. Modules missing from the list
+// may still be available; Caddy's admin API has no way to list them.
+func (c *Client) ModulesInUse() ([]string, error) {
+	raw, err := c.getRawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var config any
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("invalid config JSON: %v", err)
+	}
+
+	found := make(map[string]bool)
+	collectModules(config, "", found)
+
+	modules := make([]string, 0, len(found))
+	for module := range found {
+		if slices.Contains(optionalModules, module) || strings.HasPrefix(module, "dns.providers.") {
+			modules = append(modules, module)
+		}
+	}
+	slices.Sort(modules)
+	return modules, nil
+}
+
+// collectModules walks a JSON config and records the modules named in it, by the module field
+// Caddy uses at each place: "handler" in HTTP routes, "module" for storage, "name" for DNS
+// providers, plus the apps themselves
+func collectModules(value any, key string, found map[string]bool) {
+	switch value := value.(type) {
+	case map[string]any:
+		if key == "apps" {
+			for app := range value {
+				found[app] = true
+			}
+		}
+		if handler, ok := value["handler"].(string); ok {
+			found["http.handlers."+handler] = true
+		}
+		if module, ok := value["module"].(string); ok && key == "storage" {
+			found["caddy.storage."+module] = true
+		}
+		if name, ok := value["name"].(string); ok && key == "provider" {
+			found["dns.providers."+name] = true
+		}
+		for child, childValue := range value {
+			collectModules(childValue, child, found)
+		}
+	case []any:
+		for _, item := range value {
+			collectModules(item, key, found)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// NotificationChannels returns the notification channels with their settings decrypted, sorted by
+// name
+func (c *Client) NotificationChannels() []models.NotificationChannel {
+	channels := make([]models.NotificationChannel, 0, len(c.metadata.NotificationChannels))
+	for _, channel := range c.metadata.NotificationChannels {
+		channels = append(channels, c.openNotificationChannel(channel))
+	}
+	slices.SortFunc(channels, func(a, b models.NotificationChannel) int {
+		if n := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return channels
+}
+
+// GetNotificationChannel retrieves a notification channel by ID, with its settings decrypted
+func (c *Client) GetNotificationChannel(id string) (*models.NotificationChannel, error) {
+	channel, exists := c.metadata.GetNotificationChannel(id)
+	if !exists {
+		return nil, fmt.Errorf("notification channel %s not found", id)
+	}
+	channel = c.openNotificationChannel(channel)
+	return &channel, nil
+}
+
+// NewNotificationChannelID returns an unused ID for a new notification channel named name
+func (c *Client) NewNotificationChannelID(name string) string {
+	base := models.GenerateNotificationChannelSlug(name)
+	id := base
+	for n := 2; ; n++ {
+		if _, exists := c.metadata.GetNotificationChannel(id); !exists {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// SaveNotificationChannel stores a notification channel, encrypting its settings
+func (c *Client) SaveNotificationChannel(channel models.NotificationChannel) error {
+	previous, existed := c.metadata.GetNotificationChannel(channel.ID)
+
+	channel.Settings = c.sealSettings(channel)
+	c.metadata.SetNotificationChannel(channel)
+	if err := c.saveMetadataToFile(); err != nil {
+		if existed {
+			c.metadata.SetNotificationChannel(previous)
+		} else {
+			c.metadata.DeleteNotificationChannel(channel.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteNotificationChannel removes a notification channel
+func (c *Client) DeleteNotificationChannel(id string) error {
+	if _, exists := c.metadata.GetNotificationChannel(id); !exists {
+		return fmt.Errorf("notification channel %s not found", id)
+	}
+
+	c.metadata.DeleteNotificationChannel(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// sealSettings returns a copy of a channel's settings with each value encrypted
+func (c *Client) sealSettings(channel models.NotificationChannel) map[string]string {
+	if c.secrets == nil {
+		return maps.Clone(channel.Settings)
+	}
+
+	sealed := make(map[string]string, len(channel.Settings))
+	for key, value := range channel.Settings {
+		encrypted, err := c.secrets.Encrypt(value)
+		if err != nil {
+			log.Printf("Warning: Failed to encrypt setting %s of notification channel %s, storing it unencrypted: %v", key, channel.ID, err)
+			encrypted = value
+		}
+		sealed[key] = encrypted
+	}
+	return sealed
+}
+
+// openNotificationChannel returns a copy of a stored channel with its settings decrypted. Settings
+// that can't be decrypted are dropped rather than sent as ciphertext.
+func (c *Client) openNotificationChannel(channel models.NotificationChannel) models.NotificationChannel {
+	channel.Events = slices.Clone(channel.Events)
+	if c.secrets == nil {
+		channel.Settings = maps.Clone(channel.Settings)
+		return channel
+	}
+
+	opened := make(map[string]string, len(channel.Settings))
+	for key, value := range channel.Settings {
+		decrypted, err := c.secrets.Decrypt(value)
+		if err != nil {
+			log.Printf("Warning: Failed to decrypt setting %s of notification channel %s: %v", key, channel.ID, err)
+			continue
+		}
+		opened[key] = decrypted
+	}
+	channel.Settings = opened
+	return channel
+}
@@ -0,0 +1,117 @@
+package caddy
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// pathRuleRouteID names the route of a proxy's path rule, nested in the proxy's route
+func pathRuleRouteID(proxyID string, index int) string {
+	return fmt.Sprintf("%s_path_%d", proxyID, index)
+}
+
+// NormalizePathRules validates path rules and returns them with trimmed targets and paths without a
+// trailing slash
+func NormalizePathRules(rules []models.PathRule) ([]models.PathRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]models.PathRule, 0, len(rules))
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		rule.Path = strings.TrimRight(strings.TrimSpace(rule.Path), "/")
+		rule.TargetURL = strings.TrimSpace(rule.TargetURL)
+
+		if rule.Path == "" || !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("path rule %q must start with / and can't be the root, which the proxy's own target serves", rule.Path)
+		}
+		if strings.ContainsAny(rule.Path, "*? \t") {
+			return nil, fmt.Errorf("path rule %q must be a plain path prefix", rule.Path)
+		}
+		if seen[rule.Path] {
+			return nil, fmt.Errorf("duplicate path rule %s", rule.Path)
+		}
+		seen[rule.Path] = true
+
+		if rule.TargetURL == "" {
+			return nil, fmt.Errorf("path rule %s requires a target_url", rule.Path)
+		}
+		target, err := NormalizeTargetURL(rule.TargetURL)
+		if err != nil {
+			return nil, fmt.Errorf("path rule %s: %v", rule.Path, err)
+		}
+		rule.TargetURL = target
+
+		normalized = append(normalized, rule)
+	}
+	return normalized, nil
+}
+
+// orderedPathRules returns the rules with longer paths first, so /api/v2 is matched before /api
+func orderedPathRules(rules []models.PathRule) []models.PathRule {
+	ordered := slices.Clone(rules)
+	slices.SortStableFunc(ordered, func(a, b models.PathRule) int {
+		return cmp.Compare(len(b.Path), len(a.Path))
+	})
+	return ordered
+}
+
+// pathRuleUpstream returns the proxy as seen by one of its path rules: a single plain HTTP upstream
+// with the rule's headers added to the proxy's own
+func pathRuleUpstream(proxy models.Proxy, rule models.PathRule) models.Proxy {
+	upstream := proxy
+	upstream.TargetURL = rule.TargetURL
+	upstream.TargetURLs = nil
+	upstream.BackupTargetURL = ""
+	upstream.LBPolicy = ""
+	upstream.GRPC = false
+	upstream.UpstreamSNI = "" // names the proxy's own target, not the rule's
+	upstream.UpstreamType = UpstreamTypeHTTP
+
+	headers := maps.Clone(proxy.CustomHeaders)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	maps.Copy(headers, rule.CustomHeaders)
+	upstream.CustomHeaders = headers
+
+	return upstream
+}
+
+// buildPathRuleHandler creates the subroute sending requests under each path rule's prefix to its
+// upstream. It runs before the proxy's own upstream, which only gets requests no rule matched since
+// reverse_proxy ends the handler chain.
+func (c *Client) buildPathRuleHandler(proxy models.Proxy) (*models.CaddyHandler, error) {
+	rules, err := NormalizePathRules(proxy.PathRules)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]models.CaddyRoute, 0, len(rules))
+	for i, rule := range orderedPathRules(rules) {
+		reverseProxyHandler, err := c.buildReverseProxyHandler(pathRuleUpstream(proxy, rule))
+		if err != nil {
+			return nil, fmt.Errorf("path rule %s: %v", rule.Path, err)
+		}
+
+		var handlers []models.CaddyHandler
+		if rule.StripPrefix {
+			handlers = append(handlers, models.CaddyHandler{Handler: "rewrite", StripPathPrefix: rule.Path})
+		}
+		handlers = append(handlers, *reverseProxyHandler)
+
+		routes = append(routes, models.CaddyRoute{
+			ID:     pathRuleRouteID(proxy.ID, i),
+			Match:  []models.CaddyMatch{{Path: []string{rule.Path, rule.Path + "/*"}}},
+			Handle: handlers,
+		})
+	}
+
+	return &models.CaddyHandler{Handler: "subroute", Routes: routes}, nil
+}
@@ -0,0 +1,241 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// InternalCAID is the ID of the PKI certificate authority managed by the proxy manager
+	InternalCAID = "local"
+	// ACMEServerRouteID is the route ID used for the internal ACME server
+	ACMEServerRouteID = "internal_ca_acme_server"
+)
+
+// ACMEServerConfig describes the state of Caddy's built-in ACME server
+type ACMEServerConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Host         string `json:"host"`
+	Lifetime     string `json:"lifetime,omitempty"`
+	DirectoryURL string `json:"directory_url,omitempty"`
+}
+
+// GetACMEServer extracts the internal ACME server configuration from Caddy config
+func (c *Client) GetACMEServer(config *models.CaddyConfig) ACMEServerConfig {
+	if config == nil || config.Apps.HTTP.Servers == nil {
+		return ACMEServerConfig{}
+	}
+
+	for _, server := range config.Apps.HTTP.Servers {
+		for _, route := range server.Routes {
+			if route.ID != ACMEServerRouteID {
+				continue
+			}
+
+			acmeServer := ACMEServerConfig{Enabled: true}
+			if len(route.Match) > 0 && len(route.Match[0].Host) > 0 {
+				acmeServer.Host = route.Match[0].Host[0]
+				acmeServer.DirectoryURL = fmt.Sprintf("https://%s/acme/%s/directory", acmeServer.Host, InternalCAID)
+			}
+			for _, handler := range route.Handle {
+				if handler.Handler == "acme_server" {
+					acmeServer.Lifetime = handler.Lifetime
+				}
+			}
+
+			return acmeServer
+		}
+	}
+
+	return ACMEServerConfig{}
+}
+
+// EnableACMEServer configures Caddy's internal CA and serves its ACME endpoint on the given host
+func (c *Client) EnableACMEServer(host, lifetime string) error {
+	if host == "" {
+		return fmt.Errorf("host is required for the ACME server")
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil || config.Apps.HTTP.Servers == nil {
+		config = &models.CaddyConfig{
+			Apps: models.CaddyApps{
+				HTTP: models.CaddyHTTP{
+					Servers: map[string]models.CaddyServer{},
+				},
+			},
+		}
+	}
+
+	// Drop any previous ACME server route and policy before adding the new one
+	if previous := c.GetACMEServer(config); previous.Enabled {
+		removeRouteByID(config, ACMEServerRouteID)
+		removeInternalIssuerPolicy(config, previous.Host)
+	}
+
+	// Ensure the PKI app has the internal CA
+	if config.Apps.PKI == nil {
+		config.Apps.PKI = &models.CaddyPKI{}
+	}
+	if config.Apps.PKI.CertificateAuthorities == nil {
+		config.Apps.PKI.CertificateAuthorities = map[string]models.CaddyPKICA{}
+	}
+	if _, exists := config.Apps.PKI.CertificateAuthorities[InternalCAID]; !exists {
+		config.Apps.PKI.CertificateAuthorities[InternalCAID] = models.CaddyPKICA{
+			Name: "Caddy Proxy Manager Internal CA",
+		}
+	}
+
+	route := models.CaddyRoute{
+		ID:    ACMEServerRouteID,
+		Match: []models.CaddyMatch{{Host: []string{host}}},
+		Handle: []models.CaddyHandler{
+			{
+				Handler:  "acme_server",
+				CA:       InternalCAID,
+				Lifetime: lifetime,
+			},
+		},
+	}
+
+	serverName := "https_enabled"
+	listenPorts := []string{":80", ":443"}
+	if server, exists := config.Apps.HTTP.Servers[serverName]; exists {
+		server.Routes = append(server.Routes, route)
+		for _, port := range listenPorts {
+			if !slices.Contains(server.Listen, port) {
+				server.Listen = append(server.Listen, port)
+			}
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	} else {
+		config.Apps.HTTP.Servers[serverName] = models.CaddyServer{
+			Listen: listenPorts,
+			Routes: []models.CaddyRoute{route},
+		}
+	}
+
+	// The ACME directory itself must be served with a certificate from the internal CA
+	c.configureInternalIssuer(config, host)
+
+	return c.updateConfig(config)
+}
+
+// DisableACMEServer removes the internal ACME server route from Caddy
+func (c *Client) DisableACMEServer() error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil || config.Apps.HTTP.Servers == nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	host := c.GetACMEServer(config).Host
+	if !removeRouteByID(config, ACMEServerRouteID) {
+		return nil // Already disabled
+	}
+	removeInternalIssuerPolicy(config, host)
+
+	return c.updateConfig(config)
+}
+
+// GetCAInfo retrieves certificate authority details, including root and intermediate certificates
+func (c *Client) GetCAInfo(caID string) (*models.CaddyCAInfo, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/pki/ca/" + caID)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("caddy API returned status %d", resp.StatusCode)
+	}
+
+	var info models.CaddyCAInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// configureInternalIssuer adds a TLS automation policy issuing the domain's certificate from the local CA
+func (c *Client) configureInternalIssuer(config *models.CaddyConfig, domain string) {
+	if config.Apps.TLS == nil {
+		config.Apps.TLS = &models.CaddyTLS{}
+	}
+	if config.Apps.TLS.Automation == nil {
+		config.Apps.TLS.Automation = &models.CaddyTLSAutomation{}
+	}
+
+	removeInternalIssuerPolicy(config, domain)
+	config.Apps.TLS.Automation.Policies = append(config.Apps.TLS.Automation.Policies, models.CaddyAutomationPolicy{
+		Subjects: []string{domain},
+		Issuers:  []models.CaddyIssuer{{Module: "internal", CA: InternalCAID}},
+	})
+}
+
+// hasInternalIssuerPolicy reports whether the domain's certificate is issued by the local CA
+func hasInternalIssuerPolicy(config *models.CaddyConfig, domain string) bool {
+	if config.Apps.TLS == nil || config.Apps.TLS.Automation == nil {
+		return false
+	}
+
+	for _, policy := range config.Apps.TLS.Automation.Policies {
+		if slices.Contains(policy.Subjects, domain) && len(policy.Issuers) == 1 && policy.Issuers[0].Module == "internal" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeRouteByID removes the route with the given ID from all servers, reporting whether it was found
+func removeRouteByID(config *models.CaddyConfig, id string) bool {
+	found := false
+	for serverName, server := range config.Apps.HTTP.Servers {
+		var filteredRoutes []models.CaddyRoute
+		for _, route := range server.Routes {
+			if route.ID != id {
+				filteredRoutes = append(filteredRoutes, route)
+			}
+		}
+		if len(filteredRoutes) == len(server.Routes) {
+			continue
+		}
+
+		found = true
+		server.Routes = filteredRoutes
+		if len(filteredRoutes) == 0 {
+			delete(config.Apps.HTTP.Servers, serverName)
+		} else {
+			config.Apps.HTTP.Servers[serverName] = server
+		}
+	}
+
+	return found
+}
+
+// removeInternalIssuerPolicy removes the internal issuer automation policy for a subject
+func removeInternalIssuerPolicy(config *models.CaddyConfig, subject string) {
+	if config.Apps.TLS == nil || config.Apps.TLS.Automation == nil {
+		return
+	}
+
+	var policies []models.CaddyAutomationPolicy
+	for _, policy := range config.Apps.TLS.Automation.Policies {
+		if slices.Contains(policy.Subjects, subject) && len(policy.Issuers) == 1 && policy.Issuers[0].Module == "internal" {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	config.Apps.TLS.Automation.Policies = policies
+}
@@ -0,0 +1,77 @@
+package caddy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// ProxyTemplates returns the proxy templates, sorted by name
+func (c *Client) ProxyTemplates() []models.ProxyTemplate {
+	templates := make([]models.ProxyTemplate, 0, len(c.metadata.ProxyTemplates))
+	for _, template := range c.metadata.ProxyTemplates {
+		templates = append(templates, template)
+	}
+	slices.SortFunc(templates, func(a, b models.ProxyTemplate) int {
+		if n := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); n != 0 {
+			return n
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return templates
+}
+
+// GetProxyTemplate retrieves a proxy template by ID
+func (c *Client) GetProxyTemplate(id string) (*models.ProxyTemplate, error) {
+	template, exists := c.metadata.GetProxyTemplate(id)
+	if !exists {
+		return nil, fmt.Errorf("proxy template %s not found", id)
+	}
+	return &template, nil
+}
+
+// NewProxyTemplateID returns an unused ID for a new proxy template named name
+func (c *Client) NewProxyTemplateID(name string) string {
+	base := models.GenerateProxyTemplateSlug(name)
+	id := base
+	for n := 2; ; n++ {
+		if _, exists := c.metadata.GetProxyTemplate(id); !exists {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// SaveProxyTemplate stores a proxy template. Proxies created from it earlier keep their settings.
+// Templates only live in the metadata, so nothing is kept when it can't be saved.
+func (c *Client) SaveProxyTemplate(template models.ProxyTemplate) error {
+	previous, existed := c.metadata.GetProxyTemplate(template.ID)
+	c.metadata.SetProxyTemplate(template)
+
+	if err := c.saveMetadataToFile(); err != nil {
+		if existed {
+			c.metadata.SetProxyTemplate(previous)
+		} else {
+			c.metadata.DeleteProxyTemplate(template.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteProxyTemplate removes a proxy template
+func (c *Client) DeleteProxyTemplate(id string) error {
+	template, exists := c.metadata.GetProxyTemplate(id)
+	if !exists {
+		return fmt.Errorf("proxy template %s not found", id)
+	}
+
+	c.metadata.DeleteProxyTemplate(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		c.metadata.SetProxyTemplate(template)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package caddy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// routePatch is a single managed route to replace through Caddy's /id/ endpoint, and the config
+// Caddy runs once it is replaced
+type routePatch struct {
+	route  *models.CaddyRoute // nil when no route changes
+	config *models.CaddyConfig
+}
+
+// planRoutePatch works out whether going from running to updated only replaces one of the routes
+// with the given IDs, in place on the same server. Anything else, such as a route added, removed or
+// moved to another server, more than one route changing, or changed listeners or TLS settings,
+// needs the whole config loaded.
+func planRoutePatch(running, updated *models.CaddyConfig, ids ...string) (*routePatch, bool) {
+	before := indexRoutes(running, ids)
+	after := indexRoutes(updated, ids)
+	if len(before) != len(after) {
+		return nil, false
+	}
+
+	patch := &routePatch{}
+	for id, old := range before {
+		current, exists := after[id]
+		if !exists || current.server != old.server {
+			return nil, false
+		}
+		if routeJSON(old.route) == routeJSON(current.route) {
+			continue
+		}
+		if patch.route != nil {
+			// Several routes are loaded together, so they change at once
+			return nil, false
+		}
+		patch.route = &current.route
+	}
+
+	// Everything but the routes themselves must already be what Caddy runs
+	if configJSON(withoutRoutes(running, ids)) != configJSON(withoutRoutes(updated, ids)) {
+		return nil, false
+	}
+
+	// Keep the running config's route order, which a full load would have moved the proxy out of
+	patch.config = withoutRoutes(running, nil)
+	if patch.route != nil {
+		server := patch.config.Apps.HTTP.Servers[before[patch.route.ID].server]
+		server.Routes[before[patch.route.ID].index] = *patch.route
+	}
+	return patch, true
+}
+
+// indexedRoute is where a route sits in a config
+type indexedRoute struct {
+	server string
+	index  int
+	route  models.CaddyRoute
+}
+
+// indexRoutes finds the routes with the given IDs in config's HTTP servers
+func indexRoutes(config *models.CaddyConfig, ids []string) map[string]indexedRoute {
+	routes := make(map[string]indexedRoute)
+	for serverName, server := range config.Apps.HTTP.Servers {
+		for i, route := range server.Routes {
+			if slices.Contains(ids, route.ID) {
+				routes[route.ID] = indexedRoute{server: serverName, index: i, route: route}
+			}
+		}
+	}
+	return routes
+}
+
+// withoutRoutes returns a copy of config without the routes with the given IDs. Servers and their
+// route slices are copied, so the copy can be changed without touching config.
+func withoutRoutes(config *models.CaddyConfig, ids []string) *models.CaddyConfig {
+	copied := *config
+	copied.Apps.HTTP.Servers = make(map[string]models.CaddyServer, len(config.Apps.HTTP.Servers))
+	for serverName, server := range config.Apps.HTTP.Servers {
+		server.Routes = slices.DeleteFunc(slices.Clone(server.Routes), func(route models.CaddyRoute) bool {
+			return slices.Contains(ids, route.ID)
+		})
+		copied.Apps.HTTP.Servers[serverName] = server
+	}
+	return &copied
+}
+
+func routeJSON(route models.CaddyRoute) string {
+	raw, _ := json.Marshal(route)
+	return string(raw)
+}
+
+func configJSON(config *models.CaddyConfig) string {
+	raw, _ := json.Marshal(config)
+	return string(raw)
+}
+
+// patchRoute applies a planned route patch, replacing the route in Caddy by its @id so the other
+// routes keep serving untouched, and saves the resulting config
+func (c *Client) patchRoute(patch *routePatch) error {
+	if err := c.writes.begin(); err != nil {
+		return err
+	}
+	defer c.writes.end()
+
+	if err := c.checkForeignChanges(); err != nil {
+		return err
+	}
+
+	if patch.route != nil {
+		routeJSON, err := json.Marshal(patch.route)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPatch, c.BaseURL+"/id/"+url.PathEscape(patch.route.ID), bytes.NewBuffer(routeJSON))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if patch.config.ETag != "" {
+			req.Header.Set("If-Match", patch.config.ETag)
+		}
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return ErrConfigConflict
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to update route %s: %s", patch.route.ID, string(body))
+		}
+	}
+
+	if err := c.saveConfigToFile(patch.config); err != nil {
+		log.Printf("Warning: Failed to save config to file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// ServerSettings returns the protocol settings of the HTTP servers, Caddy's defaults when none were
+// saved
+func (c *Client) ServerSettings() models.ServerSettings {
+	if c.metadata.ServerSettings == nil {
+		return models.ServerSettings{Default: models.DefaultServerProtocols()}
+	}
+	return *c.metadata.ServerSettings
+}
+
+// NormalizeServerSettings validates server settings, dropping overrides equal to the default
+func NormalizeServerSettings(settings *models.ServerSettings) error {
+	for name, protocols := range settings.Servers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t/") {
+			return fmt.Errorf("invalid server name %q", name)
+		}
+		if protocols == settings.Default {
+			delete(settings.Servers, name)
+		}
+	}
+	if len(settings.Servers) == 0 {
+		settings.Servers = nil
+	}
+	return nil
+}
+
+// SaveServerSettings stores the protocol settings and applies them to every HTTP server. Servers
+// created later get them when they're added.
+func (c *Client) SaveServerSettings(settings models.ServerSettings) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	previous := c.metadata.ServerSettings
+	c.metadata.ServerSettings = &settings
+
+	config, err := c.GetConfig()
+	if err != nil {
+		c.metadata.ServerSettings = previous
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if c.applyServerProtocols(config) {
+		if err := c.updateConfig(config); err != nil {
+			c.metadata.ServerSettings = previous
+			return err
+		}
+	}
+
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// ServerNames returns the names of the HTTP servers in Caddy's config
+func (c *Client) ServerNames() ([]string, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+	return slices.Sorted(maps.Keys(config.Apps.HTTP.Servers)), nil
+}
+
+// applyServerProtocols sets the protocols of every server from the saved settings, reporting whether
+// anything changed. Without saved settings the servers are left as they are.
+func (c *Client) applyServerProtocols(config *models.CaddyConfig) bool {
+	if c.metadata.ServerSettings == nil {
+		return false
+	}
+
+	changed := false
+	for serverName, server := range config.Apps.HTTP.Servers {
+		protocols := c.metadata.ServerSettings.ProtocolsFor(serverName).CaddyProtocols()
+		if slices.Equal(server.Protocols, protocols) {
+			continue
+		}
+		changed = true
+
+		server.Protocols = protocols
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+	return changed
+}
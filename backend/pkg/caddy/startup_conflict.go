@@ -0,0 +1,164 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Startup conflict modes decide which config wins when Caddy is already running a config that
+// differs from the manager's saved file
+const (
+	ConflictPreferFile  = "prefer-file"  // Load the saved file into Caddy
+	ConflictPreferCaddy = "prefer-caddy" // Keep Caddy's config and save it as the managed config
+	ConflictFail        = "fail"         // Refuse to start and report the differences
+)
+
+// maxConflictDiffLines caps the number of differences reported for a conflict
+const maxConflictDiffLines = 50
+
+// ValidConflictMode reports whether mode is a supported startup conflict mode
+func ValidConflictMode(mode string) bool {
+	return mode == ConflictPreferFile || mode == ConflictPreferCaddy || mode == ConflictFail
+}
+
+// ConfigConflict describes how Caddy's running config differs from the saved config
+type ConfigConflict struct {
+	Diff      []string // One line per differing JSON path, "-" only in the file, "+" only in Caddy, "~" changed
+	Truncated bool     // More than maxConflictDiffLines paths differ
+}
+
+// StartupConflict compares Caddy's running config with the saved config file. It returns nil when
+// there is nothing to reconcile: no saved file, Caddy running an empty config, or both the same.
+func (c *Client) StartupConflict() (*ConfigConflict, error) {
+	if c.ConfigFile == "" {
+		return nil, nil
+	}
+	saved, err := os.ReadFile(c.ConfigFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	running, err := c.getRawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	var savedValue, runningValue any
+	if err := json.Unmarshal(saved, &savedValue); err != nil {
+		return nil, fmt.Errorf("invalid config file: %v", err)
+	}
+	if err := json.Unmarshal(running, &runningValue); err != nil {
+		return nil, fmt.Errorf("invalid config from Caddy: %v", err)
+	}
+	if emptyConfig(runningValue) {
+		return nil, nil // A fresh Caddy, the saved file is simply restored
+	}
+
+	var diff []string
+	diffJSON("", savedValue, runningValue, &diff)
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	conflict := &ConfigConflict{Diff: diff}
+	if len(diff) > maxConflictDiffLines {
+		conflict.Diff = diff[:maxConflictDiffLines]
+		conflict.Truncated = true
+	}
+	return conflict, nil
+}
+
+// emptyConfig reports whether a config is Caddy's empty config, null or an empty object
+func emptyConfig(value any) bool {
+	if value == nil {
+		return true
+	}
+	object, ok := value.(map[string]any)
+	return ok && len(object) == 0
+}
+
+// diffJSON appends a line for every path where the file's value a differs from Caddy's value b
+func diffJSON(path string, a, b any, diff *[]string) {
+	switch aValue := a.(type) {
+	case map[string]any:
+		if bValue, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(aValue)+len(bValue))
+			for key := range aValue {
+				keys = append(keys, key)
+			}
+			for key := range bValue {
+				if _, exists := aValue[key]; !exists {
+					keys = append(keys, key)
+				}
+			}
+			slices.Sort(keys)
+
+			for _, key := range keys {
+				childA, inA := aValue[key]
+				childB, inB := bValue[key]
+				childPath := joinJSONPath(path, key)
+				switch {
+				case !inB:
+					*diff = append(*diff, "- "+childPath+": "+compactJSON(childA))
+				case !inA:
+					*diff = append(*diff, "+ "+childPath+": "+compactJSON(childB))
+				default:
+					diffJSON(childPath, childA, childB, diff)
+				}
+			}
+			return
+		}
+	case []any:
+		if bValue, ok := b.([]any); ok {
+			for i := 0; i < max(len(aValue), len(bValue)); i++ {
+				childPath := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(bValue):
+					*diff = append(*diff, "- "+childPath+": "+compactJSON(aValue[i]))
+				case i >= len(aValue):
+					*diff = append(*diff, "+ "+childPath+": "+compactJSON(bValue[i]))
+				default:
+					diffJSON(childPath, aValue[i], bValue[i], diff)
+				}
+			}
+			return
+		}
+	}
+
+	aJSON, bJSON := compactJSON(a), compactJSON(b)
+	if aJSON != bJSON {
+		if path == "" {
+			path = "."
+		}
+		*diff = append(*diff, "~ "+path+": "+aJSON+" -> "+bJSON)
+	}
+}
+
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// compactJSON renders a value for a diff line, shortening long values
+func compactJSON(value any) string {
+	const maxLength = 120
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	text := string(raw)
+	if len(text) > maxLength {
+		text = text[:maxLength] + "..."
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,121 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// StatusSnapshot is the most recent result of polling Caddy's upstream status
+type StatusSnapshot struct {
+	Reachable   bool      `json:"caddy_reachable"`
+	Upstreams   any       `json:"upstreams,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	ChangedAt   time.Time `json:"changed_at"` // When the reachability or upstreams last changed
+	Version     uint64    `json:"-"`          // Incremented on every change, used for ETags
+}
+
+// StatusPoller refreshes Caddy status on a fixed schedule so API callers share one cached result
+// instead of each hitting the admin API
+type StatusPoller struct {
+	mu       sync.RWMutex
+	client   *Client
+	interval time.Duration
+	snapshot StatusSnapshot
+	raw      []byte // JSON of the last upstreams, for change detection
+	watchers []func(StatusSnapshot)
+}
+
+// NewStatusPoller creates a poller that refreshes status from client every interval
+func NewStatusPoller(client *Client, interval time.Duration) *StatusPoller {
+	return &StatusPoller{
+		client:   client,
+		interval: interval,
+	}
+}
+
+// Interval returns how often the poller refreshes Caddy status
+func (p *StatusPoller) Interval() time.Duration {
+	return p.interval
+}
+
+// OnReachabilityChange registers a callback run when Caddy becomes reachable or unreachable. The
+// first poll only sets the initial state.
+func (p *StatusPoller) OnReachabilityChange(listener func(StatusSnapshot)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watchers = append(p.watchers, listener)
+}
+
+// Run polls Caddy until ctx is cancelled
+func (p *StatusPoller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.Refresh()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Refresh()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Refresh polls Caddy immediately and returns the updated snapshot
+func (p *StatusPoller) Refresh() StatusSnapshot {
+	upstreams, err := p.client.GetStatus()
+	now := time.Now()
+
+	var raw []byte
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	} else if raw, err = json.Marshal(upstreams); err != nil {
+		raw = nil
+	}
+
+	p.mu.Lock()
+
+	reachable := errMsg == ""
+	var notify []func(StatusSnapshot)
+	if p.snapshot.Version != 0 && reachable != p.snapshot.Reachable {
+		notify = p.watchers
+	}
+	if p.snapshot.Version == 0 || reachable != p.snapshot.Reachable || errMsg != p.snapshot.Error || !bytes.Equal(raw, p.raw) {
+		p.snapshot.Version++
+		p.snapshot.ChangedAt = now
+	}
+
+	p.snapshot.Reachable = reachable
+	p.snapshot.Upstreams = upstreams
+	p.snapshot.Error = errMsg
+	p.snapshot.LastChecked = now
+	p.raw = raw
+
+	snapshot := p.snapshot
+	p.mu.Unlock()
+
+	for _, listener := range notify {
+		listener(snapshot)
+	}
+
+	return snapshot
+}
+
+// Snapshot returns the cached status, polling first if nothing has been fetched yet
+func (p *StatusPoller) Snapshot() StatusSnapshot {
+	p.mu.RLock()
+	snapshot := p.snapshot
+	p.mu.RUnlock()
+
+	if snapshot.Version == 0 {
+		return p.Refresh()
+	}
+	return snapshot
+}
@@ -0,0 +1,204 @@
+package caddy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// storageModuleSources tells users where to get each storage module Caddy must be built with
+var storageModuleSources = map[string]string{
+	models.StorageFileSystem: "standard Caddy",
+	models.StorageRedis:      "github.com/pberkel/caddy-storage-redis",
+	models.StorageConsul:     "github.com/pteich/caddy-tlsconsul",
+}
+
+// caddyStorage holds the fields of the storage modules the manager configures, as Caddy names them
+type caddyStorage struct {
+	Module     string          `json:"module"`
+	Root       string          `json:"root,omitempty"`
+	Address    json.RawMessage `json:"address,omitempty"` // a list for redis, a single address for consul
+	DB         int             `json:"db,omitempty"`
+	Username   string          `json:"username,omitempty"`
+	Password   string          `json:"password,omitempty"`
+	KeyPrefix  string          `json:"key_prefix,omitempty"` // redis
+	Token      string          `json:"token,omitempty"`
+	Prefix     string          `json:"prefix,omitempty"` // consul
+	TLSEnabled bool            `json:"tls_enabled,omitempty"`
+}
+
+// NormalizeStorageSettings validates storage settings, clearing the fields the module doesn't use
+func NormalizeStorageSettings(settings *models.StorageSettings) error {
+	settings.Module = strings.TrimSpace(settings.Module)
+	if settings.Module == "" {
+		settings.Module = models.StorageFileSystem
+	}
+	if _, known := storageModuleSources[settings.Module]; !known {
+		return fmt.Errorf("unsupported storage module %q, expected %s, %s or %s", settings.Module, models.StorageFileSystem, models.StorageRedis, models.StorageConsul)
+	}
+	settings.SecretSet = false
+
+	settings.Prefix = strings.TrimSpace(settings.Prefix)
+	if strings.ContainsAny(settings.Prefix, " \t/") {
+		return fmt.Errorf("storage prefix must not contain spaces or slashes")
+	}
+	for i, address := range settings.Addresses {
+		settings.Addresses[i] = strings.TrimSpace(address)
+		if _, _, err := net.SplitHostPort(settings.Addresses[i]); err != nil {
+			return fmt.Errorf("invalid storage address %q, expected host:port", address)
+		}
+	}
+
+	switch settings.Module {
+	case models.StorageFileSystem:
+		settings.Root = strings.TrimSpace(settings.Root)
+		if settings.Root != "" && !filepath.IsAbs(settings.Root) {
+			return fmt.Errorf("storage root must be an absolute path")
+		}
+		*settings = models.StorageSettings{Module: settings.Module, Root: settings.Root}
+	case models.StorageRedis:
+		if len(settings.Addresses) == 0 {
+			return fmt.Errorf("redis storage needs at least one address")
+		}
+		if settings.DB < 0 {
+			return fmt.Errorf("redis db must not be negative")
+		}
+		settings.Root, settings.Token = "", ""
+	case models.StorageConsul:
+		if len(settings.Addresses) != 1 {
+			return fmt.Errorf("consul storage needs exactly one address")
+		}
+		settings.Root, settings.DB, settings.Username, settings.Password = "", 0, "", ""
+	}
+	return nil
+}
+
+// StorageSettings returns where Caddy keeps certificates, without the password or token. Storage
+// modules the manager doesn't configure are reported by name only.
+func (c *Client) StorageSettings() (models.StorageSettings, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return models.StorageSettings{}, fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	settings, err := storageSettingsFromConfig(config)
+	if err != nil {
+		return models.StorageSettings{}, err
+	}
+	settings.SecretSet = settings.Password != "" || settings.Token != ""
+	settings.Password, settings.Token = "", ""
+	return settings, nil
+}
+
+// SaveStorageSettings points Caddy at new certificate storage. An empty password or token keeps the
+// one configured for the same module. Caddy refuses storage modules it wasn't built with, which is
+// reported along with where to get the module.
+func (c *Client) SaveStorageSettings(settings models.StorageSettings) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	current, err := storageSettingsFromConfig(config)
+	if err != nil {
+		return err
+	}
+	if current.Module == settings.Module {
+		if settings.Password == "" {
+			settings.Password = current.Password
+		}
+		if settings.Token == "" {
+			settings.Token = current.Token
+		}
+	}
+
+	if config.Storage, err = storageConfig(settings); err != nil {
+		return err
+	}
+
+	if err := c.updateConfig(config); err != nil {
+		if strings.Contains(err.Error(), "unknown module") {
+			return fmt.Errorf("Caddy is not built with the %s storage module (%s): %v", settings.Module, storageModuleSources[settings.Module], err)
+		}
+		return err
+	}
+	return nil
+}
+
+// storageSettingsFromConfig reads the storage settings from Caddy's config, file_system in Caddy's
+// default directory when none is set
+func storageSettingsFromConfig(config *models.CaddyConfig) (models.StorageSettings, error) {
+	if len(config.Storage) == 0 || string(config.Storage) == "null" {
+		return models.StorageSettings{Module: models.StorageFileSystem}, nil
+	}
+
+	var storage caddyStorage
+	if err := json.Unmarshal(config.Storage, &storage); err != nil {
+		return models.StorageSettings{}, fmt.Errorf("failed to read storage config: %v", err)
+	}
+
+	settings := models.StorageSettings{
+		Module:   storage.Module,
+		Root:     storage.Root,
+		DB:       storage.DB,
+		Username: storage.Username,
+		Password: storage.Password,
+		Token:    storage.Token,
+		Prefix:   storage.KeyPrefix,
+		TLS:      storage.TLSEnabled,
+	}
+	if storage.Prefix != "" {
+		settings.Prefix = storage.Prefix
+	}
+
+	var address string
+	if json.Unmarshal(storage.Address, &address) == nil && address != "" {
+		settings.Addresses = []string{address}
+	} else {
+		_ = json.Unmarshal(storage.Address, &settings.Addresses)
+	}
+	return settings, nil
+}
+
+// storageConfig renders storage settings as Caddy's storage config, nil for Caddy's default
+// directory
+func storageConfig(settings models.StorageSettings) (json.RawMessage, error) {
+	storage := caddyStorage{Module: settings.Module}
+
+	switch settings.Module {
+	case models.StorageFileSystem:
+		if settings.Root == "" {
+			return nil, nil
+		}
+		storage.Root = settings.Root
+	case models.StorageRedis:
+		address, err := json.Marshal(settings.Addresses)
+		if err != nil {
+			return nil, err
+		}
+		storage.Address = address
+		storage.DB = settings.DB
+		storage.Username = settings.Username
+		storage.Password = settings.Password
+		storage.KeyPrefix = settings.Prefix
+		storage.TLSEnabled = settings.TLS
+	case models.StorageConsul:
+		address, err := json.Marshal(settings.Addresses[0])
+		if err != nil {
+			return nil, err
+		}
+		storage.Address = address
+		storage.Token = settings.Token
+		storage.Prefix = settings.Prefix
+		storage.TLSEnabled = settings.TLS
+	}
+
+	return json.Marshal(storage)
+}
@@ -0,0 +1,303 @@
+package caddy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// NormalizeStream validates a stream proxy, defaulting its protocol to TCP
+func NormalizeStream(stream *models.StreamProxy) error {
+	stream.Protocol = strings.ToLower(strings.TrimSpace(stream.Protocol))
+	if stream.Protocol == "" {
+		stream.Protocol = models.StreamProtocolTCP
+	}
+	if stream.Protocol != models.StreamProtocolTCP && stream.Protocol != models.StreamProtocolUDP {
+		return fmt.Errorf("protocol must be %s or %s", models.StreamProtocolTCP, models.StreamProtocolUDP)
+	}
+
+	stream.Listen = strings.TrimSpace(stream.Listen)
+	if stream.Listen == "" {
+		return fmt.Errorf("listen address is required, e.g. :5432")
+	}
+	if err := ValidateListenAddresses([]string{stream.Listen}); err != nil {
+		return err
+	}
+
+	if len(stream.Upstreams) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	for i, upstream := range stream.Upstreams {
+		upstream = strings.TrimSpace(upstream)
+		host, port, err := net.SplitHostPort(upstream)
+		if err != nil || host == "" {
+			return fmt.Errorf("invalid upstream %q, expected host:port", upstream)
+		}
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return fmt.Errorf("invalid port in upstream %q", upstream)
+		}
+		stream.Upstreams[i] = upstream
+	}
+	return nil
+}
+
+// NewStreamID returns an unused ID for a new stream proxy
+func (c *Client) NewStreamID(stream models.StreamProxy) string {
+	if c.idStrategy == IDStrategyTimestamp {
+		return c.uniqueID(models.GenerateStreamID(stream.Protocol, stream.Listen))
+	}
+	return c.uniqueID(models.GenerateStreamSlug(stream.Protocol, stream.Listen))
+}
+
+// ParseStreamsFromConfig extracts stream proxies from the layer4 app of a Caddy config
+func (c *Client) ParseStreamsFromConfig(config *models.CaddyConfig) []models.StreamProxy {
+	var streams []models.StreamProxy
+	if config.Apps.Layer4 == nil {
+		return streams
+	}
+
+	for _, server := range config.Apps.Layer4.Servers {
+		if len(server.Listen) == 0 {
+			continue
+		}
+		protocol, listen := splitStreamAddress(server.Listen[0])
+
+		for _, route := range server.Routes {
+			// Skip routes without IDs (not created by proxy manager)
+			if route.ID == "" {
+				continue
+			}
+
+			stream := models.StreamProxy{
+				ID:        route.ID,
+				Protocol:  protocol,
+				Listen:    listen,
+				Upstreams: []string{},
+				Status:    "active",
+			}
+			for _, handler := range route.Handle {
+				if handler.Handler != "proxy" {
+					continue
+				}
+				for _, upstream := range handler.Upstreams {
+					if len(upstream.Dial) > 0 {
+						_, dial := splitStreamAddress(upstream.Dial[0])
+						stream.Upstreams = append(stream.Upstreams, dial)
+					}
+				}
+			}
+			c.metadata.ApplyToStream(&stream)
+
+			streams = append(streams, stream)
+		}
+	}
+
+	slices.SortFunc(streams, func(a, b models.StreamProxy) int { return strings.Compare(a.ID, b.ID) })
+	return streams
+}
+
+// GetStream retrieves a stream proxy by ID
+func (c *Client) GetStream(id string) (*models.StreamProxy, error) {
+	config, err := c.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stream := range c.ParseStreamsFromConfig(config) {
+		if stream.ID == id {
+			return &stream, nil
+		}
+	}
+
+	return nil, fmt.Errorf("stream with ID %s not found", id)
+}
+
+// AddStream adds a stream proxy to Caddy's layer4 app
+func (c *Client) AddStream(stream models.StreamProxy) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	if err := addStreamToConfig(config, stream); err != nil {
+		return err
+	}
+	return c.applyStreamConfig(config, stream)
+}
+
+// UpdateStream replaces a stream proxy's listener and upstreams
+func (c *Client) UpdateStream(stream models.StreamProxy) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	if !removeStreamFromConfig(config, stream.ID) {
+		return fmt.Errorf("stream with ID %s not found", stream.ID)
+	}
+	if err := addStreamToConfig(config, stream); err != nil {
+		return err
+	}
+	return c.applyStreamConfig(config, stream)
+}
+
+// DeleteStream removes a stream proxy from Caddy's layer4 app
+func (c *Client) DeleteStream(id string) error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+
+	if !removeStreamFromConfig(config, id) {
+		return fmt.Errorf("stream with ID %s not found", id)
+	}
+	if err := c.updateConfig(config); err != nil {
+		return err
+	}
+
+	c.metadata.DeleteStream(id)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// applyStreamConfig applies a config with a new or changed stream and stores the stream's metadata
+func (c *Client) applyStreamConfig(config *models.CaddyConfig, stream models.StreamProxy) error {
+	if err := c.updateConfig(config); err != nil {
+		if strings.Contains(err.Error(), "unknown module") || strings.Contains(err.Error(), "layer4") {
+			return fmt.Errorf("%v (stream proxies require Caddy to be built with the layer4 app)", err)
+		}
+		return err
+	}
+
+	c.metadata.SetStream(stream)
+	if err := c.saveMetadataToFile(); err != nil {
+		log.Printf("Warning: Failed to save metadata: %v", err)
+	}
+	return nil
+}
+
+// addStreamToConfig adds a layer4 server of its own for a stream, after checking that its listener
+// is free
+func addStreamToConfig(config *models.CaddyConfig, stream models.StreamProxy) error {
+	if err := checkStreamConflicts(config, stream); err != nil {
+		return err
+	}
+
+	upstreams := make([]models.CaddyLayer4Upstream, len(stream.Upstreams))
+	for i, dial := range streamDials(stream) {
+		upstreams[i] = models.CaddyLayer4Upstream{Dial: []string{dial}}
+	}
+
+	if config.Apps.Layer4 == nil {
+		config.Apps.Layer4 = &models.CaddyLayer4{}
+	}
+	if config.Apps.Layer4.Servers == nil {
+		config.Apps.Layer4.Servers = make(map[string]models.CaddyLayer4Server)
+	}
+	config.Apps.Layer4.Servers[stream.ID] = models.CaddyLayer4Server{
+		Listen: []string{stream.Protocol + "/" + stream.Listen},
+		Routes: []models.CaddyLayer4Route{{
+			ID:     stream.ID,
+			Handle: []models.CaddyLayer4Handler{{Handler: "proxy", Upstreams: upstreams}},
+		}},
+	}
+	return nil
+}
+
+// removeStreamFromConfig removes the layer4 server of a stream, reporting whether it was found. The
+// layer4 app is dropped with its last server, so Caddy builds without it keep working.
+func removeStreamFromConfig(config *models.CaddyConfig, id string) bool {
+	if config.Apps.Layer4 == nil {
+		return false
+	}
+
+	for name, server := range config.Apps.Layer4.Servers {
+		if !slices.ContainsFunc(server.Routes, func(route models.CaddyLayer4Route) bool { return route.ID == id }) {
+			continue
+		}
+
+		delete(config.Apps.Layer4.Servers, name)
+		if len(config.Apps.Layer4.Servers) == 0 {
+			config.Apps.Layer4 = nil
+		}
+		return true
+	}
+	return false
+}
+
+// checkStreamConflicts fails when a stream's listener is used by another stream of its protocol or
+// by an HTTP server. UDP is checked against HTTP servers too, since they serve HTTP/3 over UDP.
+func checkStreamConflicts(config *models.CaddyConfig, stream models.StreamProxy) error {
+	if config.Apps.Layer4 != nil {
+		for name, server := range config.Apps.Layer4.Servers {
+			if name == stream.ID {
+				continue
+			}
+			for _, used := range server.Listen {
+				protocol, address := splitStreamAddress(used)
+				if protocol == stream.Protocol && listenersOverlap(stream.Listen, address) {
+					return fmt.Errorf("listen address %s conflicts with %s used by stream %s", stream.Listen, address, name)
+				}
+			}
+		}
+	}
+
+	for name, server := range config.Apps.HTTP.Servers {
+		for _, used := range server.Listen {
+			if listenersOverlap(stream.Listen, used) {
+				return fmt.Errorf("listen address %s conflicts with %s used by server %s", stream.Listen, used, name)
+			}
+		}
+	}
+	return nil
+}
+
+// splitStreamAddress splits a layer4 network address such as udp/:53 into its protocol and address,
+// which is TCP when no protocol is given
+func splitStreamAddress(address string) (string, string) {
+	if protocol, rest, found := strings.Cut(address, "/"); found {
+		return protocol, rest
+	}
+	return models.StreamProtocolTCP, address
+}
+
+// streamListeners returns the listen address of every stream in config's layer4 app
+func streamListeners(config *models.CaddyConfig) []models.StreamProxy {
+	var streams []models.StreamProxy
+	if config.Apps.Layer4 == nil {
+		return streams
+	}
+
+	for name, server := range config.Apps.Layer4.Servers {
+		for _, address := range server.Listen {
+			protocol, listen := splitStreamAddress(address)
+			streams = append(streams, models.StreamProxy{ID: name, Protocol: protocol, Listen: listen})
+		}
+	}
+	return streams
+}
+
+// streamDials returns the upstreams of a stream as layer4 dial addresses
+func streamDials(stream models.StreamProxy) []string {
+	dials := make([]string, len(stream.Upstreams))
+	for i, upstream := range stream.Upstreams {
+		dials[i] = stream.Protocol + "/" + upstream
+	}
+	return dials
+}
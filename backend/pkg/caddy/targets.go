@@ -0,0 +1,112 @@
+package caddy
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/hostname"
+)
+
+// NormalizeTargetURL validates an http or https target URL and returns it with its scheme and an
+// ASCII host. Port-only targets such as "8080" or ":8080" point at localhost, IPv6 addresses must be
+// in brackets as in http://[fd00::1]:8080, and internationalized hostnames are converted to
+// punycode. Templated targets are returned unchanged, they're checked once resolved.
+func NormalizeTargetURL(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" || strings.Contains(target, "{{") {
+		return target, nil
+	}
+
+	u, err := parseTarget(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid target URL %s: %v", target, err)
+	}
+	return u.String(), nil
+}
+
+// parseTarget parses a target URL, assuming http when it has no scheme, and validates its host and
+// port
+func parseTarget(target string) (*url.URL, error) {
+	target = strings.TrimSpace(target)
+	if port := strings.TrimPrefix(target, ":"); isNumber(port) {
+		if !isPort(port) {
+			return nil, fmt.Errorf("port %s is out of range", port)
+		}
+		target = "localhost:" + port
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %v", err)
+	}
+	// WebSocket upstreams are dialed like any other, Caddy proxies the upgrade
+	switch u.Scheme {
+	case "http", "https":
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s, use http or https", u.Scheme)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if port != "" && !isPort(port) {
+		return nil, fmt.Errorf("port %s is out of range", port)
+	}
+
+	switch {
+	case host == "":
+		host = "localhost"
+	case strings.Contains(host, ":") && !strings.HasPrefix(u.Host, "["):
+		return nil, fmt.Errorf("IPv6 addresses must be in brackets, e.g. http://[fd00::1]:8080")
+	case strings.Contains(host, ":"):
+		if _, err := netip.ParseAddr(host); err != nil {
+			return nil, fmt.Errorf("invalid IPv6 address %s", host)
+		}
+	case !validUpstreamHost(host):
+		ascii, err := hostname.Normalize(host, false)
+		if err != nil {
+			return nil, err
+		}
+		host = ascii
+	}
+
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	return u, nil
+}
+
+// validUpstreamHost reports whether host is an IPv4 address or an ASCII hostname. Underscores are
+// allowed, as container and service names often have them.
+func validUpstreamHost(host string) bool {
+	for _, r := range host {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(host, ".") && !strings.HasPrefix(host, "-") && !strings.Contains(host, "..")
+}
+
+// isNumber reports whether s is made of ASCII digits only
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// isPort reports whether s is a port number
+func isPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 1 && n <= 65535 && s == strconv.Itoa(n)
+}
@@ -0,0 +1,90 @@
+package caddy
+
+import "testing"
+
+func TestNormalizeTargetURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", target: "", want: ""},
+		{name: "templated", target: "{{.Host}}:80", want: "{{.Host}}:80"},
+		{name: "surrounding space", target: " app:80 ", want: "http://app:80"},
+		{name: "host and port", target: "app:80", want: "http://app:80"},
+		{name: "https", target: "https://app:8443/base", want: "https://app:8443/base"},
+		{name: "underscore host", target: "my_service:8080", want: "http://my_service:8080"},
+
+		{name: "port only", target: "8080", want: "http://localhost:8080"},
+		{name: "colon port", target: ":8080", want: "http://localhost:8080"},
+		{name: "scheme and port", target: "http://:8080", want: "http://localhost:8080"},
+
+		{name: "ipv6", target: "http://[fd00::1]:8080", want: "http://[fd00::1]:8080"},
+		{name: "ipv6 without scheme", target: "[fd00::1]:8080", want: "http://[fd00::1]:8080"},
+		{name: "ipv6 without port", target: "http://[::1]", want: "http://[::1]"},
+		{name: "ipv6 zone", target: "http://[fe80::1%25eth0]:8080", want: "http://[fe80::1%25eth0]:8080"},
+		{name: "ipv6 zone without port", target: "http://[fe80::1%25eth0]", want: "http://[fe80::1%25eth0]"},
+		{name: "ipv6 without brackets", target: "http://fd00::1", wantErr: true},
+		{name: "invalid ipv6", target: "http://[zz::1]:80", wantErr: true},
+
+		{name: "unicode host", target: "http://bücher.example:8080", want: "http://xn--bcher-kva.example:8080"},
+		{name: "punycode host", target: "https://xn--bcher-kva.example", want: "https://xn--bcher-kva.example"},
+		{name: "leading hyphen", target: "http://-bad", wantErr: true},
+		{name: "empty label", target: "http://a..b", wantErr: true},
+		{name: "space in host", target: "http://bad host", wantErr: true},
+
+		{name: "ws", target: "ws://chat:9000", want: "http://chat:9000"},
+		{name: "wss", target: "wss://chat", want: "https://chat"},
+		{name: "unsupported scheme", target: "ftp://files", wantErr: true},
+
+		{name: "port zero", target: "http://app:0", wantErr: true},
+		{name: "port too large", target: "http://app:65536", wantErr: true},
+		{name: "port with leading zero", target: "http://app:08080", wantErr: true},
+		{name: "port only zero", target: ":0", wantErr: true},
+		{name: "port only too large", target: "70000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeTargetURL(tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NormalizeTargetURL(%q) = %q, want an error", tt.target, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeTargetURL(%q) failed: %v", tt.target, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeTargetURL(%q) = %q, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargetHostAndPort(t *testing.T) {
+	tests := []struct {
+		target   string
+		hostname string
+		port     string
+	}{
+		{target: "8080", hostname: "localhost", port: "8080"},
+		{target: "app", hostname: "app", port: ""},
+		{target: "http://[fe80::1%25eth0]:8080", hostname: "fe80::1%eth0", port: "8080"},
+		{target: "wss://bücher.example", hostname: "xn--bcher-kva.example", port: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			u, err := parseTarget(tt.target)
+			if err != nil {
+				t.Fatalf("parseTarget(%q) failed: %v", tt.target, err)
+			}
+			if u.Hostname() != tt.hostname || u.Port() != tt.port {
+				t.Errorf("parseTarget(%q) = host %q port %q, want host %q port %q", tt.target, u.Hostname(), u.Port(), tt.hostname, tt.port)
+			}
+		})
+	}
+}
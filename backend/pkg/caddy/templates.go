@@ -0,0 +1,63 @@
+package caddy
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/templating"
+)
+
+// ResolveTemplates returns a copy of proxy with templates in its targets and custom header values,
+// including those of its path rules, expanded. The stored proxy keeps the templates; only the
+// generated Caddy config gets the values.
+func ResolveTemplates(proxy models.Proxy) (models.Proxy, error) {
+	var err error
+	if proxy.TargetURL, err = templating.Expand(proxy.TargetURL); err != nil {
+		return proxy, err
+	}
+	if proxy.BackupTargetURL, err = templating.Expand(proxy.BackupTargetURL); err != nil {
+		return proxy, err
+	}
+
+	if len(proxy.TargetURLs) > 0 {
+		targets := make([]string, len(proxy.TargetURLs))
+		for i, target := range proxy.TargetURLs {
+			if targets[i], err = templating.Expand(target); err != nil {
+				return proxy, err
+			}
+		}
+		proxy.TargetURLs = targets
+	}
+
+	if len(proxy.CustomHeaders) > 0 {
+		headers := maps.Clone(proxy.CustomHeaders)
+		for name, value := range headers {
+			if headers[name], err = templating.Expand(value); err != nil {
+				return proxy, err
+			}
+		}
+		proxy.CustomHeaders = headers
+	}
+
+	if len(proxy.PathRules) > 0 {
+		rules := slices.Clone(proxy.PathRules)
+		for i, rule := range rules {
+			if rules[i].TargetURL, err = templating.Expand(rule.TargetURL); err != nil {
+				return proxy, err
+			}
+			if len(rule.CustomHeaders) > 0 {
+				headers := maps.Clone(rule.CustomHeaders)
+				for name, value := range headers {
+					if headers[name], err = templating.Expand(value); err != nil {
+						return proxy, err
+					}
+				}
+				rules[i].CustomHeaders = headers
+			}
+		}
+		proxy.PathRules = rules
+	}
+
+	return proxy, nil
+}
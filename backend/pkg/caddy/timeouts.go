@@ -0,0 +1,77 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// NormalizeTimeouts validates a proxy's timeouts, flush interval and request body limit, dropping
+// timeouts that are all unset
+func NormalizeTimeouts(proxy *models.Proxy) error {
+	if timeouts := proxy.Timeouts; timeouts != nil {
+		for _, timeout := range []struct {
+			name  string
+			value *string
+		}{{"dial", &timeouts.Dial}, {"read", &timeouts.Read}, {"write", &timeouts.Write}} {
+			*timeout.value = strings.TrimSpace(*timeout.value)
+			if *timeout.value == "" {
+				continue
+			}
+			if duration, err := time.ParseDuration(*timeout.value); err != nil || duration <= 0 {
+				return fmt.Errorf("timeouts.%s must be a positive duration such as 30s or 10m", timeout.name)
+			}
+		}
+		if *timeouts == (models.Timeouts{}) {
+			proxy.Timeouts = nil
+		}
+	}
+
+	proxy.FlushInterval = strings.TrimSpace(proxy.FlushInterval)
+	if proxy.FlushInterval != "" {
+		if _, err := time.ParseDuration(proxy.FlushInterval); err != nil {
+			return fmt.Errorf("flush_interval must be a duration such as 100ms, or -1s to flush immediately")
+		}
+	}
+
+	if proxy.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	return nil
+}
+
+// applyTimeouts sets a proxy's upstream timeouts and flush interval on one of its reverse_proxy
+// handlers. The flush interval overrides the one gRPC sets.
+func applyTimeouts(handler *models.CaddyHandler, proxy models.Proxy) {
+	if timeouts := proxy.Timeouts; timeouts != nil {
+		if handler.Transport == nil {
+			handler.Transport = &models.CaddyTransport{Protocol: "http"}
+		}
+		handler.Transport.DialTimeout = timeouts.Dial
+		handler.Transport.ReadTimeout = timeouts.Read
+		handler.Transport.WriteTimeout = timeouts.Write
+	}
+	if proxy.FlushInterval != "" {
+		handler.FlushInterval = proxy.FlushInterval
+	}
+}
+
+// requestBodyHandler limits the size of request bodies, nil when the proxy has no limit
+func requestBodyHandler(proxy models.Proxy) *models.CaddyHandler {
+	if proxy.MaxBodySize <= 0 {
+		return nil
+	}
+	return &models.CaddyHandler{Handler: "request_body", MaxSize: proxy.MaxBodySize}
+}
+
+// isolatedServerTimeouts returns the client timeouts of an isolated proxy's server. With the server to
+// itself, the proxy's read and write timeouts also bound reading the client's request body and
+// writing the response; shared servers keep Caddy's defaults, which don't time out.
+func isolatedServerTimeouts(proxy models.Proxy) *models.CaddyServerTimeouts {
+	if proxy.Timeouts == nil || (proxy.Timeouts.Read == "" && proxy.Timeouts.Write == "") {
+		return nil
+	}
+	return &models.CaddyServerTimeouts{ReadBody: proxy.Timeouts.Read, Write: proxy.Timeouts.Write}
+}
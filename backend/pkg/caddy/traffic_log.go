@@ -0,0 +1,167 @@
+package caddy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// trafficLogName is the Caddy log the access logs of every server are written to for traffic
+	// statistics
+	trafficLogName = "cpm_traffic"
+	// accessLoggers matches the access loggers of every server and host
+	accessLoggers        = "http.log.access"
+	trafficLogRollSizeMB = 50
+)
+
+// TrafficLogFile returns the path Caddy writes the access log read for traffic statistics to
+func (c *Client) TrafficLogFile() (string, error) {
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "access"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve access log directory: %v", err)
+	}
+	return filepath.Join(dir, "access.log"), nil
+}
+
+// SetTrafficLog enables or disables writing access logs for traffic statistics. It takes effect
+// with the next config write, or ApplyTrafficLog.
+func (c *Client) SetTrafficLog(enabled bool) error {
+	if !enabled {
+		c.trafficLog = ""
+		return nil
+	}
+
+	file, err := c.TrafficLogFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create access log directory: %v", err)
+	}
+	c.trafficLog = file
+	return nil
+}
+
+// ApplyTrafficLog adds or removes the traffic access log in Caddy's running config. Caddy is only
+// reloaded when that changes it.
+func (c *Client) ApplyTrafficLog() error {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config, err := c.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get current config: %v", err)
+	}
+	if !c.applyTrafficLog(config) {
+		return nil
+	}
+	return c.updateConfig(config)
+}
+
+// applyTrafficLog writes the access logs of every server to the traffic log as JSON, or removes
+// that log when traffic statistics are off, reporting whether anything changed. Access logs stay
+// out of the default log, as debug captures keep theirs.
+func (c *Client) applyTrafficLog(config *models.CaddyConfig) bool {
+	before := trafficLogState(config)
+
+	if c.trafficLog == "" {
+		removeTrafficLog(config)
+		return !reflect.DeepEqual(before, trafficLogState(config))
+	}
+
+	if config.Logging == nil {
+		config.Logging = &models.CaddyLogging{}
+	}
+	if config.Logging.Logs == nil {
+		config.Logging.Logs = make(map[string]models.CaddyLog)
+	}
+
+	roll := true
+	config.Logging.Logs[trafficLogName] = models.CaddyLog{
+		Writer: &models.CaddyLogWriter{
+			Output:     "file",
+			Filename:   c.trafficLog,
+			Roll:       &roll,
+			RollSizeMB: trafficLogRollSizeMB,
+			RollKeep:   1,
+		},
+		Encoder: &models.CaddyLogEncoder{Format: "json"},
+		Include: []string{accessLoggers},
+	}
+
+	defaultLog := config.Logging.Logs[defaultLogName]
+	if !slices.Contains(defaultLog.Exclude, accessLoggers) {
+		defaultLog.Exclude = append(defaultLog.Exclude, accessLoggers)
+	}
+	config.Logging.Logs[defaultLogName] = defaultLog
+
+	// Servers only write access logs when they have logging configured, which then covers every
+	// host unless it is limited to the hosts of debug captures
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.Logs == nil {
+			server.Logs = &models.CaddyServerLogs{}
+		}
+		server.Logs.SkipUnmappedHosts = false
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+
+	return !reflect.DeepEqual(before, trafficLogState(config))
+}
+
+// removeTrafficLog undoes applyTrafficLog. Servers keep logging only the hosts of debug captures.
+func removeTrafficLog(config *models.CaddyConfig) {
+	if config.Logging != nil {
+		delete(config.Logging.Logs, trafficLogName)
+
+		if defaultLog, exists := config.Logging.Logs[defaultLogName]; exists {
+			defaultLog.Exclude = slices.DeleteFunc(defaultLog.Exclude, func(name string) bool { return name == accessLoggers })
+			if defaultLog.Writer == nil && defaultLog.Encoder == nil && defaultLog.Level == "" &&
+				len(defaultLog.Include) == 0 && len(defaultLog.Exclude) == 0 {
+				delete(config.Logging.Logs, defaultLogName)
+			} else {
+				config.Logging.Logs[defaultLogName] = defaultLog
+			}
+		}
+
+		if len(config.Logging.Logs) == 0 {
+			config.Logging = nil
+		}
+	}
+
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.Logs == nil || server.Logs.SkipUnmappedHosts {
+			continue
+		}
+		if len(server.Logs.LoggerNames) > 0 {
+			server.Logs.SkipUnmappedHosts = true
+		} else if server.Logs.DefaultLoggerName == "" && len(server.Logs.SkipHosts) == 0 {
+			server.Logs = nil
+		}
+		config.Apps.HTTP.Servers[serverName] = server
+	}
+}
+
+// trafficLogState is the part of a config applyTrafficLog changes, to tell whether it did
+func trafficLogState(config *models.CaddyConfig) any {
+	logs := make(map[string]models.CaddyServerLogs)
+	for serverName, server := range config.Apps.HTTP.Servers {
+		if server.Logs != nil {
+			logs[serverName] = *server.Logs
+		}
+	}
+	var logging models.CaddyLogging
+	if config.Logging != nil {
+		logging = *config.Logging
+	}
+	return []any{logging.Logs[trafficLogName], logging.Logs[defaultLogName], logs}
+}
+
+// TrafficLogEnabled reports whether Caddy writes access logs for traffic statistics
+func (c *Client) TrafficLogEnabled() bool {
+	return c.trafficLog != ""
+}
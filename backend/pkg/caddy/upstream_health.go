@@ -0,0 +1,121 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/health"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// defaultUnhealthyStatus is what passive health checks count as failed requests unless told otherwise
+var defaultUnhealthyStatus = []int{502, 503, 504}
+
+// NormalizeUpstreamHealth validates a proxy's Caddy health checks, dropping them when neither kind is
+// enabled
+func NormalizeUpstreamHealth(proxy *models.Proxy) error {
+	health := proxy.UpstreamHealth
+	if health == nil {
+		return nil
+	}
+	if !health.Active && !health.Passive {
+		proxy.UpstreamHealth = nil
+		return nil
+	}
+	if proxy.UpstreamType == UpstreamTypeFastCGI {
+		return fmt.Errorf("upstream_health needs an http upstream")
+	}
+
+	if health.MaxFails < 0 {
+		return fmt.Errorf("upstream_health.max_fails must not be negative")
+	}
+	health.FailDuration = strings.TrimSpace(health.FailDuration)
+	if health.FailDuration != "" {
+		if duration, err := time.ParseDuration(health.FailDuration); err != nil || duration <= 0 {
+			return fmt.Errorf("upstream_health.fail_duration must be a positive duration such as 30s")
+		}
+	}
+	for _, status := range health.UnhealthyStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("upstream_health.unhealthy_status has an invalid status code: %d", status)
+		}
+	}
+	return nil
+}
+
+// applyUpstreamHealth sets a proxy's Caddy health checks on its reverse_proxy handler. Its passive
+// settings replace the ones load balancing and failover use by default.
+func applyUpstreamHealth(handler *models.CaddyHandler, proxy models.Proxy) {
+	health := proxy.UpstreamHealth
+	if health == nil {
+		return
+	}
+	if handler.HealthChecks == nil {
+		handler.HealthChecks = &models.CaddyHealthChecks{}
+	}
+
+	if health.Active {
+		handler.HealthChecks.Active = activeHealthChecks(proxy)
+	}
+	if health.Passive {
+		handler.HealthChecks.Passive = passiveHealthChecks(*health)
+	}
+}
+
+// activeHealthChecks probes upstreams the way the manager's health checks do. Health check headers
+// are kept encrypted by the manager and aren't written to Caddy's config, only the User-Agent is.
+func activeHealthChecks(proxy models.Proxy) *models.CaddyActiveHealthChecks {
+	active := &models.CaddyActiveHealthChecks{
+		URI:          proxy.HealthCheckPath,
+		Interval:     proxy.HealthCheckInterval,
+		Method:       proxy.HealthCheckMethod,
+		Body:         proxy.HealthCheckRequestBody,
+		ExpectStatus: expectStatus(proxy),
+		ExpectBody:   health.BodyPattern(proxy),
+	}
+	if active.URI == "" {
+		active.URI = "/"
+	}
+	if proxy.HealthCheckUserAgent != "" {
+		active.Headers = map[string][]string{"User-Agent": {proxy.HealthCheckUserAgent}}
+	}
+	return active
+}
+
+// passiveHealthChecks fills in the defaults of unset passive health check settings
+func passiveHealthChecks(health models.UpstreamHealth) *models.CaddyPassiveHealthChecks {
+	passive := &models.CaddyPassiveHealthChecks{
+		FailDuration:    health.FailDuration,
+		MaxFails:        health.MaxFails,
+		UnhealthyStatus: health.UnhealthyStatus,
+	}
+	if passive.FailDuration == "" {
+		passive.FailDuration = failoverFailDuration
+	}
+	if passive.MaxFails == 0 {
+		passive.MaxFails = 1
+	}
+	if len(passive.UnhealthyStatus) == 0 {
+		passive.UnhealthyStatus = defaultUnhealthyStatus
+	}
+	return passive
+}
+
+// expectStatus returns the status Caddy's active checks expect. Caddy matches one code or one class,
+// so a status range other than a single code or a whole class such as 200-299 falls back to the
+// expected status.
+func expectStatus(proxy models.Proxy) int {
+	ranges, err := health.ParseStatusRanges(proxy.HealthCheckStatusRange)
+	if err != nil || len(ranges) != 1 {
+		return proxy.HealthCheckExpectedStatus
+	}
+	switch r := ranges[0]; {
+	case r.Min == r.Max:
+		return r.Min
+	case r.Min%100 == 0 && r.Max == r.Min+99:
+		return r.Min / 100
+	default:
+		return proxy.HealthCheckExpectedStatus
+	}
+}
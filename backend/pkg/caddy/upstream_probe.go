@@ -0,0 +1,188 @@
+package caddy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// probeServer is the Caddy server an upstream probe adds for as long as it runs
+	probeServer = "cpm_upstream_probe"
+	// probeErrorHeader marks responses where Caddy itself failed to reach the upstream, as opposed
+	// to the upstream answering with an error status
+	probeErrorHeader = "X-Cpm-Probe-Error"
+)
+
+// upstreamProbe is where Caddy listens for upstream probes and how the manager connects to it
+type upstreamProbe struct {
+	mu      sync.Mutex // one probe at a time, they share the listener
+	listen  string     // e.g. ":2020"
+	connect string     // e.g. "caddy:2020"
+	client  *http.Client
+}
+
+// UpstreamProbe is the result of Caddy requesting a target
+type UpstreamProbe struct {
+	Reachable bool   `json:"reachable"`
+	Status    int    `json:"status,omitempty"` // the status the upstream answered with
+	Error     string `json:"error,omitempty"`  // why Caddy couldn't reach it
+}
+
+// SetProbeAddress enables upstream probes: Caddy runs them on a temporary server listening on port,
+// which the manager reaches on host
+func (c *Client) SetProbeAddress(host, port string) {
+	c.probe = &upstreamProbe{
+		listen:  ":" + port,
+		connect: net.JoinHostPort(host, port),
+		client: &http.Client{
+			Timeout:   15 * time.Second,
+			Transport: &http.Transport{Proxy: nil},
+		},
+	}
+}
+
+// ProbeUpstream has Caddy request target, a proxy target URL, to tell whether Caddy can reach it
+// from its own network. A temporary server matching only a random host name is added for the
+// request and removed afterwards. Errors mean the probe couldn't run, not that the target is down.
+func (c *Client) ProbeUpstream(ctx context.Context, target string) (*UpstreamProbe, error) {
+	if c.probe == nil {
+		return nil, fmt.Errorf("upstream probes are disabled, set CADDY_PROBE_PORT")
+	}
+	dialAddr, useHTTPS, _, err := parseTargetURL(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %s: %v", target, err)
+	}
+
+	if err := c.writes.begin(); err != nil {
+		return nil, err
+	}
+	defer c.writes.end()
+
+	c.probe.mu.Lock()
+	defer c.probe.mu.Unlock()
+
+	token := make([]byte, 12)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	host := hex.EncodeToString(token) + ".probe.invalid"
+
+	if err := c.putProbeServer(probeServerConfig(c.probe.listen, host, dialAddr, useHTTPS)); err != nil {
+		return nil, fmt.Errorf("failed to add probe server to Caddy: %v", err)
+	}
+	defer c.deleteProbeServer()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+c.probe.connect+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Host = host
+
+	resp, err := c.probe.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Caddy's probe server at %s: %v", c.probe.connect, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get(probeErrorHeader) != "" {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &UpstreamProbe{Error: strings.TrimSpace(string(message))}, nil
+	}
+	return &UpstreamProbe{Reachable: true, Status: resp.StatusCode}, nil
+}
+
+// probeServerConfig is a plain HTTP server proxying requests for host to the upstream, answering
+// with probeErrorHeader set when Caddy can't reach it
+func probeServerConfig(listen, host, dialAddr string, useHTTPS bool) models.CaddyServer {
+	transport := &models.CaddyTransport{Protocol: "http", DialTimeout: "5s"}
+	if useHTTPS {
+		// Only reachability is probed, certificate problems show up in the proxy's own requests
+		transport.TLS = &models.CaddyTransportTLS{InsecureSkipVerify: true}
+	}
+
+	return models.CaddyServer{
+		Listen: []string{listen},
+		Routes: []models.CaddyRoute{{
+			Match: []models.CaddyMatch{{Host: []string{host}}},
+			Handle: []models.CaddyHandler{{
+				Handler:   "reverse_proxy",
+				Upstreams: []models.CaddyUpstream{{Dial: dialAddr}},
+				Transport: transport,
+			}},
+		}},
+		AutomaticHTTPS: &models.CaddyAutomaticHTTPS{Disable: true},
+		Errors: &models.CaddyServerErrors{Routes: []models.CaddyErrorRoute{{
+			Handle: []models.CaddyErrorHandler{{
+				Handler:    "static_response",
+				StatusCode: "{http.error.status_code}",
+				Headers:    map[string][]string{probeErrorHeader: {"true"}},
+				Body:       "{http.error.message}",
+			}},
+		}}},
+	}
+}
+
+// putProbeServer adds the probe server to Caddy's running config, without saving it
+func (c *Client) putProbeServer(server models.CaddyServer) error {
+	// Adding or removing the probe server changes the config's ETag, so it waits for changes in progress
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	body, err := json.Marshal(server)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/config/apps/http/servers/"+probeServer, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("caddy API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// deleteProbeServer removes the probe server. It is already gone when a config was loaded meanwhile.
+func (c *Client) deleteProbeServer() {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	req, err := http.NewRequest(http.MethodDelete, c.BaseURL+"/config/apps/http/servers/"+probeServer, nil)
+	if err != nil {
+		return
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		log.Printf("Warning: Failed to remove the upstream probe server from Caddy: %v", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// TargetDialAddress returns the host and port Caddy dials for a target URL
+func TargetDialAddress(target string) (string, error) {
+	dialAddr, _, _, err := parseTargetURL(target)
+	return dialAddr, err
+}
+
+// ProbeEnabled reports whether upstream probes are configured
+func (c *Client) ProbeEnabled() bool {
+	return c.probe != nil
+}
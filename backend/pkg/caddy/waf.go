@@ -0,0 +1,258 @@
+package caddy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+const (
+	// WAFHandler is the Caddy handler module of the Coraza WAF. It is not part of standard Caddy,
+	// so Caddy must be built with github.com/corazawaf/coraza-caddy.
+	WAFHandler = "waf"
+
+	// DefaultWAFParanoiaLevel is the CRS paranoia level used when none is set
+	DefaultWAFParanoiaLevel = 1
+
+	// maxWAFLogLine bounds a single audit log entry, which holds the request headers. Larger ones
+	// are skipped.
+	maxWAFLogLine = 1 << 20
+)
+
+// WAFViolation is a request the WAF matched rules for, read from a proxy's audit log
+type WAFViolation struct {
+	ProxyID       string         `json:"proxy_id"`
+	Time          string         `json:"time"`
+	TransactionID string         `json:"transaction_id"`
+	ClientIP      string         `json:"client_ip"`
+	Method        string         `json:"method"`
+	URI           string         `json:"uri"`
+	Status        int            `json:"status"`
+	Blocked       bool           `json:"blocked"` // false in detection-only mode
+	Rules         []WAFRuleMatch `json:"rules"`
+}
+
+// WAFRuleMatch is a rule that matched a request
+type WAFRuleMatch struct {
+	ID       int    `json:"id"`
+	Message  string `json:"message"`
+	Severity int    `json:"severity"` // 0 (emergency) to 7 (debug)
+	Data     string `json:"data,omitempty"`
+}
+
+// corazaAuditEntry is the part of a Coraza JSON audit log entry the violations view uses
+type corazaAuditEntry struct {
+	Transaction struct {
+		UnixTimestamp int64  `json:"unix_timestamp"` // nanoseconds
+		ID            string `json:"id"`
+		ClientIP      string `json:"client_ip"`
+		Request       struct {
+			Method string `json:"method"`
+			URI    string `json:"uri"`
+		} `json:"request"`
+		Response struct {
+			Status int `json:"status"`
+		} `json:"response"`
+		IsInterrupted bool `json:"is_interrupted"`
+	} `json:"transaction"`
+	Messages []struct {
+		Message string `json:"message"`
+		Data    struct {
+			ID       int    `json:"id"`
+			Msg      string `json:"msg"`
+			Data     string `json:"data"`
+			Severity int    `json:"severity"`
+		} `json:"data"`
+	} `json:"messages"`
+}
+
+// NormalizeWAF validates a proxy's WAF settings, filling in the defaults
+func NormalizeWAF(waf *models.WAFSettings) error {
+	if waf == nil || !waf.Enabled {
+		return nil
+	}
+
+	if waf.RuleSet == "" {
+		waf.RuleSet = models.WAFRuleSetCRS
+	}
+	if waf.RuleSet != models.WAFRuleSetCRS && waf.RuleSet != models.WAFRuleSetRecommended {
+		return fmt.Errorf("WAF rule_set must be %s or %s", models.WAFRuleSetCRS, models.WAFRuleSetRecommended)
+	}
+
+	if waf.ParanoiaLevel == 0 {
+		waf.ParanoiaLevel = DefaultWAFParanoiaLevel
+	}
+	if waf.ParanoiaLevel < 1 || waf.ParanoiaLevel > 4 {
+		return fmt.Errorf("WAF paranoia_level must be between 1 and 4")
+	}
+	return nil
+}
+
+// wafEnabled reports whether a proxy runs the WAF
+func wafEnabled(proxy models.Proxy) bool {
+	return proxy.WAF != nil && proxy.WAF.Enabled
+}
+
+// WAFLogFile returns the path of a proxy's WAF audit log
+func (c *Client) WAFLogFile(proxyID string) (string, error) {
+	if proxyID == "" || filepath.Base(proxyID) != proxyID {
+		return "", fmt.Errorf("invalid proxy ID")
+	}
+
+	dir, err := filepath.Abs(filepath.Join(filepath.Dir(c.ConfigFile), "waf"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve WAF log directory: %v", err)
+	}
+
+	return filepath.Join(dir, proxyID+".log"), nil
+}
+
+// buildWAFHandler creates the Coraza handler for a proxy, which writes the requests it matches rules
+// for to the proxy's audit log
+func (c *Client) buildWAFHandler(proxy models.Proxy) (*models.CaddyHandler, error) {
+	logFile, err := c.WAFLogFile(proxy.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create WAF log directory: %v", err)
+	}
+
+	return &models.CaddyHandler{
+		Handler:      WAFHandler,
+		Directives:   wafDirectives(*proxy.WAF, logFile),
+		LoadOWASPCRS: true,
+	}, nil
+}
+
+// wafDirectives returns the SecLang directives for WAF settings. The engine and audit log settings
+// come last, overriding those of the included recommended configuration.
+func wafDirectives(waf models.WAFSettings, logFile string) string {
+	directives := []string{"Include @coraza.conf-recommended"}
+	if waf.RuleSet == models.WAFRuleSetCRS {
+		directives = append(directives,
+			"Include @crs-setup.conf.example",
+			fmt.Sprintf(`SecAction "id:900000,phase:1,pass,t:none,nolog,setvar:tx.blocking_paranoia_level=%d"`, waf.ParanoiaLevel),
+			"Include @owasp_crs/*.conf",
+		)
+	}
+
+	engine := "On"
+	if waf.DetectionOnly {
+		engine = "DetectionOnly"
+	}
+	directives = append(directives,
+		"SecRuleEngine "+engine,
+		"SecAuditEngine RelevantOnly",
+		"SecAuditLogParts ABHZ",
+		"SecAuditLogType Serial",
+		"SecAuditLogFormat JSON",
+		"SecAuditLog "+logFile,
+	)
+	return strings.Join(directives, "\n")
+}
+
+// WAFViolations returns the most recent violations in the audit logs of the given proxies, newest
+// first. Entries that can't be parsed are skipped.
+func (c *Client) WAFViolations(proxyIDs []string, limit int) ([]WAFViolation, error) {
+	var violations []WAFViolation
+	for _, proxyID := range proxyIDs {
+		proxyViolations, err := c.readWAFLog(proxyID, limit)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, proxyViolations...)
+	}
+
+	slices.SortStableFunc(violations, func(a, b WAFViolation) int { return strings.Compare(b.Time, a.Time) })
+	if len(violations) > limit {
+		violations = violations[:limit]
+	}
+	return violations, nil
+}
+
+// readWAFLog reads the last limit violations from a proxy's audit log
+func (c *Client) readWAFLog(proxyID string, limit int) ([]WAFViolation, error) {
+	logFile, err := c.WAFLogFile(proxyID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(logFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAF log of proxy %s: %v", proxyID, err)
+	}
+	defer file.Close()
+
+	var violations []WAFViolation
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read WAF log of proxy %s: %v", proxyID, err)
+		}
+		if len(line) == 0 && err != nil {
+			break
+		}
+
+		var entry corazaAuditEntry
+		if len(line) > maxWAFLogLine || json.Unmarshal(line, &entry) != nil || len(entry.Messages) == 0 {
+			continue
+		}
+
+		violation := WAFViolation{
+			ProxyID:       proxyID,
+			Time:          time.Unix(0, entry.Transaction.UnixTimestamp).UTC().Format(time.RFC3339),
+			TransactionID: entry.Transaction.ID,
+			ClientIP:      entry.Transaction.ClientIP,
+			Method:        entry.Transaction.Request.Method,
+			URI:           entry.Transaction.Request.URI,
+			Status:        entry.Transaction.Response.Status,
+			Blocked:       entry.Transaction.IsInterrupted,
+		}
+		for _, message := range entry.Messages {
+			text := message.Data.Msg
+			if text == "" {
+				text = message.Message
+			}
+			violation.Rules = append(violation.Rules, WAFRuleMatch{
+				ID:       message.Data.ID,
+				Message:  text,
+				Severity: message.Data.Severity,
+				Data:     message.Data.Data,
+			})
+		}
+
+		violations = append(violations, violation)
+		if len(violations) > limit {
+			violations = violations[1:]
+		}
+	}
+
+	return violations, nil
+}
+
+// DeleteWAFLog removes the audit log of a deleted proxy
+func (c *Client) DeleteWAFLog(proxyID string) error {
+	logFile, err := c.WAFLogFile(proxyID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(logFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package caddy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxWebSocketBufferSize bounds the buffer sizes of upstream connections
+const maxWebSocketBufferSize = 16 << 20
+
+// NormalizeWebSocket validates a proxy's WebSocket settings, filling in the probe path
+func NormalizeWebSocket(ws *models.WebSocket, upstreamType string) error {
+	if ws == nil || !ws.Enabled {
+		return nil
+	}
+
+	if upstreamType == UpstreamTypeFastCGI {
+		return fmt.Errorf("WebSocket settings are not supported for fastcgi upstreams")
+	}
+	if ws.ReadBufferSize < 0 || ws.ReadBufferSize > maxWebSocketBufferSize {
+		return fmt.Errorf("websocket read_buffer_size must be between 0 and %d bytes", maxWebSocketBufferSize)
+	}
+	if ws.WriteBufferSize < 0 || ws.WriteBufferSize > maxWebSocketBufferSize {
+		return fmt.Errorf("websocket write_buffer_size must be between 0 and %d bytes", maxWebSocketBufferSize)
+	}
+	if ws.FlushInterval != "" {
+		if _, err := time.ParseDuration(ws.FlushInterval); err != nil {
+			return fmt.Errorf("websocket flush_interval must be a duration such as 100ms, or -1s to flush immediately")
+		}
+	}
+
+	ws.Path = strings.TrimSpace(ws.Path)
+	if ws.Path == "" {
+		ws.Path = "/"
+	}
+	if !strings.HasPrefix(ws.Path, "/") {
+		return fmt.Errorf("websocket path must start with /")
+	}
+	return nil
+}
+
+// webSocketEnabled reports whether a proxy has WebSocket settings
+func webSocketEnabled(proxy models.Proxy) bool {
+	return proxy.WebSocket != nil && proxy.WebSocket.Enabled
+}
+
+// applyWebSocket sets a proxy's WebSocket buffer sizes and flush interval on its reverse_proxy
+// handler. The flush interval overrides the one gRPC sets.
+func applyWebSocket(handler *models.CaddyHandler, proxy models.Proxy) {
+	if !webSocketEnabled(proxy) {
+		return
+	}
+
+	ws := proxy.WebSocket
+	if ws.ReadBufferSize > 0 || ws.WriteBufferSize > 0 {
+		if handler.Transport == nil {
+			handler.Transport = &models.CaddyTransport{Protocol: "http"}
+		}
+		handler.Transport.ReadBufferSize = ws.ReadBufferSize
+		handler.Transport.WriteBufferSize = ws.WriteBufferSize
+	}
+	if ws.FlushInterval != "" {
+		handler.FlushInterval = ws.FlushInterval
+	}
+}
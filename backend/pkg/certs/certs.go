@@ -0,0 +1,174 @@
+// Package certs lists the certificates Caddy has obtained. Certificates are read from Caddy's
+// storage directory and matched against the domains the manager configures through the admin API,
+// so domains that never got a certificate show up too.
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/caddy"
+)
+
+// Renewal status values
+const (
+	RenewalOK      = "ok"          // Not yet due for renewal
+	RenewalDue     = "renewal_due" // In Caddy's renewal window, Caddy should replace it shortly
+	RenewalOverdue = "overdue"     // Half the renewal window has passed without a renewal, it is likely failing
+	RenewalExpired = "expired"
+	RenewalMissing = "missing" // Managed domain without a certificate in storage
+)
+
+// Certificate describes a certificate in Caddy's storage, or a managed domain without one
+type Certificate struct {
+	Domain        string    `json:"domain"`
+	Issuer        string    `json:"issuer,omitempty"`     // Issuer common name
+	IssuerKey     string    `json:"issuer_key,omitempty"` // Caddy's storage key for the issuer, e.g. "local" for internal certificates
+	SANs          []string  `json:"sans,omitempty"`
+	NotBefore     time.Time `json:"not_before,omitzero"`
+	NotAfter      time.Time `json:"not_after,omitzero"`
+	DaysToExpiry  int       `json:"days_to_expiry"` // Only meaningful when not_after is set
+	RenewalStatus string    `json:"renewal_status"`
+	Managed       bool      `json:"managed"` // The domain is configured through the manager
+}
+
+// Service lists certificates from a Caddy storage directory
+type Service struct {
+	client     *caddy.Client
+	storageDir string
+}
+
+// New creates a service reading Caddy's file storage in storageDir ($XDG_DATA_HOME/caddy for a
+// default Caddy install)
+func New(client *caddy.Client, storageDir string) *Service {
+	return &Service{
+		client:     client,
+		storageDir: storageDir,
+	}
+}
+
+// StorageDir returns the Caddy storage directory certificates are read from
+func (s *Service) StorageDir() string {
+	return s.storageDir
+}
+
+// List returns every stored certificate plus managed domains that have none, sorted by domain
+func (s *Service) List() ([]Certificate, error) {
+	domains, err := s.client.CertificateDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	certificates := make([]Certificate, 0, len(stored)+len(domains))
+	for _, cert := range stored {
+		cert.Managed = slices.Contains(domains, cert.Domain)
+		cert.DaysToExpiry = int(cert.NotAfter.Sub(now).Hours() / 24)
+		cert.RenewalStatus = renewalStatus(cert, now)
+		certificates = append(certificates, cert)
+	}
+
+	for _, domain := range domains {
+		if _, exists := stored[domain]; !exists {
+			certificates = append(certificates, Certificate{
+				Domain:        domain,
+				RenewalStatus: RenewalMissing,
+				Managed:       true,
+			})
+		}
+	}
+
+	slices.SortFunc(certificates, func(a, b Certificate) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return certificates, nil
+}
+
+// readStorage parses certificates/<issuer>/<name>/<name>.crt files, keeping the certificate that
+// expires last when several issuers hold one for the same domain
+func (s *Service) readStorage() (map[string]Certificate, error) {
+	stored := make(map[string]Certificate)
+	root := filepath.Join(s.storageDir, "certificates")
+
+	files, err := filepath.Glob(filepath.Join(root, "*", "*", "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		leaf, err := readLeaf(file)
+		if err != nil {
+			continue // Ignore files Caddy is in the middle of writing
+		}
+
+		domain := storageKeyToDomain(strings.TrimSuffix(filepath.Base(file), ".crt"))
+		cert := Certificate{
+			Domain:    domain,
+			Issuer:    leaf.Issuer.CommonName,
+			IssuerKey: filepath.Base(filepath.Dir(filepath.Dir(file))),
+			SANs:      leaf.DNSNames,
+			NotBefore: leaf.NotBefore,
+			NotAfter:  leaf.NotAfter,
+		}
+		for _, ip := range leaf.IPAddresses {
+			cert.SANs = append(cert.SANs, ip.String())
+		}
+
+		if existing, exists := stored[domain]; !exists || cert.NotAfter.After(existing.NotAfter) {
+			stored[domain] = cert
+		}
+	}
+
+	return stored, nil
+}
+
+// readLeaf parses the first certificate of a PEM bundle
+func readLeaf(file string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate in %s", file)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// storageKeyToDomain reverses Caddy's storage key for a name, which replaces "*" with "wildcard_"
+func storageKeyToDomain(key string) string {
+	if rest, ok := strings.CutPrefix(key, "wildcard_"); ok {
+		return "*" + rest
+	}
+	return key
+}
+
+// renewalStatus reports where a certificate is in its renewal cycle. Like Caddy, a certificate is
+// due for renewal once a third of its lifetime is left, which also suits short-lived internal
+// certificates.
+func renewalStatus(cert Certificate, now time.Time) string {
+	remaining := cert.NotAfter.Sub(now)
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	switch {
+	case remaining <= 0:
+		return RenewalExpired
+	case remaining < lifetime/6:
+		return RenewalOverdue
+	case remaining < lifetime/3:
+		return RenewalDue
+	default:
+		return RenewalOK
+	}
+}
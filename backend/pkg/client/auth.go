@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// Me is the signed-in user with their saved proxy list searches
+type Me struct {
+	User          models.User          `json:"user"`
+	SavedSearches []models.SavedSearch `json:"saved_searches"`
+}
+
+// UserUpdate changes a user. Nil fields are left as they are.
+type UserUpdate struct {
+	Role     *string `json:"role,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
+
+// NewAPIToken is the request creating an API token
+type NewAPIToken struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`              // "read" and/or "write"
+	ProxyIDs      []string `json:"proxy_ids,omitempty"` // Limits the token to these proxies
+	Tags          []string `json:"tags,omitempty"`      // Limits the token to proxies with any of these tags
+	ExpiresInDays int      `json:"expires_in_days"`     // 0 for a token that doesn't expire
+}
+
+// CreatedAPIToken is a new API token along with its value, which can't be retrieved later
+type CreatedAPIToken struct {
+	Token    string          `json:"token"`
+	APIToken models.APIToken `json:"api_token"`
+}
+
+// SavedSearch is a named proxy list filter, private to its owner unless shared
+type SavedSearch struct {
+	Name    string            `json:"name"`
+	Filters map[string]string `json:"filters"` // query parameters of the proxy list, e.g. {"status": "unhealthy"}
+	Shared  bool              `json:"shared"`
+}
+
+// AuthStatus reports whether the manager has been set up and whether it requires signing in
+func (c *Client) AuthStatus(ctx context.Context) (*models.StatusResponse, error) {
+	var status models.StatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/auth/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Setup creates the first admin user of a new manager and signs in as them. bootstrapToken is the
+// token printed to the server log on first run, or SETUP_TOKEN.
+func (c *Client) Setup(ctx context.Context, username, password, bootstrapToken string) error {
+	return c.signIn(ctx, "/api/auth/setup", models.SetupRequest{
+		Username:       username,
+		Password:       password,
+		BootstrapToken: bootstrapToken,
+	})
+}
+
+// Login signs in and authenticates later requests with the session token
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	return c.signIn(ctx, "/api/auth/login", models.LoginRequest{Username: username, Password: password})
+}
+
+// signIn posts credentials to path and keeps the returned session token
+func (c *Client) signIn(ctx context.Context, path string, credentials any) error {
+	var response models.AuthResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, credentials, &response); err != nil {
+		return err
+	}
+	c.SetToken(response.Token)
+	return nil
+}
+
+// Logout ends the session and forgets its token
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/api/auth/logout", nil, nil, nil); err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+// Me returns the signed-in user
+func (c *Client) Me(ctx context.Context) (*Me, error) {
+	var me Me
+	if err := c.do(ctx, http.MethodGet, "/api/auth/me", nil, nil, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+// ChangePassword changes the signed-in user's password
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	return c.do(ctx, http.MethodPost, "/api/auth/change-password", nil, models.ChangePasswordRequest{
+		CurrentPassword: currentPassword,
+		NewPassword:     newPassword,
+	}, nil)
+}
+
+// Users lists the users, without their password hashes
+func (c *Client) Users(ctx context.Context) ([]models.User, error) {
+	var response struct {
+		Users []models.User `json:"users"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/users", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Users, nil
+}
+
+// GetUser returns a user
+func (c *Client) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return c.userRequest(ctx, http.MethodGet, "/api/users/"+pathID(id), nil)
+}
+
+// CreateUser adds a user with a role, read_only when empty
+func (c *Client) CreateUser(ctx context.Context, username, password, role string) (*models.User, error) {
+	return c.userRequest(ctx, http.MethodPost, "/api/users", map[string]string{
+		"username": username,
+		"password": password,
+		"role":     role,
+	})
+}
+
+// UpdateUser changes a user's role, password or whether they are disabled
+func (c *Client) UpdateUser(ctx context.Context, id string, update UserUpdate) (*models.User, error) {
+	return c.userRequest(ctx, http.MethodPut, "/api/users/"+pathID(id), update)
+}
+
+// DeleteUser removes a user
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/users/"+pathID(id), nil, nil, nil)
+}
+
+// userRequest sends a request answered with a single user
+func (c *Client) userRequest(ctx context.Context, method, path string, in any) (*models.User, error) {
+	var response struct {
+		User models.User `json:"user"`
+	}
+	if err := c.do(ctx, method, path, nil, in, &response); err != nil {
+		return nil, err
+	}
+	return &response.User, nil
+}
+
+// APITokens lists the API tokens, without their values. Managing API tokens needs a session token
+// from Login, not an API token.
+func (c *Client) APITokens(ctx context.Context) ([]models.APIToken, error) {
+	var response struct {
+		Tokens []models.APIToken `json:"tokens"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tokens", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Tokens, nil
+}
+
+// CreateAPIToken creates an API token
+func (c *Client) CreateAPIToken(ctx context.Context, token NewAPIToken) (*CreatedAPIToken, error) {
+	var created CreatedAPIToken
+	if err := c.do(ctx, http.MethodPost, "/api/tokens", nil, token, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteAPIToken revokes an API token
+func (c *Client) DeleteAPIToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/tokens/"+pathID(id), nil, nil, nil)
+}
+
+// SavedSearches lists the signed-in user's saved searches and those other users shared
+func (c *Client) SavedSearches(ctx context.Context) ([]models.SavedSearch, error) {
+	var response struct {
+		SavedSearches []models.SavedSearch `json:"saved_searches"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/saved-searches", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.SavedSearches, nil
+}
+
+// CreateSavedSearch saves a proxy list search for the signed-in user
+func (c *Client) CreateSavedSearch(ctx context.Context, search SavedSearch) (*models.SavedSearch, error) {
+	return c.savedSearchRequest(ctx, http.MethodPost, "/api/saved-searches", search)
+}
+
+// UpdateSavedSearch replaces one of the signed-in user's saved searches
+func (c *Client) UpdateSavedSearch(ctx context.Context, id string, search SavedSearch) (*models.SavedSearch, error) {
+	return c.savedSearchRequest(ctx, http.MethodPut, "/api/saved-searches/"+pathID(id), search)
+}
+
+// savedSearchRequest sends a request answered with a single saved search
+func (c *Client) savedSearchRequest(ctx context.Context, method, path string, in any) (*models.SavedSearch, error) {
+	var response struct {
+		SavedSearch models.SavedSearch `json:"saved_search"`
+	}
+	if err := c.do(ctx, method, path, nil, in, &response); err != nil {
+		return nil, err
+	}
+	return &response.SavedSearch, nil
+}
+
+// DeleteSavedSearch removes one of the signed-in user's saved searches
+func (c *Client) DeleteSavedSearch(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/saved-searches/"+pathID(id), nil, nil, nil)
+}
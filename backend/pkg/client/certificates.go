@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// CertificateStatus is the certificate state of a proxy or redirect domain
+type CertificateStatus struct {
+	Domain       string `json:"domain"`
+	Status       string `json:"status"`
+	Issuer       string `json:"issuer,omitempty"`
+	NotAfter     string `json:"not_after,omitempty"`
+	DaysToExpiry int    `json:"days_to_expiry"` // only meaningful when not_after is set
+	Message      string `json:"message,omitempty"`
+}
+
+// Certificate is a certificate in Caddy's storage, or a managed domain still without one
+type Certificate struct {
+	Domain        string    `json:"domain"`
+	Issuer        string    `json:"issuer,omitempty"`
+	IssuerKey     string    `json:"issuer_key,omitempty"`
+	SANs          []string  `json:"sans,omitempty"`
+	NotBefore     time.Time `json:"not_before,omitzero"`
+	NotAfter      time.Time `json:"not_after,omitzero"`
+	DaysToExpiry  int       `json:"days_to_expiry"`
+	RenewalStatus string    `json:"renewal_status"`
+	Managed       bool      `json:"managed"` // the domain is configured through the manager
+}
+
+// CustomCertificate describes a certificate uploaded for a proxy
+type CustomCertificate struct {
+	Subject   string   `json:"subject"`
+	Issuer    string   `json:"issuer"`
+	SANs      []string `json:"sans"`
+	NotBefore string   `json:"not_before"`
+	NotAfter  string   `json:"not_after"`
+}
+
+// CertificateReport is the expiry report of every managed certificate
+type CertificateReport struct {
+	Certificates []CertificateStatus `json:"certificates"`
+	Summary      string              `json:"summary"`
+	GeneratedAt  string              `json:"generated_at"`
+}
+
+// Preprovision is the request issuing certificates for domains before any proxy serves them
+type Preprovision struct {
+	Domains        []string          `json:"domains"`
+	ChallengeType  string            `json:"challenge_type,omitempty"` // "http" or "dns"
+	DNSProvider    string            `json:"dns_provider,omitempty"`
+	DNSCredentials map[string]string `json:"dns_credentials,omitempty"`
+	WaitSeconds    *int              `json:"wait_seconds,omitempty"` // how long to wait for issuance, nil for the server's default
+}
+
+// PreprovisionStatus is the certificate state of pre-provisioned domains
+type PreprovisionStatus struct {
+	AllIssued bool                `json:"all_issued"`
+	Domains   []CertificateStatus `json:"domains"`
+}
+
+// DNSProvider is a DNS provider usable for DNS challenges and the credentials it needs
+type DNSProvider struct {
+	Name   string `json:"name"` // the value of dns_provider
+	Label  string `json:"label"`
+	Module string `json:"module"` // the Caddy module Caddy must be built with
+	Fields []struct {
+		Key      string `json:"key"`
+		Label    string `json:"label"`
+		Type     string `json:"type"` // "password", "email" or "text"
+		Required bool   `json:"required"`
+		EnvVar   string `json:"env_var,omitempty"` // used by the server when the request leaves the field empty
+	} `json:"fields"`
+}
+
+// ACMEDNSAccount is an account registered on an acme-dns server, with the CNAME the domain needs
+type ACMEDNSAccount struct {
+	models.ACMEDNSAccount
+	CNAMEName       string `json:"cname_name"`
+	CNAMETarget     string `json:"cname_target"`
+	CNAMEConfigured bool   `json:"cname_configured"` // the CNAME currently resolves to the target
+}
+
+// ACMEServer is the configuration of Caddy's built-in ACME server
+type ACMEServer struct {
+	Enabled      bool   `json:"enabled"`
+	Host         string `json:"host"`
+	Lifetime     string `json:"lifetime,omitempty"` // of the certificates it issues, e.g. "24h"
+	DirectoryURL string `json:"directory_url,omitempty"`
+}
+
+// InternalCA is Caddy's built-in ACME server and internal CA
+type InternalCA struct {
+	ACMEServer ACMEServer          `json:"acme_server"`
+	CA         *models.CaddyCAInfo `json:"ca,omitempty"` // set once Caddy has provisioned the CA
+}
+
+// Certificates lists the certificates in Caddy's storage and managed domains still without one
+func (c *Client) Certificates(ctx context.Context) ([]Certificate, error) {
+	var response struct {
+		Certificates []Certificate `json:"certificates"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/certificates", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Certificates, nil
+}
+
+// CertificateReport returns the expiry state of every proxy and redirect certificate
+func (c *Client) CertificateReport(ctx context.Context) (*CertificateReport, error) {
+	var report CertificateReport
+	if err := c.do(ctx, http.MethodGet, "/api/certificates/report", nil, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// PreprovisionedCertificates returns the certificate state of the pre-provisioned domains
+func (c *Client) PreprovisionedCertificates(ctx context.Context) (*PreprovisionStatus, error) {
+	return c.preprovisionRequest(ctx, http.MethodGet, nil)
+}
+
+// PreprovisionCertificates has Caddy obtain certificates for domains no proxy serves yet, waiting
+// for them to be issued
+func (c *Client) PreprovisionCertificates(ctx context.Context, request Preprovision) (*PreprovisionStatus, error) {
+	return c.preprovisionRequest(ctx, http.MethodPost, request)
+}
+
+// preprovisionRequest sends a request answered with the state of pre-provisioned domains
+func (c *Client) preprovisionRequest(ctx context.Context, method string, in any) (*PreprovisionStatus, error) {
+	var status PreprovisionStatus
+	if err := c.do(ctx, method, "/api/certificates/preprovision", nil, in, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DeletePreprovisionedCertificate stops Caddy from managing a pre-provisioned domain's certificate
+func (c *Client) DeletePreprovisionedCertificate(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodDelete, "/api/certificates/preprovision/"+pathID(domain), nil, nil, nil)
+}
+
+// CustomCertificate returns the certificate uploaded for a proxy
+func (c *Client) CustomCertificate(ctx context.Context, id string) (*CustomCertificate, error) {
+	var certificate CustomCertificate
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/certificate", nil, nil, &certificate); err != nil {
+		return nil, err
+	}
+	return &certificate, nil
+}
+
+// UploadCustomCertificate stores a PEM certificate chain, leaf first, and private key for a proxy.
+// It reports whether the certificate was applied, which happens once the proxy's ssl_mode is "custom".
+func (c *Client) UploadCustomCertificate(ctx context.Context, id, certificatePEM, keyPEM string) (*CustomCertificate, bool, error) {
+	request := map[string]string{"certificate": certificatePEM, "key": keyPEM}
+	var response struct {
+		Certificate CustomCertificate `json:"certificate"`
+		Applied     bool              `json:"applied"`
+	}
+	if err := c.do(ctx, http.MethodPut, "/api/proxies/"+pathID(id)+"/certificate", nil, request, &response); err != nil {
+		return nil, false, err
+	}
+	return &response.Certificate, response.Applied, nil
+}
+
+// DeleteCustomCertificate removes the certificate uploaded for a proxy
+func (c *Client) DeleteCustomCertificate(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/proxies/"+pathID(id)+"/certificate", nil, nil, nil)
+}
+
+// DNSProviders lists the DNS providers usable for DNS challenges
+func (c *Client) DNSProviders(ctx context.Context) ([]DNSProvider, error) {
+	var response struct {
+		Providers []DNSProvider `json:"providers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/dns-providers", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Providers, nil
+}
+
+// ACMEDNSAccounts lists the registered acme-dns accounts, without their passwords
+func (c *Client) ACMEDNSAccounts(ctx context.Context) ([]ACMEDNSAccount, error) {
+	var response struct {
+		Accounts []ACMEDNSAccount `json:"accounts"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/acme-dns/accounts", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Accounts, nil
+}
+
+// RegisterACMEDNSAccount registers an account for domain on the acme-dns server at serverURL, empty
+// for the server's default. Create the returned CNAME before using the acmedns provider.
+func (c *Client) RegisterACMEDNSAccount(ctx context.Context, domain, serverURL string) (*ACMEDNSAccount, error) {
+	request := map[string]string{"domain": domain, "server_url": serverURL}
+	var account ACMEDNSAccount
+	if err := c.do(ctx, http.MethodPost, "/api/acme-dns/accounts", nil, request, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// DeleteACMEDNSAccount forgets the acme-dns account registered for domain
+func (c *Client) DeleteACMEDNSAccount(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodDelete, "/api/acme-dns/accounts/"+pathID(domain), nil, nil, nil)
+}
+
+// InternalCA returns the built-in ACME server settings and the internal CA, once provisioned
+func (c *Client) InternalCA(ctx context.Context) (*InternalCA, error) {
+	var ca InternalCA
+	if err := c.do(ctx, http.MethodGet, "/api/internal-ca", nil, nil, &ca); err != nil {
+		return nil, err
+	}
+	return &ca, nil
+}
+
+// UpdateInternalCA enables the built-in ACME server on server.Host, or disables it
+func (c *Client) UpdateInternalCA(ctx context.Context, server ACMEServer) (*ACMEServer, error) {
+	request := map[string]any{"enabled": server.Enabled, "host": server.Host, "lifetime": server.Lifetime}
+	var saved ACMEServer
+	if err := c.do(ctx, http.MethodPut, "/api/internal-ca", nil, request, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// InternalCARoot returns the internal CA's root certificate as PEM, for installing as trusted
+func (c *Client) InternalCARoot(ctx context.Context) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/internal-ca/root.crt", nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	root, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root certificate: %v", err)
+	}
+	return root, nil
+}
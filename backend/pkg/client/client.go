@@ -0,0 +1,310 @@
+// Package client is a Go client for the Caddy Proxy Manager API, for scripts, CI pipelines and
+// other programs managing proxies without the web UI.
+//
+// Requests authenticate with an API token or a session token from Login. Reads, and writes the
+// server refused before handling them, are retried with backoff.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout      = 60 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500 * time.Millisecond
+
+	// maxRetryWait caps how long a Retry-After from the server can delay a retry
+	maxRetryWait = time.Minute
+)
+
+// Client calls the API of one manager. It is safe for concurrent use.
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	userAgent    string
+	maxRetries   int
+	retryBackoff time.Duration
+	force        bool // send force=true with writes, see Forced
+
+	credentials *credentials
+}
+
+// credentials holds the token, shared by a client and its Forced copies so a Login applies to both
+type credentials struct {
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken authenticates requests with an API token or a session token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.credentials.token = token
+	}
+}
+
+// WithHTTPClient sends requests through httpClient instead of a client with a 60 second timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried, 0 to disable retries, and the
+// delay before the first retry, which doubles with each attempt
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New creates a client for the manager at baseURL, e.g. "https://proxy-manager.example.com"
+func New(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("base URL must be an http or https URL")
+	}
+
+	c := &Client{
+		baseURL:      parsed,
+		httpClient:   &http.Client{Timeout: defaultTimeout},
+		userAgent:    "caddyproxymanager-client",
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+		credentials:  &credentials{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// Token returns the token requests are authenticated with
+func (c *Client) Token() string {
+	c.credentials.mu.RLock()
+	defer c.credentials.mu.RUnlock()
+	return c.credentials.token
+}
+
+// SetToken replaces the token requests are authenticated with
+func (c *Client) SetToken(token string) {
+	c.credentials.mu.Lock()
+	defer c.credentials.mu.Unlock()
+	c.credentials.token = token
+}
+
+// Forced returns a copy of the client whose proxy, redirect and settings changes overwrite routes
+// other tools changed in Caddy, instead of failing with a 409 listing them
+func (c *Client) Forced() *Client {
+	forced := *c
+	forced.force = true
+	return &forced
+}
+
+// APIError is a response with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string // the error the server gave, or the status text
+	Code       string // machine-readable reason, set by some validation errors
+	Body       []byte // the raw response body
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("caddyproxymanager: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is an API error with status 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is an API error with status 409, returned when a change raced
+// another one, which is safe to retry, or would overwrite routes other tools changed in Caddy
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// newAPIError builds the error for a failed response. Handlers answer with {"error": "..."}, the
+// auth endpoints with {"success": false, "message": "..."}.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	apiErr := &APIError{StatusCode: resp.StatusCode, Body: body}
+
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Message = payload.Error
+		if apiErr.Message == "" {
+			apiErr.Message = payload.Message
+		}
+		apiErr.Code = payload.Code
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// Do sends a request to an API path, such as "/api/proxies?status=unhealthy", with in encoded as
+// the JSON body when non-nil, and decodes the response into out when non-nil. It covers endpoints
+// without a typed method.
+func (c *Client) Do(ctx context.Context, method, path string, in, out any) error {
+	target, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %v", err)
+	}
+	return c.do(ctx, method, target.EscapedPath(), target.Query(), in, out)
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	contentType := ""
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = encoded
+		contentType = "application/json"
+	}
+
+	resp, err := c.send(ctx, method, path, query, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// send performs a request, retrying it when that is safe, and returns the successful response.
+// Non-2xx responses are returned as *APIError.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, contentType string, body []byte) (*http.Response, error) {
+	if c.force && method != http.MethodGet {
+		query = cloneWith(query, "force", "true")
+	}
+	target := c.baseURL.JoinPath(path)
+	target.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", c.userAgent)
+		if token := c.Token(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err == nil && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return resp, nil
+		}
+
+		var failure error
+		retry := false
+		wait := c.retryBackoff << attempt
+		if err != nil {
+			failure = err
+			retry = idempotent(method) && ctx.Err() == nil
+		} else {
+			failure = newAPIError(resp)
+			resp.Body.Close()
+			retry, wait = retryAfter(method, resp, wait)
+		}
+		if !retry || attempt >= c.maxRetries {
+			return nil, failure
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, failure
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter reports whether a failed response can be retried and how long to wait first.
+// Rate limited requests and writes refused while the server shuts down were never handled, so any
+// method can be retried; other gateway errors only for idempotent methods.
+func retryAfter(method string, resp *http.Response, backoff time.Duration) (bool, time.Duration) {
+	wait := backoff
+	hint := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(hint); err == nil && seconds >= 0 {
+		wait = min(time.Duration(seconds)*time.Second, maxRetryWait)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true, wait
+	case http.StatusServiceUnavailable:
+		return hint != "" || idempotent(method), wait
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method), wait
+	}
+	return false, wait
+}
+
+// idempotent reports whether sending a request with method twice has the same effect as once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// cloneWith returns a copy of query with key set to value
+func cloneWith(query url.Values, key, value string) url.Values {
+	cloned := url.Values{}
+	for k, v := range query {
+		cloned[k] = append([]string(nil), v...)
+	}
+	cloned.Set(key, value)
+	return cloned
+}
+
+// pathID escapes an ID for use as a path segment, since request paths are joined in escaped form
+func pathID(id string) string {
+	return url.PathEscape(id)
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// DeployToken is a new deploy hook token along with the hook URL using it. The token can't be
+// retrieved later.
+type DeployToken struct {
+	Token   string `json:"token"`
+	HookURL string `json:"hook_url"` // relative to the manager's base URL
+}
+
+// CreateDeployToken creates the deploy hook token of a proxy, replacing any previous one
+func (c *Client) CreateDeployToken(ctx context.Context, id string) (*DeployToken, error) {
+	var token DeployToken
+	if err := c.do(ctx, http.MethodPost, "/api/proxies/"+pathID(id)+"/deploy-token", nil, nil, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// DeleteDeployToken revokes the deploy hook token of a proxy
+func (c *Client) DeleteDeployToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/proxies/"+pathID(id)+"/deploy-token", nil, nil, nil)
+}
+
+// Deploy points a proxy at a new target through its deploy hook, authenticated by the proxy's
+// deploy token rather than the client's token. A port without targetURL only changes the port.
+// It returns the proxy's new target URL.
+func (c *Client) Deploy(ctx context.Context, id, deployToken, targetURL string, port int) (string, error) {
+	request := struct {
+		TargetURL string `json:"target_url,omitempty"`
+		Port      int    `json:"port,omitempty"`
+	}{targetURL, port}
+
+	var response struct {
+		TargetURL string `json:"target_url"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/hooks/deploy/"+pathID(id), url.Values{"token": {deployToken}}, request, &response); err != nil {
+		return "", err
+	}
+	return response.TargetURL, nil
+}
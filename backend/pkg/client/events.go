@@ -0,0 +1,59 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event is a change the server pushes: a health transition, a proxy or redirect change, or Caddy
+// becoming reachable or unreachable
+type Event struct {
+	Type string          `json:"type"` // e.g. "health" or "proxy"
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"` // e.g. an events.HealthChange for "health" events
+}
+
+// Events streams the server's events to handle until ctx is done, the server closes the stream or
+// handle returns an error, which Events returns. The stream isn't bound by the HTTP client's
+// timeout.
+func (c *Client) Events(ctx context.Context, handle func(Event) error) error {
+	streaming := *c
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	streaming.httpClient = &httpClient
+
+	resp, err := streaming.send(ctx, http.MethodGet, "/api/events", nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Each event is an "event:" line naming its type and a "data:" line holding the whole event
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return fmt.Errorf("failed to decode event: %v", err)
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		return fmt.Errorf("event stream failed: %v", err)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,396 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/stats"
+)
+
+// ProxyListOptions filters and paginates the proxy list. The zero value lists every proxy.
+type ProxyListOptions struct {
+	Query    string   // case-insensitive substring of the ID, domain or targets
+	Statuses []string // health statuses, e.g. "unhealthy"
+	SSLMode  string
+	Tags     []string // tags a proxy must all carry
+	Sort     string   // e.g. "domain", or "-last_applied_at" for descending order
+	Page     int      // pagination only applies when Page or PerPage is set
+	PerPage  int
+}
+
+// values encodes the options as query parameters
+func (o ProxyListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Query != "" {
+		query.Set("q", o.Query)
+	}
+	if len(o.Statuses) > 0 {
+		query.Set("status", strings.Join(o.Statuses, ","))
+	}
+	if o.SSLMode != "" {
+		query.Set("ssl_mode", o.SSLMode)
+	}
+	if len(o.Tags) > 0 {
+		query.Set("tag", strings.Join(o.Tags, ","))
+	}
+	setListValues(query, o.Sort, o.Page, o.PerPage)
+	return query
+}
+
+// setListValues adds the sorting and pagination parameters shared by list endpoints
+func setListValues(query url.Values, sort string, page, perPage int) {
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if perPage > 0 {
+		query.Set("per_page", strconv.Itoa(perPage))
+	}
+}
+
+// ProxyList is a page of proxies, with their health status set
+type ProxyList struct {
+	Proxies    []models.Proxy `json:"proxies"`
+	Count      int            `json:"count"`
+	Page       int            `json:"page,omitempty"` // set when paginated
+	PerPage    int            `json:"per_page,omitempty"`
+	Total      int            `json:"total,omitempty"`
+	TotalPages int            `json:"total_pages,omitempty"`
+}
+
+// ProxyDetails is a proxy along with its health, certificate and debug capture state
+type ProxyDetails struct {
+	Proxy             models.Proxy         `json:"proxy"`
+	Health            *models.HealthStatus `json:"health,omitempty"`      // set when checked
+	Certificate       *CertificateStatus   `json:"certificate,omitempty"` // set unless SSL is off
+	DeployHookEnabled bool                 `json:"deploy_hook_enabled"`
+	DebugCapture      DebugCapture         `json:"debug_capture"`
+}
+
+// DebugCapture is the state of a proxy's request capture
+type DebugCapture struct {
+	Active      bool   `json:"active"`
+	StartedAt   string `json:"started_at,omitempty"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// ProxyPreview is the result of a dry run, which validates a proxy without saving it
+type ProxyPreview struct {
+	Valid     bool            `json:"valid"`
+	Errors    []string        `json:"errors"`
+	Proxy     models.Proxy    `json:"proxy"`
+	Generated json.RawMessage `json:"generated"` // the Caddy config the proxy would produce
+}
+
+// HealthChecks is the global health check state
+type HealthChecks struct {
+	Paused         bool `json:"paused"`
+	CheckedProxies int  `json:"checked_proxies"`
+}
+
+// ListProxies lists the proxies matching options
+func (c *Client) ListProxies(ctx context.Context, options ProxyListOptions) (*ProxyList, error) {
+	var list ProxyList
+	if err := c.do(ctx, http.MethodGet, "/api/proxies", options.values(), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetProxy returns a proxy with its health and certificate
+func (c *Client) GetProxy(ctx context.Context, id string) (*ProxyDetails, error) {
+	var details ProxyDetails
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id), nil, nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// CreateProxy adds a proxy and returns it with its ID
+func (c *Client) CreateProxy(ctx context.Context, proxy models.Proxy) (*models.Proxy, error) {
+	return c.proxyRequest(ctx, http.MethodPost, "/api/proxies", proxy)
+}
+
+// UpdateProxy replaces the proxy with proxy.ID. Secrets the server masks in responses, such as
+// health check header values, are kept when sent back masked.
+func (c *Client) UpdateProxy(ctx context.Context, proxy models.Proxy) (*models.Proxy, error) {
+	return c.proxyRequest(ctx, http.MethodPut, "/api/proxies/"+pathID(proxy.ID), proxy)
+}
+
+// PatchProxy changes some fields of a proxy with a JSON merge patch, e.g.
+// {"target_url": "http://10.0.0.5:8080"}. Fields set to nil are reset to their default.
+func (c *Client) PatchProxy(ctx context.Context, id string, patch map[string]any) (*models.Proxy, error) {
+	var saved models.Proxy
+	if err := c.do(ctx, http.MethodPatch, "/api/proxies/"+pathID(id), nil, patch, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// LookupProxy returns the proxy serving domain, e.g. to find the ID of a proxy to import
+func (c *Client) LookupProxy(ctx context.Context, domain string) (*ProxyDetails, error) {
+	var details ProxyDetails
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/lookup", url.Values{"domain": {domain}}, nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// proxyRequest sends a proxy and decodes the saved proxy from the response
+func (c *Client) proxyRequest(ctx context.Context, method, path string, proxy models.Proxy) (*models.Proxy, error) {
+	var saved models.Proxy
+	if err := c.do(ctx, method, path, nil, proxy, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// PreviewProxy validates a proxy and returns the config it would produce, without saving it. An
+// empty proxy.ID previews creating it, otherwise replacing the proxy with that ID.
+func (c *Client) PreviewProxy(ctx context.Context, proxy models.Proxy) (*ProxyPreview, error) {
+	method, path := http.MethodPost, "/api/proxies"
+	if proxy.ID != "" {
+		method, path = http.MethodPut, "/api/proxies/"+pathID(proxy.ID)
+	}
+
+	var preview ProxyPreview
+	if err := c.do(ctx, method, path, url.Values{"dry_run": {"true"}}, proxy, &preview); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// DeleteProxy removes a proxy
+func (c *Client) DeleteProxy(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/proxies/"+pathID(id), nil, nil, nil)
+}
+
+// ProxyStats returns a proxy's traffic over window: "5m", "1h", "6h" or "24h". The server needs
+// TRAFFIC_STATS=true.
+func (c *Client) ProxyStats(ctx context.Context, id, window string) (*stats.Summary, error) {
+	var response struct {
+		Stats stats.Summary `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/stats", url.Values{"window": {window}}, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response.Stats, nil
+}
+
+// ProxyHealth returns the health status of a proxy with health checks enabled
+func (c *Client) ProxyHealth(ctx context.Context, id string) (*models.HealthStatus, error) {
+	var status models.HealthStatus
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GeneratedProxyConfig returns the Caddy config generated for a proxy
+func (c *Client) GeneratedProxyConfig(ctx context.Context, id string) (json.RawMessage, error) {
+	var generated json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/generated", nil, nil, &generated); err != nil {
+		return nil, err
+	}
+	return generated, nil
+}
+
+// PauseProxyHealthCheck pauses or resumes the health check of a proxy and returns its status,
+// nil when the proxy has no check running
+func (c *Client) PauseProxyHealthCheck(ctx context.Context, id string, paused bool) (*models.HealthStatus, error) {
+	var status *models.HealthStatus
+	err := c.do(ctx, http.MethodPut, "/api/proxies/"+pathID(id)+"/health-check/pause", nil, map[string]bool{"paused": paused}, &status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// HealthChecks reports whether all health checks are paused
+func (c *Client) HealthChecks(ctx context.Context) (*HealthChecks, error) {
+	var checks HealthChecks
+	if err := c.do(ctx, http.MethodGet, "/api/health-checks", nil, nil, &checks); err != nil {
+		return nil, err
+	}
+	return &checks, nil
+}
+
+// PauseHealthChecks pauses or resumes every health check
+func (c *Client) PauseHealthChecks(ctx context.Context, paused bool) (*HealthChecks, error) {
+	var checks HealthChecks
+	if err := c.do(ctx, http.MethodPut, "/api/health-checks/pause", nil, map[string]bool{"paused": paused}, &checks); err != nil {
+		return nil, err
+	}
+	return &checks, nil
+}
+
+// WizardCheck is the outcome of one proxy wizard validation, with guidance on fixing a failure
+type WizardCheck struct {
+	Step     string `json:"step"`   // "domain", "dns", "target" or "ssl"
+	Name     string `json:"name"`   // e.g. "format"
+	Status   string `json:"status"` // "pass", "warn" or "fail"
+	Message  string `json:"message"`
+	Guidance string `json:"guidance,omitempty"`
+}
+
+// WizardResult is the outcome of validating a proxy wizard step
+type WizardResult struct {
+	Valid  bool          `json:"valid"` // no check failed
+	Checks []WizardCheck `json:"checks"`
+}
+
+// Connectivity is the result of checking that a proxy's targets are reachable
+type Connectivity struct {
+	ProxyID string               `json:"proxy_id"`
+	Targets []TargetConnectivity `json:"targets"`
+}
+
+// TargetConnectivity is whether the manager and Caddy could reach one target
+type TargetConnectivity struct {
+	Target  string `json:"target"`
+	Dial    string `json:"dial"`
+	Manager struct {
+		Reachable bool   `json:"reachable"`
+		Error     string `json:"error,omitempty"`
+	} `json:"manager"`
+	Caddy *struct {
+		Reachable bool   `json:"reachable"`
+		Status    int    `json:"status,omitempty"` // the status the target answered Caddy with
+		Error     string `json:"error,omitempty"`
+	} `json:"caddy,omitempty"` // set when the server can probe upstreams through Caddy
+	Verdict string `json:"verdict"`
+	Message string `json:"message"`
+}
+
+// NewIPList is the request importing an IP list into a proxy's allowed or blocked IPs. Either URL or
+// Content is set.
+type NewIPList struct {
+	Name            string `json:"name"`
+	List            string `json:"list"`                       // "allowed" or "blocked"
+	URL             string `json:"url,omitempty"`              // fetched now and again every RefreshInterval
+	RefreshInterval string `json:"refresh_interval,omitempty"` // e.g. "24h", only for URLs
+	Content         string `json:"content,omitempty"`          // text of an uploaded list
+}
+
+// ValidateProxy runs the checks of one proxy wizard step, "domain", "dns", "target" or "ssl", or of
+// every step when step is empty, without saving anything. proxy.ID is set when editing a proxy.
+func (c *Client) ValidateProxy(ctx context.Context, step string, proxy models.Proxy) (*WizardResult, error) {
+	request := struct {
+		models.Proxy
+		Step string `json:"step,omitempty"`
+	}{proxy, step}
+
+	var result WizardResult
+	if err := c.do(ctx, http.MethodPost, "/api/proxies/validate", nil, request, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TestProxyConnectivity checks whether the manager and Caddy can reach each target of a proxy
+func (c *Client) TestProxyConnectivity(ctx context.Context, id string) (*Connectivity, error) {
+	var connectivity Connectivity
+	if err := c.do(ctx, http.MethodPost, "/api/proxies/"+pathID(id)+"/connectivity", nil, nil, &connectivity); err != nil {
+		return nil, err
+	}
+	return &connectivity, nil
+}
+
+// GetDebugCapture returns the state of a proxy's request capture
+func (c *Client) GetDebugCapture(ctx context.Context, id string) (*DebugCapture, error) {
+	return c.debugCaptureRequest(ctx, http.MethodGet, id, nil)
+}
+
+// StartDebugCapture records a proxy's requests for durationMinutes, 0 for the server's default
+func (c *Client) StartDebugCapture(ctx context.Context, id string, durationMinutes int) (*DebugCapture, error) {
+	return c.debugCaptureRequest(ctx, http.MethodPost, id, map[string]int{"duration_minutes": durationMinutes})
+}
+
+// StopDebugCapture stops recording a proxy's requests. What was captured can still be downloaded.
+func (c *Client) StopDebugCapture(ctx context.Context, id string) (*DebugCapture, error) {
+	return c.debugCaptureRequest(ctx, http.MethodDelete, id, nil)
+}
+
+// debugCaptureRequest sends a request answered with the state of a proxy's request capture
+func (c *Client) debugCaptureRequest(ctx context.Context, method, id string, in any) (*DebugCapture, error) {
+	var capture DebugCapture
+	if err := c.do(ctx, method, "/api/proxies/"+pathID(id)+"/debug-capture", nil, in, &capture); err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// DownloadDebugCapture writes the requests captured for a proxy to w, one JSON object per line
+func (c *Client) DownloadDebugCapture(ctx context.Context, id string, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/debug-capture/download", nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download debug capture: %v", err)
+	}
+	return nil
+}
+
+// IPLists lists the IP lists imported into a proxy
+func (c *Client) IPLists(ctx context.Context, id string) ([]models.IPListSource, error) {
+	var lists []models.IPListSource
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/ip-lists", nil, nil, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// ImportIPList adds the entries of a list at a URL or of uploaded content to a proxy's allowed or
+// blocked IPs
+func (c *Client) ImportIPList(ctx context.Context, id string, list NewIPList) (*models.IPListSource, error) {
+	var imported models.IPListSource
+	if err := c.do(ctx, http.MethodPost, "/api/proxies/"+pathID(id)+"/ip-lists", nil, list, &imported); err != nil {
+		return nil, err
+	}
+	return &imported, nil
+}
+
+// RefreshIPList fetches an IP list's URL again and reports whether its entries changed
+func (c *Client) RefreshIPList(ctx context.Context, id, listID string) (*models.IPListSource, bool, error) {
+	var response struct {
+		IPList  models.IPListSource `json:"ip_list"`
+		Changed bool                `json:"changed"`
+	}
+	path := "/api/proxies/" + pathID(id) + "/ip-lists/" + pathID(listID) + "/refresh"
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &response); err != nil {
+		return nil, false, err
+	}
+	return &response.IPList, response.Changed, nil
+}
+
+// DeleteIPList removes an IP list and its entries from a proxy
+func (c *Client) DeleteIPList(ctx context.Context, id, listID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/proxies/"+pathID(id)+"/ip-lists/"+pathID(listID), nil, nil, nil)
+}
+
+// DownloadAccessLog writes a proxy's access log to w, one Caddy access log entry per line
+func (c *Client) DownloadAccessLog(ctx context.Context, id string, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/proxies/"+pathID(id)+"/access-log", nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download access log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+)
+
+// UsageReport lists resources that look broken or forgotten
+type UsageReport struct {
+	BrokenRedirects []struct {
+		ID             string   `json:"id"`
+		SourceDomains  []string `json:"source_domains"`
+		DestinationURL string   `json:"destination_url"`
+		StatusCode     int      `json:"status_code,omitempty"` // the error status the destination answered with
+		Error          string   `json:"error,omitempty"`       // why the destination couldn't be requested
+	} `json:"broken_redirects"`
+	RedirectsChecked bool `json:"redirects_checked"`
+	UnhealthyProxies []struct {
+		ID               string   `json:"id"`
+		Domain           string   `json:"domain"`
+		Message          string   `json:"message"`
+		LastChecked      string   `json:"last_checked"`
+		DownDependencies []string `json:"down_dependencies,omitempty"`
+	} `json:"unhealthy_proxies"`
+	// IdleProxies is empty unless the server collects traffic statistics
+	IdleProxies []struct {
+		ID        string `json:"id"`
+		Domain    string `json:"domain"`
+		CreatedAt string `json:"created_at"`
+	} `json:"idle_proxies"`
+	UnusedAccessLists []struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		CreatedAt string `json:"created_at"`
+	} `json:"unused_access_lists"`
+	UnusedACMEDNSAccounts []struct {
+		Domain       string `json:"domain"`
+		RegisteredAt string `json:"registered_at"`
+	} `json:"unused_acme_dns_accounts"`
+	Summary     map[string]int `json:"summary"`     // counts of resources and findings
+	Unavailable []string       `json:"unavailable"` // findings the server can't report
+	GeneratedAt string         `json:"generated_at"`
+}
+
+// WAFViolation is a request the web application firewall matched rules on
+type WAFViolation struct {
+	ProxyID       string `json:"proxy_id"`
+	Time          string `json:"time"`
+	TransactionID string `json:"transaction_id"`
+	ClientIP      string `json:"client_ip"`
+	Method        string `json:"method"`
+	URI           string `json:"uri"`
+	Status        int    `json:"status"`
+	Blocked       bool   `json:"blocked"` // false in detection-only mode
+	Rules         []struct {
+		ID       int    `json:"id"`
+		Message  string `json:"message"`
+		Severity int    `json:"severity"` // 0 (emergency) to 7 (debug)
+		Data     string `json:"data,omitempty"`
+	} `json:"rules"`
+}
+
+// AuditAnalysis is the suspicious patterns found in the audit log
+type AuditAnalysis struct {
+	Findings []audit.Finding `json:"findings"`
+	Since    time.Time       `json:"since"`
+}
+
+// ConfigDrift is whether Caddy's running config differs from the config the manager saved
+type ConfigDrift struct {
+	Dirty       bool      `json:"dirty"`
+	DetectedAt  time.Time `json:"detected_at,omitzero"`
+	CaddyHash   string    `json:"caddy_hash,omitempty"`
+	ManagedHash string    `json:"managed_hash,omitempty"`
+	LastChecked time.Time `json:"last_checked,omitzero"`
+	Error       string    `json:"error,omitempty"` // set when Caddy couldn't be polled
+	LastReapply *struct {
+		Time   time.Time `json:"time"`
+		Routes int       `json:"routes"` // managed routes pushed back to Caddy
+		Error  string    `json:"error,omitempty"`
+	} `json:"last_reapply,omitempty"` // the last re-apply after Caddy restarted without the config
+}
+
+// ForeignChange is a managed route another tool changed or removed in Caddy
+type ForeignChange struct {
+	RouteID string `json:"route_id"`
+	App     string `json:"app"` // "http" or "layer4"
+	Server  string `json:"server"`
+	Change  string `json:"change"` // "modified" or "removed"
+}
+
+// UsageReport returns broken redirects, unhealthy and idle proxies and unused access lists and
+// acme-dns accounts.
+// checkRedirects requests every redirect destination, which can take a while.
+func (c *Client) UsageReport(ctx context.Context, checkRedirects bool) (*UsageReport, error) {
+	query := url.Values{"check_redirects": {strconv.FormatBool(checkRedirects)}}
+
+	var report UsageReport
+	if err := c.do(ctx, http.MethodGet, "/api/reports/usage", query, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// WAFViolations returns the most recent requests the WAF matched, at most limit or the server's
+// default when 0, of one proxy or of every proxy when proxyID is empty
+func (c *Client) WAFViolations(ctx context.Context, proxyID string, limit int) ([]WAFViolation, error) {
+	query := url.Values{}
+	if proxyID != "" {
+		query.Set("proxy", proxyID)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var response struct {
+		Violations []WAFViolation `json:"violations"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/waf/violations", query, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Violations, nil
+}
+
+// AuditAnalysis looks for suspicious patterns, such as repeated failed logins, in the audit log
+// entries of the last window, 0 for the server's default of a day
+func (c *Client) AuditAnalysis(ctx context.Context, window time.Duration) (*AuditAnalysis, error) {
+	query := url.Values{}
+	if window > 0 {
+		query.Set("window", window.String())
+	}
+
+	var analysis AuditAnalysis
+	if err := c.do(ctx, http.MethodGet, "/api/audit-log/analysis", query, nil, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// ConfigDrift reports whether Caddy's running config differs from the config the manager saved
+func (c *Client) ConfigDrift(ctx context.Context) (*ConfigDrift, error) {
+	var drift ConfigDrift
+	if err := c.do(ctx, http.MethodGet, "/api/config/drift", nil, nil, &drift); err != nil {
+		return nil, err
+	}
+	return &drift, nil
+}
+
+// ResolveConfigDrift resolves config drift with action "adopt", keeping Caddy's running config as
+// the managed config, or "restore", pushing the managed config to Caddy again
+func (c *Client) ResolveConfigDrift(ctx context.Context, action string) (*ConfigDrift, error) {
+	var drift ConfigDrift
+	if err := c.do(ctx, http.MethodPost, "/api/config/drift/resolve", nil, map[string]string{"action": action}, &drift); err != nil {
+		return nil, err
+	}
+	return &drift, nil
+}
+
+// ForeignChanges lists managed routes other tools changed in Caddy. Writes touching them fail
+// with a 409 unless sent through Forced.
+func (c *Client) ForeignChanges(ctx context.Context) ([]ForeignChange, error) {
+	var response struct {
+		ForeignChanges []ForeignChange `json:"foreign_changes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/config/foreign-changes", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.ForeignChanges, nil
+}
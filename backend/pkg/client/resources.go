@@ -0,0 +1,353 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/portcheck"
+)
+
+// RedirectDetails is a redirect along with the certificates of its source domains
+type RedirectDetails struct {
+	Redirect     models.Redirect     `json:"redirect"`
+	Certificates []CertificateStatus `json:"certificates"`
+}
+
+// AccessList is a shared access list, without password hashes, with the proxies using it
+type AccessList struct {
+	models.AccessList
+	Proxies []string `json:"proxies"`
+}
+
+// NotificationChannel is a notification channel without its secret settings
+type NotificationChannel struct {
+	models.NotificationChannel
+	SecretsSet []string `json:"secrets_set"` // keys of the secret settings that are set
+}
+
+// NotificationTypes are the supported channel types and the events channels can subscribe to
+type NotificationTypes struct {
+	Types        []NotificationType `json:"types"`
+	Events       []string           `json:"events"`        // alerts
+	ChangeEvents []string           `json:"change_events"` // configuration changes
+}
+
+// NotificationType is a kind of notification channel and the settings it needs
+type NotificationType struct {
+	Name   string `json:"name"`
+	Label  string `json:"label"`
+	Fields []struct {
+		Key      string `json:"key"`
+		Label    string `json:"label"`
+		Type     string `json:"type"` // "password" for secrets, which are never returned
+		Required bool   `json:"required"`
+	} `json:"fields"`
+}
+
+// RedirectListOptions filters, sorts and paginates the redirect list. The zero value lists every redirect.
+type RedirectListOptions struct {
+	Query    string   // case-insensitive substring of the ID, a source domain or the destination
+	Statuses []string // e.g. "active"
+	Tags     []string // tags a redirect must all carry
+	Sort     string   // e.g. "domain", or "-redirect_code" for descending order
+	Page     int      // pagination only applies when Page or PerPage is set
+	PerPage  int
+}
+
+// values encodes the options as query parameters
+func (o RedirectListOptions) values() url.Values {
+	query := url.Values{}
+	if o.Query != "" {
+		query.Set("q", o.Query)
+	}
+	if len(o.Statuses) > 0 {
+		query.Set("status", strings.Join(o.Statuses, ","))
+	}
+	if len(o.Tags) > 0 {
+		query.Set("tag", strings.Join(o.Tags, ","))
+	}
+	setListValues(query, o.Sort, o.Page, o.PerPage)
+	return query
+}
+
+// RedirectList is a page of redirects
+type RedirectList struct {
+	Redirects  []models.Redirect `json:"redirects"`
+	Count      int               `json:"count"`
+	Page       int               `json:"page,omitempty"` // set when paginated
+	PerPage    int               `json:"per_page,omitempty"`
+	Total      int               `json:"total,omitempty"`
+	TotalPages int               `json:"total_pages,omitempty"`
+}
+
+// ListRedirects lists the redirects matching options
+func (c *Client) ListRedirects(ctx context.Context, options RedirectListOptions) (*RedirectList, error) {
+	var list RedirectList
+	if err := c.do(ctx, http.MethodGet, "/api/redirects", options.values(), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Redirects lists the redirects
+func (c *Client) Redirects(ctx context.Context) ([]models.Redirect, error) {
+	var response struct {
+		Redirects []models.Redirect `json:"redirects"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/redirects", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Redirects, nil
+}
+
+// GetRedirect returns a redirect with the certificates of its source domains
+func (c *Client) GetRedirect(ctx context.Context, id string) (*RedirectDetails, error) {
+	var details RedirectDetails
+	if err := c.do(ctx, http.MethodGet, "/api/redirects/"+pathID(id), nil, nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// CreateRedirect adds a redirect and returns it with its ID and any warnings, e.g. about chains
+func (c *Client) CreateRedirect(ctx context.Context, redirect models.Redirect) (*models.Redirect, error) {
+	return c.redirectRequest(ctx, http.MethodPost, "/api/redirects", redirect)
+}
+
+// UpdateRedirect replaces the redirect with redirect.ID
+func (c *Client) UpdateRedirect(ctx context.Context, redirect models.Redirect) (*models.Redirect, error) {
+	return c.redirectRequest(ctx, http.MethodPut, "/api/redirects/"+pathID(redirect.ID), redirect)
+}
+
+// redirectRequest sends a redirect and decodes the saved redirect from the response
+func (c *Client) redirectRequest(ctx context.Context, method, path string, redirect models.Redirect) (*models.Redirect, error) {
+	var saved models.Redirect
+	if err := c.do(ctx, method, path, nil, redirect, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// DeleteRedirect removes a redirect
+func (c *Client) DeleteRedirect(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/redirects/"+pathID(id), nil, nil, nil)
+}
+
+// Streams lists the TCP and UDP stream proxies
+func (c *Client) Streams(ctx context.Context) ([]models.StreamProxy, error) {
+	var response struct {
+		Streams []models.StreamProxy `json:"streams"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/streams", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Streams, nil
+}
+
+// GetStream returns a stream proxy
+func (c *Client) GetStream(ctx context.Context, id string) (*models.StreamProxy, error) {
+	return c.streamRequest(ctx, http.MethodGet, "/api/streams/"+pathID(id), nil)
+}
+
+// CreateStream adds a stream proxy and returns it with its ID
+func (c *Client) CreateStream(ctx context.Context, stream models.StreamProxy) (*models.StreamProxy, error) {
+	return c.streamRequest(ctx, http.MethodPost, "/api/streams", stream)
+}
+
+// UpdateStream replaces the stream proxy with stream.ID
+func (c *Client) UpdateStream(ctx context.Context, stream models.StreamProxy) (*models.StreamProxy, error) {
+	return c.streamRequest(ctx, http.MethodPut, "/api/streams/"+pathID(stream.ID), stream)
+}
+
+// streamRequest sends a request answered with a single stream proxy
+func (c *Client) streamRequest(ctx context.Context, method, path string, in any) (*models.StreamProxy, error) {
+	var stream models.StreamProxy
+	if err := c.do(ctx, method, path, nil, in, &stream); err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// DeleteStream removes a stream proxy
+func (c *Client) DeleteStream(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/streams/"+pathID(id), nil, nil, nil)
+}
+
+// PortForward is a stream created from one external port to one internal address
+type PortForward struct {
+	Name         string `json:"name,omitempty"`
+	Protocol     string `json:"protocol,omitempty"` // "tcp" (default) or "udp"
+	ExternalPort int    `json:"external_port"`
+	Target       string `json:"target"` // internal host:port
+}
+
+// CreatePortForward adds a stream for forward and reports whether its port can be reached from
+// outside
+func (c *Client) CreatePortForward(ctx context.Context, forward PortForward) (*models.StreamProxy, *portcheck.Result, error) {
+	var response struct {
+		Stream       models.StreamProxy `json:"stream"`
+		Reachability portcheck.Result   `json:"reachability"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/streams/port-forward", nil, forward, &response); err != nil {
+		return nil, nil, err
+	}
+	return &response.Stream, &response.Reachability, nil
+}
+
+// StreamReachability checks whether a stream's port can be reached from outside
+func (c *Client) StreamReachability(ctx context.Context, id string) (*portcheck.Result, error) {
+	var result portcheck.Result
+	if err := c.do(ctx, http.MethodGet, "/api/streams/"+pathID(id)+"/reachability", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AccessLists lists the shared access lists
+func (c *Client) AccessLists(ctx context.Context) ([]AccessList, error) {
+	var response struct {
+		AccessLists []AccessList `json:"access_lists"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/access-lists", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.AccessLists, nil
+}
+
+// GetAccessList returns a shared access list
+func (c *Client) GetAccessList(ctx context.Context, id string) (*AccessList, error) {
+	return c.accessListRequest(ctx, http.MethodGet, "/api/access-lists/"+pathID(id), nil)
+}
+
+// CreateAccessList adds a shared access list. Users need a password, which is stored hashed.
+func (c *Client) CreateAccessList(ctx context.Context, list models.AccessList) (*AccessList, error) {
+	return c.accessListRequest(ctx, http.MethodPost, "/api/access-lists", list)
+}
+
+// UpdateAccessList replaces the access list with list.ID. Existing users sent without a password
+// keep theirs.
+func (c *Client) UpdateAccessList(ctx context.Context, list models.AccessList) (*AccessList, error) {
+	return c.accessListRequest(ctx, http.MethodPut, "/api/access-lists/"+pathID(list.ID), list)
+}
+
+// accessListRequest sends a request answered with a single access list
+func (c *Client) accessListRequest(ctx context.Context, method, path string, in any) (*AccessList, error) {
+	var list AccessList
+	if err := c.do(ctx, method, path, nil, in, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// DeleteAccessList removes an access list, which fails with a 409 while proxies use it
+func (c *Client) DeleteAccessList(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/access-lists/"+pathID(id), nil, nil, nil)
+}
+
+// ProxyTemplates lists the proxy templates
+func (c *Client) ProxyTemplates(ctx context.Context) ([]models.ProxyTemplate, error) {
+	var response struct {
+		Templates []models.ProxyTemplate `json:"templates"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/templates", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Templates, nil
+}
+
+// GetProxyTemplate returns a proxy template
+func (c *Client) GetProxyTemplate(ctx context.Context, id string) (*models.ProxyTemplate, error) {
+	return c.proxyTemplateRequest(ctx, http.MethodGet, "/api/templates/"+pathID(id), nil)
+}
+
+// CreateProxyTemplate adds a proxy template. Its Proxy holds proxy fields without domain and targets.
+func (c *Client) CreateProxyTemplate(ctx context.Context, template models.ProxyTemplate) (*models.ProxyTemplate, error) {
+	return c.proxyTemplateRequest(ctx, http.MethodPost, "/api/templates", template)
+}
+
+// UpdateProxyTemplate replaces the proxy template with template.ID
+func (c *Client) UpdateProxyTemplate(ctx context.Context, template models.ProxyTemplate) (*models.ProxyTemplate, error) {
+	return c.proxyTemplateRequest(ctx, http.MethodPut, "/api/templates/"+pathID(template.ID), template)
+}
+
+// proxyTemplateRequest sends a request answered with a single proxy template
+func (c *Client) proxyTemplateRequest(ctx context.Context, method, path string, in any) (*models.ProxyTemplate, error) {
+	var template models.ProxyTemplate
+	if err := c.do(ctx, method, path, nil, in, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// DeleteProxyTemplate removes a proxy template
+func (c *Client) DeleteProxyTemplate(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/templates/"+pathID(id), nil, nil, nil)
+}
+
+// CreateProxyFromTemplate creates a proxy for domain and targetURL with a template's settings
+func (c *Client) CreateProxyFromTemplate(ctx context.Context, id, domain, targetURL string) (*models.Proxy, error) {
+	in := map[string]string{"domain": domain, "target_url": targetURL}
+	var proxy models.Proxy
+	if err := c.do(ctx, http.MethodPost, "/api/templates/"+pathID(id)+"/proxies", nil, in, &proxy); err != nil {
+		return nil, err
+	}
+	return &proxy, nil
+}
+
+// NotificationTypes returns the supported notification channel types and events
+func (c *Client) NotificationTypes(ctx context.Context) (*NotificationTypes, error) {
+	var types NotificationTypes
+	if err := c.do(ctx, http.MethodGet, "/api/notifications/types", nil, nil, &types); err != nil {
+		return nil, err
+	}
+	return &types, nil
+}
+
+// NotificationChannels lists the notification channels
+func (c *Client) NotificationChannels(ctx context.Context) ([]NotificationChannel, error) {
+	var response struct {
+		Channels []NotificationChannel `json:"channels"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/notifications", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Channels, nil
+}
+
+// GetNotificationChannel returns a notification channel
+func (c *Client) GetNotificationChannel(ctx context.Context, id string) (*NotificationChannel, error) {
+	return c.notificationChannelRequest(ctx, http.MethodGet, "/api/notifications/"+pathID(id), nil)
+}
+
+// CreateNotificationChannel adds a notification channel
+func (c *Client) CreateNotificationChannel(ctx context.Context, channel models.NotificationChannel) (*NotificationChannel, error) {
+	return c.notificationChannelRequest(ctx, http.MethodPost, "/api/notifications", channel)
+}
+
+// UpdateNotificationChannel replaces the channel with channel.ID. Secret settings left empty keep
+// their value.
+func (c *Client) UpdateNotificationChannel(ctx context.Context, channel models.NotificationChannel) (*NotificationChannel, error) {
+	return c.notificationChannelRequest(ctx, http.MethodPut, "/api/notifications/"+pathID(channel.ID), channel)
+}
+
+// notificationChannelRequest sends a request answered with a single notification channel
+func (c *Client) notificationChannelRequest(ctx context.Context, method, path string, in any) (*NotificationChannel, error) {
+	var channel NotificationChannel
+	if err := c.do(ctx, method, path, nil, in, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// DeleteNotificationChannel removes a notification channel
+func (c *Client) DeleteNotificationChannel(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/notifications/"+pathID(id), nil, nil, nil)
+}
+
+// TestNotificationChannel sends a test alert to a channel. Delivery failures are returned as a 502.
+func (c *Client) TestNotificationChannel(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/notifications/"+pathID(id)+"/test", nil, nil, nil)
+}
@@ -0,0 +1,480 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/audit"
+	"github.com/sarat/caddyproxymanager/pkg/discovery"
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/pages"
+	"github.com/sarat/caddyproxymanager/pkg/presets"
+	"github.com/sarat/caddyproxymanager/pkg/update"
+)
+
+// maxRestoreBytes matches the largest backup the server accepts
+const maxRestoreBytes = 50 << 20
+
+// Status is Caddy's state as last polled by the manager
+type Status struct {
+	CaddyStatus    string          `json:"caddy_status"` // "running" or "error"
+	CaddyReachable bool            `json:"caddy_reachable"`
+	LastChecked    string          `json:"last_checked"`
+	ChangedAt      string          `json:"changed_at"`
+	NextCheck      string          `json:"next_check"`
+	Error          string          `json:"error,omitempty"`     // set when Caddy is unreachable
+	Upstreams      json.RawMessage `json:"upstreams,omitempty"` // Caddy's reverse proxy upstreams, when reachable
+	WebSockets     json.RawMessage `json:"websockets,omitempty"`
+}
+
+// Version is the running version, the latest release and whether the server can update itself
+type Version struct {
+	Version           update.Info `json:"version"`
+	SelfUpdateEnabled bool        `json:"self_update_enabled"`
+}
+
+// ServerSettings are the HTTP versions the Caddy servers accept, with the servers they can override
+type ServerSettings struct {
+	models.ServerSettings
+	AvailableServers []string `json:"available_servers"`
+}
+
+// Features describes what a deployment can do
+type Features struct {
+	Version  string `json:"version"`
+	Features struct {
+		Auth             bool   `json:"auth"`
+		SessionStore     string `json:"session_store"`
+		StateStore       string `json:"state_store"`
+		HighAvailability bool   `json:"high_availability"`
+		SelfUpdate       bool   `json:"self_update"`
+		ConfigSelfHeal   bool   `json:"config_self_heal"`
+		MetricsPush      bool   `json:"metrics_push"`
+		HealthHook       bool   `json:"health_hook"`
+		OutboundGuard    bool   `json:"outbound_guard"`
+		ConnectivityTest bool   `json:"connectivity_test"`
+		TrafficStats     bool   `json:"traffic_stats"`
+		Kubernetes       bool   `json:"kubernetes_discovery"`
+		IDStrategy       string `json:"id_strategy"`
+	} `json:"features"`
+	Caddy struct {
+		InUse []string `json:"in_use"` // optional modules the running config uses
+		Error string   `json:"error,omitempty"`
+	} `json:"caddy"`
+	Notifications struct {
+		Channels int `json:"channels"`
+		Enabled  int `json:"enabled"`
+	} `json:"notifications"`
+}
+
+// RestoreResult describes a restored backup
+type RestoreResult struct {
+	Created    time.Time `json:"created"`
+	Files      int       `json:"files"`
+	Proxies    int       `json:"proxies"`
+	SecretsKey bool      `json:"secrets_key"` // the backup included the secrets key
+}
+
+// RemoteBackups lists the encrypted backups on the remote target
+type RemoteBackups struct {
+	Target   string         `json:"target"`   // "s3" or "webdav"
+	Interval string         `json:"interval"` // how often backups are uploaded, "0s" for on demand only
+	Keep     int            `json:"keep"`     // how many backups are kept
+	Backups  []RemoteBackup `json:"backups"`  // newest first
+}
+
+// RemoteBackup is an encrypted backup on the remote target
+type RemoteBackup struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Status returns Caddy's state
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Version returns the running version and the latest release. refresh bypasses the server's cache.
+func (c *Client) Version(ctx context.Context, refresh bool) (*Version, error) {
+	query := url.Values{}
+	if refresh {
+		query.Set("refresh", "true")
+	}
+
+	var version Version
+	if err := c.do(ctx, http.MethodGet, "/api/version", query, nil, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Features returns the deployment's enabled features and the optional Caddy modules it uses
+func (c *Client) Features(ctx context.Context) (*Features, error) {
+	var features Features
+	if err := c.do(ctx, http.MethodGet, "/api/features", nil, nil, &features); err != nil {
+		return nil, err
+	}
+	return &features, nil
+}
+
+// KubernetesServices lists the Services and Ingresses the server found in Kubernetes. The server
+// needs KUBERNETES_DISCOVERY=true.
+func (c *Client) KubernetesServices(ctx context.Context) (*discovery.Snapshot, error) {
+	var snapshot discovery.Snapshot
+	if err := c.do(ctx, http.MethodGet, "/api/discovery/kubernetes", nil, nil, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Reload pushes the managed configuration to Caddy again
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/reload", nil, nil, nil)
+}
+
+// AuditLog returns the most recent audit log entries
+func (c *Client) AuditLog(ctx context.Context) ([]audit.Entry, error) {
+	var response struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/audit-log", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Entries, nil
+}
+
+// ServerSettings returns the HTTP versions the Caddy servers accept
+func (c *Client) ServerSettings(ctx context.Context) (*ServerSettings, error) {
+	return c.serverSettingsRequest(ctx, http.MethodGet, nil)
+}
+
+// UpdateServerSettings replaces the HTTP versions the Caddy servers accept and applies them
+func (c *Client) UpdateServerSettings(ctx context.Context, settings models.ServerSettings) (*ServerSettings, error) {
+	return c.serverSettingsRequest(ctx, http.MethodPut, settings)
+}
+
+// serverSettingsRequest sends a request answered with the server settings
+func (c *Client) serverSettingsRequest(ctx context.Context, method string, in any) (*ServerSettings, error) {
+	var settings ServerSettings
+	if err := c.do(ctx, method, "/api/settings/server", nil, in, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// StorageSettings returns where Caddy keeps certificates, without the password or token
+func (c *Client) StorageSettings(ctx context.Context) (*models.StorageSettings, error) {
+	return c.storageSettingsRequest(ctx, http.MethodGet, nil)
+}
+
+// UpdateStorageSettings changes where Caddy keeps certificates and applies it
+func (c *Client) UpdateStorageSettings(ctx context.Context, settings models.StorageSettings) (*models.StorageSettings, error) {
+	return c.storageSettingsRequest(ctx, http.MethodPut, settings)
+}
+
+// storageSettingsRequest sends a request answered with the storage settings
+func (c *Client) storageSettingsRequest(ctx context.Context, method string, in any) (*models.StorageSettings, error) {
+	var settings models.StorageSettings
+	if err := c.do(ctx, method, "/api/settings/storage", nil, in, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// DefaultSite returns how Caddy answers requests for hostnames no proxy or redirect serves
+func (c *Client) DefaultSite(ctx context.Context) (*models.DefaultSite, error) {
+	return c.defaultSiteRequest(ctx, http.MethodGet, nil)
+}
+
+// UpdateDefaultSite enables or disables the catch-all default site and applies it
+func (c *Client) UpdateDefaultSite(ctx context.Context, site models.DefaultSite) (*models.DefaultSite, error) {
+	return c.defaultSiteRequest(ctx, http.MethodPut, site)
+}
+
+// defaultSiteRequest sends a request answered with the default site settings
+func (c *Client) defaultSiteRequest(ctx context.Context, method string, in any) (*models.DefaultSite, error) {
+	var site models.DefaultSite
+	if err := c.do(ctx, method, "/api/settings/default-site", nil, in, &site); err != nil {
+		return nil, err
+	}
+	return &site, nil
+}
+
+// ExportConfig returns the proxies, redirects and Caddy's running config with every secret masked
+func (c *Client) ExportConfig(ctx context.Context) (json.RawMessage, error) {
+	var export json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/config/export", nil, nil, &export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// ExportCaddyfile returns the managed proxies and redirects as a Caddyfile
+func (c *Client) ExportCaddyfile(ctx context.Context) (string, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/export/caddyfile", nil, "", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	caddyfile, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Caddyfile: %v", err)
+	}
+	return string(caddyfile), nil
+}
+
+// Backup writes a gzipped tar archive of the manager's state to w. It includes the secrets key,
+// so store it as carefully as the data directory itself.
+func (c *Client) Backup(ctx context.Context, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/backup", nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download backup: %v", err)
+	}
+	return nil
+}
+
+// Restore replaces the manager's state with an archive written by Backup
+func (c *Client) Restore(ctx context.Context, archive io.Reader) (*RestoreResult, error) {
+	// Read the archive up front so the request can be retried
+	body, err := io.ReadAll(io.LimitReader(archive, maxRestoreBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %v", err)
+	}
+	if len(body) > maxRestoreBytes {
+		return nil, fmt.Errorf("backup is larger than %d MB", maxRestoreBytes>>20)
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/api/restore", nil, "application/gzip", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result RestoreResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return &result, nil
+}
+
+// UpdateResult describes a self-update
+type UpdateResult struct {
+	Updated    bool   `json:"updated"` // false when already running the latest release
+	Version    string `json:"version"`
+	Restarting bool   `json:"restarting"`
+}
+
+// Secret is a generated password, token or htpasswd entry
+type Secret struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Username string `json:"username,omitempty"` // set for htpasswd
+	Hash     string `json:"hash,omitempty"`     // bcrypt hash of Value, set for htpasswd
+	Htpasswd string `json:"htpasswd,omitempty"` // "username:hash", set for htpasswd
+}
+
+// IDRename is a proxy or redirect given a readable ID
+type IDRename struct {
+	Kind  string `json:"kind"` // "proxy" or "redirect"
+	OldID string `json:"old_id"`
+	NewID string `json:"new_id"`
+}
+
+// CSVImport is the outcome of importing proxies or redirects from CSV
+type CSVImport struct {
+	DryRun   bool           `json:"dry_run"`
+	Type     string         `json:"type"`  // "proxies" or "redirects"
+	Valid    bool           `json:"valid"` // every row is valid
+	Invalid  int            `json:"invalid"`
+	Imported int            `json:"imported"`
+	Results  []CSVImportRow `json:"results"`
+}
+
+// CSVImportRow is the outcome of one CSV row
+type CSVImportRow struct {
+	Row      int      `json:"row"` // line number in the file, the header is row 1
+	Domain   string   `json:"domain"`
+	Valid    bool     `json:"valid"`
+	Imported bool     `json:"imported"`
+	ID       string   `json:"id,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Code     string   `json:"code,omitempty"` // set for domain validation errors
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Health reports whether the manager is up, without authentication
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/api/health", nil, nil, nil)
+}
+
+// ApplyUpdate updates the server to the latest release, after which it restarts. The server
+// needs SELF_UPDATE=true.
+func (c *Client) ApplyUpdate(ctx context.Context) (*UpdateResult, error) {
+	var result UpdateResult
+	if err := c.do(ctx, http.MethodPost, "/api/update", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GenerateSecret generates a secret of secretType, "password", "token" or "htpasswd", without
+// storing it. length applies to passwords, 0 for the default; username to htpasswd entries.
+func (c *Client) GenerateSecret(ctx context.Context, secretType string, length int, username string) (*Secret, error) {
+	query := url.Values{"type": {secretType}}
+	if length > 0 {
+		query.Set("length", strconv.Itoa(length))
+	}
+	if username != "" {
+		query.Set("username", username)
+	}
+
+	var secret Secret
+	if err := c.do(ctx, http.MethodGet, "/api/generate/secret", query, nil, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// MigrateIDs renames proxies and redirects with timestamp IDs to readable IDs. Requests naming
+// an old ID are redirected to the new one.
+func (c *Client) MigrateIDs(ctx context.Context) ([]IDRename, error) {
+	var response struct {
+		Renamed []IDRename `json:"renamed"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/ids/migrate", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Renamed, nil
+}
+
+// ImportCSV imports "proxies" or "redirects" from a CSV file with a header row. dryRun validates
+// every row without importing any. Nothing is imported when a row is invalid; the returned
+// *APIError's Body then holds the results.
+func (c *Client) ImportCSV(ctx context.Context, importType string, csv []byte, dryRun bool) (*CSVImport, error) {
+	query := url.Values{"type": {importType}}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/api/import/csv", query, "text/csv", csv)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result CSVImport
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return &result, nil
+}
+
+// Pages lists the custom error and default site page templates, without their bodies
+func (c *Client) Pages(ctx context.Context) ([]pages.Template, error) {
+	var response struct {
+		Templates []pages.Template `json:"templates"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/pages", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Templates, nil
+}
+
+// GetPage returns a page template of kind, "error" or "default-site", for language, "default" or
+// a language tag such as "de"
+func (c *Client) GetPage(ctx context.Context, kind, language string) (*pages.Template, error) {
+	var template pages.Template
+	if err := c.do(ctx, http.MethodGet, pagePath(kind, language), nil, nil, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// SavePage stores a page template and reports whether Caddy serves it yet
+func (c *Client) SavePage(ctx context.Context, kind, language, body string) (*pages.Template, bool, error) {
+	var response struct {
+		Template pages.Template `json:"template"`
+		Applied  bool           `json:"applied"`
+	}
+	if err := c.do(ctx, http.MethodPut, pagePath(kind, language), nil, map[string]string{"body": body}, &response); err != nil {
+		return nil, false, err
+	}
+	return &response.Template, response.Applied, nil
+}
+
+// DeletePage removes a page template, falling back to the default language or Caddy's own response
+func (c *Client) DeletePage(ctx context.Context, kind, language string) error {
+	return c.do(ctx, http.MethodDelete, pagePath(kind, language), nil, nil, nil)
+}
+
+// pagePath returns the path of a page template
+func pagePath(kind, language string) string {
+	return "/api/pages/" + pathID(kind) + "/" + pathID(language)
+}
+
+// Presets lists the application presets, proxy settings for common self-hosted applications
+func (c *Client) Presets(ctx context.Context) ([]presets.Preset, error) {
+	var response struct {
+		Presets []presets.Preset `json:"presets"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/presets", nil, nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Presets, nil
+}
+
+// GetPreset returns an application preset
+func (c *Client) GetPreset(ctx context.Context, id string) (*presets.Preset, error) {
+	var preset presets.Preset
+	if err := c.do(ctx, http.MethodGet, "/api/presets/"+pathID(id), nil, nil, &preset); err != nil {
+		return nil, err
+	}
+	return &preset, nil
+}
+
+// RemoteBackups lists the backups on the remote target configured with BACKUP_S3_* or
+// BACKUP_WEBDAV_*
+func (c *Client) RemoteBackups(ctx context.Context) (*RemoteBackups, error) {
+	var backups RemoteBackups
+	if err := c.do(ctx, http.MethodGet, "/api/backup/remote", nil, nil, &backups); err != nil {
+		return nil, err
+	}
+	return &backups, nil
+}
+
+// CreateRemoteBackup uploads an encrypted backup to the remote target and returns its name
+func (c *Client) CreateRemoteBackup(ctx context.Context) (string, error) {
+	var created struct {
+		Name string `json:"name"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/backup/remote", nil, nil, &created); err != nil {
+		return "", err
+	}
+	return created.Name, nil
+}
+
+// RestoreRemoteBackup replaces the manager's state with a backup from the remote target
+func (c *Client) RestoreRemoteBackup(ctx context.Context, name string) (*RestoreResult, error) {
+	var result RestoreResult
+	if err := c.do(ctx, http.MethodPost, "/api/backup/remote/"+pathID(name)+"/restore", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
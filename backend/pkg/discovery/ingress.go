@@ -0,0 +1,160 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Ingress is a Kubernetes Ingress. Its hosts are already routed to Services in the cluster, so
+// each rule is offered as a domain along with the target URL of its backend.
+type Ingress struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Class     string        `json:"class,omitempty"` // spec.ingressClassName
+	Rules     []IngressRule `json:"rules"`
+}
+
+// IngressRule is a host and path an Ingress routes to a Service
+type IngressRule struct {
+	Host    string `json:"host,omitempty"` // empty for the default backend and rules matching every host
+	Path    string `json:"path,omitempty"`
+	TLS     bool   `json:"tls"`     // the Ingress lists the host under spec.tls
+	Service string `json:"service"` // in the Ingress's namespace
+	Port    string `json:"port"`    // name or number
+	Target  string `json:"target,omitempty"`
+	Error   string `json:"error,omitempty"` // why the backend has no target URL
+}
+
+// ingressBackend is the part of a Kubernetes IngressBackend the discovery reads. Resource
+// backends have no service and are skipped.
+type ingressBackend struct {
+	Service *struct {
+		Name string `json:"name"`
+		Port struct {
+			Name   string `json:"name"`
+			Number int    `json:"number"`
+		} `json:"port"`
+	} `json:"service"`
+}
+
+// ingressList is the part of a Kubernetes IngressList the discovery reads
+type ingressList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			IngressClassName string          `json:"ingressClassName"`
+			DefaultBackend   *ingressBackend `json:"defaultBackend"`
+			TLS              []struct {
+				Hosts []string `json:"hosts"`
+			} `json:"tls"`
+			Rules []struct {
+				Host string `json:"host"`
+				HTTP *struct {
+					Paths []struct {
+						Path    string         `json:"path"`
+						Backend ingressBackend `json:"backend"`
+					} `json:"paths"`
+				} `json:"http"`
+			} `json:"rules"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// Ingresses lists the Ingresses of the configured namespace, or of every namespace. services,
+// from Services, give the target URLs of the backends.
+func (k *Kubernetes) Ingresses(ctx context.Context, services []Service) ([]Ingress, error) {
+	var list ingressList
+	if err := k.list(ctx, "/apis/networking.k8s.io/v1", "ingresses", &list); err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]Service, len(services))
+	for _, service := range services {
+		byKey[service.Key()] = service
+	}
+
+	ingresses := make([]Ingress, 0, len(list.Items))
+	for _, item := range list.Items {
+		ingress := Ingress{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Name,
+			Class:     item.Spec.IngressClassName,
+			Rules:     []IngressRule{},
+		}
+
+		tlsHosts := map[string]bool{}
+		for _, tls := range item.Spec.TLS {
+			for _, host := range tls.Hosts {
+				tlsHosts[host] = true
+			}
+		}
+
+		addRule := func(host, path string, backend ingressBackend) {
+			if backend.Service == nil {
+				return
+			}
+			rule := IngressRule{
+				Host:    host,
+				Path:    path,
+				TLS:     host != "" && tlsHosts[host],
+				Service: backend.Service.Name,
+				Port:    backend.Service.Port.Name,
+			}
+			if rule.Port == "" {
+				rule.Port = strconv.Itoa(backend.Service.Port.Number)
+			}
+
+			service, ok := byKey[item.Metadata.Namespace+"/"+backend.Service.Name]
+			if !ok {
+				rule.Error = fmt.Sprintf("Service %s not found", backend.Service.Name)
+			} else if target, err := backendTarget(service, backend.Service.Port.Name, backend.Service.Port.Number); err != nil {
+				rule.Error = err.Error()
+			} else {
+				rule.Target = target
+			}
+			ingress.Rules = append(ingress.Rules, rule)
+		}
+
+		if item.Spec.DefaultBackend != nil {
+			addRule("", "", *item.Spec.DefaultBackend)
+		}
+		for _, rule := range item.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				addRule(rule.Host, path.Path, path.Backend)
+			}
+		}
+		ingresses = append(ingresses, ingress)
+	}
+
+	slices.SortFunc(ingresses, func(a, b Ingress) int {
+		return strings.Compare(a.Namespace+"/"+a.Name, b.Namespace+"/"+b.Name)
+	})
+	return ingresses, nil
+}
+
+// backendTarget builds the target URL of the Service port an Ingress backend names by name or
+// number
+func backendTarget(service Service, portName string, portNumber int) (string, error) {
+	for _, port := range service.Ports {
+		if port.Protocol != "TCP" {
+			continue
+		}
+		if (portName != "" && port.Name == portName) || (portName == "" && port.Port == portNumber) {
+			return serviceTarget(service.host, port.Name, port.Port, ""), nil
+		}
+	}
+
+	if portName != "" {
+		return "", fmt.Errorf("the Service has no TCP port %q", portName)
+	}
+	return "", fmt.Errorf("the Service has no TCP port %d", portNumber)
+}
@@ -0,0 +1,119 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testServices = `{"items": [
+	{"metadata": {"name": "web", "namespace": "prod"},
+	 "spec": {"type": "ClusterIP", "clusterIP": "10.0.0.10", "ports": [{"name": "http", "port": 8080}, {"name": "metrics", "port": 9090}]}},
+	{"metadata": {"name": "api", "namespace": "prod"},
+	 "spec": {"type": "ClusterIP", "clusterIP": "10.0.0.11", "ports": [{"name": "https", "port": 443}]}},
+	{"metadata": {"name": "dns", "namespace": "prod"},
+	 "spec": {"type": "ClusterIP", "ports": [{"name": "dns", "port": 53, "protocol": "UDP"}]}}
+]}`
+
+const testIngresses = `{"items": [
+	{"metadata": {"name": "site", "namespace": "prod"},
+	 "spec": {
+		"ingressClassName": "nginx",
+		"defaultBackend": {"service": {"name": "web", "port": {"number": 8080}}},
+		"tls": [{"hosts": ["app.example.com"], "secretName": "app-tls"}],
+		"rules": [
+			{"host": "app.example.com", "http": {"paths": [
+				{"path": "/", "pathType": "Prefix", "backend": {"service": {"name": "web", "port": {"name": "http"}}}},
+				{"path": "/api", "pathType": "Prefix", "backend": {"service": {"name": "api", "port": {"number": 443}}}},
+				{"path": "/static", "pathType": "Prefix", "backend": {"resource": {"kind": "StorageBucket", "name": "assets"}}}
+			]}},
+			{"host": "broken.example.com", "http": {"paths": [
+				{"path": "/", "backend": {"service": {"name": "missing", "port": {"number": 80}}}},
+				{"path": "/dns", "backend": {"service": {"name": "dns", "port": {"name": "dns"}}}}
+			]}}
+		]}}
+]}`
+
+// fakeKubernetes serves Service and Ingress lists; ingressStatus other than 200 fails Ingress
+// listings
+func fakeKubernetes(t *testing.T, ingressStatus int) *Kubernetes {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/services":
+			w.Write([]byte(testServices))
+		case "/apis/networking.k8s.io/v1/ingresses":
+			if ingressStatus != http.StatusOK {
+				http.Error(w, `ingresses.networking.k8s.io is forbidden`, ingressStatus)
+				return
+			}
+			w.Write([]byte(testIngresses))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	kubernetes, err := NewKubernetes(KubernetesConfig{APIURL: server.URL, Token: "test-token"})
+	if err != nil {
+		t.Fatalf("NewKubernetes failed: %v", err)
+	}
+	return kubernetes
+}
+
+func TestIngresses(t *testing.T) {
+	kubernetes := fakeKubernetes(t, http.StatusOK)
+	ctx := context.Background()
+
+	services, err := kubernetes.Services(ctx)
+	if err != nil {
+		t.Fatalf("Services failed: %v", err)
+	}
+	ingresses, err := kubernetes.Ingresses(ctx, services)
+	if err != nil {
+		t.Fatalf("Ingresses failed: %v", err)
+	}
+
+	want := []Ingress{{
+		Namespace: "prod",
+		Name:      "site",
+		Class:     "nginx",
+		Rules: []IngressRule{
+			{Service: "web", Port: "8080", Target: "http://web.prod.svc.cluster.local:8080"},
+			{Host: "app.example.com", Path: "/", TLS: true, Service: "web", Port: "http", Target: "http://web.prod.svc.cluster.local:8080"},
+			{Host: "app.example.com", Path: "/api", TLS: true, Service: "api", Port: "443", Target: "https://api.prod.svc.cluster.local:443"},
+			{Host: "broken.example.com", Path: "/", Service: "missing", Port: "80", Error: "Service missing not found"},
+			{Host: "broken.example.com", Path: "/dns", Service: "dns", Port: "dns", Error: `the Service has no TCP port "dns"`},
+		},
+	}}
+	if !reflect.DeepEqual(ingresses, want) {
+		t.Errorf("Ingresses =\n%+v\nwant\n%+v", ingresses, want)
+	}
+}
+
+func TestWatcherKeepsServicesWhenIngressesFail(t *testing.T) {
+	watcher := NewWatcher(fakeKubernetes(t, http.StatusForbidden), time.Minute, nil)
+	watcher.poll(context.Background())
+
+	snapshot := watcher.Snapshot()
+	if snapshot.Error != "" {
+		t.Errorf("Error = %q, want none", snapshot.Error)
+	}
+	if len(snapshot.Services) != 3 {
+		t.Errorf("got %d Services, want 3", len(snapshot.Services))
+	}
+	if len(snapshot.Ingresses) != 0 {
+		t.Errorf("got %d Ingresses, want none", len(snapshot.Ingresses))
+	}
+	if snapshot.IngressError == "" {
+		t.Error("IngressError is empty, want the listing error")
+	}
+}
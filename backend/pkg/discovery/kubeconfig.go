@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// kubeconfig is the part of a kubeconfig file the discovery reads
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Exec                  any    `yaml:"exec"`
+			AuthProvider          any    `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// LoadKubeconfig reads the API server, CA and credentials of a context from a kubeconfig file, the
+// file's current context when contextName is empty. Relative file paths in the kubeconfig are
+// resolved against its directory. Exec and auth-provider credential plugins aren't run, so a user
+// needs a token or a client certificate.
+func LoadKubeconfig(path, contextName string) (KubernetesConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return KubernetesConfig{}, fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+
+	var file kubeconfig
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return KubernetesConfig{}, fmt.Errorf("failed to parse kubeconfig %s: %v", path, err)
+	}
+
+	if contextName == "" {
+		contextName = file.CurrentContext
+	}
+	if contextName == "" {
+		return KubernetesConfig{}, fmt.Errorf("kubeconfig %s has no current-context", path)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, context := range file.Contexts {
+		if context.Name == contextName {
+			clusterName, userName = context.Context.Cluster, context.Context.User
+			found = true
+			break
+		}
+	}
+	if !found {
+		return KubernetesConfig{}, fmt.Errorf("kubeconfig %s has no context %q", path, contextName)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	var config KubernetesConfig
+	found = false
+	for _, cluster := range file.Clusters {
+		if cluster.Name != clusterName {
+			continue
+		}
+		config.APIURL = cluster.Cluster.Server
+		config.CAFile = resolve(cluster.Cluster.CertificateAuthority)
+		config.Insecure = cluster.Cluster.InsecureSkipTLSVerify
+		if config.CAData, err = decodeKubeconfigData(cluster.Cluster.CertificateAuthorityData); err != nil {
+			return KubernetesConfig{}, fmt.Errorf("invalid certificate-authority-data of cluster %q: %v", clusterName, err)
+		}
+		found = true
+		break
+	}
+	if !found {
+		return KubernetesConfig{}, fmt.Errorf("kubeconfig %s has no cluster %q", path, clusterName)
+	}
+
+	// A context without a user, e.g. for kubectl proxy, sends no credentials
+	for _, user := range file.Users {
+		if userName == "" || user.Name != userName {
+			continue
+		}
+		plugin := user.User.Exec != nil || user.User.AuthProvider != nil
+		if plugin && user.User.Token == "" && user.User.TokenFile == "" && user.User.ClientCertificate == "" && user.User.ClientCertificateData == "" {
+			return KubernetesConfig{}, fmt.Errorf("user %q of kubeconfig %s uses a credential plugin, which isn't supported; use a token or client certificate", userName, path)
+		}
+		config.Token = user.User.Token
+		config.TokenFile = resolve(user.User.TokenFile)
+		config.CertFile = resolve(user.User.ClientCertificate)
+		config.KeyFile = resolve(user.User.ClientKey)
+		if config.CertData, err = decodeKubeconfigData(user.User.ClientCertificateData); err != nil {
+			return KubernetesConfig{}, fmt.Errorf("invalid client-certificate-data of user %q: %v", userName, err)
+		}
+		if config.KeyData, err = decodeKubeconfigData(user.User.ClientKeyData); err != nil {
+			return KubernetesConfig{}, fmt.Errorf("invalid client-key-data of user %q: %v", userName, err)
+		}
+		break
+	}
+
+	return config, nil
+}
+
+// decodeKubeconfigData decodes a base64 *-data field of a kubeconfig
+func decodeKubeconfigData(data string) ([]byte, error) {
+	if data == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
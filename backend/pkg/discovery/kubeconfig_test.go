@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClientCert creates a self-signed client certificate and its key as PEM
+func testClientCert(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "discovery"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestLoadKubeconfig(t *testing.T) {
+	certPEM, keyPEM := testClientCert(t)
+
+	// The API server accepts the token or the client certificate
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromCert := r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && r.TLS.PeerCertificates[0].Subject.CommonName == "discovery"
+		if r.Header.Get("Authorization") != "Bearer test-token" && !fromCert {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(testServices))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{"ca.crt": caPEM, "client.crt": certPEM, "client.key": keyPEM} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kubeconfig := filepath.Join(dir, "config")
+	content := `apiVersion: v1
+kind: Config
+current-context: token
+clusters:
+- name: inline
+  cluster:
+    server: ` + server.URL + `
+    certificate-authority-data: ` + base64.StdEncoding.EncodeToString(caPEM) + `
+- name: files
+  cluster:
+    server: ` + server.URL + `
+    certificate-authority: ca.crt
+users:
+- name: token-user
+  user:
+    token: test-token
+- name: cert-user
+  user:
+    client-certificate: client.crt
+    client-key: client.key
+- name: plugin-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: aws
+contexts:
+- name: token
+  context:
+    cluster: inline
+    user: token-user
+- name: cert
+  context:
+    cluster: files
+    user: cert-user
+- name: plugin
+  context:
+    cluster: inline
+    user: plugin-user
+- name: anonymous
+  context:
+    cluster: inline
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, contextName := range []string{"", "cert"} {
+		config, err := LoadKubeconfig(kubeconfig, contextName)
+		if err != nil {
+			t.Fatalf("LoadKubeconfig(%q) failed: %v", contextName, err)
+		}
+		if config.APIURL != server.URL {
+			t.Errorf("LoadKubeconfig(%q) API URL = %q, want %q", contextName, config.APIURL, server.URL)
+		}
+
+		kubernetes, err := NewKubernetes(config)
+		if err != nil {
+			t.Fatalf("NewKubernetes(%q) failed: %v", contextName, err)
+		}
+		services, err := kubernetes.Services(t.Context())
+		if err != nil {
+			t.Fatalf("Services with context %q failed: %v", contextName, err)
+		}
+		if len(services) != 3 {
+			t.Errorf("Services with context %q = %d services, want 3", contextName, len(services))
+		}
+	}
+
+	// Without credentials the API server refuses the listing, but the connection is verified
+	config, err := LoadKubeconfig(kubeconfig, "anonymous")
+	if err != nil {
+		t.Fatalf("LoadKubeconfig(anonymous) failed: %v", err)
+	}
+	kubernetes, err := NewKubernetes(config)
+	if err != nil {
+		t.Fatalf("NewKubernetes(anonymous) failed: %v", err)
+	}
+	if _, err := kubernetes.Services(t.Context()); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Services without credentials = %v, want a 401 error", err)
+	}
+
+	if _, err := LoadKubeconfig(kubeconfig, "plugin"); err == nil || !strings.Contains(err.Error(), "credential plugin") {
+		t.Errorf("LoadKubeconfig(plugin) = %v, want a credential plugin error", err)
+	}
+	if _, err := LoadKubeconfig(kubeconfig, "missing"); err == nil {
+		t.Error("LoadKubeconfig(missing) succeeded, want an error")
+	}
+}
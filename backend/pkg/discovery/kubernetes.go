@@ -0,0 +1,325 @@
+// Package discovery finds upstreams for proxies in orchestrators such as Kubernetes.
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotations read from Services. A Service with the domain annotation is synced into a proxy.
+const (
+	AnnotationPrefix          = "caddyproxymanager.io/"
+	AnnotationDomain          = AnnotationPrefix + "domain"
+	AnnotationPort            = AnnotationPrefix + "port"   // port name or number, the first TCP port when unset
+	AnnotationScheme          = AnnotationPrefix + "scheme" // http or https, https for ports named https or numbered 443 when unset
+	AnnotationSSLMode         = AnnotationPrefix + "ssl-mode"
+	AnnotationHealthCheckPath = AnnotationPrefix + "health-check-path"
+)
+
+// In-cluster service account files
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	tokenFile         = serviceAccountDir + "/token"
+	caFile            = serviceAccountDir + "/ca.crt"
+)
+
+// KubernetesConfig locates and authenticates against a Kubernetes API server
+type KubernetesConfig struct {
+	APIURL        string // e.g. https://10.0.0.1:443, or http://127.0.0.1:8001 for kubectl proxy
+	Token         string // bearer token, read from TokenFile on every request when empty
+	TokenFile     string
+	CAFile        string // CA bundle of the API server, the system roots when empty
+	CAData        []byte // PEM CA bundle, used instead of CAFile when set
+	CertFile      string // client certificate and key, for clusters that authenticate with certificates
+	KeyFile       string
+	CertData      []byte // PEM client certificate and key, used instead of CertFile and KeyFile when set
+	KeyData       []byte
+	Insecure      bool   // skip verifying the API server's certificate
+	Namespace     string // empty for every namespace
+	ClusterDomain string // DNS suffix of Service names, cluster.local when empty
+}
+
+// InClusterConfig returns the config of the pod's service account, reporting false outside of a
+// cluster
+func InClusterConfig() (KubernetesConfig, bool) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return KubernetesConfig{}, false
+	}
+	if _, err := os.Stat(tokenFile); err != nil {
+		return KubernetesConfig{}, false
+	}
+	return KubernetesConfig{
+		APIURL:    "https://" + net.JoinHostPort(host, port),
+		TokenFile: tokenFile,
+		CAFile:    caFile,
+	}, true
+}
+
+// Kubernetes lists Services from a Kubernetes API server
+type Kubernetes struct {
+	config KubernetesConfig
+	client *http.Client
+}
+
+// NewKubernetes creates a client for the API server in config
+func NewKubernetes(config KubernetesConfig) (*Kubernetes, error) {
+	if _, err := url.Parse(config.APIURL); err != nil || config.APIURL == "" {
+		return nil, fmt.Errorf("invalid Kubernetes API URL %q", config.APIURL)
+	}
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+	if config.ClusterDomain == "" {
+		config.ClusterDomain = "cluster.local"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &Kubernetes{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+// tlsConfig builds the TLS settings of the API server connection from the CA and client
+// certificate in config
+func (config KubernetesConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+
+	caPEM := config.CAData
+	if len(caPEM) == 0 && config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes CA: %v", err)
+		}
+		caPEM = pem
+	}
+	if len(caPEM) > 0 {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in the Kubernetes CA")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	certPEM, keyPEM := config.CertData, config.KeyData
+	if len(certPEM) == 0 && config.CertFile != "" {
+		pem, err := os.ReadFile(config.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes client certificate: %v", err)
+		}
+		certPEM = pem
+	}
+	if len(keyPEM) == 0 && config.KeyFile != "" {
+		pem, err := os.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes client key: %v", err)
+		}
+		keyPEM = pem
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kubernetes client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Service is a Kubernetes Service offered as an upstream
+type Service struct {
+	Namespace  string        `json:"namespace"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type"` // ClusterIP, NodePort, LoadBalancer or ExternalName
+	ClusterIP  string        `json:"cluster_ip,omitempty"`
+	Ports      []ServicePort `json:"ports"`
+	Targets    []string      `json:"targets"`            // target URLs for the TCP ports, by cluster DNS name
+	Domain     string        `json:"domain,omitempty"`   // from the domain annotation
+	Target     string        `json:"target,omitempty"`   // the target a synced proxy uses
+	SSLMode    string        `json:"ssl_mode,omitempty"` // from the ssl-mode annotation
+	HealthPath string        `json:"health_check_path,omitempty"`
+	Error      string        `json:"error,omitempty"` // why an annotated Service can't be synced
+
+	host string // cluster DNS name, or the external name, that target URLs use
+}
+
+// ServicePort is a port a Service exposes
+type ServicePort struct {
+	Name     string `json:"name,omitempty"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// Key identifies a Service across listings
+func (s Service) Key() string {
+	return s.Namespace + "/" + s.Name
+}
+
+// serviceList is the part of a Kubernetes ServiceList the discovery reads
+type serviceList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Type         string `json:"type"`
+			ClusterIP    string `json:"clusterIP"`
+			ExternalName string `json:"externalName"`
+			Ports        []struct {
+				Name     string `json:"name"`
+				Port     int    `json:"port"`
+				Protocol string `json:"protocol"`
+			} `json:"ports"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// Services lists the Services of the configured namespace, or of every namespace
+func (k *Kubernetes) Services(ctx context.Context) ([]Service, error) {
+	var list serviceList
+	if err := k.list(ctx, "/api/v1", "services", &list); err != nil {
+		return nil, err
+	}
+
+	services := make([]Service, 0, len(list.Items))
+	for _, item := range list.Items {
+		service := Service{
+			Namespace: item.Metadata.Namespace,
+			Name:      item.Metadata.Name,
+			Type:      item.Spec.Type,
+			ClusterIP: item.Spec.ClusterIP,
+			Ports:     []ServicePort{},
+			Targets:   []string{},
+		}
+
+		host := item.Metadata.Name + "." + item.Metadata.Namespace + ".svc." + k.config.ClusterDomain
+		if item.Spec.Type == "ExternalName" {
+			host = item.Spec.ExternalName
+		}
+		service.host = host
+		for _, port := range item.Spec.Ports {
+			if port.Protocol == "" {
+				port.Protocol = "TCP"
+			}
+			service.Ports = append(service.Ports, ServicePort{Name: port.Name, Port: port.Port, Protocol: port.Protocol})
+			if port.Protocol == "TCP" {
+				service.Targets = append(service.Targets, serviceTarget(host, port.Name, port.Port, ""))
+			}
+		}
+
+		annotations := item.Metadata.Annotations
+		if domain := strings.TrimSpace(annotations[AnnotationDomain]); domain != "" {
+			service.Domain = domain
+			service.SSLMode = annotations[AnnotationSSLMode]
+			service.HealthPath = annotations[AnnotationHealthCheckPath]
+			target, err := annotatedTarget(host, service.Ports, annotations)
+			if err != nil {
+				service.Error = err.Error()
+			}
+			service.Target = target
+		}
+		services = append(services, service)
+	}
+
+	slices.SortFunc(services, func(a, b Service) int { return strings.Compare(a.Key(), b.Key()) })
+	return services, nil
+}
+
+// list gets the resources of the configured namespace, or of every namespace, from an API group
+// path such as /api/v1 and decodes the list into v
+func (k *Kubernetes) list(ctx context.Context, group, resource string, v any) error {
+	path := group + "/" + resource
+	if k.config.Namespace != "" {
+		path = group + "/namespaces/" + url.PathEscape(k.config.Namespace) + "/" + resource
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.config.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	token := k.config.Token
+	if token == "" && k.config.TokenFile != "" {
+		// Projected service account tokens are rotated, so the file is read every time
+		raw, err := os.ReadFile(k.config.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read Kubernetes token: %v", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the Kubernetes API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Kubernetes API returned status %d listing %s: %s", resp.StatusCode, resource, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", resource, err)
+	}
+	return nil
+}
+
+// annotatedTarget picks the target URL of an annotated Service from its port and scheme
+// annotations
+func annotatedTarget(host string, ports []ServicePort, annotations map[string]string) (string, error) {
+	scheme := strings.ToLower(strings.TrimSpace(annotations[AnnotationScheme]))
+	if scheme != "" && scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("%s must be http or https", AnnotationScheme)
+	}
+
+	wanted := strings.TrimSpace(annotations[AnnotationPort])
+	for _, port := range ports {
+		if port.Protocol != "TCP" {
+			continue
+		}
+		if wanted == "" || wanted == port.Name || wanted == strconv.Itoa(port.Port) {
+			return serviceTarget(host, port.Name, port.Port, scheme), nil
+		}
+	}
+
+	if wanted != "" {
+		return "", fmt.Errorf("the Service has no TCP port %q", wanted)
+	}
+	return "", fmt.Errorf("the Service has no TCP port")
+}
+
+// serviceTarget builds the target URL of a Service port, guessing the scheme from the port unless
+// one is given
+func serviceTarget(host, portName string, port int, scheme string) string {
+	if scheme == "" {
+		scheme = "http"
+		if port == 443 || portName == "https" {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// SyncFunc syncs annotated Services into proxies, returning a status per Service key
+type SyncFunc func(ctx context.Context, services []Service) map[string]string
+
+// Watcher polls Kubernetes for Services and Ingresses and keeps the latest listing
+type Watcher struct {
+	kubernetes *Kubernetes
+	interval   time.Duration
+	sync       SyncFunc
+
+	mu         sync.RWMutex
+	services   []Service
+	ingresses  []Ingress
+	statuses   map[string]string
+	err        error
+	ingressErr error
+	checkedAt  time.Time
+}
+
+// Snapshot is the latest Service and Ingress listing of a Watcher
+type Snapshot struct {
+	Services     []Service         `json:"services"`
+	Ingresses    []Ingress         `json:"ingresses"`
+	SyncStatus   map[string]string `json:"sync_status,omitempty"` // by namespace/name, for annotated Services
+	SyncEnabled  bool              `json:"sync_enabled"`
+	CheckedAt    *time.Time        `json:"checked_at,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	IngressError string            `json:"ingress_error,omitempty"` // e.g. when the account may not list Ingresses
+}
+
+// NewWatcher creates a Watcher polling every interval; sync is called with every successful
+// listing and may be nil
+func NewWatcher(kubernetes *Kubernetes, interval time.Duration, sync SyncFunc) *Watcher {
+	return &Watcher{kubernetes: kubernetes, interval: interval, sync: sync}
+}
+
+// Run polls until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	services, err := w.kubernetes.Services(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Kubernetes discovery failed: %v", err)
+		}
+		w.mu.Lock()
+		w.err = err
+		w.checkedAt = time.Now()
+		w.mu.Unlock()
+		return
+	}
+
+	// Ingresses are optional: without them, or without permission to list them, Services are
+	// still offered and synced
+	ingresses, ingressErr := w.kubernetes.Ingresses(ctx, services)
+	w.mu.RLock()
+	previous := w.ingressErr
+	w.mu.RUnlock()
+	// Logged once per distinct error, as a missing permission would otherwise be logged every poll
+	if ingressErr != nil && ctx.Err() == nil && (previous == nil || previous.Error() != ingressErr.Error()) {
+		log.Printf("Kubernetes Ingress discovery failed: %v", ingressErr)
+	}
+
+	var statuses map[string]string
+	if w.sync != nil {
+		statuses = w.sync(ctx, services)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.services = services
+	if ingressErr == nil {
+		w.ingresses = ingresses
+	}
+	w.statuses = statuses
+	w.err = nil
+	w.ingressErr = ingressErr
+	w.checkedAt = time.Now()
+}
+
+// Snapshot returns the latest listing; Services and Ingresses are kept from the last successful
+// poll when the latest one failed
+func (w *Watcher) Snapshot() Snapshot {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	snapshot := Snapshot{
+		Services:    append([]Service{}, w.services...),
+		Ingresses:   append([]Ingress{}, w.ingresses...),
+		SyncStatus:  w.statuses,
+		SyncEnabled: w.sync != nil,
+	}
+	if !w.checkedAt.IsZero() {
+		checkedAt := w.checkedAt
+		snapshot.CheckedAt = &checkedAt
+	}
+	if w.err != nil {
+		snapshot.Error = w.err.Error()
+	}
+	if w.ingressErr != nil {
+		snapshot.IngressError = w.ingressErr.Error()
+	}
+	return snapshot
+}
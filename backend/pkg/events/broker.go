@@ -0,0 +1,148 @@
+// Package events fans out state changes, such as health transitions and proxy edits, to clients
+// streaming /api/events so they don't have to poll.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	TypeHealth   = "health"   // A proxy's health status changed
+	TypeProxy    = "proxy"    // A proxy was created, updated or deleted
+	TypeCaddy    = "caddy"    // Caddy's admin API became reachable or unreachable
+	TypeSecurity = "security" // The audit log analysis found a suspicious pattern
+	TypeConfig   = "config"   // The managed config was re-applied after Caddy lost it
+	TypeRedirect = "redirect" // A redirect was created, updated or deleted
+	TypeReload   = "reload"   // The managed config was pushed to Caddy again on request
+)
+
+// Proxy and redirect event actions
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// maxSubscribers caps concurrent streams, which are exempt from the API's in-flight limit
+const maxSubscribers = 100
+
+// ErrTooManySubscribers is returned by Subscribe when maxSubscribers streams are open
+var ErrTooManySubscribers = errors.New("too many event subscribers")
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before events are dropped
+const subscriberBuffer = 64
+
+// Event is a state change pushed to subscribers
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// HealthChange is the data of a health event
+type HealthChange struct {
+	ProxyID   string `json:"proxy_id"`
+	Domain    string `json:"domain"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+	Message   string `json:"message,omitempty"`
+	Runbook   string `json:"runbook,omitempty"`   // the proxy's runbook link, if any
+	Dashboard string `json:"dashboard,omitempty"` // the proxy's monitoring dashboard link, if any
+}
+
+// ProxyChange is the data of a proxy event
+type ProxyChange struct {
+	Action  string `json:"action"`
+	ProxyID string `json:"proxy_id"`
+	Domain  string `json:"domain,omitempty"`
+}
+
+// RedirectChange is the data of a redirect event
+type RedirectChange struct {
+	Action        string   `json:"action"`
+	RedirectID    string   `json:"redirect_id"`
+	SourceDomains []string `json:"source_domains,omitempty"`
+}
+
+// CaddyChange is the data of a caddy event
+type CaddyChange struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ConfigReapply is the data of a config event
+type ConfigReapply struct {
+	Routes int    `json:"routes"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Broker delivers published events to every subscriber
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	listeners   []func(Event)
+}
+
+// NewBroker creates a broker without subscribers
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving published events and a function that unsubscribes it
+func (b *Broker) Subscribe() (<-chan Event, func(), error) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if len(b.subscribers) >= maxSubscribers {
+		b.mu.Unlock()
+		return nil, nil, ErrTooManySubscribers
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}, nil
+}
+
+// OnPublish registers a listener called with every published event. Unlike subscribers, listeners
+// never miss events, so they must return quickly.
+func (b *Broker) OnPublish(listener func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+// Publish sends an event to every subscriber and listener. Subscribers whose buffer is full miss
+// the event rather than blocking the publisher.
+func (b *Broker) Publish(eventType string, data any) {
+	if b == nil {
+		return
+	}
+
+	event := Event{Type: eventType, Time: time.Now(), Data: data}
+
+	b.mu.Lock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	listeners := b.listeners
+	b.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
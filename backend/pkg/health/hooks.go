@@ -0,0 +1,83 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxHookOutput caps how much of a hook's output is logged
+const maxHookOutput = 4 << 10
+
+// CommandHook runs a local command whenever a proxy's health status changes, e.g. to restart a
+// container. The change is described in CPM_* environment variables.
+type CommandHook struct {
+	command string
+	timeout time.Duration
+}
+
+// NewCommandHook creates a hook running command, an executable path, for at most timeout
+func NewCommandHook(command string, timeout time.Duration) *CommandHook {
+	return &CommandHook{
+		command: command,
+		timeout: timeout,
+	}
+}
+
+// Register runs the hook on every status change of service
+func (h *CommandHook) Register(service *Service) {
+	service.OnStatusChange(func(proxy models.Proxy, oldStatus, newStatus, message string) {
+		// Run in the background so a slow hook doesn't delay the next health check
+		go h.Run(proxy, oldStatus, newStatus, message)
+	})
+}
+
+// Run executes the command for a status change and logs its result
+func (h *CommandHook) Run(proxy models.Proxy, oldStatus, newStatus, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.command)
+	cmd.Env = append(os.Environ(),
+		"CPM_PROXY_ID="+proxy.ID,
+		"CPM_PROXY_DOMAIN="+proxy.Domain,
+		"CPM_PROXY_TARGET="+proxy.TargetURL,
+		"CPM_OLD_STATUS="+oldStatus,
+		"CPM_NEW_STATUS="+newStatus,
+		"CPM_MESSAGE="+message,
+		"CPM_TIMESTAMP="+time.Now().UTC().Format(time.RFC3339),
+	)
+	if proxy.Links != nil {
+		cmd.Env = append(cmd.Env,
+			"CPM_DASHBOARD_URL="+proxy.Links.Dashboard,
+			"CPM_RUNBOOK_URL="+proxy.Links.Runbook,
+			"CPM_REPOSITORY_URL="+proxy.Links.Repository,
+		)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	out := strings.TrimSpace(output.String())
+	if len(out) > maxHookOutput {
+		out = out[:maxHookOutput] + "..."
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("Warning: Health hook for proxy %s (%s -> %s) timed out after %s: %s", proxy.ID, oldStatus, newStatus, h.timeout, out)
+	case err != nil:
+		log.Printf("Warning: Health hook for proxy %s (%s -> %s) failed: %v: %s", proxy.ID, oldStatus, newStatus, err, out)
+	default:
+		log.Printf("Health hook for proxy %s (%s -> %s) completed: %s", proxy.ID, oldStatus, newStatus, out)
+	}
+}
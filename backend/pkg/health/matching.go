@@ -0,0 +1,150 @@
+package health
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// maxMatchedBody is how much of a response body is searched for the expected body
+const maxMatchedBody = 1 << 20
+
+// checkMethods are the request methods health checks can use
+var checkMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// StatusRange is an inclusive range of accepted status codes
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// ParseStatusRanges parses a comma separated list of status codes and ranges such as
+// "200-299,304"
+func ParseStatusRanges(spec string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(part, "-")
+		if !isRange {
+			high = low
+		}
+		first, firstErr := strconv.Atoi(strings.TrimSpace(low))
+		last, lastErr := strconv.Atoi(strings.TrimSpace(high))
+		if firstErr != nil || lastErr != nil || first < 100 || last > 599 || first > last {
+			return nil, fmt.Errorf("invalid status range %q, expected codes or ranges such as 200-299", part)
+		}
+		ranges = append(ranges, StatusRange{Min: first, Max: last})
+	}
+	return ranges, nil
+}
+
+// NormalizeCheckMatching validates how a proxy's health check requests and matches responses,
+// clearing settings that don't apply
+func NormalizeCheckMatching(proxy *models.Proxy) error {
+	proxy.HealthCheckMethod = strings.ToUpper(strings.TrimSpace(proxy.HealthCheckMethod))
+	if proxy.HealthCheckMethod == http.MethodGet {
+		proxy.HealthCheckMethod = ""
+	}
+	if proxy.HealthCheckMethod != "" && !slices.Contains(checkMethods, proxy.HealthCheckMethod) {
+		return fmt.Errorf("health_check_method must be GET, HEAD or POST")
+	}
+	if proxy.HealthCheckMethod != http.MethodPost {
+		proxy.HealthCheckRequestBody = ""
+	}
+
+	proxy.HealthCheckStatusRange = strings.TrimSpace(proxy.HealthCheckStatusRange)
+	if _, err := ParseStatusRanges(proxy.HealthCheckStatusRange); err != nil {
+		return err
+	}
+
+	if proxy.HealthCheckExpectedBody == "" {
+		proxy.HealthCheckBodyRegex = false
+		return nil
+	}
+	if proxy.HealthCheckMethod == http.MethodHead {
+		return fmt.Errorf("health_check_expected_body can't be matched, HEAD responses have no body")
+	}
+	if proxy.HealthCheckBodyRegex {
+		if _, err := regexp.Compile(proxy.HealthCheckExpectedBody); err != nil {
+			return fmt.Errorf("invalid health_check_expected_body regex: %v", err)
+		}
+	}
+	return nil
+}
+
+// BodyPattern returns the regular expression the expected body of a proxy's health check is
+// matched with, empty when the body isn't checked
+func BodyPattern(proxy models.Proxy) string {
+	if proxy.HealthCheckBodyRegex {
+		return proxy.HealthCheckExpectedBody
+	}
+	return regexp.QuoteMeta(proxy.HealthCheckExpectedBody)
+}
+
+// checkMethod returns the request method of a proxy's health check
+func checkMethod(proxy models.Proxy) string {
+	if proxy.HealthCheckMethod == "" {
+		return http.MethodGet
+	}
+	return proxy.HealthCheckMethod
+}
+
+// checkRequestBody returns the body sent with a proxy's health check, nil when there is none
+func checkRequestBody(proxy models.Proxy) io.Reader {
+	if proxy.HealthCheckMethod != http.MethodPost || proxy.HealthCheckRequestBody == "" {
+		return nil
+	}
+	return strings.NewReader(proxy.HealthCheckRequestBody)
+}
+
+// matchResponse checks a health check response against the proxy's accepted statuses and expected
+// body, returning why it failed or "" when it passed
+func matchResponse(proxy models.Proxy, resp *http.Response) string {
+	if !statusAccepted(proxy, resp.StatusCode) {
+		if proxy.HealthCheckStatusRange != "" {
+			return fmt.Sprintf("Expected status %s, got %d", proxy.HealthCheckStatusRange, resp.StatusCode)
+		}
+		return fmt.Sprintf("Expected status %d, got %d", proxy.HealthCheckExpectedStatus, resp.StatusCode)
+	}
+
+	if proxy.HealthCheckExpectedBody == "" {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMatchedBody))
+	if err != nil {
+		return fmt.Sprintf("Failed to read response body: %v", err)
+	}
+	matched, err := regexp.Match(BodyPattern(proxy), body)
+	if err != nil {
+		return fmt.Sprintf("Invalid expected body regex: %v", err)
+	}
+	if !matched {
+		return fmt.Sprintf("Response body doesn't match %q", proxy.HealthCheckExpectedBody)
+	}
+	return ""
+}
+
+// statusAccepted reports whether a status code is in the proxy's status range, or is its expected
+// status when no range is set
+func statusAccepted(proxy models.Proxy, code int) bool {
+	ranges, err := ParseStatusRanges(proxy.HealthCheckStatusRange)
+	if err != nil || len(ranges) == 0 {
+		return code == proxy.HealthCheckExpectedStatus
+	}
+	for _, r := range ranges {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
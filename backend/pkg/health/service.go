@@ -3,32 +3,173 @@ package health
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/netguard"
+	"github.com/sarat/caddyproxymanager/pkg/templating"
 )
 
+// latencyWindow is how many recent checks the average response time covers
+const latencyWindow = 20
+
+// DefaultJitter is the fraction of the interval checks are randomly delayed by unless SetJitter
+// changes it
+const DefaultJitter = 0.1
+
+// StatusChangeFunc is called when a proxy's health status changes
+type StatusChangeFunc func(proxy models.Proxy, oldStatus, newStatus, message string)
+
 // Service manages health checks for proxies
 type Service struct {
-	mu       sync.RWMutex
-	statuses map[string]*models.HealthStatus
-	cancels  map[string]context.CancelFunc
-	client   *http.Client
+	mu        sync.RWMutex
+	statuses  map[string]*models.HealthStatus
+	cancels   map[string]context.CancelFunc
+	proxies   map[string]models.Proxy    // proxies with health checking enabled
+	active    bool                       // whether checks run on this instance
+	stopped   bool                       // set by Shutdown, no checks start afterwards
+	paused    bool                       // all checks skipped, e.g. during network maintenance
+	jitter    float64                    // fraction of the interval checks are randomly delayed by
+	checks    sync.WaitGroup             // running check goroutines
+	latencies map[string][]time.Duration // response times of each proxy's recent checks, newest last
+	listeners []StatusChangeFunc
+	client    *http.Client
+	guard     *netguard.Guard // restricts the addresses TCP checks dial, may be nil
+	caddy     *caddyClients   // set by SetCaddyAddress
+	// openHeaders decrypts health check header values, set by SetHeaderOpener
+	openHeaders func(proxyID string, headers map[string]string) map[string]string
 }
 
-// NewService creates a new health check service
-func NewService() *Service {
+// NewService creates a new health check service. When guard is non-nil, checks can't reach
+// the address ranges it blocks.
+func NewService(guard *netguard.Guard) *Service {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	if guard != nil {
+		client.Transport = guard.Transport()
+	}
+
 	return &Service{
-		statuses: make(map[string]*models.HealthStatus),
-		cancels:  make(map[string]context.CancelFunc),
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		statuses:  make(map[string]*models.HealthStatus),
+		cancels:   make(map[string]context.CancelFunc),
+		proxies:   make(map[string]models.Proxy),
+		latencies: make(map[string][]time.Duration),
+		active:    true,
+		jitter:    DefaultJitter,
+		client:    client,
+		guard:     guard,
+	}
+}
+
+// OnStatusChange registers a listener that is called whenever a proxy's health status changes
+func (s *Service) OnStatusChange(listener StatusChangeFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetActive starts or stops running health checks on this instance. Proxies registered
+// while inactive are remembered and start checking once the service becomes active.
+func (s *Service) SetActive(active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == active {
+		return
+	}
+	s.active = active
+
+	if !active {
+		for id, cancel := range s.cancels {
+			cancel()
+			delete(s.cancels, id)
+			delete(s.statuses, id)
+		}
+		return
+	}
+
+	for _, proxy := range s.proxies {
+		if err := s.startLocked(proxy); err != nil {
+			fmt.Printf("Warning: Failed to start health check for proxy %s: %v\n", proxy.ID, err)
+		}
 	}
 }
 
+// SetJitter sets the fraction of the interval, from 0 to 1, that checks are randomly delayed by so
+// they don't all fire together after a restart. Running checks pick it up on their next wait.
+func (s *Service) SetJitter(jitter float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jitter = min(max(jitter, 0), 1)
+}
+
+// SetHeaderOpener sets how health check header values, which proxies carry encrypted, are
+// decrypted before they are sent
+func (s *Service) SetHeaderOpener(open func(proxyID string, headers map[string]string) map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.openHeaders = open
+}
+
+// checkHeaders returns the headers a proxy's health check sends, decrypted
+func (s *Service) checkHeaders(proxy models.Proxy) map[string]string {
+	s.mu.RLock()
+	open := s.openHeaders
+	s.mu.RUnlock()
+
+	if open == nil {
+		return proxy.HealthCheckHeaders
+	}
+	return open(proxy.ID, proxy.HealthCheckHeaders)
+}
+
+// SetPaused pauses or resumes all health checks. Paused checks keep their last status and skip
+// their requests until resumed.
+func (s *Service) SetPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = paused
+}
+
+// Paused reports whether all health checks are paused
+func (s *Service) Paused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.paused
+}
+
+// PauseProxy pauses or resumes the health check of one proxy without restarting it, so the check
+// keeps its last status. Proxies without a health check are left alone.
+func (s *Service) PauseProxy(proxyID string, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	proxy, exists := s.proxies[proxyID]
+	if !exists {
+		return
+	}
+	proxy.HealthCheckPaused = paused
+	s.proxies[proxyID] = proxy
+}
+
+// skipped reports whether a proxy's check requests are skipped, as all checks or its own are paused
+func (s *Service) skipped(proxyID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.paused || s.proxies[proxyID].HealthCheckPaused
+}
+
 // StartHealthCheck starts health checking for a proxy
 func (s *Service) StartHealthCheck(proxy models.Proxy) error {
 	if !proxy.HealthCheckEnabled {
@@ -38,6 +179,20 @@ func (s *Service) StartHealthCheck(proxy models.Proxy) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.proxies[proxy.ID] = proxy
+	if !s.active {
+		return nil
+	}
+
+	return s.startLocked(proxy)
+}
+
+// startLocked launches the check goroutine for a proxy; the caller must hold s.mu
+func (s *Service) startLocked(proxy models.Proxy) error {
+	if s.stopped {
+		return nil
+	}
+
 	// Stop existing health check if running
 	if cancel, exists := s.cancels[proxy.ID]; exists {
 		cancel()
@@ -45,11 +200,15 @@ func (s *Service) StartHealthCheck(proxy models.Proxy) error {
 	}
 
 	// Initialize status as pending
+	delete(s.latencies, proxy.ID)
 	s.statuses[proxy.ID] = &models.HealthStatus{
 		Status:      "Pending",
 		LastChecked: time.Now().Format(time.RFC3339),
 		Message:     "Health check starting",
 	}
+	if proxy.HealthCheckPaused {
+		s.statuses[proxy.ID].Message = "Health check paused"
+	}
 
 	// Parse interval
 	interval, err := time.ParseDuration(proxy.HealthCheckInterval)
@@ -63,7 +222,11 @@ func (s *Service) StartHealthCheck(proxy models.Proxy) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	s.cancels[proxy.ID] = cancel
 
-	go s.runHealthCheck(ctx, proxy, interval)
+	s.checks.Add(1)
+	go func() {
+		defer s.checks.Done()
+		s.runHealthCheck(ctx, proxy, interval)
+	}()
 
 	return nil
 }
@@ -73,10 +236,37 @@ func (s *Service) StopHealthCheck(proxyID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	delete(s.proxies, proxyID)
 	if cancel, exists := s.cancels[proxyID]; exists {
 		cancel()
 		delete(s.cancels, proxyID)
 		delete(s.statuses, proxyID)
+		delete(s.latencies, proxyID)
+	}
+}
+
+// Shutdown cancels every health check and waits up to timeout for checks in progress to finish.
+// It reports whether they all finished in time; checks can't be started again afterwards.
+func (s *Service) Shutdown(timeout time.Duration) bool {
+	s.mu.Lock()
+	s.stopped = true
+	for id, cancel := range s.cancels {
+		cancel()
+		delete(s.cancels, id)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.checks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
@@ -96,9 +286,14 @@ func (s *Service) GetHealthStatus(proxyID string) (*models.HealthStatus, bool) {
 
 	// Return a copy to avoid race conditions
 	return &models.HealthStatus{
-		Status:      status.Status,
-		LastChecked: status.LastChecked,
-		Message:     status.Message,
+		Status:            status.Status,
+		LastChecked:       status.LastChecked,
+		Message:           status.Message,
+		ResponseTimeMs:    status.ResponseTimeMs,
+		AvgResponseTimeMs: status.AvgResponseTimeMs,
+		DownDependencies:  s.downDependencies(proxyID),
+		Paused:            s.paused || s.proxies[proxyID].HealthCheckPaused,
+		WebSocketUpgrade:  status.WebSocketUpgrade,
 	}, true
 }
 
@@ -110,65 +305,199 @@ func (s *Service) GetAllHealthStatuses() map[string]*models.HealthStatus {
 	result := make(map[string]*models.HealthStatus)
 	for id, status := range s.statuses {
 		result[id] = &models.HealthStatus{
-			Status:      status.Status,
-			LastChecked: status.LastChecked,
-			Message:     status.Message,
+			Status:            status.Status,
+			LastChecked:       status.LastChecked,
+			Message:           status.Message,
+			ResponseTimeMs:    status.ResponseTimeMs,
+			AvgResponseTimeMs: status.AvgResponseTimeMs,
+			DownDependencies:  s.downDependencies(id),
+			Paused:            s.paused || s.proxies[id].HealthCheckPaused,
+			WebSocketUpgrade:  status.WebSocketUpgrade,
 		}
 	}
 	return result
 }
 
-// runHealthCheck performs periodic health checks
-func (s *Service) runHealthCheck(ctx context.Context, proxy models.Proxy, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// CheckedProxies returns the proxies that have health checking enabled
+func (s *Service) CheckedProxies() []models.Proxy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Perform initial check immediately
-	s.performHealthCheck(proxy)
+	proxies := make([]models.Proxy, 0, len(s.proxies))
+	for _, proxy := range s.proxies {
+		proxies = append(proxies, proxy)
+	}
+	return proxies
+}
+
+// downDependencies returns the direct or indirect dependencies of an unhealthy proxy that are
+// unhealthy too, which are the likely cause of the outage. Callers must hold s.mu.
+func (s *Service) downDependencies(proxyID string) []string {
+	if status, exists := s.statuses[proxyID]; !exists || status.Status != "Unhealthy" {
+		return nil
+	}
+
+	var down []string
+	visited := map[string]bool{proxyID: true}
+	queue := slices.Clone(s.proxies[proxyID].DependsOn)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		// Dependencies without health checks have no known status
+		if status, exists := s.statuses[id]; exists && status.Status == "Unhealthy" {
+			down = append(down, id)
+		}
+		queue = append(queue, s.proxies[id].DependsOn...)
+	}
+
+	return down
+}
+
+// runHealthCheck performs periodic health checks, each wait randomly lengthened by the jitter so a
+// restart doesn't check every proxy at once
+func (s *Service) runHealthCheck(ctx context.Context, proxy models.Proxy, interval time.Duration) {
+	timer := time.NewTimer(s.jitterDelay(interval))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			s.performHealthCheck(proxy)
+		case <-timer.C:
+			if !s.skipped(proxy.ID) {
+				s.performHealthCheck(ctx, proxy)
+			}
+			timer.Reset(interval + s.jitterDelay(interval))
 		}
 	}
 }
 
-// performHealthCheck performs a single health check
-func (s *Service) performHealthCheck(proxy models.Proxy) {
-	healthURL := proxy.TargetURL + proxy.HealthCheckPath
+// jitterDelay returns a random delay of up to the jitter fraction of interval
+func (s *Service) jitterDelay(interval time.Duration) time.Duration {
+	s.mu.RLock()
+	jitter := s.jitter
+	s.mu.RUnlock()
+
+	if limit := time.Duration(float64(interval) * jitter); limit > 0 {
+		return rand.N(limit)
+	}
+	return 0
+}
+
+// performHealthCheck performs a single health check. Cancelling ctx aborts the request without
+// recording a result, so stopped checks don't report proxies as down.
+func (s *Service) performHealthCheck(ctx context.Context, proxy models.Proxy) {
+	if proxy.HealthCheckType == CheckTypeTCP {
+		s.performTCPCheck(ctx, proxy)
+		return
+	}
+
 	now := time.Now().Format(time.RFC3339)
 
-	req, err := http.NewRequest("GET", healthURL, nil)
+	client, healthURL := s.client, ""
+	if proxy.HealthCheckViaCaddy {
+		var err error
+		client, healthURL, err = s.caddyCheckRequest(proxy)
+		if err != nil {
+			s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Can't check through Caddy: %v", err), 0)
+			return
+		}
+	} else {
+		target, err := templating.Expand(proxy.TargetURL)
+		if err != nil {
+			s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Invalid target URL: %v", err), 0)
+			return
+		}
+		healthURL = target + proxy.HealthCheckPath
+	}
+
+	req, err := http.NewRequestWithContext(ctx, checkMethod(proxy), healthURL, checkRequestBody(proxy))
 	if err != nil {
-		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Failed to create request: %v", err))
+		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Failed to create request: %v", err), 0)
 		return
 	}
+	for name, value := range s.checkHeaders(proxy) {
+		req.Header.Set(name, value)
+	}
+	if proxy.HealthCheckUserAgent != "" {
+		req.Header.Set("User-Agent", proxy.HealthCheckUserAgent)
+	}
+	// Going through Caddy means passing the proxy's basic auth, unless the headers already do
+	if proxy.HealthCheckViaCaddy && proxy.BasicAuth != nil && proxy.BasicAuth.Enabled && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(proxy.BasicAuth.Username, proxy.BasicAuth.Password)
+	}
 
-	resp, err := s.client.Do(req)
+	start := time.Now()
+	resp, err := client.Do(req)
+	responseTime := time.Since(start)
 	if err != nil {
-		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Request failed: %v", err))
+		if ctx.Err() != nil {
+			return
+		}
+		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Request failed: %v", err), 0)
 		return
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == proxy.HealthCheckExpectedStatus {
-		s.updateStatus(proxy.ID, "Healthy", now, "Health check passed")
+	if proxy.WebSocket != nil && proxy.WebSocket.Enabled {
+		s.probeWebSocket(ctx, proxy)
+	}
+
+	if failure := matchResponse(proxy, resp); failure == "" {
+		s.updateStatus(proxy.ID, "Healthy", now, "Health check passed", responseTime)
 	} else {
-		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Expected status %d, got %d", proxy.HealthCheckExpectedStatus, resp.StatusCode))
+		s.updateStatus(proxy.ID, "Unhealthy", now, failure, responseTime)
+	}
+}
+
+// recordLatency adds a check's response time to a proxy's recent ones and returns their average;
+// the caller must hold s.mu
+func (s *Service) recordLatency(proxyID string, responseTime time.Duration) time.Duration {
+	latencies := append(s.latencies[proxyID], responseTime)
+	if len(latencies) > latencyWindow {
+		latencies = latencies[len(latencies)-latencyWindow:]
+	}
+	s.latencies[proxyID] = latencies
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
 	}
+	return total / time.Duration(len(latencies))
 }
 
 // updateStatus updates the health status for a proxy
-func (s *Service) updateStatus(proxyID, status, lastChecked, message string) {
+func (s *Service) updateStatus(proxyID, status, lastChecked, message string, responseTime time.Duration) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	if _, exists := s.statuses[proxyID]; exists {
-		s.statuses[proxyID].Status = status
-		s.statuses[proxyID].LastChecked = lastChecked
-		s.statuses[proxyID].Message = message
+	current, exists := s.statuses[proxyID]
+	if !exists {
+		s.mu.Unlock()
+		return
+	}
+
+	oldStatus := current.Status
+	current.Status = status
+	current.LastChecked = lastChecked
+	current.Message = message
+	current.ResponseTimeMs = responseTime.Milliseconds()
+	if responseTime > 0 {
+		current.AvgResponseTimeMs = s.recordLatency(proxyID, responseTime).Milliseconds()
+	}
+
+	proxy := s.proxies[proxyID]
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	// Notify listeners outside the lock so they can query the service
+	if oldStatus != status {
+		for _, listener := range listeners {
+			listener(proxy, oldStatus, status, message)
+		}
 	}
 }
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/templating"
+)
+
+// Health check types
+const (
+	CheckTypeHTTP = "http" // request the health check path, the default
+	CheckTypeTCP  = "tcp"  // only open a connection to the target
+)
+
+// NormalizeCheckType validates a proxy's health check type, storing the default HTTP type as empty
+func NormalizeCheckType(proxy *models.Proxy) error {
+	proxy.HealthCheckType = strings.ToLower(strings.TrimSpace(proxy.HealthCheckType))
+	switch proxy.HealthCheckType {
+	case "", CheckTypeHTTP:
+		proxy.HealthCheckType = ""
+	case CheckTypeTCP:
+		if proxy.HealthCheckViaCaddy {
+			return fmt.Errorf("tcp health checks can't run through Caddy, which only serves HTTP for the proxy")
+		}
+		if proxy.UpstreamHealth != nil && proxy.UpstreamHealth.Active {
+			return fmt.Errorf("Caddy's active health checks need the http health check type")
+		}
+	default:
+		return fmt.Errorf("health_check_type must be %s or %s", CheckTypeHTTP, CheckTypeTCP)
+	}
+	return nil
+}
+
+// performTCPCheck reports a proxy healthy when a connection to its target opens, for upstreams such
+// as gRPC services or PHP-FPM where an HTTP request says little
+func (s *Service) performTCPCheck(ctx context.Context, proxy models.Proxy) {
+	now := time.Now().Format(time.RFC3339)
+
+	network, address, err := tcpCheckAddress(proxy)
+	if err != nil {
+		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Invalid target URL: %v", err), 0)
+		return
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, s.client.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := s.dial(dialCtx, network, address)
+	responseTime := time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		s.updateStatus(proxy.ID, "Unhealthy", now, fmt.Sprintf("Connection failed: %v", err), 0)
+		return
+	}
+	conn.Close()
+
+	s.updateStatus(proxy.ID, "Healthy", now, fmt.Sprintf("Connected to %s", address), responseTime)
+}
+
+// dial opens a TCP connection through the guard, if any. Unix sockets have no address to guard.
+func (s *Service) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if s.guard != nil && network == "tcp" {
+		return s.guard.DialContext(ctx, network, address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// tcpCheckAddress returns the network and address a TCP check connects to: the target's host and
+// port, or the PHP-FPM address or socket of fastcgi upstreams
+func tcpCheckAddress(proxy models.Proxy) (string, string, error) {
+	target, err := templating.Expand(proxy.TargetURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if proxy.UpstreamType == "fastcgi" {
+		target = strings.TrimPrefix(target, "fastcgi://")
+		if socket, isSocket := strings.CutPrefix(target, "unix/"); isSocket {
+			return "unix", socket, nil
+		}
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return "tcp", net.JoinHostPort(strings.Trim(target, "[]"), "9000"), nil // Default PHP-FPM port
+		}
+		return "tcp", target, nil
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", "", err
+	}
+	if parsed.Hostname() == "" {
+		return "", "", fmt.Errorf("%q has no host", target)
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return "tcp", net.JoinHostPort(parsed.Hostname(), port), nil
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// caddyClients send the checks of proxies with HealthCheckViaCaddy to Caddy
+type caddyClients struct {
+	verified *http.Client
+	internal *http.Client // for proxies with internal certificates, signed by Caddy's own CA
+}
+
+// SetCaddyAddress sets the host Caddy serves proxies on. Checks of proxies with
+// HealthCheckViaCaddy request the proxy's public URL but connect to this host, so they cover TLS,
+// routing and basic auth without depending on public DNS. These connections skip the outbound
+// guard, since the address comes from the manager's configuration rather than from proxies.
+func (s *Service) SetCaddyAddress(host string) {
+	newClient := func(verify bool) *http.Client {
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+		}
+		if !verify {
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.caddy = &caddyClients{
+		verified: newClient(true),
+		internal: newClient(false),
+	}
+}
+
+// caddyCheckRequest returns the client and URL checking a proxy through Caddy
+func (s *Service) caddyCheckRequest(proxy models.Proxy) (*http.Client, string, error) {
+	s.mu.RLock()
+	clients := s.caddy
+	s.mu.RUnlock()
+	if clients == nil {
+		return nil, "", fmt.Errorf("Caddy's address is not configured")
+	}
+
+	host := proxy.Domain
+	if strings.Contains(host, "*") {
+		return nil, "", fmt.Errorf("wildcard domain %s has no single URL to check", host)
+	}
+
+	scheme := "https"
+	if proxy.SSLMode == "none" {
+		scheme = "http"
+	}
+
+	// Isolated proxies are only served on their own listeners
+	if proxy.Isolated {
+		port, err := isolatedCheckPort(proxy.IsolatedListen, scheme)
+		if err != nil {
+			return nil, "", err
+		}
+		if domain, _, err := net.SplitHostPort(host); err == nil {
+			host = domain
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	checkURL := (&url.URL{Scheme: scheme, Host: host}).String() + proxy.HealthCheckPath
+	if proxy.SSLMode == "internal" {
+		return clients.internal, checkURL, nil
+	}
+	return clients.verified, checkURL, nil
+}
+
+// isolatedCheckPort picks the listener of an isolated proxy to check, preferring the scheme's
+// default port
+func isolatedCheckPort(listen []string, scheme string) (string, error) {
+	defaultPort := "443"
+	if scheme == "http" {
+		defaultPort = "80"
+	}
+
+	var first string
+	for _, address := range listen {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		if port == defaultPort {
+			return port, nil
+		}
+		if first == "" {
+			first = port
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("isolated proxy has no listener to check")
+	}
+	return first, nil
+}
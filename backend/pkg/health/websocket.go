@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/templating"
+)
+
+// probeWebSocket asks the proxy's target to upgrade a request to its WebSocket path and records
+// whether it answered with 101 Switching Protocols. The connection is closed right away.
+func (s *Service) probeWebSocket(ctx context.Context, proxy models.Proxy) {
+	supported := s.webSocketUpgradeSupported(ctx, proxy)
+	if ctx.Err() != nil {
+		return
+	}
+	s.setWebSocketUpgrade(proxy.ID, supported)
+}
+
+func (s *Service) webSocketUpgradeSupported(ctx context.Context, proxy models.Proxy) bool {
+	target, err := templating.Expand(proxy.TargetURL)
+	if err != nil {
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target+proxy.WebSocket.Path, nil)
+	if err != nil {
+		return false
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return false
+	}
+	for name, value := range s.checkHeaders(proxy) {
+		req.Header.Set(name, value)
+	}
+	if proxy.HealthCheckUserAgent != "" {
+		req.Header.Set("User-Agent", proxy.HealthCheckUserAgent)
+	}
+	// These headers also keep the request on HTTP/1.1, which upgrades need
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode == http.StatusSwitchingProtocols
+}
+
+// setWebSocketUpgrade records the result of a proxy's upgrade probe
+func (s *Service) setWebSocketUpgrade(proxyID string, supported bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status, exists := s.statuses[proxyID]; exists {
+		status.WebSocketUpgrade = &supported
+	}
+}
+
+// WebSocketUpgrades returns whether the targets of the proxies that probe for WebSocket support
+// accepted their last upgrade, keyed by proxy ID. Proxies not probed yet are left out.
+func (s *Service) WebSocketUpgrades() map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	upgrades := make(map[string]bool)
+	for id, status := range s.statuses {
+		if status.WebSocketUpgrade != nil {
+			upgrades[id] = *status.WebSocketUpgrade
+		}
+	}
+	return upgrades
+}
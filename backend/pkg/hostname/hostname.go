@@ -0,0 +1,122 @@
+// Package hostname validates the domains proxies, redirects and certificates are served on. Names
+// are stored in the ASCII (punycode) form Caddy and ACME expect, so internationalized domains are
+// converted on save and converted back for display.
+package hostname
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Error codes, so clients can tell why a domain was rejected without parsing the message
+const (
+	CodeRequired       = "required"
+	CodeURL            = "url"
+	CodeInvalidPort    = "invalid_port"
+	CodePortNotAllowed = "port_not_allowed"
+	CodeInvalid        = "invalid_hostname"
+)
+
+// wildcardPrefix starts a name covering every subdomain one level down, e.g. *.example.com
+const wildcardPrefix = "*."
+
+// lookup converts names to their ASCII form, rejecting characters not allowed in hostnames and
+// labels or names longer than DNS permits
+var lookup = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true), idna.Transitional(false))
+
+// Error describes why a domain was rejected
+type Error struct {
+	Domain  string `json:"domain"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Normalize validates a domain and returns it in lower-case ASCII form, converting
+// internationalized names to punycode. IP addresses and a leading wildcard label are accepted, as
+// is a port when allowPort is set.
+func Normalize(domain string, allowPort bool) (string, error) {
+	domain = strings.TrimSpace(domain)
+	if domain == "" {
+		return "", &Error{Domain: domain, Code: CodeRequired, Message: "Domain is required"}
+	}
+	if strings.Contains(domain, "://") || strings.Contains(domain, "/") {
+		return "", &Error{Domain: domain, Code: CodeURL, Message: fmt.Sprintf("%s is a URL, not a hostname", domain)}
+	}
+
+	host, port := domain, ""
+	if h, p, err := net.SplitHostPort(domain); err == nil {
+		host, port = h, p
+		if !allowPort {
+			return "", &Error{Domain: domain, Code: CodePortNotAllowed, Message: fmt.Sprintf("%s must not include a port", domain)}
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", &Error{Domain: domain, Code: CodeInvalidPort, Message: fmt.Sprintf("%s has an invalid port", domain)}
+		}
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return joinPort(ip.String(), port), nil
+	}
+
+	prefix := ""
+	if rest, ok := strings.CutPrefix(host, wildcardPrefix); ok {
+		prefix, host = wildcardPrefix, rest
+	}
+	// A fully qualified name's trailing dot wouldn't match the Host header
+	host = strings.TrimSuffix(host, ".")
+
+	ascii, err := lookup.ToASCII(host)
+	if err != nil {
+		return "", &Error{Domain: domain, Code: CodeInvalid, Message: fmt.Sprintf("%s is not a valid hostname: %v", domain, err)}
+	}
+
+	return joinPort(prefix+ascii, port), nil
+}
+
+// NormalizeAll normalizes each domain, stopping at the first invalid one
+func NormalizeAll(domains []string, allowPort bool) ([]string, error) {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		name, err := Normalize(domain, allowPort)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
+
+// Display returns the Unicode form of a normalized domain for showing to users, or the domain
+// unchanged when it has no punycode labels
+func Display(domain string) string {
+	host, port, err := net.SplitHostPort(domain)
+	if err != nil {
+		host, port = domain, ""
+	}
+
+	prefix := ""
+	if rest, ok := strings.CutPrefix(host, wildcardPrefix); ok {
+		prefix, host = wildcardPrefix, rest
+	}
+
+	unicode, err := idna.Display.ToUnicode(host)
+	if err != nil {
+		return domain
+	}
+	return joinPort(prefix+unicode, port)
+}
+
+func joinPort(host, port string) string {
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,197 @@
+// Package iplist reads IP allow and block lists in the formats country and abuse lists are
+// published in: one IP address, CIDR or address range per line, as in plain text lists, FireHOL
+// netsets and Spamhaus DROP, with # and ; comments.
+package iplist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/netguard"
+)
+
+const (
+	// MaxBytes limits the size of a list, uploaded or fetched
+	MaxBytes = 8 << 20
+	// MaxEntries limits how many ranges a list may add to a proxy's matcher
+	MaxEntries = 100000
+	// fetchTimeout bounds downloading a list from a URL
+	fetchTimeout = 30 * time.Second
+)
+
+// Parse reads a list and returns its entries as normalized CIDRs, without duplicates. Address
+// ranges such as 10.0.0.1-10.0.0.6 are split into the CIDRs covering them. A line that isn't an
+// address, CIDR or range fails the whole list, since it usually means the wrong file or an error
+// page was fetched.
+func Parse(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(io.LimitReader(r, MaxBytes+1))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	seen := make(map[netip.Prefix]bool)
+	var prefixes []netip.Prefix
+	read, lineNumber := 0, 0
+	for scanner.Scan() {
+		lineNumber++
+		read += len(scanner.Bytes()) + 1
+		if read > MaxBytes {
+			return nil, fmt.Errorf("list is larger than %d bytes", MaxBytes)
+		}
+
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		parsed, err := parseEntry(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		for _, prefix := range parsed {
+			if !seen[prefix] {
+				seen[prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		if len(prefixes) > MaxEntries {
+			return nil, fmt.Errorf("list has more than %d entries", MaxEntries)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read list: %v", err)
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("list has no entries")
+	}
+
+	entries := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		entries[i] = prefix.String()
+	}
+	return entries, nil
+}
+
+// parseEntry parses an address, CIDR or address range into the prefixes it covers
+func parseEntry(entry string) ([]netip.Prefix, error) {
+	if from, to, ok := strings.Cut(entry, "-"); ok {
+		start, startErr := netip.ParseAddr(from)
+		end, endErr := netip.ParseAddr(to)
+		if startErr != nil || endErr != nil || start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("invalid address range %q", entry)
+		}
+		return rangePrefixes(start.Unmap(), end.Unmap()), nil
+	}
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return []netip.Prefix{prefix.Masked()}, nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address %q", entry)
+	}
+	addr = addr.Unmap()
+	return []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+}
+
+// rangePrefixes returns the fewest prefixes covering start to end inclusive
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		// Widen the prefix while it still starts at start and ends within the range
+		bits := start.BitLen()
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1).Masked()
+			if wider.Addr() != start || end.Less(lastAddr(wider)) {
+				break
+			}
+			bits--
+		}
+
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+
+		last := lastAddr(prefix)
+		if last == end || !last.Next().IsValid() {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr returns the highest address in a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(addr)*8; bit++ {
+		addr[bit/8] |= 1 << (7 - bit%8)
+	}
+	last, _ := netip.AddrFromSlice(addr)
+	return last
+}
+
+// Fetcher downloads lists published at URLs
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher creates a fetcher whose requests go through guard, or reach any address when guard is
+// nil
+func NewFetcher(guard *netguard.Guard) *Fetcher {
+	client := &http.Client{Timeout: fetchTimeout}
+	if guard != nil {
+		client.Transport = guard.Transport()
+	}
+	return &Fetcher{client: client}
+}
+
+// Fetch downloads and parses the list at url
+func (f *Fetcher) Fetch(ctx context.Context, url string) ([]string, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("list URL must use http or https")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid list URL: %v", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch list: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch list: HTTP %d", resp.StatusCode)
+	}
+
+	return Parse(resp.Body)
+}
+
+// Merge combines lists of entries, dropping duplicates and keeping the first list's order
+func Merge(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, entry := range list {
+			if !seen[entry] {
+				seen[entry] = true
+				merged = append(merged, entry)
+			}
+		}
+	}
+	return merged
+}
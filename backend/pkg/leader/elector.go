@@ -0,0 +1,114 @@
+// Package leader provides lease-based leader election between manager replicas sharing a data
+// directory or a database.
+package leader
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Backend stores the lease. Acquire must check and take the lease atomically, so two replicas
+// finding it expired at the same time can't both win.
+type Backend interface {
+	// Acquire takes or renews the lease for holder when it is free, expired or already theirs
+	Acquire(holder string, ttl time.Duration) (bool, error)
+	// Release expires holder's lease so another replica can take over immediately
+	Release(holder string) error
+}
+
+// Elector competes for a lease so only one replica runs background jobs at a time
+type Elector struct {
+	mu       sync.RWMutex
+	backend  Backend
+	id       string
+	ttl      time.Duration
+	isLeader bool
+	onChange func(isLeader bool)
+}
+
+// NewElector creates an elector competing for the lease in backend. onChange is called whenever
+// this instance gains or loses leadership.
+func NewElector(backend Backend, id string, ttl time.Duration, onChange func(isLeader bool)) *Elector {
+	return &Elector{
+		backend:  backend,
+		id:       id,
+		ttl:      ttl,
+		onChange: onChange,
+	}
+}
+
+// ID returns the identity this instance uses in the lease
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this instance currently holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader
+}
+
+// Run renews or acquires the lease until ctx is cancelled, then releases it if held
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	e.tick()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.tick()
+		case <-ctx.Done():
+			if e.IsLeader() {
+				if err := e.release(); err != nil {
+					log.Printf("Failed to release leader lease: %v", err)
+				}
+				e.setLeader(false)
+			}
+			return
+		}
+	}
+}
+
+// tick attempts to acquire or renew the lease and updates leadership state
+func (e *Elector) tick() {
+	acquired, err := e.tryAcquire()
+	if err != nil {
+		log.Printf("Leader election error: %v", err)
+	}
+	e.setLeader(acquired)
+}
+
+func (e *Elector) setLeader(isLeader bool) {
+	e.mu.Lock()
+	changed := e.isLeader != isLeader
+	e.isLeader = isLeader
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if isLeader {
+		log.Printf("Instance %s became leader\n", e.id)
+	} else {
+		log.Printf("Instance %s is no longer leader\n", e.id)
+	}
+	if e.onChange != nil {
+		e.onChange(isLeader)
+	}
+}
+
+// tryAcquire takes the lease if it is ours or has expired
+func (e *Elector) tryAcquire() (bool, error) {
+	return e.backend.Acquire(e.id, e.ttl)
+}
+
+// release expires our lease so another replica can take over immediately
+func (e *Elector) release() error {
+	return e.backend.Release(e.id)
+}
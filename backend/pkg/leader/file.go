@@ -0,0 +1,98 @@
+package leader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Lease is the leadership record persisted in the shared lease file
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// File keeps the lease in a file in a shared data directory. Each check and update holds an
+// exclusive lock on a file next to it, so replicas take turns.
+type File struct {
+	path string
+}
+
+// NewFile creates a backend keeping the lease in the file at path
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Acquire(holder string, ttl time.Duration) (bool, error) {
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	lease, err := f.read()
+	if err != nil {
+		return false, err
+	}
+	if lease != nil && lease.Holder != holder && time.Now().Before(lease.Expires) {
+		return false, nil
+	}
+
+	if err := f.write(Lease{Holder: holder, Expires: time.Now().Add(ttl)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) Release(holder string) error {
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	lease, err := f.read()
+	if err != nil || lease == nil || lease.Holder != holder {
+		return err
+	}
+	return f.write(Lease{Holder: holder, Expires: time.Now()})
+}
+
+func (f *File) read() (*Lease, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		// A corrupt lease is treated as expired so it gets overwritten
+		return nil, nil
+	}
+
+	return &lease, nil
+}
+
+// write replaces the lease file atomically via a temporary file and rename
+func (f *File) write(lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lease: %w", err)
+	}
+
+	tmpFile := filepath.Join(filepath.Dir(f.path), fmt.Sprintf(".%s.%s.tmp", filepath.Base(f.path), lease.Holder))
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, f.path); err != nil {
+		return fmt.Errorf("failed to replace lease file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package leader
+
+import "fmt"
+
+// lockFile isn't supported without flock; use the database lease instead
+func lockFile(path string) (func(), error) {
+	return nil, fmt.Errorf("file leases need a Unix system, set DATABASE_URL to elect a leader through the database")
+}
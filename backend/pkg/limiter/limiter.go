@@ -0,0 +1,184 @@
+// Package limiter protects the manager API from misbehaving clients with a cap on in-flight
+// requests, per-IP rate limits and per-route request deadlines.
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idleClientTTL = 10 * time.Minute // Per-IP buckets unused this long are dropped
+	writeMargin   = 10 * time.Second // Time to write the response once a request deadline passes
+)
+
+// Config controls the API limits. A zero value disables the corresponding limit.
+type Config struct {
+	MaxInFlight   int                      // Maximum concurrent API requests
+	RatePerSecond float64                  // Sustained requests per second per client IP
+	Burst         int                      // Requests a client IP may make at once
+	Timeout       time.Duration            // Default request deadline
+	RouteTimeouts map[string]time.Duration // Deadline overrides keyed by route pattern, e.g. "POST /api/update"
+	StreamRoutes  map[string]bool          // Long-lived routes, e.g. "GET /api/events", exempt from the in-flight cap and deadline
+	ServerTimeout time.Duration            // The server's read and write timeout, extended for requests with a later deadline
+}
+
+// Limiter enforces Config on requests under /api/
+type Limiter struct {
+	config    Config
+	inFlight  chan struct{}
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is a token bucket for one client IP
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// New creates a limiter from config
+func New(config Config) *Limiter {
+	limiter := &Limiter{
+		config:    config,
+		clients:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+	if config.MaxInFlight > 0 {
+		limiter.inFlight = make(chan struct{}, config.MaxInFlight)
+	}
+	if config.Burst <= 0 {
+		limiter.config.Burst = int(math.Ceil(config.RatePerSecond))
+	}
+
+	return limiter
+}
+
+// Middleware applies the limits to API requests served by mux. Static files are not limited.
+func (l *Limiter) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || r.Method == http.MethodOptions {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if retryAfter, ok := l.allow(clientIP(r)); !ok {
+			l.reject(w, http.StatusTooManyRequests, "Too many requests", retryAfter)
+			return
+		}
+
+		_, pattern := mux.Handler(r)
+		if l.config.StreamRoutes[pattern] {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if l.inFlight != nil {
+			select {
+			case l.inFlight <- struct{}{}:
+				defer func() { <-l.inFlight }()
+			default:
+				l.reject(w, http.StatusServiceUnavailable, "Server is busy, try again shortly", time.Second)
+				return
+			}
+		}
+
+		timeout := l.config.Timeout
+		if override, exists := l.config.RouteTimeouts[pattern]; exists && pattern != "" {
+			timeout = override
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			// The server would otherwise close the connection before the deadline is reached
+			if l.config.ServerTimeout > 0 && timeout+writeMargin > l.config.ServerTimeout {
+				controller := http.NewResponseController(w)
+				_ = controller.SetReadDeadline(time.Now().Add(timeout))
+				_ = controller.SetWriteDeadline(time.Now().Add(timeout + writeMargin))
+			}
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the client's bucket, returning how long to wait when none are left
+func (l *Limiter) allow(ip string) (time.Duration, bool) {
+	if l.config.RatePerSecond <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	client, exists := l.clients[ip]
+	if !exists {
+		client = &bucket{tokens: float64(l.config.Burst)}
+		l.clients[ip] = client
+	} else {
+		elapsed := now.Sub(client.lastSeen).Seconds()
+		client.tokens = math.Min(float64(l.config.Burst), client.tokens+elapsed*l.config.RatePerSecond)
+	}
+	client.lastSeen = now
+
+	if client.tokens < 1 {
+		wait := time.Duration((1 - client.tokens) / l.config.RatePerSecond * float64(time.Second))
+		return wait, false
+	}
+
+	client.tokens--
+	return 0, true
+}
+
+// sweep drops idle client buckets; the caller must hold the lock
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleClientTTL {
+		return
+	}
+
+	for ip, client := range l.clients {
+		if now.Sub(client.lastSeen) > idleClientTTL {
+			delete(l.clients, ip)
+		}
+	}
+	l.lastSweep = now
+}
+
+// reject writes a JSON error with a Retry-After hint
+func (l *Limiter) reject(w http.ResponseWriter, status int, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
+		// Log error if needed, but response is already written
+		return
+	}
+}
+
+// clientIP returns the address of the directly connected client. X-Forwarded-For is ignored since
+// any client can set it to dodge the limit.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,167 @@
+// Package metrics pushes health check results to external time series databases.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/health"
+)
+
+// Supported push formats
+const (
+	FormatInflux      = "influx"       // InfluxDB line protocol, also accepted by VictoriaMetrics
+	FormatRemoteWrite = "remote_write" // Prometheus remote_write protocol
+)
+
+// Sample is one proxy's health at a point in time
+type Sample struct {
+	ProxyID        string
+	Domain         string
+	Up             bool
+	ResponseTimeMs int64
+	Timestamp      time.Time
+}
+
+// Exporter periodically pushes health check results to a push-based monitoring endpoint
+type Exporter struct {
+	url           string
+	format        string
+	authorization string
+	interval      time.Duration
+	health        *health.Service
+	client        *http.Client
+}
+
+// NewExporter creates an exporter that pushes to url in the given format. authorization, if
+// set, is sent as the Authorization header, e.g. "Token ..." for InfluxDB 2.
+func NewExporter(url, format, authorization string, interval time.Duration, healthService *health.Service) (*Exporter, error) {
+	switch format {
+	case FormatInflux, FormatRemoteWrite:
+	default:
+		return nil, fmt.Errorf("unsupported metrics format %q (expected %q or %q)", format, FormatInflux, FormatRemoteWrite)
+	}
+
+	return &Exporter{
+		url:           url,
+		format:        format,
+		authorization: authorization,
+		interval:      interval,
+		health:        healthService,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Run pushes samples every interval until ctx is cancelled. shouldPush lets callers skip
+// pushes, e.g. on replicas that aren't running health checks.
+func (e *Exporter) Run(ctx context.Context, shouldPush func() bool) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if shouldPush != nil && !shouldPush() {
+				continue
+			}
+			if err := e.Push(ctx); err != nil {
+				log.Printf("Failed to push metrics: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect gathers the latest health check result for every checked proxy
+func (e *Exporter) Collect() []Sample {
+	statuses := e.health.GetAllHealthStatuses()
+	now := time.Now()
+
+	var samples []Sample
+	for _, proxy := range e.health.CheckedProxies() {
+		status, exists := statuses[proxy.ID]
+		if !exists || status.LastChecked == "" {
+			continue // Not checked yet
+		}
+
+		samples = append(samples, Sample{
+			ProxyID:        proxy.ID,
+			Domain:         proxy.Domain,
+			Up:             status.Status == "Healthy",
+			ResponseTimeMs: status.ResponseTimeMs,
+			Timestamp:      now,
+		})
+	}
+
+	return samples
+}
+
+// Push sends the current samples to the configured endpoint
+func (e *Exporter) Push(ctx context.Context) error {
+	samples := e.Collect()
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var body []byte
+	var contentType string
+	switch e.format {
+	case FormatRemoteWrite:
+		body = encodeRemoteWrite(samples)
+		contentType = "application/x-protobuf"
+	default:
+		body = encodeLineProtocol(samples)
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.format == FormatRemoteWrite {
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	if e.authorization != "" {
+		req.Header.Set("Authorization", e.authorization)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("metrics endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// encodeLineProtocol writes one proxy_health point per sample
+func encodeLineProtocol(samples []Sample) []byte {
+	escape := strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+	var b bytes.Buffer
+	for _, sample := range samples {
+		up := 0
+		if sample.Up {
+			up = 1
+		}
+		fmt.Fprintf(&b, "proxy_health,proxy_id=%s,domain=%s up=%di,response_time_ms=%di %d\n",
+			escape.Replace(sample.ProxyID), escape.Replace(sample.Domain), up, sample.ResponseTimeMs, sample.Timestamp.UnixNano())
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// encodeRemoteWrite builds a snappy-compressed Prometheus remote_write WriteRequest. The
+// protobuf and snappy encodings are written by hand as the message is small and fixed.
+func encodeRemoteWrite(samples []Sample) []byte {
+	var request []byte
+	for _, sample := range samples {
+		labels := map[string]string{
+			"proxy_id": sample.ProxyID,
+			"domain":   sample.Domain,
+		}
+		timestamp := sample.Timestamp.UnixMilli()
+
+		up := 0.0
+		if sample.Up {
+			up = 1
+		}
+		request = appendTimeSeries(request, "proxy_health_up", labels, up, timestamp)
+		request = appendTimeSeries(request, "proxy_health_response_time_ms", labels, float64(sample.ResponseTimeMs), timestamp)
+	}
+
+	return snappyEncode(request)
+}
+
+// appendTimeSeries appends WriteRequest.timeseries (field 1) with one sample
+func appendTimeSeries(buf []byte, name string, labels map[string]string, value float64, timestamp int64) []byte {
+	// Remote write requires labels sorted by name, including __name__
+	names := []string{"__name__"}
+	for labelName := range labels {
+		names = append(names, labelName)
+	}
+	sort.Strings(names)
+
+	var series []byte
+	for _, labelName := range names {
+		labelValue := labels[labelName]
+		if labelName == "__name__" {
+			labelValue = name
+		}
+
+		var label []byte
+		label = appendBytesField(label, 1, []byte(labelName))
+		label = appendBytesField(label, 2, []byte(labelValue))
+		series = appendBytesField(series, 1, label) // TimeSeries.labels
+	}
+
+	var sample []byte
+	sample = binary.AppendUvarint(sample, 1<<3|1) // Sample.value, fixed64
+	sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(value))
+	sample = binary.AppendUvarint(sample, 2<<3|0) // Sample.timestamp, varint
+	sample = binary.AppendUvarint(sample, uint64(timestamp))
+	series = appendBytesField(series, 2, sample) // TimeSeries.samples
+
+	return appendBytesField(buf, 1, series)
+}
+
+// appendBytesField appends a length-delimited protobuf field
+func appendBytesField(buf []byte, field int, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// snappyEncode produces a valid snappy block made only of literals. It doesn't compress, but
+// remote_write receivers only require the snappy framing.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+
+	const maxLiteral = 1 << 16
+	for len(src) > 0 {
+		chunk := src[:min(len(src), maxLiteral)]
+		src = src[len(chunk):]
+
+		n := len(chunk) - 1
+		switch {
+		case n < 60:
+			dst = append(dst, byte(n)<<2)
+		case n < 1<<8:
+			dst = append(dst, 60<<2, byte(n))
+		default:
+			dst = append(dst, 61<<2, byte(n), byte(n>>8))
+		}
+		dst = append(dst, chunk...)
+	}
+
+	return dst
+}
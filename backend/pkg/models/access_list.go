@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// AccessList is a named set of IP rules and basic auth users that proxies reference by ID, so the
+// same rules aren't repeated on every proxy. Its rules are added to those of each proxy using it.
+type AccessList struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	AllowedIPs []string         `json:"allowed_ips"` // only these addresses may connect when set
+	BlockedIPs []string         `json:"blocked_ips"` // ignored by proxies with allowed IPs, like a proxy's own
+	Users      []AccessListUser `json:"users"`       // basic auth accounts
+	CreatedAt  string           `json:"created_at"`
+	UpdatedAt  string           `json:"updated_at"`
+}
+
+// AccessListUser is a basic auth account of an access list
+type AccessListUser struct {
+	Username     string `json:"username"`
+	Password     string `json:"password,omitempty"`      // only sent to set a password, never stored
+	PasswordHash string `json:"password_hash,omitempty"` // bcrypt, never returned by the API
+}
+
+// NewAccessList creates a new AccessList with timestamps
+func NewAccessList(name string, allowedIPs, blockedIPs []string, users []AccessListUser) *AccessList {
+	now := time.Now().Format(time.RFC3339)
+	return &AccessList{
+		Name:       name,
+		AllowedIPs: allowedIPs,
+		BlockedIPs: blockedIPs,
+		Users:      users,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// UpdateTimestamp updates the UpdatedAt field to current time
+func (l *AccessList) UpdateTimestamp() {
+	l.UpdatedAt = time.Now().Format(time.RFC3339)
+}
+
+// Redacted returns a copy of the list without password hashes, for API responses
+func (l AccessList) Redacted() AccessList {
+	users := make([]AccessListUser, len(l.Users))
+	for i, user := range l.Users {
+		users[i] = AccessListUser{Username: user.Username}
+	}
+	l.Users = users
+	return l
+}
+
+// GenerateAccessListSlug generates a readable ID for an access list from its name
+func GenerateAccessListSlug(name string) string {
+	return "acl_" + GenerateProxySlug(name)
+}
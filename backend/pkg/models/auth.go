@@ -4,12 +4,36 @@ import (
 	"time"
 )
 
+// User roles
+const (
+	RoleAdmin        = "admin"         // Full access, including user management
+	RoleReadOnly     = "read_only"     // May view configuration but not change it
+	RoleConfigViewer = "config_viewer" // May only download the config export with secrets masked
+)
+
 type User struct {
 	ID       string    `json:"id"`
 	Username string    `json:"username"`
 	Password string    `json:"password"` // bcrypt hashed
+	Role     string    `json:"role,omitempty"`
+	Disabled bool      `json:"disabled,omitempty"` // Disabled users can't log in or use their sessions and API tokens
 	Created  time.Time `json:"created"`
 	Updated  time.Time `json:"updated"`
+	// SessionsValidAfter invalidates sessions created earlier, e.g. after a password reset
+	SessionsValidAfter time.Time `json:"sessions_valid_after,omitzero"`
+}
+
+// ValidRole reports whether role is a known user role
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleReadOnly || role == RoleConfigViewer
+}
+
+// EffectiveRole returns the user's role. Users created before roles existed are admins.
+func (u *User) EffectiveRole() string {
+	if u.Role == "" {
+		return RoleAdmin
+	}
+	return u.Role
 }
 
 type Session struct {
@@ -20,14 +44,47 @@ type Session struct {
 	Expires time.Time `json:"expires"`
 }
 
+// APIToken is a long-lived credential for scripts and CI pipelines. Only a hash of the token is stored.
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	UserID    string    `json:"user_id"`
+	TokenHash string    `json:"token_hash,omitempty"` // SHA-256 of the token, never sent to clients
+	Prefix    string    `json:"prefix"`               // Start of the token, to tell tokens apart
+	Scopes    []string  `json:"scopes"`               // "read" and/or "write"
+	ProxyIDs  []string  `json:"proxy_ids,omitempty"`  // Limits the token to these proxies
+	Tags      []string  `json:"tags,omitempty"`       // Limits the token to proxies with any of these tags
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires,omitzero"` // Zero for tokens that don't expire
+	LastUsed  time.Time `json:"last_used,omitzero"`
+}
+
+// SavedSearch is a named set of proxy list filters. Shared searches are listed for every user but
+// only their owner and admins may change them.
+type SavedSearch struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	UserID  string            `json:"user_id"`
+	Filters map[string]string `json:"filters"` // Query parameters of GET /api/proxies, e.g. {"status": "unhealthy", "q": "prod"}
+	Shared  bool              `json:"shared"`
+	Created time.Time         `json:"created"`
+	Updated time.Time         `json:"updated"`
+}
+
 type LoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type SetupRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	BootstrapToken string `json:"bootstrap_token"` // printed to the server log on first run, or SETUP_TOKEN
 }
 
 type AuthResponse struct {
@@ -1,13 +1,68 @@
 package models
 
+import "encoding/json"
+
 // CaddyConfig represents the Caddy JSON configuration structure.
 type CaddyConfig struct {
-	Apps CaddyApps `json:"apps"`
+	Logging *CaddyLogging   `json:"logging,omitempty"`
+	Storage json.RawMessage `json:"storage,omitempty"` // kept as is, so modules the manager doesn't know survive updates
+	Apps    CaddyApps       `json:"apps"`
+	ETag    string          `json:"-"` // Caddy's ETag of the config as read, sent back as If-Match when writing it
+}
+
+type CaddyLogging struct {
+	Logs map[string]CaddyLog `json:"logs,omitempty"`
+}
+
+type CaddyLog struct {
+	Writer  *CaddyLogWriter  `json:"writer,omitempty"`
+	Encoder *CaddyLogEncoder `json:"encoder,omitempty"`
+	Level   string           `json:"level,omitempty"`
+	Include []string         `json:"include,omitempty"` // Logger names this log receives
+	Exclude []string         `json:"exclude,omitempty"` // Logger names this log ignores
+}
+
+type CaddyLogWriter struct {
+	Output     string `json:"output"` // e.g. "file", "stderr"
+	Filename   string `json:"filename,omitempty"`
+	Roll       *bool  `json:"roll,omitempty"`
+	RollSizeMB int    `json:"roll_size_mb,omitempty"`
+	RollKeep   int    `json:"roll_keep,omitempty"`
+}
+
+type CaddyLogEncoder struct {
+	Format string `json:"format"` // e.g. "json", "console"
 }
 
 type CaddyApps struct {
-	HTTP CaddyHTTP `json:"http"`
-	TLS  *CaddyTLS `json:"tls,omitempty"`
+	HTTP   CaddyHTTP    `json:"http"`
+	TLS    *CaddyTLS    `json:"tls,omitempty"`
+	PKI    *CaddyPKI    `json:"pki,omitempty"`
+	Layer4 *CaddyLayer4 `json:"layer4,omitempty"` // stream proxies, needs Caddy built with the layer4 app
+}
+
+// CaddyLayer4 is the layer4 app, which proxies raw TCP and UDP connections
+type CaddyLayer4 struct {
+	Servers map[string]CaddyLayer4Server `json:"servers"`
+}
+
+type CaddyLayer4Server struct {
+	Listen []string           `json:"listen"` // e.g. "tcp/:5432", "udp/:53"
+	Routes []CaddyLayer4Route `json:"routes"`
+}
+
+type CaddyLayer4Route struct {
+	ID     string               `json:"@id,omitempty"`
+	Handle []CaddyLayer4Handler `json:"handle"`
+}
+
+type CaddyLayer4Handler struct {
+	Handler   string                `json:"handler"` // "proxy"
+	Upstreams []CaddyLayer4Upstream `json:"upstreams,omitempty"`
+}
+
+type CaddyLayer4Upstream struct {
+	Dial []string `json:"dial"` // e.g. "tcp/10.0.0.5:5432"
 }
 
 type CaddyHTTP struct {
@@ -19,10 +74,61 @@ type CaddyServer struct {
 	Routes         []CaddyRoute         `json:"routes"`
 	AutomaticHTTPS *CaddyAutomaticHTTPS `json:"automatic_https,omitempty"`
 	TLSPolicies    []CaddyTLSPolicy     `json:"tls_connection_policies,omitempty"`
+	Logs           *CaddyServerLogs     `json:"logs,omitempty"`
+	Errors         *CaddyServerErrors   `json:"errors,omitempty"`
+	Protocols      []string             `json:"protocols,omitempty"` // nil accepts h1, h2 and h3
+	Timeouts       *CaddyServerTimeouts `json:"timeouts,omitempty"`
+}
+
+// CaddyServerTimeouts bounds client connections of a server; unset ones don't time out, except idle
+// connections
+type CaddyServerTimeouts struct {
+	ReadBody string `json:"read_body,omitempty"`
+	Write    string `json:"write,omitempty"`
+}
+
+// CaddyServerErrors holds the routes Caddy runs when a request fails
+type CaddyServerErrors struct {
+	Routes []CaddyErrorRoute `json:"routes,omitempty"`
+}
+
+// CaddyErrorRoute is a route in a server's error handling chain. It is kept apart from CaddyRoute
+// because error responses take their status code from a placeholder rather than a number.
+type CaddyErrorRoute struct {
+	ID     string              `json:"@id,omitempty"`
+	Match  []CaddyErrorMatch   `json:"match,omitempty"`
+	Handle []CaddyErrorHandler `json:"handle"`
+}
+
+type CaddyErrorMatch struct {
+	HeaderRegexp map[string]CaddyRegexp `json:"header_regexp,omitempty"`
+}
+
+type CaddyRegexp struct {
+	Name    string `json:"name,omitempty"`
+	Pattern string `json:"pattern"`
+}
+
+// CaddyErrorHandler is a static_response handler for error routes
+type CaddyErrorHandler struct {
+	Handler    string              `json:"handler"`
+	StatusCode any                 `json:"status_code,omitempty"` // A number or a placeholder such as "{http.error.status_code}"
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+}
+
+// CaddyServerLogs enables access logging for a server and routes hosts to named loggers
+type CaddyServerLogs struct {
+	DefaultLoggerName    string            `json:"default_logger_name,omitempty"`
+	LoggerNames          map[string]string `json:"logger_names,omitempty"` // host -> logger name
+	SkipHosts            []string          `json:"skip_hosts,omitempty"`
+	SkipUnmappedHosts    bool              `json:"skip_unmapped_hosts,omitempty"`
+	ShouldLogCredentials bool              `json:"should_log_credentials,omitempty"`
 }
 
 type CaddyAutomaticHTTPS struct {
-	Disable bool `json:"disable"`
+	Disable          bool     `json:"disable"`
+	SkipCertificates []string `json:"skip_certificates,omitempty"` // Hosts served without managed certificates, e.g. with uploaded ones
 }
 
 type CaddyRoute struct {
@@ -33,8 +139,17 @@ type CaddyRoute struct {
 
 type CaddyMatch struct {
 	Host     []string            `json:"host,omitempty"`
+	Path     []string            `json:"path,omitempty"`
+	File     *CaddyFileMatch     `json:"file,omitempty"`
 	RemoteIP *CaddyRemoteIPMatch `json:"remote_ip,omitempty"`
 	Not      *CaddyMatch         `json:"not,omitempty"` // For inverting matches (e.g., blocking IPs)
+	// HeaderRegexp matches request headers, e.g. the client's preferred Accept-Language
+	HeaderRegexp map[string]CaddyRegexp `json:"header_regexp,omitempty"`
+}
+
+type CaddyFileMatch struct {
+	TryFiles  []string `json:"try_files,omitempty"`
+	SplitPath []string `json:"split_path,omitempty"`
 }
 
 type CaddyRemoteIPMatch struct {
@@ -42,19 +157,57 @@ type CaddyRemoteIPMatch struct {
 }
 
 type CaddyHandler struct {
-	Handler   string                       `json:"handler"`
-	Upstreams []CaddyUpstream              `json:"upstreams,omitempty"`
-	Transport *CaddyTransport              `json:"transport,omitempty"`
-	Headers   *CaddyHeaders                `json:"headers,omitempty"`
-	Providers map[string]CaddyAuthProvider `json:"providers,omitempty"` // For basic auth - must be a map
+	Handler   string          `json:"handler"`
+	Upstreams []CaddyUpstream `json:"upstreams,omitempty"`
+	Transport *CaddyTransport `json:"transport,omitempty"`
+	// LoadBalancing and HealthChecks configure upstream selection for reverse_proxy
+	LoadBalancing *CaddyLoadBalancing `json:"load_balancing,omitempty"`
+	HealthChecks  *CaddyHealthChecks  `json:"health_checks,omitempty"`
+	// FlushInterval controls response buffering; a negative duration flushes immediately
+	FlushInterval string                       `json:"flush_interval,omitempty"`
+	Headers       *CaddyHeaders                `json:"headers,omitempty"`
+	Providers     map[string]CaddyAuthProvider `json:"providers,omitempty"` // For basic auth - must be a map
+	// HandleResponse sends upstream responses matching a status through routes of their own
+	HandleResponse []CaddyResponseHandler `json:"handle_response,omitempty"`
 	// Redirect handler fields (legacy)
 	To         string `json:"to,omitempty"`          // Redirect destination URL
 	StatusCode int    `json:"status_code,omitempty"` // HTTP status code (301, 302)
 	// Static response handler fields
 	ResponseHeaders map[string][]string `json:"response_headers,omitempty"` // Response headers for static_response
+	Body            string              `json:"body,omitempty"`             // Response body for static_response
+	Abort           bool                `json:"abort,omitempty"`            // static_response closes the connection without responding
 	// Headers handler fields (direct fields, not nested)
 	Request  *CaddyHeadersRequest  `json:"request,omitempty"`
 	Response *CaddyHeadersResponse `json:"response,omitempty"`
+	// Subroute handler fields
+	Routes []CaddyRoute `json:"routes,omitempty"`
+	// Rewrite handler fields
+	URI             string `json:"uri,omitempty"`
+	StripPathPrefix string `json:"strip_path_prefix,omitempty"`
+	// Vars and file_server handler fields
+	Root              string   `json:"root,omitempty"`                // Site root directory
+	AccessLoggerNames []string `json:"access_logger_names,omitempty"` // Access loggers the request is logged to instead of the host's
+	// ACME server handler fields
+	CA       string `json:"ca,omitempty"`       // ID of the PKI certificate authority to issue from
+	Lifetime string `json:"lifetime,omitempty"` // Lifetime of issued certificates
+	// Bandwidth handler fields
+	Limit int64 `json:"limit,omitempty"` // Maximum response throughput in bytes per second
+	// Request body handler fields
+	MaxSize int64 `json:"max_size,omitempty"` // Largest request body in bytes, larger ones get 413
+	// WAF (Coraza) handler fields
+	Directives   string `json:"directives,omitempty"`     // SecLang directives, e.g. rule includes
+	LoadOWASPCRS bool   `json:"load_owasp_crs,omitempty"` // make the embedded rule files available to Include
+}
+
+// CaddyResponseHandler runs routes for the upstream responses it matches, e.g. with copy_response
+type CaddyResponseHandler struct {
+	Match  *CaddyResponseMatch `json:"match,omitempty"`
+	Routes []CaddyRoute        `json:"routes,omitempty"`
+}
+
+// CaddyResponseMatch matches upstream responses by status; a single digit matches its class, e.g. 2 for 2xx
+type CaddyResponseMatch struct {
+	StatusCode []int `json:"status_code,omitempty"`
 }
 
 type CaddyAuthProvider struct {
@@ -80,19 +233,77 @@ type CaddyHeadersResponse struct {
 }
 
 type CaddyTransport struct {
-	Protocol string    `json:"protocol"`
-	TLS      *struct{} `json:"tls,omitempty"`
+	Protocol string             `json:"protocol"`
+	TLS      *CaddyTransportTLS `json:"tls,omitempty"`
+	Versions []string           `json:"versions,omitempty"` // HTTP versions to use with the upstream, e.g. "h2c"
+	// Buffer sizes of upstream connections, in bytes
+	ReadBufferSize  int64 `json:"read_buffer_size,omitempty"`
+	WriteBufferSize int64 `json:"write_buffer_size,omitempty"`
+	// Timeouts of upstream connections
+	DialTimeout  string `json:"dial_timeout,omitempty"`
+	ReadTimeout  string `json:"read_timeout,omitempty"`
+	WriteTimeout string `json:"write_timeout,omitempty"`
+	// FastCGI transport fields
+	SplitPath []string `json:"split_path,omitempty"`
+}
+
+// CaddyTransportTLS enables TLS to the upstream
+type CaddyTransportTLS struct {
+	ServerName         string `json:"server_name,omitempty"` // SNI value, the upstream's host when empty
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
 }
 
 type CaddyUpstream struct {
 	Dial string `json:"dial"`
 }
 
+type CaddyLoadBalancing struct {
+	SelectionPolicy *CaddySelectionPolicy `json:"selection_policy,omitempty"`
+	TryDuration     string                `json:"try_duration,omitempty"` // How long to retry other upstreams
+}
+
+type CaddySelectionPolicy struct {
+	Policy string `json:"policy"` // e.g. "first", "round_robin"
+}
+
+type CaddyHealthChecks struct {
+	Active  *CaddyActiveHealthChecks  `json:"active,omitempty"`
+	Passive *CaddyPassiveHealthChecks `json:"passive,omitempty"`
+}
+
+type CaddyActiveHealthChecks struct {
+	URI          string              `json:"uri,omitempty"`      // path requested from each upstream
+	Interval     string              `json:"interval,omitempty"` // Caddy's default is 30s
+	Method       string              `json:"method,omitempty"`   // Caddy's default is GET
+	Body         string              `json:"body,omitempty"`
+	ExpectStatus int                 `json:"expect_status,omitempty"` // a single digit matches its class, e.g. 2 for 2xx
+	ExpectBody   string              `json:"expect_body,omitempty"`   // regular expression
+	Headers      map[string][]string `json:"headers,omitempty"`
+}
+
+type CaddyPassiveHealthChecks struct {
+	FailDuration    string `json:"fail_duration,omitempty"` // How long a failure counts against an upstream
+	MaxFails        int    `json:"max_fails,omitempty"`
+	UnhealthyStatus []int  `json:"unhealthy_status,omitempty"`
+}
+
 // TLS and ACME structures for DNS challenge support
 
 type CaddyTLS struct {
-	CertificateAuthorities map[string]CaddyCA  `json:"certificate_authorities,omitempty"`
-	Automation             *CaddyTLSAutomation `json:"automation,omitempty"`
+	CertificateAuthorities map[string]CaddyCA    `json:"certificate_authorities,omitempty"`
+	Certificates           *CaddyTLSCertificates `json:"certificates,omitempty"`
+	Automation             *CaddyTLSAutomation   `json:"automation,omitempty"`
+}
+
+type CaddyTLSCertificates struct {
+	Automate  []string               `json:"automate,omitempty"`   // Names to obtain and renew certificates for, even without routes
+	LoadFiles []CaddyCertificateFile `json:"load_files,omitempty"` // Certificates loaded from disk instead of managed by Caddy
+}
+
+type CaddyCertificateFile struct {
+	Certificate string   `json:"certificate"`
+	Key         string   `json:"key"`
+	Tags        []string `json:"tags,omitempty"`
 }
 
 type CaddyTLSAutomation struct {
@@ -117,17 +328,9 @@ type CaddyDNSChallenge struct {
 	Provider CaddyDNSProvider `json:"provider"`
 }
 
-type CaddyDNSProvider struct {
-	Name      string `json:"name"`
-	APIToken  string `json:"api_token,omitempty"`
-	AuthToken string `json:"auth_token,omitempty"`
-	Token     string `json:"token,omitempty"`
-	Email     string `json:"email,omitempty"`
-	// Gandi
-	BearerToken string `json:"bearer_token,omitempty"`
-	// DNSimple
-	APIAccessToken string `json:"api_access_token,omitempty"`
-}
+// CaddyDNSProvider is a dns.providers module config: "name" selects the module and the remaining
+// keys are its provider-specific fields
+type CaddyDNSProvider map[string]any
 
 type CaddyTLSPolicy struct {
 	Match   *CaddyTLSMatch `json:"match,omitempty"`
@@ -140,5 +343,27 @@ type CaddyTLSMatch struct {
 
 type CaddyIssuer struct {
 	Module     string          `json:"module"`
-	Challenges CaddyChallenges `json:"challenges,omitempty"`
+	Challenges CaddyChallenges `json:"challenges,omitzero"` // omitted entirely for issuers without challenges
+	CA         string          `json:"ca,omitempty"`        // PKI CA ID for the internal issuer
+}
+
+// PKI structures for Caddy's internal certificate authority
+
+type CaddyPKI struct {
+	CertificateAuthorities map[string]CaddyPKICA `json:"certificate_authorities,omitempty"`
+}
+
+type CaddyPKICA struct {
+	Name         string `json:"name,omitempty"`
+	InstallTrust *bool  `json:"install_trust,omitempty"`
+}
+
+// CaddyCAInfo is the CA description returned by Caddy's /pki/ca/{id} admin endpoint
+type CaddyCAInfo struct {
+	ID                      string `json:"id"`
+	Name                    string `json:"name"`
+	RootCommonName          string `json:"root_common_name"`
+	IntermediateCommonName  string `json:"intermediate_common_name"`
+	RootCertificate         string `json:"root_certificate"`
+	IntermediateCertificate string `json:"intermediate_certificate"`
 }
@@ -0,0 +1,14 @@
+package models
+
+// Default site modes
+const (
+	DefaultSitePage  = "page"  // serve the default-site page template
+	DefaultSiteAbort = "abort" // close the connection without a response, like nginx's 444
+)
+
+// DefaultSite is how Caddy answers requests for hostnames no proxy or redirect serves
+type DefaultSite struct {
+	Enabled    bool   `json:"enabled"`
+	Mode       string `json:"mode"`                  // DefaultSitePage or DefaultSiteAbort
+	StatusCode int    `json:"status_code,omitempty"` // status of the page, 404 when unset
+}
@@ -1,30 +1,156 @@
 package models
 
+import (
+	"slices"
+	"strings"
+)
+
 // ProxyMetadata represents the metadata for a proxy that's not stored in Caddy config.
 type ProxyMetadata struct {
 	ID                        string            `json:"id"`
+	Domain                    string            `json:"domain,omitempty"`     // only kept with a port, as the route has no host matcher then
+	TargetURL                 string            `json:"target_url,omitempty"` // only kept when it contains a template
+	SSLMode                   string            `json:"ssl_mode,omitempty"`   // tells apart modes that look the same in Caddy's config
 	HealthCheckEnabled        bool              `json:"health_check_enabled"`
+	HealthCheckType           string            `json:"health_check_type,omitempty"`
 	HealthCheckInterval       string            `json:"health_check_interval"`
 	HealthCheckPath           string            `json:"health_check_path"`
 	HealthCheckExpectedStatus int               `json:"health_check_expected_status"`
+	HealthCheckHeaders        map[string]string `json:"health_check_headers,omitempty"` // values are encrypted by the caddy client
+	HealthCheckUserAgent      string            `json:"health_check_user_agent,omitempty"`
+	HealthCheckMethod         string            `json:"health_check_method,omitempty"`
+	HealthCheckRequestBody    string            `json:"health_check_request_body,omitempty"`
+	HealthCheckStatusRange    string            `json:"health_check_status_range,omitempty"`
+	HealthCheckExpectedBody   string            `json:"health_check_expected_body,omitempty"`
+	HealthCheckBodyRegex      bool              `json:"health_check_body_regex,omitempty"`
+	HealthCheckViaCaddy       bool              `json:"health_check_via_caddy,omitempty"`
+	HealthCheckPaused         bool              `json:"health_check_paused,omitempty"`
+	AccessLog                 string            `json:"access_log,omitempty"`
 	ChallengeType             string            `json:"challenge_type"`
 	DNSProvider               string            `json:"dns_provider"`
 	DNSCredentials            map[string]string `json:"dns_credentials"`
 	CustomHeaders             map[string]string `json:"custom_headers"`
 	BasicAuth                 *BasicAuth        `json:"basic_auth"`
+	BandwidthLimit            int64             `json:"bandwidth_limit,omitempty"`
+	WAF                       *WAFSettings      `json:"waf,omitempty"`
+	GRPC                      bool              `json:"grpc,omitempty"`
+	WebSocket                 *WebSocket        `json:"websocket,omitempty"`
+	Timeouts                  *Timeouts         `json:"timeouts,omitempty"`
+	UpstreamHealth            *UpstreamHealth   `json:"upstream_health,omitempty"`
+	FlushInterval             string            `json:"flush_interval,omitempty"`
+	MaxBodySize               int64             `json:"max_body_size,omitempty"`
+	UpstreamSNI               string            `json:"upstream_sni,omitempty"`
+	PreserveHost              bool              `json:"preserve_host,omitempty"`
+	UpstreamType              string            `json:"upstream_type,omitempty"`
+	FastCGIRoot               string            `json:"fastcgi_root,omitempty"`
+	BackupTargetURL           string            `json:"backup_target_url,omitempty"`
+	TargetURLs                []string          `json:"target_urls,omitempty"`
+	LBPolicy                  string            `json:"lb_policy,omitempty"`
+	CanonicalRedirect         bool              `json:"canonical_redirect,omitempty"`
+	DependsOn                 []string          `json:"depends_on,omitempty"`
+	Links                     *ProxyLinks       `json:"links,omitempty"`
+	Isolated                  bool              `json:"isolated,omitempty"`
+	IsolatedListen            []string          `json:"isolated_listen,omitempty"`
+	Tags                      []string          `json:"tags,omitempty"`
+	Notes                     string            `json:"notes,omitempty"`
+	AllowedIPs                []string          `json:"allowed_ips,omitempty"`
+	BlockedIPs                []string          `json:"blocked_ips,omitempty"`
+	IPExceptionPaths          []string          `json:"ip_exception_paths,omitempty"`
+	AccessListIDs             []string          `json:"access_list_ids,omitempty"`
+	PathRules                 []PathRule        `json:"path_rules,omitempty"`
+	CustomCaddyJSON           string            `json:"custom_caddy_json,omitempty"`
 	CreatedAt                 string            `json:"created_at"`
 	UpdatedAt                 string            `json:"updated_at"`
+	LastAppliedAt             string            `json:"last_applied_at,omitempty"`
+	AppliedBy                 string            `json:"applied_by,omitempty"`
+}
+
+// DebugCapture records a time-limited access capture for a single proxy
+type DebugCapture struct {
+	ProxyID   string `json:"proxy_id"`
+	File      string `json:"file"`
+	StartedAt string `json:"started_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// ACMEDNSAccount is an acme-dns account registered to answer DNS challenges for one domain
+type ACMEDNSAccount struct {
+	Domain       string `json:"domain"`
+	ServerURL    string `json:"server_url"`
+	Username     string `json:"username"`
+	Password     string `json:"password,omitempty"` // encrypted by the caddy client
+	Subdomain    string `json:"subdomain"`
+	FullDomain   string `json:"full_domain"` // CNAME target for the domain's _acme-challenge record
+	RegisteredAt string `json:"registered_at"`
+}
+
+// IP list kinds, naming the proxy list an imported list's entries are added to
+const (
+	IPListAllowed = "allowed"
+	IPListBlocked = "blocked"
+)
+
+// IPListSource is an IP list imported into a proxy's allow or block list, from an uploaded file or
+// a URL fetched again on a schedule. Its entries are stored in a file of their own.
+type IPListSource struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	List            string `json:"list"`                       // IPListAllowed or IPListBlocked
+	URL             string `json:"url,omitempty"`              // empty for uploaded lists
+	RefreshInterval string `json:"refresh_interval,omitempty"` // how often a URL is fetched again, e.g. "24h"
+	Entries         int    `json:"entries"`
+	UpdatedAt       string `json:"updated_at"`           // when the entries last changed
+	LastFetchedAt   string `json:"last_fetched_at"`      // when the URL was last fetched, successfully or not
+	LastError       string `json:"last_error,omitempty"` // the previous entries stay in use while fetching fails
 }
 
 // MetadataStore manages proxy metadata storage.
 type MetadataStore struct {
-	Data map[string]ProxyMetadata `json:"proxies"`
+	Data                 map[string]ProxyMetadata       `json:"proxies"`
+	DeployTokens         map[string]string              `json:"deploy_tokens,omitempty"`         // proxy ID -> SHA-256 hash of its deploy hook token
+	DebugCaptures        map[string]DebugCapture        `json:"debug_captures,omitempty"`        // proxy ID -> active debug capture
+	ACMEDNSAccounts      map[string]ACMEDNSAccount      `json:"acmedns_accounts,omitempty"`      // domain -> acme-dns account
+	IPLists              map[string][]IPListSource      `json:"ip_lists,omitempty"`              // proxy ID -> imported IP lists
+	IDAliases            map[string]string              `json:"id_aliases,omitempty"`            // legacy proxy or redirect ID -> the ID it was renamed to
+	Streams              map[string]StreamMetadata      `json:"streams,omitempty"`               // stream ID -> what Caddy's layer4 config doesn't hold
+	AccessLists          map[string]AccessList          `json:"access_lists,omitempty"`          // access list ID -> shared IP rules and users
+	ProxyTemplates       map[string]ProxyTemplate       `json:"proxy_templates,omitempty"`       // template ID -> reusable proxy settings
+	ServerSettings       *ServerSettings                `json:"server_settings,omitempty"`       // protocols of the HTTP servers, nil uses Caddy's defaults
+	DefaultSite          *DefaultSite                   `json:"default_site,omitempty"`          // answer for unmanaged hostnames, nil leaves them to Caddy
+	Redirects            map[string]RedirectMetadata    `json:"redirects,omitempty"`             // redirect ID -> what Caddy's config doesn't hold
+	ChecksPaused         bool                           `json:"checks_paused,omitempty"`         // all health checks paused
+	NotificationChannels map[string]NotificationChannel `json:"notification_channels,omitempty"` // channel ID -> where alerts are delivered
+}
+
+// RedirectMetadata represents the metadata for a redirect that's not stored in Caddy config: its
+// last change, tags and notes
+type RedirectMetadata struct {
+	LastAppliedAt string   `json:"last_applied_at"`
+	AppliedBy     string   `json:"applied_by"`
+	Tags          []string `json:"tags,omitempty"`
+	Notes         string   `json:"notes,omitempty"`
+}
+
+// StreamMetadata represents the metadata for a stream proxy that's not stored in Caddy config
+type StreamMetadata struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
 }
 
 // NewMetadataStore creates a new metadata store
 func NewMetadataStore() *MetadataStore {
 	return &MetadataStore{
-		Data: make(map[string]ProxyMetadata),
+		Data:            make(map[string]ProxyMetadata),
+		DeployTokens:    make(map[string]string),
+		DebugCaptures:   make(map[string]DebugCapture),
+		ACMEDNSAccounts: make(map[string]ACMEDNSAccount),
+		IPLists:         make(map[string][]IPListSource),
+		IDAliases:       make(map[string]string),
+		Streams:         make(map[string]StreamMetadata),
+		AccessLists:     make(map[string]AccessList),
+		ProxyTemplates:  make(map[string]ProxyTemplate),
+		Redirects:       make(map[string]RedirectMetadata),
 	}
 }
 
@@ -32,17 +158,67 @@ func NewMetadataStore() *MetadataStore {
 func (ms *MetadataStore) Set(proxy Proxy) {
 	metadata := ProxyMetadata{
 		ID:                        proxy.ID,
+		SSLMode:                   proxy.SSLMode,
 		HealthCheckEnabled:        proxy.HealthCheckEnabled,
+		HealthCheckType:           proxy.HealthCheckType,
 		HealthCheckInterval:       proxy.HealthCheckInterval,
 		HealthCheckPath:           proxy.HealthCheckPath,
 		HealthCheckExpectedStatus: proxy.HealthCheckExpectedStatus,
+		HealthCheckHeaders:        proxy.HealthCheckHeaders,
+		HealthCheckUserAgent:      proxy.HealthCheckUserAgent,
+		HealthCheckMethod:         proxy.HealthCheckMethod,
+		HealthCheckRequestBody:    proxy.HealthCheckRequestBody,
+		HealthCheckStatusRange:    proxy.HealthCheckStatusRange,
+		HealthCheckExpectedBody:   proxy.HealthCheckExpectedBody,
+		HealthCheckBodyRegex:      proxy.HealthCheckBodyRegex,
+		HealthCheckViaCaddy:       proxy.HealthCheckViaCaddy,
+		HealthCheckPaused:         proxy.HealthCheckPaused,
+		AccessLog:                 proxy.AccessLog,
 		ChallengeType:             proxy.ChallengeType,
 		DNSProvider:               proxy.DNSProvider,
 		DNSCredentials:            proxy.DNSCredentials,
 		CustomHeaders:             proxy.CustomHeaders,
 		BasicAuth:                 proxy.BasicAuth,
+		BandwidthLimit:            proxy.BandwidthLimit,
+		WAF:                       proxy.WAF,
+		GRPC:                      proxy.GRPC,
+		WebSocket:                 proxy.WebSocket,
+		Timeouts:                  proxy.Timeouts,
+		UpstreamHealth:            proxy.UpstreamHealth,
+		FlushInterval:             proxy.FlushInterval,
+		MaxBodySize:               proxy.MaxBodySize,
+		UpstreamSNI:               proxy.UpstreamSNI,
+		PreserveHost:              proxy.PreserveHost,
+		UpstreamType:              proxy.UpstreamType,
+		FastCGIRoot:               proxy.FastCGIRoot,
+		BackupTargetURL:           proxy.BackupTargetURL,
+		TargetURLs:                proxy.TargetURLs,
+		LBPolicy:                  proxy.LBPolicy,
+		CanonicalRedirect:         proxy.CanonicalRedirect,
+		DependsOn:                 proxy.DependsOn,
+		Links:                     proxy.Links,
+		Isolated:                  proxy.Isolated,
+		IsolatedListen:            proxy.IsolatedListen,
+		Tags:                      proxy.Tags,
+		Notes:                     proxy.Notes,
+		AllowedIPs:                proxy.AllowedIPs,
+		BlockedIPs:                proxy.BlockedIPs,
+		IPExceptionPaths:          proxy.IPExceptionPaths,
+		AccessListIDs:             proxy.AccessListIDs,
+		PathRules:                 proxy.PathRules,
+		CustomCaddyJSON:           proxy.CustomCaddyJSON,
 		CreatedAt:                 proxy.CreatedAt,
 		UpdatedAt:                 proxy.UpdatedAt,
+		LastAppliedAt:             proxy.LastAppliedAt,
+		AppliedBy:                 proxy.AppliedBy,
+	}
+	// Caddy's config only has the expanded target, so keep the template to restore it
+	if strings.Contains(proxy.TargetURL, "{{") {
+		metadata.TargetURL = proxy.TargetURL
+	}
+	// Nor does it have the domain of a proxy on a port, which readable IDs don't contain either
+	if strings.Contains(proxy.Domain, ":") {
+		metadata.Domain = proxy.Domain
 	}
 	ms.Data[proxy.ID] = metadata
 }
@@ -54,6 +230,18 @@ func (ms *MetadataStore) Get(proxyID string) (ProxyMetadata, bool) {
 	return metadata, exists
 }
 
+// SetHealthCheckPaused stores whether a proxy's health check is paused, reporting false for an
+// unknown proxy
+func (ms *MetadataStore) SetHealthCheckPaused(proxyID string, paused bool) bool {
+	metadata, exists := ms.Data[proxyID]
+	if !exists {
+		return false
+	}
+	metadata.HealthCheckPaused = paused
+	ms.Data[proxyID] = metadata
+	return true
+}
+
 // Delete removes metadata for a proxy
 func (ms *MetadataStore) Delete(proxyID string) {
 	delete(ms.Data, proxyID)
@@ -62,16 +250,311 @@ func (ms *MetadataStore) Delete(proxyID string) {
 // ApplyToProxy applies stored metadata to a proxy object
 func (ms *MetadataStore) ApplyToProxy(proxy *Proxy) {
 	if metadata, exists := ms.Data[proxy.ID]; exists {
+		if metadata.Domain != "" {
+			proxy.Domain = metadata.Domain
+		}
+		proxy.TargetURL = metadata.TargetURL
+		proxy.SSLMode = metadata.SSLMode
 		proxy.HealthCheckEnabled = metadata.HealthCheckEnabled
+		proxy.HealthCheckType = metadata.HealthCheckType
 		proxy.HealthCheckInterval = metadata.HealthCheckInterval
 		proxy.HealthCheckPath = metadata.HealthCheckPath
 		proxy.HealthCheckExpectedStatus = metadata.HealthCheckExpectedStatus
+		proxy.HealthCheckHeaders = metadata.HealthCheckHeaders
+		proxy.HealthCheckUserAgent = metadata.HealthCheckUserAgent
+		proxy.HealthCheckMethod = metadata.HealthCheckMethod
+		proxy.HealthCheckRequestBody = metadata.HealthCheckRequestBody
+		proxy.HealthCheckStatusRange = metadata.HealthCheckStatusRange
+		proxy.HealthCheckExpectedBody = metadata.HealthCheckExpectedBody
+		proxy.HealthCheckBodyRegex = metadata.HealthCheckBodyRegex
+		proxy.HealthCheckViaCaddy = metadata.HealthCheckViaCaddy
+		proxy.HealthCheckPaused = metadata.HealthCheckPaused
+		proxy.AccessLog = metadata.AccessLog
 		proxy.ChallengeType = metadata.ChallengeType
 		proxy.DNSProvider = metadata.DNSProvider
 		proxy.DNSCredentials = metadata.DNSCredentials
 		proxy.CustomHeaders = metadata.CustomHeaders
 		proxy.BasicAuth = metadata.BasicAuth
+		proxy.BandwidthLimit = metadata.BandwidthLimit
+		proxy.WAF = metadata.WAF
+		proxy.GRPC = metadata.GRPC
+		proxy.WebSocket = metadata.WebSocket
+		proxy.Timeouts = metadata.Timeouts
+		proxy.UpstreamHealth = metadata.UpstreamHealth
+		proxy.FlushInterval = metadata.FlushInterval
+		proxy.MaxBodySize = metadata.MaxBodySize
+		proxy.UpstreamSNI = metadata.UpstreamSNI
+		proxy.PreserveHost = metadata.PreserveHost
+		proxy.UpstreamType = metadata.UpstreamType
+		proxy.FastCGIRoot = metadata.FastCGIRoot
+		proxy.BackupTargetURL = metadata.BackupTargetURL
+		proxy.TargetURLs = metadata.TargetURLs
+		proxy.LBPolicy = metadata.LBPolicy
+		proxy.CanonicalRedirect = metadata.CanonicalRedirect
+		proxy.DependsOn = metadata.DependsOn
+		proxy.Links = metadata.Links
+		proxy.Isolated = metadata.Isolated
+		proxy.IsolatedListen = metadata.IsolatedListen
+		proxy.Tags = metadata.Tags
+		proxy.Notes = metadata.Notes
+		proxy.AllowedIPs = metadata.AllowedIPs
+		proxy.BlockedIPs = metadata.BlockedIPs
+		proxy.IPExceptionPaths = metadata.IPExceptionPaths
+		proxy.AccessListIDs = metadata.AccessListIDs
+		proxy.PathRules = metadata.PathRules
+		proxy.CustomCaddyJSON = metadata.CustomCaddyJSON
 		proxy.CreatedAt = metadata.CreatedAt
 		proxy.UpdatedAt = metadata.UpdatedAt
+		proxy.LastAppliedAt = metadata.LastAppliedAt
+		proxy.AppliedBy = metadata.AppliedBy
+	}
+}
+
+// SetDeployToken stores the hashed deploy hook token for a proxy
+func (ms *MetadataStore) SetDeployToken(proxyID, tokenHash string) {
+	if ms.DeployTokens == nil {
+		ms.DeployTokens = make(map[string]string)
+	}
+	ms.DeployTokens[proxyID] = tokenHash
+}
+
+// GetDeployToken retrieves the hashed deploy hook token for a proxy
+func (ms *MetadataStore) GetDeployToken(proxyID string) (string, bool) {
+	tokenHash, exists := ms.DeployTokens[proxyID]
+
+	return tokenHash, exists
+}
+
+// DeleteDeployToken removes the deploy hook token for a proxy
+func (ms *MetadataStore) DeleteDeployToken(proxyID string) {
+	delete(ms.DeployTokens, proxyID)
+}
+
+// SetDebugCapture stores the active debug capture for a proxy
+func (ms *MetadataStore) SetDebugCapture(capture DebugCapture) {
+	if ms.DebugCaptures == nil {
+		ms.DebugCaptures = make(map[string]DebugCapture)
+	}
+	ms.DebugCaptures[capture.ProxyID] = capture
+}
+
+// GetDebugCapture retrieves the active debug capture for a proxy
+func (ms *MetadataStore) GetDebugCapture(proxyID string) (DebugCapture, bool) {
+	capture, exists := ms.DebugCaptures[proxyID]
+
+	return capture, exists
+}
+
+// DeleteDebugCapture removes the debug capture for a proxy
+func (ms *MetadataStore) DeleteDebugCapture(proxyID string) {
+	delete(ms.DebugCaptures, proxyID)
+}
+
+// SetIPLists stores the imported IP lists of a proxy
+func (ms *MetadataStore) SetIPLists(proxyID string, lists []IPListSource) {
+	if ms.IPLists == nil {
+		ms.IPLists = make(map[string][]IPListSource)
+	}
+	if len(lists) == 0 {
+		delete(ms.IPLists, proxyID)
+		return
+	}
+	ms.IPLists[proxyID] = lists
+}
+
+// GetIPLists retrieves the imported IP lists of a proxy
+func (ms *MetadataStore) GetIPLists(proxyID string) []IPListSource {
+	return ms.IPLists[proxyID]
+}
+
+// DeleteIPLists removes the imported IP lists of a proxy
+func (ms *MetadataStore) DeleteIPLists(proxyID string) {
+	delete(ms.IPLists, proxyID)
+}
+
+// RemoveDependency drops a proxy from every other proxy's dependency list
+func (ms *MetadataStore) RemoveDependency(proxyID string) {
+	for id, metadata := range ms.Data {
+		if slices.Contains(metadata.DependsOn, proxyID) {
+			metadata.DependsOn = slices.DeleteFunc(metadata.DependsOn, func(dep string) bool { return dep == proxyID })
+			ms.Data[id] = metadata
+		}
+	}
+}
+
+// SetACMEDNSAccount stores the acme-dns account for a domain
+func (ms *MetadataStore) SetACMEDNSAccount(account ACMEDNSAccount) {
+	if ms.ACMEDNSAccounts == nil {
+		ms.ACMEDNSAccounts = make(map[string]ACMEDNSAccount)
+	}
+	ms.ACMEDNSAccounts[account.Domain] = account
+}
+
+// GetACMEDNSAccount retrieves the acme-dns account for a domain
+func (ms *MetadataStore) GetACMEDNSAccount(domain string) (ACMEDNSAccount, bool) {
+	account, exists := ms.ACMEDNSAccounts[domain]
+	return account, exists
+}
+
+// DeleteACMEDNSAccount removes the acme-dns account for a domain
+func (ms *MetadataStore) DeleteACMEDNSAccount(domain string) {
+	delete(ms.ACMEDNSAccounts, domain)
+}
+
+// RenameProxy moves everything stored under a proxy's ID to newID, including other proxies'
+// dependencies on it
+func (ms *MetadataStore) RenameProxy(oldID, newID string) {
+	if metadata, exists := ms.Data[oldID]; exists {
+		metadata.ID = newID
+		ms.Data[newID] = metadata
+		delete(ms.Data, oldID)
+	}
+	if token, exists := ms.DeployTokens[oldID]; exists {
+		ms.SetDeployToken(newID, token)
+		delete(ms.DeployTokens, oldID)
+	}
+	if lists, exists := ms.IPLists[oldID]; exists {
+		ms.SetIPLists(newID, lists)
+		delete(ms.IPLists, oldID)
+	}
+
+	for id, metadata := range ms.Data {
+		if i := slices.Index(metadata.DependsOn, oldID); i >= 0 {
+			metadata.DependsOn = slices.Clone(metadata.DependsOn)
+			metadata.DependsOn[i] = newID
+			ms.Data[id] = metadata
+		}
+	}
+}
+
+// SetIDAlias records that oldID was renamed to newID, pointing earlier aliases of oldID at newID
+func (ms *MetadataStore) SetIDAlias(oldID, newID string) {
+	if ms.IDAliases == nil {
+		ms.IDAliases = make(map[string]string)
+	}
+	for alias, id := range ms.IDAliases {
+		if id == oldID {
+			ms.IDAliases[alias] = newID
+		}
+	}
+	delete(ms.IDAliases, newID)
+	ms.IDAliases[oldID] = newID
+}
+
+// ResolveIDAlias returns the current ID of a renamed proxy or redirect
+func (ms *MetadataStore) ResolveIDAlias(id string) (string, bool) {
+	current, exists := ms.IDAliases[id]
+	return current, exists
+}
+
+// SetRedirect stores when and by whom a redirect was last applied, along with its tags and notes
+func (ms *MetadataStore) SetRedirect(redirect Redirect) {
+	if ms.Redirects == nil {
+		ms.Redirects = make(map[string]RedirectMetadata)
+	}
+	ms.Redirects[redirect.ID] = RedirectMetadata{
+		LastAppliedAt: redirect.LastAppliedAt,
+		AppliedBy:     redirect.AppliedBy,
+		Tags:          redirect.Tags,
+		Notes:         redirect.Notes,
+	}
+}
+
+// ApplyToRedirect applies stored metadata to a redirect
+func (ms *MetadataStore) ApplyToRedirect(redirect *Redirect) {
+	if metadata, exists := ms.Redirects[redirect.ID]; exists {
+		redirect.LastAppliedAt = metadata.LastAppliedAt
+		redirect.AppliedBy = metadata.AppliedBy
+		redirect.Tags = metadata.Tags
+		redirect.Notes = metadata.Notes
+	}
+}
+
+// DeleteRedirect removes metadata for a redirect
+func (ms *MetadataStore) DeleteRedirect(redirectID string) {
+	delete(ms.Redirects, redirectID)
+}
+
+// RenameRedirect moves the metadata of a redirect to newID
+func (ms *MetadataStore) RenameRedirect(oldID, newID string) {
+	if applied, exists := ms.Redirects[oldID]; exists {
+		ms.Redirects[newID] = applied
+		delete(ms.Redirects, oldID)
+	}
+}
+
+// SetStream stores metadata for a stream proxy
+func (ms *MetadataStore) SetStream(stream StreamProxy) {
+	if ms.Streams == nil {
+		ms.Streams = make(map[string]StreamMetadata)
+	}
+	ms.Streams[stream.ID] = StreamMetadata{
+		Name:      stream.Name,
+		CreatedAt: stream.CreatedAt,
+		UpdatedAt: stream.UpdatedAt,
+	}
+}
+
+// ApplyToStream applies stored metadata to a stream proxy
+func (ms *MetadataStore) ApplyToStream(stream *StreamProxy) {
+	if metadata, exists := ms.Streams[stream.ID]; exists {
+		stream.Name = metadata.Name
+		stream.CreatedAt = metadata.CreatedAt
+		stream.UpdatedAt = metadata.UpdatedAt
+	}
+}
+
+// DeleteStream removes metadata for a stream proxy
+func (ms *MetadataStore) DeleteStream(streamID string) {
+	delete(ms.Streams, streamID)
+}
+
+// SetAccessList stores a shared access list
+func (ms *MetadataStore) SetAccessList(list AccessList) {
+	if ms.AccessLists == nil {
+		ms.AccessLists = make(map[string]AccessList)
+	}
+	ms.AccessLists[list.ID] = list
+}
+
+// GetAccessList retrieves a shared access list
+func (ms *MetadataStore) GetAccessList(id string) (AccessList, bool) {
+	list, exists := ms.AccessLists[id]
+	return list, exists
+}
+
+// DeleteAccessList removes a shared access list
+func (ms *MetadataStore) DeleteAccessList(id string) {
+	delete(ms.AccessLists, id)
+}
+
+// SetProxyTemplate stores a proxy template
+func (ms *MetadataStore) SetProxyTemplate(template ProxyTemplate) {
+	if ms.ProxyTemplates == nil {
+		ms.ProxyTemplates = make(map[string]ProxyTemplate)
+	}
+	ms.ProxyTemplates[template.ID] = template
+}
+
+// GetProxyTemplate retrieves a proxy template
+func (ms *MetadataStore) GetProxyTemplate(id string) (ProxyTemplate, bool) {
+	template, exists := ms.ProxyTemplates[id]
+	return template, exists
+}
+
+// DeleteProxyTemplate removes a proxy template
+func (ms *MetadataStore) DeleteProxyTemplate(id string) {
+	delete(ms.ProxyTemplates, id)
+}
+
+// AccessListUsers returns the IDs of the proxies referencing an access list
+func (ms *MetadataStore) AccessListUsers(id string) []string {
+	var proxyIDs []string
+	for proxyID, metadata := range ms.Data {
+		if slices.Contains(metadata.AccessListIDs, id) {
+			proxyIDs = append(proxyIDs, proxyID)
+		}
 	}
+	slices.Sort(proxyIDs)
+	return proxyIDs
 }
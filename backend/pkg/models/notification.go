@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// NotificationChannel is somewhere alerts are delivered, such as a Slack channel or an email address
+type NotificationChannel struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`             // a registered channel type, e.g. "slack" or "email"
+	Enabled   bool              `json:"enabled"`          // disabled channels keep their settings but get no alerts
+	Events    []string          `json:"events"`           // events delivered to the channel, every alert when empty
+	Settings  map[string]string `json:"settings"`         // type-specific, e.g. webhook_url; values are encrypted in the metadata file
+	Digest    string            `json:"digest,omitempty"` // e.g. "1h": collect non-critical alerts into a summary sent this often
+	CreatedAt string            `json:"created_at"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+// NewNotificationChannel creates a new NotificationChannel with timestamps
+func NewNotificationChannel(name, channelType string, enabled bool, events []string, settings map[string]string) *NotificationChannel {
+	now := time.Now().Format(time.RFC3339)
+	return &NotificationChannel{
+		Name:      name,
+		Type:      channelType,
+		Enabled:   enabled,
+		Events:    events,
+		Settings:  settings,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// GenerateNotificationChannelSlug generates a readable ID for a notification channel from its name
+func GenerateNotificationChannelSlug(name string) string {
+	return "notify_" + GenerateProxySlug(name)
+}
+
+// SetNotificationChannel stores a notification channel
+func (ms *MetadataStore) SetNotificationChannel(channel NotificationChannel) {
+	if ms.NotificationChannels == nil {
+		ms.NotificationChannels = make(map[string]NotificationChannel)
+	}
+	ms.NotificationChannels[channel.ID] = channel
+}
+
+// GetNotificationChannel retrieves a notification channel
+func (ms *MetadataStore) GetNotificationChannel(id string) (NotificationChannel, bool) {
+	channel, exists := ms.NotificationChannels[id]
+	return channel, exists
+}
+
+// DeleteNotificationChannel removes a notification channel
+func (ms *MetadataStore) DeleteNotificationChannel(id string) {
+	delete(ms.NotificationChannels, id)
+}
@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/redact"
 )
 
 // BasicAuth represents HTTP Basic Authentication configuration
@@ -14,36 +16,139 @@ type BasicAuth struct {
 	Password string `json:"password"` // This will be hashed by Caddy
 }
 
+// WAF rule sets
+const (
+	WAFRuleSetCRS         = "owasp_crs"   // Coraza's recommended settings with the OWASP Core Rule Set
+	WAFRuleSetRecommended = "recommended" // Coraza's recommended settings only, e.g. to add custom rules later
+)
+
+// WAFSettings enables Caddy's Coraza web application firewall for a proxy
+type WAFSettings struct {
+	Enabled       bool   `json:"enabled"`
+	RuleSet       string `json:"rule_set"`       // WAFRuleSetCRS or WAFRuleSetRecommended
+	ParanoiaLevel int    `json:"paranoia_level"` // CRS paranoia level, 1 (fewest false positives) to 4
+	DetectionOnly bool   `json:"detection_only"` // log violations without blocking requests
+}
+
+// WebSocket tunes how Caddy proxies WebSocket connections, which it upgrades by default
+type WebSocket struct {
+	Enabled         bool   `json:"enabled"`
+	ReadBufferSize  int64  `json:"read_buffer_size,omitempty"`  // bytes, Caddy's default when 0
+	WriteBufferSize int64  `json:"write_buffer_size,omitempty"` // bytes, Caddy's default when 0
+	FlushInterval   string `json:"flush_interval,omitempty"`    // e.g. "100ms", "-1s" flushes immediately
+	Path            string `json:"path,omitempty"`              // endpoint health checks probe for upgrade support, "/" by default
+}
+
+// Timeouts bounds how long Caddy waits on a proxy's upstream; Caddy's defaults apply to unset ones
+type Timeouts struct {
+	Dial  string `json:"dial,omitempty"`  // connecting to the upstream, e.g. "10s"
+	Read  string `json:"read,omitempty"`  // between reads of the upstream's response
+	Write string `json:"write,omitempty"` // between writes of the request to the upstream
+}
+
+// UpstreamHealth has Caddy itself check a proxy's upstreams and stop sending traffic to dead ones,
+// alongside the manager's own health checks
+type UpstreamHealth struct {
+	Active          bool   `json:"active"`                     // probe each upstream at the proxy's health check path, interval and expected status
+	Passive         bool   `json:"passive"`                    // take an upstream out of rotation after failed requests
+	MaxFails        int    `json:"max_fails,omitempty"`        // passive: failures within fail_duration that take an upstream out, 1 by default
+	FailDuration    string `json:"fail_duration,omitempty"`    // passive: how long a failure counts, "30s" by default
+	UnhealthyStatus []int  `json:"unhealthy_status,omitempty"` // passive: response codes counted as failures, 502, 503 and 504 by default
+}
+
+// ProxyLinks points on-call operators from a proxy to where it is documented and watched
+type ProxyLinks struct {
+	Dashboard  string `json:"dashboard,omitempty"`  // monitoring dashboard, e.g. Grafana
+	Runbook    string `json:"runbook,omitempty"`    // what to do when the proxy is unhealthy
+	Repository string `json:"repository,omitempty"` // source of the service behind the proxy
+}
+
 // HealthStatus represents the health check status for a proxy
 type HealthStatus struct {
-	Status      string `json:"status"`       // "Healthy", "Unhealthy", "Pending"
-	LastChecked string `json:"last_checked"` // RFC3339 timestamp
-	Message     string `json:"message"`      // error message if unhealthy
+	Status            string   `json:"status"`                         // "Healthy", "Unhealthy", "Pending"
+	LastChecked       string   `json:"last_checked"`                   // RFC3339 timestamp
+	Message           string   `json:"message"`                        // error message if unhealthy
+	ResponseTimeMs    int64    `json:"response_time_ms,omitempty"`     // duration of the last check request that got a response
+	AvgResponseTimeMs int64    `json:"avg_response_time_ms,omitempty"` // rolling average over the recent checks that got a response
+	DownDependencies  []string `json:"down_dependencies,omitempty"`    // unhealthy dependencies, when unhealthy
+	WebSocketUpgrade  *bool    `json:"websocket_upgrade,omitempty"`    // whether the upstream accepted the last upgrade probe
+	Paused            bool     `json:"paused,omitempty"`               // checks are paused, globally or for the proxy
 }
 
 // Proxy represents a reverse proxy configuration
 type Proxy struct {
 	ID                        string            `json:"id"`
 	Domain                    string            `json:"domain"`
+	DisplayDomain             string            `json:"display_domain,omitempty"` // Unicode form of an internationalized domain, computed not stored
 	TargetURL                 string            `json:"target_url"`
-	SSLMode                   string            `json:"ssl_mode"`          // "auto", "custom", "none"
-	ChallengeType             string            `json:"challenge_type"`    // "http", "dns"
-	DNSProvider               string            `json:"dns_provider"`      // "cloudflare", "digitalocean", "duckdns"
-	DNSCredentials            map[string]string `json:"dns_credentials"`   // provider-specific credentials
-	CustomHeaders             map[string]string `json:"custom_headers"`    // custom request headers
-	BasicAuth                 *BasicAuth        `json:"basic_auth"`        // optional basic authentication
-	CustomCaddyJSON           string            `json:"custom_caddy_json"` // custom Caddy JSON snippet
-	Status                    string            `json:"status"`            // "active", "inactive", "error"
+	TargetURLs                []string          `json:"target_urls,omitempty"` // all upstreams when load balancing, TargetURL is the first
+	LBPolicy                  string            `json:"lb_policy,omitempty"`   // "round_robin", "least_conn", "ip_hash"
+	BackupTargetURL           string            `json:"backup_target_url"`     // used when the primary target is down
+	SSLMode                   string            `json:"ssl_mode"`              // "auto", "custom", "none"
+	ChallengeType             string            `json:"challenge_type"`        // "http", "dns"
+	DNSProvider               string            `json:"dns_provider"`          // "cloudflare", "digitalocean", "duckdns"
+	DNSCredentials            map[string]string `json:"dns_credentials"`       // provider-specific credentials
+	CustomHeaders             map[string]string `json:"custom_headers"`        // custom request headers
+	BasicAuth                 *BasicAuth        `json:"basic_auth"`            // optional basic authentication
+	CustomCaddyJSON           string            `json:"custom_caddy_json"`     // custom Caddy JSON snippet
+	WAF                       *WAFSettings      `json:"waf,omitempty"`         // Coraza web application firewall, needs the module in Caddy
+	Status                    string            `json:"status"`                // "active", "inactive", "error"
 	HealthCheckEnabled        bool              `json:"health_check_enabled"`
-	HealthCheckInterval       string            `json:"health_check_interval"`        // e.g., "30s"
-	HealthCheckPath           string            `json:"health_check_path"`            // e.g., "/"
-	HealthCheckExpectedStatus int               `json:"health_check_expected_status"` // e.g., 200
-	AllowedIPs                []string          `json:"allowed_ips"`                  // IP whitelist
-	BlockedIPs                []string          `json:"blocked_ips"`                  // IP blacklist
+	HealthCheckType           string            `json:"health_check_type"`              // "http" (default) or "tcp"
+	HealthCheckInterval       string            `json:"health_check_interval"`          // e.g., "30s"
+	HealthCheckPath           string            `json:"health_check_path"`              // e.g., "/"
+	HealthCheckExpectedStatus int               `json:"health_check_expected_status"`   // e.g., 200
+	HealthCheckHeaders        map[string]string `json:"health_check_headers"`           // sent with health check requests, e.g. Authorization
+	HealthCheckUserAgent      string            `json:"health_check_user_agent"`        // overrides Go's default User-Agent
+	HealthCheckMethod         string            `json:"health_check_method"`            // GET (default), HEAD or POST
+	HealthCheckRequestBody    string            `json:"health_check_request_body"`      // sent with POST checks
+	HealthCheckStatusRange    string            `json:"health_check_status_range"`      // e.g. "200-299" or "200,204"; overrides the expected status
+	HealthCheckExpectedBody   string            `json:"health_check_expected_body"`     // substring the response body must contain
+	HealthCheckBodyRegex      bool              `json:"health_check_body_regex"`        // match the expected body as a regular expression
+	HealthCheckViaCaddy       bool              `json:"health_check_via_caddy"`         // check the domain through Caddy instead of the target
+	HealthCheckPaused         bool              `json:"health_check_paused,omitempty"`  // keep the check registered but skip its requests
+	AccessLog                 string            `json:"access_log,omitempty"`           // requests written to the proxy's access log: "all", "errors" or none
+	AllowedIPs                []string          `json:"allowed_ips"`                    // IP whitelist
+	BlockedIPs                []string          `json:"blocked_ips"`                    // IP blacklist
+	IPExceptionPaths          []string          `json:"ip_exception_paths"`             // paths reachable regardless of IP lists, e.g. "/api/webhook/*"
+	AccessListIDs             []string          `json:"access_list_ids,omitempty"`      // shared access lists whose rules and users apply too
+	PathRules                 []PathRule        `json:"path_rules,omitempty"`           // path prefixes sent to other upstreams, e.g. /api
+	IPLists                   []IPListSource    `json:"ip_lists,omitempty"`             // imported lists added to the allow or block list, managed separately
+	BandwidthLimit            int64             `json:"bandwidth_limit"`                // outbound bytes/sec, 0 = unlimited
+	GRPC                      bool              `json:"grpc"`                           // upstream is a gRPC service
+	WebSocket                 *WebSocket        `json:"websocket,omitempty"`            // WebSocket tuning and upgrade probing
+	Timeouts                  *Timeouts         `json:"timeouts,omitempty"`             // upstream dial, read and write timeouts
+	UpstreamHealth            *UpstreamHealth   `json:"upstream_health,omitempty"`      // Caddy's active and passive health checks
+	FlushInterval             string            `json:"flush_interval,omitempty"`       // response buffering, "-1s" streams immediately, e.g. for SSE
+	MaxBodySize               int64             `json:"max_body_size,omitempty"`        // largest request body accepted in bytes, 0 = unlimited
+	UpstreamSNI               string            `json:"upstream_sni,omitempty"`         // TLS server name sent to https upstreams instead of the target host
+	PreserveHost              bool              `json:"preserve_host,omitempty"`        // pass the client's Host header instead of the target host
+	UpstreamType              string            `json:"upstream_type"`                  // "http" or "fastcgi"
+	FastCGIRoot               string            `json:"fastcgi_root"`                   // document root for fastcgi upstreams
+	CanonicalRedirect         bool              `json:"canonical_redirect"`             // redirect the www/apex partner domain here
+	DependsOn                 []string          `json:"depends_on"`                     // IDs of proxies this one needs, e.g. an auth service
+	Links                     *ProxyLinks       `json:"links,omitempty"`                // dashboard, runbook and repository URLs
+	Isolated                  bool              `json:"isolated,omitempty"`             // served by a Caddy server of its own
+	IsolatedListen            []string          `json:"isolated_listen,omitempty"`      // listen addresses of the isolated server, e.g. ":8443"
+	Tags                      []string          `json:"tags,omitempty"`                 // lower-case labels for grouping, e.g. by project or environment
+	Notes                     string            `json:"notes,omitempty"`                // free-form operator notes
+	DownDependencies          []string          `json:"down_dependencies,omitempty"`    // computed from health checks, not stored
+	ResponseTimeMs            int64             `json:"response_time_ms,omitempty"`     // latency of the last health check, not stored
+	AvgResponseTimeMs         int64             `json:"avg_response_time_ms,omitempty"` // rolling average of health check latency, not stored
+	LastAppliedAt             string            `json:"last_applied_at,omitempty"`      // when a user last applied the proxy to Caddy
+	AppliedBy                 string            `json:"applied_by,omitempty"`           // who did, a username, "deploy-hook" or "kubernetes-sync"
 	CreatedAt                 string            `json:"created_at"`
 	UpdatedAt                 string            `json:"updated_at"`
 }
 
+// PathRule sends a proxy's requests under a path prefix to an upstream of their own
+type PathRule struct {
+	Path          string            `json:"path"`                     // prefix such as "/api", matching /api and /api/*
+	TargetURL     string            `json:"target_url"`               // plain HTTP(S) upstream
+	StripPrefix   bool              `json:"strip_prefix,omitempty"`   // remove the prefix before proxying
+	CustomHeaders map[string]string `json:"custom_headers,omitempty"` // set on top of the proxy's custom headers
+}
+
 // NewProxy creates a new Proxy with generated ID and timestamps
 func NewProxy(domain, targetURL, sslMode string) *Proxy {
 	now := time.Now().Format(time.RFC3339)
@@ -64,6 +169,7 @@ func NewProxy(domain, targetURL, sslMode string) *Proxy {
 		HealthCheckExpectedStatus: 200,        // default expected status
 		AllowedIPs:                []string{}, // empty whitelist by default
 		BlockedIPs:                []string{}, // empty blacklist by default
+		UpstreamType:              "http",
 		CreatedAt:                 now,
 		UpdatedAt:                 now,
 	}
@@ -74,8 +180,90 @@ func (p *Proxy) UpdateTimestamp() {
 	p.UpdatedAt = time.Now().Format(time.RFC3339)
 }
 
+// MarkApplied records that user is applying the proxy to Caddy now
+func (p *Proxy) MarkApplied(user string) {
+	p.LastAppliedAt = time.Now().Format(time.RFC3339)
+	p.AppliedBy = user
+}
+
+// Redacted returns a copy of the proxy with its secrets masked, for API responses: health check
+// header values, DNS provider credentials and the basic auth password
+func (p Proxy) Redacted() Proxy {
+	p.HealthCheckHeaders = maskValues(p.HealthCheckHeaders)
+	p.DNSCredentials = maskValues(p.DNSCredentials)
+	if p.BasicAuth != nil && p.BasicAuth.Password != "" {
+		basicAuth := *p.BasicAuth
+		basicAuth.Password = redact.Mask
+		p.BasicAuth = &basicAuth
+	}
+	return p
+}
+
+// RestoreRedacted puts back the values Redacted masked from previous, so a proxy read from the API
+// can be sent back to update it without knowing its secrets
+func (p *Proxy) RestoreRedacted(previous Proxy) {
+	p.HealthCheckHeaders = restoreValues(p.HealthCheckHeaders, previous.HealthCheckHeaders)
+	p.DNSCredentials = restoreValues(p.DNSCredentials, previous.DNSCredentials)
+	if p.BasicAuth != nil && p.BasicAuth.Password == redact.Mask {
+		p.BasicAuth.Password = ""
+		if previous.BasicAuth != nil {
+			p.BasicAuth.Password = previous.BasicAuth.Password
+		}
+	}
+}
+
+// maskValues returns a copy of values with each one masked
+func maskValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(values))
+	for key := range values {
+		masked[key] = redact.Mask
+	}
+	return masked
+}
+
+// restoreValues returns a copy of values with masked ones replaced by those in previous. Masked
+// values previous doesn't have are dropped.
+func restoreValues(values, previous map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	restored := make(map[string]string, len(values))
+	for key, value := range values {
+		if value != redact.Mask {
+			restored[key] = value
+		} else if old, ok := previous[key]; ok {
+			restored[key] = old
+		}
+	}
+	return restored
+}
+
 // GenerateProxyID generates a unique ID for a proxy based on domain and timestamp
 func GenerateProxyID(domain string) string {
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	return fmt.Sprintf("proxy_%s_%s", strings.ReplaceAll(domain, ".", "_"), timestamp)
 }
+
+// GenerateProxySlug generates a readable ID for a proxy from its domain, e.g. nextcloud-example-com
+// for nextcloud.example.com. Unlike GenerateProxyID it is the same every time a domain's proxy is
+// created, so callers must make it unique.
+func GenerateProxySlug(domain string) string {
+	domain = strings.ReplaceAll(strings.ToLower(domain), "*", "wildcard")
+
+	var slug strings.Builder
+	for _, r := range domain {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			slug.WriteRune(r)
+		} else if slug.Len() > 0 && !strings.HasSuffix(slug.String(), "-") {
+			slug.WriteByte('-')
+		}
+	}
+
+	if id := strings.TrimSuffix(slug.String(), "-"); id != "" {
+		return id
+	}
+	return "proxy"
+}
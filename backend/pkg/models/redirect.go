@@ -14,9 +14,14 @@ type Redirect struct {
 	DestinationURL string   `json:"destination_url"`
 	RedirectCode   int      `json:"redirect_code"` // 301 or 302
 	PreservePath   bool     `json:"preserve_path"`
-	Status         string   `json:"status"` // "active", "inactive", "error"
+	Tags           []string `json:"tags,omitempty"`  // lower-case labels for grouping, e.g. by project or environment
+	Notes          string   `json:"notes,omitempty"` // free-form operator notes
+	Status         string   `json:"status"`          // "active", "inactive", "error"
 	CreatedAt      string   `json:"created_at"`
 	UpdatedAt      string   `json:"updated_at"`
+	LastAppliedAt  string   `json:"last_applied_at,omitempty"` // when a user last applied the redirect to Caddy
+	AppliedBy      string   `json:"applied_by,omitempty"`      // who did, by username
+	Warnings       []string `json:"warnings,omitempty"`        // Set on save responses only, e.g. for redirect chains
 }
 
 // NewRedirect creates a new Redirect with generated ID and timestamps
@@ -46,12 +51,24 @@ func (r *Redirect) UpdateTimestamp() {
 	r.UpdatedAt = time.Now().Format(time.RFC3339)
 }
 
+// MarkApplied records that user is applying the redirect to Caddy now
+func (r *Redirect) MarkApplied(user string) {
+	r.LastAppliedAt = time.Now().Format(time.RFC3339)
+	r.AppliedBy = user
+}
+
 // GenerateRedirectID generates a unique ID for a redirect based on domain and timestamp
 func GenerateRedirectID(domain string) string {
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 	return fmt.Sprintf("redirect_%s_%s", strings.ReplaceAll(domain, ".", "_"), timestamp)
 }
 
+// GenerateRedirectSlug generates a readable ID for a redirect from its first source domain. The
+// redirect_ prefix tells redirect routes apart from proxy routes in Caddy's config.
+func GenerateRedirectSlug(domain string) string {
+	return "redirect_" + GenerateProxySlug(domain)
+}
+
 // Validate validates the redirect configuration
 func (r *Redirect) Validate() error {
 	if len(r.SourceDomains) == 0 {
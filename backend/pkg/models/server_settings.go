@@ -0,0 +1,48 @@
+package models
+
+// ServerProtocols are the HTTP versions a Caddy server accepts from clients. HTTP/1.1 is always on.
+type ServerProtocols struct {
+	HTTP2 bool `json:"h2"`  // HTTP/2 over TLS
+	H2C   bool `json:"h2c"` // cleartext HTTP/2, e.g. from a load balancer or gRPC clients in front of Caddy
+	HTTP3 bool `json:"h3"`  // HTTP/3 over QUIC, on UDP
+}
+
+// ServerSettings holds the protocols of the manager's Caddy servers: a default for every server and
+// overrides for servers by name, such as https_enabled or an isolated proxy's server
+type ServerSettings struct {
+	Default ServerProtocols            `json:"default"`
+	Servers map[string]ServerProtocols `json:"servers,omitempty"`
+}
+
+// DefaultServerProtocols are Caddy's own defaults: HTTP/1.1, HTTP/2 and HTTP/3
+func DefaultServerProtocols() ServerProtocols {
+	return ServerProtocols{HTTP2: true, HTTP3: true}
+}
+
+// ProtocolsFor returns the protocols of the named server
+func (s ServerSettings) ProtocolsFor(serverName string) ServerProtocols {
+	if protocols, exists := s.Servers[serverName]; exists {
+		return protocols
+	}
+	return s.Default
+}
+
+// CaddyProtocols returns the value of a Caddy server's protocols field, nil when it matches Caddy's
+// defaults so servers using them keep the field unset
+func (p ServerProtocols) CaddyProtocols() []string {
+	if p == DefaultServerProtocols() {
+		return nil
+	}
+
+	protocols := []string{"h1"}
+	if p.HTTP2 {
+		protocols = append(protocols, "h2")
+	}
+	if p.H2C {
+		protocols = append(protocols, "h2c")
+	}
+	if p.HTTP3 {
+		protocols = append(protocols, "h3")
+	}
+	return protocols
+}
@@ -0,0 +1,23 @@
+package models
+
+// Caddy storage modules the manager can configure. Only file_system is part of standard Caddy.
+const (
+	StorageFileSystem = "file_system" // a local or shared directory
+	StorageRedis      = "redis"       // github.com/pberkel/caddy-storage-redis
+	StorageConsul     = "consul"      // github.com/pteich/caddy-tlsconsul
+)
+
+// StorageSettings is where Caddy keeps certificates, keys and locks. Clustered Caddy instances
+// pointed at the same storage share certificates and coordinate issuance.
+type StorageSettings struct {
+	Module    string   `json:"module"`
+	Root      string   `json:"root,omitempty"`       // file_system: directory, Caddy's default data directory when empty
+	Addresses []string `json:"addresses,omitempty"`  // redis and consul: host:port of the servers, one for consul
+	DB        int      `json:"db,omitempty"`         // redis: database number
+	Username  string   `json:"username,omitempty"`   // redis
+	Password  string   `json:"password,omitempty"`   // redis, write only
+	Token     string   `json:"token,omitempty"`      // consul ACL token, write only
+	Prefix    string   `json:"prefix,omitempty"`     // key prefix, the module's default when empty
+	TLS       bool     `json:"tls,omitempty"`        // redis and consul: connect over TLS
+	SecretSet bool     `json:"secret_set,omitempty"` // a password or token is configured; responses only
+}
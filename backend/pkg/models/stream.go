@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stream protocols
+const (
+	StreamProtocolTCP = "tcp"
+	StreamProtocolUDP = "udp"
+)
+
+// StreamProxy forwards raw TCP or UDP connections on a port to upstreams, e.g. for databases, game
+// servers or SSH. Caddy serves it with the layer4 app.
+type StreamProxy struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Protocol  string   `json:"protocol"`  // "tcp" or "udp"
+	Listen    string   `json:"listen"`    // ":port" or "ip:port"
+	Upstreams []string `json:"upstreams"` // "host:port", connections are spread across them
+	Status    string   `json:"status"`    // "active", "inactive", "error"
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// NewStreamProxy creates a new StreamProxy with generated ID and timestamps
+func NewStreamProxy(name, protocol, listen string, upstreams []string) *StreamProxy {
+	now := time.Now().Format(time.RFC3339)
+	return &StreamProxy{
+		ID:        GenerateStreamID(protocol, listen),
+		Name:      name,
+		Protocol:  protocol,
+		Listen:    listen,
+		Upstreams: upstreams,
+		Status:    "active",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// UpdateTimestamp updates the UpdatedAt field to current time
+func (s *StreamProxy) UpdateTimestamp() {
+	s.UpdatedAt = time.Now().Format(time.RFC3339)
+}
+
+// GenerateStreamID generates a unique ID for a stream based on its listener and timestamp
+func GenerateStreamID(protocol, listen string) string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return fmt.Sprintf("stream_%s_%s_%s", protocol, strings.NewReplacer(".", "_", ":", "").Replace(listen), timestamp)
+}
+
+// GenerateStreamSlug generates a readable ID for a stream from its listener, e.g. stream_tcp-5432
+func GenerateStreamSlug(protocol, listen string) string {
+	return "stream_" + GenerateProxySlug(protocol+"-"+listen)
+}
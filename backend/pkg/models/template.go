@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProxyTemplate is a reusable proxy blueprint: the settings shared by similar proxies, such as
+// headers, basic auth, health checks and SSL settings. A proxy is created from it by giving just a
+// domain and target.
+type ProxyTemplate struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Proxy       json.RawMessage `json:"proxy"` // proxy request fields, without domain and targets
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// NewProxyTemplate creates a new ProxyTemplate with timestamps
+func NewProxyTemplate(name, description string, proxy json.RawMessage) *ProxyTemplate {
+	now := time.Now().Format(time.RFC3339)
+	return &ProxyTemplate{
+		Name:        name,
+		Description: description,
+		Proxy:       proxy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// GenerateProxyTemplateSlug generates a readable ID for a proxy template from its name
+func GenerateProxyTemplateSlug(name string) string {
+	return "tpl_" + GenerateProxySlug(name)
+}
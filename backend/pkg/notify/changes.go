@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarat/caddyproxymanager/pkg/events"
+)
+
+// ChangeMessage turns a proxy, redirect or reload event into the message channels subscribed to
+// configuration changes receive. It reports false for other events.
+func ChangeMessage(event events.Event) (Message, bool) {
+	msg := Message{Time: event.Time}
+
+	switch data := event.Data.(type) {
+	case events.ProxyChange:
+		msg.Event = "proxy_" + data.Action
+		msg.ProxyID = data.ProxyID
+		msg.Domain = data.Domain
+		name := data.Domain
+		if name == "" {
+			name = data.ProxyID
+		}
+		msg.Title = fmt.Sprintf("Proxy %s %s", name, data.Action)
+		msg.Text = fmt.Sprintf("Proxy '%s' was %s.", data.ProxyID, data.Action)
+	case events.RedirectChange:
+		msg.Event = "redirect_" + data.Action
+		msg.RedirectID = data.RedirectID
+		msg.Title = fmt.Sprintf("Redirect %s %s", data.RedirectID, data.Action)
+		msg.Text = fmt.Sprintf("Redirect '%s' was %s.", data.RedirectID, data.Action)
+		if len(data.SourceDomains) > 0 {
+			msg.Domain = data.SourceDomains[0]
+			msg.Text += "\nSource domains: " + strings.Join(data.SourceDomains, ", ")
+		}
+	default:
+		if event.Type != events.TypeReload {
+			return Message{}, false
+		}
+		msg.Event = EventConfigReloaded
+		msg.Title = "Caddy configuration reloaded"
+		msg.Text = "The managed configuration was pushed to Caddy again."
+	}
+	return msg, true
+}
@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody caps how much of a failed response is included in the error
+const maxErrorBody = 512
+
+func init() {
+	RegisterChannelType(ChannelType{Name: "webhook", Label: "Webhook", Fields: []Field{
+		{Key: "url", Label: "URL", Type: "password", Required: true},
+		{Key: "secret", Label: "Signing Secret (optional)", Type: "password"},
+	}, Validate: validateURLSetting("url"), Send: sendWebhook})
+	RegisterChannelType(ChannelType{Name: "slack", Label: "Slack", Fields: []Field{
+		{Key: "webhook_url", Label: "Incoming Webhook URL", Type: "password", Required: true},
+	}, Validate: validateURLSetting("webhook_url"), Send: sendSlack})
+	RegisterChannelType(ChannelType{Name: "discord", Label: "Discord", Fields: []Field{
+		{Key: "webhook_url", Label: "Webhook URL", Type: "password", Required: true},
+	}, Validate: validateURLSetting("webhook_url"), Send: sendDiscord})
+	RegisterChannelType(ChannelType{Name: "telegram", Label: "Telegram", Fields: []Field{
+		{Key: "bot_token", Label: "Bot Token", Type: "password", Required: true},
+		{Key: "chat_id", Label: "Chat ID", Type: "text", Required: true},
+	}, Send: sendTelegram})
+	RegisterChannelType(ChannelType{Name: "email", Label: "Email (SMTP)", Fields: []Field{
+		{Key: "smtp_host", Label: "SMTP Host", Type: "text", Required: true},
+		{Key: "smtp_port", Label: "SMTP Port (default 587, 465 for implicit TLS)", Type: "text"},
+		{Key: "username", Label: "Username (optional)", Type: "text"},
+		{Key: "password", Label: "Password (optional)", Type: "password"},
+		{Key: "from", Label: "From", Type: "email", Required: true},
+		{Key: "to", Label: "To (comma separated)", Type: "text", Required: true},
+	}, Validate: validateEmail, Send: sendEmail})
+}
+
+// validateURLSetting returns a validator requiring the setting key to be an http or https URL
+func validateURLSetting(key string) func(settings map[string]string) error {
+	return func(settings map[string]string) error {
+		parsed, err := url.Parse(settings[key])
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL", key)
+		}
+		return nil
+	}
+}
+
+// sendWebhook posts the message as JSON. With a secret, the body's HMAC-SHA256 is sent in the
+// X-CPM-Signature header so receivers can check where it came from.
+func sendWebhook(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	if secret := settings["secret"]; secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		headers["X-CPM-Signature"] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(ctx, transport, settings["url"], body, headers)
+}
+
+// sendSlack posts the message to a Slack incoming webhook
+func sendSlack(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+	body, err := json.Marshal(map[string]string{"text": fmt.Sprintf("*%s*\n%s", msg.Title, msg.Text)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, transport, settings["webhook_url"], body, nil)
+}
+
+// sendDiscord posts the message to a Discord webhook
+func sendDiscord(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+	body, err := json.Marshal(map[string]string{"content": fmt.Sprintf("**%s**\n%s", msg.Title, msg.Text)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, transport, settings["webhook_url"], body, nil)
+}
+
+// sendTelegram sends the message to a chat through the Telegram Bot API
+func sendTelegram(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": settings["chat_id"],
+		"text":    msg.Title + "\n\n" + msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, transport, "https://api.telegram.org/bot"+settings["bot_token"]+"/sendMessage", body, nil)
+}
+
+// postJSON posts body to target, failing on non-2xx responses. Errors leave out the URL, which
+// often holds a token.
+func postJSON(ctx context.Context, transport *Transport, target string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "caddyproxymanager")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := transport.HTTP.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// EventDigest is sent to channels in digest mode with the messages collected since the last one
+const EventDigest = "digest"
+
+// MinDigestInterval is the shortest digest interval a channel can use
+const MinDigestInterval = time.Minute
+
+// digestTick is how often digests are checked for being due
+const digestTick = time.Minute
+
+// maxDigestMessages caps the messages a digest holds; older ones are dropped and counted
+const maxDigestMessages = 200
+
+// digest holds the messages waiting to be sent to one channel
+type digest struct {
+	messages []Message
+	dropped  int       // messages dropped past maxDigestMessages
+	since    time.Time // when the first message was collected
+}
+
+// digestInterval returns how long a channel collects messages before they are sent, 0 for
+// channels that get every message as it happens
+func digestInterval(channel models.NotificationChannel) time.Duration {
+	if channel.Digest == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(channel.Digest)
+	if err != nil {
+		return 0
+	}
+	return interval
+}
+
+// collect adds a message to a channel's digest
+func (n *Notifier) collect(channel models.NotificationChannel, msg Message) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	pending, exists := n.digests[channel.ID]
+	if !exists {
+		pending = &digest{since: time.Now()}
+		n.digests[channel.ID] = pending
+	}
+	if len(pending.messages) == maxDigestMessages {
+		pending.messages = pending.messages[1:]
+		pending.dropped++
+	}
+	pending.messages = append(pending.messages, msg)
+}
+
+// RunDigests sends the digests that are due every minute until ctx is cancelled, then sends the
+// ones still collecting so shutting down loses nothing
+func (n *Notifier) RunDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.FlushDigests(ctx, false)
+		case <-ctx.Done():
+			n.FlushDigests(context.Background(), true)
+			return
+		}
+	}
+}
+
+// FlushDigests sends the digests whose interval has passed, or all of them with force. Digests of
+// channels that were deleted or disabled are dropped; those of channels no longer in digest mode
+// are sent right away.
+func (n *Notifier) FlushDigests(ctx context.Context, force bool) {
+	channels := make(map[string]models.NotificationChannel)
+	for _, channel := range n.source.NotificationChannels() {
+		if channel.Enabled {
+			channels[channel.ID] = channel
+		}
+	}
+
+	type due struct {
+		channel models.NotificationChannel
+		digest  *digest
+	}
+	var send []due
+
+	n.mu.Lock()
+	for id, pending := range n.digests {
+		channel, exists := channels[id]
+		if !exists {
+			delete(n.digests, id)
+			continue
+		}
+		interval := digestInterval(channel)
+		if force || interval == 0 || time.Since(pending.since) >= interval {
+			send = append(send, due{channel: channel, digest: pending})
+			delete(n.digests, id)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, item := range send {
+		if err := n.Send(ctx, item.channel, item.digest.message()); err != nil {
+			log.Printf("Warning: Failed to send digest of %d notifications to channel %s: %v", len(item.digest.messages), item.channel.ID, err)
+		}
+	}
+}
+
+// message summarizes the digest's messages, one line each
+func (d *digest) message() Message {
+	count := len(d.messages) + d.dropped
+	title := fmt.Sprintf("%d notifications since %s", count, d.since.Format("Jan 2 15:04"))
+	if count == 1 {
+		title = fmt.Sprintf("1 notification since %s", d.since.Format("Jan 2 15:04"))
+	}
+
+	var text strings.Builder
+	if d.dropped > 0 {
+		fmt.Fprintf(&text, "%d older notifications were left out\n", d.dropped)
+	}
+	for _, msg := range d.messages {
+		fmt.Fprintf(&text, "- %s %s", msg.Time.Format("15:04"), msg.Title)
+		if msg.Text != "" {
+			fmt.Fprintf(&text, ": %s", strings.ReplaceAll(msg.Text, "\n", " "))
+		}
+		text.WriteString("\n")
+	}
+
+	return Message{Event: EventDigest, Title: title, Text: strings.TrimSuffix(text.String(), "\n"), Time: time.Now()}
+}
@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+)
+
+// fakeChannels is a channel source with fixed channels
+type fakeChannels []models.NotificationChannel
+
+func (f fakeChannels) NotificationChannels() []models.NotificationChannel {
+	return f
+}
+
+// recordingType registers a channel type delivering to a Go channel, with ProxyID set to the name
+// setting of the channel it went to
+func recordingType(t *testing.T) chan Message {
+	t.Helper()
+
+	sent := make(chan Message, 10)
+	RegisterChannelType(ChannelType{Name: "recording", Label: "Recording", Send: func(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+		msg.ProxyID = settings["name"]
+		sent <- msg
+		return nil
+	}})
+	t.Cleanup(func() { delete(channelTypes, "recording") })
+	return sent
+}
+
+func TestDigest(t *testing.T) {
+	sent := recordingType(t)
+	notifier := New(fakeChannels{
+		{ID: "now", Type: "recording", Enabled: true, Settings: map[string]string{"name": "now"}},
+		{ID: "hourly", Type: "recording", Enabled: true, Digest: "1h", Settings: map[string]string{"name": "hourly"}},
+	}, nil)
+
+	notifier.Notify(Message{Event: EventProxyDown, Title: "a.example.com is down", Text: "Request failed\nRunbook: https://wiki"})
+	notifier.Notify(Message{Event: EventProxyUp, Title: "a.example.com recovered"})
+	notifier.Notify(Message{Event: EventProxyDown, Title: "everything is down", Critical: true})
+
+	received := map[string][]string{}
+	for range 4 {
+		select {
+		case msg := <-sent:
+			received[msg.ProxyID] = append(received[msg.ProxyID], msg.Title)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for notifications, got %v", received)
+		}
+	}
+	if len(received["now"]) != 3 || len(received["hourly"]) != 1 || received["hourly"][0] != "everything is down" {
+		t.Fatalf("sent %v, want every alert right away on now and only the critical one on hourly", received)
+	}
+
+	notifier.FlushDigests(context.Background(), false)
+	select {
+	case msg := <-sent:
+		t.Fatalf("digest %q sent before its interval passed", msg.Title)
+	default:
+	}
+
+	notifier.FlushDigests(context.Background(), true)
+	select {
+	case msg := <-sent:
+		lines := strings.Split(msg.Text, "\n")
+		if msg.ProxyID != "hourly" || msg.Event != EventDigest || !strings.HasPrefix(msg.Title, "2 notifications") || len(lines) != 2 ||
+			!strings.HasSuffix(lines[0], "a.example.com is down: Request failed Runbook: https://wiki") || !strings.HasSuffix(lines[1], "a.example.com recovered") {
+			t.Fatalf("digest = %+v, want both non-critical alerts one per line", msg)
+		}
+	default:
+		t.Fatal("FlushDigests(force) sent nothing")
+	}
+
+	notifier.FlushDigests(context.Background(), true)
+	select {
+	case msg := <-sent:
+		t.Fatalf("empty digest %q sent", msg.Title)
+	default:
+	}
+}
+
+func TestNormalizeChannelDigest(t *testing.T) {
+	recordingType(t)
+
+	for digest, valid := range map[string]bool{"": true, "1h": true, " 24h ": true, "30s": false, "daily": false} {
+		channel := models.NotificationChannel{Name: "Ops", Type: "recording", Digest: digest}
+		if err := NormalizeChannel(&channel, nil); (err == nil) != valid {
+			t.Errorf("NormalizeChannel(digest %q) = %v, want valid %t", digest, err, valid)
+		}
+	}
+}
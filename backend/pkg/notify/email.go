@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSMTPPort is the submission port, which upgrades to TLS with STARTTLS
+const defaultSMTPPort = "587"
+
+// implicitTLSPort is the SMTP port that speaks TLS from the start
+const implicitTLSPort = "465"
+
+// validateEmail checks the SMTP port and addresses of an email channel
+func validateEmail(settings map[string]string) error {
+	if port := settings["smtp_port"]; port != "" {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return fmt.Errorf("smtp_port must be between 1 and 65535")
+		}
+	}
+	if _, err := mail.ParseAddress(settings["from"]); err != nil {
+		return fmt.Errorf("invalid from address: %v", err)
+	}
+	if _, err := mail.ParseAddressList(settings["to"]); err != nil {
+		return fmt.Errorf("invalid to addresses: %v", err)
+	}
+	if settings["password"] != "" && settings["username"] == "" {
+		return fmt.Errorf("a password needs a username")
+	}
+	return nil
+}
+
+// sendEmail sends the message over SMTP, upgrading the connection with STARTTLS when the server
+// offers it. Credentials are only sent over TLS, or to localhost.
+func sendEmail(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error {
+	host, port := settings["smtp_host"], settings["smtp_port"]
+	if port == "" {
+		port = defaultSMTPPort
+	}
+	from, err := mail.ParseAddress(settings["from"])
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddressList(settings["to"])
+	if err != nil {
+		return err
+	}
+
+	conn, err := transport.Dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+	if port == implicitTLSPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake failed: %v", err)
+	}
+	defer client.Close()
+
+	if port != implicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS failed: %v", err)
+			}
+		}
+	}
+	if username := settings["username"]; username != "" {
+		if err := client.Auth(smtp.PlainAuth("", username, settings["password"], host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient.Address); err != nil {
+			return fmt.Errorf("recipient %s rejected: %v", recipient.Address, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(emailMessage(from, to, msg)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailMessage formats an alert as a plain text email
+func emailMessage(from *mail.Address, to []*mail.Address, msg Message) []byte {
+	recipients := make([]string, len(to))
+	for i, address := range to {
+		recipients[i] = address.String()
+	}
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Title)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", msg.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,272 @@
+// Package notify delivers alerts, such as a proxy going down or a certificate failing to renew, to
+// notification channels like Slack, Discord, Telegram, email or a generic webhook.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarat/caddyproxymanager/pkg/models"
+	"github.com/sarat/caddyproxymanager/pkg/netguard"
+)
+
+// Events channels can subscribe to
+const (
+	EventProxyDown                = "proxy_down"                 // a proxy's health check started failing
+	EventProxyUp                  = "proxy_up"                   // a proxy recovered
+	EventCertificateRenewalFailed = "certificate_renewal_failed" // a certificate is too close to expiry to still be renewing
+	EventBackupFailed             = "backup_failed"              // a scheduled remote backup couldn't be uploaded
+	EventTest                     = "test"                       // sent on request, whatever events the channel subscribes to
+)
+
+// Configuration change events, delivered only to channels that list them
+const (
+	EventProxyCreated    = "proxy_created"
+	EventProxyUpdated    = "proxy_updated"
+	EventProxyDeleted    = "proxy_deleted"
+	EventRedirectCreated = "redirect_created"
+	EventRedirectUpdated = "redirect_updated"
+	EventRedirectDeleted = "redirect_deleted"
+	EventConfigReloaded  = "config_reloaded"
+)
+
+// Events lists the alert events channels can subscribe to. Channels without events get all of them.
+var Events = []string{EventProxyDown, EventProxyUp, EventCertificateRenewalFailed, EventBackupFailed}
+
+// ChangeEvents lists the configuration change events channels can subscribe to, e.g. so a webhook
+// can trigger a GitOps sync
+var ChangeEvents = []string{
+	EventProxyCreated, EventProxyUpdated, EventProxyDeleted,
+	EventRedirectCreated, EventRedirectUpdated, EventRedirectDeleted,
+	EventConfigReloaded,
+}
+
+// sendTimeout caps how long delivering one alert to one channel may take
+const sendTimeout = 15 * time.Second
+
+// Message is an alert or change delivered to channels
+type Message struct {
+	Event      string    `json:"event"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	ProxyID    string    `json:"proxy_id,omitempty"`
+	RedirectID string    `json:"redirect_id,omitempty"`
+	Domain     string    `json:"domain,omitempty"`
+	Time       time.Time `json:"time"`
+	Critical   bool      `json:"critical,omitempty"` // sent right away, even to channels in digest mode
+}
+
+// Field is a setting a channel type needs
+type Field struct {
+	Key      string `json:"key"`      // key in the channel's settings
+	Label    string `json:"label"`    // shown in the UI and in validation errors
+	Type     string `json:"type"`     // input type: "password" for secrets, which are never returned, "text" or "email"
+	Required bool   `json:"required"` // must be set
+}
+
+// Transport is how senders reach the outside world. Both go through the outbound guard, if any.
+type Transport struct {
+	HTTP *http.Client
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// SendFunc delivers an alert to a channel with the given settings
+type SendFunc func(ctx context.Context, transport *Transport, settings map[string]string, msg Message) error
+
+// ChannelType describes a kind of notification channel and how alerts are delivered to it
+type ChannelType struct {
+	Name     string                                 `json:"name"`  // value of a channel's type
+	Label    string                                 `json:"label"` // display name
+	Fields   []Field                                `json:"fields"`
+	Validate func(settings map[string]string) error `json:"-"` // checks settings beyond required fields, may be nil
+	Send     SendFunc                               `json:"-"`
+}
+
+// channelTypes is the registry of supported channel types, keyed by name
+var channelTypes = map[string]ChannelType{}
+
+// RegisterChannelType adds a channel type to the registry, replacing any with the same name
+func RegisterChannelType(channelType ChannelType) {
+	channelTypes[channelType.Name] = channelType
+}
+
+// ChannelTypes returns the registered channel types sorted by name
+func ChannelTypes() []ChannelType {
+	types := make([]ChannelType, 0, len(channelTypes))
+	for _, channelType := range channelTypes {
+		types = append(types, channelType)
+	}
+	slices.SortFunc(types, func(a, b ChannelType) int { return strings.Compare(a.Name, b.Name) })
+	return types
+}
+
+// NormalizeChannel validates a channel against its type. Secret settings sent empty keep the value
+// they have in previous, so clients don't need to know them.
+func NormalizeChannel(channel *models.NotificationChannel, previous *models.NotificationChannel) error {
+	channel.Name = strings.TrimSpace(channel.Name)
+	if channel.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	channel.Type = strings.ToLower(strings.TrimSpace(channel.Type))
+	channelType, known := channelTypes[channel.Type]
+	if !known {
+		names := make([]string, 0, len(channelTypes))
+		for _, t := range ChannelTypes() {
+			names = append(names, t.Name)
+		}
+		return fmt.Errorf("unsupported channel type %q, expected one of %s", channel.Type, strings.Join(names, ", "))
+	}
+
+	subscribable := slices.Concat(Events, ChangeEvents)
+	var events []string
+	for _, event := range channel.Events {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(subscribable, event) {
+			return fmt.Errorf("unknown event %q, expected one of %s", event, strings.Join(subscribable, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	channel.Events = events
+
+	channel.Digest = strings.TrimSpace(channel.Digest)
+	if channel.Digest != "" {
+		interval, err := time.ParseDuration(channel.Digest)
+		if err != nil || interval < MinDigestInterval {
+			return fmt.Errorf("digest must be a duration of at least %s, e.g. 1h or 24h", MinDigestInterval)
+		}
+	}
+
+	settings := make(map[string]string)
+	for key, value := range channel.Settings {
+		if !slices.ContainsFunc(channelType.Fields, func(f Field) bool { return f.Key == key }) {
+			return fmt.Errorf("unknown %s setting %q", channelType.Label, key)
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			settings[key] = value
+		}
+	}
+	for _, field := range channelType.Fields {
+		if settings[field.Key] == "" && field.Type == "password" && previous != nil && previous.Type == channel.Type {
+			if value := previous.Settings[field.Key]; value != "" {
+				settings[field.Key] = value
+			}
+		}
+		if field.Required && settings[field.Key] == "" {
+			return fmt.Errorf("%s is required for %s channels", field.Label, channelType.Label)
+		}
+	}
+	channel.Settings = settings
+
+	if channelType.Validate != nil {
+		return channelType.Validate(settings)
+	}
+	return nil
+}
+
+// Redacted returns a copy of a channel without its secret settings, for API responses, along with
+// the keys of the secrets that are set
+func Redacted(channel models.NotificationChannel) (models.NotificationChannel, []string) {
+	secretsSet := []string{}
+	settings := maps.Clone(channel.Settings)
+	for _, field := range channelTypes[channel.Type].Fields {
+		if field.Type == "password" {
+			if settings[field.Key] != "" {
+				secretsSet = append(secretsSet, field.Key)
+			}
+			delete(settings, field.Key)
+		}
+	}
+	channel.Settings = settings
+	return channel, secretsSet
+}
+
+// ChannelSource provides the channels alerts are delivered to, with their settings decrypted
+type ChannelSource interface {
+	NotificationChannels() []models.NotificationChannel
+}
+
+// Notifier delivers alerts to the enabled channels subscribed to them
+type Notifier struct {
+	source    ChannelSource
+	transport *Transport
+	mu        sync.Mutex
+	digests   map[string]*digest // channel ID -> messages waiting for the channel's digest
+}
+
+// New creates a notifier delivering to the channels of source. When guard is non-nil, channels
+// can't reach the address ranges it blocks.
+func New(source ChannelSource, guard *netguard.Guard) *Notifier {
+	client := &http.Client{Timeout: sendTimeout}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	dial := dialer.DialContext
+	if guard != nil {
+		client.Transport = guard.Transport()
+		dial = guard.DialContext
+	}
+
+	return &Notifier{
+		source:    source,
+		transport: &Transport{HTTP: client, Dial: dial},
+		digests:   make(map[string]*digest),
+	}
+}
+
+// Notify delivers a message in the background to every enabled channel subscribed to its event.
+// Channels in digest mode collect it for their next digest unless it is critical. Failures are
+// logged.
+func (n *Notifier) Notify(msg Message) {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+
+	for _, channel := range n.source.NotificationChannels() {
+		if !channel.Enabled || !subscribed(channel, msg.Event) {
+			continue
+		}
+		if digestInterval(channel) > 0 && !msg.Critical {
+			n.collect(channel, msg)
+			continue
+		}
+		go func() {
+			if err := n.Send(context.Background(), channel, msg); err != nil {
+				log.Printf("Warning: Failed to send %s notification to channel %s: %v", msg.Event, channel.ID, err)
+			}
+		}()
+	}
+}
+
+// subscribed reports whether a channel wants an event. Channels without events get every alert but
+// no change events, which would flood chat channels set up before they existed.
+func subscribed(channel models.NotificationChannel, event string) bool {
+	if len(channel.Events) == 0 {
+		return !slices.Contains(ChangeEvents, event)
+	}
+	return slices.Contains(channel.Events, event)
+}
+
+// Send delivers an alert to one channel and waits for the result, whether or not the channel is
+// enabled or subscribed to the event
+func (n *Notifier) Send(ctx context.Context, channel models.NotificationChannel, msg Message) error {
+	channelType, known := channelTypes[channel.Type]
+	if !known {
+		return fmt.Errorf("unsupported channel type %q", channel.Type)
+	}
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return channelType.Send(ctx, n.transport, channel.Settings, msg)
+}
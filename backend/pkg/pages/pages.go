@@ -0,0 +1,293 @@
+// Package pages stores the bodies of responses the manager has Caddy serve itself, such as error
+// pages. Bodies are Go templates kept in the data directory, with optional variants per language.
+package pages
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Page kinds
+const (
+	KindError       = "error"        // Served when a request fails, e.g. with a 502 while the upstream is down
+	KindDefaultSite = "default-site" // Served for hostnames no proxy or redirect handles, when enabled
+)
+
+// Kinds lists the supported page kinds
+var Kinds = []string{KindError, KindDefaultSite}
+
+// DefaultLanguage names the variant served when no language variant matches
+const DefaultLanguage = "default"
+
+// maxTemplateSize caps the size of a stored template
+const maxTemplateSize = 256 << 10
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// Caddy placeholders filled in for each request, so one rendered body serves every domain
+const (
+	placeholderDomain     = "{http.request.host}"
+	placeholderTimestamp  = "{time.now.http}"
+	placeholderStatusCode = "{http.error.status_code}"
+	placeholderStatusText = "{http.error.status_text}"
+)
+
+// Data holds the variables available to page templates
+type Data struct {
+	Domain       string // Requested host
+	Timestamp    string // Time of the request
+	SupportEmail string
+	StatusCode   string
+	StatusText   string
+	Language     string // Language of the variant, or "default"
+}
+
+// Template describes a stored page template
+type Template struct {
+	Kind     string    `json:"kind"`
+	Language string    `json:"language"`
+	Size     int64     `json:"size"`
+	Updated  time.Time `json:"updated"`
+	Body     string    `json:"body,omitempty"` // Only set when a single template is requested
+}
+
+// Page is a rendered page variant ready to be served
+type Page struct {
+	Language string // "default" or a language tag such as "de" or "pt-br"
+	Body     string
+}
+
+// Store reads and writes page templates in a directory, one subdirectory per kind
+type Store struct {
+	mu           sync.RWMutex
+	dir          string
+	supportEmail string
+}
+
+// NewStore creates a store keeping templates in dir. supportEmail is available to templates as
+// {{.SupportEmail}}.
+func NewStore(dir, supportEmail string) *Store {
+	return &Store{
+		dir:          dir,
+		supportEmail: supportEmail,
+	}
+}
+
+// ValidKind reports whether kind is a supported page kind
+func ValidKind(kind string) bool {
+	return slices.Contains(Kinds, kind)
+}
+
+// ValidLanguage reports whether language is "default" or a language tag such as "de" or "pt-br"
+func ValidLanguage(language string) bool {
+	return language == DefaultLanguage || languagePattern.MatchString(language)
+}
+
+// List returns the stored templates of every kind, without their bodies
+func (s *Store) List() ([]Template, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var templates []Template
+	for _, kind := range Kinds {
+		files, err := filepath.Glob(filepath.Join(s.dir, kind, "*.html"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			templates = append(templates, Template{
+				Kind:     kind,
+				Language: strings.TrimSuffix(filepath.Base(file), ".html"),
+				Size:     info.Size(),
+				Updated:  info.ModTime(),
+			})
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Kind != templates[j].Kind {
+			return templates[i].Kind < templates[j].Kind
+		}
+		return templates[i].Language < templates[j].Language
+	})
+	return templates, nil
+}
+
+// Get returns a stored template with its body
+func (s *Store) Get(kind, language string) (*Template, error) {
+	file, err := s.file(kind, language)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{
+		Kind:     kind,
+		Language: language,
+		Size:     info.Size(),
+		Updated:  info.ModTime(),
+		Body:     string(body),
+	}, nil
+}
+
+// Save validates a template by rendering it and stores it
+func (s *Store) Save(kind, language, body string) error {
+	file, err := s.file(kind, language)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxTemplateSize {
+		return fmt.Errorf("template is larger than %d KB", maxTemplateSize>>10)
+	}
+	if _, err := s.render(body, language, placeholderStatusCode, placeholderStatusText); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create template directory: %w", err)
+	}
+	if err := os.WriteFile(file, []byte(body), 0644); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a stored template
+func (s *Store) Delete(kind, language string) error {
+	file, err := s.file(kind, language)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return os.Remove(file)
+}
+
+// Render renders every variant of a kind, with the default variant last so language variants are
+// tried first. Templates that fail to render are skipped and reported in the returned errors.
+func (s *Store) Render(kind string) ([]Page, []error) {
+	return s.renderKind(kind, placeholderStatusCode, placeholderStatusText)
+}
+
+// RenderStatus renders every variant of a kind like Render, for pages always served with the same
+// status code, such as the default site
+func (s *Store) RenderStatus(kind string, code int) ([]Page, []error) {
+	return s.renderKind(kind, strconv.Itoa(code), http.StatusText(code))
+}
+
+// renderKind renders every variant of a kind with the given status variables
+func (s *Store) renderKind(kind, statusCode, statusText string) ([]Page, []error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, kind, "*.html"))
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	var pages []Page
+	var errs []error
+	var fallback *Page
+	for _, file := range files {
+		language := strings.TrimSuffix(filepath.Base(file), ".html")
+		if !ValidLanguage(language) {
+			continue
+		}
+
+		body, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		rendered, err := s.render(string(body), language, statusCode, statusText)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", kind, language, err))
+			continue
+		}
+
+		page := Page{Language: language, Body: rendered}
+		if language == DefaultLanguage {
+			fallback = &page
+			continue
+		}
+		pages = append(pages, page)
+	}
+
+	if fallback != nil {
+		pages = append(pages, *fallback)
+	}
+	return pages, errs
+}
+
+// render executes a template, leaving Caddy placeholders for the per-request values
+func (s *Store) render(body, language, statusCode, statusText string) (string, error) {
+	tmpl, err := template.New("page").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, Data{
+		Domain:       placeholderDomain,
+		Timestamp:    placeholderTimestamp,
+		SupportEmail: s.supportEmail,
+		StatusCode:   statusCode,
+		StatusText:   statusText,
+		Language:     language,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// file returns the path of a kind's template in a language
+func (s *Store) file(kind, language string) (string, error) {
+	if !ValidKind(kind) {
+		return "", fmt.Errorf("unknown page kind %q", kind)
+	}
+	if !ValidLanguage(language) {
+		return "", fmt.Errorf("invalid language %q, expected %q or a tag such as \"de\" or \"pt-br\"", language, DefaultLanguage)
+	}
+	return filepath.Join(s.dir, kind, language+".html"), nil
+}
+
+// IsNotExist reports whether err means a template doesn't exist
+func IsNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
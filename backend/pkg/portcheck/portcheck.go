@@ -0,0 +1,142 @@
+// Package portcheck asks an external service whether a port forwarded to the manager's host can be
+// reached from the internet, and explains what usually blocks it.
+package portcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcomes of a reachability check
+const (
+	StatusReachable   = "reachable"
+	StatusUnreachable = "unreachable"
+	StatusUnknown     = "unknown"   // the check service failed or gave no answer
+	StatusUnchecked   = "unchecked" // no check service is configured
+)
+
+const (
+	checkTimeout    = 15 * time.Second
+	maxResponseSize = 64 << 10
+)
+
+// Result is the outcome of a reachability check with hints on what to look at when the port isn't
+// reachable
+type Result struct {
+	Protocol  string    `json:"protocol"`
+	Port      int       `json:"port"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Hints     []string  `json:"hints,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+}
+
+// Checker calls a check service that connects back to the public address. Its URL contains the
+// placeholders {port} and {protocol}, and {host} for a fixed public address; without {host} the
+// service checks the address the request comes from. The service answers with a JSON object
+// holding a boolean "reachable".
+type Checker struct {
+	url        string
+	host       string
+	httpClient *http.Client
+}
+
+// New creates a checker calling checkURL, with host filled in for {host}
+func New(checkURL, host string) (*Checker, error) {
+	parsed, err := url.Parse(checkURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, fmt.Errorf("port check URL must be an http(s) URL, got %q", checkURL)
+	}
+	if !strings.Contains(checkURL, "{port}") {
+		return nil, fmt.Errorf("port check URL must contain {port}, got %q", checkURL)
+	}
+	if strings.Contains(checkURL, "{host}") && host == "" {
+		return nil, fmt.Errorf("port check URL contains {host}, which needs a public host")
+	}
+
+	return &Checker{url: checkURL, host: host, httpClient: &http.Client{Timeout: checkTimeout}}, nil
+}
+
+// Check asks the service whether protocol port is reachable from outside
+func (c *Checker) Check(ctx context.Context, protocol string, port int) Result {
+	result := Result{Protocol: protocol, Port: port, CheckedAt: time.Now()}
+
+	checkURL := strings.NewReplacer(
+		"{host}", url.QueryEscape(c.host),
+		"{port}", strconv.Itoa(port),
+		"{protocol}", url.QueryEscape(protocol),
+	).Replace(c.url)
+	reachable, err := c.query(ctx, checkURL)
+	switch {
+	case err != nil:
+		result.Status = StatusUnknown
+		result.Message = fmt.Sprintf("Port check failed: %v", err)
+	case reachable:
+		result.Status = StatusReachable
+		return result
+	default:
+		result.Status = StatusUnreachable
+		result.Message = fmt.Sprintf("%s port %d doesn't accept connections from outside", strings.ToUpper(protocol), port)
+	}
+
+	result.Hints = Hints(protocol, port)
+	return result
+}
+
+// query calls the service and reads its answer
+func (c *Checker) query(ctx context.Context, checkURL string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("check service answered %s", resp.Status)
+	}
+	var answer struct {
+		Reachable *bool `json:"reachable"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&answer); err != nil || answer.Reachable == nil {
+		return false, fmt.Errorf("check service answer has no reachable field")
+	}
+	return *answer.Reachable, nil
+}
+
+// Unchecked is the result when no check service is configured, with the hints alone
+func Unchecked(protocol string, port int) Result {
+	return Result{
+		Protocol: protocol,
+		Port:     port,
+		Status:   StatusUnchecked,
+		Message:  "Set PORT_CHECK_URL to check whether the port can be reached from outside",
+		Hints:    Hints(protocol, port),
+	}
+}
+
+// Hints lists what commonly keeps a forwarded port from being reached, from the router to the host
+func Hints(protocol string, port int) []string {
+	hints := []string{
+		fmt.Sprintf("Forward %s port %d on your router to this host's LAN address", strings.ToUpper(protocol), port),
+		fmt.Sprintf("Allow the port in the host firewall, e.g. ufw allow %d/%s or firewall-cmd --add-port=%d/%s", port, protocol, port, protocol),
+		fmt.Sprintf("When Caddy runs in a container, publish the port, e.g. -p %d:%d/%s", port, port, protocol),
+		"If the router's WAN address is in 100.64.0.0/10 or differs from your public IP, your ISP uses carrier-grade NAT and ports can't be forwarded; use a VPN or tunnel instead",
+	}
+	if protocol == "udp" {
+		hints = append(hints, "UDP has no handshake, so a check only succeeds when the service behind the port answers")
+	}
+	return hints
+}
@@ -0,0 +1,162 @@
+// Package presets loads application proxy presets from JSON files in the data directory.
+package presets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var presetIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Preset describes recommended proxy settings for a known application
+type Preset struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Website     string        `json:"website,omitempty"`
+	Notes       string        `json:"notes,omitempty"` // Setup tips shown alongside the preset
+	Proxy       ProxyDefaults `json:"proxy"`
+	File        string        `json:"file"` // Source file name within the presets directory
+}
+
+// ProxyDefaults are the proxy fields a preset fills in. The domain and target host are always
+// left to the user.
+type ProxyDefaults struct {
+	TargetScheme              string            `json:"target_scheme,omitempty"` // "http" or "https"
+	TargetPort                int               `json:"target_port,omitempty"`
+	SSLMode                   string            `json:"ssl_mode,omitempty"`
+	UpstreamType              string            `json:"upstream_type,omitempty"`
+	FastCGIRoot               string            `json:"fastcgi_root,omitempty"`
+	GRPC                      bool              `json:"grpc,omitempty"`
+	CustomHeaders             map[string]string `json:"custom_headers,omitempty"`
+	CustomCaddyJSON           string            `json:"custom_caddy_json,omitempty"`
+	HealthCheckEnabled        bool              `json:"health_check_enabled,omitempty"`
+	HealthCheckPath           string            `json:"health_check_path,omitempty"`
+	HealthCheckExpectedStatus int               `json:"health_check_expected_status,omitempty"`
+	BandwidthLimit            int64             `json:"bandwidth_limit,omitempty"`
+	IPExceptionPaths          []string          `json:"ip_exception_paths,omitempty"`
+}
+
+// Store holds the presets loaded at startup
+type Store struct {
+	presets []Preset
+}
+
+// Load reads every *.json file in dir. Each file holds one preset or an array of presets.
+// Invalid presets are skipped and reported in the returned errors, so one bad community file
+// doesn't keep the others from loading. A missing directory yields an empty store.
+func Load(dir string) (*Store, []error) {
+	store := &Store{}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return store, []error{err}
+	}
+	sort.Strings(files)
+
+	var errs []error
+	seen := make(map[string]string)
+	for _, file := range files {
+		presets, err := loadFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(file), err))
+			continue
+		}
+
+		for _, preset := range presets {
+			preset.File = filepath.Base(file)
+			if err := preset.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: preset %q: %w", preset.File, preset.ID, err))
+				continue
+			}
+			if other, exists := seen[preset.ID]; exists {
+				errs = append(errs, fmt.Errorf("%s: preset %q is already defined in %s", preset.File, preset.ID, other))
+				continue
+			}
+			seen[preset.ID] = preset.File
+			store.presets = append(store.presets, preset)
+		}
+	}
+
+	sort.Slice(store.presets, func(i, j int) bool { return store.presets[i].Name < store.presets[j].Name })
+	return store, errs
+}
+
+// loadFile decodes a preset file strictly, rejecting fields that aren't part of the format
+func loadFile(file string) ([]Preset, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var presets []Preset
+	if err := decoder.Decode(&presets); err != nil {
+		return nil, fmt.Errorf("invalid preset JSON: %w", err)
+	}
+	return presets, nil
+}
+
+// Validate checks a preset against the preset format
+func (p Preset) Validate() error {
+	if !presetIDPattern.MatchString(p.ID) {
+		return fmt.Errorf("id must be lowercase letters, digits and dashes")
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	proxy := p.Proxy
+	if !slices.Contains([]string{"", "http", "https"}, proxy.TargetScheme) {
+		return fmt.Errorf("target_scheme must be http or https")
+	}
+	if proxy.TargetPort < 0 || proxy.TargetPort > 65535 {
+		return fmt.Errorf("target_port must be between 1 and 65535")
+	}
+	if !slices.Contains([]string{"", "auto", "none", "custom", "internal"}, proxy.SSLMode) {
+		return fmt.Errorf("unsupported ssl_mode %q", proxy.SSLMode)
+	}
+	if !slices.Contains([]string{"", "http", "fastcgi"}, proxy.UpstreamType) {
+		return fmt.Errorf("unsupported upstream_type %q", proxy.UpstreamType)
+	}
+	if proxy.CustomCaddyJSON != "" && !json.Valid([]byte(proxy.CustomCaddyJSON)) {
+		return fmt.Errorf("custom_caddy_json is not valid JSON")
+	}
+	if proxy.HealthCheckPath != "" && !strings.HasPrefix(proxy.HealthCheckPath, "/") {
+		return fmt.Errorf("health_check_path must start with /")
+	}
+	if proxy.BandwidthLimit < 0 {
+		return fmt.Errorf("bandwidth_limit must not be negative")
+	}
+
+	return nil
+}
+
+// List returns all loaded presets sorted by name
+func (s *Store) List() []Preset {
+	return s.presets
+}
+
+// Get returns the preset with the given ID
+func (s *Store) Get(id string) (Preset, bool) {
+	for _, preset := range s.presets {
+		if preset.ID == id {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
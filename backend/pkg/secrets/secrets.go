@@ -0,0 +1,126 @@
+// Package secrets encrypts sensitive values, such as health check credentials, before they are
+// written to the data directory.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	encryptedPrefix = "enc:v1:"
+	keySize         = 32 // AES-256
+	keyFileName     = "secret.key"
+)
+
+// Box encrypts and decrypts values with AES-GCM
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New creates a box from a 32-byte key
+func New(key []byte) (*Box, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("secret key must be %d bytes, got %d", keySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// LoadKey returns the encryption key from envKey (64 hex characters or base64), or from
+// secret.key in dataDir, generating that file on first use
+func LoadKey(envKey, dataDir string) ([]byte, error) {
+	if envKey = strings.TrimSpace(envKey); envKey != "" {
+		if key, err := hex.DecodeString(envKey); err == nil && len(key) == keySize {
+			return key, nil
+		}
+		if key, err := base64.StdEncoding.DecodeString(envKey); err == nil && len(key) == keySize {
+			return key, nil
+		}
+		return nil, fmt.Errorf("key must be %d bytes encoded as hex or base64", keySize)
+	}
+
+	keyFile := filepath.Join(dataDir, keyFileName)
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("invalid key in %s", keyFile)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", keyFile, err)
+	}
+
+	return key, nil
+}
+
+// Encrypt returns value encrypted and tagged so Decrypt can tell it apart from plain text
+func (b *Box) Encrypt(value string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// IsEncrypted reports whether value was returned by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Decrypt reverses Encrypt. Values that were never encrypted are returned unchanged.
+func (b *Box) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("invalid encrypted value")
+	}
+
+	plain, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value, was the secret key changed? %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,238 @@
+// Package stats aggregates Caddy's JSON access log into per-host traffic statistics.
+package stats
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Retention is how far back statistics are kept
+	Retention = 24 * time.Hour
+	// bucketSize is the resolution of the statistics
+	bucketSize = time.Minute
+	// maxPathsPerBucket bounds the paths counted per host and minute; the rest count as otherPaths
+	maxPathsPerBucket = 200
+	otherPaths        = "(other)"
+	// topPaths is how many paths a summary lists
+	topPaths = 10
+)
+
+// Windows are the time windows statistics can be summarized over
+var Windows = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"24h": 24 * time.Hour,
+}
+
+// Entry is the part of a Caddy access log entry the statistics use
+type Entry struct {
+	Timestamp float64 `json:"ts"` // Unix seconds
+	Request   struct {
+		Host string `json:"host"`
+		URI  string `json:"uri"`
+	} `json:"request"`
+	BytesRead int64 `json:"bytes_read"`
+	Size      int64 `json:"size"`
+	Status    int   `json:"status"`
+}
+
+// bucket counts one host's requests in one minute
+type bucket struct {
+	requests      int64
+	bytesSent     int64
+	bytesReceived int64
+	statuses      map[int]int64
+	paths         map[string]int64
+}
+
+// Aggregator keeps per-minute traffic counts per host for the last Retention
+type Aggregator struct {
+	mu    sync.Mutex
+	hosts map[string]map[int64]*bucket // host -> minute (Unix) -> counts
+}
+
+// NewAggregator creates an empty aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{hosts: make(map[string]map[int64]*bucket)}
+}
+
+// AddLine counts an access log line, ignoring lines that aren't access log entries
+func (a *Aggregator) AddLine(line []byte) {
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Request.Host == "" || entry.Status == 0 {
+		return
+	}
+	a.Add(entry)
+}
+
+// Add counts an access log entry. Entries older than Retention are ignored.
+func (a *Aggregator) Add(entry Entry) {
+	at := time.Unix(0, int64(entry.Timestamp*float64(time.Second)))
+	if time.Since(at) > Retention {
+		return
+	}
+	minute := at.Truncate(bucketSize).Unix()
+	host := normalizeHost(entry.Request.Host)
+	path, _, _ := strings.Cut(entry.Request.URI, "?")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	buckets := a.hosts[host]
+	if buckets == nil {
+		buckets = make(map[int64]*bucket)
+		a.hosts[host] = buckets
+	}
+	b := buckets[minute]
+	if b == nil {
+		b = &bucket{statuses: make(map[int]int64), paths: make(map[string]int64)}
+		buckets[minute] = b
+	}
+
+	b.requests++
+	b.bytesSent += entry.Size
+	b.bytesReceived += entry.BytesRead
+	b.statuses[entry.Status]++
+	if _, counted := b.paths[path]; !counted && len(b.paths) >= maxPathsPerBucket {
+		path = otherPaths
+	}
+	b.paths[path]++
+}
+
+// Prune drops the counts older than Retention
+func (a *Aggregator) Prune() {
+	oldest := time.Now().Add(-Retention).Truncate(bucketSize).Unix()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for host, buckets := range a.hosts {
+		maps.DeleteFunc(buckets, func(minute int64, _ *bucket) bool { return minute < oldest })
+		if len(buckets) == 0 {
+			delete(a.hosts, host)
+		}
+	}
+}
+
+// Summary is the traffic of the hosts of a proxy over a window
+type Summary struct {
+	Window         string           `json:"window"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	Requests       int64            `json:"requests"`
+	RequestsPerSec float64          `json:"requests_per_sec"`
+	BytesSent      int64            `json:"bytes_sent"`
+	BytesReceived  int64            `json:"bytes_received"`
+	BytesPerSec    float64          `json:"bytes_sent_per_sec"`
+	StatusClasses  map[string]int64 `json:"status_classes"` // e.g. "2xx"
+	StatusCodes    map[string]int64 `json:"status_codes"`
+	TopPaths       []PathCount      `json:"top_paths"`
+	Series         []Point          `json:"series"` // one point per minute with traffic
+}
+
+// PathCount is how often a path was requested
+type PathCount struct {
+	Path     string `json:"path"`
+	Requests int64  `json:"requests"`
+}
+
+// Point is the traffic of one minute
+type Point struct {
+	Time      time.Time `json:"time"`
+	Requests  int64     `json:"requests"`
+	BytesSent int64     `json:"bytes_sent"`
+	Errors    int64     `json:"errors"` // 5xx responses
+}
+
+// Summarize adds up the traffic of the hosts matching match over the window ending now
+func (a *Aggregator) Summarize(match func(host string) bool, window string) Summary {
+	length := Windows[window]
+	to := time.Now()
+	from := to.Add(-length)
+	first := from.Truncate(bucketSize).Unix()
+
+	summary := Summary{
+		Window:        window,
+		From:          from,
+		To:            to,
+		StatusClasses: make(map[string]int64),
+		StatusCodes:   make(map[string]int64),
+		TopPaths:      []PathCount{},
+		Series:        []Point{},
+	}
+	paths := make(map[string]int64)
+	series := make(map[int64]*Point)
+
+	a.mu.Lock()
+	for host, buckets := range a.hosts {
+		if !match(host) {
+			continue
+		}
+		for minute, b := range buckets {
+			if minute < first {
+				continue
+			}
+			summary.Requests += b.requests
+			summary.BytesSent += b.bytesSent
+			summary.BytesReceived += b.bytesReceived
+
+			point := series[minute]
+			if point == nil {
+				point = &Point{Time: time.Unix(minute, 0).UTC()}
+				series[minute] = point
+			}
+			point.Requests += b.requests
+			point.BytesSent += b.bytesSent
+
+			for status, count := range b.statuses {
+				summary.StatusCodes[strconv.Itoa(status)] += count
+				summary.StatusClasses[strconv.Itoa(status/100)+"xx"] += count
+				if status >= 500 {
+					point.Errors += count
+				}
+			}
+			for path, count := range b.paths {
+				paths[path] += count
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	seconds := length.Seconds()
+	summary.RequestsPerSec = float64(summary.Requests) / seconds
+	summary.BytesPerSec = float64(summary.BytesSent) / seconds
+
+	for path, count := range paths {
+		summary.TopPaths = append(summary.TopPaths, PathCount{Path: path, Requests: count})
+	}
+	slices.SortFunc(summary.TopPaths, func(x, y PathCount) int {
+		return cmp.Or(cmp.Compare(y.Requests, x.Requests), cmp.Compare(x.Path, y.Path))
+	})
+	if len(summary.TopPaths) > topPaths {
+		summary.TopPaths = summary.TopPaths[:topPaths]
+	}
+
+	for _, point := range series {
+		summary.Series = append(summary.Series, *point)
+	}
+	slices.SortFunc(summary.Series, func(x, y Point) int { return x.Time.Compare(y.Time) })
+
+	return summary
+}
+
+// normalizeHost lowercases a Host header and strips its port
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	pollInterval  = 5 * time.Second
+	maxLineLength = 1 << 20 // longer lines are skipped
+)
+
+// follower reads the lines appended to a log file
+type follower struct {
+	path     string
+	file     *os.File
+	reader   *bufio.Reader
+	offset   int64  // bytes read from the open file
+	partial  []byte // an incomplete last line, finished by the next read
+	skipping bool   // dropping the rest of an overlong line
+}
+
+// Follow reads the access log at path into the aggregator until ctx is done, starting with what
+// the file already holds and picking up new lines every few seconds. A rolled over log is followed
+// from the start of the new file.
+func (a *Aggregator) Follow(ctx context.Context, path string) {
+	f := &follower{path: path}
+	defer f.close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for {
+		if f.file != nil && f.rolledOver() {
+			f.readLines(a.AddLine) // whatever was written before the roll
+			f.close()
+		}
+		if f.file == nil {
+			f.open()
+		}
+		if f.file != nil {
+			f.readLines(a.AddLine)
+		}
+
+		if time.Since(lastPrune) > bucketSize {
+			a.Prune()
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// open opens the log, which may not exist yet
+func (f *follower) open() {
+	file, err := os.Open(f.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: Failed to open access log: %v", err)
+		}
+		return
+	}
+	f.file = file
+	f.reader = bufio.NewReaderSize(file, 64<<10)
+	f.offset = 0
+	f.partial = f.partial[:0]
+	f.skipping = false
+}
+
+// close closes the open log, if any
+func (f *follower) close() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// readLines passes every complete line available to handle
+func (f *follower) readLines(handle func([]byte)) {
+	for {
+		chunk, err := f.reader.ReadSlice('\n')
+		f.offset += int64(len(chunk))
+
+		if !f.skipping {
+			f.partial = append(f.partial, chunk...)
+			if len(f.partial) > maxLineLength {
+				f.partial = f.partial[:0]
+				f.skipping = true
+			}
+		}
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err != nil:
+			return // io.EOF, the partial line is finished by a later read
+		}
+
+		if !f.skipping {
+			handle(f.partial)
+		}
+		f.partial = f.partial[:0]
+		f.skipping = false
+	}
+}
+
+// rolledOver reports whether the log at path is no longer the open file, or was truncated
+func (f *follower) rolledOver() bool {
+	opened, err := f.file.Stat()
+	if err != nil {
+		return true
+	}
+	current, err := os.Stat(f.path)
+	if err != nil {
+		return false // keep the old file until the new one appears
+	}
+	return !os.SameFile(opened, current) || current.Size() < f.offset
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File stores documents as files in a directory and logs as JSON Lines files next to them
+type File struct {
+	mu  sync.Mutex // serializes appends so records never interleave
+	dir string
+}
+
+// NewFile creates a store rooted at dir
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+func (f *File) Init() error {
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return nil
+}
+
+func (f *File) Load(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (f *File) Save(name string, data []byte) error {
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0600)
+}
+
+func (f *File) Version(name string) (string, error) {
+	info, err := os.Stat(filepath.Join(f.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+func (f *File) Append(log string, record []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(f.dir, log), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(record, '\n'))
+	return err
+}
+
+func (f *File) Records(log string) ([][]byte, error) {
+	file, err := os.Open(filepath.Join(f.dir, log))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records [][]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			records = append(records, bytes.Clone(line))
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (f *File) Kind() string {
+	return "file"
+}
@@ -0,0 +1,28 @@
+// Package store persists the manager's own state: users, API tokens, saved searches, proxy
+// metadata and the audit log. The file store keeps it in the data directory; the Postgres store
+// keeps it in a database shared by every replica.
+package store
+
+import "errors"
+
+// ErrNotFound is returned by Load when a document has never been saved
+var ErrNotFound = errors.New("document not found")
+
+// Store persists named JSON documents and append-only logs
+type Store interface {
+	// Init prepares the store, creating directories or tables as needed
+	Init() error
+	// Load returns a document, or ErrNotFound if it doesn't exist
+	Load(name string) ([]byte, error)
+	// Save stores a document, replacing any previous version
+	Save(name string, data []byte) error
+	// Version returns a value that changes whenever a document is saved, or "" if it doesn't exist.
+	// It is cheaper than Load for checking whether another replica changed the document.
+	Version(name string) (string, error)
+	// Append adds a record to the end of a log
+	Append(log string, record []byte) error
+	// Records returns every record of a log, oldest first
+	Records(log string) ([][]byte, error)
+	// Kind names the backend, e.g. "file" or "postgres"
+	Kind() string
+}
@@ -0,0 +1,43 @@
+// Package templating expands references such as {{env "INTERNAL_HOST"}} in proxy target URLs and
+// header values, so one exported config can be applied to hosts with different environments.
+package templating
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"env": func(name string) (string, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	},
+}
+
+// HasTemplate reports whether value contains a template action
+func HasTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// Expand resolves the template actions in value. Values without templates are returned as is.
+func Expand(value string) (string, error) {
+	if !HasTemplate(value) {
+		return value, nil
+	}
+
+	tmpl, err := template.New("value").Funcs(funcs).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", value, err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", fmt.Errorf("failed to expand %q: %v", value, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,346 @@
+// Package update checks GitHub for new releases and replaces the running binary with a newer one.
+package update
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the running version, set at build time with
+// -ldflags "-X github.com/sarat/caddyproxymanager/pkg/update.Version=v1.2.3"
+var Version = "dev"
+
+const (
+	defaultRepository = "iamd3vil/caddyproxymanager"
+	checksumsAsset    = "checksums.txt"
+	maxBinarySize     = 200 << 20 // Refuse downloads larger than this
+)
+
+// ErrNoUpdate is returned by Apply when the running version is already the latest release
+var ErrNoUpdate = errors.New("already running the latest version")
+
+// Release is the subset of a GitHub release the updater needs
+type Release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	HTMLURL     string    `json:"html_url"`
+	Body        string    `json:"body"` // release notes, in Markdown
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// Asset is a file attached to a GitHub release
+type Asset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Info describes the running version and the latest available release
+type Info struct {
+	Current         string    `json:"current"`
+	Latest          string    `json:"latest,omitempty"`
+	ReleaseURL      string    `json:"release_url,omitempty"`
+	ReleaseNotes    string    `json:"release_notes,omitempty"` // Markdown changelog of the latest release
+	PublishedAt     time.Time `json:"published_at,omitzero"`
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at,omitzero"`
+	Error           string    `json:"error,omitempty"` // Set when the release check failed
+}
+
+// Updater looks up releases on GitHub, caching the result, and performs self-updates
+type Updater struct {
+	mu         sync.Mutex
+	repository string
+	cacheTTL   time.Duration
+	httpClient *http.Client
+	latest     *Release
+	checkedAt  time.Time
+	applying   bool
+}
+
+// NewUpdater creates an updater for repository ("owner/name", empty for the default) that caches
+// release lookups for cacheTTL
+func NewUpdater(repository string, cacheTTL time.Duration) *Updater {
+	if repository == "" {
+		repository = defaultRepository
+	}
+
+	return &Updater{
+		repository: repository,
+		cacheTTL:   cacheTTL,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Check returns the running version and the latest release, querying GitHub at most once per
+// cache TTL unless force is set
+func (u *Updater) Check(ctx context.Context, force bool) Info {
+	info := Info{Current: Version}
+
+	release, checkedAt, err := u.latestRelease(ctx, force)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Latest = release.TagName
+	info.ReleaseURL = release.HTMLURL
+	info.ReleaseNotes = release.Body
+	info.PublishedAt = release.PublishedAt
+	info.CheckedAt = checkedAt
+	info.UpdateAvailable = IsNewer(release.TagName, Version)
+	return info
+}
+
+// latestRelease returns the cached release, fetching it when the cache is stale
+func (u *Updater) latestRelease(ctx context.Context, force bool) (*Release, time.Time, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !force && u.latest != nil && time.Since(u.checkedAt) < u.cacheTTL {
+		return u.latest, u.checkedAt, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", u.repository)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to query releases: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("release lookup returned status %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, time.Time{}, fmt.Errorf("invalid release response: %w", err)
+	}
+
+	u.latest = &release
+	u.checkedAt = time.Now()
+	return u.latest, u.checkedAt, nil
+}
+
+// AssetName is the release asset holding the binary for the running platform
+func AssetName() string {
+	return fmt.Sprintf("caddyproxymanager_%s_%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// Apply downloads the latest release binary for this platform, verifies it against the release's
+// checksums.txt and replaces the running executable. The caller is responsible for restarting.
+// Returns the version that was installed.
+func (u *Updater) Apply(ctx context.Context) (string, error) {
+	u.mu.Lock()
+	if u.applying {
+		u.mu.Unlock()
+		return "", fmt.Errorf("an update is already in progress")
+	}
+	u.applying = true
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		u.applying = false
+		u.mu.Unlock()
+	}()
+
+	release, _, err := u.latestRelease(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	if !IsNewer(release.TagName, Version) {
+		return "", ErrNoUpdate
+	}
+
+	binaryAsset, ok := release.asset(AssetName())
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s binary", release.TagName, AssetName())
+	}
+	checksumAsset, ok := release.asset(checksumsAsset)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s, refusing to install an unverified binary", release.TagName, checksumsAsset)
+	}
+
+	checksums, err := u.download(ctx, checksumAsset.BrowserDownloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download checksums: %w", err)
+	}
+	expected, err := findChecksum(checksums, binaryAsset.Name)
+	if err != nil {
+		return "", err
+	}
+
+	binary, err := u.download(ctx, binaryAsset.BrowserDownloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download binary: %w", err)
+	}
+
+	sum := sha256.Sum256(binary)
+	if hex.EncodeToString(sum[:]) != expected {
+		return "", fmt.Errorf("checksum mismatch for %s", binaryAsset.Name)
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		return "", err
+	}
+
+	return release.TagName, nil
+}
+
+// asset finds a release asset by name
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// download fetches a release asset into memory
+func (u *Updater) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBinarySize {
+		return nil, fmt.Errorf("download exceeds %d bytes", maxBinarySize)
+	}
+	return data, nil
+}
+
+// findChecksum looks up a file's SHA-256 in sha256sum output ("<hex>  <name>" per line)
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(checksums)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// replaceExecutable atomically swaps the running executable for binary. The new file is written
+// next to the old one so the final rename stays on one filesystem.
+func replaceExecutable(binary []byte) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	stat, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".update-*")
+	if err != nil {
+		return fmt.Errorf("executable directory is not writable: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		return fmt.Errorf("failed to replace executable: %w", err)
+	}
+	return nil
+}
+
+// IsNewer reports whether release is a newer semantic version than current. Development builds
+// are never considered outdated.
+func IsNewer(release, current string) bool {
+	releaseParts, ok := parseVersion(release)
+	if !ok {
+		return false
+	}
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for i := range releaseParts {
+		if releaseParts[i] != currentParts[i] {
+			return releaseParts[i] > currentParts[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses "v1.2.3" into its numeric parts, ignoring any pre-release suffix
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
@@ -1,8 +1,17 @@
+import type { User } from "./auth";
+
 export interface Proxy {
   id: string;
   domain: string;
+  display_domain?: string; // Unicode form of an internationalized (punycode) domain
   target_url: string;
-  ssl_mode: string;
+  target_urls?: string[];
+  lb_policy?: string;
+  isolated?: boolean;
+  isolated_listen?: string[];
+  tags?: string[]; // lower-case labels for grouping, e.g. by project or environment
+  notes?: string;
+  ssl_mode: string; // "auto", "none", "internal", "custom" or "existing"
   challenge_type?: string;
   dns_provider?: string;
   dns_credentials?: Record<string, string>;
@@ -10,14 +19,234 @@ export interface Proxy {
   basic_auth?: { enabled: boolean; username: string; password: string } | null;
   custom_caddy_json?: string;
   health_check_enabled?: boolean;
+  health_check_type?: "http" | "tcp"; // tcp only opens a connection to the target
   health_check_interval?: string;
   health_check_path?: string;
   health_check_expected_status?: number;
+  health_check_headers?: Record<string, string>;
+  health_check_user_agent?: string;
+  health_check_method?: "GET" | "HEAD" | "POST";
+  health_check_request_body?: string; // sent with POST checks
+  health_check_status_range?: string; // e.g. "200-299,304"; overrides health_check_expected_status
+  health_check_expected_body?: string;
+  health_check_body_regex?: boolean;
+  health_check_via_caddy?: boolean; // check the public URL through Caddy instead of the target
+  health_check_paused?: boolean; // skip the check's requests, keeping its last status
+  access_log?: "" | "all" | "errors"; // requests written to the proxy's access log, none when empty
   allowed_ips?: string[];
   blocked_ips?: string[];
+  ip_lists?: IPListSource[]; // managed with the ip-lists methods, not saved with the proxy
+  access_list_ids?: string[];
+  path_rules?: PathRule[];
+  upstream_sni?: string; // TLS server name for https targets, the target's host when empty
+  preserve_host?: boolean; // send the client's Host header instead of the target's host
+  links?: ProxyLinks; // dashboard, runbook and repository URLs
+  timeouts?: ProxyTimeouts;
+  upstream_health?: UpstreamHealth;
+  flush_interval?: string; // "-1s" streams responses immediately, e.g. for SSE
+  max_body_size?: number; // bytes, 0 = unlimited
+  websocket?: WebSocketSettings;
+  waf?: WAFSettings;
   status?: string;
   created_at: string;
   updated_at: string;
+  last_applied_at?: string; // when a user last created or updated it
+  applied_by?: string; // username, or "deploy-hook"
+  response_time_ms?: number; // latency of the last health check
+  avg_response_time_ms?: number; // rolling average of recent health checks
+}
+
+// Coraza WAF settings; needs Caddy built with github.com/corazawaf/coraza-caddy
+export interface WAFSettings {
+  enabled: boolean;
+  rule_set?: "owasp_crs" | "recommended"; // defaults to owasp_crs
+  paranoia_level?: number; // 1-4, defaults to 1
+  detection_only?: boolean; // log matches without blocking
+}
+
+// WebSocket tuning; with health checks enabled the target is probed for upgrade support
+export interface WebSocketSettings {
+  enabled: boolean;
+  read_buffer_size?: number; // bytes
+  write_buffer_size?: number; // bytes
+  flush_interval?: string; // e.g. "100ms", "-1s" flushes immediately
+  path?: string; // endpoint probed for upgrades, defaults to "/"
+}
+
+// A request the WAF matched rules for
+export interface WAFViolation {
+  proxy_id: string;
+  time: string;
+  transaction_id: string;
+  client_ip: string;
+  method: string;
+  uri: string;
+  status: number;
+  blocked: boolean;
+  rules: { id: number; message: string; severity: number; data?: string }[];
+}
+
+// Shared IP rules and basic auth users that proxies reference by ID
+export interface AccessList {
+  id: string;
+  name: string;
+  allowed_ips: string[];
+  blocked_ips: string[];
+  users: { username: string }[];
+  proxies: string[]; // IDs of the proxies using the list
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AccessListInput {
+  name: string;
+  allowed_ips?: string[];
+  blocked_ips?: string[];
+  users?: { username: string; password?: string }[]; // leave password out to keep a user's current one
+}
+
+// Proxy fields a template fills in; the domain and targets are given when it is used
+export type ProxyTemplateSettings = Omit<Partial<Proxy>, "id" | "domain" | "target_url" | "target_urls" | "backup_target_url" | "status" | "created_at" | "updated_at">;
+
+// Reusable proxy settings, instantiated with just a domain and target
+export interface ProxyTemplate {
+  id: string;
+  name: string;
+  description?: string;
+  proxy: ProxyTemplateSettings;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ProxyTemplateInput {
+  name: string;
+  description?: string;
+  proxy: ProxyTemplateSettings;
+}
+
+export interface RemoteBackups {
+  target: "s3" | "webdav";
+  interval: string; // "0s" when backups are only uploaded on demand
+  keep: number;
+  backups: { name: string; size: number; modified: string }[]; // newest first
+}
+
+export type NotificationEvent = "proxy_down" | "proxy_up" | "certificate_renewal_failed" | "backup_failed";
+
+// Configuration change events, only delivered to channels that list them
+export type NotificationChangeEvent =
+  | "proxy_created"
+  | "proxy_updated"
+  | "proxy_deleted"
+  | "redirect_created"
+  | "redirect_updated"
+  | "redirect_deleted"
+  | "config_reloaded";
+
+export interface NotificationChannelType {
+  name: string;
+  label: string;
+  fields: { key: string; label: string; type: "password" | "text" | "email"; required: boolean }[];
+}
+
+export interface NotificationChannel {
+  id: string;
+  name: string;
+  type: string;
+  enabled: boolean;
+  events: (NotificationEvent | NotificationChangeEvent)[]; // every alert when empty
+  settings: Record<string, string>; // without secrets
+  secrets_set: string[]; // keys of the secret settings that are set
+  digest?: string; // e.g. "1h": non-critical alerts are collected into a summary sent this often
+  created_at: string;
+  updated_at: string;
+}
+
+export interface NotificationChannelInput {
+  name: string;
+  type: string;
+  enabled?: boolean;
+  events?: (NotificationEvent | NotificationChangeEvent)[];
+  settings: Record<string, string>; // leave a secret empty to keep its current value
+  digest?: string; // at least "1m"; empty sends every alert as it happens
+}
+
+// How long Caddy waits on a proxy's upstream, as durations such as "30s"
+export interface ProxyTimeouts {
+  dial?: string;
+  read?: string;
+  write?: string;
+}
+
+// Caddy's own health checks, which stop it sending traffic to dead upstreams
+export interface UpstreamHealth {
+  active: boolean; // probes health_check_path every health_check_interval
+  passive: boolean; // counts failed requests
+  max_fails?: number;
+  fail_duration?: string;
+  unhealthy_status?: number[];
+}
+
+// Where a proxy is documented and watched, shown to on-call operators
+export interface ProxyLinks {
+  dashboard?: string;
+  runbook?: string;
+  repository?: string;
+}
+
+// HTTP versions a Caddy server accepts from clients, HTTP/1.1 is always on
+export interface ServerProtocols {
+  h2: boolean;
+  h2c: boolean; // cleartext HTTP/2
+  h3: boolean;
+}
+
+export interface ServerSettings {
+  default: ServerProtocols;
+  servers?: Record<string, ServerProtocols>; // overrides by server name
+}
+
+// Where Caddy keeps certificates; redis and consul need Caddy built with their storage module
+export interface StorageSettings {
+  module: "file_system" | "redis" | "consul";
+  root?: string; // file_system, Caddy's default directory when empty
+  addresses?: string[]; // host:port, exactly one for consul
+  db?: number; // redis
+  username?: string; // redis
+  password?: string; // redis, write only
+  token?: string; // consul, write only
+  prefix?: string;
+  tls?: boolean;
+  secret_set?: boolean; // a password or token is configured
+}
+
+// How Caddy answers requests for hostnames no proxy or redirect serves; the page comes from the
+// default-site page templates
+export interface DefaultSite {
+  enabled: boolean;
+  mode: "page" | "abort"; // abort closes the connection without a response, like nginx's 444
+  status_code?: number; // status of the page, 404 when unset
+}
+
+// Sends requests under a path prefix to another upstream than the proxy's target
+export interface PathRule {
+  path: string; // e.g. "/api", matching /api and /api/*
+  target_url: string;
+  strip_prefix?: boolean;
+  custom_headers?: Record<string, string>;
+}
+
+// An IP list imported into a proxy's allow or block list
+export interface IPListSource {
+  id: string;
+  name: string;
+  list: "allowed" | "blocked";
+  url?: string; // empty for uploaded lists
+  refresh_interval?: string;
+  entries: number;
+  updated_at: string;
+  last_fetched_at: string;
+  last_error?: string;
 }
 
 export interface Redirect {
@@ -26,22 +255,351 @@ export interface Redirect {
   destination_url: string;
   redirect_code: number;
   preserve_path: boolean;
+  tags?: string[];
+  notes?: string;
   status?: string;
   created_at: string;
   updated_at: string;
+  last_applied_at?: string; // when a user last created or updated it
+  applied_by?: string; // username
+}
+
+export interface DNSCredentialField {
+  key: string;
+  label: string;
+  type: string;
+  required: boolean;
+  env_var?: string;
+}
+
+export interface DNSProvider {
+  name: string;
+  label: string;
+  module: string;
+  fields: DNSCredentialField[];
+}
+
+export interface Certificate {
+  domain: string;
+  issuer?: string;
+  issuer_key?: string;
+  sans?: string[];
+  not_before?: string;
+  not_after?: string;
+  days_to_expiry: number;
+  renewal_status: "ok" | "renewal_due" | "overdue" | "expired" | "missing";
+  managed: boolean;
+}
+
+export interface CustomCertificate {
+  subject: string;
+  issuer: string;
+  sans: string[];
+  not_before: string;
+  not_after: string;
+}
+
+// Fragments of Caddy's JSON config, shown as Caddy returns them
+export interface TargetConnectivity {
+  target: string;
+  dial: string;
+  manager: { reachable: boolean; error?: string };
+  caddy?: { reachable: boolean; status?: number; error?: string };
+  verdict: "reachable" | "caddy_cannot_reach" | "manager_cannot_reach" | "unreachable" | "inconclusive";
+  message: string;
+}
+
+// A proxy's traffic over a window, aggregated from Caddy's access log
+export interface ProxyStats {
+  window: "5m" | "1h" | "6h" | "24h";
+  from: string;
+  to: string;
+  requests: number;
+  requests_per_sec: number;
+  bytes_sent: number;
+  bytes_received: number;
+  bytes_sent_per_sec: number;
+  status_classes: Record<string, number>; // e.g. "2xx"
+  status_codes: Record<string, number>;
+  top_paths: { path: string; requests: number }[];
+  series: { time: string; requests: number; bytes_sent: number; errors: number }[]; // per minute, errors are 5xx
 }
 
+// Kubernetes Services offered as upstreams and the Ingress hosts routed to them, with the sync
+// result of annotated Services
+export interface KubernetesServices {
+  services: {
+    namespace: string;
+    name: string;
+    type: string;
+    cluster_ip?: string;
+    ports: { name?: string; port: number; protocol: string }[];
+    targets: string[];
+    domain?: string; // from the caddyproxymanager.io/domain annotation
+    target?: string;
+    ssl_mode?: string;
+    health_check_path?: string;
+    error?: string;
+  }[];
+  ingresses: {
+    namespace: string;
+    name: string;
+    class?: string;
+    rules: {
+      host?: string; // empty for the default backend
+      path?: string;
+      tls: boolean;
+      service: string;
+      port: string; // name or number
+      target?: string;
+      error?: string;
+    }[];
+  }[];
+  sync_status?: Record<string, string>; // by namespace/name: created, updated, in_sync, skipped: ... or error: ...
+  sync_enabled: boolean;
+  checked_at?: string;
+  error?: string;
+  ingress_error?: string; // e.g. when the service account may not list Ingresses
+}
+
+// Optional capabilities of the deployment, for showing only what is available
+export interface Features {
+  version: string;
+  features: {
+    auth: boolean;
+    session_store: "file" | "redis" | "postgres";
+    state_store: "file" | "postgres";
+    high_availability: boolean;
+    self_update: boolean;
+    config_self_heal: boolean;
+    metrics_push: boolean;
+    health_hook: boolean;
+    outbound_guard: boolean;
+    connectivity_test: boolean;
+    traffic_stats: boolean;
+    kubernetes_discovery: boolean;
+    id_strategy: "slug" | "timestamp";
+  };
+  caddy: { in_use?: string[]; error?: string }; // optional modules the running config uses
+  notifications: { channels: number; enabled: number };
+}
+
+export interface GeneratedConfig {
+  proxy_id: string;
+  domain: string;
+  servers: Record<string, unknown>;
+  tls_automation_policies?: unknown[];
+  tls_certificates?: unknown[];
+  pki_certificate_authorities?: Record<string, unknown>;
+  logs?: Record<string, unknown>;
+}
+
+export interface ProxyPreview {
+  dry_run: true;
+  valid: boolean;
+  errors: string[];
+  proxy: Proxy;
+  generated: GeneratedConfig | null;
+}
+
+export interface APIToken {
+  id: string;
+  name: string;
+  user_id: string;
+  prefix: string;
+  scopes: ("read" | "write")[];
+  proxy_ids?: string[]; // limits the token to these proxies
+  tags?: string[]; // limits the token to proxies with any of these tags
+  created: string;
+  expires?: string;
+  last_used?: string;
+}
+
+// Query parameters of the proxy list, which saved searches store
+export interface ProxyFilters {
+  q?: string; // substring of the ID, domain or targets
+  status?: string; // comma-separated health statuses, e.g. "Unhealthy,Pending"
+  ssl_mode?: string;
+  tag?: string; // comma-separated tags a proxy must all carry
+  sort?: string; // e.g. "domain", or "-last_applied_at" for descending order
+  fields?: string;
+  per_page?: string;
+  limit?: string; // alias of per_page
+  page?: string;
+}
+
+export interface RedirectFilters {
+  q?: string; // substring of the ID, a source domain or the destination
+  status?: string;
+  tag?: string; // comma-separated tags a redirect must all carry
+  sort?: string; // id, domain, destination_url, redirect_code, created_at, updated_at or last_applied_at
+  fields?: string;
+  per_page?: string;
+  page?: string;
+}
+
+// A named set of proxy list filters; shared ones are listed for every user
+export interface SavedSearch {
+  id: string;
+  name: string;
+  user_id: string;
+  filters: ProxyFilters;
+  shared: boolean;
+  created: string;
+  updated: string;
+}
+
+export interface CSVImportRow {
+  row: number;
+  domain: string;
+  valid: boolean;
+  imported: boolean;
+  id?: string;
+  error?: string;
+  code?: string;
+  warnings?: string[];
+}
+
+// A proxy or redirect renamed from a timestamp ID
+export interface StreamProxy {
+  id: string;
+  name: string;
+  protocol: "tcp" | "udp";
+  listen: string;
+  upstreams: string[];
+  status: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface StreamInput {
+  name?: string;
+  protocol?: "tcp" | "udp";
+  listen: string;
+  upstreams: string[];
+}
+
+export interface PortForwardInput {
+  name?: string;
+  protocol?: "tcp" | "udp";
+  external_port: number;
+  target: string; // internal host:port
+}
+
+export interface Reachability {
+  protocol: "tcp" | "udp";
+  port: number;
+  status: "reachable" | "unreachable" | "unknown" | "unchecked";
+  message?: string;
+  hints?: string[]; // router, firewall and NAT settings to check
+  checked_at?: string;
+}
+
+export interface IDRename {
+  kind: "proxy" | "redirect";
+  old_id: string;
+  new_id: string;
+}
+
+export interface CSVImportResponse {
+  dry_run: boolean;
+  type: "proxies" | "redirects";
+  valid: boolean;
+  invalid?: number;
+  imported?: number;
+  results: CSVImportRow[];
+}
+
+export type ServerEvent =
+  | {
+      type: "health";
+      time: string;
+      data: {
+        proxy_id: string;
+        domain: string;
+        old_status: string;
+        new_status: string;
+        message?: string;
+        runbook?: string;
+        dashboard?: string;
+      };
+    }
+  | {
+      type: "proxy";
+      time: string;
+      data: { action: "created" | "updated" | "deleted"; proxy_id: string; domain?: string };
+    }
+  | { type: "caddy"; time: string; data: { reachable: boolean; error?: string } }
+  | { type: "security"; time: string; data: SecurityFinding }
+  | { type: "config"; time: string; data: { routes: number; error?: string } };
+
+export interface SecurityFinding {
+  kind: "failed_logins" | "new_ip_login" | "mass_deletion";
+  severity: "warning" | "critical";
+  ip_address?: string;
+  username?: string;
+  count: number;
+  first: string;
+  last: string;
+  details: string;
+}
+
+export interface UsageReport {
+  broken_redirects: {
+    id: string;
+    source_domains: string[];
+    destination_url: string;
+    status_code?: number;
+    error?: string;
+  }[];
+  redirects_checked: boolean;
+  unhealthy_proxies: {
+    id: string;
+    domain: string;
+    message: string;
+    last_checked: string;
+    down_dependencies?: string[];
+  }[];
+  // Empty unless the server collects traffic statistics
+  idle_proxies: { id: string; domain: string; created_at: string }[];
+  unused_access_lists: { id: string; name: string; created_at: string }[];
+  unused_acme_dns_accounts: { domain: string; registered_at: string }[];
+  summary: Record<string, number>;
+  unavailable: string[];
+  generated_at: string;
+}
+
+export interface PageTemplate {
+  kind: "error" | "default-site";
+  language: string;
+  size: number;
+  updated: string;
+  body?: string;
+}
+
+// Codes returned with domain validation errors
+export type DomainErrorCode = "required" | "url" | "invalid_port" | "port_not_allowed" | "invalid_hostname";
+
 export interface ApiResponse<T> {
   data?: T;
   error?: string;
+  code?: DomainErrorCode; // Set when a domain was rejected
 }
 
-export interface ProxiesResponse {
+// Totals set on list responses when page or per_page is given
+interface Pagination {
+  page?: number;
+  per_page?: number;
+  total?: number;
+  total_pages?: number;
+}
+
+export interface ProxiesResponse extends Pagination {
   proxies: Proxy[];
   count: number;
 }
 
-export interface RedirectsResponse {
+export interface RedirectsResponse extends Pagination {
   redirects: Redirect[];
   count: number;
 }
@@ -52,6 +610,7 @@ export interface StatusResponse {
   upstreams?: any;
   error?: string;
   last_checked: string;
+  websockets?: Record<string, boolean>; // proxy ID to whether its target accepted the last upgrade probe
 }
 
 class ApiClient {
@@ -94,7 +653,7 @@ class ApiClient {
         const errorText = await response.text();
         try {
           const errorJson = JSON.parse(errorText);
-          return { error: errorJson.error || errorText };
+          return { error: errorJson.error || errorText, code: errorJson.code };
         } catch {
           return { error: errorText };
         }
@@ -111,13 +670,20 @@ class ApiClient {
     return this.request("/api/health");
   }
 
-  async getProxies(): Promise<ApiResponse<ProxiesResponse>> {
-    return this.request("/api/proxies");
+  async getProxies(filters?: ProxyFilters): Promise<ApiResponse<ProxiesResponse>> {
+    const query = new URLSearchParams(filters as Record<string, string>).toString();
+    return this.request(`/api/proxies${query ? `?${query}` : ""}`);
   }
 
   async createProxy(proxy: {
     domain: string;
     target_url: string;
+    target_urls?: string[];
+    lb_policy?: string;
+    isolated?: boolean;
+    isolated_listen?: string[];
+    tags?: string[];
+    notes?: string;
     ssl_mode?: string;
     challenge_type?: string;
     dns_provider?: string;
@@ -126,11 +692,33 @@ class ApiClient {
     basic_auth?: { enabled: boolean; username: string; password: string } | null;
     custom_caddy_json?: string;
     health_check_enabled?: boolean;
+    health_check_type?: "http" | "tcp";
     health_check_interval?: string;
     health_check_path?: string;
     health_check_expected_status?: number;
+    health_check_headers?: Record<string, string>;
+    health_check_user_agent?: string;
+    health_check_method?: "GET" | "HEAD" | "POST";
+    health_check_request_body?: string;
+    health_check_status_range?: string;
+    health_check_expected_body?: string;
+    health_check_body_regex?: boolean;
+    health_check_via_caddy?: boolean;
+    health_check_paused?: boolean;
+    access_log?: "" | "all" | "errors";
     allowed_ips?: string[];
     blocked_ips?: string[];
+    access_list_ids?: string[];
+    path_rules?: PathRule[];
+    upstream_sni?: string;
+    preserve_host?: boolean;
+    links?: ProxyLinks;
+    timeouts?: ProxyTimeouts;
+    upstream_health?: UpstreamHealth;
+    flush_interval?: string;
+    max_body_size?: number;
+    websocket?: WebSocketSettings;
+    waf?: WAFSettings;
   }): Promise<ApiResponse<Proxy>> {
     return this.request("/api/proxies", {
       method: "POST",
@@ -143,6 +731,12 @@ class ApiClient {
     proxy: {
       domain: string;
       target_url: string;
+      target_urls?: string[];
+      lb_policy?: string;
+      isolated?: boolean;
+      isolated_listen?: string[];
+      tags?: string[];
+      notes?: string;
       ssl_mode?: string;
       challenge_type?: string;
       dns_provider?: string;
@@ -151,11 +745,33 @@ class ApiClient {
       basic_auth?: { enabled: boolean; username: string; password: string } | null;
       custom_caddy_json?: string;
       health_check_enabled?: boolean;
+      health_check_type?: "http" | "tcp";
       health_check_interval?: string;
       health_check_path?: string;
       health_check_expected_status?: number;
+      health_check_headers?: Record<string, string>;
+      health_check_user_agent?: string;
+      health_check_method?: "GET" | "HEAD" | "POST";
+      health_check_request_body?: string;
+      health_check_status_range?: string;
+      health_check_expected_body?: string;
+      health_check_body_regex?: boolean;
+      health_check_via_caddy?: boolean;
+      health_check_paused?: boolean;
+      access_log?: "" | "all" | "errors";
       allowed_ips?: string[];
       blocked_ips?: string[];
+      access_list_ids?: string[];
+      path_rules?: PathRule[];
+      upstream_sni?: string;
+      preserve_host?: boolean;
+      links?: ProxyLinks;
+      timeouts?: ProxyTimeouts;
+      upstream_health?: UpstreamHealth;
+      flush_interval?: string;
+      max_body_size?: number;
+      websocket?: WebSocketSettings;
+      waf?: WAFSettings;
     },
   ): Promise<ApiResponse<Proxy>> {
     return this.request(`/api/proxies/${id}`, {
@@ -164,6 +780,26 @@ class ApiClient {
     });
   }
 
+  // Applies a JSON merge patch: only the given fields change, and null resets a field to its default
+  async patchProxy(id: string, patch: Partial<Parameters<ApiClient["updateProxy"]>[1]> | Record<string, unknown>): Promise<ApiResponse<Proxy>> {
+    return this.request(`/api/proxies/${id}`, {
+      method: "PATCH",
+      headers: { "Content-Type": "application/merge-patch+json" },
+      body: JSON.stringify(patch),
+    });
+  }
+
+  // Returns the config creating (or, with an id, updating) the proxy would generate without applying it
+  async previewProxy(
+    proxy: Parameters<ApiClient["createProxy"]>[0],
+    id?: string,
+  ): Promise<ApiResponse<ProxyPreview>> {
+    return this.request(id ? `/api/proxies/${id}?dry_run=true` : "/api/proxies?dry_run=true", {
+      method: id ? "PUT" : "POST",
+      body: JSON.stringify(proxy),
+    });
+  }
+
   async deleteProxy(id: string): Promise<ApiResponse<{ message: string }>> {
     return this.request(`/api/proxies/${id}`, {
       method: "DELETE",
@@ -176,6 +812,223 @@ class ApiClient {
     return this.request(`/api/proxies/${id}/status`);
   }
 
+  // Tests whether the manager and Caddy can each reach the proxy's targets
+  async testProxyConnectivity(id: string): Promise<ApiResponse<{ proxy_id: string; targets: TargetConnectivity[] }>> {
+    return this.request(`/api/proxies/${id}/connectivity`, {
+      method: "POST",
+    });
+  }
+
+  async getProxyStats(id: string, window: ProxyStats["window"] = "1h"): Promise<ApiResponse<{ proxy_id: string; stats: ProxyStats }>> {
+    return this.request(`/api/proxies/${id}/stats?window=${window}`);
+  }
+
+  async getKubernetesServices(): Promise<ApiResponse<KubernetesServices>> {
+    return this.request("/api/discovery/kubernetes");
+  }
+
+  async getProxyGenerated(id: string): Promise<ApiResponse<GeneratedConfig>> {
+    return this.request(`/api/proxies/${id}/generated`);
+  }
+
+  async getIPLists(id: string): Promise<ApiResponse<IPListSource[]>> {
+    return this.request(`/api/proxies/${id}/ip-lists`);
+  }
+
+  // Imports a list from a URL, refreshed every refresh_interval, or from uploaded text in content
+  async importIPList(
+    id: string,
+    list: { name?: string; list: "allowed" | "blocked"; url?: string; refresh_interval?: string; content?: string },
+  ): Promise<ApiResponse<IPListSource>> {
+    return this.request(`/api/proxies/${id}/ip-lists`, {
+      method: "POST",
+      body: JSON.stringify(list),
+    });
+  }
+
+  async refreshIPList(id: string, listId: string): Promise<ApiResponse<{ ip_list: IPListSource; changed: boolean }>> {
+    return this.request(`/api/proxies/${id}/ip-lists/${listId}/refresh`, { method: "POST" });
+  }
+
+  async deleteIPList(id: string, listId: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/proxies/${id}/ip-lists/${listId}`, { method: "DELETE" });
+  }
+
+  async uploadCustomCertificate(
+    id: string,
+    certificate: string,
+    key: string,
+  ): Promise<ApiResponse<{ certificate: CustomCertificate; applied: boolean }>> {
+    return this.request(`/api/proxies/${id}/certificate`, {
+      method: "PUT",
+      body: JSON.stringify({ certificate, key }),
+    });
+  }
+
+  async deleteCustomCertificate(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/proxies/${id}/certificate`, {
+      method: "DELETE",
+    });
+  }
+
+  async getHealthChecks(): Promise<ApiResponse<{ paused: boolean; checked_proxies: number }>> {
+    return this.request("/api/health-checks");
+  }
+
+  // Pauses or resumes every health check
+  async pauseHealthChecks(paused: boolean): Promise<ApiResponse<{ paused: boolean; checked_proxies: number }>> {
+    return this.request("/api/health-checks/pause", {
+      method: "PUT",
+      body: JSON.stringify({ paused }),
+    });
+  }
+
+  async pauseProxyHealthCheck(
+    id: string,
+    paused: boolean,
+  ): Promise<ApiResponse<{ status: string; last_checked: string; message: string; paused?: boolean }>> {
+    return this.request(`/api/proxies/${id}/health-check/pause`, {
+      method: "PUT",
+      body: JSON.stringify({ paused }),
+    });
+  }
+
+  async getDNSProviders(): Promise<ApiResponse<{ providers: DNSProvider[] }>> {
+    return this.request("/api/dns-providers");
+  }
+
+  async getCertificates(): Promise<ApiResponse<{ certificates: Certificate[]; count: number }>> {
+    return this.request("/api/certificates");
+  }
+
+  async getUsers(): Promise<ApiResponse<{ success: boolean; users: User[] }>> {
+    return this.request("/api/users");
+  }
+
+  async createUser(user: {
+    username: string;
+    password: string;
+    role?: User["role"];
+  }): Promise<ApiResponse<{ success: boolean; user: User }>> {
+    return this.request("/api/users", {
+      method: "POST",
+      body: JSON.stringify(user),
+    });
+  }
+
+  async getUser(id: string): Promise<ApiResponse<{ success: boolean; user: User }>> {
+    return this.request(`/api/users/${id}`);
+  }
+
+  async updateUser(
+    id: string,
+    update: { role?: User["role"]; password?: string; disabled?: boolean },
+  ): Promise<ApiResponse<{ success: boolean; user: User }>> {
+    return this.request(`/api/users/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(update),
+    });
+  }
+
+  async deleteUser(id: string): Promise<ApiResponse<{ success: boolean; message: string }>> {
+    return this.request(`/api/users/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async getAPITokens(): Promise<ApiResponse<{ success: boolean; tokens: APIToken[] }>> {
+    return this.request("/api/tokens");
+  }
+
+  async createAPIToken(token: {
+    name: string;
+    scopes: ("read" | "write")[];
+    proxy_ids?: string[];
+    tags?: string[];
+    expires_in_days?: number;
+  }): Promise<ApiResponse<{ success: boolean; token: string; api_token: APIToken }>> {
+    return this.request("/api/tokens", {
+      method: "POST",
+      body: JSON.stringify(token),
+    });
+  }
+
+  async deleteAPIToken(id: string): Promise<ApiResponse<{ success: boolean; message: string }>> {
+    return this.request(`/api/tokens/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async generateSecret(
+    type: "password" | "token" | "htpasswd" = "password",
+    options: { length?: number; username?: string } = {},
+  ): Promise<ApiResponse<{ type: string; value: string; username?: string; hash?: string; htpasswd?: string }>> {
+    const params = new URLSearchParams({ type });
+    if (options.length) params.set("length", String(options.length));
+    if (options.username) params.set("username", options.username);
+    return this.request(`/api/generate/secret?${params}`);
+  }
+
+  async getSavedSearches(): Promise<ApiResponse<{ success: boolean; saved_searches: SavedSearch[] }>> {
+    return this.request("/api/saved-searches");
+  }
+
+  async createSavedSearch(search: {
+    name: string;
+    filters: ProxyFilters;
+    shared?: boolean;
+  }): Promise<ApiResponse<{ success: boolean; saved_search: SavedSearch }>> {
+    return this.request("/api/saved-searches", {
+      method: "POST",
+      body: JSON.stringify(search),
+    });
+  }
+
+  async updateSavedSearch(
+    id: string,
+    search: { name: string; filters: ProxyFilters; shared?: boolean },
+  ): Promise<ApiResponse<{ success: boolean; saved_search: SavedSearch }>> {
+    return this.request(`/api/saved-searches/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(search),
+    });
+  }
+
+  async deleteSavedSearch(id: string): Promise<ApiResponse<{ success: boolean; message: string }>> {
+    return this.request(`/api/saved-searches/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async getPageTemplates(): Promise<ApiResponse<{ templates: PageTemplate[]; kinds: string[]; count: number }>> {
+    return this.request("/api/pages");
+  }
+
+  async getPageTemplate(kind: string, language: string): Promise<ApiResponse<PageTemplate>> {
+    return this.request(`/api/pages/${kind}/${language}`);
+  }
+
+  async savePageTemplate(
+    kind: string,
+    language: string,
+    body: string,
+  ): Promise<ApiResponse<{ template: PageTemplate; applied: boolean }>> {
+    return this.request(`/api/pages/${kind}/${language}`, {
+      method: "PUT",
+      body: JSON.stringify({ body }),
+    });
+  }
+
+  async deletePageTemplate(kind: string, language: string): Promise<ApiResponse<{ message: string; applied: boolean }>> {
+    return this.request(`/api/pages/${kind}/${language}`, {
+      method: "DELETE",
+    });
+  }
+
+  async getFeatures(): Promise<ApiResponse<Features>> {
+    return this.request("/api/features");
+  }
+
   async getStatus(): Promise<ApiResponse<StatusResponse>> {
     return this.request("/api/status");
   }
@@ -186,8 +1039,9 @@ class ApiClient {
     });
   }
 
-  async getRedirects(): Promise<ApiResponse<RedirectsResponse>> {
-    return this.request("/api/redirects");
+  async getRedirects(filters?: RedirectFilters): Promise<ApiResponse<RedirectsResponse>> {
+    const query = new URLSearchParams(filters as Record<string, string>).toString();
+    return this.request(`/api/redirects${query ? `?${query}` : ""}`);
   }
 
   async createRedirect(redirect: {
@@ -195,6 +1049,8 @@ class ApiClient {
     destination_url: string;
     redirect_code?: number;
     preserve_path?: boolean;
+    tags?: string[];
+    notes?: string;
   }): Promise<ApiResponse<Redirect>> {
     return this.request("/api/redirects", {
       method: "POST",
@@ -209,6 +1065,8 @@ class ApiClient {
       destination_url: string;
       redirect_code?: number;
       preserve_path?: boolean;
+      tags?: string[];
+      notes?: string;
     },
   ): Promise<ApiResponse<Redirect>> {
     return this.request(`/api/redirects/${id}`, {
@@ -217,6 +1075,305 @@ class ApiClient {
     });
   }
 
+  async importCSV(
+    type: "proxies" | "redirects",
+    csv: string,
+    dryRun = false,
+  ): Promise<ApiResponse<CSVImportResponse>> {
+    return this.request(`/api/import/csv?type=${type}&dry_run=${dryRun}`, {
+      method: "POST",
+      headers: { "Content-Type": "text/csv" },
+      body: csv,
+    });
+  }
+
+  async getAccessLists(): Promise<ApiResponse<{ access_lists: AccessList[]; count: number }>> {
+    return this.request("/api/access-lists");
+  }
+
+  async createAccessList(list: AccessListInput): Promise<ApiResponse<AccessList>> {
+    return this.request("/api/access-lists", {
+      method: "POST",
+      body: JSON.stringify(list),
+    });
+  }
+
+  async updateAccessList(id: string, list: AccessListInput): Promise<ApiResponse<AccessList>> {
+    return this.request(`/api/access-lists/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(list),
+    });
+  }
+
+  async deleteAccessList(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/access-lists/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async getProxyTemplates(): Promise<ApiResponse<{ templates: ProxyTemplate[]; count: number }>> {
+    return this.request("/api/templates");
+  }
+
+  async createProxyTemplate(template: ProxyTemplateInput): Promise<ApiResponse<ProxyTemplate>> {
+    return this.request("/api/templates", {
+      method: "POST",
+      body: JSON.stringify(template),
+    });
+  }
+
+  async updateProxyTemplate(id: string, template: ProxyTemplateInput): Promise<ApiResponse<ProxyTemplate>> {
+    return this.request(`/api/templates/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(template),
+    });
+  }
+
+  async deleteProxyTemplate(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/templates/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  // Fields besides domain and target_url override the template's
+  async createProxyFromTemplate(
+    id: string,
+    proxy: { domain: string; target_url: string } & ProxyTemplateSettings
+  ): Promise<ApiResponse<Proxy>> {
+    return this.request(`/api/templates/${id}/proxies`, {
+      method: "POST",
+      body: JSON.stringify(proxy),
+    });
+  }
+
+  async getNotificationTypes(): Promise<ApiResponse<{ types: NotificationChannelType[]; events: NotificationEvent[]; change_events: NotificationChangeEvent[] }>> {
+    return this.request("/api/notifications/types");
+  }
+
+  async getNotificationChannels(): Promise<ApiResponse<{ channels: NotificationChannel[]; count: number }>> {
+    return this.request("/api/notifications");
+  }
+
+  async createNotificationChannel(channel: NotificationChannelInput): Promise<ApiResponse<NotificationChannel>> {
+    return this.request("/api/notifications", {
+      method: "POST",
+      body: JSON.stringify(channel),
+    });
+  }
+
+  async updateNotificationChannel(id: string, channel: NotificationChannelInput): Promise<ApiResponse<NotificationChannel>> {
+    return this.request(`/api/notifications/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(channel),
+    });
+  }
+
+  async deleteNotificationChannel(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/notifications/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async testNotificationChannel(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/notifications/${id}/test`, {
+      method: "POST",
+    });
+  }
+
+  async getServerSettings(): Promise<ApiResponse<ServerSettings & { available_servers: string[] }>> {
+    return this.request("/api/settings/server");
+  }
+
+  async updateServerSettings(settings: ServerSettings): Promise<ApiResponse<ServerSettings & { available_servers: string[] }>> {
+    return this.request("/api/settings/server", {
+      method: "PUT",
+      body: JSON.stringify(settings),
+    });
+  }
+
+  async getStorageSettings(): Promise<ApiResponse<StorageSettings>> {
+    return this.request("/api/settings/storage");
+  }
+
+  async updateStorageSettings(settings: StorageSettings): Promise<ApiResponse<StorageSettings>> {
+    return this.request("/api/settings/storage", {
+      method: "PUT",
+      body: JSON.stringify(settings),
+    });
+  }
+
+  async getDefaultSite(): Promise<ApiResponse<DefaultSite>> {
+    return this.request("/api/settings/default-site");
+  }
+
+  async updateDefaultSite(site: DefaultSite): Promise<ApiResponse<DefaultSite>> {
+    return this.request("/api/settings/default-site", {
+      method: "PUT",
+      body: JSON.stringify(site),
+    });
+  }
+
+  async getWAFViolations(proxyId?: string, limit?: number): Promise<ApiResponse<{ violations: WAFViolation[]; count: number }>> {
+    const params = new URLSearchParams();
+    if (proxyId) params.set("proxy", proxyId);
+    if (limit) params.set("limit", String(limit));
+    const query = params.toString();
+    return this.request(`/api/waf/violations${query ? `?${query}` : ""}`);
+  }
+
+  async getStreams(): Promise<ApiResponse<{ streams: StreamProxy[]; count: number }>> {
+    return this.request("/api/streams");
+  }
+
+  async createStream(stream: StreamInput): Promise<ApiResponse<StreamProxy>> {
+    return this.request("/api/streams", {
+      method: "POST",
+      body: JSON.stringify(stream),
+    });
+  }
+
+  async createPortForward(
+    forward: PortForwardInput,
+  ): Promise<ApiResponse<{ stream: StreamProxy; reachability: Reachability }>> {
+    return this.request("/api/streams/port-forward", {
+      method: "POST",
+      body: JSON.stringify(forward),
+    });
+  }
+
+  async getStreamReachability(id: string): Promise<ApiResponse<Reachability>> {
+    return this.request(`/api/streams/${id}/reachability`);
+  }
+
+  async updateStream(id: string, stream: StreamInput): Promise<ApiResponse<StreamProxy>> {
+    return this.request(`/api/streams/${id}`, {
+      method: "PUT",
+      body: JSON.stringify(stream),
+    });
+  }
+
+  async deleteStream(id: string): Promise<ApiResponse<{ message: string }>> {
+    return this.request(`/api/streams/${id}`, {
+      method: "DELETE",
+    });
+  }
+
+  async migrateIDs(): Promise<ApiResponse<{ renamed: IDRename[] }>> {
+    return this.request("/api/ids/migrate", { method: "POST" });
+  }
+
+  // Streams server events until signal is aborted. EventSource can't send the Authorization header,
+  // so the stream is read with fetch.
+  async subscribeEvents(
+    onEvent: (event: ServerEvent) => void,
+    signal: AbortSignal,
+  ): Promise<void> {
+    const headers: Record<string, string> = { Accept: "text/event-stream" };
+    const token = localStorage.getItem("auth_token");
+    if (token) {
+      headers["Authorization"] = `Bearer ${token}`;
+    }
+
+    const response = await fetch(`${this.baseUrl}/api/events`, { headers, signal });
+    if (!response.ok || !response.body) {
+      throw new Error(`Failed to subscribe to events: HTTP ${response.status}`);
+    }
+
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        return;
+      }
+
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf("\n\n")) !== -1) {
+        const message = buffer.slice(0, end);
+        buffer = buffer.slice(end + 2);
+
+        const data = message
+          .split("\n")
+          .filter((line) => line.startsWith("data: "))
+          .map((line) => line.slice(6))
+          .join("\n");
+        if (data) {
+          onEvent(JSON.parse(data) as ServerEvent);
+        }
+      }
+    }
+  }
+
+  async downloadBackup(): Promise<Blob> {
+    const headers: Record<string, string> = {};
+    const token = localStorage.getItem("auth_token");
+    if (token) {
+      headers["Authorization"] = `Bearer ${token}`;
+    }
+
+    const response = await fetch(`${this.baseUrl}/api/backup`, { headers });
+    if (!response.ok) {
+      throw new Error(`Failed to download backup: HTTP ${response.status}`);
+    }
+    return response.blob();
+  }
+
+  // Returns the config with every secret masked, for sharing when asking for help
+  async exportConfig(): Promise<ApiResponse<Record<string, unknown>>> {
+    return this.request("/api/config/export");
+  }
+
+  // Returns the managed proxies and redirects as a Caddyfile
+  async exportCaddyfile(): Promise<string> {
+    const headers: Record<string, string> = {};
+    const token = localStorage.getItem("auth_token");
+    if (token) {
+      headers["Authorization"] = `Bearer ${token}`;
+    }
+
+    const response = await fetch(`${this.baseUrl}/api/export/caddyfile`, { headers });
+    if (!response.ok) {
+      throw new Error(`Failed to export Caddyfile: HTTP ${response.status}`);
+    }
+    return response.text();
+  }
+
+  async restoreBackup(
+    archive: Blob,
+  ): Promise<ApiResponse<{ message: string; created: string; files: number; proxies: number; secrets_key: boolean }>> {
+    return this.request("/api/restore", {
+      method: "POST",
+      headers: { "Content-Type": "application/gzip" },
+      body: archive,
+    });
+  }
+
+  // Lists the encrypted backups on the S3 or WebDAV target; 404 when none is configured
+  async getRemoteBackups(): Promise<ApiResponse<RemoteBackups>> {
+    return this.request("/api/backup/remote");
+  }
+
+  async createRemoteBackup(): Promise<ApiResponse<{ name: string }>> {
+    return this.request("/api/backup/remote", { method: "POST" });
+  }
+
+  async restoreRemoteBackup(
+    name: string,
+  ): Promise<ApiResponse<{ message: string; created: string; files: number; proxies: number; secrets_key: boolean }>> {
+    return this.request(`/api/backup/remote/${encodeURIComponent(name)}/restore`, { method: "POST" });
+  }
+
+  async getAuditAnalysis(
+    window = "24h",
+  ): Promise<ApiResponse<{ findings: SecurityFinding[]; count: number; since: string }>> {
+    return this.request(`/api/audit-log/analysis?window=${encodeURIComponent(window)}`);
+  }
+
+  async getUsageReport(checkRedirects = true): Promise<ApiResponse<UsageReport>> {
+    return this.request(`/api/reports/usage?check_redirects=${checkRedirects}`);
+  }
+
   async deleteRedirect(id: string): Promise<ApiResponse<{ message: string }>> {
     return this.request(`/api/redirects/${id}`, {
       method: "DELETE",
@@ -1,8 +1,10 @@
-import { api } from './api'
+import { api, type SavedSearch } from './api'
 
 export interface User {
   id: string
   username: string
+  role: 'admin' | 'read_only' | 'config_viewer'
+  disabled?: boolean
   created: string
   updated: string
 }
@@ -26,11 +28,18 @@ export interface LoginRequest {
 export interface SetupRequest {
   username: string
   password: string
+  bootstrap_token: string
+}
+
+export interface ChangePasswordRequest {
+  current_password: string
+  new_password: string
 }
 
 export interface UserResponse {
   success: boolean
   user?: User
+  saved_searches?: SavedSearch[] // the user's own and those shared by others
 }
 
 class AuthService {
@@ -114,6 +123,24 @@ class AuthService {
     return result
   }
 
+  async changePassword(data: ChangePasswordRequest): Promise<AuthResponse> {
+    const response = await fetch(`${api.baseUrl}/auth/change-password`, {
+      method: 'POST',
+      headers: this.setAuthHeaders(),
+      body: JSON.stringify(data)
+    })
+
+    const result: AuthResponse = await response.json()
+
+    // Other sessions, including the previous one, were ended
+    if (result.success && result.token) {
+      this.token = result.token
+      localStorage.setItem('auth_token', result.token)
+    }
+
+    return result
+  }
+
   async getCurrentUser(): Promise<UserResponse> {
     if (!this.token) {
       throw new Error('Not authenticated')